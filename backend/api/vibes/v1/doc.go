@@ -0,0 +1,12 @@
+// Package vibesv1 holds the protobuf definition of the gRPC packet feed.
+//
+// The generated code is not committed. To build the server with the feed enabled:
+//
+//	go install google.golang.org/protobuf/cmd/protoc-gen-go@latest
+//	go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@latest
+//	go get google.golang.org/grpc google.golang.org/protobuf
+//	go generate ./api/...
+//	go build -tags grpc ./cmd
+package vibesv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative vibes.proto
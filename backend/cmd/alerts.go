@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/notify"
+)
+
+// alertAckMessage tells every room an alert was acknowledged, so wall screens showing it can
+// clear it together
+type alertAckMessage struct {
+	Type      string `json:"type"` // always "alert_ack"
+	Key       string `json:"key"`
+	Alert     string `json:"alert"` // the alert's type
+	Kind      string `json:"kind,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Severity  string `json:"severity"`
+	AckedBy   string `json:"acked_by,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// handleAlerts lists the alerts handed to the webhooks lately, acknowledged or not:
+// GET /api/alerts. Unacknowledged ones come first, the most severe at the top.
+func (manager *ClientManager) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts": manager.notifier.Load().Open(),
+	})
+}
+
+// handleAlertAck acknowledges an open alert, which stops its escalation:
+// POST /api/alerts/ack with {"key": ..., "by": "alice"}, or ?key=&by=
+func (manager *ClientManager) handleAlertAck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Key string `json:"key"`
+		By  string `json:"by"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	query := r.URL.Query()
+	if req.Key == "" {
+		req.Key = query.Get("key")
+	}
+	if req.By == "" {
+		req.By = query.Get("by")
+	}
+	if req.Key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	if req.By == "" {
+		req.By = r.RemoteAddr
+	}
+	alert, err := manager.notifier.Load().Ack(req.Key, req.By)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	manager.auditRequest(r, "ack_alert", alert.Room, map[string]interface{}{"key": alert.Key, "by": req.By})
+	log.Printf("✅ Alert %s acknowledged by %s", alert.Key, alert.AckedBy)
+	manager.broadcastAlertAck(alert)
+	json.NewEncoder(w).Encode(alert)
+}
+
+// broadcastAlertAck tells the rooms that got an alert it was acknowledged: every room for a
+// server-wide alert, bar anonymized rooms masking its subject, or the room that raised it
+func (manager *ClientManager) broadcastAlertAck(alert notify.OpenAlert) {
+	data, err := json.Marshal(alertAckMessage{
+		Type:      "alert_ack",
+		Key:       alert.Key,
+		Alert:     alert.Type,
+		Kind:      alert.Kind,
+		Subject:   alert.Subject,
+		Severity:  alert.Severity,
+		AckedBy:   alert.AckedBy,
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return
+	}
+	manager.clientsMutex.RLock()
+	defer manager.clientsMutex.RUnlock()
+	for client := range manager.clients {
+		if alert.Room != "" && client.room.name != alert.Room {
+			continue
+		}
+		if alert.Room != "" || !manager.masks(client, alert.Subject) {
+			client.enqueue(data)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/timeline"
+)
+
+const (
+	maxAnnotationLength = 500
+	maxMarkerLength     = 32
+)
+
+// annotationMessage is an operator's note to a room, sent when it is made and again when a time
+// window replay reaches it
+type annotationMessage struct {
+	Type      string      `json:"type"` // always "annotation"
+	Room      string      `json:"room"`
+	Text      string      `json:"text"`
+	Marker    string      `json:"marker,omitempty"`
+	Timestamp int64       `json:"timestamp"`        // ms, when it was made
+	Replay    bool        `json:"replay,omitempty"` // sent by time window playback
+	ID        interface{} `json:"id,omitempty"`
+}
+
+// ToJSON converts an annotation to JSON
+func (m *annotationMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func newAnnotationMessage(room *Room, event timeline.Event, replay bool) *annotationMessage {
+	return &annotationMessage{
+		Type:      "annotation",
+		Room:      room.name,
+		Text:      event.Text,
+		Marker:    event.Marker,
+		Timestamp: event.Time.UnixMilli(),
+		Replay:    replay,
+	}
+}
+
+// handleAnnotateCommand serves the annotate WebSocket command: the note goes to everyone in the
+// room and onto the timeline
+func (manager *ClientManager) handleAnnotateCommand(msg map[string]interface{}, client *Client) {
+	text := strings.TrimSpace(msg["text"].(string))
+	marker, _ := msg["marker"].(string)
+	var protoErr *protocolError
+	switch {
+	case text == "":
+		protoErr = newProtocolError(errCodeInvalidField, "annotate", "text", "annotation text is empty")
+	case len(text) > maxAnnotationLength:
+		protoErr = newProtocolError(errCodeInvalidField, "annotate", "text", "annotation text is longer than %d bytes", maxAnnotationLength)
+	case len(marker) > maxMarkerLength:
+		protoErr = newProtocolError(errCodeInvalidField, "annotate", "marker", "marker is longer than %d bytes", maxMarkerLength)
+	}
+	if protoErr != nil {
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+
+	event := timeline.Event{
+		Time:   time.Now(),
+		Kind:   timeline.KindAnnotation,
+		Room:   client.room.name,
+		Text:   text,
+		Marker: marker,
+		Actor:  client.conn.RemoteAddr().String(),
+	}
+	manager.recordTimeline(event)
+	log.Printf("🗒️ Room %s: annotation %q", client.room.name, text)
+
+	reply := newAnnotationMessage(client.room, event, false)
+	for _, member := range manager.roomClients(client.room) {
+		if member != client {
+			member.trySend(reply)
+		}
+	}
+	withID := *reply
+	withID.ID = requestID(msg)
+	client.trySend(&withID)
+}
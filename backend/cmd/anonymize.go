@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// setupAnonymizer prepares IP pseudonymization when -anonymize or any room's config asks for it.
+// A passphrase from -anonymize-key or $VIBES_ANONYMIZE_KEY keeps pseudonyms stable across
+// restarts; without one a random key is used and they change every time the server starts.
+func (manager *ClientManager) setupAnonymizer() error {
+	needed := *anonymize
+	for _, room := range manager.cfg.Rooms {
+		if room.Anonymize != nil && *room.Anonymize {
+			needed = true
+		}
+	}
+	if !needed {
+		return nil
+	}
+
+	key := make([]byte, enrich.AnonymizeKeySize)
+	passphrase := *anonymizeKey
+	if passphrase == "" {
+		passphrase = os.Getenv("VIBES_ANONYMIZE_KEY")
+	}
+	if passphrase != "" {
+		sum := sha512.Sum512([]byte(passphrase))
+		copy(key, sum[:])
+	} else {
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("generating anonymization key: %v", err)
+		}
+		log.Printf("⚠️ No -anonymize-key: pseudonymized addresses will change when the server restarts")
+	}
+
+	var nets []string
+	for _, cidr := range strings.Split(*anonymizeNets, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			nets = append(nets, cidr)
+		}
+	}
+	anonymizer, err := enrich.NewAnonymizer(key, nets)
+	if err != nil {
+		return err
+	}
+	manager.anonymizer = anonymizer
+	return nil
+}
+
+// anonymizes reports whether a room streams pseudonymized addresses: its config decides,
+// and -anonymize covers rooms that don't say
+func (manager *ClientManager) anonymizes(room *Room) bool {
+	if manager.anonymizer == nil {
+		return false
+	}
+	if room.config.Anonymize != nil {
+		return *room.config.Anonymize
+	}
+	return *anonymize
+}
+
+// masks reports whether a client must not see an address in the clear
+func (manager *ClientManager) masks(client *Client, ip string) bool {
+	return client.room.anonymized && manager.anonymizer.Masks(ip)
+}
+
+// pinMatcher matches packets with real addresses against a room's pinning rules, which in an
+// anonymized room name pseudonyms
+func (manager *ClientManager) pinMatcher(room *Room) func(p *capture.Packet) bool {
+	return func(p *capture.Packet) bool {
+		src, dst := p.Src, p.Dst
+		if room.anonymized {
+			src, dst = manager.anonymizer.Address(src), manager.anonymizer.Address(dst)
+		}
+		return room.pins.Matches(src, dst, p.SrcPort, p.DstPort, p.Protocol)
+	}
+}
+
+// anonymizePacket returns a copy of a packet with its addresses pseudonymized. The original
+// frame is dropped so nothing downstream, recordings included, sees the real addresses.
+func (manager *ClientManager) anonymizePacket(packet *capture.Packet) *capture.Packet {
+	masked := *packet
+	masked.Src = manager.anonymizer.Address(packet.Src)
+	masked.Dst = manager.anonymizer.Address(packet.Dst)
+	if packet.SrcNAT != "" {
+		masked.SrcNAT = manager.anonymizer.Address(packet.SrcNAT)
+	}
+	if packet.DstNAT != "" {
+		masked.DstNAT = manager.anonymizer.Address(packet.DstNAT)
+	}
+	masked.Raw = nil
+	return &masked
+}
+
+// roomDarkSpaceAlert returns a dark-space alert as the room sees it, with pseudonymized
+// addresses in anonymized rooms
+func (manager *ClientManager) roomDarkSpaceAlert(room *Room, alert *capture.DarkSpaceAlert) *capture.DarkSpaceAlert {
+	if !room.anonymized {
+		return alert
+	}
+	masked := *alert
+	masked.IP = manager.anonymizer.Address(alert.IP)
+	masked.Dst = manager.anonymizer.Address(alert.Dst)
+	return &masked
+}
+
+// roomExfilAlert returns an exfiltration alert as the room sees it, with pseudonymized
+// addresses in anonymized rooms
+func (manager *ClientManager) roomExfilAlert(room *Room, alert *capture.ExfilAlert) *capture.ExfilAlert {
+	if !room.anonymized {
+		return alert
+	}
+	masked := *alert
+	masked.IP = manager.anonymizer.Address(alert.IP)
+	masked.Dst = manager.anonymizer.Address(alert.Dst)
+	return &masked
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/storage"
+)
+
+// defaultCoverageGap is the largest hole between archive files still treated as continuous
+const defaultCoverageGap = 5 * time.Second
+
+// newArchiveStore opens -storage: a directory, or an s3://bucket/prefix read with -s3-endpoint,
+// -s3-region and the usual AWS credential variables
+func newArchiveStore(location string) (capture.ArchiveStore, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return capture.DirStore(location), nil
+	}
+	store, err := storage.NewS3Store(location, storage.S3Config{
+		Endpoint:     *s3Endpoint,
+		Region:       *s3Region,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// handleArchiveCoverage reports which time ranges the PCAP archive can play back or export.
+// ?gap=<duration> sets how large a hole between files still counts as continuous (default 5s).
+func (manager *ClientManager) handleArchiveCoverage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	maxGap := defaultCoverageGap
+	if gapStr := r.URL.Query().Get("gap"); gapStr != "" {
+		gap, err := time.ParseDuration(gapStr)
+		if err != nil || gap < 0 {
+			http.Error(w, "invalid gap duration", http.StatusBadRequest)
+			return
+		}
+		maxGap = gap
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"storage_dir": *storageDir,
+		"files":       manager.archive.Len(),
+		"last_scan":   manager.archive.LastScan(),
+		"ranges":      manager.archive.Coverage(maxGap),
+	})
+}
+
+// handleArchiveHeatmap reports the archive's packets per minute over one day, so the replay UI
+// can show where the action is before a window is picked. ?day=YYYY-MM-DD (default today) is
+// taken in ?tz= (an IANA zone, default the server's); ?by=protocol adds counts per protocol.
+func (manager *ClientManager) handleArchiveHeatmap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	loc := time.Local
+	if tz := query.Get("tz"); tz != "" {
+		zone, err := time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, "invalid tz", http.StatusBadRequest)
+			return
+		}
+		loc = zone
+	}
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if dayStr := query.Get("day"); dayStr != "" {
+		day, err := time.ParseInLocation("2006-01-02", dayStr, loc)
+		if err != nil {
+			http.Error(w, "invalid day, want YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		start = day
+	}
+	var byProtocol bool
+	switch by := query.Get("by"); by {
+	case "":
+	case "protocol":
+		byProtocol = true
+	default:
+		http.Error(w, "by must be protocol", http.StatusBadRequest)
+		return
+	}
+
+	// AddDate rather than 24h so days with a DST change get 23 or 25 hours
+	heatmap := manager.archive.Heatmap(start, start.AddDate(0, 0, 1), byProtocol)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"day":       start.Format("2006-01-02"),
+		"tz":        loc.String(),
+		"last_scan": manager.archive.LastScan(),
+		"heatmap":   heatmap,
+	})
+}
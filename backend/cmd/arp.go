@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// knownHostsChunk bounds how many hosts one known_hosts message carries
+const knownHostsChunk = 1000
+
+// knownHostsMessage seeds the graph with hosts the gateways know, before (or without) the tap
+// seeing them send
+type knownHostsMessage struct {
+	Type      string      `json:"type"` // always "known_hosts"
+	Hosts     []knownHost `json:"hosts"`
+	Removed   []string    `json:"removed,omitempty"` // forgotten by every gateway
+	Full      bool        `json:"full,omitempty"`    // the whole table, sent when the session starts
+	Timestamp int64       `json:"timestamp"`
+}
+
+type knownHost struct {
+	IP        string `json:"ip"`
+	MAC       string `json:"mac,omitempty"`
+	Interface string `json:"interface,omitempty"` // the gateway's interface or VLAN
+}
+
+// ToJSON converts known hosts to JSON
+func (m *knownHostsMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// setupARP builds the neighbor table of the config file's arp section and keeps it current from
+// the gateways' dumps; live sessions hear about hosts as they appear and are forgotten
+func (manager *ClientManager) setupARP(cfg *config.ARP) error {
+	if cfg == nil {
+		return nil
+	}
+	neighbors, err := enrich.NewNeighborTable(cfg)
+	if err != nil {
+		return err
+	}
+	neighbors.OnChange = manager.broadcastKnownHosts
+	switch {
+	case cfg.File != "":
+		log.Printf("🗺️ ARP table: reading %s dumps from %s", cfg.Source, cfg.File)
+	case len(cfg.Command) > 0:
+		log.Printf("🗺️ ARP table: running %q", cfg.Command)
+	default:
+		log.Printf("🗺️ ARP table: from POST /api/arp")
+	}
+	go neighbors.Run(make(chan struct{}))
+	manager.neighbors = neighbors
+	return nil
+}
+
+// knownHosts converts table entries for a session. Anonymized rooms never learn which attendee
+// addresses exist, and rooms that hide hostnames don't get MACs.
+func (manager *ClientManager) knownHosts(client *Client, entries []enrich.NeighborEntry) []knownHost {
+	hosts := make([]knownHost, 0, len(entries))
+	for _, entry := range entries {
+		if manager.masks(client, entry.IP) {
+			continue
+		}
+		host := knownHost{IP: entry.IP, Interface: entry.Interface}
+		if client.room.exposure.Hostnames {
+			host.MAC = entry.MAC
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// sendKnownHosts seeds a starting session with the whole neighbor table. Replays and simulations
+// show another network, so they get nothing.
+func (manager *ClientManager) sendKnownHosts(client *Client) {
+	if manager.neighbors == nil {
+		return
+	}
+	if _, live := client.liveInterface(); !live {
+		return
+	}
+	hosts := manager.knownHosts(client, manager.neighbors.Entries())
+	now := time.Now().UnixMilli()
+	for start := 0; start < len(hosts); start += knownHostsChunk {
+		end := min(start+knownHostsChunk, len(hosts))
+		client.trySend(&knownHostsMessage{Type: "known_hosts", Hosts: hosts[start:end], Full: true, Timestamp: now})
+	}
+}
+
+// broadcastKnownHosts tells live sessions about hosts that appeared in, or were forgotten by, the
+// neighbor table
+func (manager *ClientManager) broadcastKnownHosts(added []enrich.NeighborEntry, removed []string) {
+	manager.clientsMutex.RLock()
+	clients := make([]*Client, 0, len(manager.clients))
+	for client := range manager.clients {
+		clients = append(clients, client)
+	}
+	manager.clientsMutex.RUnlock()
+
+	now := time.Now().UnixMilli()
+	for _, client := range clients {
+		if _, live := client.liveInterface(); !live {
+			continue
+		}
+		msg := &knownHostsMessage{Type: "known_hosts", Hosts: manager.knownHosts(client, added), Timestamp: now}
+		for _, ip := range removed {
+			if !manager.masks(client, ip) {
+				msg.Removed = append(msg.Removed, ip)
+			}
+		}
+		if len(msg.Hosts) > 0 || len(msg.Removed) > 0 {
+			client.trySend(msg)
+		}
+	}
+}
+
+// handleARP lists and feeds the neighbor table, for gateways that push their ARP and neighbor
+// tables rather than being polled:
+//
+//	GET  /api/arp   the known hosts
+//	POST /api/arp   [{"ip":...,"mac":...,"interface":"vlan30"}]
+func (manager *ClientManager) handleARP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if manager.neighbors == nil {
+		http.Error(w, "host seeding is off: the config file has no arp section", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(manager.neighbors.Entries())
+
+	case http.MethodPost:
+		var entries []enrich.NeighborEntry
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		added, learned, errors := manager.neighbors.Put(entries)
+		if errors == nil {
+			errors = []string{}
+		}
+		if len(added) > 0 {
+			manager.broadcastKnownHosts(added, nil)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"learned": learned, "added": len(added), "errors": errors})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// handleAssets serves CRUD for the asset inventory:
+//
+//	GET    /api/assets        list all assets
+//	POST   /api/assets        create or replace an asset (JSON body)
+//	GET    /api/assets/{ip}   fetch one asset
+//	PUT    /api/assets/{ip}   create or replace the asset for ip
+//	DELETE /api/assets/{ip}   remove the asset for ip
+func (manager *ClientManager) handleAssets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	ip := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/assets"), "/")
+
+	switch {
+	case ip == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(manager.assets.List())
+
+	case ip != "" && r.Method == http.MethodGet:
+		asset, ok := manager.assets.Get(ip)
+		if !ok {
+			http.Error(w, "asset not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(asset)
+
+	case (ip == "" && r.Method == http.MethodPost) || (ip != "" && r.Method == http.MethodPut):
+		var asset enrich.Asset
+		if err := json.NewDecoder(r.Body).Decode(&asset); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ip != "" {
+			asset.IP = ip
+		}
+		if err := manager.assets.Put(&asset); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("🏷️ Asset %s labeled %q", asset.IP, asset.Label)
+		manager.auditRequest(r, "asset_save", "", map[string]interface{}{"ip": asset.IP, "asset": &asset})
+		manager.broadcastNodeInfo(asset.IP)
+		json.NewEncoder(w).Encode(&asset)
+
+	case ip != "" && r.Method == http.MethodDelete:
+		found, err := manager.assets.Delete(ip)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "asset not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("🏷️ Asset %s removed", ip)
+		manager.auditRequest(r, "asset_delete", "", map[string]interface{}{"ip": ip})
+		manager.broadcastNodeInfo(ip)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
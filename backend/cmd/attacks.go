@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/scenario"
+)
+
+// attackMessage tells a room that an attack from the library was triggered
+type attackMessage struct {
+	Type        string      `json:"type"` // always "scenario_triggered"
+	Room        string      `json:"room"`
+	Attack      string      `json:"attack"`
+	Kind        string      `json:"kind"`
+	Description string      `json:"description,omitempty"`
+	Source      string      `json:"source,omitempty"` // the attacking host, when there is only one
+	Target      string      `json:"target,omitempty"` // the victim, when there is only one
+	Sources     int         `json:"sources"`
+	Targets     int         `json:"targets"`
+	DurationS   float64     `json:"duration_s"`
+	Sessions    int         `json:"sessions"` // simulated sessions running it
+	Timestamp   int64       `json:"timestamp"`
+	ID          interface{} `json:"id,omitempty"`
+}
+
+// ToJSON converts an attack message to JSON
+func (m *attackMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// attackSummary describes an attack for /api/attacks
+type attackSummary struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Kind        string  `json:"kind"`
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Rate        float64 `json:"rate"`
+	Duration    string  `json:"duration"`
+}
+
+func summarizeAttack(a *scenario.Attack) attackSummary {
+	return attackSummary{
+		Name:        a.Name,
+		Description: a.Description,
+		Kind:        a.Event.Kind,
+		From:        a.Event.From,
+		To:          a.Event.To,
+		Rate:        a.Event.Rate,
+		Duration:    a.Event.Duration.String(),
+	}
+}
+
+// triggerOptions reads the optional overrides of a trigger_scenario command or /trigger body
+func triggerOptions(msg map[string]interface{}) (scenario.TriggerOptions, error) {
+	var opts scenario.TriggerOptions
+	var ok bool
+	if v, present := msg["source"]; present {
+		if opts.Source, ok = v.(string); !ok {
+			return opts, fmt.Errorf("source must be a string")
+		}
+	}
+	if v, present := msg["target"]; present {
+		if opts.Target, ok = v.(string); !ok {
+			return opts, fmt.Errorf("target must be a string")
+		}
+	}
+	if v, present := msg["duration"]; present {
+		seconds, ok := v.(float64)
+		if !ok || seconds <= 0 {
+			return opts, fmt.Errorf("duration must be a positive number of seconds")
+		}
+		opts.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	if v, present := msg["rate"]; present {
+		if opts.Rate, ok = v.(float64); !ok || opts.Rate <= 0 {
+			return opts, fmt.Errorf("rate must be a positive number")
+		}
+	}
+	return opts, nil
+}
+
+// triggerAttack starts an attack from the library in every simulated session of the room.
+// Sessions running the same scenario get the same attacker and victim.
+func (manager *ClientManager) triggerAttack(room *Room, name string, opts scenario.TriggerOptions) (*attackMessage, error) {
+	attack, err := scenario.BuiltinAttack(name)
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	// Resolving against no scenario checks the overrides even when nobody is simulating
+	first, err := attack.Resolve(nil, opts, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[*scenario.Scenario]*scenario.Triggered)
+	sessions := 0
+	for _, client := range manager.roomClients(room) {
+		sim := simulationOf(client.captureSource())
+		if sim == nil {
+			continue
+		}
+		sc := sim.Scenario()
+		t, ok := resolved[sc]
+		if !ok {
+			if t, err = attack.Resolve(sc, opts, rng); err != nil {
+				return nil, err
+			}
+			resolved[sc] = t
+			if sessions == 0 {
+				first = t
+			}
+		}
+		if sim.Trigger(t) {
+			sessions++
+		} else {
+			log.Printf("⚠️ Room %s: session %s is backlogged, attack %s not started there", room.name, client.conn.RemoteAddr(), name)
+		}
+	}
+
+	reply := &attackMessage{
+		Type:        "scenario_triggered",
+		Room:        room.name,
+		Attack:      attack.Name,
+		Kind:        first.Event.Kind,
+		Description: attack.Description,
+		Sources:     len(first.Sources),
+		Targets:     len(first.Targets),
+		DurationS:   first.Event.Duration.Seconds(),
+		Sessions:    sessions,
+		Timestamp:   time.Now().UnixMilli(),
+	}
+	if len(first.Sources) == 1 {
+		reply.Source = first.Sources[0]
+	}
+	if len(first.Targets) == 1 {
+		reply.Target = first.Targets[0]
+	}
+	log.Printf("🎯 Room %s: attack %s triggered on %d simulated sessions", room.name, attack.Name, sessions)
+	return reply, nil
+}
+
+// handleTriggerCommand serves the trigger_scenario WebSocket command; everyone in the room is told
+func (manager *ClientManager) handleTriggerCommand(msg map[string]interface{}, client *Client) {
+	opts, err := triggerOptions(msg)
+	if err != nil {
+		protoErr := newProtocolError(errCodeInvalidField, "trigger_scenario", "", "%v", err)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	reply, err := manager.triggerAttack(client.room, msg["name"].(string), opts)
+	if err != nil {
+		protoErr := newProtocolError(errCodeInvalidField, "trigger_scenario", "name", "%v", err)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	for _, member := range manager.roomClients(client.room) {
+		if member != client {
+			member.trySend(reply)
+		}
+	}
+	withID := *reply
+	withID.ID = requestID(msg)
+	client.trySend(&withID)
+}
+
+// handleAttacks lists the attack library and triggers attacks:
+//
+//	GET  /api/attacks                 the attack library
+//	GET  /api/attacks/{name}          one attack
+//	POST /api/attacks/{name}/trigger  run it in a room's simulated sessions (?room=, default "default");
+//	                                  the optional JSON body takes source, target, duration and rate
+func (manager *ClientManager) handleAttacks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/attacks"), "/")
+	trigger := false
+	if trimmed := strings.TrimSuffix(name, "/trigger"); trimmed != name {
+		name, trigger = trimmed, true
+	}
+
+	switch {
+	case trigger && r.Method == http.MethodPost:
+		room, err := manager.room(r.URL.Query().Get("room"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body := map[string]interface{}{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+		}
+		opts, err := triggerOptions(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := scenario.BuiltinAttack(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		reply, err := manager.triggerAttack(room, name, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body["name"] = name
+		manager.auditRequest(r, "trigger_scenario", room.name, body)
+		manager.sendToRoom(room, reply)
+		json.NewEncoder(w).Encode(reply)
+
+	case name == "" && r.Method == http.MethodGet:
+		list := []attackSummary{}
+		for _, name := range scenario.AttackNames() {
+			if attack, err := scenario.BuiltinAttack(name); err == nil {
+				list = append(list, summarizeAttack(attack))
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case name != "" && r.Method == http.MethodGet:
+		attack, err := scenario.BuiltinAttack(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(summarizeAttack(attack))
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
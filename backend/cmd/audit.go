@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vibes-network-visualizer/internal/audit"
+)
+
+// auditCommand records a WebSocket command as received, before it runs
+func (manager *ClientManager) auditCommand(client *Client, msgType string, msg map[string]interface{}) {
+	params := make(map[string]interface{}, len(msg))
+	for key, value := range msg {
+		if key != "type" && key != "id" && key != "token" {
+			params[key] = value
+		}
+	}
+	manager.record(audit.Entry{
+		Actor:  client.conn.RemoteAddr().String(),
+		Via:    "websocket",
+		Action: msgType,
+		Room:   client.room.name,
+		Params: params,
+	})
+}
+
+// auditRequest records an API action once it has been carried out
+func (manager *ClientManager) auditRequest(r *http.Request, action, room string, params map[string]interface{}) {
+	manager.record(audit.Entry{
+		Actor:  r.RemoteAddr,
+		Via:    "http",
+		Action: action,
+		Room:   room,
+		Params: params,
+	})
+}
+
+func (manager *ClientManager) record(entry audit.Entry) {
+	if manager.auditLog == nil {
+		return
+	}
+	if err := manager.auditLog.Record(entry); err != nil {
+		log.Printf("⚠️ Audit log: %v", err)
+	}
+}
+
+// handleAudit queries the audit log:
+//
+//	GET /api/audit?since=&until=&action=&room=&actor=&limit=
+//
+// since and until are RFC 3339; the most recent limit entries (200 by default) come back oldest first
+func (manager *ClientManager) handleAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if manager.auditLog == nil {
+		http.Error(w, "audit log disabled (-audit-log)", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	q := audit.Query{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Room:   query.Get("room"),
+	}
+	for _, bound := range []struct {
+		name string
+		into *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if value := query.Get(bound.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "invalid "+bound.name+" (RFC3339 required): "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*bound.into = t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		q.Limit = limit
+	}
+
+	entries, err := manager.auditLog.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/pins"
+)
+
+const (
+	// Jumping to a bookmark from live replays from a little before it...
+	bookmarkLeadIn = 30 * time.Second
+	// ...to a while after it, or now if that is sooner
+	bookmarkWindow = 10 * time.Minute
+)
+
+// bookmarkMessage tells a room that one of its sessions bookmarked a moment
+type bookmarkMessage struct {
+	Type     string         `json:"type"` // always "bookmark_added"
+	Bookmark *pins.Bookmark `json:"bookmark"`
+	ID       interface{}    `json:"id,omitempty"`
+}
+
+// ToJSON converts a bookmark message to JSON
+func (m *bookmarkMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// playbackTime is the moment the session is looking at: its time window's playback position, or now
+func (c *Client) playbackTime() time.Time {
+	c.room.mu.Lock()
+	defer c.room.mu.Unlock()
+	if processor := c.timeWindow(); processor != nil {
+		return processor.Position()
+	}
+	return time.Now()
+}
+
+// handleBookmarkCommand serves the bookmark WebSocket command: the moment the room is looking at
+// (or the given time) is stored with the room's pins, filter and sampling, and everyone in the
+// room is told
+func (manager *ClientManager) handleBookmarkCommand(msg map[string]interface{}, client *Client) {
+	room := client.room
+	bookmark := &pins.Bookmark{
+		Room:      room.name,
+		Pins:      room.pins.Rules(),
+		CreatedBy: client.conn.RemoteAddr().String(),
+	}
+	bookmark.Description, _ = msg["description"].(string)
+	if value, ok := msg["time"].(string); ok {
+		bookmark.Time, _ = time.Parse(time.RFC3339, value) // checked against the schema
+	} else {
+		bookmark.Time = client.playbackTime()
+	}
+	view := room.view.Load()
+	if view != nil {
+		bookmark.Preset, bookmark.Filter = view.Preset, view.FilterSpec
+	}
+	bookmark.SampleRate = view.sampleRate()
+
+	if err := manager.bookmarks.Add(bookmark); err != nil {
+		protoErr := newProtocolError(errCodeInvalidField, "bookmark", "description", "%v", err)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	log.Printf("🔖 Room %s: bookmark %s at %s", room.name, bookmark.ID, bookmark.Time.Format(time.RFC3339))
+
+	reply := &bookmarkMessage{Type: "bookmark_added", Bookmark: bookmark}
+	for _, member := range manager.roomClients(room) {
+		if member != client {
+			member.trySend(reply)
+		}
+	}
+	withID := *reply
+	withID.ID = requestID(msg)
+	client.trySend(&withID)
+}
+
+// applyBookmarkView gives the room the pins, filter and sampling it had when the bookmark was made
+func (manager *ClientManager) applyBookmarkView(room *Room, bookmark *pins.Bookmark) (*presetMessage, error) {
+	filter, err := pins.CompileView(bookmark.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := room.pins.Replace(bookmark.Pins); err != nil {
+		return nil, err
+	}
+	room.view.Store(&clientView{
+		Preset:     bookmark.Preset,
+		Filter:     filter,
+		FilterSpec: bookmark.Filter,
+		SampleRate: bookmark.SampleRate,
+	})
+	manager.pushWatchFilter(room)
+	return &presetMessage{
+		Type:       "preset_applied",
+		Room:       room.name,
+		Preset:     bookmark.Preset,
+		Pins:       room.pins.Rules(),
+		Filter:     bookmark.Filter,
+		SampleRate: bookmark.SampleRate,
+	}, nil
+}
+
+// seekToBookmark serves seek_to_time with a bookmark: the room gets the bookmark's view back and
+// plays its moment, seeking within the active time window or starting one around it
+func (manager *ClientManager) seekToBookmark(msg map[string]interface{}, client *Client, id string) {
+	room := client.room
+	seekError := func(message string) {
+		response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+			"type":  "seek_error",
+			"error": message,
+		}))
+		client.sendReply(response)
+	}
+	bookmark, ok := manager.bookmarks.Get(id)
+	if !ok {
+		seekError("no bookmark " + id)
+		return
+	}
+	view, err := manager.applyBookmarkView(room, bookmark)
+	if err != nil {
+		seekError(err.Error())
+		return
+	}
+	manager.sendToRoom(room, view)
+
+	room.mu.Lock()
+	replaying := client.timeWindow() != nil
+	room.mu.Unlock()
+	if replaying {
+		msg["time"] = bookmark.Time.Format(time.RFC3339)
+		manager.handleSeekToTime(msg, client)
+		return
+	}
+	end := bookmark.Time.Add(bookmarkWindow)
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	window := map[string]interface{}{
+		"type":       "select_time_window",
+		"start_time": bookmark.Time.Add(-bookmarkLeadIn).Format(time.RFC3339),
+		"end_time":   end.Format(time.RFC3339),
+	}
+	if id := requestID(msg); id != nil {
+		window["id"] = id
+	}
+	manager.handleTimeWindowCommand(window, room, nil, client.sendReply)
+}
+
+// handleBookmarks lists and removes bookmarks:
+//
+//	GET    /api/bookmarks            every bookmark (?room= for one room's), oldest moment first
+//	GET    /api/bookmarks/{id}       fetch one bookmark
+//	DELETE /api/bookmarks/{id}       remove a bookmark
+func (manager *ClientManager) handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/bookmarks"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(manager.bookmarks.List(r.URL.Query().Get("room")))
+
+	case id != "" && r.Method == http.MethodGet:
+		bookmark, ok := manager.bookmarks.Get(id)
+		if !ok {
+			http.Error(w, "bookmark not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(bookmark)
+
+	case id != "" && r.Method == http.MethodDelete:
+		found, err := manager.bookmarks.Delete(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "bookmark not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("🔖 Bookmark %s removed", id)
+		manager.auditRequest(r, "bookmark_delete", "", map[string]interface{}{"id": id})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
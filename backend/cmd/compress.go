@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+)
+
+const (
+	// compressDeflate is the only ?compress= scheme: one raw deflate stream (RFC 1951) per
+	// connection, flushed at the end of every binary frame
+	compressDeflate = "deflate"
+	// maxCompressBatch bounds the messages, in bytes before compression, packed into one frame
+	maxCompressBatch = 64 * 1024
+)
+
+// binaryWriter is a session connection that can carry binary frames; compression needs one
+type binaryWriter interface {
+	writeBinary(frame []byte) error
+}
+
+// negotiateCompression checks a session's ?compress= parameter
+func negotiateCompression(requested string) (string, error) {
+	switch requested {
+	case "", "0", "none":
+		return "", nil
+	case compressDeflate:
+		return compressDeflate, nil
+	}
+	return "", fmt.Errorf("unsupported compression %q (server supports %q)", requested, compressDeflate)
+}
+
+// frameCompressor packs a session's queued messages into compressed binary frames, one message
+// per line. The deflate stream carries on across frames, so later frames reuse the dictionary
+// of earlier ones; a frame decompresses fully once every frame before it has.
+type frameCompressor struct {
+	out    bytes.Buffer
+	writer *flate.Writer
+}
+
+func newFrameCompressor() *frameCompressor {
+	f := &frameCompressor{}
+	f.writer, _ = flate.NewWriter(&f.out, flate.DefaultCompression)
+	return f
+}
+
+// frame compresses a batch of messages. The result is only valid until the next call.
+func (f *frameCompressor) frame(messages [][]byte) ([]byte, error) {
+	f.out.Reset()
+	for _, message := range messages {
+		if _, err := f.writer.Write(message); err != nil {
+			return nil, err
+		}
+		if _, err := f.writer.Write([]byte{'\n'}); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.writer.Flush(); err != nil {
+		return nil, err
+	}
+	return f.out.Bytes(), nil
+}
+
+// writeCompressed sends first and whatever else is already queued, up to maxCompressBatch, as
+// one compressed frame. closed reports that the send queue was closed while draining it.
+func (c *Client) writeCompressed(manager *ClientManager, first []byte) (closed bool, err error) {
+	batch := [][]byte{first}
+	size := len(first)
+drain:
+	for size < maxCompressBatch {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				closed = true
+				break drain
+			}
+			batch = append(batch, message)
+			size += len(message)
+		default:
+			break drain
+		}
+	}
+
+	frame, err := c.compressor.frame(batch)
+	if err != nil {
+		return closed, err
+	}
+	if err := c.conn.(binaryWriter).writeBinary(frame); err != nil {
+		return closed, err
+	}
+	c.compressedBytes.Add(uint64(len(frame)))
+	for _, message := range batch {
+		c.wroteMessage(manager, message)
+	}
+	return closed, nil
+}
+
+// compression names the session's ?compress= scheme, "" for none
+func (c *Client) compression() string {
+	if c.compressor == nil {
+		return ""
+	}
+	return compressDeflate
+}
+
+// compressionRatio is the bytes of messages sent per byte on the wire, 0 when uncompressed
+func (c *Client) compressionRatio() float64 {
+	compressed := c.compressedBytes.Load()
+	if compressed == 0 {
+		return 0
+	}
+	return float64(c.sentBytes.Load()) / float64(compressed)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// newDeviceAlert is broadcast the first time a host is seen sending on a monitored subnet
+type newDeviceAlert struct {
+	Type      string `json:"type"` // always "new_device"
+	IP        string `json:"ip"`
+	MAC       string `json:"mac,omitempty"`
+	Hostname  string `json:"hostname,omitempty"` // from its DHCP lease
+	Timestamp int64  `json:"timestamp"`          // first seen (ms)
+}
+
+// setupDevices loads the device registry of the config file's devices section and saves it as
+// it grows
+func (manager *ClientManager) setupDevices(cfg *config.Devices) error {
+	if cfg == nil {
+		return nil
+	}
+	devices, err := enrich.NewDeviceRegistry(cfg, *devicesFile)
+	if err != nil {
+		return err
+	}
+	log.Printf("🆕 New-device detection on %v", devices.Subnets())
+	go devices.Run(make(chan struct{}))
+	manager.devices = devices
+	return nil
+}
+
+// observeDevice records the sender of a live packet and alerts every room when it has never
+// been seen before. Replays and simulations would fill the registry with hosts from elsewhere.
+func (manager *ClientManager) observeDevice(packet *capture.Packet) {
+	if manager.devices == nil || (packet.Source != "real" && packet.Source != "zeek") {
+		return
+	}
+	mac := ""
+	// A routed packet carries the router's MAC; only a TTL still at its initial value came
+	// straight from the host
+	switch packet.TTL {
+	case 64, 128, 255:
+		mac = packet.SrcMAC
+	}
+	var lease *enrich.Lease
+	if manager.leases != nil {
+		if leased, ok := manager.leases.Get(packet.Src); ok {
+			lease = leased
+			if leased.MAC != "" {
+				mac = leased.MAC
+			}
+		}
+	}
+	at := time.UnixMilli(packet.Timestamp)
+	device := manager.devices.Observe(packet.Src, mac, at)
+	if device == nil {
+		return
+	}
+
+	alert := newDeviceAlert{Type: "new_device", IP: device.IP, MAC: device.MAC, Timestamp: device.FirstSeen.UnixMilli()}
+	if lease != nil {
+		alert.Hostname = lease.Hostname
+	}
+	log.Printf("🆕 New device %s %s", alert.IP, alert.MAC)
+	full, _ := json.Marshal(alert)
+	alert.MAC, alert.Hostname = "", ""
+	unnamed, _ := json.Marshal(alert)
+
+	manager.clientsMutex.RLock()
+	for client := range manager.clients {
+		switch {
+		case manager.masks(client, alert.IP):
+			// Anonymized rooms never learn which attendee address is new
+		case client.room.exposure.Hostnames:
+			client.enqueue(full)
+		default:
+			client.enqueue(unnamed)
+		}
+	}
+	manager.clientsMutex.RUnlock()
+	manager.notifyJSON(full, "")
+	manager.recordAlert(full, "")
+}
+
+// handleDevices lists the devices seen on the monitored subnets:
+//
+//	GET /api/devices?order=first_seen|last_seen&limit=100   most recent first
+func (manager *ClientManager) handleDevices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if manager.devices == nil {
+		http.Error(w, "new-device detection is off: the config file has no devices section", http.StatusNotFound)
+		return
+	}
+
+	order := r.URL.Query().Get("order")
+	switch order {
+	case "":
+		order = "first_seen"
+	case "first_seen", "last_seen":
+	default:
+		http.Error(w, "order must be first_seen or last_seen", http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subnets": manager.devices.Subnets(),
+		"order":   order,
+		"devices": manager.devices.List(order, limit),
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// setupDHCP builds the lease table of the config file's dhcp section and keeps it current from
+// the leases file; nodes whose address changes hands get a fresh node_info
+func (manager *ClientManager) setupDHCP(cfg *config.DHCP) error {
+	if cfg == nil {
+		return nil
+	}
+	leases, err := enrich.NewLeaseTable(cfg)
+	if err != nil {
+		return err
+	}
+	leases.OnChange = manager.broadcastNodeInfo
+	if cfg.Source != "" {
+		log.Printf("🏷️ DHCP leases: reading %s leases from %s", cfg.Source, cfg.File)
+	} else {
+		log.Printf("🏷️ DHCP leases: from POST /api/dhcp")
+	}
+	go leases.Run(make(chan struct{}))
+	manager.leases = leases
+	return nil
+}
+
+// handleDHCP lists and feeds the lease table:
+//
+//	GET  /api/dhcp   the live leases
+//	POST /api/dhcp   [{"ip":...,"mac":...,"hostname":...,"expires":"2024-08-10T16:00:00Z","release":false}]
+func (manager *ClientManager) handleDHCP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if manager.leases == nil {
+		http.Error(w, "lease naming is off: the config file has no dhcp section", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(manager.leases.Leases())
+
+	case http.MethodPost:
+		var updates []struct {
+			enrich.Lease
+			Release bool `json:"release"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		learned, released := 0, 0
+		errors := []string{}
+		for _, update := range updates {
+			if update.Release {
+				if manager.leases.Release(update.IP) {
+					released++
+					manager.broadcastNodeInfo(update.IP)
+				}
+				continue
+			}
+			changed, err := manager.leases.Put(update.Lease)
+			if err != nil {
+				errors = append(errors, err.Error())
+				continue
+			}
+			learned++
+			if changed {
+				manager.broadcastNodeInfo(update.IP)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"learned": learned, "released": released, "errors": errors})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+)
+
+// dhcpGuardRules reads the config file's dhcp_guard section; nil when it is absent
+func dhcpGuardRules(cfg *config.DHCPGuard) (*capture.DHCPGuardRules, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.MaxDiscovers < 0 {
+		return nil, fmt.Errorf("max_discovers_per_minute must be positive")
+	}
+	rules := &capture.DHCPGuardRules{MaxDiscovers: cfg.MaxDiscovers, Cooldown: cfg.Cooldown.Duration}
+	for _, server := range cfg.Servers {
+		addr, err := netip.ParseAddr(server)
+		if err != nil {
+			return nil, fmt.Errorf("server %q: %v", server, err)
+		}
+		rules.Servers = append(rules.Servers, addr)
+	}
+	return rules, nil
+}
+
+// observeDHCP hands a DHCP message heard by the session's live capture to the DHCP guard. Every
+// room is alerted when the rules are broken, and a server heard for the first time, or raising an
+// alert, gets a fresh node_info so screens can mark it. Replays describe how the network was.
+func (manager *ClientManager) observeDHCP(c *Client, packet *capture.Packet) {
+	if packet.DHCP == nil {
+		return
+	}
+	live, ok := c.liveInterface()
+	if !ok {
+		return
+	}
+	alert, added := manager.dhcpGuard.Observe(packet, live.Interface(), time.Now())
+	if alert == nil {
+		if added != "" {
+			manager.broadcastNodeInfo(added)
+		}
+		return
+	}
+	if alert.Kind == "starvation" {
+		log.Printf("🚨 DHCP starvation on %s: %d DISCOVERs in a minute from %d MACs", alert.Interface, alert.Discovers, alert.Clients)
+	} else {
+		log.Printf("🚨 Rogue DHCP server %s (%s) on %s offered %s to %s", alert.IP, alert.MAC, alert.Interface, alert.Offered, alert.Client)
+		manager.broadcastNodeInfo(alert.IP)
+	}
+	data, err := alert.ToJSON()
+	if err != nil {
+		return
+	}
+	manager.clientsMutex.RLock()
+	for client := range manager.clients {
+		// Anonymized rooms never learn which attendee address runs a DHCP server
+		if alert.IP == "" || !manager.masks(client, alert.IP) {
+			client.enqueue(data)
+		}
+	}
+	manager.clientsMutex.RUnlock()
+	manager.notifyJSON(data, "")
+	manager.recordAlert(data, "")
+}
+
+// handleDHCPServers lists the DHCP servers heard answering in the last hour, and whether the
+// dhcp_guard section allows them: GET /api/dhcp/servers
+func (manager *ClientManager) handleDHCPServers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	json.NewEncoder(w).Encode(manager.dhcpGuard.Servers(time.Now()))
+}
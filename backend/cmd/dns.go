@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// observeDNS remembers the addresses DNS responses on the wire resolved, for hostname pins
+func (manager *ClientManager) observeDNS(packet *capture.Packet) {
+	for _, answer := range packet.DNSAnswers {
+		manager.dns.Observe(answer.IP, answer.Name, time.Duration(answer.TTL)*time.Second)
+	}
+}
+
+// hostNames lists the names an address goes by: what DNS resolved to it and its DHCP lease's
+// hostname. Hostname pins match against them.
+func (manager *ClientManager) hostNames(ip string) []string {
+	names := manager.dns.Names(ip)
+	if manager.leases != nil {
+		if lease, ok := manager.leases.Get(ip); ok && lease.Hostname != "" {
+			names = append(names, strings.ToLower(lease.Hostname))
+		}
+	}
+	return names
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// dropsReportInterval is how often a session that lost messages is told how many
+const dropsReportInterval = 10 * time.Second
+
+// dropsMessage tells a client how many messages its full send queue cost it ("drops")
+type dropsMessage struct {
+	Type       string `json:"type"`
+	Dropped    uint64 `json:"dropped"`     // since the previous report
+	IntervalMs int64  `json:"interval_ms"` // time the count covers
+	Total      uint64 `json:"total"`       // since the session connected
+	Coalesce   bool   `json:"coalesce"`    // drops were the oldest queued messages, not the newest
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// ToJSON converts a drops report to JSON
+func (m *dropsMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// enqueue queues an encoded message without blocking. When the queue is full the message is
+// dropped, or, for a client that asked for latest-wins coalescing, the oldest queued message
+// makes room for it. Either way the drop is counted against the client.
+func (c *Client) enqueue(msg []byte) bool {
+	if c.coalesce {
+		return c.enqueueLatest(msg)
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.countDrop()
+		return false
+	}
+}
+
+// enqueueLatest queues a message, evicting the oldest queued one if the queue is full
+func (c *Client) enqueueLatest(msg []byte) bool {
+	select {
+	case c.send <- msg:
+		return true
+	default:
+	}
+	select {
+	case <-c.send:
+		c.countDrop()
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		c.countDrop()
+		return false
+	}
+}
+
+func (c *Client) countDrop() {
+	c.dropped.Add(1)
+	if n := wsSendDropped.Add(1); n == 1 || n%10000 == 0 {
+		log.Printf("WebSocket send saturated: dropped %d messages (slow client vs ingest); graph may sample", n)
+	}
+}
+
+// reportDrops sends the client its drops since the last report, if there were any (forwarder
+// goroutine only). A report that doesn't fit in the queue is folded into the next one.
+func (c *Client) reportDrops(now time.Time) {
+	total := c.dropped.Load()
+	if total == c.dropsReported {
+		c.dropsReportedAt = now
+		return
+	}
+	sent := c.trySend(&dropsMessage{
+		Type:       "drops",
+		Dropped:    total - c.dropsReported,
+		IntervalMs: now.Sub(c.dropsReportedAt).Milliseconds(),
+		Total:      total,
+		Coalesce:   c.coalesce,
+		Timestamp:  now.UnixMilli(),
+	})
+	if sent {
+		c.dropsReported = total
+		c.dropsReportedAt = now
+	}
+}
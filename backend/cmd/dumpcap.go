@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dumpcapFallbackPaths are default Wireshark install locations that are often not on PATH
+var dumpcapFallbackPaths = []string{
+	`C:\Program Files\Wireshark\dumpcap.exe`,
+	`C:\Program Files (x86)\Wireshark\dumpcap.exe`,
+	"/Applications/Wireshark.app/Contents/MacOS/dumpcap",
+	"/usr/local/bin/dumpcap",
+	"/opt/homebrew/bin/dumpcap",
+	"/usr/bin/dumpcap",
+	"/usr/sbin/dumpcap",
+}
+
+// findDumpcap locates the dumpcap binary on PATH or in a standard install location
+func findDumpcap() (string, bool) {
+	if path, err := exec.LookPath("dumpcap"); err == nil {
+		return path, true
+	}
+	for _, path := range dumpcapFallbackPaths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// isDumpcapProcessName matches a process image name against dumpcap on any platform
+func isDumpcapProcessName(name string) bool {
+	name = strings.ToLower(filepath.Base(strings.TrimSpace(name)))
+	return name == "dumpcap" || name == "dumpcap.exe"
+}
+
+// fileSafeInterfaceName turns an interface name into something usable in a file name.
+// Windows names look like \Device\NPF_{GUID}.
+func fileSafeInterfaceName(iface string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', '/', ':', '*', '?', '"', '<', '>', '|', '{', '}', ' ':
+			return '_'
+		}
+		return r
+	}, iface)
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dumpcapProcessRunning looks for a dumpcap process via /proc where available, otherwise ps
+func dumpcapProcessRunning() bool {
+	if comms, err := filepath.Glob("/proc/[0-9]*/comm"); err == nil && len(comms) > 0 {
+		for _, comm := range comms {
+			if name, err := os.ReadFile(comm); err == nil && isDumpcapProcessName(string(name)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// macOS and BSDs have no /proc
+	out, err := exec.Command("ps", "-A", "-o", "comm=").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if isDumpcapProcessName(line) {
+			return true
+		}
+	}
+	return false
+}
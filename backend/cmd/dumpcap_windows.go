@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+	"encoding/csv"
+	"os/exec"
+	"strings"
+)
+
+// dumpcapProcessRunning asks tasklist for running dumpcap.exe images
+func dumpcapProcessRunning() bool {
+	out, err := exec.Command("tasklist", "/FI", "IMAGENAME eq dumpcap.exe", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(out))).ReadAll()
+	if err != nil {
+		return false
+	}
+	for _, row := range rows {
+		if len(row) > 0 && isDumpcapProcessName(row[0]) {
+			return true
+		}
+	}
+	return false
+}
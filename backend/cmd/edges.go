@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// newEdgeAlert is broadcast the first time a client reaches a service, once the baseline of the
+// new_edges subnets is done learning
+type newEdgeAlert struct {
+	Type      string `json:"type"` // always "new_edge"
+	IP        string `json:"ip"`   // the client
+	Server    string `json:"server"`
+	Port      int    `json:"port"`
+	Protocol  string `json:"protocol"`
+	Timestamp int64  `json:"timestamp"` // first seen (ms)
+}
+
+// setupEdges loads the connection baseline of the config file's new_edges section and saves it
+// as it grows
+func (manager *ClientManager) setupEdges(cfg *config.NewEdges) error {
+	if cfg == nil {
+		return nil
+	}
+	edges, err := enrich.NewEdgeBaseline(cfg, *edgesFile)
+	if err != nil {
+		return err
+	}
+	if until := edges.LearningUntil(); time.Now().Before(until) {
+		log.Printf("🕸️ New-edge detection on %v, learning until %s", edges.Subnets(), until.Format(time.RFC3339))
+	} else {
+		log.Printf("🕸️ New-edge detection on %v", edges.Subnets())
+	}
+	go edges.Run(make(chan struct{}))
+	manager.edges = edges
+	return nil
+}
+
+// observeEdge records the client and service of a live packet and alerts every room when the
+// client has never reached that service before. Replays and simulations would fill the baseline
+// with connections from elsewhere.
+func (manager *ClientManager) observeEdge(packet *capture.Packet) {
+	if manager.edges == nil || (packet.Source != "real" && packet.Source != "zeek") {
+		return
+	}
+	client, server, port, ok := capture.ClientService(packet)
+	if !ok {
+		return
+	}
+	edge := manager.edges.Observe(client, server, port, packet.Protocol, time.UnixMilli(packet.Timestamp))
+	if edge == nil {
+		return
+	}
+
+	alert := newEdgeAlert{Type: "new_edge", IP: edge.Client, Server: edge.Server, Port: edge.Port, Protocol: edge.Protocol, Timestamp: edge.FirstSeen.UnixMilli()}
+	log.Printf("🕸️ New connection %s → %s:%d/%s", alert.IP, alert.Server, alert.Port, alert.Protocol)
+	full, _ := json.Marshal(alert)
+	alert.Port = 0
+	portless, _ := json.Marshal(alert)
+
+	manager.clientsMutex.RLock()
+	for client := range manager.clients {
+		switch {
+		case manager.masks(client, alert.IP) || manager.masks(client, alert.Server):
+			// Anonymized rooms never learn which attendee address reached what
+		case client.room.exposure.Ports:
+			client.enqueue(full)
+		default:
+			client.enqueue(portless)
+		}
+	}
+	manager.clientsMutex.RUnlock()
+	manager.notifyJSON(full, "")
+	manager.recordAlert(full, "")
+}
+
+// handleEdges lists the connections the baseline knows:
+//
+//	GET /api/edges?ip=10.0.0.5&limit=100   most recent first; ip keeps those with it at either end
+func (manager *ClientManager) handleEdges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if manager.edges == nil {
+		http.Error(w, "new-edge detection is off: the config file has no new_edges section", http.StatusNotFound)
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	until := manager.edges.LearningUntil()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subnets":        manager.edges.Subnets(),
+		"learning":       time.Now().Before(until),
+		"learning_until": until,
+		"edges":          manager.edges.List(r.URL.Query().Get("ip"), limit),
+	})
+}
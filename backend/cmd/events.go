@@ -0,0 +1,28 @@
+package main
+
+// outboundMessage is any typed server → client message
+type outboundMessage interface {
+	ToJSON() ([]byte, error)
+}
+
+// trySend queues a message without blocking the forwarder; see enqueue for a backed-up client
+func (c *Client) trySend(msg outboundMessage) bool {
+	msgJSON, err := msg.ToJSON()
+	if err != nil {
+		return false
+	}
+	return c.enqueue(msgJSON)
+}
+
+// sendReply queues a command reply, blocking like the original handlers did: replies are rare
+// and must not be dropped
+func (c *Client) sendReply(msg []byte) {
+	c.send <- msg
+}
+
+// sendAll queues a batch of events (conn_open/conn_close, tcp_anomaly, ...) in order
+func sendAll[T outboundMessage](c *Client, msgs []T) {
+	for _, msg := range msgs {
+		c.trySend(msg)
+	}
+}
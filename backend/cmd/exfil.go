@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+)
+
+// exfilRules reads the config file's exfiltration section; nil when it is absent
+func exfilRules(cfg *config.Exfiltration) (*capture.ExfilRules, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	switch {
+	case cfg.Window.Duration != 0 && cfg.Window.Duration < 10*time.Second:
+		return nil, fmt.Errorf("window must be at least 10s")
+	case cfg.Baseline.Duration != 0 && cfg.Baseline.Duration <= cfg.Window.Duration:
+		return nil, fmt.Errorf("baseline must be longer than the window")
+	case cfg.MaxBytes < 0 || cfg.MinBytes < 0:
+		return nil, fmt.Errorf("max_bytes and min_bytes must be positive")
+	case cfg.Deviation != 0 && cfg.Deviation <= 1:
+		return nil, fmt.Errorf("deviation must be more than 1")
+	}
+	rules := &capture.ExfilRules{
+		Window:    cfg.Window.Duration,
+		MaxBytes:  cfg.MaxBytes,
+		Deviation: cfg.Deviation,
+		MinBytes:  cfg.MinBytes,
+		Baseline:  cfg.Baseline.Duration,
+		Cooldown:  cfg.Cooldown.Duration,
+	}
+	for _, cidr := range cfg.Internal {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("internal %q: %v", cidr, err)
+		}
+		rules.Internal = append(rules.Internal, prefix.Masked())
+	}
+	return rules, nil
+}
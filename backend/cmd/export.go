@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// handleExportPCAP streams the archived packets between start and end (RFC3339) matching an
+// optional BPF filter as a single downloadable PCAP:
+// GET /api/export/pcap?start=...&end=...&filter=host 10.0.0.5
+func (manager *ClientManager) handleExportPCAP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	startTime, err := time.Parse(time.RFC3339, query.Get("start"))
+	if err != nil {
+		http.Error(w, "invalid start time (RFC3339 required): "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	endTime, err := time.Parse(time.RFC3339, query.Get("end"))
+	if err != nil {
+		http.Error(w, "invalid end time (RFC3339 required): "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := capture.NewArchiveExport(capture.ExportConfig{
+		StorageDir: *storageDir,
+		Store:      manager.store,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Filter:     query.Get("filter"),
+		Index:      manager.archive,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	manager.auditRequest(r, "export_pcap", "", map[string]interface{}{
+		"start":  startTime.Format(time.RFC3339),
+		"end":    endTime.Format(time.RFC3339),
+		"filter": query.Get("filter"),
+	})
+	log.Printf("📦 PCAP export: %s to %s from %d files (filter: %q)",
+		startTime.Format(time.RFC3339), endTime.Format(time.RFC3339), len(export.Files()), query.Get("filter"))
+
+	filename := fmt.Sprintf("vibes-export-%s-%s.pcap", startTime.UTC().Format("20060102_150405"), endTime.UTC().Format("20060102_150405"))
+	w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	result, err := export.Write(w)
+	if err != nil {
+		// The response is already streaming; the client sees a truncated file
+		log.Printf("PCAP export failed after %d packets: %v", result.Packets, err)
+		return
+	}
+	log.Printf("📦 PCAP export complete: %d packets (%d bytes) from %d files", result.Packets, result.Bytes, result.Files)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// exposureOf returns the metadata a room's clients may see: the room's "expose" list, or
+// -expose for rooms without one
+func exposureOf(cfg []string) (capture.Exposure, error) {
+	if cfg == nil {
+		cfg = strings.Split(*exposeMetadata, ",")
+	}
+	return capture.ParseExposure(cfg)
+}
+
+// checkExposure rejects bad -expose values and room "expose" lists at startup, so no room
+// ever falls back to showing more than it was configured to
+func (manager *ClientManager) checkExposure() error {
+	if _, err := exposureOf(nil); err != nil {
+		return fmt.Errorf("-expose: %v", err)
+	}
+	for _, room := range manager.cfg.Rooms {
+		if _, err := exposureOf(room.Expose); err != nil {
+			return fmt.Errorf("room %s: %v", room.Name, err)
+		}
+	}
+	return nil
+}
+
+// exposed passes a batch of messages through a room's exposure stage
+func exposed[T any](stage func(T) T, msgs []T) []T {
+	if len(msgs) == 0 {
+		return msgs
+	}
+	out := make([]T, len(msgs))
+	for i, msg := range msgs {
+		out[i] = stage(msg)
+	}
+	return out
+}
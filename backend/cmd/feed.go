@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// auxServers are started after the HTTP handlers are registered; optional
+// transports built with extra tags (see grpc.go) add themselves here
+var auxServers []func(manager *ClientManager) error
+
+// feedFilter selects the packets and flow events a programmatic subscriber receives
+type feedFilter struct {
+	Session    string       // remote addr of the browser session to follow; empty = every session
+	Protocols  []string     // TCP, UDP, ICMP, OTHER
+	Hosts      []*net.IPNet // either endpoint inside one of these
+	Ports      []int        // either port equal to one of these
+	PinnedOnly bool         // only packets matching the pinning rules of the session's room
+}
+
+// newFeedFilter validates filter arguments; hosts are IPs or CIDRs
+func newFeedFilter(session string, protocols, hosts []string, ports []int, pinnedOnly bool) (feedFilter, error) {
+	filter := feedFilter{Session: session, Ports: ports, PinnedOnly: pinnedOnly}
+	for _, protocol := range protocols {
+		filter.Protocols = append(filter.Protocols, strings.ToUpper(protocol))
+	}
+	for _, host := range hosts {
+		if !strings.Contains(host, "/") {
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return filter, fmt.Errorf("invalid host %q", host)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			host = fmt.Sprintf("%s/%d", host, bits)
+		}
+		_, ipnet, err := net.ParseCIDR(host)
+		if err != nil {
+			return filter, fmt.Errorf("invalid host %q", host)
+		}
+		filter.Hosts = append(filter.Hosts, ipnet)
+	}
+	for _, port := range ports {
+		if port < 0 || port > 65535 {
+			return filter, fmt.Errorf("invalid port %d", port)
+		}
+	}
+	return filter, nil
+}
+
+func (f *feedFilter) matches(src, dst string, srcPort, dstPort int, protocol string) bool {
+	if len(f.Protocols) > 0 && !containsString(f.Protocols, protocol) {
+		return false
+	}
+	if len(f.Ports) > 0 && !containsInt(f.Ports, srcPort) && !containsInt(f.Ports, dstPort) {
+		return false
+	}
+	if len(f.Hosts) > 0 {
+		srcIP, dstIP := net.ParseIP(src), net.ParseIP(dst)
+		found := false
+		for _, ipnet := range f.Hosts {
+			if (srcIP != nil && ipnet.Contains(srcIP)) || (dstIP != nil && ipnet.Contains(dstIP)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// feedPacket is a packet as seen by one session, after enrichment and before sampling
+type feedPacket struct {
+	Session string
+	Packet  *capture.Packet
+	Dropped uint64 // packets dropped for this subscriber since the previous delivery
+}
+
+// feedFlow is a conn_open/conn_close event from one session
+type feedFlow struct {
+	Session string
+	Event   *capture.ConnEvent
+	Dropped uint64
+}
+
+// feedSubscriber receives a filtered copy of the enriched stream. Delivery never blocks the
+// forwarders: a subscriber that falls behind loses messages and is told how many.
+type feedSubscriber struct {
+	filter         feedFilter
+	Packets        chan feedPacket
+	Flows          chan feedFlow
+	droppedPackets atomic.Uint64
+	droppedFlows   atomic.Uint64
+}
+
+// packetFeed fans the enriched stream of every session out to programmatic subscribers
+type packetFeed struct {
+	mu   sync.RWMutex
+	subs map[*feedSubscriber]struct{}
+	size atomic.Int32
+}
+
+func newPacketFeed() *packetFeed {
+	return &packetFeed{subs: make(map[*feedSubscriber]struct{})}
+}
+
+// subscribe registers a subscriber for packets, flow events or both; unused channels stay nil
+func (f *packetFeed) subscribe(filter feedFilter, packets, flows bool) *feedSubscriber {
+	sub := &feedSubscriber{filter: filter}
+	if packets {
+		sub.Packets = make(chan feedPacket, 4096)
+	}
+	if flows {
+		sub.Flows = make(chan feedFlow, 1024)
+	}
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.size.Store(int32(len(f.subs)))
+	f.mu.Unlock()
+	return sub
+}
+
+func (f *packetFeed) unsubscribe(sub *feedSubscriber) {
+	f.mu.Lock()
+	delete(f.subs, sub)
+	f.size.Store(int32(len(f.subs)))
+	f.mu.Unlock()
+}
+
+// active is a cheap check so forwarders skip the feed entirely when nobody is subscribed
+func (f *packetFeed) active() bool {
+	return f.size.Load() > 0
+}
+
+// publishFeed offers one forwarded packet (nil for timeout sweeps) and its conn events to the feed subscribers
+func (manager *ClientManager) publishFeed(client *Client, packet *capture.Packet, events []*capture.ConnEvent) {
+	feed := manager.feed
+	if !feed.active() {
+		return
+	}
+	session := client.conn.RemoteAddr().String()
+	exposure := client.room.exposure
+	var exposedPacket *capture.Packet
+	if packet != nil {
+		packet = manager.annotateGroups(client.room, packet)
+		exposedPacket = exposure.Packet(packet)
+	}
+
+	feed.mu.RLock()
+	defer feed.mu.RUnlock()
+	for sub := range feed.subs {
+		if sub.filter.Session != "" && sub.filter.Session != session {
+			continue
+		}
+		if packet != nil && sub.Packets != nil && sub.filter.matches(packet.Src, packet.Dst, packet.SrcPort, packet.DstPort, packet.Protocol) &&
+			(!sub.filter.PinnedOnly || packet.Pinned) {
+			dropped := sub.droppedPackets.Swap(0)
+			select {
+			case sub.Packets <- feedPacket{Session: session, Packet: exposedPacket, Dropped: dropped}:
+			default:
+				sub.droppedPackets.Add(dropped + 1)
+			}
+		}
+		for _, event := range events {
+			if sub.Flows == nil || !sub.filter.matches(event.Src, event.Dst, event.SrcPort, event.DstPort, event.Protocol) {
+				continue
+			}
+			dropped := sub.droppedFlows.Swap(0)
+			select {
+			case sub.Flows <- feedFlow{Session: session, Event: exposure.ConnEvent(event), Dropped: dropped}:
+			default:
+				sub.droppedFlows.Add(dropped + 1)
+			}
+		}
+	}
+}
+
+// annotateGroups returns a copy of the packet with node_grouping cluster hints filled in, unless
+// the room's capture profile leaves them out. Capture sources may share one *Packet across
+// clients, so the original is never modified.
+func (manager *ClientManager) annotateGroups(room *Room, packet *capture.Packet) *capture.Packet {
+	grouper := manager.nodeGrouper.Load()
+	if grouper == nil || !room.enriches(enrichNodeGroups) {
+		return packet
+	}
+	annotated := *packet
+	annotated.SrcGroup = grouper.Group(packet.Src)
+	annotated.DstGroup = grouper.Group(packet.Dst)
+	return &annotated
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+// handleGroups lists (GET) or replaces (PUT/POST) the subnet groups used for group_stats
+func (manager *ClientManager) handleGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(manager.groups.Groups())
+
+	case http.MethodPut, http.MethodPost:
+		var groups []config.SubnetGroup
+		if err := json.NewDecoder(r.Body).Decode(&groups); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := manager.groups.Set(groups); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("📊 Subnet groups updated via API: %d groups", len(groups))
+		manager.auditRequest(r, "groups_set", "", map[string]interface{}{"groups": groups})
+		json.NewEncoder(w).Encode(manager.groups.Groups())
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,138 @@
+//go:build grpc
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	vibesv1 "vibes-network-visualizer/api/vibes/v1"
+)
+
+var grpcAddr = flag.String("grpc", "", "serve the gRPC packet feed on this address, e.g. :9090")
+
+func init() {
+	auxServers = append(auxServers, startGRPCServer)
+}
+
+// feedServer implements vibes.v1.PacketFeed on top of the manager's packet feed
+type feedServer struct {
+	vibesv1.UnimplementedPacketFeedServer
+	manager *ClientManager
+}
+
+func startGRPCServer(manager *ClientManager) error {
+	if *grpcAddr == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		return fmt.Errorf("gRPC listen on %s: %v", *grpcAddr, err)
+	}
+	server := grpc.NewServer()
+	vibesv1.RegisterPacketFeedServer(server, &feedServer{manager: manager})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("⚠️ gRPC server stopped: %v", err)
+		}
+	}()
+	log.Printf("📡 gRPC packet feed on %s", *grpcAddr)
+	return nil
+}
+
+func filterFromRequest(req *vibesv1.StreamRequest) (feedFilter, error) {
+	ports := make([]int, 0, len(req.GetPorts()))
+	for _, port := range req.GetPorts() {
+		ports = append(ports, int(port))
+	}
+	filter, err := newFeedFilter(req.GetSession(), req.GetProtocols(), req.GetHosts(), ports, req.GetPinnedOnly())
+	if err != nil {
+		return filter, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return filter, nil
+}
+
+// StreamPackets sends matching packets until the client cancels. Stream.Send blocks under
+// HTTP/2 flow control; the subscriber buffer absorbs that and reports drops in Packet.dropped.
+func (s *feedServer) StreamPackets(req *vibesv1.StreamRequest, stream vibesv1.PacketFeed_StreamPacketsServer) error {
+	filter, err := filterFromRequest(req)
+	if err != nil {
+		return err
+	}
+	sub := s.manager.feed.subscribe(filter, true, false)
+	defer s.manager.feed.unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case fp := <-sub.Packets:
+			p := fp.Packet
+			if err := stream.Send(&vibesv1.Packet{
+				Src:         p.Src,
+				Dst:         p.Dst,
+				SrcPort:     int32(p.SrcPort),
+				DstPort:     int32(p.DstPort),
+				Size:        int32(p.Size),
+				Protocol:    p.Protocol,
+				TimestampMs: p.Timestamp,
+				Source:      p.Source,
+				TcpFlags:    p.TCPFlags,
+				SrcGroup:    p.SrcGroup,
+				DstGroup:    p.DstGroup,
+				Session:     fp.Session,
+				Dropped:     fp.Dropped,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StreamFlows sends conn_open/conn_close events until the client cancels
+func (s *feedServer) StreamFlows(req *vibesv1.StreamRequest, stream vibesv1.PacketFeed_StreamFlowsServer) error {
+	filter, err := filterFromRequest(req)
+	if err != nil {
+		return err
+	}
+	sub := s.manager.feed.subscribe(filter, false, true)
+	defer s.manager.feed.unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ff := <-sub.Flows:
+			e := ff.Event
+			if err := stream.Send(&vibesv1.Flow{
+				Type:        e.Type,
+				Id:          e.ID,
+				Src:         e.Src,
+				Dst:         e.Dst,
+				SrcPort:     int32(e.SrcPort),
+				DstPort:     int32(e.DstPort),
+				Protocol:    e.Protocol,
+				Reason:      e.Reason,
+				Midstream:   e.Midstream,
+				StartTimeMs: e.StartTime,
+				TimestampMs: e.Timestamp,
+				DurationMs:  e.DurationMs,
+				BytesOut:    e.BytesOut,
+				BytesIn:     e.BytesIn,
+				PacketsOut:  e.PacketsOut,
+				PacketsIn:   e.PacketsIn,
+				Retransmits: e.Retransmits,
+				Session:     ff.Session,
+				Dropped:     ff.Dropped,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
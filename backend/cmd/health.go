@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/health"
+)
+
+// setupHealthChecks starts the config file's health checks; a server whose service goes up or
+// down gets a fresh node_info carrying its checks
+func (manager *ClientManager) setupHealthChecks(checks []config.HealthCheck) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	monitor, err := health.NewMonitor(checks)
+	if err != nil {
+		return err
+	}
+	monitor.OnChange = func(status health.Status) {
+		manager.broadcastNodeInfo(status.Host)
+	}
+	log.Printf("🩺 Health checks: %d services", len(checks))
+	go monitor.Run(make(chan struct{}))
+	manager.health = monitor
+	return nil
+}
+
+// handleHealth lists every health check with its state: GET /api/health
+func (manager *ClientManager) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := []health.Status{}
+	if manager.health != nil {
+		statuses = manager.health.Statuses()
+	}
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// heartbeatInterval is how often a session gets a heartbeat message
+	heartbeatInterval = 5 * time.Second
+	// staleAfter is how long a screen may go without answering heartbeats, or let its send
+	// queue lag, before /api/sessions calls it stale
+	staleAfter = 3 * heartbeatInterval
+)
+
+// heartbeatPrefix starts every heartbeat message, so the write pump spots them cheaply
+var heartbeatPrefix = []byte(`{"type":"heartbeat"`)
+
+// heartbeatMessage reports a session's latency to itself every heartbeatInterval
+// ("heartbeat"). A client that echoes seq in a heartbeat_ack command has its page's own
+// round trip measured too, which a frozen tab stops answering while the browser still
+// answers pings.
+type heartbeatMessage struct {
+	Type       string  `json:"type"` // always "heartbeat"
+	Seq        uint64  `json:"seq"`
+	Timestamp  int64   `json:"timestamp"`            // when it was queued (ms)
+	RTTMs      float64 `json:"rtt_ms,omitempty"`     // latest ping round trip
+	AppRTTMs   float64 `json:"app_rtt_ms,omitempty"` // latest heartbeat to heartbeat_ack round trip
+	QueueLagMs float64 `json:"queue_lag_ms"`         // how long the previous heartbeat waited in the send queue
+	Queued     int     `json:"queued"`               // messages waiting in the send queue now
+	Stale      bool    `json:"stale,omitempty"`      // see staleAfter
+}
+
+// ToJSON converts a heartbeat to JSON
+func (m *heartbeatMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// heartbeatState is a session's latency bookkeeping. seq belongs to the forwarder; the rest
+// is written by the write and read pumps and read by /api/sessions.
+type heartbeatState struct {
+	seq        uint64
+	writtenSeq atomic.Uint64
+	writtenAt  atomic.Int64 // unix nanoseconds heartbeat writtenSeq went out
+	queueLag   atomic.Int64 // nanoseconds the latest heartbeat written waited in the send queue
+	appRTT     atomic.Int64 // nanoseconds
+	lastAck    atomic.Int64 // unix nanoseconds of the latest heartbeat_ack, 0 before the first
+}
+
+// sendHeartbeat queues the next heartbeat (forwarder goroutine only)
+func (c *Client) sendHeartbeat(now time.Time) {
+	c.heartbeat.seq++
+	rtt, _ := c.conn.pingStats()
+	c.trySend(&heartbeatMessage{
+		Type:       "heartbeat",
+		Seq:        c.heartbeat.seq,
+		Timestamp:  now.UnixMilli(),
+		RTTMs:      latencyMs(rtt),
+		AppRTTMs:   latencyMs(time.Duration(c.heartbeat.appRTT.Load())),
+		QueueLagMs: latencyMs(time.Duration(c.heartbeat.queueLag.Load())),
+		Queued:     len(c.send),
+		Stale:      c.stale(now),
+	})
+}
+
+// wroteMessage does the write pump's accounting for one message sent to the client
+func (c *Client) wroteMessage(manager *ClientManager, message []byte) {
+	c.sentBytes.Add(uint64(len(message)))
+	c.sentMessages.Add(1)
+	manager.recordStream(c, message)
+	if !bytes.HasPrefix(message, heartbeatPrefix) {
+		return
+	}
+	var heartbeat heartbeatMessage
+	if json.Unmarshal(message, &heartbeat) != nil {
+		return
+	}
+	now := time.Now()
+	c.heartbeat.queueLag.Store(int64(now.Sub(time.UnixMilli(heartbeat.Timestamp))))
+	c.heartbeat.writtenAt.Store(now.UnixNano())
+	c.heartbeat.writtenSeq.Store(heartbeat.Seq)
+}
+
+// heartbeatAcked measures the page's round trip from a heartbeat_ack. Acks for anything but
+// the latest heartbeat written are only proof of life.
+func (c *Client) heartbeatAcked(msg map[string]interface{}) {
+	now := time.Now()
+	c.heartbeat.lastAck.Store(now.UnixNano())
+	seq, ok := msg["seq"].(float64)
+	if !ok || uint64(seq) != c.heartbeat.writtenSeq.Load() {
+		return
+	}
+	c.heartbeat.appRTT.Store(int64(now.Sub(time.Unix(0, c.heartbeat.writtenAt.Load()))))
+}
+
+// stale reports a screen that stopped answering heartbeats it used to answer, or whose send
+// queue holds messages back for longer than staleAfter, or has not let a heartbeat through
+// for as long
+func (c *Client) stale(now time.Time) bool {
+	if lastAck := c.heartbeat.lastAck.Load(); lastAck != 0 && now.Sub(time.Unix(0, lastAck)) > staleAfter {
+		return true
+	}
+	lastWritten := c.connectedAt
+	if writtenAt := c.heartbeat.writtenAt.Load(); writtenAt != 0 {
+		lastWritten = time.Unix(0, writtenAt)
+	}
+	return now.Sub(lastWritten) > staleAfter+heartbeatInterval || time.Duration(c.heartbeat.queueLag.Load()) > staleAfter
+}
+
+// latencyMs rounds a duration to hundredths of a millisecond
+func latencyMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// injectStatsInterval is how often the injector logs what it put on the wire
+const injectStatsInterval = time.Minute
+
+// injection is the traffic generator started with -inject-iface, if any
+var injection struct {
+	sim      *capture.SimulatedCapture
+	injector *capture.Injector
+}
+
+// startInjection runs a scenario and transmits its packets on -inject-iface. The interface
+// is opened here, before privileges are dropped.
+func startInjection() error {
+	sim, err := newSimulation(*injectScenario)
+	if err != nil {
+		return err
+	}
+	injector, err := capture.NewInjector(*injectIface, *injectPPS)
+	if err != nil {
+		return err
+	}
+	if err := sim.Start(); err != nil {
+		injector.Close()
+		return err
+	}
+	injection.sim, injection.injector = sim, injector
+	log.Printf("💉 Injecting scenario %s on %s (at most %d packets/s); use on closed lab networks only", sim.Scenario().Name, *injectIface, *injectPPS)
+
+	go func() {
+		ticker := time.NewTicker(injectStatsInterval)
+		defer ticker.Stop()
+		var lastErr string
+		for {
+			select {
+			case p := <-sim.GetPacketChannel():
+				// Log each kind of failure once rather than once per packet
+				if err := injector.Inject(p); err != nil && err.Error() != lastErr {
+					lastErr = err.Error()
+					log.Printf("⚠️ Injection: %v", err)
+				}
+			case <-ticker.C:
+				stats := injector.Stats()
+				log.Printf("💉 Injected %d packets (%d bytes) on %s; %d failed, %d over the rate cap",
+					stats.Sent, stats.Bytes, stats.Interface, stats.Failed, stats.Throttled)
+			}
+		}
+	}()
+	return nil
+}
+
+// handleInject reports the traffic generator: GET /api/inject
+func handleInject(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if injection.injector == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":  true,
+		"scenario": injection.sim.Scenario().Name,
+		"max_pps":  *injectPPS,
+		"stats":    injection.injector.Stats(),
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// handleInterfaces lists capture interfaces with link state, addresses and a capture permission
+// preflight so the UI can grey out interfaces that would fail. ?probe=false skips the preflight.
+func handleInterfaces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	probe := r.URL.Query().Get("probe") != "false"
+	interfaces, err := capture.ListInterfaceDetails(probe)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(interfaces)
+}
+
+// newRealCapture opens live capture on an interface, in monitor mode with -monitor
+func newRealCapture(iface string) *capture.RealCapture {
+	source := capture.NewRealCapture(iface)
+	source.Monitor = *monitorMode
+	return source
+}
+
+// liveInterface returns the interface the session's capture reads, under any attack overlay. It
+// reports false for sources that don't read one, such as replays and simulations.
+func (c *Client) liveInterface() (capture.LiveInterface, bool) {
+	source := c.captureSource()
+	if mixed, ok := source.(*capture.MixedCapture); ok {
+		source = mixed.Base()
+	}
+	live, ok := source.(capture.LiveInterface)
+	return live, ok
+}
+
+// sendInterfaceStats tells the session how busy the interface its capture reads is, and whether
+// the link, the driver or the capture itself is dropping packets (forwarder goroutine only). Sources
+// that don't read an interface, such as replays and simulations, send nothing.
+func (c *Client) sendInterfaceStats(now time.Time) {
+	live, ok := c.liveInterface()
+	if !ok {
+		return
+	}
+	if stats := c.interfaceStats.Poll(live, now); stats != nil {
+		c.trySend(stats)
+	}
+}
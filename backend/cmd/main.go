@@ -1,822 +1,1835 @@
-package main
-
-import (
-	"encoding/json"
-	"flag"
-	"fmt"
-	"log"
-	"math/rand"
-	"net"
-	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strconv"
-	"strings"
-	"sync"
-	"sync/atomic"
-	"time"
-
-	"github.com/c-robinson/iplib"
-	"github.com/gorilla/websocket"
-	"vibes-network-visualizer/internal/capture"
-)
-
-const (
-	writeWait      = 10 * time.Second
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
-)
-
-var (
-	addr        = flag.String("addr", ":8080", "http service address")
-	iface       = flag.String("iface", "", "network interface to capture (empty for simulated data)")
-	pcapFile    = flag.String("pcap", "", "path to PCAP file for replay mode")
-	replaySpeed = flag.Float64("speed", 1.0, "replay speed multiplier (1.0 = real-time, 2.0 = 2x speed)")
-	storageDir  = flag.String("storage", "/data/pcaps", "directory containing PCAP archives for time window playback")
-	useDumpcap  = flag.Bool("dumpcap", false, "use external dumpcap for high-performance capture (requires dumpcap to be running)")
-	dumpcapDir  = flag.String("dumpcap-dir", "/data/pcaps", "directory where dumpcap writes PCAP files")
-	launchDumpcap = flag.Bool("launch-dumpcap", false, "automatically launch dumpcap process if not running")
-	zeekTCPListen = flag.String("zeek-tcp", "", "default listen address for Zeek conn.log JSON over TCP (e.g. :4777); used when WebSocket connects with zeek_tcp=1")
-	upgrader    = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all origins
-		},
-	}
-	// Packets dropped when WebSocket send buffer is full (ingest faster than browser/network).
-	wsSendDropped atomic.Uint64
-)
-
-type Client struct {
-	conn          *websocket.Conn
-	send          chan []byte
-	disconnected  chan struct{}
-	stopForwarder chan struct{}
-}
-
-type ClientManager struct {
-	clients            map[*Client]bool
-	broadcast          chan []byte
-	register           chan *Client
-	unregister         chan *Client
-	pinningRules       []string
-	rulesMutex         sync.RWMutex
-	timeWindowProcessor *capture.TimeWindowProcessor
-	currentCaptureMode  string
-	originalCapture     capture.PacketCapture
-}
-
-func NewClientManager() *ClientManager {
-	return &ClientManager{
-		clients:      make(map[*Client]bool),
-		broadcast:    make(chan []byte),
-		register:     make(chan *Client),
-		unregister:   make(chan *Client),
-		pinningRules: make([]string, 0),
-	}
-}
-
-func NewClient(conn *websocket.Conn) *Client {
-	return &Client{
-		conn:          conn,
-		send:          make(chan []byte, 8192), // large enough for bursty Zeek NDJSON without blocking the capture drain loop
-		disconnected:  make(chan struct{}),
-		stopForwarder: make(chan struct{}),
-	}
-}
-
-func (manager *ClientManager) isIPPinned(ipStr string) bool {
-	manager.rulesMutex.RLock()
-	defer manager.rulesMutex.RUnlock()
-
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return false
-	}
-
-	for _, rule := range manager.pinningRules {
-		if strings.Contains(rule, "/") { // CIDR
-			_, ipnet, err := net.ParseCIDR(rule)
-			if err == nil && ipnet.Contains(ip) {
-				return true
-			}
-		} else if strings.Contains(rule, "-") { // Range
-			parts := strings.Split(rule, "-")
-			startIPStr := parts[0]
-			endOctetStr := parts[1]
-
-			startIP := net.ParseIP(startIPStr)
-			if startIP == nil {
-				continue
-			}
-			
-			baseIPParts := strings.Split(startIPStr, ".")
-			if len(baseIPParts) != 4 {
-				continue
-			}
-			
-			endIPStr := fmt.Sprintf("%s.%s.%s.%s", baseIPParts[0], baseIPParts[1], baseIPParts[2], endOctetStr)
-			endIP := net.ParseIP(endIPStr)
-			if endIP == nil {
-				continue
-			}
-
-			if iplib.CompareIPs(ip, startIP) >= 0 && iplib.CompareIPs(ip, endIP) <= 0 {
-				return true
-			}
-		} else { // Exact match
-			if ipStr == rule {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-func (manager *ClientManager) Start() {
-	for {
-		select {
-		case client := <-manager.register:
-			manager.clients[client] = true
-			log.Printf("Client connected. Total clients: %d", len(manager.clients))
-		case client := <-manager.unregister:
-			if _, ok := manager.clients[client]; ok {
-				delete(manager.clients, client)
-				close(client.stopForwarder)
-				go func() {
-					time.Sleep(50 * time.Millisecond)
-					close(client.send)
-				}()
-				log.Printf("Client disconnected. Total clients: %d", len(manager.clients))
-			}
-		case message := <-manager.broadcast:
-			for client := range manager.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(manager.clients, client)
-				}
-			}
-		}
-	}
-}
-
-func (manager *ClientManager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	ifaceName := r.URL.Query().Get("interface")
-	pcapParam := r.URL.Query().Get("pcap")
-	speedParam := r.URL.Query().Get("speed")
-
-	var captureSystem capture.PacketCapture
-	captureMode := "simulated"
-	
-	selectedPcapFile := *pcapFile
-	selectedReplaySpeed := *replaySpeed
-	selectedInterface := *iface
-
-	if pcapParam != "" {
-		selectedPcapFile = pcapParam
-	}
-	if speedParam != "" {
-		if speed, err := strconv.ParseFloat(speedParam, 64); err == nil && speed > 0 {
-			selectedReplaySpeed = speed
-		}
-	}
-	if ifaceName != "" {
-		selectedInterface = ifaceName
-	}
-
-	zeekParam := r.URL.Query().Get("zeek_tcp")
-	var zeekAddr string
-	if zeekParam != "" {
-		if zeekParam == "1" || zeekParam == "true" {
-			if *zeekTCPListen == "" {
-				http.Error(w, "zeek_tcp=1 requires -zeek-tcp (e.g. -zeek-tcp :4777)", http.StatusBadRequest)
-				return
-			}
-			zeekAddr = *zeekTCPListen
-		} else {
-			zeekAddr = zeekParam
-		}
-	}
-
-	if selectedPcapFile != "" {
-		config := capture.PCAPReplayConfig{
-			FilePath:    selectedPcapFile,
-			ReplaySpeed: selectedReplaySpeed,
-		}
-		captureSystem = capture.NewPCAPReplayCapture(config)
-		captureMode = "pcap_replay"
-	} else if zeekAddr != "" {
-		captureSystem = capture.NewZeekConnJSONCapture(zeekAddr)
-		captureMode = "zeek_conn"
-	} else if *useDumpcap {
-		// Check dumpcap status and optionally launch it
-		if err := handleDumpcapSetup(selectedInterface, *dumpcapDir); err != nil {
-			log.Printf("❌ Dumpcap setup failed: %v", err)
-			// Fall back to real capture if available
-			if selectedInterface != "" {
-				log.Printf("⚠️ Falling back to real capture mode")
-				captureSystem = capture.NewRealCapture(selectedInterface)
-				captureMode = "real"
-			} else {
-				log.Printf("⚠️ Falling back to simulation mode")
-				captureSystem = capture.NewSimulatedCapture()
-				captureMode = "simulated"
-			}
-		} else {
-			captureSystem = capture.NewDumpcapCapture(*dumpcapDir, selectedInterface)
-			captureMode = "dumpcap"
-		}
-	} else if selectedInterface != "" {
-		captureSystem = capture.NewRealCapture(selectedInterface)
-		captureMode = "real"
-	} else {
-		captureSystem = capture.NewSimulatedCapture()
-		captureMode = "simulated"
-	}
-
-	// Try to start the capture with fallback handling
-	captureFailed := false
-	captureErrorMsg := ""
-	originalMode := captureMode
-	
-	if err := captureSystem.Start(); err != nil {
-		log.Printf("Failed to start %s capture: %v", captureMode, err)
-		captureFailed = true
-		captureErrorMsg = err.Error()
-		
-		// Fall back to simulation
-		log.Printf("Falling back to simulated capture")
-		captureSystem = capture.NewSimulatedCapture()
-		if err := captureSystem.Start(); err != nil {
-			http.Error(w, "Failed to start capture: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		captureMode = "simulated"
-		log.Printf("*** FALLBACK TO SIMULATION (%s failed) ***", originalMode)
-	} else {
-		// Log success based on mode
-		switch captureMode {
-		case "real":
-			log.Printf("*** 📡 REAL CAPTURE ACTIVE on interface %s ***", selectedInterface)
-		case "dumpcap":
-			log.Printf("*** 🚀 DUMPCAP MONITORING ACTIVE: %s (interface: %s) ***", *dumpcapDir, selectedInterface)
-		case "pcap_replay":
-			log.Printf("*** 🔥 PCAP REPLAY ACTIVE: %s (%.2fx speed) ***", selectedPcapFile, selectedReplaySpeed)
-		case "zeek_conn":
-			log.Printf("*** 🦅 ZEEK CONN JSON (TCP) ACTIVE: ingest %s ***", zeekAddr)
-		case "simulated":
-			log.Printf("*** 🎮 SIMULATION ACTIVE (synthetic traffic) ***")
-		}
-	}
-
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println(err)
-		captureSystem.Stop()
-		return
-	}
-
-	client := NewClient(conn)
-	manager.register <- client
-	
-	// Store original capture for live mode switching
-	manager.originalCapture = captureSystem
-	manager.currentCaptureMode = captureMode
-
-	// Send mode information to the client
-	var modeMessage []byte
-	if captureFailed {
-		// Send error message with fallback info
-		modeMessage, _ = json.Marshal(map[string]interface{}{
-			"type": "mode",
-			"mode": captureMode,
-			"interface": selectedInterface,
-			"pcapFile": selectedPcapFile,
-			"replaySpeed": selectedReplaySpeed,
-			"zeek_tcp": zeekAddr,
-			"error": true,
-			"errorMsg": captureErrorMsg,
-			"requestedMode": originalMode,
-		})
-	} else {
-		// Normal mode message
-		modeMessage, _ = json.Marshal(map[string]interface{}{
-			"type": "mode",
-			"mode": captureMode,
-			"interface": selectedInterface,
-			"pcapFile": selectedPcapFile,
-			"replaySpeed": selectedReplaySpeed,
-			"zeek_tcp": zeekAddr,
-		})
-	}
-	client.send <- modeMessage
-
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Packet forwarder recovered from panic: %v", r)
-			}
-			log.Printf("Packet forwarder exiting for %s", client.conn.RemoteAddr())
-		}()
-		
-		for {
-			select {
-			case <-client.stopForwarder:
-				return
-			default:
-			}
-			
-			var packet *capture.Packet
-			var packetReceived bool
-			
-			// Check if we're in time window mode
-			if manager.timeWindowProcessor != nil && manager.currentCaptureMode == "time_window" {
-				select {
-				case packet = <-manager.timeWindowProcessor.GetPacketChannel():
-					packetReceived = true
-				case <-client.stopForwarder:
-					return
-				case <-time.After(1 * time.Millisecond):
-					// No packet available from time window, continue
-				}
-			} else {
-				// Normal live capture mode
-				select {
-				case packet = <-captureSystem.GetPacketChannel():
-					packetReceived = true
-				case <-client.stopForwarder:
-					return
-				case <-time.After(1 * time.Millisecond):
-					// No packet available, continue
-				}
-			}
-			
-			if packetReceived && packet != nil {
-				if manager.isIPPinned(packet.Src) || manager.isIPPinned(packet.Dst) || rand.Intn(10) < 9 { // Send 90% of packets instead of 50%
-					if packetJSON, err := packet.ToJSON(); err == nil {
-						select {
-						case client.send <- packetJSON:
-						case <-client.stopForwarder:
-							return
-						default:
-							// Never block the forwarder: if the WS queue is full, drop and keep draining ingest.
-							n := wsSendDropped.Add(1)
-							if n == 1 || n%10000 == 0 {
-								log.Printf("WebSocket send saturated: dropped %d packets (slow client vs ingest); graph may sample", n)
-							}
-						}
-					}
-				}
-			}
-		}
-	}()
-
-	go client.writePump(manager)
-	go client.readPump(manager)
-
-	<-client.disconnected
-	captureSystem.Stop()
-}
-
-func (c *Client) writePump(manager *ClientManager) {
-	ticker := time.NewTicker(pingPeriod)
-	defer func() {
-		ticker.Stop()
-		c.conn.Close()
-	}()
-
-	for {
-		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				return
-			}
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
-		}
-	}
-}
-
-func (c *Client) readPump(manager *ClientManager) {
-	defer func() {
-		manager.unregister <- c
-		c.conn.Close()
-		close(c.disconnected)
-	}()
-
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error { 
-		c.conn.SetReadDeadline(time.Now().Add(pongWait)); 
-		return nil 
-	})
-
-	for {
-		_, message, err := c.conn.ReadMessage()
-		if err != nil {
-			break
-		}
-		
-		var msg map[string]interface{}
-		if err := json.Unmarshal(message, &msg); err != nil {
-			continue
-		}
-
-		msgType, ok := msg["type"].(string)
-		if !ok {
-			continue
-		}
-
-		manager.rulesMutex.Lock()
-		switch msgType {
-		case "pinRule":
-			if rule, ok := msg["rule"].(string); ok {
-				manager.pinningRules = append(manager.pinningRules, rule)
-				log.Printf("Added pinning rule: %s", rule)
-			}
-		case "unpinRule":
-			if rule, ok := msg["rule"].(string); ok {
-				var newRules []string
-				for _, r := range manager.pinningRules {
-					if r != rule {
-						newRules = append(newRules, r)
-					}
-				}
-				manager.pinningRules = newRules
-				log.Printf("Removed pinning rule: %s", rule)
-			}
-		case "clearAllPins":
-			manager.pinningRules = make([]string, 0)
-			log.Printf("Cleared all pinning rules")
-		case "select_time_window":
-			manager.rulesMutex.Unlock() // Unlock before time window operations
-			manager.handleTimeWindowCommand(msg, c)
-			continue
-		case "switch_to_live":
-			manager.rulesMutex.Unlock()
-			manager.handleSwitchToLive(c)
-			continue
-		case "seek_to_time":
-			manager.rulesMutex.Unlock()
-			manager.handleSeekToTime(msg, c)
-			continue
-		}
-		manager.rulesMutex.Unlock()
-	}
-}
-
-func (manager *ClientManager) handleTimeWindowCommand(msg map[string]interface{}, client *Client) {
-	startTimeStr, startOk := msg["start_time"].(string)
-	endTimeStr, endOk := msg["end_time"].(string)
-	speed, speedOk := msg["speed"].(float64)
-	
-	if !startOk || !endOk {
-		log.Printf("Invalid time window command: missing start_time or end_time")
-		return
-	}
-	
-	startTime, err := time.Parse(time.RFC3339, startTimeStr)
-	if err != nil {
-		log.Printf("Invalid start_time format: %v", err)
-		return
-	}
-	
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
-	if err != nil {
-		log.Printf("Invalid end_time format: %v", err)
-		return
-	}
-	
-	replaySpeed := 1.0
-	if speedOk && speed > 0 {
-		replaySpeed = speed
-	}
-	
-	log.Printf("🕰️ Time Window Request: %s to %s (%.2fx speed)", startTime.Format("15:04:05"), endTime.Format("15:04:05"), replaySpeed)
-	
-	// Create time window processor
-	config := capture.TimeWindowConfig{
-		StorageDir:   *storageDir,
-		StartTime:    startTime,
-		EndTime:      endTime,
-		ReplaySpeed:  replaySpeed,
-		SamplingRate: 10, // Default sampling rate
-	}
-	processor := capture.NewTimeWindowProcessor(config)
-	
-	// Stop current capture if running
-	if manager.originalCapture != nil {
-		manager.originalCapture.Stop()
-	}
-	
-	// Start time window playback
-	if err := processor.Start(); err != nil {
-		log.Printf("Failed to start time window playback: %v", err)
-		response, _ := json.Marshal(map[string]interface{}{
-			"type": "time_window_error",
-			"error": err.Error(),
-		})
-		client.send <- response
-		return
-	}
-	
-	manager.timeWindowProcessor = processor
-	manager.currentCaptureMode = "time_window"
-	
-	// Send success response
-	response, _ := json.Marshal(map[string]interface{}{
-		"type": "time_window_active",
-		"start_time": startTimeStr,
-		"end_time": endTimeStr,
-		"speed": replaySpeed,
-	})
-	client.send <- response
-	
-	log.Printf("⚡ Time window playback activated!")
-}
-
-func (manager *ClientManager) handleSwitchToLive(client *Client) {
-	log.Printf("🔄 Switching back to live mode...")
-	
-	// Stop time window processor
-	if manager.timeWindowProcessor != nil {
-		manager.timeWindowProcessor.Stop()
-		manager.timeWindowProcessor = nil
-	}
-	
-	// Restart original capture
-	if manager.originalCapture != nil {
-		if err := manager.originalCapture.Start(); err != nil {
-			log.Printf("Failed to restart live capture: %v", err)
-			response, _ := json.Marshal(map[string]interface{}{
-				"type": "switch_to_live_error",
-				"error": err.Error(),
-			})
-			client.send <- response
-			return
-		}
-	}
-	
-	manager.currentCaptureMode = "live"
-	
-	// Send success response
-	response, _ := json.Marshal(map[string]interface{}{
-		"type": "live_mode_active",
-	})
-	client.send <- response
-	
-	log.Printf("📡 Live mode reactivated!")
-}
-
-func (manager *ClientManager) handleSeekToTime(msg map[string]interface{}, client *Client) {
-	timeStr, ok := msg["time"].(string)
-	if !ok {
-		log.Printf("Invalid seek command: missing time")
-		return
-	}
-	
-	seekTime, err := time.Parse(time.RFC3339, timeStr)
-	if err != nil {
-		log.Printf("Invalid seek time format: %v", err)
-		return
-	}
-	
-	if manager.timeWindowProcessor == nil {
-		log.Printf("No time window processor active for seeking")
-		response, _ := json.Marshal(map[string]interface{}{
-			"type": "seek_error",
-			"error": "No time window active",
-		})
-		client.send <- response
-		return
-	}
-	
-	log.Printf("⏰ Seeking to time: %s", seekTime.Format("15:04:05"))
-	
-	if err := manager.timeWindowProcessor.SeekToTime(seekTime); err != nil {
-		log.Printf("Failed to seek to time: %v", err)
-		response, _ := json.Marshal(map[string]interface{}{
-			"type": "seek_error",
-			"error": err.Error(),
-		})
-		client.send <- response
-		return
-	}
-	
-	// Send success response
-	response, _ := json.Marshal(map[string]interface{}{
-		"type": "seek_complete",
-		"time": timeStr,
-	})
-	client.send <- response
-	
-	log.Printf("🎯 Seek complete!")
-}
-
-// checkDumpcapRunning checks if dumpcap is already running
-func checkDumpcapRunning() bool {
-	cmd := exec.Command("pgrep", "-f", "dumpcap")
-	err := cmd.Run()
-	return err == nil
-}
-
-// checkDumpcapInstalled checks if dumpcap is installed and available
-func checkDumpcapInstalled() bool {
-	cmd := exec.Command("which", "dumpcap")
-	err := cmd.Run()
-	return err == nil
-}
-
-// launchDumpcapProcess starts dumpcap with the specified interface and output directory
-func launchDumpcapProcess(iface string, outputDir string) error {
-	if !checkDumpcapInstalled() {
-		return fmt.Errorf("dumpcap not found in PATH - please install Wireshark/dumpcap")
-	}
-
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create dumpcap output directory: %v", err)
-	}
-
-	// Generate output filename with timestamp
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	outputFile := filepath.Join(outputDir, fmt.Sprintf("dumpcap_%s_%s.pcap", iface, timestamp))
-
-	// Build dumpcap command
-	args := []string{
-		"-i", iface,
-		"-w", outputFile,
-		"-b", "duration:3600", // Rotate every hour
-		"-b", "filesize:1000000", // Rotate at 1GB
-	}
-
-	log.Printf("🚀 Launching dumpcap: dumpcap %s", strings.Join(args, " "))
-	
-	cmd := exec.Command("dumpcap", args...)
-	
-	// Start dumpcap in background
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start dumpcap: %v", err)
-	}
-
-	log.Printf("✅ Dumpcap process started with PID %d", cmd.Process.Pid)
-	log.Printf("📁 Writing to: %s", outputFile)
-	
-	// Give dumpcap a moment to start writing
-	time.Sleep(2 * time.Second)
-	
-	return nil
-}
-
-// handleDumpcapSetup checks dumpcap status and optionally launches it
-func handleDumpcapSetup(iface string, outputDir string) error {
-	log.Printf("🔍 Checking dumpcap status...")
-	
-	// Check if dumpcap is installed
-	if !checkDumpcapInstalled() {
-		return fmt.Errorf("dumpcap not installed - please install Wireshark or dumpcap")
-	}
-	log.Printf("✅ Dumpcap is installed")
-	
-	// Check if dumpcap is already running
-	if checkDumpcapRunning() {
-		log.Printf("✅ Dumpcap process is already running")
-		
-		// Check if output directory has recent PCAP files
-		if hasRecentPcapFiles(outputDir) {
-			log.Printf("✅ Found recent PCAP files in %s", outputDir)
-			return nil
-		} else {
-			log.Printf("⚠️ Dumpcap is running but no recent PCAP files found")
-			log.Printf("💡 Check that dumpcap is writing to: %s", outputDir)
-		}
-	} else {
-		log.Printf("❌ Dumpcap is not running")
-		
-		if *launchDumpcap {
-			log.Printf("🚀 Auto-launching dumpcap...")
-			if err := launchDumpcapProcess(iface, outputDir); err != nil {
-				return fmt.Errorf("failed to auto-launch dumpcap: %v", err)
-			}
-		} else {
-			return fmt.Errorf("dumpcap is not running. Options:\n" +
-				"  1. Start dumpcap manually: dumpcap -i %s -w %s/capture.pcap\n" +
-				"  2. Use auto-launch: add -launch-dumpcap flag", iface, outputDir)
-		}
-	}
-	
-	return nil
-}
-
-// hasRecentPcapFiles checks if there are PCAP files modified in the last 5 minutes
-func hasRecentPcapFiles(dir string) bool {
-	files, err := filepath.Glob(filepath.Join(dir, "*.pcap"))
-	if err != nil {
-		return false
-	}
-	
-	cutoff := time.Now().Add(-5 * time.Minute)
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			continue
-		}
-		
-		if info.ModTime().After(cutoff) {
-			return true
-		}
-	}
-	
-	return false
-}
-
-func main() {
-	flag.Parse()
-
-	// Show usage information if help is requested
-	if len(flag.Args()) > 0 && (flag.Args()[0] == "help" || flag.Args()[0] == "-help" || flag.Args()[0] == "--help") {
-		fmt.Println("VIBES Network Visualizer Backend")
-		fmt.Println("================================")
-		fmt.Println()
-		fmt.Println("Usage examples:")
-		fmt.Println("  Simulated mode:     go run main.go")
-		fmt.Println("  Real capture:       sudo go run main.go -iface eth0")
-		fmt.Println("  Dumpcap mode:       go run main.go -dumpcap -dumpcap-dir /data/pcaps -iface en1")
-		fmt.Println("  Auto-launch:        go run main.go -dumpcap -launch-dumpcap -iface en1")
-		fmt.Println("  PCAP replay:        go run main.go -pcap /path/to/file.pcap")
-		fmt.Println("  PCAP replay 2x:     go run main.go -pcap /path/to/file.pcap -speed 2.0")
-		fmt.Println("  Zeek conn JSON:     go run main.go -zeek-tcp :4777   # then ws://.../ws?zeek_tcp=1")
-		fmt.Println("  Custom port:        go run main.go -addr :9090")
-		fmt.Println("  Time windows:       go run main.go -storage /data/pcaps")
-		fmt.Println()
-		fmt.Println("URL Parameters (override command line):")
-		fmt.Println("  ws://localhost:8080/ws?pcap=/path/file.pcap&speed=2.0")
-		fmt.Println("  ws://localhost:8080/ws?interface=eth0")
-		fmt.Println("  ws://localhost:8080/ws?zeek_tcp=:4777")
-		fmt.Println("  ws://localhost:8080/ws?zeek_tcp=1   (uses -zeek-tcp address)")
-		fmt.Println()
-		fmt.Println("WebSocket Commands:")
-		fmt.Println("  Time Window: {\"type\":\"select_time_window\",\"start_time\":\"2023-01-01T10:00:00Z\",\"end_time\":\"2023-01-01T11:00:00Z\",\"speed\":2.0}")
-		fmt.Println("  Switch Live: {\"type\":\"switch_to_live\"}")
-		fmt.Println("  Seek Time:   {\"type\":\"seek_to_time\",\"time\":\"2023-01-01T10:30:00Z\"}")
-		fmt.Println()
-		fmt.Printf("Available flags:\n")
-		flag.PrintDefaults()
-		return
-	}
-
-	log.Printf("🔥 Starting VIBES Backend Server")
-
-	if *zeekTCPListen != "" {
-		if err := capture.EnsureZeekListener(*zeekTCPListen); err != nil {
-			log.Printf("⚠️ Zeek TCP listen (optional startup): %v — listener will start when a WebSocket connects in Zeek mode", err)
-		}
-	}
-	
-	// Log the current configuration
-	if *pcapFile != "" {
-		log.Printf("📼 PCAP Replay Mode: %s (speed: %.2fx)", *pcapFile, *replaySpeed)
-	} else if *useDumpcap {
-		log.Printf("🚀 Dumpcap Monitor Mode: %s (interface: %s)", *dumpcapDir, *iface)
-	} else if *iface != "" {
-		log.Printf("📡 Real Capture Mode: interface %s", *iface)
-	} else if *zeekTCPListen != "" {
-		log.Printf("🦅 Zeek TCP ingest default: %s (connect WebSocket with ?zeek_tcp=1 or ?zeek_tcp=%s)", *zeekTCPListen, *zeekTCPListen)
-	} else {
-		log.Printf("🎮 Simulation Mode: generating synthetic traffic")
-	}
-
-	manager := NewClientManager()
-	go manager.Start()
-
-	http.HandleFunc("/ws", manager.HandleWebSocket)
-	http.HandleFunc("/api/interfaces", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		interfaces, err := capture.ListInterfaces()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		json.NewEncoder(w).Encode(interfaces)
-	})
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "public/index.html")
-	})
-
-	log.Printf("Starting server on %s", *addr)
-	if err := http.ListenAndServe(*addr, nil); err != nil {
-		log.Fatal("ListenAndServe: ", err)
-	}
-}
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"vibes-network-visualizer/internal/audit"
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+	"vibes-network-visualizer/internal/health"
+	"vibes-network-visualizer/internal/notify"
+	"vibes-network-visualizer/internal/pins"
+	"vibes-network-visualizer/internal/schedule"
+	"vibes-network-visualizer/internal/storage"
+	"vibes-network-visualizer/internal/timeline"
+	"vibes-network-visualizer/internal/web"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = 5 * time.Second // often, so the round trip in /api/sessions stays current
+	maxMessageSize = 512
+)
+
+var (
+	addr        = flag.String("addr", ":8080", "http service address")
+	webDir      = flag.String("web-dir", "", "serve the frontend from this build directory (e.g. ../frontend/dist) instead of the one embedded in the binary")
+	iface       = flag.String("iface", "", "network interface to capture (empty for simulated data)")
+	monitorMode = flag.Bool("monitor", false, "put the -iface wireless interface into monitor mode and report 802.11 networks, channels and signal strength (wifi_stats)")
+	dedupWindow = flag.Duration("dedup-window", 0, "drop the second copy of packets seen twice within this long, for mirrored taps that deliver both directions from two switch ports (e.g. 10ms; 0 disables)")
+	pcapFile    = flag.String("pcap", "", "path to PCAP file for replay mode")
+	replaySpeed = flag.Float64("speed", 1.0, "replay speed multiplier (1.0 = real-time, 2.0 = 2x speed)")
+	storageDir  = flag.String("storage", "/data/pcaps", "directory containing PCAP archives for time window playback, or an s3://bucket/prefix in S3 or MinIO")
+	useDumpcap  = flag.Bool("dumpcap", false, "use external dumpcap for high-performance capture (requires dumpcap to be running)")
+	dumpcapDir  = flag.String("dumpcap-dir", "/data/pcaps", "directory where dumpcap writes PCAP files")
+	captureDir    = flag.String("capture-dir", "", "capture -iface natively and write rotating PCAP files here (no dumpcap needed); point -storage at the same directory for time window playback")
+	s3Endpoint    = flag.String("s3-endpoint", "", "S3-compatible endpoint (e.g. http://minio:9000) of an s3:// -storage, addressed path-style; empty for AWS. Credentials come from $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY")
+	s3Region      = flag.String("s3-region", "us-east-1", "region of an s3:// -storage bucket")
+	rotateEvery   = flag.Duration("rotate-every", time.Hour, "start a new capture file after this long (-capture-dir)")
+	rotateSizeMB  = flag.Int64("rotate-size", 1024, "start a new capture file after this many MB (-capture-dir)")
+	runAsUser     = flag.String("user", "", "drop root privileges to this user after opening the listen socket and -capture-dir handle (Unix)")
+	systemdNotify = flag.Bool("systemd-notify", false, "tell systemd when the server is ready and feed its watchdog (Type=notify units; see deploy/systemd)")
+	launchDumpcap = flag.Bool("launch-dumpcap", false, "automatically launch dumpcap process if not running")
+	zeekTCPListen = flag.String("zeek-tcp", "", "default listen address for Zeek conn.log JSON over TCP (e.g. :4777); used when WebSocket connects with zeek_tcp=1")
+	configPath         = flag.String("config", "", "path to JSON config file (subnet groups and other structured settings)")
+	watchConfig        = flag.Bool("watch-config", false, "reload -config when the file changes (SIGHUP and POST /api/config/reload always work)")
+	archiveIndexFile   = flag.String("archive-index", "archive-index.json", "file where the storage directory's time index is persisted (empty to keep it in memory)")
+	archiveScan        = flag.Duration("archive-scan", time.Minute, "how often the storage directory is rescanned for new or changed PCAP files")
+	verifyArchives     = flag.Bool("verify-archives", true, "check rotated PCAP files after crashes: cut truncated ones back to their last whole packet and rename damaged ones to *.corrupt")
+	recordingsDir      = flag.String("recordings", "recordings", "directory where start_recording writes PCAP files")
+	assetsFile         = flag.String("assets", "assets.json", "file where the IP → label/role/owner asset inventory is persisted")
+	adminToken         = flag.String("admin-token", "", "bearer token required by /api/sessions and /api/rooms (empty leaves them open)")
+	presetsFile        = flag.String("presets", "presets.json", "file where named pin/filter/sampling presets are persisted")
+	bookmarksFile      = flag.String("bookmarks", "bookmarks.json", "file where bookmarked moments are persisted")
+	devicesFile        = flag.String("devices", "devices.json", "file where the hosts seen on the config file's devices subnets are remembered")
+	edgesFile          = flag.String("edges", "edges.json", "file where the connections seen on the config file's new_edges subnets are remembered")
+	streamsDir         = flag.String("streams", "streams", "directory where room stream recordings are written and played back from with /ws/playback")
+	relayAccept        = flag.Bool("relay-accept", false, "accept packet streams from capture agents on /api/relay (view them with /ws?relay=1)")
+	relayToken         = flag.String("relay-token", "", "shared secret agents must present to /api/relay (empty leaves it open)")
+	relayTo            = flag.String("relay-to", "", "run as a capture agent: forward this instance's capture to the vibes server at this URL")
+	sensorID           = flag.String("sensor-id", "", "sensor ID this agent tags its packets with (-relay-to; defaults to the hostname)")
+	scenarioName       = flag.String("scenario", "", "scenario for simulated traffic: a built-in name (see /api/scenarios) or a YAML file (default busy-lan)")
+	scenarioDir        = flag.String("scenario-dir", "scenarios", "directory of YAML scenarios selectable by name with ?scenario= or /api/scenarios")
+	mixScenario        = flag.String("mix", "", "overlay simulated traffic on every non-simulated session: a scenario name, or 1 for the default scenario")
+	injectIface        = flag.String("inject-iface", "", "transmit simulated traffic as real frames on this interface (closed lab networks only)")
+	injectScenario     = flag.String("inject-scenario", "", "scenario to transmit with -inject-iface (default: the simulation's scenario)")
+	injectPPS          = flag.Int("inject-pps", 2000, "cap on frames per second transmitted with -inject-iface (0 = no cap)")
+	replayIface        = flag.String("replay-iface", "", "re-transmit a capture file onto this interface, like tcpreplay (see -replay-file)")
+	replayFile         = flag.String("replay-file", "", "capture file to re-transmit with -replay-iface (default: -pcap)")
+	replayWireSpeed    = flag.Float64("replay-speed", 1.0, "-replay-iface pace as a multiple of the file's own timing (0 = as fast as possible)")
+	replayPPS          = flag.Float64("replay-pps", 0, "-replay-iface pace in packets per second instead of the file's timing")
+	replayMbps         = flag.Float64("replay-mbps", 0, "-replay-iface pace in megabits per second instead of the file's timing")
+	replayLoops        = flag.Int("replay-loops", 1, "passes over the file with -replay-iface (0 = until the server stops)")
+	topologySpec       = flag.String("topology", "", "generate the simulated network instead of using -scenario: subnets=N,hosts=N,server_ratio=F,external=N,seed=N")
+	sensorSilentAfter  = flag.Duration("sensor-silent", 30*time.Second, "alert when a relay sensor sends nothing (not even a heartbeat) for this long")
+	auditFile          = flag.String("audit-log", "audit.jsonl", "append-only file recording mode switches, pin changes, exports and admin actions, queried with /api/audit (empty to disable)")
+	timelineFile       = flag.String("timeline", "timeline.jsonl", "file keeping annotations, alerts, scans and mode switches for /api/timeline and time window replays (empty to disable)")
+	anonymize          = flag.Bool("anonymize", false, "pseudonymize attendee addresses in every room's stream unless its config sets \"anonymize\": false (for public kiosks)")
+	anonymizeKey       = flag.String("anonymize-key", "", "passphrase for -anonymize and anonymized rooms; keeps pseudonyms stable across restarts (defaults to $VIBES_ANONYMIZE_KEY)")
+	edgeQuietBps       = flag.Float64("edge-quiet", 10_000, "edges carrying less than this many bits per second (rolling 10 s average) are tagged intensity \"quiet\"")
+	edgeHotBps         = flag.Float64("edge-hot", 1_000_000, "edges carrying at least this many bits per second (rolling 10 s average) are tagged intensity \"hot\"")
+	layoutMinNodes     = flag.Int("layout-min-nodes", 5000, "send layout_hints (communities and k-cores every 5 s) to sessions whose graph has at least this many hosts (0 = never)")
+	resumeTTL          = flag.Duration("resume-ttl", 2*time.Minute, "how long a disconnected session can be resumed with its resume token, keeping its parameters, stream and time window playback (0 disables)")
+	maxTimeWindows     = flag.Int("max-time-windows", 4, "time window playbacks that may read the archive at once, rooms' and sessions' together; more wait in a queue (0 = no limit)")
+	windowWorkers      = flag.Int("window-workers", 4, "PCAP files each time window playback scans and reads ahead at once, merged in timestamp order")
+	summaryAfter       = flag.Int("summary-after", 3, "switch a session from raw packets to per-second edge summaries after it loses messages this many seconds in a row (0 = never)")
+	watchlistBPF       = flag.Bool("watchlist-bpf", false, "narrow live capture, in the kernel, to traffic the room's pins or preset filter select, in rooms whose config doesn't set \"watchlist_bpf\"")
+	aggregateMulticast = flag.Bool("aggregate-multicast", false, "show multicast and broadcast destinations as one node per group protocol (mdns, ssdp, igmp, ...) in rooms whose config doesn't set \"aggregate_multicast\"")
+	exposeMetadata     = flag.String("expose", "ports,hostnames", "metadata streamed to rooms without \"expose\" in their config: ports, hostnames, both or neither (empty)")
+	anonymizeNets      = flag.String("anonymize-nets", "", "comma-separated CIDRs to pseudonymize (default: private, CGNAT and link-local ranges)")
+	reputationProvider = flag.String("reputation", "", "IP reputation provider for external IPs: abuseipdb or greynoise (empty to disable)")
+	reputationKey      = flag.String("reputation-key", "", "API key for the reputation provider (defaults to $VIBES_REPUTATION_KEY)")
+	reputationRate     = flag.Int("reputation-rate", 30, "maximum reputation API lookups per minute")
+	reputationTTL      = flag.Duration("reputation-ttl", 24*time.Hour, "how long reputation scores stay cached")
+	reputationCache    = flag.String("reputation-cache", "", "file used to persist reputation scores across restarts")
+	ifaceStatsEvery    = flag.Duration("interface-stats", 2*time.Second, "how often sessions capturing an interface are sent its link counters: utilization, errors and drops (interface_stats; 0 disables)")
+	upgrader    = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true // Allow all origins
+		},
+		Subprotocols: []string{"vibes.v1"}, // echoed back when a client negotiates the version this way
+	}
+	// Packets dropped when WebSocket send buffer is full (ingest faster than browser/network).
+	wsSendDropped atomic.Uint64
+)
+
+type Client struct {
+	conn          sessionConn
+	send          chan []byte
+	disconnected  chan struct{}
+	stopForwarder chan struct{}
+	nodeInfoSent  map[string]struct{} // IPs this client already received node_info for (forwarder goroutine only)
+	tcpMetrics    atomic.Pointer[capture.TCPMetrics]
+	graph         *capture.FlowGraph
+	recorder      atomic.Pointer[capture.Recorder] // active PCAP recording, if any
+	ring          *capture.PacketRing              // the last -ring of frames, for dump_ring; nil when off
+	connectedAt   time.Time
+	protocol      int // negotiated WebSocket protocol version
+	room          *Room
+	sourceMu      sync.Mutex // guards mode, source and sourceClosed; switch_profile replaces the source
+	mode          string     // capture mode chosen at connect or by the room's profile
+	source        capture.PacketCapture
+	sourceClosed  bool // the session ended; a source installed now must be stopped instead
+
+	// WebSocket send accounting for /api/sessions
+	sentBytes          atomic.Uint64
+	sentMessages       atomic.Uint64
+	duplicates         atomic.Uint64    // mirrored-tap copies removed with -dedup-window
+	compressor         *frameCompressor // set with ?compress=deflate; writePump only
+	compressedBytes    atomic.Uint64    // compressed frame bytes sent; sentBytes counts messages before compression
+	uplink             atomic.Pointer[uplinkRate]
+	uplinkLastBytes    uint64 // forwarder goroutine only
+	uplinkLastMessages uint64
+
+	// Messages lost to a full send queue, reported to the client every dropsReportInterval
+	coalesce        bool // latest-wins: a full queue drops its oldest message instead of the newest
+	dropped         atomic.Uint64
+	dropsReported   uint64    // forwarder goroutine only
+	dropsReportedAt time.Time // forwarder goroutine only
+
+	// Summary stream: per-second edge totals instead of packets, requested or forced by backpressure
+	summary         atomic.Bool
+	conversations   atomic.Bool // summaries merge both directions of a host pair (the conversations stream)
+	slowSeconds     int         // forwarder goroutine only
+	slowLastDropped uint64      // forwarder goroutine only
+
+	replayFinished  capture.ProgressReporter // replay whose end was already reported (forwarder goroutine only)
+	onComplete      string                   // what to do when a PCAP replay ends: loop, switch_to_live or stop
+	replayCompleted *capture.ReplayComplete  // forwarder goroutine only
+	interfaceStats  capture.InterfacePoller  // forwarder goroutine only
+
+	// The session's own time window playback (select_time_window with "scope":"session"), guarded by room.mu
+	window       *capture.TimeWindowProcessor
+	windowSlot   func()           // frees the playback's slot in manager.windows
+	windowQueued chan struct{}    // closed to take back a request waiting for a slot
+	windowEvents []timeline.Event // the window's timeline, shown again as playback passes it
+
+	heartbeat heartbeatState // round trips and send queue lag
+}
+
+type ClientManager struct {
+	clients            map[*Client]bool
+	clientsMutex       sync.RWMutex // guards clients for readers outside Start
+	broadcast          chan []byte
+	register           chan *Client
+	unregister         chan *Client
+	presets            *pins.PresetStore
+	bookmarks          *pins.BookmarkStore
+	rooms              map[string]*Room
+	roomsMutex         sync.Mutex
+	reputation          *enrich.ReputationCache
+	cfg                 *config.Config // replaced on reload, under roomsMutex
+	groups              *enrich.SubnetGroups
+	darkSpace           *enrich.SubnetGroups               // ranges that should never send; empty when none are configured
+	exfil               atomic.Pointer[capture.ExfilRules] // nil unless the config file has an exfiltration section
+	schedules           atomic.Pointer[schedule.Schedules] // nil unless the config file has schedules
+	scheduled           atomic.Pointer[schedule.State]     // what the open schedule windows change; nil when none is open
+	assets              *enrich.AssetStore
+	nodeGrouper         atomic.Pointer[enrich.NodeGrouper] // nil when node_grouping isn't configured
+	nat                 *enrich.NATTable                   // nil unless the config file has a nat section
+	leases              *enrich.LeaseTable                 // nil unless the config file has a dhcp section
+	neighbors           *enrich.NeighborTable              // nil unless the config file has an arp section
+	devices             *enrich.DeviceRegistry             // nil unless the config file has a devices section
+	edges               *enrich.EdgeBaseline               // nil unless the config file has a new_edges section
+	dns                 *enrich.DNSCache                   // names DNS responses on the wire resolved, for hostname pins
+	topology            *capture.Topology                  // LLDP and CDP neighbors, for /api/topology
+	raGuard             *capture.RAGuard                   // IPv6 routers heard advertising, checked against router_advertisements
+	dhcpGuard           *capture.DHCPGuard                 // DHCP servers heard answering, checked against dhcp_guard
+	probes              probeSlots                         // probe commands running, one per room
+	health              *health.Monitor                    // nil unless the config file has health_checks
+	anonymizer          *enrich.Anonymizer  // nil unless some room is anonymized
+	auditLog            *audit.Log          // nil when -audit-log is empty
+	timeline            *timeline.Store     // nil when -timeline is empty
+	timelineAlerts      alertCooldown       // one timeline entry per repeated alert
+	store               capture.ArchiveStore // -storage: a directory or a bucket
+	archive             *capture.ArchiveIndex
+	retention           atomic.Pointer[storage.RetentionManager] // nil unless the config file has a retention section
+	integrity           *storage.IntegrityChecker                // nil with -verify-archives=false
+	feed                *packetFeed               // enriched stream for programmatic subscribers (gRPC)
+	sensors             *sensorRegistry           // relay agents seen by /api/relay
+	resumes             *resumeStore              // state of disconnected sessions, by resume token
+	windows             *windowQueue              // slots for time window playback
+	notifier            atomic.Pointer[notify.Notifier] // nil unless the config file lists webhooks
+	reloadMu            sync.Mutex                      // serializes config reloads
+}
+
+func NewClientManager(cfg *config.Config) (*ClientManager, error) {
+	groups, err := enrich.NewSubnetGroups(cfg.SubnetGroups)
+	if err != nil {
+		return nil, err
+	}
+	darkSpace, err := enrich.NewSubnetGroups(cfg.DarkSpace)
+	if err != nil {
+		return nil, fmt.Errorf("dark_space: %v", err)
+	}
+	raRules, err := raGuardRules(cfg.RouterAdverts)
+	if err != nil {
+		return nil, fmt.Errorf("router_advertisements: %v", err)
+	}
+	dhcpRules, err := dhcpGuardRules(cfg.DHCPGuard)
+	if err != nil {
+		return nil, fmt.Errorf("dhcp_guard: %v", err)
+	}
+	exfil, err := exfilRules(cfg.Exfiltration)
+	if err != nil {
+		return nil, fmt.Errorf("exfiltration: %v", err)
+	}
+	schedules, err := schedule.New(cfg.Schedules)
+	if err != nil {
+		return nil, fmt.Errorf("schedules: %v", err)
+	}
+	assets, err := enrich.NewAssetStore(*assetsFile)
+	if err != nil {
+		return nil, err
+	}
+	presets, err := pins.NewPresetStore(*presetsFile)
+	if err != nil {
+		return nil, err
+	}
+	bookmarks, err := pins.NewBookmarkStore(*bookmarksFile)
+	if err != nil {
+		return nil, err
+	}
+	var nodeGrouper *enrich.NodeGrouper
+	if cfg.NodeGrouping != nil {
+		if nodeGrouper, err = enrich.NewNodeGrouper(cfg.NodeGrouping); err != nil {
+			return nil, err
+		}
+	}
+	nat, err := newNATTable(cfg.NAT)
+	if err != nil {
+		return nil, err
+	}
+	store, err := newArchiveStore(*storageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &ClientManager{
+		clients:      make(map[*Client]bool),
+		broadcast:    make(chan []byte),
+		register:     make(chan *Client),
+		unregister:   make(chan *Client),
+		presets:      presets,
+		bookmarks:    bookmarks,
+		rooms:        make(map[string]*Room),
+		cfg:          cfg,
+		groups:       groups,
+		darkSpace:    darkSpace,
+		assets:       assets,
+		store:        store,
+		archive:      capture.NewArchiveIndex(store, *archiveIndexFile),
+		windows:      newWindowQueue(*maxTimeWindows),
+		feed:         newPacketFeed(),
+		sensors:      newSensorRegistry(),
+		resumes:      newResumeStore(),
+		nat:          nat,
+		dns:          enrich.NewDNSCache(),
+		topology:     capture.NewTopology(),
+		raGuard:      capture.NewRAGuard(raRules),
+		dhcpGuard:    capture.NewDHCPGuard(dhcpRules),
+	}
+	manager.nodeGrouper.Store(nodeGrouper)
+	manager.exfil.Store(exfil)
+	manager.schedules.Store(schedules)
+	if err := manager.setupDHCP(cfg.DHCP); err != nil {
+		return nil, err
+	}
+	if err := manager.setupARP(cfg.ARP); err != nil {
+		return nil, err
+	}
+	if err := manager.setupHealthChecks(cfg.HealthChecks); err != nil {
+		return nil, err
+	}
+	if err := manager.setupDevices(cfg.Devices); err != nil {
+		return nil, err
+	}
+	if err := manager.setupEdges(cfg.NewEdges); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+func NewClient(conn sessionConn) *Client {
+	now := time.Now()
+	return &Client{
+		conn:            conn,
+		send:            make(chan []byte, 8192), // large enough for bursty Zeek NDJSON without blocking the capture drain loop
+		disconnected:    make(chan struct{}),
+		stopForwarder:   make(chan struct{}),
+		nodeInfoSent:    make(map[string]struct{}),
+		graph:           capture.NewFlowGraph(30*time.Minute, 100000),
+		ring:            newPacketRing(),
+		connectedAt:     now,
+		dropsReportedAt: now,
+	}
+}
+
+func (manager *ClientManager) Start() {
+	for {
+		select {
+		case client := <-manager.register:
+			manager.clientsMutex.Lock()
+			manager.clients[client] = true
+			manager.clientsMutex.Unlock()
+			log.Printf("Client connected. Total clients: %d", len(manager.clients))
+		case client := <-manager.unregister:
+			if _, ok := manager.clients[client]; ok {
+				manager.clientsMutex.Lock()
+				delete(manager.clients, client)
+				manager.clientsMutex.Unlock()
+				close(client.stopForwarder)
+				go func() {
+					time.Sleep(50 * time.Millisecond)
+					close(client.send)
+				}()
+				log.Printf("Client disconnected. Total clients: %d", len(manager.clients))
+			}
+		case message := <-manager.broadcast:
+			for client := range manager.clients {
+				// Slow client: drop (and count) this side-channel message rather than tearing the client
+				// down here (unregister owns closing client.send).
+				client.enqueue(message)
+			}
+		}
+	}
+}
+
+func (manager *ClientManager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	manager.serveSession(w, r, func() (sessionConn, error) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return nil, err
+		}
+		return newWSConn(conn), nil
+	})
+}
+
+// serveSession sets up a browser session from its request and runs it until it disconnects.
+// accept completes the handshake of the session's transport once the capture is ready.
+func (manager *ClientManager) serveSession(w http.ResponseWriter, r *http.Request, accept func() (sessionConn, error)) {
+	resume := manager.resumeSession(r)
+	protocol, err := negotiateProtocol(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	room, err := manager.room(r.URL.Query().Get("room"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	compression, err := negotiateCompression(r.URL.Query().Get("compress"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	onComplete, err := negotiateOnComplete(r.URL.Query().Get("on_complete"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ifaceName := r.URL.Query().Get("interface")
+	pcapParam := r.URL.Query().Get("pcap")
+	speedParam := r.URL.Query().Get("speed")
+
+	var captureSystem capture.PacketCapture
+	captureMode := "simulated"
+	
+	selectedPcapFile := *pcapFile
+	selectedReplaySpeed := *replaySpeed
+	selectedInterface := *iface
+	zeekParam := r.URL.Query().Get("zeek_tcp")
+	relayParam := r.URL.Query().Get("relay")
+	scenarioParam := r.URL.Query().Get("scenario")
+	mixParam := *mixScenario
+	if room.config.Mix != "" {
+		mixParam = room.config.Mix
+	}
+	if r.URL.Query().Has("mix") {
+		mixParam = r.URL.Query().Get("mix")
+	}
+
+	// Room configuration overrides the command line; query parameters override both
+	if room.config.Interface != "" {
+		selectedInterface = room.config.Interface
+	}
+	if room.config.PCAP != "" {
+		selectedPcapFile = room.config.PCAP
+	}
+	if room.config.Speed > 0 {
+		selectedReplaySpeed = room.config.Speed
+	}
+	if room.config.ZeekTCP != "" && zeekParam == "" {
+		zeekParam = room.config.ZeekTCP
+	}
+	if room.config.Relay != "" && relayParam == "" {
+		relayParam = room.config.Relay
+	}
+	if room.config.Scenario != "" && scenarioParam == "" {
+		scenarioParam = room.config.Scenario
+	}
+
+	if pcapParam != "" {
+		selectedPcapFile = pcapParam
+	}
+	if speedParam != "" {
+		if speed, err := strconv.ParseFloat(speedParam, 64); err == nil && speed > 0 {
+			selectedReplaySpeed = speed
+		}
+	}
+	if ifaceName != "" {
+		selectedInterface = ifaceName
+	}
+
+	var zeekAddr string
+	if zeekParam != "" {
+		if zeekParam == "1" || zeekParam == "true" {
+			if *zeekTCPListen == "" {
+				http.Error(w, "zeek_tcp=1 requires -zeek-tcp (e.g. -zeek-tcp :4777)", http.StatusBadRequest)
+				return
+			}
+			zeekAddr = *zeekTCPListen
+		} else {
+			zeekAddr = zeekParam
+		}
+	}
+
+	// relay=1 merges every sensor; relay=<sensor> follows one
+	var relaySensor string
+	if relayParam != "" {
+		if !*relayAccept {
+			http.Error(w, "relay requires -relay-accept", http.StatusBadRequest)
+			return
+		}
+		if relayParam != "1" && relayParam != "true" {
+			relaySensor = relayParam
+		}
+	}
+
+	// A room's capture profile replaces its configured source; explicit query parameters still win
+	profile := room.profile.Load()
+	query := r.URL.Query()
+	explicitSource := query.Has("interface") || query.Has("pcap") || query.Has("zeek_tcp") || query.Has("relay") || query.Has("scenario")
+	if profile != nil && !explicitSource {
+		captureSystem, captureMode, err = profileCapture(profile)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		selectedInterface, selectedPcapFile = profile.Interface, profile.PCAP
+		if profile.Speed > 0 {
+			selectedReplaySpeed = profile.Speed
+		}
+	} else if relayParam != "" {
+		captureSystem = capture.NewRelayCapture(relaySensor)
+		captureMode = "relay"
+	} else if scenarioParam != "" {
+		sim, err := newSimulation(scenarioParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		captureSystem = sim
+		captureMode = "simulated"
+	} else if selectedPcapFile != "" {
+		config := capture.PCAPReplayConfig{
+			FilePath:    selectedPcapFile,
+			ReplaySpeed: selectedReplaySpeed,
+		}
+		captureSystem = capture.NewPCAPReplayCapture(config)
+		captureMode = "pcap_replay"
+	} else if zeekAddr != "" {
+		captureSystem = capture.NewZeekConnJSONCapture(zeekAddr)
+		captureMode = "zeek_conn"
+	} else if *captureDir != "" && selectedInterface == *iface {
+		captureSystem = capture.NewArchiveCapture(selectedInterface)
+		captureMode = "archive"
+	} else if *useDumpcap {
+		// Check dumpcap status and optionally launch it
+		if err := handleDumpcapSetup(selectedInterface, *dumpcapDir); err != nil {
+			log.Printf("❌ Dumpcap setup failed: %v", err)
+			// Fall back to real capture if available
+			if selectedInterface != "" {
+				log.Printf("⚠️ Falling back to real capture mode")
+				captureSystem = newRealCapture(selectedInterface)
+				captureMode = "real"
+			} else {
+				log.Printf("⚠️ Falling back to simulation mode")
+				captureSystem = defaultSimulation()
+				captureMode = "simulated"
+			}
+		} else {
+			captureSystem = capture.NewDumpcapCapture(*dumpcapDir, selectedInterface)
+			captureMode = "dumpcap"
+		}
+	} else if selectedInterface != "" {
+		captureSystem = newRealCapture(selectedInterface)
+		captureMode = "real"
+	} else {
+		captureSystem = defaultSimulation()
+		captureMode = "simulated"
+	}
+
+	// mix overlays a scenario on the capture so simulated attacks play over real background traffic
+	var overlay *capture.SimulatedCapture
+	if mixParam != "" && mixParam != "0" && mixParam != "false" && captureMode != "simulated" {
+		var err error
+		if mixParam == "1" || mixParam == "true" {
+			overlay, err = newSimulation("")
+		} else {
+			overlay, err = newSimulation(mixParam)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		captureSystem = capture.NewMixedCapture(captureSystem, overlay)
+	}
+
+	// A room that only watches its watchlist has the kernel drop everything else
+	manager.narrowCapture(room, captureSystem)
+
+	// Try to start the capture with fallback handling
+	captureFailed := false
+	captureErrorMsg := ""
+	originalMode := captureMode
+	
+	if err := captureSystem.Start(); err != nil {
+		log.Printf("Failed to start %s capture: %v", captureMode, err)
+		captureFailed = true
+		captureErrorMsg = err.Error()
+		
+		// Fall back to simulation
+		log.Printf("Falling back to simulated capture")
+		captureSystem = defaultSimulation()
+		if err := captureSystem.Start(); err != nil {
+			http.Error(w, "Failed to start capture: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		captureMode = "simulated"
+		overlay = nil
+		log.Printf("*** FALLBACK TO SIMULATION (%s failed) ***", originalMode)
+	} else {
+		if overlay != nil {
+			log.Printf("*** 🎭 SIMULATION OVERLAY: scenario %s mixed into %s capture ***", overlay.Scenario().Name, captureMode)
+		}
+		// Log success based on mode
+		switch captureMode {
+		case "real":
+			log.Printf("*** 📡 REAL CAPTURE ACTIVE on interface %s ***", selectedInterface)
+		case "dumpcap":
+			log.Printf("*** 🚀 DUMPCAP MONITORING ACTIVE: %s (interface: %s) ***", *dumpcapDir, selectedInterface)
+		case "archive":
+			log.Printf("*** 💾 NATIVE CAPTURE ACTIVE on interface %s (archiving to %s) ***", selectedInterface, *captureDir)
+		case "pcap_replay":
+			log.Printf("*** 🔥 PCAP REPLAY ACTIVE: %s (%.2fx speed) ***", selectedPcapFile, selectedReplaySpeed)
+		case "zeek_conn":
+			log.Printf("*** 🦅 ZEEK CONN JSON (TCP) ACTIVE: ingest %s ***", zeekAddr)
+		case "relay":
+			if relaySensor == "" {
+				log.Printf("*** 🛰️ RELAY ACTIVE: merging all sensors ***")
+			} else {
+				log.Printf("*** 🛰️ RELAY ACTIVE: sensor %s ***", relaySensor)
+			}
+		case "simulated":
+			log.Printf("*** 🎮 SIMULATION ACTIVE (scenario %s) ***", captureSystem.(*capture.SimulatedCapture).Scenario().Name)
+		}
+	}
+
+	conn, err := accept()
+	if err != nil {
+		log.Println(err)
+		captureSystem.Stop()
+		return
+	}
+
+	client := NewClient(conn)
+	client.protocol = protocol
+	client.room = room
+	client.setCaptureSource(captureSystem, captureMode)
+	client.coalesce, _ = strconv.ParseBool(r.URL.Query().Get("coalesce"))
+	client.onComplete = onComplete
+	// WebTransport already sends packets as datagrams; only connections with binary frames compress
+	if _, ok := conn.(binaryWriter); ok && compression != "" {
+		client.compressor = newFrameCompressor()
+	}
+	client.useStream(r.URL.Query().Get("stream"))
+	manager.register <- client
+	
+	// Store original capture for live mode switching. A session resuming into the time window
+	// it was watching joins the playback instead of taking the room back to live.
+	room.mu.Lock()
+	replaying := resume != nil && resume.timeWindow && resume.room == room.name &&
+		room.timeWindowProcessor != nil && room.currentCaptureMode == "time_window"
+	room.originalCapture = captureSystem
+	if replaying {
+		captureSystem.Stop() // started again by switch_to_live
+	} else {
+		withdrawQueued(&room.windowQueued)
+		room.endTimeWindow()
+		room.currentCaptureMode = captureMode
+		room.notifyModeChange()
+	}
+	room.mu.Unlock()
+	resumeToken := manager.resumes.newToken()
+
+	// Send mode information to the client
+	var scenarioLabel string
+	if sim := simulationOf(captureSystem); sim != nil {
+		scenarioLabel = sim.Scenario().Name
+	}
+	var modeMessage []byte
+	if captureFailed {
+		// Send error message with fallback info
+		modeMessage, _ = json.Marshal(map[string]interface{}{
+			"type": "mode",
+			"mode": captureMode,
+			"interface": selectedInterface,
+			"pcapFile": selectedPcapFile,
+			"replaySpeed": selectedReplaySpeed,
+			"zeek_tcp": zeekAddr,
+			"protocol_version": protocol,
+			"room": room.name,
+			"scenario": scenarioLabel,
+			"anonymized": room.anonymized,
+			"coalesce": client.coalesce,
+			"stream": client.stream(),
+			"compress": client.compression(),
+			"on_complete": onComplete,
+			"resume_token": resumeToken,
+			"resumed": resume != nil,
+			"time_window": replaying,
+			"error": true,
+			"errorMsg": captureErrorMsg,
+			"requestedMode": originalMode,
+			"profile": profileName(profile),
+		})
+	} else {
+		// Normal mode message
+		modeMessage, _ = json.Marshal(map[string]interface{}{
+			"type": "mode",
+			"mode": captureMode,
+			"interface": selectedInterface,
+			"pcapFile": selectedPcapFile,
+			"replaySpeed": selectedReplaySpeed,
+			"zeek_tcp": zeekAddr,
+			"protocol_version": protocol,
+			"room": room.name,
+			"scenario": scenarioLabel,
+			"anonymized": room.anonymized,
+			"coalesce": client.coalesce,
+			"stream": client.stream(),
+			"compress": client.compression(),
+			"on_complete": onComplete,
+			"resume_token": resumeToken,
+			"resumed": resume != nil,
+			"time_window": replaying,
+			"overlay": overlay != nil,
+			"profile": profileName(profile),
+		})
+	}
+	client.send <- modeMessage
+	manager.sendKnownHosts(client)
+	if resume != nil {
+		client.trySend(roomViewMessage(room))
+		if resume.window != nil {
+			manager.resumeTimeWindow(client, resume.window)
+		}
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Packet forwarder recovered from panic: %v", r)
+			}
+			if client.recorder.Load() != nil {
+				manager.stopRecording(client)
+			}
+			log.Printf("Packet forwarder exiting for %s", client.conn.RemoteAddr())
+		}()
+
+		// Session lifecycle tracking sees every packet, ahead of sampling
+		conns := capture.NewConnTracker(capture.DefaultConnTrackerConfig())
+		sweepTicker := time.NewTicker(5 * time.Second)
+		defer sweepTicker.Stop()
+		tcpAnomalies := capture.NewTCPAnomalyDetector(capture.DefaultTCPAnomalyConfig(), conns)
+		statsTicker := time.NewTicker(5 * time.Second)
+		defer statsTicker.Stop()
+		dropsTicker := time.NewTicker(dropsReportInterval)
+		defer dropsTicker.Stop()
+		heartbeatTicker := time.NewTicker(heartbeatInterval)
+		defer heartbeatTicker.Stop()
+		var interfaceTick <-chan time.Time
+		if *ifaceStatsEvery > 0 {
+			interfaceTicker := time.NewTicker(*ifaceStatsEvery)
+			defer interfaceTicker.Stop()
+			interfaceTick = interfaceTicker.C
+		}
+		edges := capture.NewEdgeSummarizer()
+		intensityConfig := capture.DefaultIntensityConfig()
+		intensityConfig.QuietBps, intensityConfig.HotBps = *edgeQuietBps, *edgeHotBps
+		intensity := capture.NewEdgeIntensity(intensityConfig)
+		summaryTicker := time.NewTicker(time.Second)
+		defer summaryTicker.Stop()
+		groupStats := capture.NewGroupAccountant(manager.groups.Lookup)
+		protocolStats := capture.NewProtocolAccountant()
+		// Size stats are per interface: the captured one, a relay sensor or the kind of source
+		sizeInterface := captureMode
+		switch captureMode {
+		case "real", "dumpcap", "archive":
+			sizeInterface = selectedInterface
+		}
+		sizeStats := capture.NewSizeAnomalyDetector(capture.DefaultSizeAnomalyConfig(), sizeInterface)
+		fragments := capture.NewFragmentTracker(capture.DefaultFragmentConfig())
+		ttls := capture.NewTTLTracker(capture.DefaultTTLConfig())
+		latency := capture.NewLatencyTracker(capture.DefaultLatencyConfig())
+		tunnels := capture.NewTunnelClassifier(capture.DefaultTunnelConfig())
+		wireless := capture.NewWirelessTracker(capture.DefaultWirelessConfig())
+		roles := capture.NewRoleClassifier(capture.DefaultRoleConfig())
+		layoutConfig := capture.DefaultLayoutConfig()
+		layoutConfig.MinNodes = *layoutMinNodes
+		layout := capture.NewLayoutHinter(layoutConfig)
+		darkSpace := capture.NewDarkSpaceDetector(capture.DefaultDarkSpaceConfig(), manager.darkSpace.Lookup)
+		exfil := capture.NewExfilDetector(manager.exfil.Load)
+		dedupConfig := capture.DefaultDedupConfig()
+		dedupConfig.Window = *dedupWindow
+		dedup := capture.NewDeduplicator(dedupConfig)
+		
+		// Block until something happens: a packet, a tick, a room mode change or the session ending
+		packets, modeChanged := room.packetSource(client)
+		replay := client.replayCursor()
+		for {
+			select {
+			case <-client.stopForwarder:
+				return
+			case <-modeChanged:
+				packets, modeChanged = room.packetSource(client)
+				replay = client.replayCursor()
+			case <-sweepTicker.C:
+				swept := conns.Sweep()
+				sendAll(client, exposed(room.exposure.ConnEvent, swept))
+				manager.publishFeed(client, nil, swept)
+				client.graph.Prune()
+				tunnels.Sweep()
+				darkSpace.Sweep()
+				exfil.Sweep()
+			case <-statsTicker.C:
+				metrics, anomalies := tcpAnomalies.Report()
+				client.tcpMetrics.Store(metrics)
+				client.updateUplink(5 * time.Second)
+				client.trySend(room.exposure.TCPMetrics(metrics))
+				exposedAnomalies := exposed(room.exposure.TCPAnomaly, anomalies)
+				sendAll(client, exposedAnomalies)
+				notifyAll(manager, room, anomalies)
+				recordAlerts(manager, client, exposedAnomalies)
+				if len(manager.groups.Groups()) > 0 {
+					client.trySend(groupStats.Report())
+				}
+				if stats := protocolStats.Report(room.exposure.Ports); stats != nil {
+					client.trySend(stats)
+				}
+				sizes, sizeAnomalies := sizeStats.Report()
+				if sizes != nil {
+					client.trySend(sizes)
+				}
+				sendAll(client, sizeAnomalies)
+				notifyAll(manager, room, sizeAnomalies)
+				recordAlerts(manager, client, sizeAnomalies)
+				if stats := fragments.Report(); stats != nil {
+					client.trySend(stats)
+				}
+				ttlStats, ttlAnomalies := ttls.Report()
+				if ttlStats.Sources > 0 {
+					client.trySend(ttlStats)
+				}
+				sendAll(client, ttlAnomalies)
+				notifyAll(manager, room, ttlAnomalies)
+				recordAlerts(manager, client, ttlAnomalies)
+				if stats := latency.Report(); stats != nil {
+					client.trySend(stats)
+				}
+				if stats := wireless.Report(); stats != nil {
+					client.trySend(stats)
+				}
+				if stats := dedup.Report(); stats != nil {
+					client.trySend(stats)
+				}
+				if room.enriches(enrichNodeInfo) {
+					sendAll(client, exposed(room.exposure.NodeRole, roles.Report()))
+				}
+				if *layoutMinNodes > 0 {
+					if hints := layout.Report(); hints != nil {
+						client.trySend(hints)
+					}
+				}
+			case now := <-dropsTicker.C:
+				client.reportDrops(now)
+			case now := <-heartbeatTicker.C:
+				client.sendHeartbeat(now)
+			case now := <-interfaceTick:
+				client.sendInterfaceStats(now)
+			case <-summaryTicker.C:
+				intensity.Tick()
+				// Also flushes what was summed before a switch back to raw
+				if client.conversations.Load() {
+					if summary := edges.ReportConversations(maxSummaryEdges); summary != nil {
+						intensity.AnnotateConversations(summary)
+						client.trySend(summary)
+					}
+				} else if summary := edges.Report(maxSummaryEdges); summary != nil {
+					intensity.AnnotateEdges(summary)
+					client.trySend(summary)
+				}
+				client.watchBackpressure()
+				client.sendReplayProgress()
+				if manager.finishReplay(client) {
+					packets, modeChanged = room.packetSource(client)
+				}
+			case packet, ok := <-packets:
+				if !ok {
+					// The source ran out (a finished replay); wait for the room to switch
+					packets = nil
+					continue
+				}
+				if packet == nil {
+					continue
+				}
+				// Annotations and alerts from when this traffic was live come back as playback reaches them
+				for _, event := range replay.due(packet.Timestamp) {
+					client.trySend(newTimelineMessage(room, event, true))
+				}
+				// 802.11 management frames feed the wireless view only; they aren't IP traffic
+				if packet.Wireless != nil {
+					wireless.Observe(packet)
+					continue
+				}
+				// LLDP and CDP announcements only feed the topology
+				if packet.Neighbor != nil {
+					manager.observeNeighbor(client, packet)
+					continue
+				}
+				// and IPv6 Router Advertisements the router guard
+				if packet.RouterAdvert != nil {
+					manager.observeRouterAdvert(client, packet)
+					continue
+				}
+				trace := tracePacket(client, packet, len(packets))
+				// Mirrored taps deliver some packets twice; only the first copy goes any further
+				if dedup.Duplicate(packet) {
+					client.duplicates.Add(1)
+					trace.drop(dropDuplicate)
+					trace.finish()
+					continue
+				}
+				// The flight recorder keeps frames as captured; anonymized rooms keep no original frames
+				if client.ring != nil && !room.anonymized {
+					client.ring.Add(packet)
+				}
+				// Clients behind the NAT gateway get their own nodes, before anything counts the gateway
+				packet = manager.translateNAT(packet)
+				manager.observeDevice(packet)
+				manager.observeEdge(packet)
+				manager.observeDNS(packet)
+				manager.observeDHCP(client, packet)
+				// Subnet groups are defined on real addresses, so they are counted before masking
+				groupStats.Observe(packet)
+				// So is dark space; one packet from there is enough to alert, without waiting for the stats tick
+				if alert := darkSpace.Observe(packet); alert != nil {
+					alerts := []*capture.DarkSpaceAlert{manager.roomDarkSpaceAlert(room, alert)}
+					sendAll(client, alerts)
+					notifyAll(manager, room, alerts)
+					recordAlerts(manager, client, alerts)
+				}
+				// and what internal hosts send out, which also alerts as soon as a window fills up
+				if alert := exfil.Observe(packet); alert != nil {
+					alerts := []*capture.ExfilAlert{manager.roomExfilAlert(room, alert)}
+					sendAll(client, alerts)
+					notifyAll(manager, room, alerts)
+					recordAlerts(manager, client, alerts)
+				}
+				if room.anonymized {
+					packet = manager.anonymizePacket(packet)
+				}
+				// Handshakes tag the rest of their flow, so every tunnel packet is counted
+				if room.enriches(enrichTunnels) {
+					packet = tunnels.Classify(packet)
+				}
+				if room.enriches(enrichMulticast) {
+					packet = capture.ClassifyCast(packet, room.aggregated)
+				}
+				// Pins match the addresses as the room sees them, and count what they matched for
+				// /api/pins; the mark then rides with the packet past the feed, sampling, summaries and full queues
+				packet = capture.WithPinned(packet, room.pins.Observe(packet.Src, packet.Dst, packet.SrcPort, packet.DstPort, packet.Protocol))
+				if room.enriches(enrichNodeInfo) {
+					manager.sendNodeInfo(client, packet)
+				}
+				events := conns.Observe(packet)
+				sendAll(client, exposed(room.exposure.ConnEvent, events))
+				manager.publishFeed(client, packet, events)
+				tcpAnomalies.Observe(packet)
+				sizeStats.Observe(packet)
+				fragments.Observe(packet)
+				ttls.Observe(packet)
+				latency.Observe(packet)
+				if room.enriches(enrichNodeInfo) {
+					roles.Observe(packet)
+				}
+				client.graph.Observe(packet)
+				if recorder := client.recorder.Load(); recorder != nil {
+					if err := recorder.Write(packet); err != nil {
+						log.Printf("Recording failed, stopping: %v", err)
+						if _, err := manager.stopRecording(client); err == nil {
+							client.trySend(&recordingMessage{Type: "recording_error", Error: "recording stopped: write failed"})
+						}
+					}
+				}
+				trace.stage(stageEnrich)
+				view := room.view.Load()
+				inView := view.matches(packet)
+				if inView {
+					// Counted ahead of sampling, so the breakdown covers all of the room's traffic
+					protocolStats.Observe(packet)
+					intensity.Observe(packet)
+					if *layoutMinNodes > 0 {
+						layout.Observe(packet)
+					}
+				}
+				summarized := client.summary.Load() && inView
+				if summarized {
+					// Summaries count every packet in view; sampling only thins the raw stream
+					trace.stage(stageFilter)
+					edges.Observe(packet)
+					trace.stage(stageBroadcast)
+				}
+				// Pinned packets are streamed whole on every stream, never sampled away
+				if inView && (packet.Pinned || (!summarized && rand.Float64() < manager.sampleRate(room, view))) {
+					trace.stage(stageFilter)
+					// Nothing but the room's exposed metadata is streamed, never payload bytes
+					packet = room.exposure.Packet(manager.annotateGroups(room, packet))
+					packet.Intensity = intensity.Bucket(packet.Src, packet.Dst)
+					if packetJSON, err := packet.ToJSON(); err == nil {
+						// Never block the forwarder: if the WS queue is full, drop and keep draining ingest.
+						// A pinned packet takes the place of the oldest queued message instead.
+						enqueue := client.enqueue
+						if packet.Pinned {
+							enqueue = client.enqueueLatest
+						}
+						if !enqueue(packetJSON) {
+							trace.drop(dropQueueFull)
+						}
+					} else {
+						trace.drop(dropEncode)
+					}
+					trace.stage(stageBroadcast)
+				} else if !summarized {
+					trace.stage(stageFilter)
+					trace.drop(dropFiltered)
+				}
+				trace.finish()
+			}
+		}
+	}()
+
+	go client.writePump(manager)
+	go client.readPump(manager)
+
+	<-client.disconnected
+	client.closeCaptureSource()
+	manager.saveForResume(client, r, resumeToken)
+	manager.endSessionWindow(client)
+	manager.releaseIdleWindow(room)
+}
+
+func (c *Client) writePump(manager *ClientManager) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.room.releaseStreamTap(c)
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				c.conn.writeClose()
+				return
+			}
+			if c.compressor != nil {
+				closed, err := c.writeCompressed(manager, message)
+				if err != nil {
+					return
+				}
+				if closed {
+					c.conn.writeClose()
+					return
+				}
+				continue
+			}
+			if err := c.conn.writeText(message); err != nil {
+				return
+			}
+			c.wroteMessage(manager, message)
+		case <-ticker.C:
+			if err := c.conn.writePing(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) readPump(manager *ClientManager) {
+	defer func() {
+		manager.unregister <- c
+		c.conn.Close()
+		close(c.disconnected)
+	}()
+
+	for {
+		message, err := c.conn.readText()
+		if err != nil {
+			break
+		}
+		
+		msg, msgType, protoErr := decodeCommand(message)
+		if protoErr != nil {
+			log.Printf("Rejected command from %s: %s", c.conn.RemoteAddr(), protoErr.Message)
+			c.trySend(protoErr)
+			continue
+		}
+		// Every few seconds from every screen; too frequent and too dull for the audit log
+		if msgType == "heartbeat_ack" {
+			c.heartbeatAcked(msg)
+			continue
+		}
+		manager.auditCommand(c, msgType, msg)
+
+		switch msgType {
+		case "pinRule":
+			ttl, _ := msg["ttl"].(float64)
+			rule, err := c.room.pins.Add(msg["rule"].(string), time.Duration(ttl*float64(time.Second)))
+			if err != nil {
+				log.Printf("Rejected pinning rule %q: %v", msg["rule"], err)
+				protoErr := newProtocolError(errCodeInvalidField, msgType, "rule", "%v", err)
+				protoErr.ID = requestID(msg)
+				c.trySend(protoErr)
+				continue
+			}
+			if expires := rule.Expires(); !expires.IsZero() {
+				log.Printf("Added pinning rule: %s until %s", rule, expires.Format(time.RFC3339))
+			} else {
+				log.Printf("Added pinning rule: %s", rule)
+			}
+			manager.pushWatchFilter(c.room)
+		case "unpinRule":
+			rule := msg["rule"].(string)
+			c.room.pins.Remove(rule)
+			log.Printf("Removed pinning rule: %s", rule)
+			manager.pushWatchFilter(c.room)
+		case "clearAllPins":
+			c.room.pins.Clear()
+			log.Printf("Cleared all pinning rules")
+			manager.pushWatchFilter(c.room)
+		case "select_time_window":
+			var session *Client
+			switch msg["scope"] {
+			case nil, windowScopeRoom:
+			case windowScopeSession:
+				session = c
+			default:
+				protoErr := newProtocolError(errCodeInvalidField, msgType, "scope", "scope must be %q or %q", windowScopeRoom, windowScopeSession)
+				protoErr.ID = requestID(msg)
+				c.trySend(protoErr)
+				continue
+			}
+			manager.handleTimeWindowCommand(msg, c.room, session, c.sendReply)
+			continue
+		case "switch_to_live":
+			manager.handleSwitchToLive(msg, c.room, c, c.sendReply)
+			continue
+		case "seek_to_time":
+			manager.handleSeekToTime(msg, c)
+			continue
+		case "jump_back":
+			manager.handleJumpBack(msg, c)
+			continue
+		case "start_recording", "stop_recording":
+			manager.handleRecordingCommand(msgType, msg, c)
+			continue
+		case "apply_preset":
+			manager.handleApplyPresetCommand(msg, c)
+			continue
+		case "switch_profile":
+			manager.handleSwitchProfileCommand(msg, c)
+			continue
+		case "annotate":
+			manager.handleAnnotateCommand(msg, c)
+			continue
+		case "bookmark":
+			manager.handleBookmarkCommand(msg, c)
+			continue
+		case "trigger_scenario":
+			manager.handleTriggerCommand(msg, c)
+			continue
+		case "probe":
+			manager.handleProbeCommand(msg, c)
+			continue
+		case "dump_ring":
+			manager.handleDumpRingCommand(msg, c)
+			continue
+		case "set_stream":
+			manager.handleSetStreamCommand(msg, c)
+			continue
+		}
+
+		// Pin commands have no reply of their own; acknowledge them when the client asked to correlate
+		if id := requestID(msg); id != nil {
+			c.trySend(&commandAck{Type: "ack", Command: msgType, ID: id})
+		}
+	}
+}
+
+// handleTimeWindowCommand starts time window playback for a room, or for one session of it when
+// session is set; reply receives the result message
+func (manager *ClientManager) handleTimeWindowCommand(msg map[string]interface{}, room *Room, session *Client, reply func([]byte)) {
+	startTimeStr, startOk := msg["start_time"].(string)
+	endTimeStr, endOk := msg["end_time"].(string)
+	speed, speedOk := msg["speed"].(float64)
+	
+	if !startOk || !endOk {
+		log.Printf("Invalid time window command: missing start_time or end_time")
+		return
+	}
+	
+	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	if err != nil {
+		log.Printf("Invalid start_time format: %v", err)
+		return
+	}
+	
+	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	if err != nil {
+		log.Printf("Invalid end_time format: %v", err)
+		return
+	}
+	
+	replaySpeed := 1.0
+	if speedOk && speed > 0 {
+		replaySpeed = speed
+	}
+
+	sampling := capture.SamplingConfig{Strategy: capture.SampleUniform, KeepPinned: true}
+	sampling.Rate, _ = msg["sample_rate"].(float64)
+	if strategy, ok := msg["sampling"].(string); ok {
+		sampling.Strategy = strategy
+	}
+	if keepPinned, ok := msg["keep_pinned"].(bool); ok {
+		sampling.KeepPinned = keepPinned
+	}
+	if err := sampling.Validate(); err != nil {
+		field := "sampling"
+		if sampling.Rate > 1 {
+			field = "sample_rate"
+		}
+		protoErr := newProtocolError(errCodeInvalidField, "select_time_window", field, "%v", err)
+		protoErr.ID = requestID(msg)
+		response, _ := protoErr.ToJSON()
+		reply(response)
+		return
+	}
+	
+	log.Printf("🕰️ Time Window Request: %s to %s (%.2fx speed)", startTime.Format("15:04:05"), endTime.Format("15:04:05"), replaySpeed)
+	manager.requestTimeWindow(&timeWindowRequest{msg: msg, start: startTime, end: endTime, speed: replaySpeed, sampling: sampling, session: session}, room, reply)
+}
+
+// playTimeWindow starts a time window playback that has its slot, replacing the one the room (or
+// the session) had; release frees the slot when the playback ends or fails to start
+func (manager *ClientManager) playTimeWindow(req *timeWindowRequest, room *Room, release func(), reply func([]byte)) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	session := req.session
+	// Create time window processor
+	config := capture.TimeWindowConfig{
+		StorageDir:   *storageDir,
+		Store:        manager.store,
+		StartTime:    req.start,
+		EndTime:      req.end,
+		ReplaySpeed:  req.speed,
+		Sampling:     req.sampling,
+		Pinned:       manager.pinMatcher(room),
+		Index:        manager.archive,
+		ScanWorkers:  *windowWorkers,
+		OnSkew: func(skew capture.ClockSkew) {
+			if session != nil {
+				session.trySend(&skew)
+				return
+			}
+			manager.sendToRoom(room, &skew)
+		},
+	}
+	processor := capture.NewTimeWindowProcessor(config)
+
+	// Report holes (dumpcap restarts, rotated-away files) instead of silently playing nothing
+	var coverage *capture.WindowCoverage
+	if manager.archive.Len() > 0 {
+		coverage = manager.archive.CoverageFor(req.start, req.end, defaultCoverageGap)
+		if len(coverage.Gaps) > 0 {
+			log.Printf("⚠️ Time window has %d gaps (%.0f%% covered)", len(coverage.Gaps), coverage.CoveredRatio*100)
+		}
+	}
+	
+	// Stop the playback this one replaces, and the room's live capture
+	if session != nil {
+		session.endTimeWindow()
+	} else {
+		room.endTimeWindow()
+		if room.originalCapture != nil {
+			room.originalCapture.Stop()
+		}
+	}
+	
+	// Start time window playback
+	if err := processor.Start(); err != nil {
+		release()
+		log.Printf("Failed to start time window playback: %v", err)
+		response, _ := json.Marshal(withRequestID(req.msg, map[string]interface{}{
+			"type": "time_window_error",
+			"error": err.Error(),
+			"coverage": coverage,
+			"scope": req.scope(),
+		}))
+		reply(response)
+		return
+	}
+	if !req.resumeAt.IsZero() {
+		processor.SeekToTime(req.resumeAt)
+	}
+	
+	events := manager.timelineBetween(room, req.start, req.end)
+	if session != nil {
+		session.window, session.windowSlot, session.windowEvents = processor, release, events
+	} else {
+		room.timeWindowProcessor, room.windowSlot = processor, release
+		room.currentCaptureMode = "time_window"
+		room.replayEvents = events
+		manager.recordMode(room, "time window %s to %s (%.2fx)", req.start.Format(time.RFC3339), req.end.Format(time.RFC3339), req.speed)
+	}
+	room.notifyModeChange()
+	
+	// Send success response
+	response, _ := json.Marshal(withRequestID(req.msg, map[string]interface{}{
+		"type": "time_window_active",
+		"start_time": req.start.Format(time.RFC3339),
+		"end_time": req.end.Format(time.RFC3339),
+		"speed": req.speed,
+		"sampling": req.sampling,
+		"scope": req.scope(),
+		"coverage": coverage,
+		"timeline": events,
+	}))
+	reply(response)
+	
+	log.Printf("⚡ Time window playback activated!")
+}
+
+// handleSwitchToLive ends time window playback for a room; reply receives the result message. A
+// session (nil for the room's operator) with a time window of its own only ends that one.
+func (manager *ClientManager) handleSwitchToLive(msg map[string]interface{}, room *Room, session *Client, reply func([]byte)) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if session != nil && (session.window != nil || session.windowQueued != nil) {
+		withdrawQueued(&session.windowQueued)
+		session.endTimeWindow()
+		room.notifyModeChange()
+		log.Printf("🔄 %s left its own time window", session.conn.RemoteAddr())
+		response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+			"type": "live_mode_active",
+			"scope": windowScopeSession,
+		}))
+		reply(response)
+		return
+	}
+
+	log.Printf("🔄 Switching back to live mode...")
+	
+	// Stop time window processor
+	withdrawQueued(&room.windowQueued)
+	wasReplaying := room.timeWindowProcessor != nil
+	if wasReplaying {
+		room.endTimeWindow()
+		room.notifyModeChange()
+	}
+	
+	// Restart original capture
+	if room.originalCapture != nil {
+		if err := room.originalCapture.Start(); err != nil {
+			log.Printf("Failed to restart live capture: %v", err)
+			response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+				"type": "switch_to_live_error",
+				"error": err.Error(),
+			}))
+			reply(response)
+			return
+		}
+	}
+	
+	room.currentCaptureMode = "live"
+	if wasReplaying {
+		manager.recordMode(room, "live")
+	}
+	
+	// Send success response
+	response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+		"type": "live_mode_active",
+		"scope": windowScopeRoom,
+	}))
+	reply(response)
+	
+	log.Printf("📡 Live mode reactivated!")
+}
+
+func (manager *ClientManager) handleSeekToTime(msg map[string]interface{}, client *Client) {
+	if id, ok := msg["bookmark"].(string); ok {
+		delete(msg, "bookmark")
+		manager.seekToBookmark(msg, client, id)
+		return
+	}
+	if _, ok := msg["time"].(string); !ok {
+		protoErr := newProtocolError(errCodeMissingField, "seek_to_time", "time", "seek_to_time requires \"time\" or \"bookmark\"")
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	room := client.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	timeStr, ok := msg["time"].(string)
+	if !ok {
+		log.Printf("Invalid seek command: missing time")
+		return
+	}
+	
+	seekTime, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		log.Printf("Invalid seek time format: %v", err)
+		return
+	}
+	
+	processor := client.timeWindow()
+	if processor == nil {
+		log.Printf("No time window processor active for seeking")
+		response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+			"type": "seek_error",
+			"error": "No time window active",
+		}))
+		client.send <- response
+		return
+	}
+	
+	log.Printf("⏰ Seeking to time: %s", seekTime.Format("15:04:05"))
+	
+	if err := processor.SeekToTime(seekTime); err != nil {
+		log.Printf("Failed to seek to time: %v", err)
+		response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+			"type": "seek_error",
+			"error": err.Error(),
+		}))
+		client.send <- response
+		return
+	}
+	
+	// Send success response
+	response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+		"type": "seek_complete",
+		"time": timeStr,
+	}))
+	client.send <- response
+	
+	log.Printf("🎯 Seek complete!")
+}
+
+// checkDumpcapRunning checks if dumpcap is already running
+func checkDumpcapRunning() bool {
+	return dumpcapProcessRunning()
+}
+
+// checkDumpcapInstalled checks if dumpcap is installed and available
+func checkDumpcapInstalled() bool {
+	_, ok := findDumpcap()
+	return ok
+}
+
+// launchDumpcapProcess starts dumpcap with the specified interface and output directory
+func launchDumpcapProcess(iface string, outputDir string) error {
+	dumpcapPath, ok := findDumpcap()
+	if !ok {
+		return fmt.Errorf("dumpcap not found in PATH - please install Wireshark/dumpcap")
+	}
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create dumpcap output directory: %v", err)
+	}
+
+	// Generate output filename with timestamp
+	timestamp := time.Now().Format("2006-01-02_15-04-05")
+	outputFile := filepath.Join(outputDir, fmt.Sprintf("dumpcap_%s_%s.pcap", fileSafeInterfaceName(iface), timestamp))
+
+	// Build dumpcap command
+	args := []string{
+		"-i", iface,
+		"-w", outputFile,
+		"-b", "duration:3600", // Rotate every hour
+		"-b", "filesize:1000000", // Rotate at 1GB
+	}
+
+	log.Printf("🚀 Launching dumpcap: %s %s", dumpcapPath, strings.Join(args, " "))
+	
+	cmd := exec.Command(dumpcapPath, args...)
+	
+	// Start dumpcap in background
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start dumpcap: %v", err)
+	}
+
+	log.Printf("✅ Dumpcap process started with PID %d", cmd.Process.Pid)
+	log.Printf("📁 Writing to: %s", outputFile)
+	
+	// Give dumpcap a moment to start writing
+	time.Sleep(2 * time.Second)
+	
+	return nil
+}
+
+// handleDumpcapSetup checks dumpcap status and optionally launches it
+func handleDumpcapSetup(iface string, outputDir string) error {
+	log.Printf("🔍 Checking dumpcap status...")
+	
+	// Check if dumpcap is installed
+	if !checkDumpcapInstalled() {
+		return fmt.Errorf("dumpcap not installed - please install Wireshark or dumpcap")
+	}
+	log.Printf("✅ Dumpcap is installed")
+	
+	// Check if dumpcap is already running
+	if checkDumpcapRunning() {
+		log.Printf("✅ Dumpcap process is already running")
+		
+		// Check if output directory has recent PCAP files
+		if hasRecentPcapFiles(outputDir) {
+			log.Printf("✅ Found recent PCAP files in %s", outputDir)
+			return nil
+		} else {
+			log.Printf("⚠️ Dumpcap is running but no recent PCAP files found")
+			log.Printf("💡 Check that dumpcap is writing to: %s", outputDir)
+		}
+	} else {
+		log.Printf("❌ Dumpcap is not running")
+		
+		if *launchDumpcap {
+			log.Printf("🚀 Auto-launching dumpcap...")
+			if err := launchDumpcapProcess(iface, outputDir); err != nil {
+				return fmt.Errorf("failed to auto-launch dumpcap: %v", err)
+			}
+		} else {
+			return fmt.Errorf("dumpcap is not running. Options:\n" +
+				"  1. Start dumpcap manually: dumpcap -i %s -w %s/capture.pcap\n" +
+				"  2. Use auto-launch: add -launch-dumpcap flag", iface, outputDir)
+		}
+	}
+	
+	return nil
+}
+
+// hasRecentPcapFiles checks if there are PCAP files modified in the last 5 minutes
+func hasRecentPcapFiles(dir string) bool {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pcap"))
+	if err != nil {
+		return false
+	}
+	
+	cutoff := time.Now().Add(-5 * time.Minute)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		
+		if info.ModTime().After(cutoff) {
+			return true
+		}
+	}
+	
+	return false
+}
+
+func main() {
+	flag.Parse()
+
+	// Show usage information if help is requested
+	if len(flag.Args()) > 0 && (flag.Args()[0] == "help" || flag.Args()[0] == "-help" || flag.Args()[0] == "--help") {
+		fmt.Println("VIBES Network Visualizer Backend")
+		fmt.Println("================================")
+		fmt.Println()
+		fmt.Println("Usage examples:")
+		fmt.Println("  Simulated mode:     go run main.go")
+		fmt.Println("  Real capture:       sudo go run main.go -iface eth0")
+		fmt.Println("  Capture to disk:    go run main.go -iface en1 -capture-dir /data/pcaps -storage /data/pcaps")
+		fmt.Println("  Drop root:          sudo ./vibes -iface en1 -capture-dir /data/pcaps -user vibes")
+		fmt.Println("  Dumpcap mode:       go run main.go -dumpcap -dumpcap-dir /data/pcaps -iface en1")
+		fmt.Println("  Auto-launch:        go run main.go -dumpcap -launch-dumpcap -iface en1")
+		fmt.Println("  PCAP replay:        go run main.go -pcap /path/to/file.pcap")
+		fmt.Println("  PCAP replay 2x:     go run main.go -pcap /path/to/file.pcap -speed 2.0")
+		fmt.Println("  Zeek conn JSON:     go run main.go -zeek-tcp :4777   # then ws://.../ws?zeek_tcp=1")
+		fmt.Println("  Relay server:       go run main.go -relay-accept -relay-token $TOKEN   # then ws://.../ws?relay=1")
+		fmt.Println("  Relay agent:        sudo ./vibes -iface eth0 -relay-to http://central:8080 -relay-token $TOKEN -sensor-id hall-b -addr 127.0.0.1:8081")
+		fmt.Println("  Scenario:           go run main.go -scenario booth-demo   # or -scenario my-network.yaml")
+		fmt.Println("  Mixed mode:         sudo go run main.go -iface eth0 -mix booth-demo   # or ws://.../ws?mix=booth-demo")
+		fmt.Println("  Lab injection:      sudo go run main.go -inject-iface lab0 -inject-scenario booth-demo -inject-pps 500")
+		fmt.Println("  Replay onto a NIC:  sudo go run main.go -replay-iface lab0 -replay-file drill.pcap -replay-mbps 100 -replay-loops 0")
+		fmt.Println("  Generated network:  go run main.go -topology subnets=20,hosts=200,server_ratio=0.05,external=1000,seed=42")
+		fmt.Println("  Custom port:        go run main.go -addr :9090")
+		fmt.Println("  Time windows:       go run main.go -storage /data/pcaps")
+		fmt.Println("  Config file:        go run main.go -config vibes.json   # subnet_groups, ...")
+		fmt.Println("  Session admin:      go run main.go -admin-token $TOKEN   # /api/sessions, /api/rooms")
+		fmt.Println("  IP reputation:      go run main.go -reputation abuseipdb -reputation-key $KEY -reputation-cache rep.json")
+		fmt.Println()
+		fmt.Println("URL Parameters (override command line):")
+		fmt.Println("  ws://localhost:8080/ws?pcap=/path/file.pcap&speed=2.0")
+		fmt.Println("  ws://localhost:8080/ws?interface=eth0")
+		fmt.Println("  ws://localhost:8080/ws?zeek_tcp=:4777")
+		fmt.Println("  ws://localhost:8080/ws?zeek_tcp=1   (uses -zeek-tcp address)")
+		fmt.Println("  ws://localhost:8080/ws?scenario=booth-demo   (simulated traffic from a scenario)")
+		fmt.Println("  ws://localhost:8080/ws?relay=1      (all relay sensors; ?relay=hall-b for one)")
+		fmt.Println("  ws://localhost:8080/ws?room=kiosk   (own pins, preset and time window; see \"rooms\" in -config)")
+		fmt.Println("  -anonymize-key $KEY with \"anonymize\": true on a room   (pseudonymized attendee addresses for public walls)")
+		fmt.Println()
+		fmt.Println("WebSocket Commands:")
+		fmt.Println("  Time Window: {\"type\":\"select_time_window\",\"start_time\":\"2023-01-01T10:00:00Z\",\"end_time\":\"2023-01-01T11:00:00Z\",\"speed\":2.0}")
+		fmt.Println("  Switch Live: {\"type\":\"switch_to_live\"}")
+		fmt.Println("  Seek Time:   {\"type\":\"seek_to_time\",\"time\":\"2023-01-01T10:30:00Z\"}")
+		fmt.Println("  Pin:         {\"type\":\"pinRule\",\"rule\":\"10.0.0.0/24\"}   (also unpinRule, clearAllPins)")
+		fmt.Println("  Preset:      {\"type\":\"apply_preset\",\"name\":\"CTF subnet\"}   (manage with /api/presets)")
+		fmt.Println("  Attack:      {\"type\":\"trigger_scenario\",\"name\":\"port_scan\"}   (simulated sessions; library at /api/attacks)")
+		fmt.Println("  Record:      {\"type\":\"start_recording\",\"filter\":\"tcp port 443\"}   {\"type\":\"stop_recording\"}")
+		fmt.Println("  Ring Dump:   {\"type\":\"dump_ring\",\"ip\":\"10.0.0.5\",\"seconds\":60}   (the last -ring of frames to -recordings)")
+		fmt.Println("  Malformed commands get {\"type\":\"error\",\"code\":...}; schema at /api/protocol, version with ws://.../ws?v=1")
+		fmt.Println()
+		fmt.Printf("Available flags:\n")
+		flag.PrintDefaults()
+		return
+	}
+
+	log.Printf("🔥 Starting VIBES Backend Server")
+
+	if *zeekTCPListen != "" {
+		if err := capture.EnsureZeekListener(*zeekTCPListen); err != nil {
+			log.Printf("⚠️ Zeek TCP listen (optional startup): %v — listener will start when a WebSocket connects in Zeek mode", err)
+		}
+	}
+	
+	if *edgeHotBps <= *edgeQuietBps {
+		log.Fatalf("❌ -edge-hot (%g) must be above -edge-quiet (%g)", *edgeHotBps, *edgeQuietBps)
+	}
+
+	if *captureDir != "" {
+		if *iface == "" {
+			log.Fatalf("❌ -capture-dir requires -iface")
+		}
+		if err := capture.StartArchiveCapture(capture.ArchiveCaptureConfig{
+			Interface:   *iface,
+			Dir:         *captureDir,
+			RotateEvery: *rotateEvery,
+			RotateBytes: *rotateSizeMB * 1024 * 1024,
+		}); err != nil {
+			log.Fatalf("❌ Native capture failed: %v", err)
+		}
+	}
+
+	// Log the current configuration
+	if *pcapFile != "" {
+		log.Printf("📼 PCAP Replay Mode: %s (speed: %.2fx)", *pcapFile, *replaySpeed)
+	} else if *captureDir != "" {
+		log.Printf("💾 Native Capture Mode: interface %s → %s", *iface, *captureDir)
+	} else if *useDumpcap {
+		log.Printf("🚀 Dumpcap Monitor Mode: %s (interface: %s)", *dumpcapDir, *iface)
+	} else if *iface != "" {
+		log.Printf("📡 Real Capture Mode: interface %s", *iface)
+	} else if *zeekTCPListen != "" {
+		log.Printf("🦅 Zeek TCP ingest default: %s (connect WebSocket with ?zeek_tcp=1 or ?zeek_tcp=%s)", *zeekTCPListen, *zeekTCPListen)
+	} else {
+		log.Printf("🎮 Simulation Mode: generating synthetic traffic")
+	}
+
+	if *scenarioName != "" || *topologySpec != "" {
+		sc, err := defaultScenario()
+		if err != nil {
+			log.Fatalf("❌ Scenario: %v", err)
+		}
+		log.Printf("🎬 Simulated sessions run scenario %s (%d nodes)", sc.Name, sc.NodeCount())
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	manager, err := NewClientManager(cfg)
+	if err != nil {
+		log.Fatalf("❌ Invalid config: %v", err)
+	}
+	notifier, err := notify.New(cfg.Webhooks, cfg.AlertPolicies)
+	if err != nil {
+		log.Fatalf("❌ Invalid config: %v", err)
+	}
+	manager.notifier.Store(notifier)
+	if notifier != nil {
+		log.Printf("🔔 Posting alerts to %d webhook(s)", len(cfg.Webhooks))
+	}
+	go manager.Start()
+	go manager.expirePins()
+	manager.archive.Start(*archiveScan)
+
+	if cfg.Retention != nil {
+		if err := manager.setupRetention(*cfg.Retention); err != nil {
+			log.Fatalf("❌ Invalid config: %v", err)
+		}
+	}
+	if *verifyArchives {
+		manager.setupIntegrity()
+	}
+
+	if *auditFile != "" {
+		if manager.auditLog, err = audit.Open(*auditFile); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("📝 Recording control actions in %s", *auditFile)
+	}
+	if *timelineFile != "" {
+		if manager.timeline, err = timeline.Open(*timelineFile); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("🗓️ Keeping the timeline in %s", *timelineFile)
+	}
+
+	if err := manager.checkExposure(); err != nil {
+		log.Fatalf("❌ Invalid config: %v", err)
+	}
+	if err := checkProfiles(manager.cfg.Profiles); err != nil {
+		log.Fatalf("❌ Invalid config: %v", err)
+	}
+
+	if err := manager.setupAnonymizer(); err != nil {
+		log.Fatalf("❌ Anonymization: %v", err)
+	}
+
+	if *reputationProvider != "" {
+		if err := manager.setupReputation(); err != nil {
+			log.Printf("⚠️ Reputation lookups disabled: %v", err)
+		}
+	}
+	// Windows already open apply from the start
+	manager.applySchedules(time.Now())
+	go manager.runSchedules()
+	go manager.watchConfig()
+
+	http.HandleFunc("/ws", manager.HandleWebSocket)
+	http.HandleFunc("/ws/playback", manager.handleStreamPlayback)
+	http.HandleFunc("/api/interfaces", handleInterfaces)
+	http.HandleFunc("/api/topology", manager.handleTopology)
+	http.HandleFunc("/api/routers", manager.handleRouters)
+	http.HandleFunc("/api/dhcp/servers", manager.handleDHCPServers)
+	http.HandleFunc("/api/health", manager.handleHealth)
+	http.HandleFunc("/api/metrics/tcp", manager.handleTCPMetrics)
+	http.HandleFunc("/api/groups", manager.handleGroups)
+	http.HandleFunc("/api/nat", manager.handleNAT)
+	http.HandleFunc("/api/dhcp", manager.handleDHCP)
+	http.HandleFunc("/api/arp", manager.handleARP)
+	http.HandleFunc("/api/devices", manager.handleDevices)
+	http.HandleFunc("/api/edges", manager.handleEdges)
+	http.HandleFunc("/api/assets", manager.handleAssets)
+	http.HandleFunc("/api/assets/", manager.handleAssets)
+	http.HandleFunc("/api/snapshot", manager.handleSnapshot)
+	http.HandleFunc("/api/recording", manager.handleRecording)
+	http.HandleFunc("/api/recording/", manager.handleRecording)
+	http.HandleFunc("/api/ring/dump", manager.handleRingDump)
+	http.HandleFunc("/api/export/pcap", manager.handleExportPCAP)
+	http.HandleFunc("/api/archive/coverage", manager.handleArchiveCoverage)
+	http.HandleFunc("/api/archive/heatmap", manager.handleArchiveHeatmap)
+	http.HandleFunc("/api/storage", manager.handleStorage)
+	http.HandleFunc("/api/protocol", handleProtocol)
+	http.HandleFunc("/api/pins", manager.handlePins)
+	http.HandleFunc("/api/presets", manager.handlePresets)
+	http.HandleFunc("/api/presets/", manager.handlePresets)
+	http.HandleFunc("/api/audit", manager.handleAudit)
+	http.HandleFunc("/api/timeline", manager.handleTimeline)
+	http.HandleFunc("/api/bookmarks", manager.handleBookmarks)
+	http.HandleFunc("/api/bookmarks/", manager.handleBookmarks)
+	http.HandleFunc("/api/config/reload", manager.handleConfigReload)
+	http.HandleFunc("/api/webhooks", manager.handleWebhooks)
+	http.HandleFunc("/api/alerts", manager.handleAlerts)
+	http.HandleFunc("/api/alerts/ack", manager.handleAlertAck)
+	http.HandleFunc("/api/schedules", manager.handleSchedules)
+	http.HandleFunc("/api/webhooks/", manager.handleWebhooks)
+	http.HandleFunc("/api/sessions", manager.handleSessions)
+	http.HandleFunc("/api/sessions/", manager.handleSessions)
+	http.HandleFunc("/api/rooms", manager.handleRooms)
+	http.HandleFunc("/api/rooms/", manager.handleRooms)
+	http.HandleFunc("/api/relay", manager.handleRelayIngest)
+	http.HandleFunc("/api/sensors", manager.handleSensors)
+	http.HandleFunc("/api/scenarios", manager.handleScenarios)
+	http.HandleFunc("/api/scenarios/", manager.handleScenarios)
+	http.HandleFunc("/api/attacks", manager.handleAttacks)
+	http.HandleFunc("/api/attacks/", manager.handleAttacks)
+	http.HandleFunc("/api/sensors/", manager.handleSensors)
+	http.HandleFunc("/api/inject", handleInject)
+	http.HandleFunc("/api/replay", handleWireReplay)
+	http.HandleFunc("/api/streams", manager.handleStreams)
+	http.HandleFunc("/api/streams/", manager.handleStreams)
+
+	frontend := web.Embedded()
+	if *webDir != "" {
+		frontend = os.DirFS(*webDir)
+	}
+	http.Handle("/", web.New(frontend))
+
+	if *relayAccept {
+		go manager.watchSensors(*sensorSilentAfter)
+	}
+	if cfg.SNMP != nil {
+		manager.pollSNMP(*cfg.SNMP)
+	}
+	if *relayTo != "" {
+		sensor := *sensorID
+		if sensor == "" {
+			sensor, _ = os.Hostname()
+		}
+		if err := runRelayAgent(*relayTo, sensor); err != nil {
+			log.Fatalf("❌ Relay agent: %v", err)
+		}
+	}
+
+	if *injectIface != "" {
+		if err := startInjection(); err != nil {
+			log.Fatalf("❌ Injection: %v", err)
+		}
+	}
+
+	if *replayIface != "" {
+		// -replay-speed has a default, so the other paces replace it rather than conflict with it
+		if *replayPPS > 0 || *replayMbps > 0 {
+			*replayWireSpeed = 0
+		}
+		if err := startWireReplay(); err != nil {
+			log.Fatalf("❌ Wire replay: %v", err)
+		}
+	}
+
+	for _, start := range auxServers {
+		if err := start(manager); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	// Bind before dropping privileges so ports below 1024 still work, unless systemd already did
+	listener, err := activationListener()
+	if err != nil {
+		log.Fatalf("❌ Socket activation: %v", err)
+	}
+	if listener == nil {
+		listener, err = net.Listen("tcp", *addr)
+		if err != nil {
+			log.Fatal("ListenAndServe: ", err)
+		}
+	}
+	if *runAsUser != "" {
+		if err := dropPrivileges(*runAsUser, []string{*captureDir, *recordingsDir, *streamsDir}); err != nil {
+			log.Fatalf("❌ Privilege drop failed: %v", err)
+		}
+		if *captureDir == "" && (*iface != "" || *useDumpcap) {
+			log.Printf("⚠️ Per-session live capture opens its handle when a browser connects, which needs CAP_NET_RAW after the privilege drop; use -capture-dir to open it at startup")
+		}
+	} else {
+		logCaptureCapabilityHint()
+	}
+
+	log.Printf("Starting server on %s", listener.Addr())
+	if *systemdNotify {
+		notifySystemdReady(listener.Addr().String())
+	}
+	if err := http.Serve(listener, nil); err != nil {
+		log.Fatal("ListenAndServe: ", err)
+	}
+}
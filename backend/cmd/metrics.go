@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// handleTCPMetrics returns the latest tcp_stats snapshot for every connected session
+func (manager *ClientManager) handleTCPMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	type sessionMetrics struct {
+		Client  string              `json:"client"`
+		Metrics *capture.TCPMetrics `json:"metrics"`
+	}
+
+	manager.clientsMutex.RLock()
+	sessions := make([]sessionMetrics, 0, len(manager.clients))
+	for client := range manager.clients {
+		sessions = append(sessions, sessionMetrics{
+			Client:  client.conn.RemoteAddr().String(),
+			Metrics: client.tcpMetrics.Load(),
+		})
+	}
+	manager.clientsMutex.RUnlock()
+
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// selectClient finds the session with the given remote address, or the longest-connected
+// session when addr is empty. Returns nil if there is no match.
+func (manager *ClientManager) selectClient(addr string) *Client {
+	manager.clientsMutex.RLock()
+	defer manager.clientsMutex.RUnlock()
+
+	var selected *Client
+	for client := range manager.clients {
+		if addr != "" {
+			if client.conn.RemoteAddr().String() == addr {
+				return client
+			}
+			continue
+		}
+		if selected == nil || client.connectedAt.Before(selected.connectedAt) {
+			selected = client
+		}
+	}
+	return selected
+}
@@ -0,0 +1,233 @@
+//go:build mqtt
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var (
+	mqttBroker   = flag.String("mqtt", "", "publish room stats and top talkers to this MQTT broker, e.g. tcp://localhost:1883")
+	mqttTopic    = flag.String("mqtt-topic", "vibes", "topic prefix for -mqtt; messages go to <prefix>/<room>/stats and <prefix>/<room>/top_talkers")
+	mqttInterval = flag.Duration("mqtt-interval", 5*time.Second, "how often -mqtt publishes")
+	mqttClientID = flag.String("mqtt-client-id", "", "MQTT client ID (default vibes-<hostname>)")
+	mqttUsername = flag.String("mqtt-username", "", "MQTT username")
+	mqttPassword = flag.String("mqtt-password", "", "MQTT password (defaults to $VIBES_MQTT_PASSWORD)")
+	mqttTop      = flag.Int("mqtt-top", 10, "hosts listed in each top_talkers message")
+)
+
+// maxMQTTHosts bounds the hosts tallied per session between two publishes
+const maxMQTTHosts = 50000
+
+func init() {
+	auxServers = append(auxServers, startMQTTPublisher)
+}
+
+// mqttTally is one session's traffic since the previous publish
+type mqttTally struct {
+	packets   int64
+	bytes     int64
+	protocols map[string]int64
+	hosts     map[string]*mqttTalker
+}
+
+// mqttTalker is a host's traffic, sent or received, in a top_talkers message
+type mqttTalker struct {
+	IP      string `json:"ip"`
+	Packets int64  `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// mqttStats is the payload of <prefix>/<room>/stats
+type mqttStats struct {
+	Timestamp     int64            `json:"timestamp"`
+	IntervalMs    int64            `json:"interval_ms"`
+	Packets       int64            `json:"packets"`
+	Bytes         int64            `json:"bytes"`
+	PacketsPerSec float64          `json:"packets_per_sec"`
+	BitsPerSec    float64          `json:"bits_per_sec"`
+	Hosts         int              `json:"hosts"`
+	Protocols     map[string]int64 `json:"protocols"` // packets by protocol
+}
+
+// mqttTopTalkers is the payload of <prefix>/<room>/top_talkers
+type mqttTopTalkers struct {
+	Timestamp  int64         `json:"timestamp"`
+	IntervalMs int64         `json:"interval_ms"`
+	Talkers    []*mqttTalker `json:"talkers"` // busiest first, by bytes
+}
+
+// mqttPublisher tallies the packet feed per session and publishes each room's traffic as small
+// retained JSON messages, for LED boards and signage that can't hold a WebSocket open
+type mqttPublisher struct {
+	manager  *ClientManager
+	client   mqtt.Client
+	sessions map[string]*mqttTally
+	last     time.Time
+}
+
+func startMQTTPublisher(manager *ClientManager) error {
+	if *mqttBroker == "" {
+		return nil
+	}
+	if *mqttInterval <= 0 {
+		return fmt.Errorf("-mqtt-interval must be positive")
+	}
+	clientID := *mqttClientID
+	if clientID == "" {
+		hostname, _ := os.Hostname()
+		clientID = "vibes-" + hostname
+	}
+	password := *mqttPassword
+	if password == "" {
+		password = os.Getenv("VIBES_MQTT_PASSWORD")
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(*mqttBroker).
+		SetClientID(clientID).
+		SetUsername(*mqttUsername).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true) // keep trying in the background if the broker isn't up yet
+	opts.SetOnConnectHandler(func(mqtt.Client) {
+		log.Printf("📟 Connected to MQTT broker %s", *mqttBroker)
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Printf("⚠️ MQTT connection lost: %v", err)
+	})
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return fmt.Errorf("MQTT broker %s: %v", *mqttBroker, token.Error())
+	}
+
+	p := &mqttPublisher{
+		manager:  manager,
+		client:   client,
+		sessions: make(map[string]*mqttTally),
+		last:     time.Now(),
+	}
+	go p.run()
+	log.Printf("📟 Publishing room stats to %s under %s/ every %s", *mqttBroker, *mqttTopic, *mqttInterval)
+	return nil
+}
+
+func (p *mqttPublisher) run() {
+	sub := p.manager.feed.subscribe(feedFilter{}, true, false)
+	defer p.manager.feed.unsubscribe(sub)
+	ticker := time.NewTicker(*mqttInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case fp := <-sub.Packets:
+			p.observe(fp)
+		case <-ticker.C:
+			p.publish()
+		}
+	}
+}
+
+func (p *mqttPublisher) observe(fp feedPacket) {
+	tally, ok := p.sessions[fp.Session]
+	if !ok {
+		tally = &mqttTally{protocols: make(map[string]int64), hosts: make(map[string]*mqttTalker)}
+		p.sessions[fp.Session] = tally
+	}
+	packet := fp.Packet
+	tally.packets++
+	tally.bytes += int64(packet.Size)
+	tally.protocols[packet.Protocol]++
+	for _, ip := range [2]string{packet.Src, packet.Dst} {
+		host, ok := tally.hosts[ip]
+		if !ok {
+			if len(tally.hosts) >= maxMQTTHosts {
+				continue
+			}
+			host = &mqttTalker{IP: ip}
+			tally.hosts[ip] = host
+		}
+		host.Packets++
+		host.Bytes += int64(packet.Size)
+	}
+}
+
+// publish sends every room's stats and starts the next interval. Each session counts its own
+// capture, so a room is reported from one session (the one connected longest) rather than
+// adding up the same traffic once per browser.
+func (p *mqttPublisher) publish() {
+	now := time.Now()
+	interval := now.Sub(p.last)
+	p.last = now
+	defer func() { p.sessions = make(map[string]*mqttTally) }()
+	if len(p.sessions) == 0 {
+		return
+	}
+
+	type source struct {
+		tally       *mqttTally
+		connectedAt time.Time
+	}
+	rooms := make(map[string]source)
+	p.manager.clientsMutex.RLock()
+	for client := range p.manager.clients {
+		tally, ok := p.sessions[client.conn.RemoteAddr().String()]
+		if !ok {
+			continue
+		}
+		if current, ok := rooms[client.room.name]; !ok || client.connectedAt.Before(current.connectedAt) {
+			rooms[client.room.name] = source{tally, client.connectedAt}
+		}
+	}
+	p.manager.clientsMutex.RUnlock()
+
+	for room, src := range rooms {
+		tally := src.tally
+		stats := mqttStats{
+			Timestamp:     now.UnixMilli(),
+			IntervalMs:    interval.Milliseconds(),
+			Packets:       tally.packets,
+			Bytes:         tally.bytes,
+			PacketsPerSec: float64(tally.packets) / interval.Seconds(),
+			BitsPerSec:    float64(tally.bytes*8) / interval.Seconds(),
+			Hosts:         len(tally.hosts),
+			Protocols:     tally.protocols,
+		}
+		top := mqttTopTalkers{Timestamp: now.UnixMilli(), IntervalMs: interval.Milliseconds(), Talkers: make([]*mqttTalker, 0, len(tally.hosts))}
+		for _, host := range tally.hosts {
+			top.Talkers = append(top.Talkers, host)
+		}
+		sort.Slice(top.Talkers, func(i, j int) bool {
+			if top.Talkers[i].Bytes != top.Talkers[j].Bytes {
+				return top.Talkers[i].Bytes > top.Talkers[j].Bytes
+			}
+			return top.Talkers[i].IP < top.Talkers[j].IP
+		})
+		if len(top.Talkers) > *mqttTop {
+			top.Talkers = top.Talkers[:*mqttTop]
+		}
+		p.send(*mqttTopic+"/"+room+"/stats", stats)
+		p.send(*mqttTopic+"/"+room+"/top_talkers", top)
+	}
+}
+
+// send publishes a retained QoS 0 message, so a display that (re)connects gets the latest
+// values at once; it doesn't wait for the broker
+func (p *mqttPublisher) send(topic string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if !p.client.IsConnected() {
+		return
+	}
+	p.client.Publish(topic, 0, true, data)
+}
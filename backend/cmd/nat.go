@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// newNATTable builds the NAT table of the config file's nat section and starts following its
+// translation source; nil without a nat section
+func newNATTable(cfg *config.NAT) (*enrich.NATTable, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	table, err := enrich.NewNATTable(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Source != "" {
+		log.Printf("🔀 NAT awareness: following %s translations in %s for pool %v", cfg.Source, cfg.File, table.Pool())
+		go table.Follow(cfg.File, enrich.NATParsers[cfg.Source], make(chan struct{}))
+	} else {
+		log.Printf("🔀 NAT awareness: pool %v, mappings from POST /api/nat", table.Pool())
+	}
+	return table, nil
+}
+
+// translateNAT shows a packet to or from the NAT pool with the internal host behind the gateway,
+// keeping the public address in src_nat/dst_nat. Packets without a known mapping are unchanged.
+func (manager *ClientManager) translateNAT(packet *capture.Packet) *capture.Packet {
+	if manager.nat == nil {
+		return packet
+	}
+	src, srcPort, srcOK := manager.nat.Translate(packet.Protocol, packet.Src, packet.SrcPort)
+	dst, dstPort, dstOK := manager.nat.Translate(packet.Protocol, packet.Dst, packet.DstPort)
+	if !srcOK && !dstOK {
+		return packet
+	}
+	translated := *packet
+	if srcOK {
+		translated.SrcNAT = packet.Src
+		translated.Src, translated.SrcPort = src, srcPort
+	}
+	if dstOK {
+		translated.DstNAT = packet.Dst
+		translated.Dst, translated.DstPort = dst, dstPort
+	}
+	return &translated
+}
+
+// handleNAT lists and feeds the NAT table:
+//
+//	GET  /api/nat   the pool and the live mappings
+//	POST /api/nat   [{"protocol":"tcp","public":...,"public_port":...,"internal":...,"internal_port":...,"teardown":false}]
+func (manager *ClientManager) handleNAT(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if manager.nat == nil {
+		http.Error(w, "NAT awareness is off: the config file has no nat section", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pool":     manager.nat.Pool(),
+			"mappings": manager.nat.Mappings(),
+		})
+
+	case http.MethodPost:
+		var updates []struct {
+			enrich.NATMapping
+			Teardown bool `json:"teardown"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		learned, removed, ignored := 0, 0, 0
+		for _, update := range updates {
+			switch {
+			case update.Teardown:
+				manager.nat.Forget(update.Protocol, update.Public, update.PublicPort)
+				removed++
+			case manager.nat.Learn(update.NATMapping):
+				learned++
+			default:
+				ignored++
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]int{"learned": learned, "removed": removed, "ignored": ignored})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/enrich"
+)
+
+// maxNodeInfoSent bounds the per-client record of IPs already described via node_info
+const maxNodeInfoSent = 50000
+
+// setupReputation creates the reputation cache from flags and broadcasts scores as they arrive
+func (manager *ClientManager) setupReputation() error {
+	apiKey := *reputationKey
+	if apiKey == "" {
+		apiKey = os.Getenv("VIBES_REPUTATION_KEY")
+	}
+	if apiKey == "" {
+		return fmt.Errorf("-reputation %s requires -reputation-key or $VIBES_REPUTATION_KEY", *reputationProvider)
+	}
+
+	cache, err := enrich.NewReputationCache(enrich.ReputationConfig{
+		Provider:      *reputationProvider,
+		APIKey:        apiKey,
+		TTL:           *reputationTTL,
+		LookupsPerMin: *reputationRate,
+		CacheFile:     *reputationCache,
+	})
+	if err != nil {
+		return err
+	}
+	cache.OnUpdate = func(rep *enrich.Reputation) {
+		manager.broadcastNodeInfo(rep.IP)
+	}
+	cache.Start()
+	manager.reputation = cache
+	return nil
+}
+
+// sendNodeInfo emits node_info (asset label, DHCP lease, reputation) for a packet's endpoints the first time this
+// client sees them. Unknown external IPs are queued for lookup and broadcast to everyone once scored.
+func (manager *ClientManager) sendNodeInfo(client *Client, packet *capture.Packet) {
+	for _, ip := range [2]string{packet.Src, packet.Dst} {
+		if _, sent := client.nodeInfoSent[ip]; sent {
+			continue
+		}
+		// A pseudonym may collide with a real address; its labels aren't this node's
+		if manager.masks(client, ip) {
+			continue
+		}
+		msg, ok := manager.nodeInfoMessage(ip, true, client.room.exposure.Hostnames)
+		if !ok {
+			continue
+		}
+
+		if len(client.nodeInfoSent) >= maxNodeInfoSent {
+			client.nodeInfoSent = make(map[string]struct{})
+		}
+		client.nodeInfoSent[ip] = struct{}{}
+
+		client.enqueue(msg)
+	}
+}
+
+// broadcastNodeInfo pushes the current node_info for an IP to every client, e.g. after an asset edit.
+// Rooms that hide hostnames get the reputation alone, and anonymized rooms never learn what
+// belongs to a real attendee address.
+func (manager *ClientManager) broadcastNodeInfo(ip string) {
+	full, _ := manager.nodeInfoMessage(ip, false, true)
+	unnamed, hasUnnamed := manager.nodeInfoMessage(ip, false, false)
+
+	manager.clientsMutex.RLock()
+	defer manager.clientsMutex.RUnlock()
+	for client := range manager.clients {
+		msg := full
+		switch {
+		case manager.masks(client, ip):
+			continue
+		case !client.room.exposure.Hostnames:
+			if !hasUnnamed {
+				continue
+			}
+			msg = unnamed
+		}
+		client.enqueue(msg)
+	}
+}
+
+// nodeInfoMessage builds the node_info side-channel message for an IP; ok is false when there is
+// nothing to say. observe queues a reputation lookup for unknown external IPs; without it (broadcast
+// after an edit) the message is always built, with "asset": null telling clients to clear a label.
+// Without hostnames the asset and the DHCP lease are left out altogether.
+func (manager *ClientManager) nodeInfoMessage(ip string, observe, hostnames bool) ([]byte, bool) {
+	info := map[string]interface{}{
+		"type": "node_info",
+		"ip":   ip,
+	}
+	found := false
+
+	// Synthetic group nodes stand for protocols, not hosts: they have a label and nothing else
+	if capture.IsGroupNode(ip) {
+		info["label"] = capture.GroupNodeLabel(ip)
+		info["group_node"] = true
+		msg, _ := json.Marshal(info)
+		return msg, true
+	}
+
+	// Without hostnames not even a null asset is sent: it would tell the client a label exists
+	if hostnames {
+		if asset, ok := manager.assets.Get(ip); ok {
+			info["label"] = asset.Label
+			info["asset"] = asset
+			found = true
+		} else if !observe {
+			info["asset"] = nil
+			found = true
+		}
+		// A lease names the device behind the address; an asset label still comes first
+		if manager.leases != nil {
+			if lease, ok := manager.leases.Get(ip); ok {
+				if _, labeled := info["label"]; !labeled && lease.Hostname != "" {
+					info["label"] = lease.Hostname
+				}
+				info["lease"] = lease
+				found = true
+			} else if !observe {
+				info["lease"] = nil
+			}
+		}
+	}
+	// Health checks are of the venue's own servers, named for their service, not for anyone
+	if manager.health != nil {
+		if checks := manager.health.ForHost(ip); len(checks) > 0 {
+			info["health"] = checks
+			found = true
+		}
+	}
+	// A DHCP server heard answering, with whether dhcp_guard allows it, so a rogue one stands out
+	if server, ok := manager.dhcpGuard.Server(ip); ok {
+		info["dhcp_server"] = server
+		found = true
+	}
+	if manager.reputation != nil {
+		var rep *enrich.Reputation
+		var ok bool
+		if observe {
+			rep, ok = manager.reputation.Observe(ip)
+		} else {
+			rep, ok = manager.reputation.Get(ip)
+		}
+		if ok {
+			info["reputation"] = rep
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, false
+	}
+	msg, _ := json.Marshal(info)
+	return msg, true
+}
@@ -0,0 +1,197 @@
+//go:build otel
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	otelEndpoint = flag.String("otel-endpoint", "", "export OpenTelemetry traces and metrics over OTLP/HTTP to this host:port, e.g. localhost:4318 (default: $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	otelInsecure = flag.Bool("otel-insecure", true, "send OTLP over plain HTTP (-otel-endpoint)")
+	otelSample   = flag.Float64("otel-sample", 0.001, "fraction of packets traced as spans; metrics cover every packet")
+)
+
+// Stage durations are microseconds to milliseconds; the default buckets start at 5 ms
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+func init() {
+	auxServers = append(auxServers, startTracing)
+}
+
+// otelPipeline reports forwarder timings as OpenTelemetry metrics, and a sample of packets as
+// traces with one span per stage
+type otelPipeline struct {
+	tracer trace.Tracer
+	sample float64
+
+	stageDuration  metric.Float64Histogram
+	captureLatency metric.Float64Histogram
+	backlog        metric.Int64Histogram
+	packets        metric.Int64Counter
+	drops          metric.Int64Counter
+
+	options sync.Map // attribute sets by room, kind and value, built once
+}
+
+func startTracing(manager *ClientManager) error {
+	if *otelEndpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return nil
+	}
+	ctx := context.Background()
+	var traceOpts []otlptracehttp.Option
+	var metricOpts []otlpmetrichttp.Option
+	if *otelEndpoint != "" {
+		traceOpts = append(traceOpts, otlptracehttp.WithEndpoint(*otelEndpoint))
+		metricOpts = append(metricOpts, otlpmetrichttp.WithEndpoint(*otelEndpoint))
+	}
+	if *otelInsecure {
+		traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+		metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+	}
+	traceExporter, err := otlptracehttp.New(ctx, traceOpts...)
+	if err != nil {
+		return fmt.Errorf("OTLP trace exporter: %v", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx, metricOpts...)
+	if err != nil {
+		return fmt.Errorf("OTLP metric exporter: %v", err)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", "vibes"))
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+
+	p := &otelPipeline{
+		tracer: tracerProvider.Tracer("vibes-network-visualizer/pipeline"),
+		sample: *otelSample,
+	}
+	meter := meterProvider.Meter("vibes-network-visualizer/pipeline")
+	if p.stageDuration, err = meter.Float64Histogram("vibes.pipeline.stage.duration", metric.WithUnit("ms"),
+		metric.WithDescription("Time a packet spends in each forwarder stage"), metric.WithExplicitBucketBoundaries(latencyBuckets...)); err != nil {
+		return err
+	}
+	if p.captureLatency, err = meter.Float64Histogram("vibes.pipeline.capture.latency", metric.WithUnit("ms"),
+		metric.WithDescription("Time from capture to a forwarder taking the packet (live capture only)"), metric.WithExplicitBucketBoundaries(latencyBuckets...)); err != nil {
+		return err
+	}
+	if p.backlog, err = meter.Int64Histogram("vibes.pipeline.capture.backlog", metric.WithUnit("{packet}"),
+		metric.WithDescription("Packets waiting in the capture channel when a forwarder takes one")); err != nil {
+		return err
+	}
+	if p.packets, err = meter.Int64Counter("vibes.pipeline.packets", metric.WithUnit("{packet}"),
+		metric.WithDescription("Packets taken by forwarders")); err != nil {
+		return err
+	}
+	if p.drops, err = meter.Int64Counter("vibes.pipeline.drops", metric.WithUnit("{packet}"),
+		metric.WithDescription("Packets a forwarder did not stream, by reason")); err != nil {
+		return err
+	}
+	if _, err = meter.Int64ObservableGauge("vibes.websocket.sessions", metric.WithUnit("{session}"),
+		metric.WithDescription("Connected WebSocket sessions"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			manager.clientsMutex.RLock()
+			defer manager.clientsMutex.RUnlock()
+			o.Observe(int64(len(manager.clients)))
+			return nil
+		})); err != nil {
+		return err
+	}
+
+	pipeline = p
+	endpoint := *otelEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	log.Printf("🔭 OpenTelemetry pipeline tracing to %s (%g of packets traced)", endpoint, p.sample)
+	return nil
+}
+
+// option returns the measurement attributes for a room and, unless kind is empty, one more attribute
+func (p *otelPipeline) option(room, kind, value string) metric.MeasurementOption {
+	key := room + "\x00" + kind + "\x00" + value
+	if option, ok := p.options.Load(key); ok {
+		return option.(metric.MeasurementOption)
+	}
+	attrs := []attribute.KeyValue{attribute.String("room", room)}
+	if kind != "" {
+		attrs = append(attrs, attribute.String(kind, value))
+	}
+	option, _ := p.options.LoadOrStore(key, metric.WithAttributeSet(attribute.NewSet(attrs...)))
+	return option.(metric.MeasurementOption)
+}
+
+// Packet records one packet's pass through a forwarder
+func (p *otelPipeline) Packet(t *packetTrace) {
+	ctx := context.Background()
+	roomOption := p.option(t.Room, "", "")
+	p.packets.Add(ctx, 1, roomOption)
+	p.backlog.Record(ctx, int64(t.Backlog), roomOption)
+	previous := t.Start
+	for _, stage := range t.Stages {
+		p.stageDuration.Record(ctx, milliseconds(stage.End.Sub(previous)), p.option(t.Room, "stage", stage.Name))
+		previous = stage.End
+	}
+	if t.Dropped != "" {
+		p.drops.Add(ctx, 1, p.option(t.Room, "reason", t.Dropped))
+	}
+
+	// Replayed and simulated packets carry file or synthetic times, not when they were captured
+	live := t.Packet.Source == "real"
+	captured := time.UnixMilli(t.Packet.Timestamp)
+	if live {
+		p.captureLatency.Record(ctx, milliseconds(t.Start.Sub(captured)), roomOption)
+	}
+
+	if rand.Float64() >= p.sample {
+		return
+	}
+	start := t.Start
+	if live && captured.Before(start) {
+		start = captured
+	}
+	ctx, span := p.tracer.Start(ctx, "packet", trace.WithTimestamp(start), trace.WithAttributes(
+		attribute.String("room", t.Room),
+		attribute.String("packet.source", t.Packet.Source),
+		attribute.String("packet.protocol", t.Packet.Protocol),
+		attribute.Int("packet.size", t.Packet.Size),
+		attribute.Int("capture.backlog", t.Backlog),
+	))
+	if live {
+		_, capture := p.tracer.Start(ctx, "capture", trace.WithTimestamp(captured))
+		capture.End(trace.WithTimestamp(t.Start))
+	}
+	previous = t.Start
+	for _, stage := range t.Stages {
+		_, child := p.tracer.Start(ctx, stage.Name, trace.WithTimestamp(previous))
+		child.End(trace.WithTimestamp(stage.End))
+		previous = stage.End
+	}
+	if t.Dropped != "" {
+		span.SetAttributes(attribute.String("dropped", t.Dropped))
+	}
+	span.End(trace.WithTimestamp(t.End))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"vibes-network-visualizer/internal/audit"
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/pins"
+)
+
+// pinExpiryInterval is how often temporary pins are checked for expiry
+const pinExpiryInterval = 5 * time.Second
+
+// pinExpiredMessage tells a room that a temporary pin lapsed, so screens drop its highlight
+type pinExpiredMessage struct {
+	Type    string    `json:"type"` // always "pin_expired"
+	Room    string    `json:"room"`
+	Rule    string    `json:"rule"`
+	Expired time.Time `json:"expired"`
+}
+
+// ToJSON converts a pin expiry to JSON
+func (m *pinExpiredMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// expirePins removes temporary pins once their time is up, telling the room's sessions and
+// recording it in the audit log, so a days-long event doesn't pile up stale watches
+func (manager *ClientManager) expirePins() {
+	ticker := time.NewTicker(pinExpiryInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		manager.roomsMutex.Lock()
+		rooms := make([]*Room, 0, len(manager.rooms))
+		for _, room := range manager.rooms {
+			rooms = append(rooms, room)
+		}
+		manager.roomsMutex.Unlock()
+
+		for _, room := range rooms {
+			expired := room.pins.Expire(now)
+			for _, pin := range expired {
+				log.Printf("⏲️ Room %s: pinning rule %s expired", room.name, pin)
+				manager.sendToRoom(room, &pinExpiredMessage{Type: "pin_expired", Room: room.name, Rule: pin.String(), Expired: pin.Expires()})
+				manager.record(audit.Entry{
+					Actor:  "server",
+					Via:    "expiry",
+					Action: "pin_expired",
+					Room:   room.name,
+					Params: map[string]interface{}{"rule": pin.String()},
+				})
+			}
+			// Also catches sessions and profile switches that brought new captures along
+			manager.pushWatchFilter(room)
+		}
+	}
+}
+
+// roomPins is one room's pinning rules with how much traffic each has matched
+type roomPins struct {
+	Room  string          `json:"room"`
+	Rules []pins.RuleHits `json:"rules"`
+}
+
+// handlePins lists every room's pinning rules with their match counts, so a rule that no longer
+// matches anything can be spotted before it is cleared:
+//
+//	GET /api/pins             every room
+//	GET /api/pins?room=noc    one room
+func (manager *ClientManager) handlePins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("room")
+	manager.roomsMutex.Lock()
+	rooms := make([]*Room, 0, len(manager.rooms))
+	for _, room := range manager.rooms {
+		if name == "" || room.name == name {
+			rooms = append(rooms, room)
+		}
+	}
+	manager.roomsMutex.Unlock()
+	if name != "" && len(rooms) == 0 {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].name < rooms[j].name })
+
+	list := make([]roomPins, 0, len(rooms))
+	for _, room := range rooms {
+		list = append(list, roomPins{Room: room.name, Rules: room.pins.Hits()})
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// compileWatchFilter compiles what a room watches, its pins or else whatever its preset filter
+// passes, into one BPF expression. It is "" when the room watches everything or a rule can't be
+// expressed, such as a hostname pin.
+func compileWatchFilter(room *Room) string {
+	pinned, ok := room.pins.BPF()
+	if !ok {
+		return ""
+	}
+	var filter *pins.View
+	if view := room.view.Load(); view != nil {
+		filter = view.Filter
+	}
+	viewed, ok := filter.BPF()
+	if !ok || (viewed == "" && filter != nil) {
+		return ""
+	}
+	switch {
+	case pinned == "":
+		return viewed
+	case viewed == "":
+		return pinned
+	}
+	return "(" + pinned + ") or (" + viewed + ")"
+}
+
+// pushWatchFilter recompiles a room's watchlist and narrows its sessions' live captures to it
+func (manager *ClientManager) pushWatchFilter(room *Room) {
+	if !room.watchBPF {
+		return
+	}
+	expr := compileWatchFilter(room)
+	if previous := room.watchFilter.Swap(&expr); previous == nil || *previous != expr {
+		if expr == "" {
+			log.Printf("🎯 Room %s captures everything: its watchlist is empty or can't be expressed in BPF", room.name)
+		} else {
+			log.Printf("🎯 Room %s captures only its watchlist: %s", room.name, expr)
+		}
+	}
+	for _, client := range manager.roomClients(room) {
+		manager.narrowCapture(room, client.captureSource())
+	}
+}
+
+// narrowCapture applies a room's compiled watchlist to a live capture, or to the live capture
+// under a simulation overlay; other sources are left alone
+func (manager *ClientManager) narrowCapture(room *Room, source capture.PacketCapture) {
+	if !room.watchBPF {
+		return
+	}
+	if mixed, ok := source.(*capture.MixedCapture); ok {
+		source = mixed.Base()
+	}
+	live, ok := source.(*capture.RealCapture)
+	if !ok {
+		return
+	}
+	expr := room.watchFilter.Load()
+	if expr == nil {
+		compiled := compileWatchFilter(room)
+		expr = &compiled
+	}
+	if err := live.SetWatchFilter(*expr); err != nil {
+		log.Printf("⚠️ Room %s: %v", room.name, err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/pins"
+)
+
+// defaultSampleRate is the fraction of unpinned packets forwarded to a browser
+const defaultSampleRate = 0.9
+
+// clientView is what a room currently displays; nil means the defaults
+type clientView struct {
+	Preset     string
+	Filter     *pins.View
+	FilterSpec *pins.ViewFilter // as written in the preset, for reporting
+	SampleRate float64
+}
+
+func (v *clientView) matches(packet *capture.Packet) bool {
+	if v == nil {
+		return true
+	}
+	return v.Filter.Matches(packet.Src, packet.Dst, packet.SrcPort, packet.DstPort, packet.Protocol)
+}
+
+func (v *clientView) sampleRate() float64 {
+	if v == nil || v.SampleRate <= 0 {
+		return defaultSampleRate
+	}
+	return v.SampleRate
+}
+
+// presetMessage confirms that a preset was applied to a room
+type presetMessage struct {
+	Type       string           `json:"type"` // always "preset_applied"
+	Room       string           `json:"room"`
+	Preset     string           `json:"preset"`
+	Pins       []string         `json:"pins"`
+	Filter     *pins.ViewFilter `json:"filter,omitempty"`
+	SampleRate float64          `json:"sample_rate"`
+	ID         interface{}      `json:"id,omitempty"`
+}
+
+// ToJSON converts a preset message to JSON
+func (m *presetMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// applyPresetToRoom replaces the room's pinning rules and sets its filter and sampling rate
+func (manager *ClientManager) applyPresetToRoom(room *Room, name string) (*presetMessage, error) {
+	preset, ok := manager.presets.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("no preset named %q", name)
+	}
+	filter, err := pins.CompileView(preset.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := room.pins.Replace(preset.Pins); err != nil {
+		return nil, err
+	}
+
+	view := &clientView{Preset: preset.Name, Filter: filter, FilterSpec: preset.Filter}
+	if preset.SampleRate != nil {
+		view.SampleRate = *preset.SampleRate
+	}
+	room.view.Store(view)
+	log.Printf("📌 Applied preset %q to room %s (%d pins)", preset.Name, room.name, len(preset.Pins))
+	manager.pushWatchFilter(room)
+
+	return &presetMessage{
+		Type:       "preset_applied",
+		Room:       room.name,
+		Preset:     preset.Name,
+		Pins:       room.pins.Rules(),
+		Filter:     preset.Filter,
+		SampleRate: view.sampleRate(),
+	}, nil
+}
+
+// handleApplyPresetCommand serves the apply_preset WebSocket command; everyone in the room is told
+func (manager *ClientManager) handleApplyPresetCommand(msg map[string]interface{}, client *Client) {
+	reply, err := manager.applyPresetToRoom(client.room, msg["name"].(string))
+	if err != nil {
+		protoErr := newProtocolError(errCodeInvalidField, "apply_preset", "name", "%v", err)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	for _, member := range manager.roomClients(client.room) {
+		if member != client {
+			member.trySend(reply)
+		}
+	}
+	withID := *reply
+	withID.ID = requestID(msg)
+	client.trySend(&withID)
+}
+
+// handlePresets serves CRUD for named presets:
+//
+//	GET    /api/presets               list all presets
+//	POST   /api/presets               create or replace a preset (JSON body)
+//	GET    /api/presets/{name}        fetch one preset
+//	PUT    /api/presets/{name}        create or replace the preset called name
+//	DELETE /api/presets/{name}        remove a preset
+//	POST   /api/presets/{name}/apply  apply to a room (?room=, default "default")
+func (manager *ClientManager) handlePresets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/presets"), "/")
+	apply := false
+	if trimmed := strings.TrimSuffix(name, "/apply"); trimmed != name {
+		name, apply = trimmed, true
+	}
+
+	switch {
+	case apply && r.Method == http.MethodPost:
+		room, err := manager.room(r.URL.Query().Get("room"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply, err := manager.applyPresetToRoom(room, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		manager.auditRequest(r, "preset_apply", room.name, map[string]interface{}{"name": name})
+		manager.sendToRoom(room, reply)
+		json.NewEncoder(w).Encode(reply)
+
+	case name == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(manager.presets.List())
+
+	case name != "" && r.Method == http.MethodGet:
+		preset, ok := manager.presets.Get(name)
+		if !ok {
+			http.Error(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(preset)
+
+	case (name == "" && r.Method == http.MethodPost) || (name != "" && r.Method == http.MethodPut):
+		var preset pins.Preset
+		if err := json.NewDecoder(r.Body).Decode(&preset); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if name != "" {
+			preset.Name = name
+		}
+		if err := manager.presets.Put(&preset); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("📌 Preset %q saved (%d pins)", preset.Name, len(preset.Pins))
+		manager.auditRequest(r, "preset_save", "", map[string]interface{}{"name": preset.Name, "preset": &preset})
+		json.NewEncoder(w).Encode(&preset)
+
+	case name != "" && r.Method == http.MethodDelete:
+		found, err := manager.presets.Delete(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("📌 Preset %q removed", name)
+		manager.auditRequest(r, "preset_delete", "", map[string]interface{}{"name": name})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,88 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// dropPrivileges switches the process to username (and its primary group) once capture handles
+// and the listen socket are open. Directories the server keeps writing to are handed over first.
+func dropPrivileges(username string, writableDirs []string) error {
+	if os.Geteuid() != 0 {
+		log.Printf("ℹ️ -user %s ignored: not running as root", username)
+		return nil
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("user %s: non-numeric uid %q", username, u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("user %s: non-numeric gid %q", username, u.Gid)
+	}
+
+	for _, dir := range writableDirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err == nil {
+			os.Chown(dir, uid, gid)
+		}
+	}
+
+	// Order matters: groups and gid can only be changed while still root
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %v", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %v", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %v", err)
+	}
+	if os.Geteuid() == 0 || os.Getuid() == 0 {
+		return fmt.Errorf("still running as root after setuid")
+	}
+
+	log.Printf("🔒 Dropped root privileges: now running as %s (uid %d, gid %d)", username, uid, gid)
+	return nil
+}
+
+// logCaptureCapabilityHint explains how to capture without root when the process lacks CAP_NET_RAW
+func logCaptureCapabilityHint() {
+	if os.Geteuid() == 0 {
+		log.Printf("⚠️ Running as root for the whole session; consider -user with -capture-dir, or setcap cap_net_raw,cap_net_admin=eip on the binary")
+		return
+	}
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return // not Linux
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return
+		}
+		const capNetRaw = 13
+		if caps&(1<<capNetRaw) == 0 {
+			exe, _ := os.Executable()
+			log.Printf("💡 Live capture needs CAP_NET_RAW when not root: sudo setcap cap_net_raw,cap_net_admin=eip %s", exe)
+		}
+		return
+	}
+}
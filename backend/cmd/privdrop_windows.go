@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "fmt"
+
+func dropPrivileges(username string, writableDirs []string) error {
+	return fmt.Errorf("-user is not supported on Windows; run the service under a dedicated account with Npcap access instead")
+}
+
+func logCaptureCapabilityHint() {}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/probe"
+)
+
+// probeMessage carries a probe through its life: probe_started, a probe_result per reply or hop,
+// then probe_complete. Every message goes to the whole room.
+type probeMessage struct {
+	Type      string         `json:"type"`
+	Probe     string         `json:"probe"` // ties the messages of one probe together
+	Kind      string         `json:"kind"`  // ping or traceroute
+	Target    string         `json:"target"`
+	Room      string         `json:"room"`
+	Result    *probe.Result  `json:"result,omitempty"`  // probe_result
+	Summary   *probe.Summary `json:"summary,omitempty"` // probe_complete
+	Error     string         `json:"error,omitempty"`   // probe_complete: the probe couldn't run or was stopped
+	Timestamp int64          `json:"timestamp"`
+	ID        interface{}    `json:"id,omitempty"`
+}
+
+// ToJSON converts a probe message to JSON
+func (m *probeMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// probeSlots allows one probe per room at a time
+type probeSlots struct {
+	mu      sync.Mutex
+	running map[string]bool
+	next    int
+}
+
+// acquire claims the room's slot and names the probe; false while another probe runs there
+func (s *probeSlots) acquire(room string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running == nil {
+		s.running = make(map[string]bool)
+	}
+	if s.running[room] {
+		return "", false
+	}
+	s.running[room] = true
+	s.next++
+	return fmt.Sprintf("probe-%d", s.next), true
+}
+
+func (s *probeSlots) release(room string) {
+	s.mu.Lock()
+	delete(s.running, room)
+	s.mu.Unlock()
+}
+
+// probeConfig returns the config file's probes section, nil when probing is off
+func (manager *ClientManager) probeConfig() *config.Probes {
+	manager.roomsMutex.Lock()
+	defer manager.roomsMutex.Unlock()
+	return manager.cfg.Probes
+}
+
+// handleProbeCommand serves the probe WebSocket command: the server pings or traceroutes a node
+// and the room watches the replies come in
+func (manager *ClientManager) handleProbeCommand(msg map[string]interface{}, client *Client) {
+	reject := func(code, field, format string, args ...interface{}) {
+		protoErr := newProtocolError(code, "probe", field, format, args...)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+	}
+	if !checkAdminToken(msg) {
+		reject(errCodeUnauthorized, "token", "admin token required")
+		return
+	}
+	cfg := manager.probeConfig()
+	if cfg == nil {
+		reject(errCodeUnavailable, "", "probing is off: the config file has no probes section")
+		return
+	}
+	kind, _ := msg["kind"].(string)
+	switch kind {
+	case "":
+		kind = probe.KindPing
+	case probe.KindPing, probe.KindTraceroute:
+	default:
+		reject(errCodeInvalidField, "kind", "kind must be %q or %q", probe.KindPing, probe.KindTraceroute)
+		return
+	}
+	room := client.room
+	target, err := netip.ParseAddr(msg["target"].(string))
+	switch {
+	case err != nil:
+		reject(errCodeInvalidField, "target", "target must be an IP address")
+		return
+	case manager.masks(client, target.String()):
+		reject(errCodeInvalidField, "target", "%s is a pseudonym in this anonymized room", target)
+		return
+	case !probeAllowed(cfg, target.Unmap()):
+		reject(errCodeInvalidField, "target", "%s is outside the probes section's targets", target)
+		return
+	}
+	target = target.Unmap()
+
+	id, ok := manager.probes.acquire(room.name)
+	if !ok {
+		reject(errCodeUnavailable, "", "a probe is already running in room %s", room.name)
+		return
+	}
+	started := &probeMessage{Type: "probe_started", Probe: id, Kind: kind, Target: target.String(), Room: room.name, Timestamp: time.Now().UnixMilli()}
+	for _, member := range manager.roomClients(room) {
+		if member != client {
+			member.trySend(started)
+		}
+	}
+	withID := *started
+	withID.ID = requestID(msg)
+	client.trySend(&withID)
+
+	log.Printf("📡 Room %s: %s %s (%s)", room.name, kind, target, id)
+	go manager.runProbe(room, id, kind, target, cfg)
+}
+
+// runProbe runs a probe to the end, sending each reply or hop to the room as it comes
+func (manager *ClientManager) runProbe(room *Room, id, kind string, target netip.Addr, cfg *config.Probes) {
+	defer manager.probes.release(room.name)
+	opts := probe.Options{Count: cfg.Count, MaxHops: cfg.MaxHops}
+	if opts.Count <= 0 {
+		opts.Count = 4
+	}
+	if opts.MaxHops <= 0 {
+		opts.MaxHops = 30
+	}
+	timeout := cfg.Timeout.Duration
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	message := func(msgType string) *probeMessage {
+		return &probeMessage{Type: msgType, Probe: id, Kind: kind, Target: target.String(), Room: room.name, Timestamp: time.Now().UnixMilli()}
+	}
+	summary, err := probe.Run(ctx, kind, target, opts, func(result probe.Result) {
+		// Hops inside anonymized ranges are shown as pseudonyms, and the raw line would name them
+		if room.anonymized && result.From != "" && manager.anonymizer.Masks(result.From) {
+			result.From, result.Line = manager.anonymizer.Address(result.From), ""
+		}
+		msg := message("probe_result")
+		msg.Result = &result
+		manager.sendToRoom(room, msg)
+	})
+	done := message("probe_complete")
+	done.Summary = &summary
+	if err != nil {
+		done.Error = err.Error()
+		log.Printf("⚠️ Room %s: %s %s: %v", room.name, kind, target, err)
+	}
+	manager.sendToRoom(room, done)
+}
+
+// probeAllowed reports whether the probes section lets target be probed
+func probeAllowed(cfg *config.Probes, target netip.Addr) bool {
+	if len(cfg.Targets) == 0 {
+		return true
+	}
+	for _, cidr := range cfg.Targets {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(target) {
+			return true
+		}
+	}
+	return false
+}
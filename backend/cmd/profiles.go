@@ -0,0 +1,279 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+)
+
+// Enrichments a capture profile can turn off with its "enrich" list
+const (
+	enrichNodeInfo   = "node_info"   // node_info messages (hostnames, vendors, reputation)
+	enrichNodeGroups = "node_groups" // node_grouping cluster hints on packets
+	enrichTunnels    = "tunnels"     // VPN/tunnel classification
+	enrichMulticast  = "multicast"   // multicast and broadcast tagging
+)
+
+var knownEnrichments = map[string]bool{
+	enrichNodeInfo:   true,
+	enrichNodeGroups: true,
+	enrichTunnels:    true,
+	enrichMulticast:  true,
+}
+
+// profileMessage tells a room that its capture profile changed
+type profileMessage struct {
+	Type       string      `json:"type"` // always "profile_switched"
+	Room       string      `json:"room"`
+	Profile    string      `json:"profile"`
+	Mode       string      `json:"mode"`     // capture mode of the new source
+	Sessions   int         `json:"sessions"` // sessions moved to it
+	SampleRate float64     `json:"sample_rate"`
+	Enrich     []string    `json:"enrich,omitempty"` // absent when every enrichment runs
+	ID         interface{} `json:"id,omitempty"`
+}
+
+// ToJSON converts a profile message to JSON
+func (m *profileMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// checkProfiles rejects unknown enrichment names, which would otherwise silently turn one off
+func checkProfiles(profiles []config.Profile) error {
+	for _, profile := range profiles {
+		for _, name := range profile.Enrich {
+			if !knownEnrichments[name] {
+				return fmt.Errorf("profile %s: unknown enrichment %q", profile.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// profileNamed finds a capture profile by name
+func profileNamed(profiles []config.Profile, name string) (*config.Profile, bool) {
+	for i := range profiles {
+		if profiles[i].Name == name {
+			profile := profiles[i]
+			return &profile, true
+		}
+	}
+	return nil, false
+}
+
+// profileName is the name of a profile, or "" for none
+func profileName(profile *config.Profile) string {
+	if profile == nil {
+		return ""
+	}
+	return profile.Name
+}
+
+// useProfile makes a profile the room's active one and applies its sampling rate
+func (room *Room) useProfile(profile *config.Profile) {
+	room.profile.Store(profile)
+	if profile.SampleRate > 0 {
+		room.overrideSampleRate(profile.SampleRate)
+	}
+}
+
+// enriches reports whether the room's capture profile runs an enrichment; rooms without a
+// profile, or with no "enrich" list, run them all
+func (room *Room) enriches(name string) bool {
+	profile := room.profile.Load()
+	if profile == nil || profile.Enrich == nil {
+		return true
+	}
+	for _, enabled := range profile.Enrich {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// profileCapture creates, but doesn't start, the capture a profile describes
+func profileCapture(profile *config.Profile) (capture.PacketCapture, string, error) {
+	switch {
+	case profile.Scenario != "":
+		sim, err := newSimulation(profile.Scenario)
+		if err != nil {
+			return nil, "", fmt.Errorf("profile %s: %v", profile.Name, err)
+		}
+		return sim, "simulated", nil
+	case profile.PCAP != "":
+		speed := profile.Speed
+		if speed <= 0 {
+			speed = 1
+		}
+		return capture.NewPCAPReplayCapture(capture.PCAPReplayConfig{FilePath: profile.PCAP, ReplaySpeed: speed}), "pcap_replay", nil
+	case profile.Interface != "":
+		source := newRealCapture(profile.Interface)
+		source.Filter = profile.Filter
+		return source, "real", nil
+	default:
+		return defaultSimulation(), "simulated", nil
+	}
+}
+
+// switchProfile moves every session in a room to the named capture profile. The new captures
+// are all started before any session is touched, so a profile that fails to start (a missing
+// interface, a bad filter) leaves the room exactly as it was.
+func (manager *ClientManager) switchProfile(room *Room, name string) (*profileMessage, error) {
+	manager.roomsMutex.Lock()
+	profile, ok := profileNamed(manager.cfg.Profiles, name)
+	manager.roomsMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no capture profile named %q", name)
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if room.currentCaptureMode == "time_window" {
+		return nil, fmt.Errorf("room %s is playing a time window; switch to live first", room.name)
+	}
+
+	clients := manager.roomClients(room)
+	sources := make([]capture.PacketCapture, 0, len(clients))
+	mode := ""
+	for range clients {
+		source, sourceMode, err := profileCapture(profile)
+		if err == nil {
+			err = source.Start()
+		}
+		if err != nil {
+			for _, started := range sources {
+				started.Stop()
+			}
+			return nil, err
+		}
+		sources = append(sources, source)
+		mode = sourceMode
+	}
+
+	room.useProfile(profile)
+	var retired []capture.PacketCapture
+	for i, client := range clients {
+		if old, ok := client.setCaptureSource(sources[i], mode); ok {
+			retired = append(retired, old)
+		}
+	}
+	if len(sources) > 0 {
+		room.originalCapture = sources[len(sources)-1]
+		room.currentCaptureMode = mode
+	}
+	// Forwarders move to the new sources before the old ones close
+	room.notifyModeChange()
+	for _, old := range retired {
+		old.Stop()
+	}
+	log.Printf("🎛️ Room %s switched to capture profile %s (%s, %d sessions)", room.name, profile.Name, mode, len(clients))
+	manager.recordMode(room, "profile %s", profile.Name)
+
+	return &profileMessage{
+		Type:       "profile_switched",
+		Room:       room.name,
+		Profile:    profile.Name,
+		Mode:       mode,
+		Sessions:   len(clients),
+		SampleRate: room.view.Load().sampleRate(),
+		Enrich:     profile.Enrich,
+	}, nil
+}
+
+// captureSource returns the session's current capture
+func (c *Client) captureSource() capture.PacketCapture {
+	c.sourceMu.Lock()
+	defer c.sourceMu.Unlock()
+	return c.source
+}
+
+// captureMode returns the mode of the session's current capture
+func (c *Client) captureMode() string {
+	c.sourceMu.Lock()
+	defer c.sourceMu.Unlock()
+	return c.mode
+}
+
+// setCaptureSource installs a new capture and returns the one it replaces. Once the session has
+// ended it stops the new capture instead and reports false.
+func (c *Client) setCaptureSource(source capture.PacketCapture, mode string) (capture.PacketCapture, bool) {
+	c.sourceMu.Lock()
+	defer c.sourceMu.Unlock()
+	if c.sourceClosed {
+		source.Stop()
+		return nil, false
+	}
+	old := c.source
+	c.source, c.mode = source, mode
+	return old, old != nil
+}
+
+// closeCaptureSource stops the session's capture when it ends
+func (c *Client) closeCaptureSource() {
+	c.sourceMu.Lock()
+	defer c.sourceMu.Unlock()
+	c.sourceClosed = true
+	if c.source != nil {
+		c.source.Stop()
+	}
+}
+
+// checkAdminToken compares a command's token with -admin-token; with no token configured every
+// session may run admin commands
+func checkAdminToken(msg map[string]interface{}) bool {
+	if *adminToken == "" {
+		return true
+	}
+	given, _ := msg["token"].(string)
+	return subtle.ConstantTimeCompare([]byte(given), []byte(*adminToken)) == 1
+}
+
+// handleSwitchProfileCommand serves the switch_profile WebSocket command; everyone in the room is told
+func (manager *ClientManager) handleSwitchProfileCommand(msg map[string]interface{}, client *Client) {
+	if !checkAdminToken(msg) {
+		protoErr := newProtocolError(errCodeUnauthorized, "switch_profile", "token", "admin token required")
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	reply, err := manager.switchProfile(client.room, msg["name"].(string))
+	if err != nil {
+		protoErr := newProtocolError(errCodeInvalidField, "switch_profile", "name", "%v", err)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	for _, member := range manager.roomClients(client.room) {
+		if member != client {
+			member.trySend(reply)
+		}
+	}
+	withID := *reply
+	withID.ID = requestID(msg)
+	client.trySend(&withID)
+}
+
+// handleRoomProfile serves POST /api/rooms/{room}/profile with {"profile": name}
+func (manager *ClientManager) handleRoomProfile(w http.ResponseWriter, r *http.Request, room *Room) {
+	var body struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	reply, err := manager.switchProfile(room, body.Profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	manager.auditRequest(r, "switch_profile", room.name, map[string]interface{}{"name": body.Profile})
+	manager.sendToRoom(room, reply)
+	json.NewEncoder(w).Encode(reply)
+}
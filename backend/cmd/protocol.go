@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// protocolVersion is the WebSocket message schema this server speaks. Bump it when a message
+// changes incompatibly; additive fields don't need a bump.
+const protocolVersion = 1
+
+// supportedProtocolVersions are the versions a client may ask for with ?v= or the
+// "vibes.v<N>" subprotocol
+var supportedProtocolVersions = []int{1}
+
+// Error codes carried by the "error" message
+const (
+	errCodeInvalidJSON    = "invalid_json"
+	errCodeMissingType    = "missing_type"
+	errCodeUnknownCommand = "unknown_command"
+	errCodeMissingField   = "missing_field"
+	errCodeInvalidField   = "invalid_field"
+	errCodeUnauthorized   = "unauthorized"
+	errCodeUnavailable    = "unavailable"
+)
+
+// Field kinds understood by decodeCommand
+const (
+	fieldString   = "string"
+	fieldNumber   = "number"
+	fieldPositive = "positive_number"
+	fieldTime     = "rfc3339_time"
+	fieldBool     = "boolean"
+)
+
+// commandField describes one field of an inbound command
+type commandField struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Required bool   `json:"required"`
+}
+
+// commandSchemas lists every client → server command and its fields
+var commandSchemas = map[string][]commandField{
+	"pinRule": {
+		{Name: "rule", Kind: fieldString, Required: true},
+		{Name: "ttl", Kind: fieldPositive}, // seconds until a temporary watch expires; absent keeps the pin
+	},
+	"unpinRule":    {{Name: "rule", Kind: fieldString, Required: true}},
+	"clearAllPins": {},
+	"select_time_window": {
+		{Name: "start_time", Kind: fieldTime, Required: true},
+		{Name: "end_time", Kind: fieldTime, Required: true},
+		{Name: "speed", Kind: fieldPositive},
+		{Name: "scope", Kind: fieldString},         // "room" (the default) or "session" for a window of its own
+		{Name: "sample_rate", Kind: fieldPositive}, // fraction of packets played, up to 1 (the default)
+		{Name: "sampling", Kind: fieldString},      // "uniform" (the default), "flow" or "size"
+		{Name: "keep_pinned", Kind: fieldBool},     // pinned addresses' packets are never sampled away; true by default
+	},
+	"switch_to_live": {},
+	"seek_to_time": {
+		{Name: "time", Kind: fieldTime},
+		{Name: "bookmark", Kind: fieldString}, // instead of time: jump to a bookmark and restore its view
+	},
+	"jump_back":       {{Name: "seconds", Kind: fieldPositive}}, // 30 by default
+	"start_recording": {{Name: "filter", Kind: fieldString}},
+	"stop_recording":  {},
+	"apply_preset":    {{Name: "name", Kind: fieldString, Required: true}},
+	"set_stream":      {{Name: "stream", Kind: fieldString, Required: true}},
+	"heartbeat_ack":   {{Name: "seq", Kind: fieldNumber, Required: true}},
+	"bookmark": {
+		{Name: "description", Kind: fieldString},
+		{Name: "time", Kind: fieldTime}, // defaults to the moment the room is looking at
+	},
+	"annotate": {
+		{Name: "text", Kind: fieldString, Required: true},
+		{Name: "marker", Kind: fieldString},
+	},
+	"switch_profile": {
+		{Name: "name", Kind: fieldString, Required: true},
+		{Name: "token", Kind: fieldString}, // -admin-token, when one is set
+	},
+	"trigger_scenario": {
+		{Name: "name", Kind: fieldString, Required: true},
+		{Name: "source", Kind: fieldString},
+		{Name: "target", Kind: fieldString},
+		{Name: "duration", Kind: fieldPositive},
+		{Name: "rate", Kind: fieldPositive},
+	},
+	"probe": {
+		{Name: "target", Kind: fieldString, Required: true},
+		{Name: "kind", Kind: fieldString},  // "ping" (the default) or "traceroute"
+		{Name: "token", Kind: fieldString}, // -admin-token, when one is set
+	},
+	"dump_ring": {
+		{Name: "ip", Kind: fieldString},        // only the packets to or from this address
+		{Name: "seconds", Kind: fieldPositive}, // the last this many seconds; the whole ring by default
+		{Name: "token", Kind: fieldString},     // -admin-token, when one is set
+	},
+}
+
+// outboundMessageTypes lists every server → client message type; fields are documented in PROTOCOL.md
+var outboundMessageTypes = []string{
+	"packet", "edge_summary", "conversation_summary", "stream_mode", "mode", "error", "ack", "node_info", "drops",
+	"conn_open", "conn_close", "tcp_stats", "tcp_anomaly", "group_stats", "protocol_stats",
+	"size_stats", "size_anomaly", "fragment_stats", "ttl_stats", "ttl_anomaly", "latency_stats",
+	"wifi_stats", "dedup_stats", "interface_stats", "layout_hints", "heartbeat", "replay_progress", "replay_complete",
+	"time_window_active", "time_window_queued", "time_window_error", "live_mode_active", "switch_to_live_error",
+	"seek_complete", "seek_error",
+	"recording_started", "recording_stopped", "recording_error",
+	"preset_applied", "profile_switched", "annotation", "timeline_event", "bookmark_added", "pin_expired",
+	"storage_warning", "alert_ack",
+	"sensor_alert", "clock_skew", "new_device", "new_edge", "dark_space", "rogue_ra", "dhcp_anomaly", "exfiltration", "known_hosts",
+	"scenario_started", "scenario_triggered",
+	"probe_started", "probe_result", "probe_complete", "ring_dump",
+}
+
+// protocolError is a rejected command, sent back as an "error" message
+type protocolError struct {
+	Type    string      `json:"type"` // always "error"
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Command string      `json:"command,omitempty"` // type of the offending command, when known
+	Field   string      `json:"field,omitempty"`
+	ID      interface{} `json:"id,omitempty"` // request id of the offending command, when it had a valid one
+}
+
+// ToJSON converts a protocol error to JSON
+func (e *protocolError) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func newProtocolError(code, command, field, format string, args ...interface{}) *protocolError {
+	return &protocolError{
+		Type:    "error",
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Command: command,
+		Field:   field,
+	}
+}
+
+// decodeCommand parses and validates one inbound frame against commandSchemas
+func decodeCommand(data []byte) (map[string]interface{}, string, *protocolError) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, "", newProtocolError(errCodeInvalidJSON, "", "", "command is not a JSON object: %v", err)
+	}
+	msgType, protoErr := validateCommand(msg)
+	if protoErr != nil {
+		protoErr.ID = requestID(msg)
+		return nil, msgType, protoErr
+	}
+	return msg, msgType, nil
+}
+
+func validateCommand(msg map[string]interface{}) (string, *protocolError) {
+	if id, present := msg["id"]; present && id != nil {
+		if _, ok := id.(string); !ok {
+			if _, ok := id.(float64); !ok {
+				delete(msg, "id") // don't echo it back
+				return "", newProtocolError(errCodeInvalidField, "", "id", "id must be a string or number")
+			}
+		}
+	}
+	msgType, ok := msg["type"].(string)
+	if !ok || msgType == "" {
+		return "", newProtocolError(errCodeMissingType, "", "type", "command has no string \"type\" field")
+	}
+	fields, ok := commandSchemas[msgType]
+	if !ok {
+		return msgType, newProtocolError(errCodeUnknownCommand, msgType, "type", "unknown command %q", msgType)
+	}
+
+	for _, field := range fields {
+		value, present := msg[field.Name]
+		if !present || value == nil {
+			if field.Required {
+				return msgType, newProtocolError(errCodeMissingField, msgType, field.Name, "%s requires %q", msgType, field.Name)
+			}
+			continue
+		}
+		if err := checkFieldKind(field, value); err != "" {
+			return msgType, newProtocolError(errCodeInvalidField, msgType, field.Name, "%s.%s %s", msgType, field.Name, err)
+		}
+	}
+	return msgType, nil
+}
+
+// requestID returns the optional client-chosen "id" of a command (string or number), or nil
+func requestID(msg map[string]interface{}) interface{} {
+	return msg["id"]
+}
+
+// withRequestID echoes the command's id into a map-built response so the client can correlate it
+func withRequestID(msg, response map[string]interface{}) map[string]interface{} {
+	if id := requestID(msg); id != nil {
+		response["id"] = id
+	}
+	return response
+}
+
+// commandAck answers commands that have no dedicated reply message, when they carry an id
+type commandAck struct {
+	Type    string      `json:"type"` // always "ack"
+	Command string      `json:"command"`
+	ID      interface{} `json:"id"`
+}
+
+// ToJSON converts an acknowledgement to JSON
+func (a *commandAck) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func checkFieldKind(field commandField, value interface{}) string {
+	switch field.Kind {
+	case fieldString:
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case fieldNumber, fieldPositive:
+		n, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if field.Kind == fieldPositive && n <= 0 {
+			return "must be greater than 0"
+		}
+	case fieldBool:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case fieldTime:
+		s, ok := value.(string)
+		if !ok {
+			return "must be an RFC 3339 timestamp string"
+		}
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return fmt.Sprintf("is not an RFC 3339 timestamp: %q", s)
+		}
+	}
+	return ""
+}
+
+// negotiateProtocol picks the protocol version for a connection from ?v= or a "vibes.v<N>"
+// subprotocol. Clients that ask for nothing get the current version.
+func negotiateProtocol(r *http.Request) (int, error) {
+	requested := r.URL.Query().Get("v")
+	if requested == "" {
+		for _, sub := range strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",") {
+			sub = strings.TrimSpace(sub)
+			if strings.HasPrefix(sub, "vibes.v") {
+				requested = strings.TrimPrefix(sub, "vibes.v")
+				break
+			}
+		}
+	}
+	if requested == "" {
+		return protocolVersion, nil
+	}
+	version, err := strconv.Atoi(requested)
+	if err != nil {
+		return 0, fmt.Errorf("invalid protocol version %q", requested)
+	}
+	for _, supported := range supportedProtocolVersions {
+		if version == supported {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported protocol version %d (server supports %v)", version, supportedProtocolVersions)
+}
+
+// handleProtocol serves the protocol version and the inbound command schema
+func handleProtocol(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	commands := make([]string, 0, len(commandSchemas))
+	for name := range commandSchemas {
+		commands = append(commands, name)
+	}
+	sort.Strings(commands)
+	schema := make([]map[string]interface{}, 0, len(commands))
+	for _, name := range commands {
+		schema = append(schema, map[string]interface{}{
+			"type":   name,
+			"fields": commandSchemas[name],
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version":            protocolVersion,
+		"supported_versions": supportedProtocolVersions,
+		"commands":           schema,
+		"request_id":         "any command may carry an \"id\" (string or number); its reply, error or ack echoes it",
+		"messages":           outboundMessageTypes,
+	})
+}
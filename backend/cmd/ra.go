@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+)
+
+// raGuardRules reads the config file's router_advertisements section; nil when it is absent
+func raGuardRules(cfg *config.RouterAdverts) (*capture.RAGuardRules, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	rules := &capture.RAGuardRules{Cooldown: cfg.Cooldown.Duration}
+	for _, router := range cfg.Routers {
+		addr, err := netip.ParseAddr(router)
+		if err != nil {
+			return nil, fmt.Errorf("router %q: %v", router, err)
+		}
+		rules.Routers = append(rules.Routers, addr.WithZone(""))
+	}
+	for _, mac := range cfg.MACs {
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return nil, fmt.Errorf("mac %q: %v", mac, err)
+		}
+		rules.MACs = append(rules.MACs, hw.String())
+	}
+	for _, cidr := range cfg.Prefixes {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("prefix %q: %v", cidr, err)
+		}
+		rules.Prefixes = append(rules.Prefixes, prefix.Masked())
+	}
+	return rules, nil
+}
+
+// observeRouterAdvert checks a Router Advertisement heard by the session's live capture and
+// alerts every room when the rules don't allow it. Advertisements in replayed files are left out:
+// they describe how the network was.
+func (manager *ClientManager) observeRouterAdvert(c *Client, packet *capture.Packet) {
+	live, ok := c.liveInterface()
+	if !ok {
+		return
+	}
+	alert := manager.raGuard.Observe(packet, live.Interface(), time.Now())
+	if alert == nil {
+		return
+	}
+	log.Printf("🚨 Rogue router advertisement (%s) from %s %s on %s: %v", alert.Kind, alert.IP, alert.MAC, alert.Interface, alert.Prefixes)
+	data, err := alert.ToJSON()
+	if err != nil {
+		return
+	}
+	manager.clientsMutex.RLock()
+	for client := range manager.clients {
+		// Anonymized rooms mask link-local addresses, routers' included
+		if !manager.masks(client, alert.IP) {
+			client.enqueue(data)
+		}
+	}
+	manager.clientsMutex.RUnlock()
+	manager.notifyJSON(data, "")
+	manager.recordAlert(data, "")
+}
+
+// handleRouters lists the IPv6 routers heard advertising in the last hour, with their prefixes
+// and whether the router_advertisements rules allow them: GET /api/routers
+func (manager *ClientManager) handleRouters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(manager.raGuard.Routers(time.Now()))
+}
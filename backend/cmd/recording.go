@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+var (
+	errAlreadyRecording = errors.New("a recording is already in progress for this session")
+	errNotRecording     = errors.New("no recording in progress for this session")
+)
+
+// recordingMessage reports recording state changes to the client
+type recordingMessage struct {
+	Type      string                   `json:"type"` // recording_started, recording_stopped or recording_error
+	Recording *capture.RecordingStatus `json:"recording,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+	ID        interface{}              `json:"id,omitempty"` // request id of the command being answered
+}
+
+// ToJSON converts a recording message to JSON
+func (m *recordingMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// startRecording begins writing the session's packets to a new PCAP in the recordings directory
+func (manager *ClientManager) startRecording(client *Client, filter string) (*capture.RecordingStatus, error) {
+	if client.recorder.Load() != nil {
+		return nil, errAlreadyRecording
+	}
+	recorder, err := capture.NewRecorder(*recordingsDir, filter)
+	if err != nil {
+		return nil, err
+	}
+	if !client.recorder.CompareAndSwap(nil, recorder) {
+		// Lost a race with a concurrent start; discard the empty file
+		status, _ := recorder.Close()
+		os.Remove(status.File)
+		return nil, errAlreadyRecording
+	}
+	status := recorder.Status()
+	log.Printf("⏺️ Recording %s to %s (filter: %q)", client.conn.RemoteAddr(), status.File, filter)
+	return &status, nil
+}
+
+// stopRecording closes the session's active recording
+func (manager *ClientManager) stopRecording(client *Client) (*capture.RecordingStatus, error) {
+	recorder := client.recorder.Swap(nil)
+	if recorder == nil {
+		return nil, errNotRecording
+	}
+	status, err := recorder.Close()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("⏹️ Recording saved: %s (%d packets, %d bytes)", status.File, status.Packets, status.Bytes)
+	return &status, nil
+}
+
+// handleRecordingCommand serves the start_recording/stop_recording WebSocket commands
+func (manager *ClientManager) handleRecordingCommand(msgType string, msg map[string]interface{}, client *Client) {
+	var status *capture.RecordingStatus
+	var err error
+	if msgType == "start_recording" {
+		filter, _ := msg["filter"].(string)
+		status, err = manager.startRecording(client, filter)
+	} else {
+		status, err = manager.stopRecording(client)
+	}
+
+	if err != nil {
+		client.trySend(&recordingMessage{Type: "recording_error", Error: err.Error(), ID: requestID(msg)})
+		return
+	}
+	reply := &recordingMessage{Type: "recording_started", Recording: status, ID: requestID(msg)}
+	if msgType == "stop_recording" {
+		reply.Type = "recording_stopped"
+	}
+	client.trySend(reply)
+}
+
+// handleRecording is the REST equivalent of the recording commands:
+// GET /api/recording reports status, POST /api/recording/start?filter=... and POST /api/recording/stop
+// control it. ?client=<remote addr> selects the session as for /api/snapshot.
+func (manager *ClientManager) handleRecording(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	client := manager.selectClient(r.URL.Query().Get("client"))
+	if client == nil {
+		http.Error(w, "no active capture session", http.StatusNotFound)
+		return
+	}
+
+	var status *capture.RecordingStatus
+	var err error
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/recording":
+		if recorder := client.recorder.Load(); recorder != nil {
+			current := recorder.Status()
+			status = &current
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/api/recording/start":
+		status, err = manager.startRecording(client, r.URL.Query().Get("filter"))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/recording/stop":
+		status, err = manager.stopRecording(client)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errAlreadyRecording) || errors.Is(err, errNotRecording) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	// Notify the browser session too, so its UI reflects REST-driven changes
+	session := map[string]interface{}{"session": client.conn.RemoteAddr().String()}
+	switch r.URL.Path {
+	case "/api/recording/start":
+		session["filter"] = r.URL.Query().Get("filter")
+		manager.auditRequest(r, "start_recording", client.room.name, session)
+		client.trySend(&recordingMessage{Type: "recording_started", Recording: status})
+	case "/api/recording/stop":
+		manager.auditRequest(r, "stop_recording", client.room.name, session)
+		client.trySend(&recordingMessage{Type: "recording_stopped", Recording: status})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"client":    client.conn.RemoteAddr().String(),
+		"recording": status,
+	})
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"vibes-network-visualizer/internal/capture"
+)
+
+// Relay wire format, agent → central, one JSON object per WebSocket frame:
+//
+//	{"type":"relay_hello","sensor":"hall-b","version":1,"hostname":"nuc-7","capture":"real","interface":"eth0","sent_at":...}
+//	{"type":"relay_packets","sent_at":1700000000000,"packets":[{...packet...}, ...]}
+//	{"type":"relay_heartbeat","sent_at":1700000000000,"captured":123456,"dropped":12}
+const (
+	relayVersion           = 1
+	relayBatchSize         = 500
+	relayFlushInterval     = 200 * time.Millisecond
+	relayHeartbeatInterval = 10 * time.Second
+)
+
+type relayFrame struct {
+	Type      string            `json:"type"`
+	Sensor    string            `json:"sensor,omitempty"`
+	Version   int               `json:"version,omitempty"`
+	Hostname  string            `json:"hostname,omitempty"`
+	Capture   string            `json:"capture,omitempty"`
+	Interface string            `json:"interface,omitempty"`
+	SentAt    int64             `json:"sent_at,omitempty"`
+	Packets   []*capture.Packet `json:"packets,omitempty"`
+	Captured  uint64            `json:"captured,omitempty"` // heartbeat: packets captured since the agent started
+	Dropped   uint64            `json:"dropped,omitempty"`  // heartbeat: captured packets never delivered
+}
+
+// Agent-side counters reported in heartbeats
+var agentCaptured, agentDropped atomic.Uint64
+
+// checkRelayToken validates the -relay-token shared secret, if one is configured
+func checkRelayToken(r *http.Request) bool {
+	if *relayToken == "" {
+		return true
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if given == "" {
+		given = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(*relayToken)) == 1
+}
+
+// handleRelayIngest accepts a capture agent's stream on /api/relay and republishes its packets,
+// tagged with the agent's sensor ID, to every relay-mode session
+func (manager *ClientManager) handleRelayIngest(w http.ResponseWriter, r *http.Request) {
+	if !*relayAccept {
+		http.Error(w, "relay ingest disabled (start with -relay-accept)", http.StatusNotFound)
+		return
+	}
+	if !checkRelayToken(r) {
+		http.Error(w, "relay token required", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(64 << 20)
+
+	var sensor string
+	defer func() {
+		if sensor != "" {
+			manager.sensors.disconnect(sensor)
+		}
+	}()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if sensor != "" {
+				log.Printf("🛰️ Sensor %s disconnected: %v", sensor, err)
+			}
+			return
+		}
+		var frame relayFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			log.Printf("⚠️ Relay: bad frame from %s: %v", conn.RemoteAddr(), err)
+			continue
+		}
+
+		switch frame.Type {
+		case "relay_hello":
+			if !roomNamePattern.MatchString(frame.Sensor) {
+				log.Printf("⚠️ Relay: rejecting sensor ID %q from %s", frame.Sensor, conn.RemoteAddr())
+				return
+			}
+			sensor = frame.Sensor
+			manager.sensors.register(frame, conn.RemoteAddr().String())
+			log.Printf("🛰️ Sensor %s connected from %s (%s capture)", sensor, conn.RemoteAddr(), frame.Capture)
+		case "relay_packets", "relay_heartbeat":
+			if sensor == "" {
+				log.Printf("⚠️ Relay: %s before relay_hello from %s", frame.Type, conn.RemoteAddr())
+				return
+			}
+			offset, skew := manager.sensors.observe(sensor, frame)
+			if skew != nil {
+				log.Printf("⏱️ Sensor %s clock is %+dms off; correcting its timestamps", sensor, skew.OffsetMs)
+				if msg, err := json.Marshal(skew); err == nil {
+					manager.broadcast <- msg
+				}
+			}
+			dropped := 0
+			for _, packet := range frame.Packets {
+				if packet == nil {
+					continue
+				}
+				packet.Type = "packet"
+				packet.Sensor = sensor
+				packet.Timestamp -= offset
+				dropped += capture.PublishRelayPacket(packet)
+			}
+			manager.sensors.relayDropped(sensor, dropped)
+		}
+	}
+}
+
+// newAgentCapture picks the local capture source for agent mode from the command line
+func newAgentCapture() (capture.PacketCapture, string) {
+	switch {
+	case *pcapFile != "":
+		return capture.NewPCAPReplayCapture(capture.PCAPReplayConfig{FilePath: *pcapFile, ReplaySpeed: *replaySpeed}), "pcap_replay"
+	case *captureDir != "" && *iface != "":
+		return capture.NewArchiveCapture(*iface), "archive"
+	case *iface != "":
+		return newRealCapture(*iface), "real"
+	default:
+		return defaultSimulation(), "simulated"
+	}
+}
+
+// relayAgentURL turns the -relay-to server address into the ingest WebSocket URL
+func relayAgentURL(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported scheme %q in -relay-to", u.Scheme)
+	}
+	if !strings.HasSuffix(u.Path, "/api/relay") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/api/relay"
+	}
+	return u.String(), nil
+}
+
+// runRelayAgent captures locally and forwards every packet to a central vibes instance,
+// reconnecting with backoff. Packets captured while disconnected are dropped.
+func runRelayAgent(server, sensor string) error {
+	target, err := relayAgentURL(server)
+	if err != nil {
+		return err
+	}
+	if !roomNamePattern.MatchString(sensor) {
+		return fmt.Errorf("invalid -sensor-id %q (letters, digits, '.', '_' and '-')", sensor)
+	}
+
+	source, mode := newAgentCapture()
+	if err := source.Start(); err != nil {
+		return fmt.Errorf("agent capture: %v", err)
+	}
+	packets := source.GetPacketChannel()
+	hostname, _ := os.Hostname()
+	hello := relayFrame{Type: "relay_hello", Sensor: sensor, Version: relayVersion, Hostname: hostname, Capture: mode, Interface: *iface}
+
+	go func() {
+		backoff := time.Second
+		for {
+			connectedAt := time.Now()
+			err := relayOnce(target, hello, packets)
+			log.Printf("⚠️ Relay to %s interrupted: %v (retrying in %s)", server, err, backoff)
+
+			// Keep draining so the capture doesn't back up while we're offline
+			deadline := time.After(backoff)
+		drain:
+			for {
+				select {
+				case <-packets:
+					agentCaptured.Add(1)
+					agentDropped.Add(1)
+				case <-deadline:
+					break drain
+				}
+			}
+			if time.Since(connectedAt) > time.Minute {
+				backoff = time.Second
+			} else if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+
+	log.Printf("🛰️ Relaying capture to %s as sensor %s", server, sensor)
+	return nil
+}
+
+func relayOnce(target string, hello relayFrame, packets <-chan *capture.Packet) error {
+	header := http.Header{}
+	if *relayToken != "" {
+		header.Set("Authorization", "Bearer "+*relayToken)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(target, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Drain control frames so pings are answered and a server-side close is noticed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	hello.SentAt = time.Now().UnixMilli()
+	if err := conn.WriteJSON(hello); err != nil {
+		return err
+	}
+	log.Printf("🛰️ Connected to relay %s", target)
+
+	ticker := time.NewTicker(relayFlushInterval)
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(relayHeartbeatInterval)
+	defer heartbeat.Stop()
+	batch := make([]*capture.Packet, 0, relayBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		err := conn.WriteJSON(relayFrame{Type: "relay_packets", SentAt: time.Now().UnixMilli(), Packets: batch})
+		if err != nil {
+			agentDropped.Add(uint64(len(batch)))
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		select {
+		case packet := <-packets:
+			// 802.11 management frames are summarized where they are captured, not relayed; neither
+			// are LLDP and CDP announcements
+			if packet == nil || packet.Wireless != nil || packet.Neighbor != nil {
+				continue
+			}
+			agentCaptured.Add(1)
+			batch = append(batch, capture.FullExposure.Packet(packet))
+			if len(batch) >= relayBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case <-heartbeat.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(relayFrame{
+				Type:     "relay_heartbeat",
+				SentAt:   time.Now().UnixMilli(),
+				Captured: agentCaptured.Load(),
+				Dropped:  agentDropped.Load(),
+			}); err != nil {
+				return err
+			}
+		case <-closed:
+			return fmt.Errorf("connection closed by relay")
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"vibes-network-visualizer/internal/audit"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/enrich"
+	"vibes-network-visualizer/internal/health"
+	"vibes-network-visualizer/internal/notify"
+	"vibes-network-visualizer/internal/schedule"
+	"vibes-network-visualizer/internal/storage"
+)
+
+// configWatchInterval is how often -watch-config checks the file for changes
+const configWatchInterval = 2 * time.Second
+
+// configReload reports what a reload changed
+type configReload struct {
+	Applied         []string `json:"applied"`          // sections now in effect: "webhooks", "rooms.kiosk-3", ...
+	RestartRequired []string `json:"restart_required"` // open rooms whose capture source or privacy settings changed
+}
+
+// reloadConfig re-reads the -config file and applies what changed without dropping a session.
+// Subnet groups, node grouping, webhooks, retention and schedules are replaced outright. Open rooms get a
+// changed preset or sample_rate at once; their other settings apply to rooms opened afterwards.
+// Nothing is applied unless the whole file is valid.
+func (manager *ClientManager) reloadConfig() (*configReload, error) {
+	manager.reloadMu.Lock()
+	defer manager.reloadMu.Unlock()
+
+	if *configPath == "" {
+		return nil, fmt.Errorf("the server was started without -config")
+	}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return nil, err
+	}
+	manager.roomsMutex.Lock()
+	old := manager.cfg
+	manager.roomsMutex.Unlock()
+
+	// Validate everything before touching the running server
+	if _, err := enrich.NewSubnetGroups(cfg.SubnetGroups); err != nil {
+		return nil, err
+	}
+	if _, err := enrich.NewSubnetGroups(cfg.DarkSpace); err != nil {
+		return nil, fmt.Errorf("dark_space: %v", err)
+	}
+	raRules, err := raGuardRules(cfg.RouterAdverts)
+	if err != nil {
+		return nil, fmt.Errorf("router_advertisements: %v", err)
+	}
+	dhcpRules, err := dhcpGuardRules(cfg.DHCPGuard)
+	if err != nil {
+		return nil, fmt.Errorf("dhcp_guard: %v", err)
+	}
+	exfil, err := exfilRules(cfg.Exfiltration)
+	if err != nil {
+		return nil, fmt.Errorf("exfiltration: %v", err)
+	}
+	schedules, err := schedule.New(cfg.Schedules)
+	if err != nil {
+		return nil, fmt.Errorf("schedules: %v", err)
+	}
+	scheduled := schedules.At(time.Now())
+	var grouper *enrich.NodeGrouper
+	if cfg.NodeGrouping != nil {
+		if grouper, err = enrich.NewNodeGrouper(cfg.NodeGrouping); err != nil {
+			return nil, err
+		}
+	}
+	for _, room := range cfg.Rooms {
+		if _, err := exposureOf(room.Expose); err != nil {
+			return nil, fmt.Errorf("room %s: %v", room.Name, err)
+		}
+		// Without a key loaded at startup the room would silently stream real addresses
+		if room.Anonymize != nil && *room.Anonymize && manager.anonymizer == nil {
+			return nil, fmt.Errorf("room %s: turning anonymization on needs a restart", room.Name)
+		}
+	}
+	if err := checkProfiles(cfg.Profiles); err != nil {
+		return nil, err
+	}
+	natChanged := !reflect.DeepEqual(old.NAT, cfg.NAT)
+	if natChanged && cfg.NAT != nil {
+		if _, err := enrich.NewNATTable(cfg.NAT); err != nil {
+			return nil, err
+		}
+	}
+	dhcpChanged := !reflect.DeepEqual(old.DHCP, cfg.DHCP)
+	if dhcpChanged && cfg.DHCP != nil {
+		if _, err := enrich.NewLeaseTable(cfg.DHCP); err != nil {
+			return nil, err
+		}
+	}
+	arpChanged := !reflect.DeepEqual(old.ARP, cfg.ARP)
+	if arpChanged && cfg.ARP != nil {
+		if _, err := enrich.NewNeighborTable(cfg.ARP); err != nil {
+			return nil, err
+		}
+	}
+	snmpChanged := !reflect.DeepEqual(old.SNMP, cfg.SNMP)
+	healthChanged := !reflect.DeepEqual(old.HealthChecks, cfg.HealthChecks)
+	if healthChanged {
+		if _, err := health.NewMonitor(cfg.HealthChecks); err != nil {
+			return nil, err
+		}
+	}
+	devicesChanged := !reflect.DeepEqual(old.Devices, cfg.Devices)
+	if devicesChanged && cfg.Devices != nil {
+		if _, err := enrich.NewDeviceRegistry(cfg.Devices, ""); err != nil {
+			return nil, err
+		}
+	}
+	edgesChanged := !reflect.DeepEqual(old.NewEdges, cfg.NewEdges)
+	if edgesChanged && cfg.NewEdges != nil {
+		if _, err := enrich.NewEdgeBaseline(cfg.NewEdges, ""); err != nil {
+			return nil, err
+		}
+	}
+	// Compared with the open schedule windows' overrides applied
+	var retention *storage.RetentionManager
+	policy := retentionPolicy(cfg.Retention, scheduled)
+	retentionChanged := !reflect.DeepEqual(retentionPolicy(old.Retention, manager.scheduled.Load()), policy)
+	if retentionChanged && policy != nil {
+		if retention, err = manager.newRetention(*policy); err != nil {
+			return nil, err
+		}
+	}
+	// Last, since a new notifier starts its senders straight away
+	var notifier *notify.Notifier
+	webhooksChanged := !reflect.DeepEqual(old.Webhooks, cfg.Webhooks)
+	policiesChanged := !reflect.DeepEqual(old.AlertPolicies, cfg.AlertPolicies)
+	if webhooksChanged || policiesChanged {
+		if notifier, err = notify.New(cfg.Webhooks, cfg.AlertPolicies); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &configReload{Applied: []string{}, RestartRequired: []string{}}
+	// Groups edited through /api/groups survive reloads that leave the section alone
+	if !reflect.DeepEqual(old.SubnetGroups, cfg.SubnetGroups) {
+		manager.groups.Set(cfg.SubnetGroups)
+		result.Applied = append(result.Applied, "subnet_groups")
+	}
+	if !reflect.DeepEqual(old.DarkSpace, cfg.DarkSpace) {
+		manager.darkSpace.Set(cfg.DarkSpace)
+		result.Applied = append(result.Applied, "dark_space")
+	}
+	if !reflect.DeepEqual(old.RouterAdverts, cfg.RouterAdverts) {
+		manager.raGuard.SetRules(raRules)
+		result.Applied = append(result.Applied, "router_advertisements")
+	}
+	if !reflect.DeepEqual(old.DHCPGuard, cfg.DHCPGuard) {
+		manager.dhcpGuard.SetRules(dhcpRules)
+		result.Applied = append(result.Applied, "dhcp_guard")
+	}
+	if !reflect.DeepEqual(old.Exfiltration, cfg.Exfiltration) {
+		manager.exfil.Store(exfil)
+		result.Applied = append(result.Applied, "exfiltration")
+	}
+	if !reflect.DeepEqual(old.NodeGrouping, cfg.NodeGrouping) {
+		manager.nodeGrouper.Store(grouper)
+		result.Applied = append(result.Applied, "node_grouping")
+	}
+	if webhooksChanged || policiesChanged {
+		// Open alerts, acknowledged or escalating, carry over to the new policies
+		notifier.Adopt(manager.notifier.Load())
+		manager.notifier.Swap(notifier).Stop()
+	}
+	if webhooksChanged {
+		result.Applied = append(result.Applied, "webhooks")
+	}
+	if policiesChanged {
+		result.Applied = append(result.Applied, "alert_policies")
+	}
+	manager.schedules.Store(schedules)
+	manager.windowsChanged(manager.scheduled.Swap(scheduled), scheduled)
+	if !reflect.DeepEqual(old.Schedules, cfg.Schedules) {
+		result.Applied = append(result.Applied, "schedules")
+	}
+	if retentionChanged {
+		if retention != nil {
+			retention.Start()
+		}
+		if previous := manager.retention.Swap(retention); previous != nil {
+			previous.Stop()
+		}
+	}
+	if !reflect.DeepEqual(old.Retention, cfg.Retention) {
+		result.Applied = append(result.Applied, "retention")
+	}
+
+	type presetChange struct {
+		room *Room
+		msg  *presetMessage
+	}
+	var presets []presetChange
+	manager.roomsMutex.Lock()
+	manager.cfg = cfg
+	// Rooms keep the profile they run; switch_profile picks up the new definitions
+	if !reflect.DeepEqual(old.Profiles, cfg.Profiles) {
+		result.Applied = append(result.Applied, "profiles")
+	}
+	// Probes already running finish with the settings they started with
+	if !reflect.DeepEqual(old.Probes, cfg.Probes) {
+		result.Applied = append(result.Applied, "probes")
+	}
+	if natChanged {
+		result.RestartRequired = append(result.RestartRequired, "nat")
+	}
+	if dhcpChanged {
+		result.RestartRequired = append(result.RestartRequired, "dhcp")
+	}
+	if arpChanged {
+		result.RestartRequired = append(result.RestartRequired, "arp")
+	}
+	if healthChanged {
+		result.RestartRequired = append(result.RestartRequired, "health_checks")
+	}
+	if devicesChanged {
+		result.RestartRequired = append(result.RestartRequired, "devices")
+	}
+	if edgesChanged {
+		result.RestartRequired = append(result.RestartRequired, "new_edges")
+	}
+	if snmpChanged {
+		result.RestartRequired = append(result.RestartRequired, "snmp")
+	}
+	for _, entry := range changedRooms(old.Rooms, cfg.Rooms) {
+		room, open := manager.rooms[entry.Name]
+		if !open {
+			result.Applied = append(result.Applied, "rooms."+entry.Name)
+			continue
+		}
+		previous, _ := roomEntry(old.Rooms, entry.Name)
+		if entry.Preset != previous.Preset || entry.SampleRate != previous.SampleRate {
+			if entry.Preset != previous.Preset && entry.Preset != "" {
+				if msg, err := manager.applyPresetToRoom(room, entry.Preset); err != nil {
+					log.Printf("⚠️ Room %s: %v", room.name, err)
+				} else {
+					presets = append(presets, presetChange{room, msg})
+				}
+			}
+			if entry.SampleRate > 0 {
+				room.overrideSampleRate(entry.SampleRate)
+			}
+			result.Applied = append(result.Applied, "rooms."+entry.Name)
+		}
+		if entry.RecordStream != previous.RecordStream {
+			var err error
+			if entry.RecordStream {
+				_, err = manager.startStreamRecording(room)
+			} else {
+				_, err = manager.stopStreamRecording(room)
+			}
+			if err != nil {
+				log.Printf("⚠️ Room %s: %v", room.name, err)
+			}
+			result.Applied = append(result.Applied, "rooms."+entry.Name)
+		}
+		previous.Preset, previous.SampleRate, previous.RecordStream = entry.Preset, entry.SampleRate, entry.RecordStream
+		if !reflect.DeepEqual(previous, entry) {
+			result.RestartRequired = append(result.RestartRequired, "rooms."+entry.Name)
+		}
+	}
+	manager.roomsMutex.Unlock()
+	for _, change := range presets {
+		manager.sendToRoom(change.room, change.msg)
+	}
+
+	log.Printf("🔄 Reloaded %s: applied %v", *configPath, result.Applied)
+	if len(result.RestartRequired) > 0 {
+		log.Printf("⚠️ Open rooms keep their capture source and privacy settings until the server restarts: %v", result.RestartRequired)
+	}
+	return result, nil
+}
+
+// changedRooms returns the room entries that were added, changed or removed (as an entry with
+// just the name)
+func changedRooms(old, updated []config.Room) []config.Room {
+	var changed []config.Room
+	for _, entry := range updated {
+		if previous, ok := roomEntry(old, entry.Name); !ok || !reflect.DeepEqual(previous, entry) {
+			changed = append(changed, entry)
+		}
+	}
+	for _, entry := range old {
+		if _, ok := roomEntry(updated, entry.Name); !ok {
+			changed = append(changed, config.Room{Name: entry.Name})
+		}
+	}
+	return changed
+}
+
+// roomEntry finds a room's config entry; rooms without one get the zero entry with their name
+func roomEntry(rooms []config.Room, name string) (config.Room, bool) {
+	for _, entry := range rooms {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return config.Room{Name: name}, false
+}
+
+// watchConfig reloads the config file on SIGHUP and, with -watch-config, whenever it changes
+func (manager *ClientManager) watchConfig() {
+	if *configPath == "" {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var poll <-chan time.Time
+	var lastMod time.Time
+	var lastSize int64
+	if *watchConfig {
+		if info, err := os.Stat(*configPath); err == nil {
+			lastMod, lastSize = info.ModTime(), info.Size()
+		}
+		poll = time.NewTicker(configWatchInterval).C
+		log.Printf("👀 Watching %s for changes", *configPath)
+	}
+
+	for {
+		via := "signal"
+		select {
+		case <-hup:
+		case <-poll:
+			info, err := os.Stat(*configPath)
+			if err != nil || (info.ModTime().Equal(lastMod) && info.Size() == lastSize) {
+				continue
+			}
+			lastMod, lastSize = info.ModTime(), info.Size()
+			via = "watch"
+		}
+		result, err := manager.reloadConfig()
+		if err != nil {
+			log.Printf("⚠️ Config reload failed, keeping the current config: %v", err)
+			continue
+		}
+		manager.record(audit.Entry{
+			Actor:  "server",
+			Via:    via,
+			Action: "config_reload",
+			Params: map[string]interface{}{"applied": result.Applied, "restart_required": result.RestartRequired},
+		})
+	}
+}
+
+// handleConfigReload serves POST /api/config/reload
+func (manager *ClientManager) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := manager.reloadConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	manager.auditRequest(r, "config_reload", "", map[string]interface{}{"applied": result.Applied, "restart_required": result.RestartRequired})
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// replaySource returns the replay the session is watching: its own or the room's time window
+// playback, or its own capture when that is a PCAP replay. nil for live sources.
+func (c *Client) replaySource() capture.ProgressReporter {
+	c.room.mu.Lock()
+	processor := c.timeWindow()
+	c.room.mu.Unlock()
+	if processor != nil {
+		return processor
+	}
+
+	source := c.captureSource()
+	if mixed, ok := source.(*capture.MixedCapture); ok {
+		source = mixed.Base()
+	}
+	reporter, _ := source.(capture.ProgressReporter)
+	return reporter
+}
+
+// sendReplayProgress tells the session how far the replay it is watching has got (forwarder
+// goroutine only, called every second). A finished replay is reported once.
+func (c *Client) sendReplayProgress() {
+	reporter := c.replaySource()
+	if reporter == nil {
+		return
+	}
+	progress, ok := reporter.Progress()
+	if !ok {
+		return
+	}
+	if !progress.Done {
+		c.replayFinished = nil
+	} else if c.replayFinished == reporter {
+		return
+	} else {
+		c.replayFinished = reporter
+	}
+	c.trySend(progress)
+}
+
+// What a session does when its PCAP replay reaches the end (?on_complete=)
+const (
+	onCompleteStop = "stop" // keep the last picture; the default
+	onCompleteLoop = "loop" // replay the file again from the start
+	onCompleteLive = "switch_to_live"
+)
+
+// negotiateOnComplete checks a session's ?on_complete= parameter
+func negotiateOnComplete(requested string) (string, error) {
+	switch requested {
+	case "", onCompleteStop:
+		return onCompleteStop, nil
+	case onCompleteLoop, onCompleteLive:
+		return requested, nil
+	}
+	return "", fmt.Errorf("on_complete must be %q, %q or %q", onCompleteLoop, onCompleteLive, onCompleteStop)
+}
+
+// finishReplay tells the session its PCAP replay reached the end, with the totals, and does what
+// its on_complete asks (forwarder goroutine only, called every second). changed reports that the
+// session has a new capture source to read.
+func (manager *ClientManager) finishReplay(c *Client) (changed bool) {
+	c.room.mu.Lock()
+	replaying := c.timeWindow() != nil
+	c.room.mu.Unlock()
+	if replaying {
+		return false // the session's own capture isn't the one being watched
+	}
+	source := c.captureSource()
+	replay, _ := source.(*capture.PCAPReplayCapture)
+	if mixed, ok := source.(*capture.MixedCapture); ok {
+		replay, _ = mixed.Base().(*capture.PCAPReplayCapture)
+	}
+	if replay == nil {
+		return false
+	}
+	completed := replay.Completed()
+	if completed == nil || completed == c.replayCompleted {
+		return false
+	}
+	c.replayCompleted = completed
+
+	totals := *completed
+	totals.OnComplete, totals.Mode = c.onComplete, c.captureMode()
+	switch c.onComplete {
+	case onCompleteLoop:
+		replay.Stop()
+		if err := replay.Start(); err != nil {
+			log.Printf("⚠️ Can't loop %s for %s: %v", totals.File, c.conn.RemoteAddr(), err)
+			totals.OnComplete = onCompleteStop
+			break
+		}
+		log.Printf("🔁 Looping %s for %s", totals.File, c.conn.RemoteAddr())
+	case onCompleteLive:
+		mode, err := c.switchToLiveCapture(source)
+		if err != nil {
+			log.Printf("⚠️ Can't switch %s to live after its replay: %v", c.conn.RemoteAddr(), err)
+			totals.OnComplete = onCompleteStop
+			break
+		}
+		totals.Mode, changed = mode, true
+		log.Printf("📡 %s finished replaying %s, now %s", c.conn.RemoteAddr(), totals.File, mode)
+	}
+	c.trySend(&totals)
+	return changed
+}
+
+// switchToLiveCapture replaces a session's finished replay with the room's live capture, keeping a
+// mixed-in scenario, and returns the new capture mode
+func (c *Client) switchToLiveCapture(old capture.PacketCapture) (string, error) {
+	selected := *iface
+	if c.room.config.Interface != "" {
+		selected = c.room.config.Interface
+	}
+	var live capture.PacketCapture
+	var mode string
+	switch {
+	case *captureDir != "" && selected == *iface:
+		live, mode = capture.NewArchiveCapture(selected), "archive"
+	case selected != "":
+		live, mode = newRealCapture(selected), "real"
+	default:
+		live, mode = defaultSimulation(), "simulated"
+	}
+	if mixed, ok := old.(*capture.MixedCapture); ok && mode != "simulated" {
+		if overlay, err := newSimulation(mixed.Simulation().Scenario().Name); err == nil {
+			live = capture.NewMixedCapture(live, overlay)
+		}
+	}
+	if err := live.Start(); err != nil {
+		return "", err
+	}
+
+	if _, ok := c.setCaptureSource(live, mode); !ok {
+		return "", fmt.Errorf("session ended")
+	}
+	c.room.mu.Lock()
+	if c.room.originalCapture == old {
+		c.room.originalCapture = live
+		c.room.currentCaptureMode = mode
+	}
+	c.room.mu.Unlock()
+	old.Stop()
+	return mode, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// resumeParams are the session parameters a resumed session inherits when its new URL leaves
+// them out
+var resumeParams = []string{"room", "interface", "pcap", "speed", "zeek_tcp", "relay", "scenario", "mix", "coalesce", "stream", "compress", "on_complete"}
+
+// resumeState is what a session left behind for the client to pick up again with ?resume=
+type resumeState struct {
+	room       string
+	query      map[string]string  // resumeParams as the session had them, stream as it last was
+	timeWindow bool               // the room was playing a time window
+	window     *timeWindowRequest // the session's own time window, resumed where it was
+	expires    time.Time
+}
+
+// resumeStore keeps disconnected sessions' state for -resume-ttl. Tokens are single use; every
+// session, resumed or not, is given a fresh one.
+type resumeStore struct {
+	mu     sync.Mutex
+	states map[string]*resumeState
+}
+
+func newResumeStore() *resumeStore {
+	return &resumeStore{states: make(map[string]*resumeState)}
+}
+
+// newToken returns a token for a new session, "" when -resume-ttl disables resuming
+func (s *resumeStore) newToken() string {
+	if *resumeTTL <= 0 {
+		return ""
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		log.Printf("⚠️ Resume token: %v", err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// save keeps a disconnected session's state under its token, dropping any that expired
+func (s *resumeStore) save(token string, state *resumeState) {
+	if token == "" {
+		return
+	}
+	now := time.Now()
+	state.expires = now.Add(*resumeTTL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for old, saved := range s.states {
+		if now.After(saved.expires) {
+			delete(s.states, old)
+		}
+	}
+	s.states[token] = state
+}
+
+// take returns and forgets the state saved under a token
+func (s *resumeStore) take(token string) (*resumeState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[token]
+	if !ok {
+		return nil, false
+	}
+	delete(s.states, token)
+	if time.Now().After(state.expires) {
+		return nil, false
+	}
+	return state, true
+}
+
+// resumeSession looks up the request's ?resume= token and fills the parameters the new URL
+// leaves out from the session it resumes. nil means a fresh session: no token, or one that is
+// unknown, expired or already used.
+func (manager *ClientManager) resumeSession(r *http.Request) *resumeState {
+	query := r.URL.Query()
+	token := query.Get("resume")
+	if token == "" {
+		return nil
+	}
+	state, ok := manager.resumes.take(token)
+	if !ok {
+		log.Printf("🔁 %s tried an unknown or expired resume token, starting afresh", r.RemoteAddr)
+		return nil
+	}
+	for _, name := range resumeParams {
+		if value, saved := state.query[name]; saved && !query.Has(name) {
+			query.Set(name, value)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+	log.Printf("🔁 %s resumed its session in room %s", r.RemoteAddr, state.room)
+	return state
+}
+
+// saveForResume records a disconnected session's state under its resume token
+func (manager *ClientManager) saveForResume(client *Client, r *http.Request, token string) {
+	if token == "" {
+		return
+	}
+	query := r.URL.Query()
+	state := &resumeState{room: client.room.name, query: make(map[string]string)}
+	for _, name := range resumeParams {
+		if query.Has(name) {
+			state.query[name] = query.Get(name)
+		}
+	}
+	state.query["room"] = client.room.name
+	state.query["stream"] = client.stream()
+	client.room.mu.Lock()
+	state.timeWindow = client.room.timeWindowProcessor != nil && client.room.currentCaptureMode == "time_window"
+	if processor := client.window; processor != nil {
+		start, end := processor.Window()
+		state.window = &timeWindowRequest{start: start, end: end, speed: processor.Speed(), sampling: processor.Sampling(), resumeAt: processor.Position()}
+	}
+	client.room.mu.Unlock()
+	manager.resumes.save(token, state)
+}
+
+// roomViewMessage describes the room's pins, filter and sampling as a preset_applied message, so
+// a resumed page that lost them can show them again
+func roomViewMessage(room *Room) *presetMessage {
+	view := room.view.Load()
+	msg := &presetMessage{
+		Type:       "preset_applied",
+		Room:       room.name,
+		Pins:       room.pins.Rules(),
+		SampleRate: view.sampleRate(),
+	}
+	if view != nil {
+		msg.Preset, msg.Filter = view.Preset, view.FilterSpec
+	}
+	return msg
+}
+
+// resumeTimeWindow starts a resumed session's own time window again where it left off
+func (manager *ClientManager) resumeTimeWindow(client *Client, window *timeWindowRequest) {
+	req := *window
+	req.msg = map[string]interface{}{"type": "select_time_window"}
+	req.session = client
+	log.Printf("🔁 %s resumes its time window at %s", client.conn.RemoteAddr(), req.resumeAt.Format("15:04:05"))
+	manager.requestTimeWindow(&req, client.room, client.sendReply)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+var (
+	ringWindow = flag.Duration("ring", time.Minute, "how much traffic each session keeps as raw frames in memory, for dump_ring to write to -recordings (0 disables)")
+	ringSizeMB = flag.Int("ring-size", 64, "most MB of frames each session's ring keeps (-ring)")
+)
+
+// ringDumpMessage answers dump_ring with the PCAP it wrote
+type ringDumpMessage struct {
+	Type string `json:"type"` // ring_dump
+	capture.RingDump
+	ID interface{} `json:"id,omitempty"`
+}
+
+// ToJSON converts a ring dump message to JSON
+func (m *ringDumpMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// newPacketRing returns a session's flight recorder, nil when -ring is 0
+func newPacketRing() *capture.PacketRing {
+	if *ringWindow <= 0 {
+		return nil
+	}
+	return capture.NewPacketRing(*ringWindow, *ringSizeMB<<20)
+}
+
+// dumpRing writes the session's ring, or the packets to or from ip in the last span of it, to
+// -recordings
+func (manager *ClientManager) dumpRing(client *Client, ip string, span time.Duration) (capture.RingDump, error) {
+	dump, err := client.ring.DumpFile(*recordingsDir, ip, span)
+	if err != nil {
+		return dump, err
+	}
+	subject := ip
+	if subject == "" {
+		subject = "all traffic"
+	}
+	log.Printf("🛩️ Ring dump of %s for %s: %s (%d packets, %d bytes)", client.conn.RemoteAddr(), subject, dump.File, dump.Packets, dump.Bytes)
+	return dump, nil
+}
+
+// ringTarget reads the address a dump is limited to; empty means every packet
+func ringTarget(value string) (string, bool) {
+	if value == "" {
+		return "", true
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return "", false
+	}
+	return addr.Unmap().String(), true
+}
+
+// handleDumpRingCommand serves the dump_ring WebSocket command: the session's last minute of
+// frames, or those of one host, written to a PCAP for a closer look
+func (manager *ClientManager) handleDumpRingCommand(msg map[string]interface{}, client *Client) {
+	reject := func(code, field, format string, args ...interface{}) {
+		protoErr := newProtocolError(code, "dump_ring", field, format, args...)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+	}
+	if !checkAdminToken(msg) {
+		reject(errCodeUnauthorized, "token", "admin token required")
+		return
+	}
+	switch {
+	case client.ring == nil:
+		reject(errCodeUnavailable, "", "the packet ring is off (-ring 0)")
+		return
+	case client.room.anonymized:
+		reject(errCodeUnavailable, "", "anonymized rooms keep no original frames")
+		return
+	}
+	value, _ := msg["ip"].(string)
+	ip, ok := ringTarget(value)
+	if !ok {
+		reject(errCodeInvalidField, "ip", "ip must be an IP address")
+		return
+	}
+	var span time.Duration
+	if seconds, ok := msg["seconds"].(float64); ok {
+		span = time.Duration(seconds * float64(time.Second))
+	}
+	dump, err := manager.dumpRing(client, ip, span)
+	if err != nil {
+		log.Printf("⚠️ Ring dump failed: %v", err)
+		reject(errCodeUnavailable, "", "dump failed: %v", err)
+		return
+	}
+	client.trySend(&ringDumpMessage{Type: "ring_dump", RingDump: dump, ID: requestID(msg)})
+}
+
+// handleRingDump is the REST equivalent of dump_ring: POST /api/ring/dump?ip=&seconds=.
+// ?client=<remote addr> selects the session as for /api/recording.
+func (manager *ClientManager) handleRingDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	client := manager.selectClient(query.Get("client"))
+	if client == nil {
+		http.Error(w, "no active capture session", http.StatusNotFound)
+		return
+	}
+	if client.ring == nil {
+		http.Error(w, "the packet ring is off (-ring 0)", http.StatusNotFound)
+		return
+	}
+	if client.room.anonymized {
+		http.Error(w, "anonymized rooms keep no original frames", http.StatusConflict)
+		return
+	}
+	ip, ok := ringTarget(query.Get("ip"))
+	if !ok {
+		http.Error(w, "ip must be an IP address", http.StatusBadRequest)
+		return
+	}
+	var span time.Duration
+	if value := query.Get("seconds"); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "seconds must be a positive number", http.StatusBadRequest)
+			return
+		}
+		span = time.Duration(seconds * float64(time.Second))
+	}
+	dump, err := manager.dumpRing(client, ip, span)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	manager.auditRequest(r, "dump_ring", client.room.name, map[string]interface{}{
+		"session": client.conn.RemoteAddr().String(),
+		"ip":      ip,
+		"seconds": query.Get("seconds"),
+	})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"client": client.conn.RemoteAddr().String(),
+		"dump":   dump,
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/pins"
+	"vibes-network-visualizer/internal/streamrec"
+	"vibes-network-visualizer/internal/timeline"
+)
+
+// defaultRoom is used by clients that don't pass ?room=
+const defaultRoom = "default"
+
+var roomNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Room is a named group of WebSocket sessions (the NOC wall, a kiosk, an analyst laptop) that
+// share a capture source configuration, pinning rules, view and time window playback.
+type Room struct {
+	name    string
+	config  config.Room // capture source defaults; zero value for unconfigured rooms
+	pins    *pins.RuleSet
+	view    atomic.Pointer[clientView]     // preset filter and sampling; nil = defaults
+	profile atomic.Pointer[config.Profile] // active capture profile; nil = the room's source settings
+
+	anonymized bool             // attendee addresses are pseudonymized before they are streamed
+	exposure   capture.Exposure // metadata the room's clients may see
+	aggregated bool             // multicast and broadcast destinations become synthetic group nodes
+	watchBPF   bool             // live capture is narrowed in the kernel to the pins and filter
+
+	watchFilter atomic.Pointer[string] // BPF last compiled from the pins and filter, when watchBPF
+
+	streamRecorder atomic.Pointer[streamrec.Recorder] // recording of the messages the room receives, if any
+	streamTap      atomic.Pointer[Client]             // the session whose messages are recorded
+
+	// Time window playback state, guarded by mu. Forwarders read it when modeChanged is closed.
+	mu                  sync.Mutex
+	timeWindowProcessor *capture.TimeWindowProcessor
+	windowSlot          func()        // frees the playback's slot in manager.windows
+	windowQueued        chan struct{} // closed to take back a request waiting for a slot
+	currentCaptureMode  string
+	originalCapture     capture.PacketCapture
+	modeChanged         chan struct{}
+	replayEvents        []timeline.Event // the window's timeline, shown again as playback passes it
+}
+
+// packetSource returns the channel a session's forwarder reads, the session's or the room's time
+// window playback or else the session's own capture, and a channel that is closed when that changes
+func (room *Room) packetSource(c *Client) (<-chan *capture.Packet, <-chan struct{}) {
+	live := c.captureSource()
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if room.modeChanged == nil {
+		room.modeChanged = make(chan struct{})
+	}
+	if processor := c.timeWindow(); processor != nil {
+		return processor.GetPacketChannel(), room.modeChanged
+	}
+	return live.GetPacketChannel(), room.modeChanged
+}
+
+// notifyModeChange wakes the room's forwarders to pick up a new packet source; callers hold mu
+func (room *Room) notifyModeChange() {
+	if room.modeChanged != nil {
+		close(room.modeChanged)
+	}
+	room.modeChanged = make(chan struct{})
+}
+
+// overrideSampleRate replaces the sampling rate of the room's view, keeping its preset and filter
+func (room *Room) overrideSampleRate(rate float64) {
+	view := &clientView{}
+	if current := room.view.Load(); current != nil {
+		copied := *current
+		view = &copied
+	}
+	view.SampleRate = rate
+	room.view.Store(view)
+}
+
+// room returns the named room, creating it on first use. Configured rooms get their preset applied then.
+func (manager *ClientManager) room(name string) (*Room, error) {
+	if name == "" {
+		name = defaultRoom
+	}
+	if !roomNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid room name %q (letters, digits, '.', '_' and '-', up to 64)", name)
+	}
+
+	manager.roomsMutex.Lock()
+	defer manager.roomsMutex.Unlock()
+	if room, ok := manager.rooms[name]; ok {
+		return room, nil
+	}
+
+	room := &Room{name: name, pins: pins.NewRuleSet()}
+	for _, cfg := range manager.cfg.Rooms {
+		if cfg.Name == name {
+			room.config = cfg
+			break
+		}
+	}
+	room.anonymized = manager.anonymizes(room)
+	room.exposure, _ = exposureOf(room.config.Expose) // checked at startup
+	// Hostname pins would tell which address is which name, so they only match where hostnames
+	// are shown; an anonymized room's pseudonyms have no names anyway
+	if room.exposure.Hostnames && !room.anonymized {
+		room.pins.SetResolver(manager.hostNames)
+	}
+	room.watchBPF = *watchlistBPF
+	if room.config.WatchlistBPF != nil {
+		room.watchBPF = *room.config.WatchlistBPF
+	}
+	if room.watchBPF && room.anonymized {
+		// Its pins name pseudonyms, which never appear on the wire
+		log.Printf("⚠️ Room %s: watchlist_bpf is ignored in anonymized rooms", name)
+		room.watchBPF = false
+	}
+	room.aggregated = *aggregateMulticast
+	if room.config.AggregateMulticast != nil {
+		room.aggregated = *room.config.AggregateMulticast
+	}
+	if room.config.Preset != "" {
+		if _, err := manager.applyPresetToRoom(room, room.config.Preset); err != nil {
+			log.Printf("⚠️ Room %s: %v", name, err)
+		}
+	}
+	if room.config.SampleRate > 0 {
+		room.overrideSampleRate(room.config.SampleRate)
+	}
+	if room.config.Profile != "" {
+		// Checked at startup and on reload
+		if profile, ok := profileNamed(manager.cfg.Profiles, room.config.Profile); ok {
+			room.useProfile(profile)
+		}
+	}
+	if room.config.RecordStream {
+		if _, err := manager.startStreamRecording(room); err != nil {
+			log.Printf("⚠️ Room %s: %v", name, err)
+		}
+	}
+	manager.rooms[name] = room
+	log.Printf("🚪 Room %s opened", name)
+	return room, nil
+}
+
+// roomClients returns the sessions currently in a room
+func (manager *ClientManager) roomClients(room *Room) []*Client {
+	manager.clientsMutex.RLock()
+	defer manager.clientsMutex.RUnlock()
+	var clients []*Client
+	for client := range manager.clients {
+		if client.room == room {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// sendToRoom queues a message for every session in a room without blocking
+func (manager *ClientManager) sendToRoom(room *Room, msg outboundMessage) {
+	for _, client := range manager.roomClients(room) {
+		client.trySend(msg)
+	}
+}
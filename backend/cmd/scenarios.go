@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/scenario"
+)
+
+// scenarioMessage tells a room which scenario its simulated sessions now run
+type scenarioMessage struct {
+	Type        string `json:"type"` // always "scenario_started"
+	Room        string `json:"room"`
+	Scenario    string `json:"scenario"`
+	Description string `json:"description,omitempty"`
+	Sessions    int    `json:"sessions"`
+}
+
+// ToJSON converts a scenario message to JSON
+func (m *scenarioMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// scenarioSummary describes a scenario for /api/scenarios
+type scenarioSummary struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Source      string             `json:"source"` // "builtin" or the file it was loaded from
+	Nodes       int                `json:"nodes"`
+	Topology    *scenario.Topology `json:"topology,omitempty"`
+	Flows       int                `json:"flows"`
+	Loop        string             `json:"loop,omitempty"`
+	Events      []eventSummary     `json:"events"`
+}
+
+type eventSummary struct {
+	Name     string `json:"name,omitempty"`
+	Kind     string `json:"kind"`
+	At       string `json:"at"`
+	Duration string `json:"duration,omitempty"`
+}
+
+func summarizeScenario(sc *scenario.Scenario, source string) scenarioSummary {
+	summary := scenarioSummary{
+		Name:        sc.Name,
+		Description: sc.Description,
+		Source:      source,
+		Nodes:       sc.NodeCount(),
+		Topology:    sc.Topology,
+		Flows:       len(sc.Traffic),
+		Events:      []eventSummary{},
+	}
+	if sc.Loop > 0 {
+		summary.Loop = sc.Loop.String()
+	}
+	for _, event := range sc.Events {
+		e := eventSummary{Name: event.Name, Kind: event.Kind, At: event.At.String()}
+		if event.Duration > 0 {
+			e.Duration = event.Duration.String()
+		}
+		summary.Events = append(summary.Events, e)
+	}
+	return summary
+}
+
+// scenarioFile returns the file in -scenario-dir that defines name, if any
+func scenarioFile(name string) string {
+	if *scenarioDir == "" {
+		return ""
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		file := filepath.Join(*scenarioDir, name+ext)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// lookupScenario resolves a scenario by name: a file in -scenario-dir, then the built-ins.
+// Only the -scenario flag may name a file path directly; names from clients may not.
+func lookupScenario(name string) (*scenario.Scenario, string, error) {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, "", fmt.Errorf("invalid scenario name %q", name)
+	}
+	if file := scenarioFile(name); file != "" {
+		sc, err := scenario.Load(file)
+		return sc, file, err
+	}
+	sc, err := scenario.Builtin(name)
+	return sc, "builtin", err
+}
+
+var (
+	generatedOnce sync.Once
+	generated     *scenario.Scenario
+	generatedErr  error
+)
+
+// defaultScenario is the scenario simulated sessions run unless they ask for another (-scenario or -topology)
+func defaultScenario() (*scenario.Scenario, error) {
+	switch {
+	case *topologySpec != "":
+		if *scenarioName != "" {
+			return nil, fmt.Errorf("-scenario and -topology can't be combined; put a topology block in the scenario instead")
+		}
+		// The same parameters always generate the same network, so every session can share it
+		generatedOnce.Do(func() {
+			topology, err := scenario.ParseTopology(*topologySpec)
+			if err != nil {
+				generatedErr = err
+				return
+			}
+			generated, generatedErr = scenario.Generate(topology)
+		})
+		return generated, generatedErr
+	case *scenarioName == "":
+		return scenario.Builtin(scenario.DefaultName)
+	case strings.HasSuffix(*scenarioName, ".yaml") || strings.HasSuffix(*scenarioName, ".yml"):
+		return scenario.Load(*scenarioName)
+	default:
+		sc, _, err := lookupScenario(*scenarioName)
+		return sc, err
+	}
+}
+
+// newSimulation creates a simulated capture for the named scenario, or the default one
+func newSimulation(name string) (*capture.SimulatedCapture, error) {
+	var sc *scenario.Scenario
+	var err error
+	if name == "" {
+		sc, err = defaultScenario()
+	} else {
+		sc, _, err = lookupScenario(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return capture.NewScenarioCapture(sc), nil
+}
+
+// defaultSimulation is the simulated capture used when nothing else was requested, and as the fallback
+func defaultSimulation() *capture.SimulatedCapture {
+	sim, err := newSimulation("")
+	if err != nil {
+		log.Printf("⚠️ Scenario %s: %v; using %s", *scenarioName, err, scenario.DefaultName)
+		return capture.NewSimulatedCapture()
+	}
+	return sim
+}
+
+// simulationOf returns the simulation behind a session's capture: the capture itself, or
+// the overlay of a mixed one; nil for other captures
+func simulationOf(source capture.PacketCapture) *capture.SimulatedCapture {
+	switch c := source.(type) {
+	case *capture.SimulatedCapture:
+		return c
+	case *capture.MixedCapture:
+		return c.Simulation()
+	}
+	return nil
+}
+
+// listScenarios returns the built-in scenarios and those in -scenario-dir; files win on name clashes
+func listScenarios() []scenarioSummary {
+	byName := make(map[string]scenarioSummary)
+	for _, name := range scenario.BuiltinNames() {
+		if sc, err := scenario.Builtin(name); err == nil {
+			byName[name] = summarizeScenario(sc, "builtin")
+		}
+	}
+	if *scenarioDir != "" {
+		files, _ := filepath.Glob(filepath.Join(*scenarioDir, "*.y*ml"))
+		for _, file := range files {
+			sc, err := scenario.Load(file)
+			if err != nil {
+				log.Printf("⚠️ Skipping scenario: %v", err)
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			byName[name] = summarizeScenario(sc, file)
+		}
+	}
+	list := make([]scenarioSummary, 0, len(byName))
+	for _, summary := range byName {
+		list = append(list, summary)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// startScenario switches every simulated session in the room to the named scenario
+func (manager *ClientManager) startScenario(room *Room, name string) (*scenarioMessage, error) {
+	sc, _, err := lookupScenario(name)
+	if err != nil {
+		return nil, err
+	}
+	reply := &scenarioMessage{Type: "scenario_started", Room: room.name, Scenario: sc.Name, Description: sc.Description}
+	for _, client := range manager.roomClients(room) {
+		if sim := simulationOf(client.captureSource()); sim != nil {
+			sim.SetScenario(sc)
+			reply.Sessions++
+		}
+	}
+	log.Printf("🎬 Room %s: scenario %s started on %d simulated sessions", room.name, sc.Name, reply.Sessions)
+	manager.sendToRoom(room, reply)
+	return reply, nil
+}
+
+// handleScenarios lists scenarios and starts them:
+//
+//	GET  /api/scenarios               built-in scenarios and those in -scenario-dir
+//	GET  /api/scenarios/{name}        one scenario's summary
+//	POST /api/scenarios/{name}/start  run it in a room's simulated sessions (?room=, default "default")
+func (manager *ClientManager) handleScenarios(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/scenarios"), "/")
+	start := false
+	if trimmed := strings.TrimSuffix(name, "/start"); trimmed != name {
+		name, start = trimmed, true
+	}
+
+	switch {
+	case start && r.Method == http.MethodPost:
+		room, err := manager.room(r.URL.Query().Get("room"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply, err := manager.startScenario(room, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		manager.auditRequest(r, "scenario_start", room.name, map[string]interface{}{"name": name})
+		json.NewEncoder(w).Encode(reply)
+
+	case name == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(listScenarios())
+
+	case name != "" && r.Method == http.MethodGet:
+		sc, source, err := lookupScenario(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(summarizeScenario(sc, source))
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
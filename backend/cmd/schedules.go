@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/schedule"
+	"vibes-network-visualizer/internal/storage"
+	"vibes-network-visualizer/internal/timeline"
+)
+
+// runSchedules opens and closes the config file's schedule windows on each minute
+func (manager *ClientManager) runSchedules() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		manager.applySchedules(time.Now())
+	}
+}
+
+// applySchedules works out which windows are open at now and, when that changed, applies what
+// they change: muted alerts and sample rates are read as alerts and packets go by, retention is
+// swapped here
+func (manager *ClientManager) applySchedules(now time.Time) {
+	manager.reloadMu.Lock()
+	defer manager.reloadMu.Unlock()
+	state := manager.schedules.Load().At(now)
+	previous := manager.scheduled.Swap(state)
+	manager.roomsMutex.Lock()
+	base := manager.cfg.Retention
+	manager.roomsMutex.Unlock()
+	manager.windowsChanged(previous, state)
+
+	policy := retentionPolicy(base, state)
+	if reflect.DeepEqual(retentionPolicy(base, previous), policy) {
+		return
+	}
+	var retention *storage.RetentionManager
+	if policy != nil {
+		var err error
+		if retention, err = manager.newRetention(*policy); err != nil {
+			log.Printf("⚠️ Schedules: keeping the retention policy: %v", err)
+			return
+		}
+		retention.Start()
+	}
+	if previous := manager.retention.Swap(retention); previous != nil {
+		previous.Stop()
+	}
+}
+
+// windowsChanged logs the windows that opened or closed between two states and puts them on
+// the timeline
+func (manager *ClientManager) windowsChanged(previous, state *schedule.State) {
+	var was, is []string
+	if previous != nil {
+		was = previous.Open
+	}
+	if state != nil {
+		is = state.Open
+	}
+	for _, name := range is {
+		if !containsString(was, name) {
+			log.Printf("🗓️ Scheduled window %s opened", name)
+			manager.recordTimeline(timeline.Event{Kind: timeline.KindMode, Text: "Scheduled window " + name + " opened"})
+		}
+	}
+	for _, name := range was {
+		if !containsString(is, name) {
+			log.Printf("🗓️ Scheduled window %s closed", name)
+			manager.recordTimeline(timeline.Event{Kind: timeline.KindMode, Text: "Scheduled window " + name + " closed"})
+		}
+	}
+}
+
+// retentionPolicy is the retention section with an open window's overrides applied, or nil when
+// there is neither
+func retentionPolicy(base *config.Retention, state *schedule.State) *config.Retention {
+	if state == nil || state.Retention == nil {
+		return base
+	}
+	policy := config.Retention{}
+	if base != nil {
+		policy = *base
+	}
+	override := state.Retention
+	if len(override.Dirs) > 0 {
+		policy.Dirs = override.Dirs
+	}
+	if override.MaxAge.Duration > 0 {
+		policy.MaxAge = override.MaxAge
+	}
+	if override.MaxTotalGB > 0 {
+		policy.MaxTotalGB = override.MaxTotalGB
+	}
+	if override.WarnFreePercent > 0 {
+		policy.WarnFreePercent = override.WarnFreePercent
+	}
+	if override.CheckInterval.Duration > 0 {
+		policy.CheckInterval = override.CheckInterval
+	}
+	if override.CompressAfter.Duration > 0 {
+		policy.CompressAfter = override.CompressAfter
+	}
+	return &policy
+}
+
+// sampleRate is the share of a room's unpinned packets streamed: the room's own, unless an open
+// schedule window says otherwise
+func (manager *ClientManager) sampleRate(room *Room, view *clientView) float64 {
+	if rate, ok := manager.scheduled.Load().SampleRate(room.name); ok {
+		return rate
+	}
+	return view.sampleRate()
+}
+
+// handleSchedules reports the schedule windows, which are open and when the others open next:
+// GET /api/schedules
+func (manager *ClientManager) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	open := []string{}
+	if state := manager.scheduled.Load(); state != nil {
+		open = state.Open
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"open":      open,
+		"schedules": manager.schedules.Load().Status(time.Now()),
+	})
+}
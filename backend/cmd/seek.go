@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// defaultJumpBack is how far jump_back rewinds without "seconds"
+const defaultJumpBack = 30 * time.Second
+
+// handleJumpBack serves the jump_back WebSocket command: the session's time window playback goes
+// back a few seconds from where it is, never before the window's start, so an analyst can watch
+// a moment again without choosing the window anew
+func (manager *ClientManager) handleJumpBack(msg map[string]interface{}, client *Client) {
+	jump := defaultJumpBack
+	if seconds, ok := msg["seconds"].(float64); ok {
+		jump = time.Duration(seconds * float64(time.Second))
+	}
+
+	room := client.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	processor := client.timeWindow()
+	if processor == nil {
+		response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+			"type":  "seek_error",
+			"error": "No time window active",
+		}))
+		client.sendReply(response)
+		return
+	}
+
+	target := processor.Position().Add(-jump)
+	if start, _ := processor.Window(); target.Before(start) {
+		target = start
+	}
+	if err := processor.SeekToTime(target); err != nil {
+		response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+			"type":  "seek_error",
+			"error": err.Error(),
+		}))
+		client.sendReply(response)
+		return
+	}
+	log.Printf("⏪ %s in room %s jumped back %s to %s", client.conn.RemoteAddr(), room.name, jump, target.Format("15:04:05"))
+
+	response, _ := json.Marshal(withRequestID(msg, map[string]interface{}{
+		"type": "seek_complete",
+		"time": target.Format(time.RFC3339),
+	}))
+	client.sendReply(response)
+}
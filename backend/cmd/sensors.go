@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// sensorWatchInterval is how often sensor rates are recomputed and silence is checked
+const sensorWatchInterval = 5 * time.Second
+
+// sensorInfo is one relay agent as reported by /api/sensors
+type sensorInfo struct {
+	ID            string    `json:"id"`
+	Hostname      string    `json:"hostname,omitempty"`
+	Version       int       `json:"version"`
+	Capture       string    `json:"capture,omitempty"` // agent's local capture mode
+	Interface     string    `json:"interface,omitempty"`
+	Remote        string    `json:"remote"`
+	Connected     bool      `json:"connected"`
+	Silent        bool      `json:"silent"`
+	RegisteredAt  time.Time `json:"registered_at"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	LastSeen      time.Time `json:"last_seen"`
+	Connections   int       `json:"connections"`
+	Packets       uint64    `json:"packets"`         // received by this server
+	PacketsPerSec float64   `json:"packets_per_sec"` // over the last watch interval
+	AgentCaptured uint64    `json:"agent_captured"`  // reported by the agent's last heartbeat
+	AgentDropped  uint64    `json:"agent_dropped"`   // captured but never sent (agent offline or backlogged)
+	RelayDropped  uint64    `json:"relay_dropped"`   // received but dropped for slow relay sessions
+	ClockSkewMs   int64     `json:"clock_skew_ms"`   // estimated agent clock minus ours
+	ClockOffsetMs int64     `json:"clock_offset_ms"` // subtracted from the sensor's packet timestamps
+}
+
+type sensorState struct {
+	info           sensorInfo
+	lastPackets    uint64
+	clock          capture.ClockSync
+	reportedOffset int64
+}
+
+// correction turns the skew estimate into the offset applied to timestamps; skew below
+// capture.SkewThreshold is left alone since it can't be told apart from transit delay
+func correction(skewMs int64) int64 {
+	if skewMs > -capture.SkewThreshold.Milliseconds() && skewMs < capture.SkewThreshold.Milliseconds() {
+		return 0
+	}
+	return skewMs
+}
+
+// sensorRegistry tracks every relay agent that has said hello since startup
+type sensorRegistry struct {
+	mu      sync.Mutex
+	sensors map[string]*sensorState
+}
+
+func newSensorRegistry() *sensorRegistry {
+	return &sensorRegistry{sensors: make(map[string]*sensorState)}
+}
+
+// register records an agent's relay_hello; a sensor ID that reconnects keeps its counters
+func (s *sensorRegistry) register(hello relayFrame, remote string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	state, ok := s.sensors[hello.Sensor]
+	if !ok {
+		state = &sensorState{info: sensorInfo{ID: hello.Sensor, RegisteredAt: now}}
+		s.sensors[hello.Sensor] = state
+	}
+	state.info.Hostname = hello.Hostname
+	state.info.Version = hello.Version
+	state.info.Capture = hello.Capture
+	state.info.Interface = hello.Interface
+	state.info.Remote = remote
+	state.info.Connected = true
+	state.info.ConnectedAt = now
+	state.info.LastSeen = now
+	state.info.Connections++
+	// The agent's clock may have been fixed while it was away
+	state.clock = capture.ClockSync{}
+	if hello.SentAt > 0 {
+		state.info.ClockSkewMs = state.clock.Observe(hello.SentAt, now.UnixMilli())
+		state.info.ClockOffsetMs = correction(state.info.ClockSkewMs)
+	}
+}
+
+// observe updates a sensor from any frame after its hello and returns the offset to subtract
+// from its packet timestamps, plus a clock_skew report when that offset changed noticeably
+func (s *sensorRegistry) observe(sensor string, frame relayFrame) (int64, *capture.ClockSkew) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.sensors[sensor]
+	if !ok {
+		return 0, nil
+	}
+	now := time.Now()
+	state.info.LastSeen = now
+	state.info.Packets += uint64(len(frame.Packets))
+	if frame.SentAt > 0 {
+		state.info.ClockSkewMs = state.clock.Observe(frame.SentAt, now.UnixMilli())
+		state.info.ClockOffsetMs = correction(state.info.ClockSkewMs)
+	}
+	if frame.Type == "relay_heartbeat" {
+		state.info.AgentCaptured = frame.Captured
+		state.info.AgentDropped = frame.Dropped
+	}
+
+	var report *capture.ClockSkew
+	if change := state.info.ClockOffsetMs - state.reportedOffset; change >= capture.SkewThreshold.Milliseconds() || -change >= capture.SkewThreshold.Milliseconds() {
+		state.reportedOffset = state.info.ClockOffsetMs
+		report = &capture.ClockSkew{
+			Type:      "clock_skew",
+			Source:    "sensor",
+			Sensor:    sensor,
+			OffsetMs:  state.info.ClockOffsetMs,
+			Timestamp: now.UnixMilli(),
+		}
+	}
+	return state.info.ClockOffsetMs, report
+}
+
+// relayDropped counts packets from a sensor that slow relay sessions couldn't take
+func (s *sensorRegistry) relayDropped(sensor string, count int) {
+	if count == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.sensors[sensor]; ok {
+		state.info.RelayDropped += uint64(count)
+	}
+}
+
+func (s *sensorRegistry) disconnect(sensor string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.sensors[sensor]; ok {
+		state.info.Connected = false
+	}
+}
+
+func (s *sensorRegistry) get(sensor string) (sensorInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.sensors[sensor]
+	if !ok {
+		return sensorInfo{}, false
+	}
+	return state.info, true
+}
+
+func (s *sensorRegistry) list() []sensorInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]sensorInfo, 0, len(s.sensors))
+	for _, state := range s.sensors {
+		list = append(list, state.info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// sensorAlert is broadcast when a sensor stops sending or comes back
+type sensorAlert struct {
+	Type       string    `json:"type"` // always "sensor_alert"
+	Kind       string    `json:"kind"` // sensor_silent or sensor_recovered
+	Sensor     string    `json:"sensor"`
+	Connected  bool      `json:"connected"`
+	LastSeen   time.Time `json:"last_seen"`
+	SilentForS float64   `json:"silent_for_s,omitempty"`
+	Timestamp  int64     `json:"timestamp"`
+}
+
+// tick recomputes packet rates and returns alerts for sensors that went silent or recovered
+func (s *sensorRegistry) tick(interval, silentAfter time.Duration) []sensorAlert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var alerts []sensorAlert
+	for _, state := range s.sensors {
+		info := &state.info
+		info.PacketsPerSec = float64(info.Packets-state.lastPackets) / interval.Seconds()
+		state.lastPackets = info.Packets
+
+		silentFor := now.Sub(info.LastSeen)
+		silent := silentFor >= silentAfter
+		if silent == info.Silent {
+			continue
+		}
+		info.Silent = silent
+		alert := sensorAlert{
+			Type:      "sensor_alert",
+			Kind:      "sensor_recovered",
+			Sensor:    info.ID,
+			Connected: info.Connected,
+			LastSeen:  info.LastSeen,
+			Timestamp: now.UnixMilli(),
+		}
+		if silent {
+			alert.Kind = "sensor_silent"
+			alert.SilentForS = silentFor.Round(time.Second).Seconds()
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// watchSensors broadcasts sensor_alert messages to every client and logs them
+func (manager *ClientManager) watchSensors(silentAfter time.Duration) {
+	ticker := time.NewTicker(sensorWatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, alert := range manager.sensors.tick(sensorWatchInterval, silentAfter) {
+			if alert.Kind == "sensor_silent" {
+				log.Printf("⚠️ Sensor %s silent for %.0fs (last seen %s)", alert.Sensor, alert.SilentForS, alert.LastSeen.Format(time.RFC3339))
+			} else {
+				log.Printf("🛰️ Sensor %s is sending again", alert.Sensor)
+			}
+			msg, _ := json.Marshal(alert)
+			manager.broadcast <- msg
+			manager.notifyJSON(msg, "")
+			manager.recordAlert(msg, "")
+		}
+	}
+}
+
+// handleSensors reports relay agents: GET /api/sensors lists them, GET /api/sensors/{id} shows one
+func (manager *ClientManager) handleSensors(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sensors"), "/")
+	if id == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"relay_accept":   *relayAccept,
+			"silent_after_s": sensorSilentAfter.Seconds(),
+			"sensors":        manager.sensors.list(),
+		})
+		return
+	}
+	info, ok := manager.sensors.get(id)
+	if !ok {
+		http.Error(w, "unknown sensor", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/pins"
+)
+
+// uplinkRate is a session's WebSocket send rate over the last stats interval
+type uplinkRate struct {
+	BytesPerSec    float64
+	MessagesPerSec float64
+}
+
+// updateUplink recomputes the send rate; called from the forwarder's stats tick only
+func (c *Client) updateUplink(interval time.Duration) {
+	bytes, messages := c.sentBytes.Load(), c.sentMessages.Load()
+	seconds := interval.Seconds()
+	c.uplink.Store(&uplinkRate{
+		BytesPerSec:    float64(bytes-c.uplinkLastBytes) / seconds,
+		MessagesPerSec: float64(messages-c.uplinkLastMessages) / seconds,
+	})
+	c.uplinkLastBytes, c.uplinkLastMessages = bytes, messages
+}
+
+// sessionInfo describes one connected WebSocket client for /api/sessions
+type sessionInfo struct {
+	Client               string           `json:"client"`
+	Transport            string           `json:"transport"` // websocket or webtransport
+	Room                 string           `json:"room"`
+	Mode                 string           `json:"mode"`
+	Protocol             int              `json:"protocol_version"`
+	ConnectedAt          time.Time        `json:"connected_at"`
+	Preset               string           `json:"preset,omitempty"`
+	Pins                 []string         `json:"pins"`
+	Filter               *pins.ViewFilter `json:"filter,omitempty"`
+	SampleRate           float64          `json:"sample_rate"`
+	UplinkBytesPerSec    float64          `json:"uplink_bytes_per_sec"`
+	UplinkMessagesPerSec float64          `json:"uplink_messages_per_sec"`
+	SentBytes            uint64           `json:"sent_bytes"`
+	SentMessages         uint64           `json:"sent_messages"`
+	DroppedMessages      uint64           `json:"dropped_messages"`   // lost to a full send queue
+	DuplicatesRemoved    uint64           `json:"duplicates_removed"` // mirrored-tap copies (-dedup-window)
+	Coalesce             bool             `json:"coalesce"`
+	Stream               string           `json:"stream"`                      // raw or summary
+	Compress             string           `json:"compress,omitempty"`          // deflate when the session asked for it
+	CompressedBytes      uint64           `json:"compressed_bytes,omitempty"`  // sent on the wire; sent_bytes counts messages before compression
+	CompressionRatio     float64          `json:"compression_ratio,omitempty"` // sent_bytes per compressed byte
+	Recording            bool             `json:"recording"`
+	RTTMs                float64          `json:"rtt_ms,omitempty"`     // latest ping round trip
+	LastPong             *time.Time       `json:"last_pong,omitempty"`  // when it came back
+	AppRTTMs             float64          `json:"app_rtt_ms,omitempty"` // heartbeat to heartbeat_ack, through the page's own code
+	LastAck              *time.Time       `json:"last_ack,omitempty"`   // latest heartbeat_ack; never for clients that don't send them
+	QueueLagMs           float64          `json:"queue_lag_ms"`         // how long the latest heartbeat waited in the send queue
+	QueuedMessages       int              `json:"queued_messages"`
+	Stale                bool             `json:"stale"` // stopped answering heartbeats, or its queue lags
+}
+
+func (manager *ClientManager) sessionInfo(client *Client) sessionInfo {
+	room := client.room
+	view := room.view.Load()
+	info := sessionInfo{
+		Client:            client.conn.RemoteAddr().String(),
+		Transport:         client.conn.transport(),
+		Room:              room.name,
+		Mode:              client.captureMode(),
+		Protocol:          client.protocol,
+		ConnectedAt:       client.connectedAt,
+		Pins:              room.pins.Rules(),
+		SampleRate:        view.sampleRate(),
+		SentBytes:         client.sentBytes.Load(),
+		SentMessages:      client.sentMessages.Load(),
+		DroppedMessages:   client.dropped.Load(),
+		DuplicatesRemoved: client.duplicates.Load(),
+		Coalesce:          client.coalesce,
+		Stream:            client.stream(),
+		Compress:          client.compression(),
+		CompressedBytes:   client.compressedBytes.Load(),
+		CompressionRatio:  math.Round(client.compressionRatio()*100) / 100,
+		Recording:         client.recorder.Load() != nil,
+		AppRTTMs:          latencyMs(time.Duration(client.heartbeat.appRTT.Load())),
+		QueueLagMs:        latencyMs(time.Duration(client.heartbeat.queueLag.Load())),
+		QueuedMessages:    len(client.send),
+		Stale:             client.stale(time.Now()),
+	}
+	if rtt, lastPong := client.conn.pingStats(); !lastPong.IsZero() {
+		info.RTTMs, info.LastPong = latencyMs(rtt), &lastPong
+	}
+	if lastAck := client.heartbeat.lastAck.Load(); lastAck != 0 {
+		at := time.Unix(0, lastAck)
+		info.LastAck = &at
+	}
+	room.mu.Lock()
+	if client.timeWindow() != nil {
+		info.Mode = "time_window"
+	}
+	room.mu.Unlock()
+	if view != nil {
+		info.Preset = view.Preset
+		info.Filter = view.FilterSpec
+	}
+	if rate := client.uplink.Load(); rate != nil {
+		info.UplinkBytesPerSec = rate.BytesPerSec
+		info.UplinkMessagesPerSec = rate.MessagesPerSec
+	}
+	return info
+}
+
+// kick tells a client why it is being disconnected, then closes its connection
+func (manager *ClientManager) kick(client *Client, reason string) {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"type":   "session_closed",
+		"reason": reason,
+	})
+	client.enqueue(msg)
+	log.Printf("👢 Kicking %s (room %s): %s", client.conn.RemoteAddr(), client.room.name, reason)
+	// Give the write pump a moment to deliver the notice
+	time.AfterFunc(250*time.Millisecond, func() { client.conn.Close() })
+}
+
+// requireAdmin checks the -admin-token bearer token; with no token configured the API is open
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if *adminToken == "" {
+		return true
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if given == "" {
+		given = r.URL.Query().Get("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(given), []byte(*adminToken)) != 1 {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleSessions serves the session admin API:
+//
+//	GET    /api/sessions          list connected clients (?stale=1 for stale screens only)
+//	DELETE /api/sessions/{addr}   disconnect a client (?reason= is shown to it)
+func (manager *ClientManager) handleSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	addr := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/sessions"), "/")
+	switch {
+	case addr == "" && r.Method == http.MethodGet:
+		// Snapshot first: sessionInfo takes room locks, which must never nest inside clientsMutex
+		manager.clientsMutex.RLock()
+		clients := make([]*Client, 0, len(manager.clients))
+		for client := range manager.clients {
+			clients = append(clients, client)
+		}
+		manager.clientsMutex.RUnlock()
+
+		staleOnly, _ := strconv.ParseBool(r.URL.Query().Get("stale"))
+		sessions := make([]sessionInfo, 0, len(clients))
+		for _, client := range clients {
+			if info := manager.sessionInfo(client); info.Stale || !staleOnly {
+				sessions = append(sessions, info)
+			}
+		}
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt) })
+		json.NewEncoder(w).Encode(sessions)
+
+	case addr != "" && r.Method == http.MethodDelete:
+		client := manager.selectClient(addr)
+		if client == nil {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		reason := r.URL.Query().Get("reason")
+		if reason == "" {
+			reason = "disconnected by operator"
+		}
+		manager.kick(client, reason)
+		manager.auditRequest(r, "kick_session", client.room.name, map[string]interface{}{"session": addr, "reason": reason})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRooms lists rooms and forces a room's playback mode:
+//
+//	GET  /api/rooms               list rooms with their sessions and mode
+//	POST /api/rooms/{room}/mode   {"mode":"live"} or {"mode":"time_window","start_time":...,"end_time":...,"speed":2}
+//	POST /api/rooms/{room}/stream/start, /stream/stop   record the room's message stream to -streams
+func (manager *ClientManager) handleRooms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/rooms"), "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		manager.roomsMutex.Lock()
+		rooms := make([]*Room, 0, len(manager.rooms))
+		for _, room := range manager.rooms {
+			rooms = append(rooms, room)
+		}
+		manager.roomsMutex.Unlock()
+		sort.Slice(rooms, func(i, j int) bool { return rooms[i].name < rooms[j].name })
+
+		list := make([]map[string]interface{}, 0, len(rooms))
+		for _, room := range rooms {
+			room.mu.Lock()
+			mode := room.currentCaptureMode
+			room.mu.Unlock()
+			entry := map[string]interface{}{
+				"name":     room.name,
+				"mode":     mode,
+				"sessions": len(manager.roomClients(room)),
+				"pins":     room.pins.Rules(),
+			}
+			if view := room.view.Load(); view != nil {
+				entry["preset"] = view.Preset
+			}
+			if profile := room.profile.Load(); profile != nil {
+				entry["profile"] = profile.Name
+			}
+			if expr := room.watchFilter.Load(); room.watchBPF && expr != nil {
+				entry["watch_filter"] = *expr // "" while it captures everything
+			}
+			if recorder := room.streamRecorder.Load(); recorder != nil {
+				entry["stream_recording"] = recorder.Status()
+			}
+			list = append(list, entry)
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case strings.HasSuffix(path, "/mode") && r.Method == http.MethodPost:
+		manager.roomsMutex.Lock()
+		room, ok := manager.rooms[strings.TrimSuffix(path, "/mode")]
+		manager.roomsMutex.Unlock()
+		if !ok {
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		}
+		manager.forceRoomMode(w, r, room)
+
+	case strings.HasSuffix(path, "/profile") && r.Method == http.MethodPost:
+		manager.roomsMutex.Lock()
+		room, ok := manager.rooms[strings.TrimSuffix(path, "/profile")]
+		manager.roomsMutex.Unlock()
+		if !ok {
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		}
+		manager.handleRoomProfile(w, r, room)
+
+	case (strings.HasSuffix(path, "/stream/start") || strings.HasSuffix(path, "/stream/stop")) && r.Method == http.MethodPost:
+		name, action := path[:strings.LastIndex(path, "/stream/")], path[strings.LastIndex(path, "/")+1:]
+		manager.roomsMutex.Lock()
+		room, ok := manager.rooms[name]
+		manager.roomsMutex.Unlock()
+		if !ok {
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		}
+		manager.handleRoomStreamRecording(w, r, room, action)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// forceRoomMode runs switch_to_live or select_time_window on behalf of the room and relays the
+// result message to every session in it
+func (manager *ClientManager) forceRoomMode(w http.ResponseWriter, r *http.Request, room *Room) {
+	var msg map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	mode, _ := msg["mode"].(string)
+	switch mode {
+	case "live":
+		msg["type"] = "switch_to_live"
+	case "time_window":
+		msg["type"] = "select_time_window"
+	default:
+		http.Error(w, `mode must be "live" or "time_window"`, http.StatusBadRequest)
+		return
+	}
+	delete(msg, "mode")
+	if _, protoErr := validateCommand(msg); protoErr != nil {
+		http.Error(w, protoErr.Message, http.StatusBadRequest)
+		return
+	}
+
+	// A queued window reports here when it starts playing, after the response has gone
+	var result []byte
+	var once sync.Once
+	reply := func(response []byte) {
+		once.Do(func() { result = response })
+		for _, client := range manager.roomClients(room) {
+			client.enqueue(response)
+		}
+	}
+	log.Printf("🎛️ Operator forcing room %s into %s mode", room.name, mode)
+	params := map[string]interface{}{"mode": mode}
+	for key, value := range msg {
+		if key != "type" {
+			params[key] = value
+		}
+	}
+	manager.auditRequest(r, "room_mode", room.name, params)
+	if mode == "live" {
+		manager.handleSwitchToLive(msg, room, nil, reply)
+	} else {
+		manager.handleTimeWindowCommand(msg, room, nil, reply)
+	}
+	w.Write(result)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// handleSnapshot exports the current traffic graph of a session as JSON, GraphML or DOT.
+// ?client=<remote addr> selects a session (see /api/metrics/tcp); default is the longest-connected one.
+func (manager *ClientManager) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "graphml" && format != "dot" {
+		http.Error(w, "format must be json, graphml or dot", http.StatusBadRequest)
+		return
+	}
+
+	selected := manager.selectClient(r.URL.Query().Get("client"))
+	if selected == nil {
+		http.Error(w, "no active capture session", http.StatusNotFound)
+		return
+	}
+
+	manager.auditRequest(r, "export_snapshot", selected.room.name, map[string]interface{}{
+		"session": selected.conn.RemoteAddr().String(),
+		"format":  format,
+	})
+	graph := selected.graph.Snapshot()
+	grouper := manager.nodeGrouper.Load()
+	for _, node := range graph.Nodes {
+		// A pseudonymized session's node IDs aren't the addresses assets are labelled by
+		if asset, ok := manager.assets.Get(node.ID); ok && !manager.masks(selected, node.ID) {
+			node.Label = asset.Label
+		}
+		if grouper != nil {
+			node.Group = grouper.Group(node.ID)
+		}
+	}
+
+	filename := fmt.Sprintf("vibes-snapshot-%s.%s", graph.GeneratedAt.Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var err error
+	switch format {
+	case "graphml":
+		w.Header().Set("Content-Type", "application/graphml+xml")
+		err = graph.WriteGraphML(w)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		err = graph.WriteDOT(w)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		err = graph.WriteJSON(w)
+	}
+	if err != nil {
+		log.Printf("Snapshot export (%s) failed: %v", format, err)
+	}
+}
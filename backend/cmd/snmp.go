@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/snmp"
+)
+
+// IF-MIB columns read for each switch port, indexed by ifIndex
+const (
+	oidIfInDiscards         = "1.3.6.1.2.1.2.2.1.13"
+	oidIfInErrors           = "1.3.6.1.2.1.2.2.1.14"
+	oidIfOutDiscards        = "1.3.6.1.2.1.2.2.1.19"
+	oidIfOutErrors          = "1.3.6.1.2.1.2.2.1.20"
+	oidIfHCInOctets         = "1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCInUcastPkts      = "1.3.6.1.2.1.31.1.1.1.7"
+	oidIfHCInMulticastPkts  = "1.3.6.1.2.1.31.1.1.1.8"
+	oidIfHCInBroadcastPkts  = "1.3.6.1.2.1.31.1.1.1.9"
+	oidIfHCOutOctets        = "1.3.6.1.2.1.31.1.1.1.10"
+	oidIfHCOutUcastPkts     = "1.3.6.1.2.1.31.1.1.1.11"
+	oidIfHCOutMulticastPkts = "1.3.6.1.2.1.31.1.1.1.12"
+	oidIfHCOutBroadcastPkts = "1.3.6.1.2.1.31.1.1.1.13"
+	oidIfHighSpeed          = "1.3.6.1.2.1.31.1.1.1.15"
+)
+
+// pollSNMP starts polling the configured switch ports; each sends its utilization to every
+// session as interface_stats with source "snmp", next to the tap's own
+func (manager *ClientManager) pollSNMP(cfg config.SNMP) {
+	interval, timeout := cfg.Interval.Duration, cfg.Timeout.Duration
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	for _, port := range cfg.Ports {
+		community := port.Community
+		if community == "" {
+			community = "public"
+		}
+		log.Printf("🔌 SNMP: polling %s (%s ifIndex %d) every %s", port.Name, port.Host, port.IfIndex, interval)
+		go manager.pollSwitchPort(port, snmp.NewClient(port.Host, community, timeout), interval)
+	}
+}
+
+// pollSwitchPort reads one switch port's counters every interval. Failures are logged when they
+// start and when the port answers again, not on every poll.
+func (manager *ClientManager) pollSwitchPort(port config.SNMPTarget, client *snmp.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var previous capture.InterfaceCounters
+	var previousAt time.Time
+	failing := false
+	for now := range ticker.C {
+		counters, err := readSwitchPort(client, port)
+		if err != nil {
+			if !failing {
+				log.Printf("⚠️ SNMP: %s (%s): %v", port.Name, port.Host, err)
+			}
+			failing, previousAt = true, time.Time{}
+			continue
+		}
+		if failing {
+			log.Printf("🔌 SNMP: %s (%s) is answering again", port.Name, port.Host)
+			failing = false
+		}
+		if !previousAt.IsZero() {
+			stats := capture.LinkStats(port.Name, capture.LinkSourceSNMP, previous, counters, now.Sub(previousAt), now)
+			stats.Device = port.Host
+			if msg, err := json.Marshal(stats); err == nil {
+				manager.broadcast <- msg
+			}
+		}
+		previous, previousAt = counters, now
+	}
+}
+
+// readSwitchPort reads a port's IF-MIB counters; rx is what the switch port received
+func readSwitchPort(client *snmp.Client, port config.SNMPTarget) (capture.InterfaceCounters, error) {
+	oid := func(column string) string {
+		return fmt.Sprintf("%s.%d", column, port.IfIndex)
+	}
+	values, err := client.Get(
+		oid(oidIfHCInOctets), oid(oidIfHCOutOctets),
+		oid(oidIfHCInUcastPkts), oid(oidIfHCInMulticastPkts), oid(oidIfHCInBroadcastPkts),
+		oid(oidIfHCOutUcastPkts), oid(oidIfHCOutMulticastPkts), oid(oidIfHCOutBroadcastPkts),
+		oid(oidIfInErrors), oid(oidIfOutErrors), oid(oidIfInDiscards), oid(oidIfOutDiscards),
+		oid(oidIfHighSpeed),
+	)
+	if err != nil {
+		return capture.InterfaceCounters{}, err
+	}
+	if _, ok := values[oid(oidIfHCInOctets)]; !ok {
+		return capture.InterfaceCounters{}, fmt.Errorf("no 64-bit octet counters for ifIndex %d", port.IfIndex)
+	}
+	counters := capture.InterfaceCounters{
+		RxBytes:   values[oid(oidIfHCInOctets)],
+		TxBytes:   values[oid(oidIfHCOutOctets)],
+		RxPackets: values[oid(oidIfHCInUcastPkts)] + values[oid(oidIfHCInMulticastPkts)] + values[oid(oidIfHCInBroadcastPkts)],
+		TxPackets: values[oid(oidIfHCOutUcastPkts)] + values[oid(oidIfHCOutMulticastPkts)] + values[oid(oidIfHCOutBroadcastPkts)],
+		RxErrors:  values[oid(oidIfInErrors)],
+		TxErrors:  values[oid(oidIfOutErrors)],
+		RxDropped: values[oid(oidIfInDiscards)],
+		TxDropped: values[oid(oidIfOutDiscards)],
+		SpeedMbps: port.SpeedMbps,
+	}
+	if counters.SpeedMbps == 0 {
+		counters.SpeedMbps = int(values[oid(oidIfHighSpeed)])
+	}
+	return counters, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+	"vibes-network-visualizer/internal/storage"
+)
+
+// setupRetention starts the retention manager for the archive directories
+func (manager *ClientManager) setupRetention(policy config.Retention) error {
+	retention, err := manager.newRetention(policy)
+	if err != nil {
+		return err
+	}
+	retention.Start()
+	manager.retention.Store(retention)
+	return nil
+}
+
+// newRetention creates a retention manager that relays its warnings to every connected client
+// as storage_warning messages; call Start to begin enforcement
+func (manager *ClientManager) newRetention(policy config.Retention) (*storage.RetentionManager, error) {
+	if len(policy.Dirs) == 0 {
+		policy.Dirs = manager.archiveDirs()
+	}
+
+	retention, err := storage.NewRetentionManager(policy)
+	if err != nil {
+		return nil, err
+	}
+	retention.OnWarning = manager.storageWarning
+	return retention, nil
+}
+
+// archiveDirs returns the local directories capture files are archived in: the -storage and
+// -dumpcap-dir directories
+func (manager *ClientManager) archiveDirs() []string {
+	var dirs []string
+	// Buckets expire old captures with their own lifecycle rules
+	if _, local := manager.store.(capture.DirStore); local {
+		dirs = []string{*storageDir}
+	}
+	if *dumpcapDir != *storageDir {
+		dirs = append(dirs, *dumpcapDir)
+	}
+	return dirs
+}
+
+// setupIntegrity starts checking rotated capture files in the archive directories for damage
+func (manager *ClientManager) setupIntegrity() {
+	dirs := manager.archiveDirs()
+	if len(dirs) == 0 {
+		return
+	}
+	manager.integrity = storage.NewIntegrityChecker(dirs)
+	manager.integrity.OnWarning = manager.storageWarning
+	manager.integrity.Start()
+}
+
+// storageWarning relays a retention or integrity warning to every connected client as a
+// storage_warning message
+func (manager *ClientManager) storageWarning(message string) {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"type":      "storage_warning",
+		"message":   message,
+		"timestamp": time.Now().UnixMilli(),
+	})
+	manager.broadcast <- msg
+	manager.notifyJSON(msg, "")
+	manager.recordAlert(msg, "")
+}
+
+// handleStorage reports disk usage, the retention policy and recent deletions, and the capture
+// files the integrity checker repaired or quarantined
+func (manager *ClientManager) handleStorage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	var integrity *storage.IntegrityStatus
+	if manager.integrity != nil {
+		status := manager.integrity.Status()
+		integrity = &status
+	}
+
+	retention := manager.retention.Load()
+	if retention == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled":   false,
+			"integrity": integrity,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Enabled bool `json:"enabled"`
+		storage.RetentionStatus
+		Integrity *storage.IntegrityStatus `json:"integrity"`
+	}{true, retention.Status(), integrity})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"vibes-network-visualizer/internal/streamrec"
+)
+
+var (
+	errRoomRecording    = errors.New("the room's stream is already being recorded")
+	errRoomNotRecording = errors.New("the room's stream is not being recorded")
+)
+
+// streamRecording returns the room's stream recorder if this session is the one it follows. The
+// first session to send a message while no session is followed becomes it, so the recording
+// carries on with another session of the room when the followed one leaves.
+func (room *Room) streamRecording(c *Client) *streamrec.Recorder {
+	recorder := room.streamRecorder.Load()
+	if recorder == nil {
+		return nil
+	}
+	if tap := room.streamTap.Load(); tap != c && (tap != nil || !room.streamTap.CompareAndSwap(nil, c)) {
+		return nil
+	}
+	return recorder
+}
+
+// releaseStreamTap lets another session of the room feed its stream recording once c has gone
+func (room *Room) releaseStreamTap(c *Client) {
+	room.streamTap.CompareAndSwap(c, nil)
+}
+
+// startStreamRecording starts writing the messages the room receives to a new file in -streams
+func (manager *ClientManager) startStreamRecording(room *Room) (*streamrec.Status, error) {
+	if room.streamRecorder.Load() != nil {
+		return nil, errRoomRecording
+	}
+	recorder, err := streamrec.Create(*streamsDir, room.name)
+	if err != nil {
+		return nil, err
+	}
+	if !room.streamRecorder.CompareAndSwap(nil, recorder) {
+		status, _ := recorder.Close()
+		os.Remove(status.File)
+		return nil, errRoomRecording
+	}
+	status := recorder.Status()
+	log.Printf("⏺️ Recording room %s's stream to %s", room.name, status.File)
+	return &status, nil
+}
+
+// stopStreamRecording closes the room's stream recording
+func (manager *ClientManager) stopStreamRecording(room *Room) (*streamrec.Status, error) {
+	recorder := room.streamRecorder.Swap(nil)
+	if recorder == nil {
+		return nil, errRoomNotRecording
+	}
+	status, err := recorder.Close()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("⏹️ Stream recording saved: %s (%d messages, %d bytes)", status.File, status.Messages, status.Bytes)
+	return &status, nil
+}
+
+// recordStream appends a message the session just sent to its room's stream recording
+func (manager *ClientManager) recordStream(c *Client, message []byte) {
+	recorder := c.room.streamRecording(c)
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Write(message); err != nil {
+		log.Printf("Stream recording of room %s failed, stopping: %v", c.room.name, err)
+		manager.stopStreamRecording(c.room)
+	}
+}
+
+// handleRoomStreamRecording serves POST /api/rooms/{room}/stream/start and /stream/stop
+func (manager *ClientManager) handleRoomStreamRecording(w http.ResponseWriter, r *http.Request, room *Room, action string) {
+	var status *streamrec.Status
+	var err error
+	if action == "start" {
+		status, err = manager.startStreamRecording(room)
+	} else {
+		status, err = manager.stopStreamRecording(room)
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, errRoomRecording) || errors.Is(err, errRoomNotRecording) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	manager.auditRequest(r, action+"_stream_recording", room.name, map[string]interface{}{"file": status.File})
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleStreams lists stream recordings and hands them out:
+//
+//	GET /api/streams          recordings in -streams, newest first
+//	GET /api/streams/{name}   download one
+func (manager *ClientManager) handleStreams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/streams"), "/")
+	if name == "" {
+		recordings, err := streamrec.List(*streamsDir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recordings)
+		return
+	}
+	path, err := streamrec.Path(*streamsDir, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeFile(w, r, path)
+}
+
+// handleStreamPlayback serves /ws/playback?file=<name>: a WebSocket that re-sends a stream
+// recording verbatim with its original timing, scaled by ?speed=, and starts over with ?loop=1.
+// Commands the client sends are ignored; the recording already holds every reply.
+func (manager *ClientManager) handleStreamPlayback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path, err := streamrec.Path(*streamsDir, query.Get("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	speed := 1.0
+	if value := query.Get("speed"); value != "" {
+		speed, err = strconv.ParseFloat(value, 64)
+		if err != nil || speed <= 0 {
+			http.Error(w, "speed must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	loop, _ := strconv.ParseBool(query.Get("loop"))
+
+	player, err := streamrec.Open(path)
+	if os.IsNotExist(err) {
+		http.Error(w, "stream recording not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer player.Close()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Stream playback upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Reading keeps pings answered and notices the client leaving
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	log.Printf("▶️ Playing stream recording %s (room %s) to %s at %gx", query.Get("file"), player.Header.Room, r.RemoteAddr, speed)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	start := time.Now()
+	for {
+		offset, message, ok, err := player.Next()
+		if err != nil {
+			log.Printf("⚠️ Stream playback of %s stopped: %v", query.Get("file"), err)
+			return
+		}
+		if !ok {
+			if loop {
+				if err := player.Rewind(); err != nil {
+					log.Printf("⚠️ Stream playback of %s stopped: %v", query.Get("file"), err)
+					return
+				}
+				start = time.Now()
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "end of recording"))
+			return
+		}
+
+		if wait := time.Until(start.Add(time.Duration(float64(offset) / speed))); wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-gone:
+				return
+			}
+		}
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Streams a session can be on
+const (
+	streamRaw     = "raw"     // every packet that passes the room's view and sampling
+	streamSummary = "summary" // one edge_summary per second instead of packets
+	// one conversation_summary per second instead of packets: edge_summary with A→B and B→A on one edge
+	streamConversations = "conversations"
+)
+
+// maxSummaryEdges caps the edges in one edge_summary; quieter ones are only counted
+const maxSummaryEdges = 2000
+
+// streamModeMessage tells a client which stream it is on after a switch ("stream_mode")
+type streamModeMessage struct {
+	Type   string      `json:"type"`
+	Stream string      `json:"stream"`
+	Reason string      `json:"reason"` // requested, or slow_client for an automatic downgrade
+	ID     interface{} `json:"id,omitempty"`
+}
+
+// ToJSON converts a stream switch to JSON
+func (m *streamModeMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// stream names the stream a client is on
+func (c *Client) stream() string {
+	switch {
+	case c.conversations.Load():
+		return streamConversations
+	case c.summary.Load():
+		return streamSummary
+	}
+	return streamRaw
+}
+
+// useStream puts a client on a stream; anything unknown is the raw stream
+func (c *Client) useStream(stream string) {
+	c.conversations.Store(stream == streamConversations)
+	c.summary.Store(stream == streamSummary || stream == streamConversations)
+}
+
+// setStream switches a client to another stream and tells it so. A client being downgraded has
+// a full queue, so the notice takes the place of its oldest message.
+func (c *Client) setStream(stream, reason string, id interface{}) {
+	c.useStream(stream)
+	msg, _ := (&streamModeMessage{Type: "stream_mode", Stream: stream, Reason: reason, ID: id}).ToJSON()
+	c.enqueueLatest(msg)
+}
+
+// watchBackpressure downgrades a raw-stream client to the summary stream once it has lost
+// messages -summary-after seconds in a row (forwarder goroutine only, called every second)
+func (c *Client) watchBackpressure() {
+	dropped := c.dropped.Load()
+	switch {
+	case c.summary.Load():
+		c.slowSeconds = 0 // a client that asks for raw again gets a fresh start
+	case dropped > c.slowLastDropped:
+		c.slowSeconds++
+	default:
+		c.slowSeconds = 0
+	}
+	c.slowLastDropped = dropped
+	if *summaryAfter <= 0 || c.slowSeconds < *summaryAfter {
+		return
+	}
+	log.Printf("🐢 %s can't keep up with the raw stream, switching it to edge summaries", c.conn.RemoteAddr())
+	c.setStream(streamSummary, "slow_client", nil)
+}
+
+// handleSetStreamCommand serves the set_stream WebSocket command
+func (manager *ClientManager) handleSetStreamCommand(msg map[string]interface{}, client *Client) {
+	stream := msg["stream"].(string)
+	if stream != streamRaw && stream != streamSummary && stream != streamConversations {
+		protoErr := newProtocolError(errCodeInvalidField, "set_stream", "stream", "stream must be %q, %q or %q", streamRaw, streamSummary, streamConversations)
+		protoErr.ID = requestID(msg)
+		client.trySend(protoErr)
+		return
+	}
+	client.setStream(stream, "requested", requestID(msg))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFdsStart is the first file descriptor systemd passes to a socket-activated service
+const listenFdsStart = 3
+
+// activationListener returns the listen socket systemd passed in (LISTEN_FDS), or nil when the
+// process wasn't socket-activated. The socket unit then owns the address, so -addr is ignored
+// and a port below 1024 needs no privileges at all.
+func activationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+	// Child processes (dumpcap) must not think they were activated too
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if count > 1 {
+		log.Printf("⚠️ systemd passed %d sockets; serving on the first one only", count)
+	}
+
+	file := os.NewFile(listenFdsStart, "systemd-socket")
+	// FileListener works on a duplicate; closing the original keeps it out of child processes
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("fd %d from systemd: %v", listenFdsStart, err)
+	}
+	log.Printf("🔌 Socket-activated by systemd on %s (-addr ignored)", listener.Addr())
+	return listener, nil
+}
+
+// sdNotify sends a state change ("READY=1", "STATUS=...") to the service manager. It does
+// nothing when the process doesn't run under a Type=notify unit.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notifySystemdReady tells systemd the server is up, once capture handles are open, privileges
+// are dropped and the listener is bound. If the unit sets WatchdogSec, it also keeps the
+// watchdog fed.
+func notifySystemdReady(listenAddr string) {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		log.Printf("⚠️ -systemd-notify: NOTIFY_SOCKET is not set; is the unit Type=notify?")
+		return
+	}
+	if err := sdNotify("READY=1\nSTATUS=Serving on " + listenAddr); err != nil {
+		log.Printf("⚠️ systemd notify: %v", err)
+		return
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid, err := strconv.Atoi(os.Getenv("WATCHDOG_PID")); err == nil && pid != os.Getpid() {
+		return
+	}
+	interval := time.Duration(usec) * time.Microsecond / 2
+	log.Printf("🐕 systemd watchdog: pinging every %s", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				log.Printf("⚠️ systemd watchdog: %v", err)
+			}
+		}
+	}()
+}
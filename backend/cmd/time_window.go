@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// Who a select_time_window plays for ("scope")
+const (
+	windowScopeRoom    = "room" // every session in the room follows the playback; the default
+	windowScopeSession = "session"
+)
+
+// timeWindowRequest is a checked select_time_window command
+type timeWindowRequest struct {
+	msg        map[string]interface{}
+	start, end time.Time
+	speed      float64
+	sampling   capture.SamplingConfig
+	session    *Client   // the session playing a window of its own; nil for the whole room
+	resumeAt   time.Time // where playback starts within the window, when not at its start
+}
+
+func (req *timeWindowRequest) scope() string {
+	if req.session != nil {
+		return windowScopeSession
+	}
+	return windowScopeRoom
+}
+
+// windowQueue caps how many time window playbacks read the archive at once (-max-time-windows).
+// Requests over the cap wait their turn, first come first served.
+type windowQueue struct {
+	slots   chan struct{} // one per running playback; nil without a cap
+	waiting atomic.Int64
+}
+
+func newWindowQueue(limit int) *windowQueue {
+	q := &windowQueue{}
+	if limit > 0 {
+		q.slots = make(chan struct{}, limit)
+	}
+	return q
+}
+
+// tryAcquire takes a slot if one is free. release gives it back; calling it again does nothing.
+func (q *windowQueue) tryAcquire() (release func(), ok bool) {
+	if q.slots == nil {
+		return func() {}, true
+	}
+	select {
+	case q.slots <- struct{}{}:
+		return q.releaser(), true
+	default:
+		return nil, false
+	}
+}
+
+// join puts a request in the queue and returns its place in it, 1 for the next to play. The
+// request then waits for its slot with wait.
+func (q *windowQueue) join() int64 {
+	return q.waiting.Add(1)
+}
+
+// wait blocks a request that joined the queue until a slot frees up, or until withdraw is closed
+func (q *windowQueue) wait(withdraw <-chan struct{}) (release func(), ok bool) {
+	defer q.waiting.Add(-1)
+	select {
+	case q.slots <- struct{}{}:
+		return q.releaser(), true
+	case <-withdraw:
+		return nil, false
+	}
+}
+
+func (q *windowQueue) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-q.slots })
+	}
+}
+
+// queuedWindow returns where the request of a session (or of the room, for nil) waiting for a slot
+// is kept; callers hold room.mu
+func queuedWindow(room *Room, session *Client) *chan struct{} {
+	if session != nil {
+		return &session.windowQueued
+	}
+	return &room.windowQueued
+}
+
+// withdrawQueued takes back a request still waiting for a slot; callers hold room.mu
+func withdrawQueued(queued *chan struct{}) {
+	if *queued != nil {
+		close(*queued)
+		*queued = nil
+	}
+}
+
+// requestTimeWindow plays a time window as soon as the archive has a slot for it. Until then the
+// requester is sent time_window_queued with its place in the queue; a newer select_time_window or
+// switch_to_live from the same room (or session, for its own window) takes the request back.
+func (manager *ClientManager) requestTimeWindow(req *timeWindowRequest, room *Room, reply func([]byte)) {
+	room.mu.Lock()
+	queued := queuedWindow(room, req.session)
+	withdrawQueued(queued)
+	room.mu.Unlock()
+
+	if release, ok := manager.windows.tryAcquire(); ok {
+		manager.playTimeWindow(req, room, release, reply)
+		return
+	}
+
+	withdraw := make(chan struct{})
+	room.mu.Lock()
+	*queued = withdraw
+	room.mu.Unlock()
+	position := manager.windows.join()
+	log.Printf("⏳ Time window for %s %s queued at %d (%d playing)", req.scope(), room.name, position, len(manager.windows.slots))
+	response, _ := json.Marshal(withRequestID(req.msg, map[string]interface{}{
+		"type":       "time_window_queued",
+		"start_time": req.start.Format(time.RFC3339),
+		"end_time":   req.end.Format(time.RFC3339),
+		"scope":      req.scope(),
+		"position":   position,
+		"playing":    len(manager.windows.slots),
+	}))
+	reply(response)
+
+	go func() {
+		release, ok := manager.windows.wait(withdraw)
+		if !ok {
+			return
+		}
+		room.mu.Lock()
+		current := *queued == withdraw
+		if current {
+			*queued = nil
+		}
+		room.mu.Unlock()
+		if !current {
+			release() // taken back while the slot came free
+			return
+		}
+		manager.playTimeWindow(req, room, release, reply)
+	}()
+}
+
+// timeWindow returns the time window playback the session watches, its own or the room's; nil
+// when it watches its capture. Callers hold room.mu.
+func (c *Client) timeWindow() *capture.TimeWindowProcessor {
+	if c.window != nil {
+		return c.window
+	}
+	if c.room.timeWindowProcessor != nil && c.room.currentCaptureMode == "time_window" {
+		return c.room.timeWindowProcessor
+	}
+	return nil
+}
+
+// endTimeWindow stops the session's own time window playback and frees its slot; callers hold
+// room.mu and wake the forwarder with notifyModeChange
+func (c *Client) endTimeWindow() {
+	if c.window == nil {
+		return
+	}
+	c.window.Stop()
+	c.windowSlot()
+	c.window, c.windowSlot, c.windowEvents = nil, nil, nil
+}
+
+// endTimeWindow stops the room's time window playback and frees its slot; callers hold mu and
+// set the mode that follows
+func (room *Room) endTimeWindow() {
+	if room.timeWindowProcessor == nil {
+		return
+	}
+	room.timeWindowProcessor.Stop()
+	room.windowSlot()
+	room.timeWindowProcessor, room.windowSlot, room.replayEvents = nil, nil, nil
+}
+
+// endSessionWindow ends a departed session's own time window, playing or queued
+func (manager *ClientManager) endSessionWindow(client *Client) {
+	client.room.mu.Lock()
+	defer client.room.mu.Unlock()
+	withdrawQueued(&client.windowQueued)
+	client.endTimeWindow()
+}
+
+// releaseIdleWindow ends the room's time window playback if nobody is back to watch it within
+// -resume-ttl of its last session leaving, so an abandoned window doesn't keep its archive slot
+func (manager *ClientManager) releaseIdleWindow(room *Room) {
+	idle := *resumeTTL
+	if idle <= 0 {
+		idle = time.Second // long enough for the session's unregister to land
+	}
+	time.AfterFunc(idle, func() {
+		if len(manager.roomClients(room)) > 0 {
+			return
+		}
+		room.mu.Lock()
+		defer room.mu.Unlock()
+		withdrawQueued(&room.windowQueued)
+		if room.timeWindowProcessor == nil {
+			return
+		}
+		room.endTimeWindow()
+		room.currentCaptureMode = "live"
+		room.notifyModeChange()
+		log.Printf("🕰️ Room %s left its time window unwatched; playback stopped", room.name)
+	})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/notify"
+	"vibes-network-visualizer/internal/timeline"
+)
+
+const (
+	// Every session in a room raises the same alert every few seconds; the timeline keeps one
+	timelineAlertCooldown = time.Minute
+	maxTimelineAlertKeys  = 10000
+	// Replayed packets step back a little now and then; only a bigger jump is a seek
+	replayRewindMillis = 1000
+)
+
+// scanKinds are the alert kinds the timeline files as scans rather than plain alerts
+var scanKinds = map[string]bool{
+	capture.AnomalyTraceroute: true,
+}
+
+// timelineEventMessage replays an alert, scan or mode switch during time window playback
+type timelineEventMessage struct {
+	Type      string          `json:"type"` // always "timeline_event"
+	Room      string          `json:"room"`
+	Kind      string          `json:"kind"`
+	Text      string          `json:"text"`
+	Timestamp int64           `json:"timestamp"` // ms, when it happened
+	Details   json.RawMessage `json:"details,omitempty"`
+	Replay    bool            `json:"replay"`
+}
+
+// ToJSON converts a timeline event to JSON
+func (m *timelineEventMessage) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// newTimelineMessage is what a room is sent when playback reaches a timeline event: annotations
+// come back as they were made, anything else as a timeline_event
+func newTimelineMessage(room *Room, event timeline.Event, replay bool) outboundMessage {
+	if event.Kind == timeline.KindAnnotation {
+		return newAnnotationMessage(room, event, replay)
+	}
+	return &timelineEventMessage{
+		Type:      "timeline_event",
+		Room:      room.name,
+		Kind:      event.Kind,
+		Text:      event.Text,
+		Timestamp: event.Time.UnixMilli(),
+		Details:   event.Details,
+		Replay:    replay,
+	}
+}
+
+// recordTimeline stores an event when -timeline is set
+func (manager *ClientManager) recordTimeline(event timeline.Event) {
+	if manager.timeline == nil {
+		return
+	}
+	if _, err := manager.timeline.Record(event); err != nil {
+		log.Printf("⚠️ Timeline: %v", err)
+	}
+}
+
+// recordMode puts a room's mode switch on the timeline
+func (manager *ClientManager) recordMode(room *Room, format string, args ...interface{}) {
+	manager.recordTimeline(timeline.Event{
+		Kind: timeline.KindMode,
+		Room: room.name,
+		Text: fmt.Sprintf(format, args...),
+	})
+}
+
+// recordAlerts puts a batch of alerts raised on a session's live traffic on the timeline, as the
+// session received them. Alerts raised while it watches a time window are old news.
+func recordAlerts[T outboundMessage](manager *ClientManager, client *Client, msgs []T) {
+	if manager.timeline == nil || len(msgs) == 0 {
+		return
+	}
+	client.room.mu.Lock()
+	replaying := client.timeWindow() != nil
+	client.room.mu.Unlock()
+	if replaying {
+		return
+	}
+	for _, msg := range msgs {
+		if data, err := msg.ToJSON(); err == nil {
+			manager.recordAlert(data, client.room.name)
+		}
+	}
+}
+
+// recordAlert puts one alert message on the timeline; room is empty for server-wide alerts
+func (manager *ClientManager) recordAlert(data []byte, room string) {
+	if manager.timeline == nil {
+		return
+	}
+	alert, err := notify.FromMessage(data, room)
+	if err != nil {
+		return
+	}
+	if !manager.timelineAlerts.first(room+"|"+alert.Type+"|"+alert.Kind+"|"+alert.Subject, alert.Time) {
+		return
+	}
+	kind := timeline.KindAlert
+	if scanKinds[alert.Kind] {
+		kind = timeline.KindScan
+	}
+	text := alert.Type
+	if alert.Kind != "" {
+		text = alert.Kind
+	}
+	if alert.Subject != "" {
+		text += " " + alert.Subject
+	} else if message, ok := alert.Details["message"].(string); ok {
+		text += ": " + message
+	}
+	manager.recordTimeline(timeline.Event{
+		Time:    alert.Time,
+		Kind:    kind,
+		Room:    room,
+		Text:    text,
+		Details: data,
+	})
+}
+
+// alertCooldown remembers when each alert last went on the timeline
+type alertCooldown struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// first reports whether an alert is new or its previous occurrence is over a cooldown ago
+func (c *alertCooldown) first(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		c.last = make(map[string]time.Time)
+	}
+	if last, ok := c.last[key]; ok && now.Sub(last) < timelineAlertCooldown {
+		return false
+	}
+	if len(c.last) >= maxTimelineAlertKeys {
+		for k, t := range c.last {
+			if now.Sub(t) >= timelineAlertCooldown {
+				delete(c.last, k)
+			}
+		}
+	}
+	c.last[key] = now
+	return true
+}
+
+// timelineBetween returns the room's timeline events in a time window; none without -timeline
+func (manager *ClientManager) timelineBetween(room *Room, from, to time.Time) []timeline.Event {
+	if manager.timeline == nil {
+		return []timeline.Event{}
+	}
+	return manager.timeline.Between(room.name, from, to)
+}
+
+// replayCursor follows one forwarder through a time window's timeline events
+type replayCursor struct {
+	events []timeline.Event
+	next   int
+	last   int64 // latest packet timestamp seen, ms
+}
+
+// replayCursor returns a cursor over the replay events of the time window the session watches, its
+// own or the room's, or nil outside time window playback
+func (c *Client) replayCursor() *replayCursor {
+	c.room.mu.Lock()
+	defer c.room.mu.Unlock()
+	events := c.room.replayEvents
+	if c.window != nil {
+		events = c.windowEvents
+	} else if c.room.currentCaptureMode != "time_window" {
+		return nil
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return &replayCursor{events: events}
+}
+
+// due returns the events that playback passed on reaching a packet stamped ts (ms). After a seek
+// back, events later than ts are played again.
+func (c *replayCursor) due(ts int64) []timeline.Event {
+	if c == nil {
+		return nil
+	}
+	if ts+replayRewindMillis < c.last {
+		c.next = sort.Search(len(c.events), func(i int) bool { return c.events[i].Time.UnixMilli() > ts })
+		c.last = ts
+		return nil
+	}
+	if ts > c.last {
+		c.last = ts
+	}
+	start := c.next
+	for c.next < len(c.events) && c.events[c.next].Time.UnixMilli() <= ts {
+		c.next++
+	}
+	return c.events[start:c.next]
+}
+
+// handleTimeline serves GET /api/timeline?from=&to= (RFC 3339), filtered by room (its events and
+// server-wide ones) and kind (annotation, alert, scan, mode), most recent limit events, oldest first
+func (manager *ClientManager) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if manager.timeline == nil {
+		http.Error(w, "timeline disabled (-timeline)", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	q := timeline.Query{
+		Room: query.Get("room"),
+		Kind: query.Get("kind"),
+	}
+	for _, bound := range []struct {
+		name string
+		into *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if value := query.Get(bound.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "invalid "+bound.name+" (RFC3339 required): "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*bound.into = t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		q.Limit = limit
+	}
+	switch q.Kind {
+	case "", timeline.KindAnnotation, timeline.KindAlert, timeline.KindScan, timeline.KindMode:
+	default:
+		http.Error(w, "kind must be annotation, alert, scan or mode", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(manager.timeline.Query(q))
+}
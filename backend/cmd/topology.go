@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// observeNeighbor records an LLDP or CDP announcement heard by the session's live capture in the
+// topology. Announcements in replayed files are left out: they describe how the network was.
+func (manager *ClientManager) observeNeighbor(c *Client, packet *capture.Packet) {
+	live, ok := c.liveInterface()
+	if !ok {
+		return
+	}
+	neighbor := packet.Neighbor
+	if manager.topology.Observe(neighbor, packet.SrcMAC, live.Interface(), time.Now()) {
+		log.Printf("🧭 %s neighbor %s (%s) on %s, port %s", neighbor.Protocol, neighbor.SystemName, neighbor.ChassisID, live.Interface(), neighbor.PortID)
+	}
+}
+
+// handleTopology returns the switch, router and access point neighbors heard over LLDP and CDP,
+// and which of their ports each capture interface is plugged into, as an L2 skeleton the
+// frontend can anchor the force graph to: GET /api/topology
+func (manager *ClientManager) handleTopology(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(manager.topology.Snapshot(time.Now()))
+}
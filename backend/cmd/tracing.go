@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// Stages a packet passes through in a session's forwarder, after capture
+const (
+	stageEnrich    = "enrich"    // node_info, connection tracking, anomalies, recording
+	stageFilter    = "filter"    // room view and sampling
+	stageBroadcast = "broadcast" // encoding and queueing for the WebSocket
+)
+
+// Reasons a forwarder drops a packet instead of streaming it
+const (
+	dropFiltered  = "filtered"      // outside the room's view, or sampled out
+	dropQueueFull = "ws_queue_full" // the client's send queue was full
+	dropEncode    = "encode_failed" // the packet couldn't be encoded
+	dropDuplicate = "duplicate"     // a second copy from a mirrored tap (-dedup-window)
+)
+
+// pipelineTracer receives the timing of every packet's pass through a forwarder. The otel
+// build tag installs one that reports OpenTelemetry spans and metrics; without it tracing is off.
+type pipelineTracer interface {
+	Packet(trace *packetTrace)
+}
+
+// pipeline is the installed tracer, if any
+var pipeline pipelineTracer
+
+// packetTrace times one packet through the forwarder's stages. A nil trace does nothing, so
+// untraced builds pay one nil check per stage.
+type packetTrace struct {
+	Room    string
+	Packet  *capture.Packet
+	Backlog int // packets waiting in the capture channel when this one was taken
+	Start   time.Time
+	Stages  []stageTiming
+	Dropped string // drop reason; empty when the packet was streamed
+	End     time.Time
+}
+
+// stageTiming is when a stage ended; it started when the previous one did
+type stageTiming struct {
+	Name string
+	End  time.Time
+}
+
+// tracePacket starts timing a packet the forwarder has just taken from its capture
+func tracePacket(client *Client, packet *capture.Packet, backlog int) *packetTrace {
+	if pipeline == nil {
+		return nil
+	}
+	return &packetTrace{
+		Room:    client.room.name,
+		Packet:  packet,
+		Backlog: backlog,
+		Start:   time.Now(),
+		Stages:  make([]stageTiming, 0, 3),
+	}
+}
+
+// stage marks the end of a stage
+func (t *packetTrace) stage(name string) {
+	if t != nil {
+		t.Stages = append(t.Stages, stageTiming{Name: name, End: time.Now()})
+	}
+}
+
+// drop records why the packet went no further
+func (t *packetTrace) drop(reason string) {
+	if t != nil {
+		t.Dropped = reason
+	}
+}
+
+// finish hands the timing to the tracer
+func (t *packetTrace) finish() {
+	if t != nil {
+		t.End = time.Now()
+		pipeline.Packet(t)
+	}
+}
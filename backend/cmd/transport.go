@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// sessionConn carries one session's messages: a WebSocket, or a WebTransport session when the
+// server is built with -tags webtransport (see webtransport.go)
+type sessionConn interface {
+	RemoteAddr() net.Addr
+	Close() error
+	// transport names the kind of connection for /api/sessions
+	transport() string
+	// writeText sends one message, giving up after writeWait
+	writeText(message []byte) error
+	// writePing keeps an idle connection alive, finds peers that went away and times the round trip
+	writePing() error
+	// pingStats returns the latest ping round trip and when its pong came back; zero without pings
+	pingStats() (time.Duration, time.Time)
+	// writeClose tells the peer no more messages are coming
+	writeClose() error
+	// readText blocks for the next command; an error means the session is over
+	readText() ([]byte, error)
+}
+
+// wsConn is a session over a WebSocket. Pings carry the time they were sent, which the
+// browser echoes in its pong.
+type wsConn struct {
+	conn     *websocket.Conn
+	rtt      atomic.Int64 // nanoseconds
+	lastPong atomic.Int64 // unix nanoseconds
+}
+
+func newWSConn(conn *websocket.Conn) *wsConn {
+	c := &wsConn{conn: conn}
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(payload string) error {
+		now := time.Now()
+		conn.SetReadDeadline(now.Add(pongWait))
+		if sent, err := strconv.ParseInt(payload, 10, 64); err == nil {
+			c.rtt.Store(int64(now.Sub(time.Unix(0, sent))))
+			c.lastPong.Store(now.UnixNano())
+		}
+		return nil
+	})
+	return c
+}
+
+func (c *wsConn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+func (c *wsConn) Close() error         { return c.conn.Close() }
+func (c *wsConn) transport() string    { return "websocket" }
+
+func (c *wsConn) writeText(message []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+func (c *wsConn) writeBinary(frame []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func (c *wsConn) writePing() error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.PingMessage, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)))
+}
+
+func (c *wsConn) pingStats() (time.Duration, time.Time) {
+	lastPong := c.lastPong.Load()
+	if lastPong == 0 {
+		return 0, time.Time{}
+	}
+	return time.Duration(c.rtt.Load()), time.Unix(0, lastPong)
+}
+
+func (c *wsConn) writeClose() error {
+	return c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+}
+
+func (c *wsConn) readText() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	return message, err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"vibes-network-visualizer/internal/notify"
+)
+
+// notifyAll hands a batch of alerts (tcp_anomaly, size_anomaly, ...) raised on a room's traffic
+// to the webhooks. Every session in the room raises the same ones; the notifier posts each once.
+func notifyAll[T outboundMessage](manager *ClientManager, room *Room, msgs []T) {
+	if manager.notifier.Load() == nil {
+		return
+	}
+	for _, msg := range msgs {
+		if data, err := msg.ToJSON(); err == nil {
+			manager.notifyJSON(data, room.name)
+		}
+	}
+}
+
+// notifyJSON hands one alert message to the webhooks; room is empty for server-wide alerts
+func (manager *ClientManager) notifyJSON(data []byte, room string) {
+	notifier := manager.notifier.Load()
+	if notifier == nil {
+		return
+	}
+	alert, err := notify.FromMessage(data, room)
+	if err != nil {
+		log.Printf("⚠️ Webhooks: unreadable alert: %v", err)
+		return
+	}
+	// Screens and the timeline still get alerts a schedule window mutes
+	if manager.scheduled.Load().Muted(alert.Type, alert.Kind) {
+		return
+	}
+	notifier.Notify(alert)
+}
+
+// handleWebhooks reports the configured webhooks (GET /api/webhooks) and sends a test alert
+// through one (POST /api/webhooks/{name}/test). URLs and headers are never shown: they
+// usually hold the channel's secret.
+func (manager *ClientManager) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/webhooks"), "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"webhooks": manager.notifier.Load().Status(),
+		})
+	case strings.HasSuffix(path, "/test") && r.Method == http.MethodPost:
+		name := strings.TrimSuffix(path, "/test")
+		if err := manager.notifier.Load().Test(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		manager.auditRequest(r, "webhook_test", "", map[string]interface{}{"webhook": name})
+		json.NewEncoder(w).Encode(map[string]interface{}{"queued": true, "webhook": name})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
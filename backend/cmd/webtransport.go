@@ -0,0 +1,239 @@
+//go:build webtransport
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+var (
+	webTransportAddr = flag.String("webtransport", "", "also serve sessions over WebTransport (HTTP/3 on UDP) on this address, e.g. :4433; packets travel as datagrams, so one lost on the WiFi doesn't hold up the rest (experimental)")
+	webTransportCert = flag.String("webtransport-cert", "", "TLS certificate for -webtransport (default: a self-signed one, renewed weekly, whose hash /api/webtransport publishes for browsers to pin)")
+	webTransportKey  = flag.String("webtransport-key", "", "TLS key for -webtransport-cert")
+)
+
+const (
+	// maxDatagramMessage is the largest packet message sent as a datagram; the rest of a QUIC
+	// packet's ~1200 bytes go to headers. Larger ones take the stream.
+	maxDatagramMessage = 1100
+	// streamAcceptWait is how long a new session has to open its message stream
+	streamAcceptWait = 10 * time.Second
+	// selfSignedLifetime is under the 14 days browsers accept for a pinned certificate hash
+	selfSignedLifetime = 10 * 24 * time.Hour
+	selfSignedRenew    = 7 * 24 * time.Hour
+)
+
+// packetPrefix starts every packet message; those go out as datagrams
+var packetPrefix = []byte(`{"type":"packet"`)
+
+func init() {
+	auxServers = append(auxServers, startWebTransport)
+}
+
+func startWebTransport(manager *ClientManager) error {
+	if *webTransportAddr == "" {
+		return nil
+	}
+	_, portText, err := net.SplitHostPort(*webTransportAddr)
+	if err != nil {
+		return fmt.Errorf("-webtransport %s: %v", *webTransportAddr, err)
+	}
+	port, _ := strconv.Atoi(portText)
+
+	tlsConfig := &tls.Config{}
+	var certs *selfSignedCerts
+	switch {
+	case *webTransportCert != "" && *webTransportKey != "":
+		cert, err := tls.LoadX509KeyPair(*webTransportCert, *webTransportKey)
+		if err != nil {
+			return fmt.Errorf("-webtransport-cert: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case *webTransportCert != "" || *webTransportKey != "":
+		return fmt.Errorf("-webtransport-cert and -webtransport-key go together")
+	default:
+		certs = &selfSignedCerts{}
+		if _, err := certs.current(); err != nil {
+			return fmt.Errorf("-webtransport certificate: %v", err)
+		}
+		tlsConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.current()
+		}
+	}
+
+	mux := http.NewServeMux()
+	server := &webtransport.Server{
+		H3: http3.Server{Addr: *webTransportAddr, Handler: mux, TLSConfig: tlsConfig},
+		CheckOrigin: func(r *http.Request) bool {
+			return true // Allow all origins, like the WebSocket
+		},
+	}
+	mux.HandleFunc("/wt", func(w http.ResponseWriter, r *http.Request) {
+		manager.serveSession(w, r, func() (sessionConn, error) {
+			session, err := server.Upgrade(w, r)
+			if err != nil {
+				return nil, err
+			}
+			return newWTConn(session)
+		})
+	})
+
+	// Browsers discover the transport from the HTTP server they loaded the page from
+	http.HandleFunc("/api/webtransport", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		info := map[string]interface{}{"port": port, "path": "/wt"}
+		if certs != nil {
+			if _, err := certs.current(); err == nil {
+				info["cert_hash"], info["cert_expires"] = certs.hashAndExpiry()
+			}
+		}
+		json.NewEncoder(w).Encode(info)
+	})
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("⚠️ WebTransport server stopped: %v", err)
+		}
+	}()
+	log.Printf("🚀 WebTransport sessions on %s (UDP), path /wt", *webTransportAddr)
+	return nil
+}
+
+// wtConn is a session over WebTransport. Packet messages go out as datagrams: unordered and
+// never retransmitted, so a loss costs one packet instead of stalling everything behind it.
+// Every other message, and every command, is a line of JSON on the bidirectional stream the
+// browser opens first.
+type wtConn struct {
+	session *webtransport.Session
+	stream  webtransport.Stream
+	reader  *bufio.Reader
+}
+
+func newWTConn(session *webtransport.Session) (*wtConn, error) {
+	ctx, cancel := context.WithTimeout(session.Context(), streamAcceptWait)
+	defer cancel()
+	stream, err := session.AcceptStream(ctx)
+	if err != nil {
+		session.CloseWithError(0, "no message stream opened")
+		return nil, fmt.Errorf("WebTransport session from %s opened no stream: %v", session.RemoteAddr(), err)
+	}
+	return &wtConn{
+		session: session,
+		stream:  stream,
+		reader:  bufio.NewReaderSize(stream, maxMessageSize),
+	}, nil
+}
+
+func (c *wtConn) RemoteAddr() net.Addr { return c.session.RemoteAddr() }
+func (c *wtConn) Close() error         { return c.session.CloseWithError(0, "") }
+func (c *wtConn) transport() string    { return "webtransport" }
+
+func (c *wtConn) writeText(message []byte) error {
+	if len(message) <= maxDatagramMessage && bytes.HasPrefix(message, packetPrefix) {
+		// A datagram too large for the path falls back to the stream
+		if err := c.session.SendDatagram(message); err == nil {
+			return nil
+		}
+	}
+	line := make([]byte, len(message)+1)
+	copy(line, message)
+	line[len(message)] = '\n'
+	c.stream.SetWriteDeadline(time.Now().Add(writeWait))
+	_, err := c.stream.Write(line)
+	return err
+}
+
+// writePing does nothing; QUIC keeps the connection alive and times out peers that vanish
+func (c *wtConn) writePing() error { return nil }
+
+func (c *wtConn) pingStats() (time.Duration, time.Time) { return 0, time.Time{} }
+
+func (c *wtConn) writeClose() error { return c.stream.Close() }
+
+func (c *wtConn) readText() ([]byte, error) {
+	for {
+		line, err := c.reader.ReadSlice('\n')
+		if err != nil {
+			// Includes a command longer than maxMessageSize
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		// The browser may open the stream with an empty line
+		if len(line) > 0 {
+			return append([]byte(nil), line...), nil
+		}
+	}
+}
+
+// selfSignedCerts issues the short-lived certificate browsers accept by hash
+// (serverCertificateHashes) without a CA, renewing it before it runs out
+type selfSignedCerts struct {
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	hash    []byte
+	expires time.Time
+}
+
+func (s *selfSignedCerts) current() (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cert != nil && time.Until(s.expires) > selfSignedLifetime-selfSignedRenew {
+		return s.cert, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "vibes"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(der)
+	s.cert = &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	s.hash, s.expires = hash[:], template.NotAfter
+	log.Printf("🔐 WebTransport self-signed certificate, sha-256 %x, valid until %s", s.hash, s.expires.Format(time.RFC3339))
+	return s.cert, nil
+}
+
+// hashAndExpiry returns the current certificate's SHA-256 (base64 in JSON) and expiry
+func (s *selfSignedCerts) hashAndExpiry() ([]byte, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hash, s.expires
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// wireReplay is the capture file being re-transmitted with -replay-iface, if any
+var wireReplay *capture.WireReplay
+
+// startWireReplay opens -replay-iface (before privileges are dropped) and re-transmits the
+// capture file onto it in the background
+func startWireReplay() error {
+	file := *replayFile
+	if file == "" {
+		file = *pcapFile
+	}
+	if file == "" {
+		return fmt.Errorf("-replay-iface needs -replay-file or -pcap")
+	}
+	replay, err := capture.NewWireReplay(capture.WireReplayConfig{
+		File:      file,
+		Interface: *replayIface,
+		Speed:     *replayWireSpeed,
+		PPS:       *replayPPS,
+		Mbps:      *replayMbps,
+		Loops:     *replayLoops,
+	})
+	if err != nil {
+		return err
+	}
+	wireReplay = replay
+	status := replay.Status()
+	log.Printf("📤 Re-transmitting %s onto %s at %s", status.File, status.Interface, status.Pace)
+
+	go func() {
+		err := replay.Run(make(chan struct{}))
+		status := replay.Status()
+		if err != nil {
+			log.Printf("⚠️ Wire replay of %s stopped: %v", status.File, err)
+			return
+		}
+		log.Printf("📤 Wire replay of %s finished: %d packets (%d bytes) in %d passes, %d failed",
+			status.File, status.Packets, status.Bytes, status.Pass, status.Failed)
+	}()
+	return nil
+}
+
+// handleWireReplay reports the re-transmission started with -replay-iface: GET /api/replay
+func handleWireReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if wireReplay == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"enabled": false})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": true,
+		"status":  wireReplay.Status(),
+	})
+}
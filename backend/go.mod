@@ -5,9 +5,10 @@ go 1.21
 require (
 	github.com/google/gopacket v1.1.19
 	github.com/gorilla/websocket v1.5.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/c-robinson/iplib v1.0.8
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
 )
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultQueryLimit is how many entries a query returns when it doesn't say
+const DefaultQueryLimit = 200
+
+// Entry records one control action: who did what, where, with which parameters
+type Entry struct {
+	Time   time.Time              `json:"time"`
+	Actor  string                 `json:"actor"` // remote address of the session or API caller
+	Via    string                 `json:"via"`   // "websocket" or "http"
+	Action string                 `json:"action"`
+	Room   string                 `json:"room,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// Query selects entries; zero fields match everything
+type Query struct {
+	Since  time.Time
+	Until  time.Time
+	Actor  string
+	Action string
+	Room   string
+	Limit  int // most recent matches returned; DefaultQueryLimit when zero
+}
+
+func (q *Query) matches(e *Entry) bool {
+	switch {
+	case !q.Since.IsZero() && e.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && e.Time.After(q.Until):
+		return false
+	case q.Actor != "" && e.Actor != q.Actor:
+		return false
+	case q.Action != "" && e.Action != q.Action:
+		return false
+	case q.Room != "" && e.Room != q.Room:
+		return false
+	}
+	return true
+}
+
+// Log appends entries to a JSON-lines file. The file is only ever appended to, so it can be
+// handed over after an event as the record of who changed what.
+type Log struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens (or creates) the audit file for appending
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log %s: %v", path, err)
+	}
+	return &Log{path: path, file: file}, nil
+}
+
+// Path returns the audit file's path
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends an entry and flushes it to disk; Time defaults to now
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(line); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Query returns the most recent entries matching q, oldest first
+func (l *Log) Query(q Query) ([]Entry, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Keep a ring of the last Limit matches while scanning the whole file
+	ring := make([]Entry, 0, q.Limit)
+	next := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // a line cut short by a crash
+		}
+		if !q.matches(&e) {
+			continue
+		}
+		if len(ring) < q.Limit {
+			ring = append(ring, e)
+		} else {
+			ring[next] = e
+			next = (next + 1) % q.Limit
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return append(ring[next:], ring[:next]...), nil
+}
+
+// Close closes the audit file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,191 @@
+package capture
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcap"
+)
+
+const archiveSnaplen = 65535
+
+// ArchiveCaptureConfig configures native capture-to-disk on one interface
+type ArchiveCaptureConfig struct {
+	Interface   string
+	Dir         string
+	RotateEvery time.Duration // start a new file after this long (0 = no time rotation)
+	RotateBytes int64         // start a new file after this many bytes (0 = no size rotation)
+}
+
+// ArchiveCapture streams live packets from an interface that is also being written to rotating
+// PCAP files in-process, replacing an external dumpcap. All WebSocket clients share one capture
+// per interface; the archive keeps running when no browser is connected.
+type ArchiveCapture struct {
+	iface      string
+	packetChan chan *Packet
+	hub        *archiveHub
+	running    bool
+	mu         sync.Mutex
+}
+
+// NewArchiveCapture creates a subscriber to the archive capture on iface (see StartArchiveCapture)
+func NewArchiveCapture(iface string) *ArchiveCapture {
+	return &ArchiveCapture{
+		iface:      iface,
+		packetChan: make(chan *Packet, 10000),
+	}
+}
+
+// Start subscribes to the shared capture; it fails if StartArchiveCapture wasn't called for the interface
+func (a *ArchiveCapture) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running {
+		return fmt.Errorf("archive capture already running")
+	}
+	v, ok := archiveHubRegistry.Load(a.iface)
+	if !ok {
+		return fmt.Errorf("no archive capture running on interface %s", a.iface)
+	}
+	a.hub = v.(*archiveHub)
+	a.hub.subscribe(a.packetChan)
+	a.running = true
+	return nil
+}
+
+// Stop unsubscribes; capture to disk continues
+func (a *ArchiveCapture) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running {
+		return fmt.Errorf("archive capture not running")
+	}
+	a.hub.unsubscribe(a.packetChan)
+	a.running = false
+	return nil
+}
+
+// GetPacketChannel returns the channel to receive packets
+func (a *ArchiveCapture) GetPacketChannel() <-chan *Packet {
+	return a.packetChan
+}
+
+// --- shared hub (one capture handle and writer per interface, fan-out to subscribers) ---
+
+var archiveHubRegistry sync.Map // string iface -> *archiveHub
+
+type archiveHub struct {
+	mu     sync.Mutex
+	config ArchiveCaptureConfig
+	handle *pcap.Handle
+	writer *RotatingWriter
+	subs   map[chan *Packet]struct{}
+}
+
+// StartArchiveCapture opens the interface and begins writing rotating PCAP files.
+// Call once at startup; clients then attach with NewArchiveCapture.
+func StartArchiveCapture(config ArchiveCaptureConfig) error {
+	if _, loaded := archiveHubRegistry.Load(config.Interface); loaded {
+		return fmt.Errorf("archive capture already running on %s", config.Interface)
+	}
+
+	inactive, err := pcap.NewInactiveHandle(config.Interface)
+	if err != nil {
+		return fmt.Errorf("error creating inactive handle for %s: %v", config.Interface, err)
+	}
+	defer inactive.CleanUp()
+	if err := inactive.SetSnapLen(archiveSnaplen); err != nil {
+		return err
+	}
+	if err := inactive.SetPromisc(true); err != nil {
+		return err
+	}
+	if err := inactive.SetTimeout(pcap.BlockForever); err != nil {
+		return err
+	}
+	// A large kernel buffer absorbs bursts while a file is being rotated
+	if err := inactive.SetBufferSize(64 * 1024 * 1024); err != nil {
+		log.Printf("Warning: couldn't set capture buffer size: %v", err)
+	}
+	handle, err := inactive.Activate()
+	if err != nil {
+		return fmt.Errorf("error activating capture on device %s: %v (may need root)", config.Interface, err)
+	}
+
+	writer, err := NewRotatingWriter(config.Dir, "capture_"+config.Interface, config.RotateEvery, config.RotateBytes, handle.LinkType(), archiveSnaplen)
+	if err != nil {
+		handle.Close()
+		return err
+	}
+
+	hub := &archiveHub{
+		config: config,
+		handle: handle,
+		writer: writer,
+		subs:   make(map[chan *Packet]struct{}),
+	}
+	archiveHubRegistry.Store(config.Interface, hub)
+	go hub.run()
+
+	log.Printf("💾 Native capture to disk on %s → %s (rotate every %s or %d MB)",
+		config.Interface, config.Dir, config.RotateEvery, config.RotateBytes/(1024*1024))
+	return nil
+}
+
+func (h *archiveHub) run() {
+	linkType := h.handle.LinkType()
+	var writeErrors uint64
+
+	for {
+		data, ci, err := h.handle.ReadPacketData()
+		if err != nil {
+			log.Printf("Error reading packet: %v", err)
+			time.Sleep(100 * time.Millisecond) // interface down; don't spin
+			continue
+		}
+
+		// Every frame goes to disk, IPv4 or not
+		if err := h.writer.WritePacket(ci, data); err != nil {
+			writeErrors++
+			if writeErrors == 1 || writeErrors%10000 == 0 {
+				log.Printf("⚠️ Capture file write failed (%d errors): %v", writeErrors, err)
+			}
+		}
+
+		packet := gopacket.NewPacket(data, linkType, gopacket.Default)
+		packet.Metadata().CaptureInfo = ci
+		p := decodePacket(packet)
+		if p == nil {
+			continue
+		}
+		p.Source = "real"
+		h.broadcast(p)
+	}
+}
+
+func (h *archiveHub) subscribe(ch chan *Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[ch] = struct{}{}
+}
+
+func (h *archiveHub) unsubscribe(ch chan *Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, ch)
+}
+
+func (h *archiveHub) broadcast(p *Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- p:
+		default:
+			// drop if client is slow; never stall the writer
+		}
+	}
+}
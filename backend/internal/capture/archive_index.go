@@ -0,0 +1,442 @@
+package capture
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TimeRange is a span of archived traffic
+type TimeRange struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Files   int       `json:"files"`
+	Packets int64     `json:"packets"`
+}
+
+// ArchiveIndex maps every capture file in the archive store to the time span it covers. A
+// background scanner keeps it current; unchanged files (same size and mtime) are not re-read.
+type ArchiveIndex struct {
+	mu        sync.RWMutex
+	store     ArchiveStore
+	indexPath string
+	files     map[string]*CaptureIndex
+	manifests map[string]struct{} // files whose sidecar manifest is current
+	lastScan  time.Time
+	stopChan  chan struct{}
+}
+
+// NewArchiveIndex creates an index over an archive store, loading a previously persisted index
+// from indexPath if present. indexPath may be empty to keep the index in memory only.
+func NewArchiveIndex(store ArchiveStore, indexPath string) *ArchiveIndex {
+	idx := &ArchiveIndex{
+		store:     store,
+		indexPath: indexPath,
+		files:     make(map[string]*CaptureIndex),
+		manifests: make(map[string]struct{}),
+		stopChan:  make(chan struct{}),
+	}
+	if indexPath != "" {
+		if err := idx.load(); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Archive index load failed: %v", err)
+		}
+	}
+	return idx
+}
+
+// Start scans immediately and then every interval until Stop
+func (idx *ArchiveIndex) Start(interval time.Duration) {
+	go func() {
+		idx.Scan()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				idx.Scan()
+			case <-idx.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background scanner
+func (idx *ArchiveIndex) Stop() {
+	close(idx.stopChan)
+}
+
+// Scan walks the archive store, indexing new or modified files and dropping deleted ones. A
+// file with a current sidecar manifest is taken from it; one that has settled after rotation
+// gets a manifest written.
+func (idx *ArchiveIndex) Scan() {
+	seen := make(map[string]struct{})
+	var changed int
+
+	err := idx.store.Walk(func(obj ArchiveObject) {
+		seen[obj.Path] = struct{}{}
+
+		idx.mu.RLock()
+		existing, ok := idx.files[obj.Path]
+		_, manifested := idx.manifests[obj.Path]
+		idx.mu.RUnlock()
+		// Entries from before byte counts and per-minute histograms were kept are read again once
+		if ok && existing.FileSize == obj.Size && existing.ModTime.Equal(obj.ModTime) &&
+			(existing.Bytes != 0 || existing.PacketCount == 0) {
+			if !manifested {
+				idx.saveManifest(obj, existing)
+			}
+			return
+		}
+
+		entry, fromManifest := loadManifest(idx.store, obj)
+		if !fromManifest {
+			var err error
+			if entry, err = indexArchiveFile(idx.store, obj); err != nil {
+				log.Printf("⚠️ Archive index: skipping %s: %v", filepath.Base(obj.Path), err)
+				return
+			}
+		}
+		idx.mu.Lock()
+		idx.files[obj.Path] = entry
+		delete(idx.manifests, obj.Path)
+		if fromManifest {
+			idx.manifests[obj.Path] = struct{}{}
+		}
+		idx.mu.Unlock()
+		if !fromManifest {
+			idx.saveManifest(obj, entry)
+		}
+		changed++
+	})
+	if err != nil {
+		// A store that can't be listed (a bucket out of reach) keeps the entries it had
+		log.Printf("⚠️ Archive index: listing %s failed: %v", idx.store.Location(), err)
+		return
+	}
+
+	idx.mu.Lock()
+	for path := range idx.files {
+		if _, ok := seen[path]; !ok {
+			delete(idx.files, path)
+			delete(idx.manifests, path)
+			changed++
+		}
+	}
+	idx.lastScan = time.Now()
+	idx.mu.Unlock()
+
+	if changed > 0 {
+		log.Printf("🗂️ Archive index updated: %d changes, %d files", changed, idx.Len())
+		idx.save()
+	}
+}
+
+// saveManifest writes the sidecar manifest of a settled file, once
+func (idx *ArchiveIndex) saveManifest(obj ArchiveObject, entry *CaptureIndex) {
+	if !saveManifest(idx.store, obj, entry) {
+		return
+	}
+	idx.mu.Lock()
+	idx.manifests[obj.Path] = struct{}{}
+	idx.mu.Unlock()
+}
+
+// Len returns the number of indexed files
+func (idx *ArchiveIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.files)
+}
+
+// LastScan returns when the scanner last finished
+func (idx *ArchiveIndex) LastScan() time.Time {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.lastScan
+}
+
+// Files returns all indexed files ordered by first packet
+func (idx *ArchiveIndex) Files() []CaptureIndex {
+	idx.mu.RLock()
+	files := make([]CaptureIndex, 0, len(idx.files))
+	for _, f := range idx.files {
+		files = append(files, *f)
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(files, func(i, j int) bool { return files[i].StartTime.Before(files[j].StartTime) })
+	return files
+}
+
+// File returns the index entry of one file
+func (idx *ArchiveIndex) File(path string) (CaptureIndex, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	f, ok := idx.files[path]
+	if !ok {
+		return CaptureIndex{}, false
+	}
+	return *f, true
+}
+
+// FilesFor returns the paths of files with packets inside [start, end], ordered by first packet
+func (idx *ArchiveIndex) FilesFor(start, end time.Time) []string {
+	var paths []string
+	for _, f := range idx.Files() {
+		if f.PacketCount > 0 && !f.EndTime.Before(start) && !f.StartTime.After(end) {
+			paths = append(paths, f.FilePath)
+		}
+	}
+	return paths
+}
+
+// Coverage merges indexed files into continuous time ranges. Files separated by no more than
+// maxGap are treated as contiguous (rotation leaves small gaps between files).
+func (idx *ArchiveIndex) Coverage(maxGap time.Duration) []TimeRange {
+	ranges := []TimeRange{}
+	for _, f := range idx.Files() {
+		if f.PacketCount == 0 {
+			continue
+		}
+		if n := len(ranges); n > 0 && !f.StartTime.After(ranges[n-1].End.Add(maxGap)) {
+			last := &ranges[n-1]
+			if f.EndTime.After(last.End) {
+				last.End = f.EndTime
+			}
+			last.Files++
+			last.Packets += f.PacketCount
+			continue
+		}
+		ranges = append(ranges, TimeRange{Start: f.StartTime, End: f.EndTime, Files: 1, Packets: f.PacketCount})
+	}
+	return ranges
+}
+
+// indexArchiveFile reads a capture file once to record its packet and byte counts, time span,
+// traffic per minute and protocol, and busiest addresses
+func indexArchiveFile(store ArchiveStore, obj ArchiveObject) (*CaptureIndex, error) {
+	handle, err := store.OpenCapture(obj.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer handle.Close()
+
+	entry := &CaptureIndex{FilePath: obj.Path, FileSize: obj.Size, ModTime: obj.ModTime}
+	minutes := make(map[int64]*MinuteCount)
+	addrs := make(map[string]*IPCount)
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err != nil {
+			// io.EOF, or a truncated tail on a file still being written
+			if err != io.EOF && entry.PacketCount == 0 {
+				return nil, err
+			}
+			break
+		}
+		if entry.PacketCount == 0 || ci.Timestamp.Before(entry.StartTime) {
+			entry.StartTime = ci.Timestamp
+		}
+		if ci.Timestamp.After(entry.EndTime) {
+			entry.EndTime = ci.Timestamp
+		}
+		entry.PacketCount++
+		entry.Bytes += int64(ci.Length)
+
+		proto, src, dst := indexedPacket(data, handle.LinkType())
+		for _, ip := range [2]string{src, dst} {
+			if ip == "" {
+				continue
+			}
+			count, ok := addrs[ip]
+			if !ok {
+				count = &IPCount{IP: ip}
+				addrs[ip] = count
+			}
+			count.Packets++
+			count.Bytes += int64(ci.Length)
+		}
+
+		minute := ci.Timestamp.Truncate(time.Minute).UnixMilli()
+		count, ok := minutes[minute]
+		if !ok {
+			count = &MinuteCount{Minute: minute, Protocols: make(map[string]int64)}
+			minutes[minute] = count
+		}
+		count.Packets++
+		count.Protocols[proto]++
+	}
+	entry.TopIPs = topIPs(addrs, maxManifestIPs)
+
+	entry.Minutes = make([]MinuteCount, 0, len(minutes))
+	for _, count := range minutes {
+		entry.Minutes = append(entry.Minutes, *count)
+	}
+	sort.Slice(entry.Minutes, func(i, j int) bool { return entry.Minutes[i].Minute < entry.Minutes[j].Minute })
+	return entry, nil
+}
+
+// indexedPacket names a frame's protocol the way packets do, and its addresses, decoding no
+// further than its IP header. Frames without IP have no addresses.
+func indexedPacket(data []byte, linkType layers.LinkType) (proto, src, dst string) {
+	packet := gopacket.NewPacket(data, linkType, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		return ipProtocolName(ip.Protocol), ip.SrcIP.String(), ip.DstIP.String()
+	case *layers.IPv6:
+		return ProtocolOther, ip.SrcIP.String(), ip.DstIP.String()
+	}
+	return ProtocolOther, "", ""
+}
+
+func (idx *ArchiveIndex) load() error {
+	data, err := os.ReadFile(idx.indexPath)
+	if err != nil {
+		return err
+	}
+	var files []*CaptureIndex
+	if err := json.Unmarshal(data, &files); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, f := range files {
+		idx.files[f.FilePath] = f
+	}
+	log.Printf("🗂️ Loaded archive index with %d files from %s", len(files), idx.indexPath)
+	return nil
+}
+
+func (idx *ArchiveIndex) save() {
+	if idx.indexPath == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(idx.Files(), "", "  ")
+	if err != nil {
+		log.Printf("⚠️ Archive index save failed: %v", err)
+		return
+	}
+	tmp := idx.indexPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("⚠️ Archive index save failed: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, idx.indexPath); err != nil {
+		log.Printf("⚠️ Archive index save failed: %v", err)
+	}
+}
+
+// Gap is a stretch of a requested window with no archived packets
+type Gap struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// WindowCoverage describes how much of a requested time window the archive can play back
+type WindowCoverage struct {
+	Start        time.Time   `json:"start"`
+	End          time.Time   `json:"end"`
+	Covered      []TimeRange `json:"covered"`
+	Gaps         []Gap       `json:"gaps"`
+	CoveredRatio float64     `json:"covered_ratio"` // 0..1 of the window's duration
+	Complete     bool        `json:"complete"`
+}
+
+// CoverageFor clips the archive coverage to [start, end] and lists the holes in between
+func (idx *ArchiveIndex) CoverageFor(start, end time.Time, maxGap time.Duration) *WindowCoverage {
+	wc := &WindowCoverage{Start: start, End: end, Covered: []TimeRange{}, Gaps: []Gap{}}
+
+	cursor := start
+	var covered time.Duration
+	for _, rng := range idx.Coverage(maxGap) {
+		if rng.End.Before(start) || rng.Start.After(end) {
+			continue
+		}
+		if rng.Start.Before(start) {
+			rng.Start = start
+		}
+		if rng.End.After(end) {
+			rng.End = end
+		}
+		if rng.Start.Sub(cursor) > maxGap {
+			wc.Gaps = append(wc.Gaps, newGap(cursor, rng.Start))
+		}
+		wc.Covered = append(wc.Covered, rng)
+		covered += rng.End.Sub(rng.Start)
+		cursor = rng.End
+	}
+	if end.Sub(cursor) > maxGap {
+		wc.Gaps = append(wc.Gaps, newGap(cursor, end))
+	}
+
+	if window := end.Sub(start); window > 0 {
+		wc.CoveredRatio = float64(covered) / float64(window)
+		if wc.CoveredRatio > 1 {
+			wc.CoveredRatio = 1
+		}
+	}
+	wc.Complete = len(wc.Gaps) == 0 && len(wc.Covered) > 0
+	return wc
+}
+
+func newGap(start, end time.Time) Gap {
+	return Gap{Start: start, End: end, DurationMs: end.Sub(start).Milliseconds()}
+}
+
+// Heatmap is the archive's traffic per minute over a span, so a replay picker can show where the
+// busy stretches are. Packets[i] counts the minute starting Start + i minutes.
+type Heatmap struct {
+	Start     time.Time          `json:"start"`
+	Minutes   int                `json:"minutes"`
+	Packets   []int64            `json:"packets"`
+	Protocols map[string][]int64 `json:"protocols,omitempty"` // per-minute counts by protocol, when asked for
+	Files     int                `json:"files"`
+	Peak      int64              `json:"peak"` // the busiest minute's packets, for scaling colours
+}
+
+// Heatmap adds up the per-minute counts of the files overlapping [start, end)
+func (idx *ArchiveIndex) Heatmap(start, end time.Time, byProtocol bool) *Heatmap {
+	minutes := int(end.Sub(start) / time.Minute)
+	hm := &Heatmap{Start: start, Minutes: minutes, Packets: make([]int64, minutes)}
+	if byProtocol {
+		hm.Protocols = make(map[string][]int64)
+	}
+
+	for _, f := range idx.Files() {
+		if f.PacketCount == 0 || !f.StartTime.Before(end) || f.EndTime.Before(start) {
+			continue
+		}
+		hm.Files++
+		for _, count := range f.Minutes {
+			i := int(time.UnixMilli(count.Minute).Sub(start) / time.Minute)
+			if i < 0 || i >= minutes {
+				continue
+			}
+			hm.Packets[i] += count.Packets
+			if hm.Packets[i] > hm.Peak {
+				hm.Peak = hm.Packets[i]
+			}
+			if !byProtocol {
+				continue
+			}
+			for proto, n := range count.Protocols {
+				if hm.Protocols[proto] == nil {
+					hm.Protocols[proto] = make([]int64, minutes)
+				}
+				hm.Protocols[proto][i] += n
+			}
+		}
+	}
+	return hm
+}
@@ -0,0 +1,53 @@
+package capture
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// ArchiveObject is one capture file in an archive store
+type ArchiveObject struct {
+	Path    string // the store's own name for it: a file path, or s3://bucket/key
+	Size    int64
+	ModTime time.Time
+}
+
+// ArchiveStore is where archived capture files live, for time window playback, the archive index
+// and exports: a directory (DirStore) or an object storage bucket
+type ArchiveStore interface {
+	// Walk calls fn for every capture file in the store
+	Walk(fn func(ArchiveObject)) error
+	// OpenCapture opens one of the store's capture files by the path Walk gave it
+	OpenCapture(path string) (CaptureFile, error)
+	// Location names the store for logs and /api/archive/coverage
+	Location() string
+}
+
+// DirStore is an archive in a local directory tree
+type DirStore string
+
+// Walk calls fn for every capture file under the directory, subdirectories included
+func (d DirStore) Walk(fn func(ArchiveObject)) error {
+	return filepath.WalkDir(string(d), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !IsCaptureFile(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		fn(ArchiveObject{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+}
+
+// OpenCapture opens a capture file by its path
+func (d DirStore) OpenCapture(path string) (CaptureFile, error) {
+	return OpenCaptureFile(path)
+}
+
+// Location returns the directory
+func (d DirStore) Location() string {
+	return string(d)
+}
@@ -0,0 +1,100 @@
+package capture
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SkewThreshold is the smallest clock offset that gets corrected and reported; smaller
+// differences are indistinguishable from network and batching delay
+const SkewThreshold = 500 * time.Millisecond
+
+// clockSyncWindow is how many recent samples ClockSync keeps
+const clockSyncWindow = 64
+
+// ClockSync estimates how far a remote clock runs ahead of ours from (remote send time,
+// local receive time) pairs. Each sample is the true offset minus that message's transit
+// delay, so the largest recent sample is the best estimate.
+type ClockSync struct {
+	mu      sync.Mutex
+	samples [clockSyncWindow]int64
+	count   int
+	next    int
+}
+
+// Observe records one sample (Unix ms on both clocks) and returns the updated offset in ms
+func (c *ClockSync) Observe(remoteMs, localMs int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples[c.next] = remoteMs - localMs
+	c.next = (c.next + 1) % clockSyncWindow
+	if c.count < clockSyncWindow {
+		c.count++
+	}
+	return c.offsetLocked()
+}
+
+// Offset returns the current estimate in ms (remote minus local), or 0 before any sample
+func (c *ClockSync) Offset() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offsetLocked()
+}
+
+func (c *ClockSync) offsetLocked() int64 {
+	if c.count == 0 {
+		return 0
+	}
+	best := c.samples[0]
+	for _, sample := range c.samples[1:c.count] {
+		if sample > best {
+			best = sample
+		}
+	}
+	return best
+}
+
+// ClockSkew reports a clock offset that was detected and corrected in a merged or replayed stream
+type ClockSkew struct {
+	Type      string `json:"type"`             // always "clock_skew"
+	Source    string `json:"source"`           // "sensor" or "archive"
+	Sensor    string `json:"sensor,omitempty"` // relay agent, for source "sensor"
+	File      string `json:"file,omitempty"`   // capture file, for source "archive"
+	OffsetMs  int64  `json:"offset_ms"`        // subtracted from the source's timestamps
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ToJSON converts a skew report to JSON
+func (s *ClockSkew) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// monotonicClock keeps a replayed stream's timestamps from running backwards. A step back
+// larger than SkewThreshold (a file from a host with a different clock, or an NTP step) is
+// treated as skew: the difference becomes a running offset for the rest of the stream.
+// Smaller reorderings are clamped to the previous timestamp.
+type monotonicClock struct {
+	offset int64
+	last   int64
+}
+
+// normalize returns the corrected timestamp and whether a new skew was detected; the
+// running offset is then in m.offset
+func (m *monotonicClock) normalize(ts int64) (int64, bool) {
+	ts -= m.offset
+	skewed := false
+	if m.last != 0 && ts < m.last {
+		if step := m.last - ts; step > SkewThreshold.Milliseconds() {
+			m.offset -= step
+			skewed = true
+		}
+		ts = m.last
+	}
+	m.last = ts
+	return ts, skewed
+}
+
+func (m *monotonicClock) reset() {
+	m.offset, m.last = 0, 0
+}
@@ -0,0 +1,373 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connection states tracked by ConnTracker
+const (
+	ConnStateSynSent     = "syn_sent"
+	ConnStateSynReceived = "syn_received"
+	ConnStateEstablished = "established"
+	ConnStateClosing     = "closing"
+	ConnStateClosed      = "closed"
+)
+
+// Reasons reported in conn_close events
+const (
+	CloseReasonFin     = "fin"
+	CloseReasonReset   = "reset"
+	CloseReasonRefused = "refused" // SYN answered with RST
+	CloseReasonFailed  = "failed"  // handshake never completed
+	CloseReasonTimeout = "timeout" // idle established connection
+)
+
+// ConnEvent is streamed to clients when a TCP session opens or closes
+type ConnEvent struct {
+	Type        string `json:"type"` // "conn_open" or "conn_close"
+	ID          string `json:"id"`
+	Src         string `json:"src"` // connection initiator
+	Dst         string `json:"dst"`
+	SrcPort     int    `json:"src_port"`
+	DstPort     int    `json:"dst_port"`
+	Protocol    string `json:"protocol"`
+	Reason      string `json:"reason,omitempty"`
+	Midstream   bool   `json:"midstream,omitempty"` // first seen after the handshake
+	StartTime   int64  `json:"start_time"`
+	Timestamp   int64  `json:"timestamp"`
+	DurationMs  int64  `json:"duration_ms"`
+	BytesOut    int64  `json:"bytes_out"` // initiator → responder
+	BytesIn     int64  `json:"bytes_in"`  // responder → initiator
+	PacketsOut  int64  `json:"packets_out"`
+	PacketsIn   int64  `json:"packets_in"`
+	Retransmits int64  `json:"retransmits"`
+}
+
+// ToJSON converts a connection event to JSON
+func (e *ConnEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// ConnTrackerConfig holds timeouts and limits for connection tracking
+type ConnTrackerConfig struct {
+	HandshakeTimeout time.Duration // SYN without completion
+	IdleTimeout      time.Duration // established with no packets
+	ClosedLinger     time.Duration // keep closed entries so trailing ACKs don't look like new sessions
+	MaxConns         int
+}
+
+// DefaultConnTrackerConfig returns sensible defaults for a busy event network
+func DefaultConnTrackerConfig() ConnTrackerConfig {
+	return ConnTrackerConfig{
+		HandshakeTimeout: 30 * time.Second,
+		IdleTimeout:      5 * time.Minute,
+		ClosedLinger:     10 * time.Second,
+		MaxConns:         200000,
+	}
+}
+
+type connKey struct {
+	a, b         string
+	aPort, bPort int
+}
+
+// newConnKey orders endpoints so both directions map to the same key
+func newConnKey(p *Packet) connKey {
+	if p.Src < p.Dst || (p.Src == p.Dst && p.SrcPort <= p.DstPort) {
+		return connKey{p.Src, p.Dst, p.SrcPort, p.DstPort}
+	}
+	return connKey{p.Dst, p.Src, p.DstPort, p.SrcPort}
+}
+
+type connDirection struct {
+	bytes   int64
+	packets int64
+	nextSeq uint32 // highest sequence end seen
+	seqSet  bool
+	fin     bool
+}
+
+type tcpConn struct {
+	id         string
+	state      string
+	client     string
+	clientPort int
+	server     string
+	serverPort int
+	midstream  bool
+	start      int64
+	lastSeen   int64
+	out, in    connDirection
+	retrans    int64
+}
+
+// ConnTracker follows TCP handshakes and teardowns to turn packets into session lifecycles.
+// Observe must see every packet (before any sampling) for byte counts to be accurate.
+type ConnTracker struct {
+	mu     sync.Mutex
+	config ConnTrackerConfig
+	conns  map[connKey]*tcpConn
+	nextID uint64
+
+	tcpPackets  uint64
+	retransmits uint64
+
+	// Capture clock: replayed traffic carries historic timestamps, so timeouts
+	// run relative to the last packet seen rather than wall time
+	clockTS   int64
+	clockWall time.Time
+}
+
+// NewConnTracker creates a connection tracker
+func NewConnTracker(config ConnTrackerConfig) *ConnTracker {
+	return &ConnTracker{
+		config: config,
+		conns:  make(map[connKey]*tcpConn),
+	}
+}
+
+// Observe updates connection state for a packet and returns any resulting open/close events
+func (ct *ConnTracker) Observe(p *Packet) []*ConnEvent {
+	if p.Protocol != ProtocolTCP || p.TCPFlags == "" {
+		return nil
+	}
+
+	syn := strings.IndexByte(p.TCPFlags, 'S') >= 0
+	ack := strings.IndexByte(p.TCPFlags, 'A') >= 0
+	fin := strings.IndexByte(p.TCPFlags, 'F') >= 0
+	rst := strings.IndexByte(p.TCPFlags, 'R') >= 0
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.clockTS = p.Timestamp
+	ct.clockWall = time.Now()
+
+	key := newConnKey(p)
+	c, ok := ct.conns[key]
+	if !ok {
+		// Teardown for a session we never saw isn't worth a lifecycle
+		if rst || fin || len(ct.conns) >= ct.config.MaxConns {
+			return nil
+		}
+		c = ct.newConn(p, syn && !ack)
+		ct.conns[key] = c
+	}
+	if c.state == ConnStateClosed {
+		return nil
+	}
+
+	c.lastSeen = p.Timestamp
+	ct.tcpPackets++
+	fromClient := p.Src == c.client && p.SrcPort == c.clientPort
+	dir := &c.in
+	if fromClient {
+		dir = &c.out
+	}
+	dir.bytes += int64(p.Size)
+	dir.packets++
+	ct.trackSequence(c, dir, p, syn, fin)
+
+	var events []*ConnEvent
+	switch {
+	case rst:
+		reason := CloseReasonReset
+		if c.state == ConnStateSynSent || c.state == ConnStateSynReceived {
+			reason = CloseReasonRefused
+		}
+		events = append(events, ct.closeConn(c, reason, p.Timestamp))
+
+	case c.state == ConnStateSynSent && syn && ack && !fromClient:
+		c.state = ConnStateSynReceived
+
+	case c.state == ConnStateSynReceived && ack && !syn && fromClient:
+		c.state = ConnStateEstablished
+		events = append(events, ct.event("conn_open", c, "", p.Timestamp))
+
+	case c.state == ConnStateEstablished && c.midstream && dir.packets == 1 && c.in.packets+c.out.packets == 1:
+		// First packet of a session already in progress
+		events = append(events, ct.event("conn_open", c, "", p.Timestamp))
+	}
+
+	if fin && c.state != ConnStateClosed {
+		dir.fin = true
+		c.state = ConnStateClosing
+		if c.out.fin && c.in.fin {
+			events = append(events, ct.closeConn(c, CloseReasonFin, p.Timestamp))
+		}
+	}
+
+	return events
+}
+
+// Sweep expires idle and half-open connections and drops lingering closed entries
+func (ct *ConnTracker) Sweep() []*ConnEvent {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.clockWall.IsZero() {
+		return nil
+	}
+	now := ct.clockTS + time.Since(ct.clockWall).Milliseconds()
+
+	var events []*ConnEvent
+	for key, c := range ct.conns {
+		idle := time.Duration(now-c.lastSeen) * time.Millisecond
+		switch c.state {
+		case ConnStateClosed:
+			if idle > ct.config.ClosedLinger {
+				delete(ct.conns, key)
+			}
+		case ConnStateSynSent, ConnStateSynReceived:
+			if idle > ct.config.HandshakeTimeout {
+				events = append(events, ct.closeConn(c, CloseReasonFailed, now))
+			}
+		default:
+			if idle > ct.config.IdleTimeout {
+				events = append(events, ct.closeConn(c, CloseReasonTimeout, now))
+			}
+		}
+	}
+	return events
+}
+
+// ActiveCount returns the number of connections that are not yet closed
+func (ct *ConnTracker) ActiveCount() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	n := 0
+	for _, c := range ct.conns {
+		if c.state != ConnStateClosed {
+			n++
+		}
+	}
+	return n
+}
+
+// Totals returns cumulative tracked TCP packets and retransmissions
+func (ct *ConnTracker) Totals() (packets, retransmits uint64) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.tcpPackets, ct.retransmits
+}
+
+// FlowHealth summarizes retransmissions on a single tracked connection
+type FlowHealth struct {
+	ID             string  `json:"id"`
+	Src            string  `json:"src"`
+	Dst            string  `json:"dst"`
+	SrcPort        int     `json:"src_port"`
+	DstPort        int     `json:"dst_port"`
+	Packets        int64   `json:"packets"`
+	Retransmits    int64   `json:"retransmits"`
+	RetransmitRate float64 `json:"retransmit_rate"`
+}
+
+// FlowHealth returns retransmission rates for open connections with at least minPackets packets
+func (ct *ConnTracker) FlowHealth(minPackets int64) []FlowHealth {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	var flows []FlowHealth
+	for _, c := range ct.conns {
+		packets := c.out.packets + c.in.packets
+		if c.state == ConnStateClosed || packets < minPackets || c.retrans == 0 {
+			continue
+		}
+		flows = append(flows, FlowHealth{
+			ID:             c.id,
+			Src:            c.client,
+			Dst:            c.server,
+			SrcPort:        c.clientPort,
+			DstPort:        c.serverPort,
+			Packets:        packets,
+			Retransmits:    c.retrans,
+			RetransmitRate: float64(c.retrans) / float64(packets),
+		})
+	}
+	return flows
+}
+
+func (ct *ConnTracker) newConn(p *Packet, isSyn bool) *tcpConn {
+	ct.nextID++
+	c := &tcpConn{
+		id:         fmt.Sprintf("c%d", ct.nextID),
+		client:     p.Src,
+		clientPort: p.SrcPort,
+		server:     p.Dst,
+		serverPort: p.DstPort,
+		start:      p.Timestamp,
+	}
+	if isSyn {
+		c.state = ConnStateSynSent
+	} else {
+		// Midstream: guess the initiator is the side using the ephemeral (higher) port
+		c.state = ConnStateEstablished
+		c.midstream = true
+		if p.SrcPort < p.DstPort {
+			c.client, c.server = p.Dst, p.Src
+			c.clientPort, c.serverPort = p.DstPort, p.SrcPort
+		}
+	}
+	return c
+}
+
+// trackSequence counts data segments that don't advance the sender's sequence space as retransmissions
+func (ct *ConnTracker) trackSequence(c *tcpConn, dir *connDirection, p *Packet, syn, fin bool) {
+	segLen := uint32(p.PayloadLen)
+	if syn || fin {
+		segLen++
+	}
+	if segLen == 0 {
+		return
+	}
+
+	end := p.TCPSeq + segLen
+	if !dir.seqSet {
+		dir.nextSeq = end
+		dir.seqSet = true
+		return
+	}
+	// Serial number arithmetic handles sequence wraparound
+	if int32(end-dir.nextSeq) <= 0 {
+		c.retrans++
+		ct.retransmits++
+		return
+	}
+	dir.nextSeq = end
+}
+
+func (ct *ConnTracker) closeConn(c *tcpConn, reason string, now int64) *ConnEvent {
+	c.state = ConnStateClosed
+	c.lastSeen = now
+	return ct.event("conn_close", c, reason, now)
+}
+
+func (ct *ConnTracker) event(eventType string, c *tcpConn, reason string, now int64) *ConnEvent {
+	e := &ConnEvent{
+		Type:        eventType,
+		ID:          c.id,
+		Src:         c.client,
+		Dst:         c.server,
+		SrcPort:     c.clientPort,
+		DstPort:     c.serverPort,
+		Protocol:    ProtocolTCP,
+		Reason:      reason,
+		Midstream:   c.midstream,
+		StartTime:   c.start,
+		Timestamp:   now,
+		BytesOut:    c.out.bytes,
+		BytesIn:     c.in.bytes,
+		PacketsOut:  c.out.packets,
+		PacketsIn:   c.in.packets,
+		Retransmits: c.retrans,
+	}
+	if now > c.start {
+		e.DurationMs = now - c.start
+	}
+	return e
+}
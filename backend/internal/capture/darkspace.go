@@ -0,0 +1,106 @@
+package capture
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DarkSpaceConfig holds the limits of dark-space alerting
+type DarkSpaceConfig struct {
+	Cooldown   time.Duration // minimum gap between repeat alerts for the same source
+	MaxSources int           // sources remembered at once; new ones alert again once forgotten
+}
+
+// DefaultDarkSpaceConfig returns limits for a NOC watching a handful of ranges
+func DefaultDarkSpaceConfig() DarkSpaceConfig {
+	return DarkSpaceConfig{
+		Cooldown:   time.Minute,
+		MaxSources: 10000,
+	}
+}
+
+// DarkSpaceAlert is sent the moment an address in dark space sends a packet
+type DarkSpaceAlert struct {
+	Type      string `json:"type"` // always "dark_space"
+	Timestamp int64  `json:"timestamp"`
+	IP        string `json:"ip"`    // the source in dark space
+	Range     string `json:"range"` // name of the dark_space entry it belongs to
+	Dst       string `json:"dst"`   // the destination of the packet that raised the alert
+	Protocol  string `json:"protocol"`
+	Count     int    `json:"count"` // packets from the source since its previous alert, this one included
+}
+
+// ToJSON converts a dark-space alert to JSON
+func (a *DarkSpaceAlert) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+type darkSource struct {
+	alerted time.Time
+	count   int // packets since the last alert
+}
+
+// DarkSpaceDetector raises an alert as soon as a packet comes from an address range that should
+// never send anything: unallocated subnets, a honeynet. Nothing legitimate lives there, so a
+// single packet is worth a look. It is not safe for concurrent use; each session's forwarder
+// owns one.
+type DarkSpaceDetector struct {
+	config  DarkSpaceConfig
+	lookup  func(ip string) string // dark range an address is in, "" for none
+	sources map[string]*darkSource
+}
+
+// NewDarkSpaceDetector creates a detector over the ranges lookup knows
+func NewDarkSpaceDetector(config DarkSpaceConfig, lookup func(ip string) string) *DarkSpaceDetector {
+	return &DarkSpaceDetector{
+		config:  config,
+		lookup:  lookup,
+		sources: make(map[string]*darkSource),
+	}
+}
+
+// Observe returns an alert for a packet from dark space, unless its source alerted within the
+// cooldown
+func (d *DarkSpaceDetector) Observe(p *Packet) *DarkSpaceAlert {
+	name := d.lookup(p.Src)
+	if name == "" {
+		return nil
+	}
+	now := time.Now()
+	source, ok := d.sources[p.Src]
+	if !ok {
+		if len(d.sources) >= d.config.MaxSources {
+			d.Sweep()
+		}
+		source = &darkSource{}
+		if len(d.sources) < d.config.MaxSources {
+			d.sources[p.Src] = source
+		}
+	}
+	source.count++
+	if ok && now.Sub(source.alerted) < d.config.Cooldown {
+		return nil
+	}
+
+	alert := &DarkSpaceAlert{
+		Type:      "dark_space",
+		Timestamp: now.UnixMilli(),
+		IP:        p.Src,
+		Range:     name,
+		Dst:       p.Dst,
+		Protocol:  p.Protocol,
+		Count:     source.count,
+	}
+	source.alerted, source.count = now, 0
+	return alert
+}
+
+// Sweep forgets sources whose cooldown is over; their next packet alerts afresh
+func (d *DarkSpaceDetector) Sweep() {
+	now := time.Now()
+	for ip, source := range d.sources {
+		if now.Sub(source.alerted) >= d.config.Cooldown {
+			delete(d.sources, ip)
+		}
+	}
+}
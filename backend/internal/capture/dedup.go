@@ -0,0 +1,150 @@
+package capture
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DedupConfig holds the limits of duplicate removal for mirrored taps
+type DedupConfig struct {
+	Window     time.Duration // a copy arriving this long after the original at most is a duplicate; 0 disables removal
+	MaxTracked int           // recent packets remembered at once; the oldest are forgotten first
+}
+
+// DefaultDedupConfig returns limits for two mirror ports feeding one interface: copies of a
+// frame arrive microseconds to a few milliseconds apart
+func DefaultDedupConfig() DedupConfig {
+	return DedupConfig{
+		Window:     10 * time.Millisecond,
+		MaxTracked: 65536,
+	}
+}
+
+// DedupStats is the periodic dedup_stats message
+type DedupStats struct {
+	Type       string  `json:"type"`
+	Timestamp  int64   `json:"timestamp"`
+	IntervalMs int64   `json:"interval_ms"`
+	Packets    int64   `json:"packets"`    // decoded packets checked this interval
+	Duplicates int64   `json:"duplicates"` // of those, copies that were removed
+	Ratio      float64 `json:"ratio"`      // duplicates / packets
+	Total      int64   `json:"total"`      // duplicates removed since the session started
+}
+
+// ToJSON converts dedup stats to JSON
+func (s *DedupStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// dedupKey is what two copies of one IPv4 packet have in common. The IP checksum covers the
+// TTL, so copies taken on either side of a router are not the same packet.
+type dedupKey struct {
+	src, dst         string
+	srcPort, dstPort int
+	protocol         string
+	id, checksum     uint16
+	size             int
+	seq              uint32
+	flags            string
+}
+
+type dedupEntry struct {
+	key  dedupKey
+	seen time.Time
+}
+
+// Deduplicator removes the second copy of packets seen twice, as when both directions of a link
+// are mirrored from two switch ports onto one capture interface. Packets are matched on their
+// addresses, ports, IP ID, IP checksum, size and TCP sequence number, within a short window of
+// capture time. Packets not decoded from a frame (simulated, relayed, Zeek) are never
+// duplicates. It is not safe for concurrent use; each session's forwarder owns one.
+type Deduplicator struct {
+	config DedupConfig
+	seen   map[dedupKey]time.Time
+	order  []dedupEntry // oldest first, for expiry
+	stats  DedupStats
+	total  int64
+	last   time.Time
+}
+
+// NewDeduplicator creates an empty deduplicator
+func NewDeduplicator(config DedupConfig) *Deduplicator {
+	return &Deduplicator{
+		config: config,
+		seen:   make(map[dedupKey]time.Time),
+		last:   time.Now(),
+	}
+}
+
+// Duplicate reports whether p is a copy of a packet seen within the window; the first copy is
+// remembered and kept
+func (d *Deduplicator) Duplicate(p *Packet) bool {
+	if d.config.Window <= 0 || p.TTL == 0 {
+		return false
+	}
+	at := p.CaptureInfo.Timestamp
+	if at.IsZero() {
+		at = time.UnixMilli(p.Timestamp)
+	}
+	d.stats.Packets++
+	d.expire(at)
+
+	key := dedupKey{
+		src: p.Src, dst: p.Dst, srcPort: p.SrcPort, dstPort: p.DstPort, protocol: p.Protocol,
+		id: p.IPID, checksum: p.IPChecksum, size: p.Size, seq: p.TCPSeq, flags: p.TCPFlags,
+	}
+	if seen, ok := d.seen[key]; ok {
+		// The two ports can deliver their copies in either order
+		if gap := at.Sub(seen); gap <= d.config.Window && gap >= -d.config.Window {
+			d.stats.Duplicates++
+			d.total++
+			delete(d.seen, key) // a third sighting is a new packet
+			return true
+		}
+	}
+	if len(d.order) >= d.config.MaxTracked {
+		d.forget(d.order[0])
+		d.order = d.order[1:]
+	}
+	d.seen[key] = at
+	d.order = append(d.order, dedupEntry{key, at})
+	return false
+}
+
+// expire forgets packets that can no longer have a copy arriving
+func (d *Deduplicator) expire(now time.Time) {
+	cutoff := now.Add(-2 * d.config.Window)
+	n := 0
+	for n < len(d.order) && d.order[n].seen.Before(cutoff) {
+		d.forget(d.order[n])
+		n++
+	}
+	d.order = d.order[n:]
+}
+
+// forget drops an entry unless the key has been seen again since
+func (d *Deduplicator) forget(entry dedupEntry) {
+	if seen, ok := d.seen[entry.key]; ok && seen.Equal(entry.seen) {
+		delete(d.seen, entry.key)
+	}
+}
+
+// Report closes the current interval. It returns nil while removal is off, and for intervals
+// without duplicates.
+func (d *Deduplicator) Report() *DedupStats {
+	now := time.Now()
+	stats := d.stats
+	interval := now.Sub(d.last)
+	d.stats = DedupStats{}
+	d.last = now
+	if d.config.Window <= 0 || stats.Duplicates == 0 {
+		return nil
+	}
+
+	stats.Type = "dedup_stats"
+	stats.Timestamp = now.UnixMilli()
+	stats.IntervalMs = interval.Milliseconds()
+	stats.Ratio = float64(stats.Duplicates) / float64(stats.Packets)
+	stats.Total = d.total
+	return &stats
+}
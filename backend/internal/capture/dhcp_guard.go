@@ -0,0 +1,353 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Bounds of the DHCP guard: DHCP servers remembered at once, how long one is kept after its last
+// offer, transactions remembered per window, and the defaults of its rules
+const (
+	maxDHCPServers         = 256
+	dhcpServerForgetAfter  = time.Hour
+	maxDHCPTransactions    = 100000
+	defaultDiscoverRate    = 600
+	defaultDHCPGuardWait   = 10 * time.Minute
+	dhcpStarvationInterval = time.Minute
+)
+
+// DHCPMessage is the part of a DHCPv4 message the guard looks at
+type DHCPMessage struct {
+	Type      string // discover, offer, request, decline, ack, nak, release or inform
+	Xid       uint32
+	ClientMAC string
+	ServerID  string // the server identifier option, when present
+	YourIP    string // the address offered or acknowledged
+}
+
+// dhcpMessage reads a decoded DHCPv4 layer; nil when it has no message type
+func dhcpMessage(dhcp *layers.DHCPv4) *DHCPMessage {
+	msg := &DHCPMessage{Xid: dhcp.Xid}
+	if len(dhcp.ClientHWAddr) >= 6 {
+		msg.ClientMAC = net.HardwareAddr(dhcp.ClientHWAddr[:6]).String()
+	}
+	if ip := dhcp.YourClientIP.To4(); ip != nil && !ip.IsUnspecified() {
+		msg.YourIP = ip.String()
+	}
+	for _, option := range dhcp.Options {
+		switch option.Type {
+		case layers.DHCPOptMessageType:
+			if len(option.Data) == 1 {
+				msg.Type = strings.ToLower(layers.DHCPMsgType(option.Data[0]).String())
+			}
+		case layers.DHCPOptServerID:
+			if len(option.Data) == 4 {
+				msg.ServerID = net.IP(option.Data).String()
+			}
+		}
+	}
+	if msg.Type == "" || msg.Type == "unknown" {
+		return nil
+	}
+	return msg
+}
+
+// DHCPGuardRules say which DHCP servers may answer, and how many new DISCOVERs a minute are normal
+type DHCPGuardRules struct {
+	Servers      []netip.Addr  // server identifiers allowed to offer; any server when empty
+	MaxDiscovers int           // new DISCOVER transactions per minute before starvation is suspected
+	Cooldown     time.Duration // minimum gap between repeat alerts for the same server or interface
+}
+
+// DHCPServer is a DHCP server heard answering clients
+type DHCPServer struct {
+	IP         string    `json:"ip"`
+	MAC        string    `json:"mac,omitempty"` // the Ethernet source of its last answer
+	Authorized bool      `json:"authorized"`    // listed in the rules, or no servers are listed
+	Offers     int64     `json:"offers"`        // offers and acks, once per transaction
+	Clients    int       `json:"clients"`       // distinct client MACs answered
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+
+	clients map[string]bool
+	alerted time.Time
+	count   int // answers since the last alert
+}
+
+// DHCPAlert is raised when a DHCP server the rules don't list answers clients (rogue_server), or
+// when new DISCOVERs come in far faster than clients join (starvation: a tool burning through
+// the pool with made-up MACs)
+type DHCPAlert struct {
+	Type      string `json:"type"` // always "dhcp_anomaly"
+	Kind      string `json:"kind"` // rogue_server or starvation
+	Timestamp int64  `json:"timestamp"`
+	Interface string `json:"interface"`
+
+	// rogue_server
+	IP      string `json:"ip,omitempty"`  // the server
+	MAC     string `json:"mac,omitempty"` // its Ethernet source
+	Offered string `json:"offered,omitempty"`
+	Client  string `json:"client,omitempty"` // the client MAC it answered
+	Count   int    `json:"count,omitempty"`  // answers since its previous alert
+
+	// starvation
+	Discovers int     `json:"discovers,omitempty"`  // new DISCOVER transactions in the last minute
+	Clients   int     `json:"clients,omitempty"`    // distinct client MACs among them
+	TopSource string  `json:"top_source,omitempty"` // the Ethernet source that sent the most
+	TopShare  float64 `json:"top_share,omitempty"`  // its share of them, 0-1
+}
+
+// ToJSON converts a DHCP alert to JSON
+func (a *DHCPAlert) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+type dhcpDiscover struct {
+	at        time.Time
+	clientMAC string
+	source    string // Ethernet source
+}
+
+// dhcpLink counts the DISCOVERs heard on one interface over the last minute
+type dhcpLink struct {
+	discovers []dhcpDiscover // oldest first
+	alerted   time.Time
+}
+
+// DHCPGuard keeps the DHCP servers answering on the captured links and watches DISCOVER rates.
+// Safe for concurrent use; every session's live capture feeds the same guard, so messages are
+// counted once per transaction however many sessions hear them.
+type DHCPGuard struct {
+	mu      sync.Mutex
+	rules   *DHCPGuardRules
+	servers map[string]*DHCPServer
+	links   map[string]*dhcpLink
+	seen    map[string]time.Time // transactions counted in the last minute, by interface, type, xid and MAC
+}
+
+// NewDHCPGuard creates a guard; nil rules only keep track of the servers
+func NewDHCPGuard(rules *DHCPGuardRules) *DHCPGuard {
+	return &DHCPGuard{
+		rules:   rules,
+		servers: make(map[string]*DHCPServer),
+		links:   make(map[string]*dhcpLink),
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// SetRules replaces the rules; servers and links are checked afresh
+func (g *DHCPGuard) SetRules(rules *DHCPGuardRules) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rules = rules
+	for _, server := range g.servers {
+		server.Authorized = g.authorized(server.IP)
+		server.alerted, server.count = time.Time{}, 0
+	}
+	for _, link := range g.links {
+		link.alerted = time.Time{}
+	}
+}
+
+// Observe records a DHCP message heard on iface. It returns an alert when the rules are broken,
+// unless the same server or interface alerted within the cooldown, and the address of a server
+// answering for the first time.
+func (g *DHCPGuard) Observe(p *Packet, iface string, now time.Time) (*DHCPAlert, string) {
+	msg := p.DHCP
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := fmt.Sprintf("%s|%s|%d|%s", iface, msg.Type, msg.Xid, msg.ClientMAC)
+	if _, counted := g.seen[key]; counted {
+		return nil, ""
+	}
+	if len(g.seen) >= maxDHCPTransactions {
+		g.expireSeen(now)
+	}
+	if len(g.seen) < maxDHCPTransactions {
+		g.seen[key] = now
+	}
+
+	switch msg.Type {
+	case "discover":
+		return g.discover(msg, p.SrcMAC, iface, now), ""
+	case "offer", "ack":
+		return g.answer(msg, p, iface, now)
+	}
+	return nil, ""
+}
+
+// answer records an offer or ack; callers hold mu
+func (g *DHCPGuard) answer(msg *DHCPMessage, p *Packet, iface string, now time.Time) (*DHCPAlert, string) {
+	ip := msg.ServerID
+	if ip == "" {
+		ip = p.Src
+	}
+	var added string
+	server, known := g.servers[ip]
+	if !known {
+		if len(g.servers) >= maxDHCPServers {
+			g.expireServers(now)
+			if len(g.servers) >= maxDHCPServers {
+				return nil, ""
+			}
+		}
+		server = &DHCPServer{IP: ip, FirstSeen: now, Authorized: g.authorized(ip), clients: make(map[string]bool)}
+		g.servers[ip] = server
+		added = ip
+	}
+	server.MAC = p.SrcMAC
+	server.Offers++
+	server.LastSeen = now
+	if msg.ClientMAC != "" && len(server.clients) < maxDHCPTransactions {
+		server.clients[msg.ClientMAC] = true
+		server.Clients = len(server.clients)
+	}
+	if server.Authorized {
+		return nil, added
+	}
+	server.count++
+	if !server.alerted.IsZero() && now.Sub(server.alerted) < g.cooldown() {
+		return nil, added
+	}
+	alert := &DHCPAlert{
+		Type:      "dhcp_anomaly",
+		Kind:      "rogue_server",
+		Timestamp: now.UnixMilli(),
+		Interface: iface,
+		IP:        ip,
+		MAC:       p.SrcMAC,
+		Offered:   msg.YourIP,
+		Client:    msg.ClientMAC,
+		Count:     server.count,
+	}
+	server.alerted, server.count = now, 0
+	return alert, added
+}
+
+// discover counts a new DISCOVER and checks the interface's rate; callers hold mu
+func (g *DHCPGuard) discover(msg *DHCPMessage, source, iface string, now time.Time) *DHCPAlert {
+	link, ok := g.links[iface]
+	if !ok {
+		link = &dhcpLink{}
+		g.links[iface] = link
+	}
+	cutoff := now.Add(-dhcpStarvationInterval)
+	drop := 0
+	for drop < len(link.discovers) && link.discovers[drop].at.Before(cutoff) {
+		drop++
+	}
+	link.discovers = append(link.discovers[drop:], dhcpDiscover{at: now, clientMAC: msg.ClientMAC, source: source})
+	if len(link.discovers) > maxDHCPTransactions {
+		link.discovers = link.discovers[len(link.discovers)-maxDHCPTransactions:]
+	}
+
+	if g.rules == nil {
+		return nil
+	}
+	limit := g.rules.MaxDiscovers
+	if limit <= 0 {
+		limit = defaultDiscoverRate
+	}
+	if len(link.discovers) <= limit {
+		return nil
+	}
+	if !link.alerted.IsZero() && now.Sub(link.alerted) < g.cooldown() {
+		return nil
+	}
+	clients := make(map[string]bool)
+	sources := make(map[string]int)
+	for _, d := range link.discovers {
+		clients[d.clientMAC] = true
+		sources[d.source]++
+	}
+	alert := &DHCPAlert{
+		Type:      "dhcp_anomaly",
+		Kind:      "starvation",
+		Timestamp: now.UnixMilli(),
+		Interface: iface,
+		Discovers: len(link.discovers),
+		Clients:   len(clients),
+	}
+	for mac, n := range sources {
+		if share := float64(n) / float64(len(link.discovers)); mac != "" && share > alert.TopShare {
+			alert.TopSource, alert.TopShare = mac, share
+		}
+	}
+	link.alerted = now
+	return alert
+}
+
+// authorized reports whether the rules let ip answer; callers hold mu
+func (g *DHCPGuard) authorized(ip string) bool {
+	if g.rules == nil || len(g.rules.Servers) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, server := range g.rules.Servers {
+		if server == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *DHCPGuard) cooldown() time.Duration {
+	if g.rules.Cooldown > 0 {
+		return g.rules.Cooldown
+	}
+	return defaultDHCPGuardWait
+}
+
+// Server returns the DHCP server at ip, if it was heard in the last hour
+func (g *DHCPGuard) Server(ip string) (DHCPServer, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	server, ok := g.servers[ip]
+	if !ok || time.Since(server.LastSeen) > dhcpServerForgetAfter {
+		return DHCPServer{}, false
+	}
+	return *server, true
+}
+
+// Servers returns the DHCP servers heard in the last hour, ordered by address
+func (g *DHCPGuard) Servers(now time.Time) []DHCPServer {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expireServers(now)
+	servers := make([]DHCPServer, 0, len(g.servers))
+	for _, server := range g.servers {
+		servers = append(servers, *server)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].IP < servers[j].IP })
+	return servers
+}
+
+// expireServers forgets servers that stopped answering; callers hold mu
+func (g *DHCPGuard) expireServers(now time.Time) {
+	for ip, server := range g.servers {
+		if now.Sub(server.LastSeen) > dhcpServerForgetAfter {
+			delete(g.servers, ip)
+		}
+	}
+}
+
+// expireSeen forgets transactions older than the rate window; callers hold mu
+func (g *DHCPGuard) expireSeen(now time.Time) {
+	for key, at := range g.seen {
+		if now.Sub(at) > dhcpStarvationInterval {
+			delete(g.seen, key)
+		}
+	}
+}
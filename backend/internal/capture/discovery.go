@@ -0,0 +1,173 @@
+package capture
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// discoveryFilter passes LLDP frames and CDP's multicast, which live capture keeps alongside IP
+const discoveryFilter = "ether proto 0x88cc or ether dst 01:00:0c:cc:cc:cc"
+
+// Neighbor is what a switch, router, access point or phone announced about itself in an LLDP or
+// CDP frame: the device, and the port of it the frame left from
+type Neighbor struct {
+	Protocol        string   // "lldp" or "cdp"
+	ChassisID       string   // LLDP chassis ID, or CDP device ID
+	SystemName      string   // empty when the device didn't send one
+	Description     string   // LLDP system description, or CDP platform
+	PortID          string   // the device's port, e.g. "Gi1/0/24"
+	PortDescription string   // LLDP only
+	MgmtAddresses   []string // where the device is managed; graph nodes with these IPs are the device
+	Capabilities    []string // "bridge", "router", "wlan_ap", "phone", ...
+	NativeVLAN      int      // CDP only
+	TTL             time.Duration
+}
+
+// decodeDiscovery turns an LLDP or CDP frame into a Packet from the announcing port's MAC,
+// carrying the announcement in Neighbor. It returns nil for anything else.
+func decodeDiscovery(packet gopacket.Packet) *Packet {
+	var neighbor *Neighbor
+	if lldpLayer := packet.Layer(layers.LayerTypeLinkLayerDiscovery); lldpLayer != nil {
+		neighbor = lldpNeighbor(lldpLayer.(*layers.LinkLayerDiscovery), packet.Layer(layers.LayerTypeLinkLayerDiscoveryInfo))
+	} else if cdpLayer := packet.Layer(layers.LayerTypeCiscoDiscovery); cdpLayer != nil {
+		neighbor = cdpNeighbor(cdpLayer.(*layers.CiscoDiscovery), packet.Layer(layers.LayerTypeCiscoDiscoveryInfo))
+	}
+	if neighbor == nil {
+		return nil
+	}
+	ethLayer := packet.Layer(layers.LayerTypeEthernet)
+	if ethLayer == nil {
+		return nil
+	}
+	eth := ethLayer.(*layers.Ethernet)
+
+	p := NewPacket(eth.SrcMAC.String(), eth.DstMAC.String(), 0, 0, len(packet.Data()), ProtocolOther)
+	p.SrcMAC = eth.SrcMAC.String()
+	p.Neighbor = neighbor
+	p.Raw = packet.Data()
+	p.CaptureInfo = packet.Metadata().CaptureInfo
+	p.LinkType = linkTypeOf(packet)
+	return p
+}
+
+func lldpNeighbor(lldp *layers.LinkLayerDiscovery, infoLayer gopacket.Layer) *Neighbor {
+	n := &Neighbor{Protocol: "lldp", TTL: time.Duration(lldp.TTL) * time.Second}
+	switch lldp.ChassisID.Subtype {
+	case layers.LLDPChassisIDSubTypeMACAddr:
+		n.ChassisID = net.HardwareAddr(lldp.ChassisID.ID).String()
+	case layers.LLDPChassisIDSubTypeNetworkAddr:
+		n.ChassisID = networkAddress(lldp.ChassisID.ID)
+	default:
+		n.ChassisID = printable(lldp.ChassisID.ID)
+	}
+	switch lldp.PortID.Subtype {
+	case layers.LLDPPortIDSubtypeMACAddr:
+		n.PortID = net.HardwareAddr(lldp.PortID.ID).String()
+	case layers.LLDPPortIDSubtypeNetworkAddr:
+		n.PortID = networkAddress(lldp.PortID.ID)
+	default:
+		n.PortID = printable(lldp.PortID.ID)
+	}
+	if n.ChassisID == "" {
+		return nil
+	}
+	if infoLayer == nil {
+		return n
+	}
+	info := infoLayer.(*layers.LinkLayerDiscoveryInfo)
+	n.SystemName = printable([]byte(info.SysName))
+	n.Description = printable([]byte(info.SysDescription))
+	n.PortDescription = printable([]byte(info.PortDescription))
+	switch info.MgmtAddress.Subtype {
+	case layers.IANAAddressFamilyIPV4, layers.IANAAddressFamilyIPV6:
+		if ip := net.IP(info.MgmtAddress.Address); len(ip) == net.IPv4len || len(ip) == net.IPv6len {
+			n.MgmtAddresses = []string{ip.String()}
+		}
+	}
+	caps := info.SysCapabilities.EnabledCap
+	for _, c := range []struct {
+		on   bool
+		name string
+	}{
+		{caps.Bridge, "bridge"}, {caps.Router, "router"}, {caps.WLANAP, "wlan_ap"}, {caps.Phone, "phone"},
+		{caps.Repeater, "repeater"}, {caps.DocSis, "docsis"}, {caps.StationOnly, "station"},
+	} {
+		if c.on {
+			n.Capabilities = append(n.Capabilities, c.name)
+		}
+	}
+	return n
+}
+
+func cdpNeighbor(cdp *layers.CiscoDiscovery, infoLayer gopacket.Layer) *Neighbor {
+	if infoLayer == nil {
+		return nil
+	}
+	info := infoLayer.(*layers.CiscoDiscoveryInfo)
+	n := &Neighbor{
+		Protocol:    "cdp",
+		ChassisID:   printable([]byte(info.DeviceID)),
+		SystemName:  printable([]byte(info.SysName)),
+		Description: printable([]byte(info.Platform)),
+		PortID:      printable([]byte(info.PortID)),
+		NativeVLAN:  int(info.NativeVLAN),
+		TTL:         time.Duration(cdp.TTL) * time.Second,
+	}
+	if n.ChassisID == "" {
+		return nil
+	}
+	if n.SystemName == "" {
+		n.SystemName = n.ChassisID
+	}
+	seen := make(map[string]bool)
+	for _, addresses := range [][]net.IP{info.MgmtAddresses, info.Addresses} {
+		for _, ip := range addresses {
+			if address := ip.String(); ip != nil && !seen[address] {
+				seen[address] = true
+				n.MgmtAddresses = append(n.MgmtAddresses, address)
+			}
+		}
+	}
+	caps := info.Capabilities
+	for _, c := range []struct {
+		on   bool
+		name string
+	}{
+		{caps.L2Switch || caps.TBBridge || caps.SPBridge, "bridge"}, {caps.L3Router, "router"},
+		{caps.IsPhone, "phone"}, {caps.L1Repeater, "repeater"}, {caps.IsHost, "station"},
+	} {
+		if c.on {
+			n.Capabilities = append(n.Capabilities, c.name)
+		}
+	}
+	return n
+}
+
+// networkAddress reads an LLDP network address ID: an IANA address family byte, then the address
+func networkAddress(id []byte) string {
+	if len(id) == 1+net.IPv4len || len(id) == 1+net.IPv6len {
+		return net.IP(id[1:]).String()
+	}
+	return fmt.Sprintf("%x", id)
+}
+
+// printable keeps a device-supplied string to one line of printable text
+func printable(b []byte) string {
+	text := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+			return ' '
+		}
+		return r
+	}, string(b))
+	text = strings.TrimSpace(text)
+	if len(text) > 256 {
+		text = strings.ToValidUTF8(text[:256], "")
+	}
+	return text
+}
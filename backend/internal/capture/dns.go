@@ -0,0 +1,45 @@
+package capture
+
+import (
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// DNSAnswer is one address a DNS response on the wire gave for a name
+type DNSAnswer struct {
+	Name string // lowercase, without the trailing dot
+	IP   string
+	TTL  uint32 // seconds
+}
+
+// dnsAnswers lists the addresses a DNS response resolved, under the name each record carries and
+// the name that was asked for, so a CNAME chain still ties the address to the name a client used
+func dnsAnswers(dns *layers.DNS) []DNSAnswer {
+	if !dns.QR {
+		return nil
+	}
+	var answers []DNSAnswer
+	for _, record := range dns.Answers {
+		if (record.Type != layers.DNSTypeA && record.Type != layers.DNSTypeAAAA) || record.IP == nil {
+			continue
+		}
+		ip := record.IP.String()
+		names := []string{dnsName(record.Name)}
+		for _, question := range dns.Questions {
+			if name := dnsName(question.Name); name != names[0] {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			if name != "" {
+				answers = append(answers, DNSAnswer{Name: name, IP: ip, TTL: record.TTL})
+			}
+		}
+	}
+	return answers
+}
+
+func dnsName(name []byte) string {
+	return strings.TrimSuffix(strings.ToLower(string(name)), ".")
+}
@@ -0,0 +1,188 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+)
+
+// EdgeUpdate is the traffic between two hosts over one summary interval
+type EdgeUpdate struct {
+	Src       string `json:"src"`
+	Dst       string `json:"dst"`
+	Packets   int64  `json:"packets"`
+	Bytes     int64  `json:"bytes"`
+	Intensity string `json:"intensity,omitempty"` // quiet, normal or hot (see EdgeIntensity)
+}
+
+// EdgeSummary is the periodic edge_summary message that replaces individual packets for
+// sessions on the summary stream
+type EdgeSummary struct {
+	Type       string        `json:"type"`
+	Timestamp  int64         `json:"timestamp"`
+	IntervalMs int64         `json:"interval_ms"`
+	Edges      []*EdgeUpdate `json:"edges"`
+	Truncated  int           `json:"truncated,omitempty"` // quieter edges left out of this summary
+}
+
+// ToJSON converts an edge summary to JSON
+func (s *EdgeSummary) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Conversation is the traffic between two hosts in both directions over one summary interval.
+// A is the lower address, so a pair keeps its orientation from one summary to the next.
+type Conversation struct {
+	A         string `json:"a"`
+	B         string `json:"b"`
+	Packets   int64  `json:"packets"` // both directions
+	Bytes     int64  `json:"bytes"`
+	PacketsAB int64  `json:"packets_ab"` // A → B
+	BytesAB   int64  `json:"bytes_ab"`
+	PacketsBA int64  `json:"packets_ba"` // B → A
+	BytesBA   int64  `json:"bytes_ba"`
+	Intensity string `json:"intensity,omitempty"` // quiet, normal or hot (see EdgeIntensity)
+}
+
+// ConversationSummary is the periodic conversation_summary message for sessions on the
+// conversations stream: edge_summary with both directions of a host pair on one edge
+type ConversationSummary struct {
+	Type          string          `json:"type"`
+	Timestamp     int64           `json:"timestamp"`
+	IntervalMs    int64           `json:"interval_ms"`
+	Conversations []*Conversation `json:"conversations"`
+	Truncated     int             `json:"truncated,omitempty"` // quieter conversations left out of this summary
+}
+
+// ToJSON converts a conversation summary to JSON
+func (s *ConversationSummary) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// EdgeSummarizer sums packets and bytes per directed host pair between reports. It is not
+// safe for concurrent use; each session's forwarder owns one.
+type EdgeSummarizer struct {
+	edges      map[edgeKey]*EdgeUpdate
+	lastReport time.Time
+}
+
+// NewEdgeSummarizer creates an empty summarizer
+func NewEdgeSummarizer() *EdgeSummarizer {
+	return &EdgeSummarizer{
+		edges:      make(map[edgeKey]*EdgeUpdate),
+		lastReport: time.Now(),
+	}
+}
+
+// Observe adds a packet to its edge
+func (s *EdgeSummarizer) Observe(p *Packet) {
+	key := edgeKey{p.Src, p.Dst}
+	edge, ok := s.edges[key]
+	if !ok {
+		edge = &EdgeUpdate{Src: p.Src, Dst: p.Dst}
+		s.edges[key] = edge
+	}
+	edge.Packets++
+	edge.Bytes += int64(p.Size)
+}
+
+// Report returns the edges seen since the previous report, busiest first and at most maxEdges
+// of them, and starts a new interval. It returns nil when no packet was observed.
+func (s *EdgeSummarizer) Report(maxEdges int) *EdgeSummary {
+	edges, now, interval := s.take()
+	if len(edges) == 0 {
+		return nil
+	}
+
+	summary := &EdgeSummary{
+		Type:       "edge_summary",
+		Timestamp:  now.UnixMilli(),
+		IntervalMs: interval.Milliseconds(),
+		Edges:      make([]*EdgeUpdate, 0, len(edges)),
+	}
+	for _, edge := range edges {
+		summary.Edges = append(summary.Edges, edge)
+	}
+	sort.Slice(summary.Edges, func(i, j int) bool {
+		return summary.Edges[i].Bytes > summary.Edges[j].Bytes
+	})
+	if maxEdges > 0 && len(summary.Edges) > maxEdges {
+		summary.Truncated = len(summary.Edges) - maxEdges
+		summary.Edges = summary.Edges[:maxEdges]
+	}
+	return summary
+}
+
+// ReportConversations is Report with the two directions between a pair of hosts merged into one
+// conversation, busiest first and at most maxConversations of them
+func (s *EdgeSummarizer) ReportConversations(maxConversations int) *ConversationSummary {
+	edges, now, interval := s.take()
+	if len(edges) == 0 {
+		return nil
+	}
+
+	merged := make(map[edgeKey]*Conversation, len(edges))
+	for _, edge := range edges {
+		a, b := edge.Src, edge.Dst
+		forward := addressBefore(a, b) || a == b
+		if !forward {
+			a, b = b, a
+		}
+		conv, ok := merged[edgeKey{a, b}]
+		if !ok {
+			conv = &Conversation{A: a, B: b}
+			merged[edgeKey{a, b}] = conv
+		}
+		conv.Packets += edge.Packets
+		conv.Bytes += edge.Bytes
+		if forward {
+			conv.PacketsAB += edge.Packets
+			conv.BytesAB += edge.Bytes
+		} else {
+			conv.PacketsBA += edge.Packets
+			conv.BytesBA += edge.Bytes
+		}
+	}
+
+	summary := &ConversationSummary{
+		Type:          "conversation_summary",
+		Timestamp:     now.UnixMilli(),
+		IntervalMs:    interval.Milliseconds(),
+		Conversations: make([]*Conversation, 0, len(merged)),
+	}
+	for _, conv := range merged {
+		summary.Conversations = append(summary.Conversations, conv)
+	}
+	sort.Slice(summary.Conversations, func(i, j int) bool {
+		return summary.Conversations[i].Bytes > summary.Conversations[j].Bytes
+	})
+	if maxConversations > 0 && len(summary.Conversations) > maxConversations {
+		summary.Truncated = len(summary.Conversations) - maxConversations
+		summary.Conversations = summary.Conversations[:maxConversations]
+	}
+	return summary
+}
+
+// take hands over the edges of the interval that just ended and starts a new one
+func (s *EdgeSummarizer) take() (map[edgeKey]*EdgeUpdate, time.Time, time.Duration) {
+	now := time.Now()
+	interval := now.Sub(s.lastReport)
+	s.lastReport = now
+	edges := s.edges
+	if len(edges) > 0 {
+		s.edges = make(map[edgeKey]*EdgeUpdate)
+	}
+	return edges, now, interval
+}
+
+// addressBefore orders two hosts by address, numerically for IPs; pseudonyms and other names
+// that don't parse are compared as text
+func addressBefore(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a < b
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16()) < 0
+}
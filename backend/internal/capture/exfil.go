@@ -0,0 +1,221 @@
+package capture
+
+import (
+	"encoding/json"
+	"math"
+	"net/netip"
+	"time"
+)
+
+// Defaults of the exfiltration rules, and the limits of the detector: windows are summed from
+// this many buckets, and hosts tracked at once
+const (
+	defaultExfilWindow    = 5 * time.Minute
+	defaultExfilDeviation = 10
+	defaultExfilMinBytes  = 100 << 20
+	defaultExfilBaseline  = time.Hour
+	defaultExfilCooldown  = 10 * time.Minute
+	exfilBuckets          = 10
+	maxExfilHosts         = 10000
+)
+
+// ExfilRules say which hosts are internal and how much they may send to external addresses
+type ExfilRules struct {
+	Internal  []netip.Prefix // hosts watched; the private ranges when empty
+	Window    time.Duration  // the sliding window bytes are summed over
+	MaxBytes  int64          // a host sending more than this in a window raises a volume alert; 0 for none
+	Deviation float64        // a window this many times the host's usual raises a baseline alert
+	MinBytes  int64          // baseline alerts need at least this many bytes in the window
+	Baseline  time.Duration  // how long the usual is averaged over; younger hosts aren't compared with theirs
+	Cooldown  time.Duration  // minimum gap between repeat alerts for the same host
+}
+
+// ExfilAlert is raised when an internal host sends more to external addresses over the window
+// than the rules allow (volume), or far more than it usually does (baseline)
+type ExfilAlert struct {
+	Type      string  `json:"type"` // always "exfiltration"
+	Kind      string  `json:"kind"` // volume or baseline
+	Timestamp int64   `json:"timestamp"`
+	IP        string  `json:"ip"`  // the internal host
+	Dst       string  `json:"dst"` // the destination of the packet that crossed the limit
+	Bytes     int64   `json:"bytes"`
+	Window    int     `json:"window_seconds"`
+	Limit     int64   `json:"limit"`              // the bytes per window that were crossed
+	Baseline  int64   `json:"baseline,omitempty"` // the host's usual bytes per window
+	Ratio     float64 `json:"ratio,omitempty"`    // bytes over baseline
+}
+
+// ToJSON converts an exfiltration alert to JSON
+func (a *ExfilAlert) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// exfilHost is one internal host's outbound bytes, bucketed over the window
+type exfilHost struct {
+	buckets  [exfilBuckets]int64
+	pos      int       // the current bucket
+	start    time.Time // when the current bucket began
+	sum      int64     // bytes in the window
+	baseline float64   // moving average of the window's bytes, sampled as each bucket closes
+	since    time.Time // first seen
+	alerted  time.Time
+}
+
+// advance closes the buckets that ended before now, folding each window into the baseline
+func (h *exfilHost) advance(now time.Time, bucket time.Duration, alpha float64) {
+	steps := int(now.Sub(h.start) / bucket)
+	if steps <= 0 {
+		return
+	}
+	for i := 0; i < steps && i < exfilBuckets; i++ {
+		h.baseline += alpha * (float64(h.sum) - h.baseline)
+		h.pos = (h.pos + 1) % exfilBuckets
+		h.sum -= h.buckets[h.pos]
+		h.buckets[h.pos] = 0
+	}
+	// Windows after the last bucket emptied were all zero
+	if steps > exfilBuckets {
+		h.baseline *= math.Pow(1-alpha, float64(steps-exfilBuckets))
+	}
+	h.start = h.start.Add(time.Duration(steps) * bucket)
+}
+
+// ExfilDetector sums the bytes each internal host sends to external addresses over a sliding
+// window, and alerts when a host goes over the rules' volume or far above its own baseline. It
+// is not safe for concurrent use; each session's forwarder owns one.
+type ExfilDetector struct {
+	rules   func() *ExfilRules // the current rules; nil turns detection off
+	current *ExfilRules        // the rules the hosts were counted under
+	hosts   map[string]*exfilHost
+}
+
+// NewExfilDetector creates a detector that follows the rules rules returns, so a reload takes
+// effect without a new session
+func NewExfilDetector(rules func() *ExfilRules) *ExfilDetector {
+	return &ExfilDetector{rules: rules, hosts: make(map[string]*exfilHost)}
+}
+
+// Observe counts a packet from an internal host to an external one, and returns an alert when
+// the host's window crosses a limit, unless it alerted within the cooldown
+func (d *ExfilDetector) Observe(p *Packet) *ExfilAlert {
+	rules := d.rules()
+	if rules != d.current {
+		// Windows counted under other rules don't compare; start over
+		d.current = rules
+		d.hosts = make(map[string]*exfilHost)
+	}
+	if rules == nil || !rules.internal(p.Src) || !isPublic(p.Dst) || rules.internal(p.Dst) {
+		return nil
+	}
+	now := time.Now()
+	window := rules.window()
+	bucket := window / exfilBuckets
+	host, ok := d.hosts[p.Src]
+	if !ok {
+		if len(d.hosts) >= maxExfilHosts {
+			d.Sweep()
+			if len(d.hosts) >= maxExfilHosts {
+				return nil
+			}
+		}
+		host = &exfilHost{start: now, since: now}
+		d.hosts[p.Src] = host
+	}
+	host.advance(now, bucket, float64(bucket)/float64(rules.baseline()))
+	host.buckets[host.pos] += int64(p.Size)
+	host.sum += int64(p.Size)
+
+	cooldown := rules.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultExfilCooldown
+	}
+	if !host.alerted.IsZero() && now.Sub(host.alerted) < cooldown {
+		return nil
+	}
+	alert := &ExfilAlert{
+		Type:      "exfiltration",
+		Timestamp: now.UnixMilli(),
+		IP:        p.Src,
+		Dst:       p.Dst,
+		Bytes:     host.sum,
+		Window:    int(window / time.Second),
+	}
+	switch {
+	case rules.MaxBytes > 0 && host.sum > rules.MaxBytes:
+		alert.Kind, alert.Limit = "volume", rules.MaxBytes
+	case now.Sub(host.since) >= rules.baseline():
+		deviation, minBytes := rules.Deviation, rules.MinBytes
+		if deviation <= 0 {
+			deviation = defaultExfilDeviation
+		}
+		if minBytes <= 0 {
+			minBytes = defaultExfilMinBytes
+		}
+		limit := int64(deviation * host.baseline)
+		if limit < minBytes {
+			limit = minBytes
+		}
+		if host.sum <= limit {
+			return nil
+		}
+		alert.Kind, alert.Limit = "baseline", limit
+		alert.Baseline = int64(host.baseline)
+		if host.baseline >= 1 {
+			alert.Ratio = math.Round(float64(host.sum)/host.baseline*10) / 10
+		}
+	default:
+		return nil
+	}
+	host.alerted = now
+	return alert
+}
+
+// Sweep forgets hosts that sent nothing out for a whole baseline period; their baseline has
+// worn down to nothing by then anyway
+func (d *ExfilDetector) Sweep() {
+	if d.current == nil {
+		return
+	}
+	idle := d.current.window() + d.current.baseline()
+	now := time.Now()
+	for ip, host := range d.hosts {
+		if now.Sub(host.start) > idle {
+			delete(d.hosts, ip)
+		}
+	}
+}
+
+// internal reports whether ip is one of the hosts the rules watch
+func (r *ExfilRules) internal(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if len(r.Internal) == 0 {
+		return addr.IsPrivate()
+	}
+	for _, prefix := range r.Internal {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ExfilRules) window() time.Duration {
+	if r.Window >= exfilBuckets*time.Second {
+		return r.Window
+	}
+	return defaultExfilWindow
+}
+
+func (r *ExfilRules) baseline() time.Duration {
+	if r.Baseline > r.window() {
+		return r.Baseline
+	}
+	if long := 12 * r.window(); long > defaultExfilBaseline {
+		return long
+	}
+	return defaultExfilBaseline
+}
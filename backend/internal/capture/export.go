@@ -0,0 +1,153 @@
+package capture
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// ExportConfig selects the archive slice written by an ArchiveExport
+type ExportConfig struct {
+	StorageDir string
+	Store      ArchiveStore // where the files are; StorageDir's DirStore when nil
+	StartTime  time.Time
+	EndTime    time.Time
+	Filter     string // BPF expression; empty exports every packet in the window
+	Index      *ArchiveIndex
+}
+
+// ExportResult summarizes a finished export
+type ExportResult struct {
+	Files   int
+	Packets int64
+	Bytes   int64
+}
+
+// ArchiveExport stitches the packets of a time window from the storage directory into one PCAP
+type ArchiveExport struct {
+	config ExportConfig
+	store  ArchiveStore
+	files  []string
+}
+
+// NewArchiveExport locates the archive files overlapping the window. It fails if the filter
+// doesn't compile or no file covers the window, so callers can report errors before streaming.
+func NewArchiveExport(config ExportConfig) (*ArchiveExport, error) {
+	if !config.EndTime.After(config.StartTime) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+	if config.Filter != "" {
+		if _, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, 65535, config.Filter); err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %v", config.Filter, err)
+		}
+	}
+
+	// Reuse the time window file discovery so export and playback agree on what's in range
+	twp := NewTimeWindowProcessor(TimeWindowConfig{
+		StorageDir: config.StorageDir,
+		Store:      config.Store,
+		StartTime:  config.StartTime,
+		EndTime:    config.EndTime,
+		Index:      config.Index,
+	})
+	if err := twp.buildFileSequence(); err != nil {
+		return nil, fmt.Errorf("failed to build file sequence: %v", err)
+	}
+	if len(twp.fileSequence) == 0 {
+		return nil, fmt.Errorf("no capture files found for time range")
+	}
+
+	return &ArchiveExport{config: config, store: twp.store, files: twp.fileSequence}, nil
+}
+
+// Files returns the archive files that will be read, in order
+func (e *ArchiveExport) Files() []string {
+	return e.files
+}
+
+// Write writes a PCAP containing every matching packet within the window.
+// Files with a different link type than the first one are skipped.
+func (e *ArchiveExport) Write(w io.Writer) (ExportResult, error) {
+	var result ExportResult
+	writer := pcapgo.NewWriter(w)
+	headerWritten := false
+	var linkType layers.LinkType
+
+	for _, path := range e.files {
+		handle, err := e.store.OpenCapture(path)
+		if err != nil {
+			log.Printf("Export: skipping %s: %v", filepath.Base(path), err)
+			continue
+		}
+
+		if !headerWritten {
+			linkType = handle.LinkType()
+			if err := writer.WriteFileHeader(65535, linkType); err != nil {
+				handle.Close()
+				return result, err
+			}
+			headerWritten = true
+		} else if handle.LinkType() != linkType {
+			log.Printf("Export: skipping %s: link type %v differs from %v", filepath.Base(path), handle.LinkType(), linkType)
+			handle.Close()
+			continue
+		}
+
+		var bpf *pcap.BPF
+		if e.config.Filter != "" {
+			if bpf, err = pcap.NewBPF(linkType, 65535, e.config.Filter); err != nil {
+				handle.Close()
+				return result, fmt.Errorf("applying filter to %s: %v", filepath.Base(path), err)
+			}
+		}
+
+		result.Files++
+		err = e.copyWindow(handle, bpf, writer, &result)
+		handle.Close()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if !headerWritten {
+		// Every file failed to open; still produce a valid empty capture
+		if err := writer.WriteFileHeader(65535, layers.LinkTypeEthernet); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (e *ArchiveExport) copyWindow(handle CaptureFile, bpf *pcap.BPF, writer *pcapgo.Writer, result *ExportResult) error {
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Truncated tail of a file dumpcap is still writing; keep what we have
+			return nil
+		}
+		if ci.Timestamp.Before(e.config.StartTime) {
+			continue
+		}
+		if ci.Timestamp.After(e.config.EndTime) {
+			// Files are time ordered, so nothing later in this file is in range
+			return nil
+		}
+		if bpf != nil && !bpf.Matches(ci, data) {
+			continue
+		}
+		if err := writer.WritePacket(ci, data); err != nil {
+			return err
+		}
+		result.Packets++
+		result.Bytes += int64(len(data))
+	}
+}
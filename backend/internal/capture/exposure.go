@@ -0,0 +1,114 @@
+package capture
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exposure is how much metadata a room's clients may see. Payload bytes are not part of it:
+// no stage ever lets them out.
+type Exposure struct {
+	Ports     bool // TCP/UDP ports on packets, connection events and flows
+	Hostnames bool // asset labels and other names attached to addresses
+}
+
+// FullExposure shows all metadata, which is what rooms get unless configured otherwise
+var FullExposure = Exposure{Ports: true, Hostnames: true}
+
+// ParseExposure reads the metadata a room exposes, e.g. ["ports", "hostnames"]; an empty
+// list exposes addresses, sizes and protocols only
+func ParseExposure(fields []string) (Exposure, error) {
+	var e Exposure
+	for _, field := range fields {
+		switch strings.TrimSpace(field) {
+		case "ports":
+			e.Ports = true
+		case "hostnames":
+			e.Hostnames = true
+		case "":
+		default:
+			return e, fmt.Errorf("unknown metadata %q (ports or hostnames)", field)
+		}
+	}
+	return e, nil
+}
+
+// Packet is the stage every packet passes through on its way out of the backend. The copy
+// is built field by field from what clients may see, so the original frame, the payload and
+// decoded header internals stay behind, including any fields added to Packet later.
+func (e Exposure) Packet(p *Packet) *Packet {
+	out := &Packet{
+		Type:       p.Type,
+		Src:        p.Src,
+		Dst:        p.Dst,
+		Size:       p.Size,
+		Protocol:   p.Protocol,
+		Timestamp:  p.Timestamp,
+		Source:     p.Source,
+		TCPFlags:   p.TCPFlags,
+		Fragmented: p.Fragmented,
+		Tunnel:     p.Tunnel,
+		Cast:       p.Cast,
+		CastGroup:  p.CastGroup,
+		SrcGroup:   p.SrcGroup,
+		DstGroup:   p.DstGroup,
+		Sensor:     p.Sensor,
+		SrcNAT:     p.SrcNAT,
+		DstNAT:     p.DstNAT,
+		Intensity:  p.Intensity,
+		Pinned:     p.Pinned,
+	}
+	if e.Ports {
+		out.SrcPort, out.DstPort = p.SrcPort, p.DstPort
+	}
+	return out
+}
+
+// ConnEvent returns a connection event as the room may see it
+func (e Exposure) ConnEvent(ev *ConnEvent) *ConnEvent {
+	if e.Ports {
+		return ev
+	}
+	out := *ev
+	out.SrcPort, out.DstPort = 0, 0
+	return &out
+}
+
+// TCPMetrics returns TCP metrics as the room may see them
+func (e Exposure) TCPMetrics(m *TCPMetrics) *TCPMetrics {
+	if e.Ports || m == nil {
+		return m
+	}
+	out := *m
+	out.TopRetransmitters = make([]FlowHealth, len(m.TopRetransmitters))
+	for i, flow := range m.TopRetransmitters {
+		out.TopRetransmitters[i] = *e.flow(&flow)
+	}
+	return &out
+}
+
+// TCPAnomaly returns an anomaly event as the room may see it
+func (e Exposure) TCPAnomaly(ev *TCPAnomalyEvent) *TCPAnomalyEvent {
+	if e.Ports || ev.Flow == nil {
+		return ev
+	}
+	out := *ev
+	out.Flow = e.flow(ev.Flow)
+	return &out
+}
+
+func (e Exposure) flow(f *FlowHealth) *FlowHealth {
+	out := *f
+	out.SrcPort, out.DstPort = 0, 0
+	return &out
+}
+
+// NodeRole returns a role update as the room may see it: the services it lists are ports
+func (e Exposure) NodeRole(r *NodeRole) *NodeRole {
+	if e.Ports || len(r.Role.Services) == 0 {
+		return r
+	}
+	role := *r.Role
+	role.Services = nil
+	return &NodeRole{Type: r.Type, IP: r.IP, Role: &role}
+}
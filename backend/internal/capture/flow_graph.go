@@ -0,0 +1,156 @@
+package capture
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// GraphNode is an aggregated host in the traffic graph
+type GraphNode struct {
+	ID        string `json:"id"` // IP address
+	Label     string `json:"label,omitempty"`
+	Group     string `json:"group,omitempty"`
+	PacketsTx int64  `json:"packets_tx"`
+	PacketsRx int64  `json:"packets_rx"`
+	BytesTx   int64  `json:"bytes_tx"`
+	BytesRx   int64  `json:"bytes_rx"`
+	FirstSeen int64  `json:"first_seen"`
+	LastSeen  int64  `json:"last_seen"`
+}
+
+// GraphEdge is aggregated directed traffic between two hosts
+type GraphEdge struct {
+	Source    string         `json:"source"`
+	Target    string         `json:"target"`
+	Packets   int64          `json:"packets"`
+	Bytes     int64          `json:"bytes"`
+	Protocols map[string]int `json:"protocols"`
+	FirstSeen int64          `json:"first_seen"`
+	LastSeen  int64          `json:"last_seen"`
+}
+
+// Graph is a point-in-time snapshot of the traffic graph
+type Graph struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Nodes       []*GraphNode `json:"nodes"`
+	Edges       []*GraphEdge `json:"edges"`
+}
+
+type edgeKey struct {
+	src, dst string
+}
+
+// FlowGraph aggregates every packet into node and edge totals for snapshot export.
+// Entries idle longer than the retention period are pruned.
+type FlowGraph struct {
+	mu        sync.Mutex
+	nodes     map[string]*GraphNode
+	edges     map[edgeKey]*GraphEdge
+	retention time.Duration
+	maxEdges  int
+}
+
+// NewFlowGraph creates a graph that forgets hosts and edges idle for longer than retention
+func NewFlowGraph(retention time.Duration, maxEdges int) *FlowGraph {
+	return &FlowGraph{
+		nodes:     make(map[string]*GraphNode),
+		edges:     make(map[edgeKey]*GraphEdge),
+		retention: retention,
+		maxEdges:  maxEdges,
+	}
+}
+
+// Observe adds a packet to the graph
+func (g *FlowGraph) Observe(p *Packet) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := edgeKey{p.Src, p.Dst}
+	edge, ok := g.edges[key]
+	if !ok {
+		if len(g.edges) >= g.maxEdges {
+			return
+		}
+		edge = &GraphEdge{Source: p.Src, Target: p.Dst, Protocols: make(map[string]int), FirstSeen: p.Timestamp}
+		g.edges[key] = edge
+	}
+	edge.Packets++
+	edge.Bytes += int64(p.Size)
+	edge.Protocols[p.Protocol]++
+	edge.LastSeen = p.Timestamp
+
+	src := g.node(p.Src, p.Timestamp)
+	src.PacketsTx++
+	src.BytesTx += int64(p.Size)
+	dst := g.node(p.Dst, p.Timestamp)
+	dst.PacketsRx++
+	dst.BytesRx += int64(p.Size)
+}
+
+func (g *FlowGraph) node(ip string, ts int64) *GraphNode {
+	n, ok := g.nodes[ip]
+	if !ok {
+		n = &GraphNode{ID: ip, FirstSeen: ts}
+		g.nodes[ip] = n
+	}
+	n.LastSeen = ts
+	return n
+}
+
+// Prune drops edges and nodes not seen within the retention period of the newest packet
+func (g *FlowGraph) Prune() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var newest int64
+	for _, n := range g.nodes {
+		if n.LastSeen > newest {
+			newest = n.LastSeen
+		}
+	}
+	cutoff := newest - g.retention.Milliseconds()
+	for key, e := range g.edges {
+		if e.LastSeen < cutoff {
+			delete(g.edges, key)
+		}
+	}
+	for ip, n := range g.nodes {
+		if n.LastSeen < cutoff {
+			delete(g.nodes, ip)
+		}
+	}
+}
+
+// Snapshot returns a deep copy of the current graph, ordered for stable output
+func (g *FlowGraph) Snapshot() *Graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	graph := &Graph{
+		GeneratedAt: time.Now(),
+		Nodes:       make([]*GraphNode, 0, len(g.nodes)),
+		Edges:       make([]*GraphEdge, 0, len(g.edges)),
+	}
+	for _, n := range g.nodes {
+		nodeCopy := *n
+		graph.Nodes = append(graph.Nodes, &nodeCopy)
+	}
+	for _, e := range g.edges {
+		edgeCopy := *e
+		edgeCopy.Protocols = make(map[string]int, len(e.Protocols))
+		for proto, count := range e.Protocols {
+			edgeCopy.Protocols[proto] = count
+		}
+		graph.Edges = append(graph.Edges, &edgeCopy)
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+		return graph.Edges[i].Target < graph.Edges[j].Target
+	})
+	return graph
+}
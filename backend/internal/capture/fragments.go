@@ -0,0 +1,192 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// IPFragment is the fragmentation part of a decoded IPv4 header
+type IPFragment struct {
+	ID     uint16
+	Offset int  // bytes into the original datagram's payload
+	More   bool // MF: more fragments follow
+	Length int  // payload bytes this fragment carries
+}
+
+// FragmentConfig holds the limits of fragment reassembly tracking
+type FragmentConfig struct {
+	Timeout      time.Duration // give up on a datagram this long after its first fragment (Linux: 30 s)
+	MaxPending   int           // datagrams tracked at once; fragments of others are only counted
+	MaxFragments int           // fragments tracked per datagram
+	TinyFirst    int           // a first fragment with less payload than this can't hold a TCP header
+	TopN         int
+}
+
+// DefaultFragmentConfig returns limits matching a typical host's reassembly queue
+func DefaultFragmentConfig() FragmentConfig {
+	return FragmentConfig{
+		Timeout:      30 * time.Second,
+		MaxPending:   10000,
+		MaxFragments: 256,
+		TinyFirst:    20,
+		TopN:         10,
+	}
+}
+
+// FragmentStats is the periodic fragment_stats message
+type FragmentStats struct {
+	Type        string      `json:"type"`
+	Timestamp   int64       `json:"timestamp"`
+	IntervalMs  int64       `json:"interval_ms"`
+	Fragments   int64       `json:"fragments"`   // fragment packets this interval
+	Bytes       int64       `json:"bytes"`       // their size
+	Datagrams   int64       `json:"datagrams"`   // fragmented datagrams first seen this interval
+	Reassembled int64       `json:"reassembled"` // datagrams all of whose fragments arrived
+	TimedOut    int64       `json:"timed_out"`   // datagrams still missing fragments after the timeout
+	Overlapping int64       `json:"overlapping"` // datagrams with overlapping fragments (teardrop, IDS evasion)
+	TinyFirst   int64       `json:"tiny_first"`  // first fragments too small for a transport header
+	Pending     int         `json:"pending"`     // datagrams waiting for fragments now
+	TopSources  []HostCount `json:"top_sources"` // hosts sending the most fragments
+}
+
+// ToJSON converts fragment stats to JSON
+func (s *FragmentStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+type fragmentKey struct {
+	src, dst string
+	id       uint16
+}
+
+// fragmentedDatagram is what has arrived of one datagram
+type fragmentedDatagram struct {
+	started time.Time
+	total   int      // payload length, known once the last fragment arrived; -1 until then
+	ranges  [][2]int // byte ranges received
+	overlap bool
+}
+
+// covered returns how many payload bytes from the start of the datagram have arrived without a gap
+func (d *fragmentedDatagram) covered() int {
+	sort.Slice(d.ranges, func(i, j int) bool { return d.ranges[i][0] < d.ranges[j][0] })
+	end := 0
+	for _, r := range d.ranges {
+		if r[0] > end {
+			break
+		}
+		if r[1] > end {
+			end = r[1]
+		}
+	}
+	return end
+}
+
+// FragmentTracker counts IPv4 fragments and follows their datagrams to see whether they would
+// reassemble. It keeps byte ranges only, never payload. It is not safe for concurrent use; each
+// session's forwarder owns one.
+type FragmentTracker struct {
+	config  FragmentConfig
+	pending map[fragmentKey]*fragmentedDatagram
+	sources map[string]int
+	stats   FragmentStats
+	last    time.Time
+}
+
+// NewFragmentTracker creates an empty tracker
+func NewFragmentTracker(config FragmentConfig) *FragmentTracker {
+	return &FragmentTracker{
+		config:  config,
+		pending: make(map[fragmentKey]*fragmentedDatagram),
+		sources: make(map[string]int),
+		last:    time.Now(),
+	}
+}
+
+// Observe counts a fragment. Packets relayed by a sensor are tagged as fragments but carry no
+// header details, so they are counted without reassembly tracking.
+func (t *FragmentTracker) Observe(p *Packet) {
+	if !p.Fragmented {
+		return
+	}
+	t.stats.Fragments++
+	t.stats.Bytes += int64(p.Size)
+	t.sources[p.Src]++
+
+	f := p.Fragment
+	if f == nil {
+		return
+	}
+	if f.Offset == 0 && f.More && f.Length < t.config.TinyFirst {
+		t.stats.TinyFirst++
+	}
+
+	key := fragmentKey{p.Src, p.Dst, f.ID}
+	d, ok := t.pending[key]
+	if !ok {
+		if len(t.pending) >= t.config.MaxPending {
+			return
+		}
+		d = &fragmentedDatagram{started: time.Now(), total: -1}
+		t.pending[key] = d
+		t.stats.Datagrams++
+	}
+	if len(d.ranges) >= t.config.MaxFragments {
+		return
+	}
+
+	start, end := f.Offset, f.Offset+f.Length
+	for _, r := range d.ranges {
+		if start < r[1] && r[0] < end && !d.overlap {
+			d.overlap = true
+			t.stats.Overlapping++
+		}
+	}
+	d.ranges = append(d.ranges, [2]int{start, end})
+	if !f.More {
+		d.total = end
+	}
+	if d.total >= 0 && d.covered() >= d.total {
+		t.stats.Reassembled++
+		delete(t.pending, key)
+	}
+}
+
+// Report closes the current interval, expiring datagrams that waited too long. It returns nil
+// when there were no fragments this interval and none are pending.
+func (t *FragmentTracker) Report() *FragmentStats {
+	now := time.Now()
+	for key, d := range t.pending {
+		if now.Sub(d.started) > t.config.Timeout {
+			t.stats.TimedOut++
+			delete(t.pending, key)
+		}
+	}
+
+	stats := t.stats
+	stats.Pending = len(t.pending)
+	interval := now.Sub(t.last)
+	t.stats = FragmentStats{}
+	t.last = now
+	if stats.Fragments == 0 && stats.TimedOut == 0 && stats.Pending == 0 {
+		t.sources = make(map[string]int)
+		return nil
+	}
+
+	stats.Type = "fragment_stats"
+	stats.Timestamp = now.UnixMilli()
+	stats.IntervalMs = interval.Milliseconds()
+	stats.TopSources = make([]HostCount, 0, len(t.sources))
+	for ip, count := range t.sources {
+		stats.TopSources = append(stats.TopSources, HostCount{IP: ip, Count: count})
+	}
+	sort.Slice(stats.TopSources, func(i, j int) bool {
+		return stats.TopSources[i].Count > stats.TopSources[j].Count
+	})
+	if len(stats.TopSources) > t.config.TopN {
+		stats.TopSources = stats.TopSources[:t.config.TopN]
+	}
+	t.sources = make(map[string]int)
+	return &stats
+}
@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteJSON writes the graph as JSON
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteGraphML writes the graph as GraphML (Gephi, yEd, Cytoscape)
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	for _, key := range []struct{ id, target, attrType string }{
+		{"label", "node", "string"},
+		{"group", "node", "string"},
+		{"packets_tx", "node", "long"},
+		{"packets_rx", "node", "long"},
+		{"bytes_tx", "node", "long"},
+		{"bytes_rx", "node", "long"},
+		{"packets", "edge", "long"},
+		{"bytes", "edge", "long"},
+		{"protocols", "edge", "string"},
+	} {
+		fmt.Fprintf(bw, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n", key.id, key.target, key.id, key.attrType)
+	}
+	bw.WriteString(`  <graph id="vibes" edgedefault="directed">` + "\n")
+
+	for _, n := range g.Nodes {
+		fmt.Fprintf(bw, `    <node id="%s">`, xmlEscape(n.ID))
+		if n.Label != "" {
+			fmt.Fprintf(bw, `<data key="label">%s</data>`, xmlEscape(n.Label))
+		}
+		if n.Group != "" {
+			fmt.Fprintf(bw, `<data key="group">%s</data>`, xmlEscape(n.Group))
+		}
+		fmt.Fprintf(bw, `<data key="packets_tx">%d</data><data key="packets_rx">%d</data><data key="bytes_tx">%d</data><data key="bytes_rx">%d</data></node>`+"\n",
+			n.PacketsTx, n.PacketsRx, n.BytesTx, n.BytesRx)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, `    <edge source="%s" target="%s"><data key="packets">%d</data><data key="bytes">%d</data><data key="protocols">%s</data></edge>`+"\n",
+			xmlEscape(e.Source), xmlEscape(e.Target), e.Packets, e.Bytes, xmlEscape(protocolList(e.Protocols)))
+	}
+
+	bw.WriteString("  </graph>\n</graphml>\n")
+	return bw.Flush()
+}
+
+// WriteDOT writes the graph in Graphviz DOT format
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("digraph vibes {\n")
+	for _, n := range g.Nodes {
+		label := n.ID
+		if n.Label != "" {
+			label = n.Label + "\\n" + n.ID
+		}
+		fmt.Fprintf(bw, "  %s [label=%s", dotQuote(n.ID), dotQuote(label))
+		if n.Group != "" {
+			fmt.Fprintf(bw, ", group=%s", dotQuote(n.Group))
+		}
+		fmt.Fprintf(bw, ", bytes=%d];\n", n.BytesTx+n.BytesRx)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -> %s [packets=%d, bytes=%d, label=%s];\n",
+			dotQuote(e.Source), dotQuote(e.Target), e.Packets, e.Bytes, dotQuote(protocolList(e.Protocols)))
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+func protocolList(protocols map[string]int) string {
+	names := make([]string, 0, len(protocols))
+	for proto := range protocols {
+		names = append(names, proto)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// dotQuote quotes a DOT ID, keeping "\n" line breaks in labels intact
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,111 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GroupCounters holds traffic totals for one subnet group over a report interval
+type GroupCounters struct {
+	Name          string  `json:"name"`
+	PacketsTx     int64   `json:"packets_tx"` // sourced from the group
+	PacketsRx     int64   `json:"packets_rx"` // destined to the group
+	BytesTx       int64   `json:"bytes_tx"`
+	BytesRx       int64   `json:"bytes_rx"`
+	PacketsPerSec float64 `json:"packets_per_sec"`
+	BitsPerSec    float64 `json:"bits_per_sec"`
+}
+
+// GroupStats is the periodic group_stats message
+type GroupStats struct {
+	Type       string           `json:"type"`
+	Timestamp  int64            `json:"timestamp"`
+	IntervalMs int64            `json:"interval_ms"`
+	Groups     []*GroupCounters `json:"groups"`
+}
+
+// ToJSON converts group stats to JSON
+func (g *GroupStats) ToJSON() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// GroupAccountant tallies packets and bytes per subnet group
+type GroupAccountant struct {
+	mu         sync.Mutex
+	lookup     func(ip string) string
+	counters   map[string]*GroupCounters
+	lastReport time.Time
+}
+
+// NewGroupAccountant creates an accountant using lookup to map IPs to group names
+func NewGroupAccountant(lookup func(ip string) string) *GroupAccountant {
+	return &GroupAccountant{
+		lookup:     lookup,
+		counters:   make(map[string]*GroupCounters),
+		lastReport: time.Now(),
+	}
+}
+
+// Observe attributes a packet to its source and destination groups
+func (g *GroupAccountant) Observe(p *Packet) {
+	srcGroup := g.lookup(p.Src)
+	dstGroup := g.lookup(p.Dst)
+	if srcGroup == "" && dstGroup == "" {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if srcGroup != "" {
+		c := g.counter(srcGroup)
+		c.PacketsTx++
+		c.BytesTx += int64(p.Size)
+	}
+	if dstGroup != "" {
+		c := g.counter(dstGroup)
+		c.PacketsRx++
+		c.BytesRx += int64(p.Size)
+	}
+}
+
+func (g *GroupAccountant) counter(name string) *GroupCounters {
+	c, ok := g.counters[name]
+	if !ok {
+		c = &GroupCounters{Name: name}
+		g.counters[name] = c
+	}
+	return c
+}
+
+// Report returns rates for the interval since the previous report and resets the counters
+func (g *GroupAccountant) Report() *GroupStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	interval := now.Sub(g.lastReport)
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	stats := &GroupStats{
+		Type:       "group_stats",
+		Timestamp:  now.UnixMilli(),
+		IntervalMs: interval.Milliseconds(),
+		Groups:     make([]*GroupCounters, 0, len(g.counters)),
+	}
+	for _, c := range g.counters {
+		c.PacketsPerSec = float64(c.PacketsTx+c.PacketsRx) / interval.Seconds()
+		c.BitsPerSec = float64(c.BytesTx+c.BytesRx) * 8 / interval.Seconds()
+		stats.Groups = append(stats.Groups, c)
+	}
+	sort.Slice(stats.Groups, func(i, j int) bool {
+		return stats.Groups[i].Name < stats.Groups[j].Name
+	})
+
+	g.counters = make(map[string]*GroupCounters)
+	g.lastReport = now
+	return stats
+}
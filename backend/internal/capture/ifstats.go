@@ -0,0 +1,218 @@
+package capture
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// InterfaceCounters are an interface's cumulative kernel counters. Speed is the negotiated link
+// speed in Mbit/s, 0 when the driver doesn't report one (virtual and wireless interfaces).
+type InterfaceCounters struct {
+	RxBytes, TxBytes     uint64
+	RxPackets, TxPackets uint64
+	RxErrors, TxErrors   uint64
+	RxDropped, TxDropped uint64
+	SpeedMbps            int
+}
+
+// CaptureStats are a capture handle's cumulative pcap counters: packets it received, dropped for
+// lack of buffer space, and dropped by the interface or driver before they reached it
+type CaptureStats struct {
+	Received, Dropped, IfDropped uint64
+}
+
+// LiveInterface is a capture reading a network interface. ok is false when the capture has no
+// pcap handle of its own to ask, such as one reading dumpcap's files.
+type LiveInterface interface {
+	Interface() string
+	CaptureStats() (stats CaptureStats, ok bool)
+}
+
+// Where an interface_stats message's link counters come from
+const (
+	LinkSourceKernel = "kernel" // the captured interface, from /sys/class/net
+	LinkSourcePcap   = "pcap"   // only the capture handle's counters, outside Linux
+	LinkSourceSNMP   = "snmp"   // a venue switch port polled over SNMP
+)
+
+// InterfaceStats is one poll of an interface's counters, as rates and per-interval deltas, so the
+// dashboard can show link utilization and tell a saturated tap from a quiet network
+// ("interface_stats")
+type InterfaceStats struct {
+	Type        string  `json:"type"` // always "interface_stats"
+	Interface   string  `json:"interface"`
+	Source      string  `json:"source"`           // kernel, pcap or snmp
+	Device      string  `json:"device,omitempty"` // the switch an SNMP port belongs to
+	IntervalMs  int64   `json:"interval_ms"`      // time the rates and deltas cover
+	RxBps       float64 `json:"rx_bps"`
+	TxBps       float64 `json:"tx_bps"`
+	RxPps       float64 `json:"rx_pps"`
+	TxPps       float64 `json:"tx_pps"`
+	RxErrors    uint64  `json:"rx_errors"`
+	TxErrors    uint64  `json:"tx_errors"`
+	RxDropped   uint64  `json:"rx_dropped"`
+	TxDropped   uint64  `json:"tx_dropped"`
+	SpeedMbps   int     `json:"speed_mbps,omitempty"`
+	Utilization float64 `json:"utilization,omitempty"` // busier direction's share of the link speed, 0-1
+
+	// The capture handle's own counters over the interval; unset for sources without one
+	CaptureReceived  uint64 `json:"capture_received,omitempty"`
+	CaptureDropped   uint64 `json:"capture_dropped,omitempty"`    // the capture fell behind
+	CaptureIfDropped uint64 `json:"capture_if_dropped,omitempty"` // the interface or driver fell behind
+	Timestamp        int64  `json:"timestamp"`
+}
+
+// ToJSON converts interface stats to JSON
+func (s *InterfaceStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// InterfacePoller turns an interface's cumulative counters into rates between polls. It belongs
+// to one session's forwarder; it is not safe for concurrent use.
+type InterfacePoller struct {
+	iface      string
+	counters   InterfaceCounters
+	kernel     bool
+	capture    CaptureStats
+	hasCapture bool
+	at         time.Time
+}
+
+// Poll reads the source's counters and reports what changed since the previous poll. The first
+// poll of an interface, or of a new one after a profile switch, only takes a baseline and
+// reports nil; so does a source that offers no counters at all.
+func (p *InterfacePoller) Poll(source LiveInterface, now time.Time) *InterfaceStats {
+	iface := source.Interface()
+	counters, err := readInterfaceCounters(iface)
+	kernel := err == nil
+	captured, hasCapture := source.CaptureStats()
+	if !kernel && !hasCapture {
+		return nil
+	}
+
+	previous := *p
+	*p = InterfacePoller{iface: iface, counters: counters, kernel: kernel, capture: captured, hasCapture: hasCapture, at: now}
+	elapsed := now.Sub(previous.at)
+	if previous.iface != iface || previous.at.IsZero() || elapsed <= 0 {
+		return nil
+	}
+
+	var stats *InterfaceStats
+	if kernel && previous.kernel {
+		stats = LinkStats(iface, LinkSourceKernel, previous.counters, counters, elapsed, now)
+	} else {
+		stats = &InterfaceStats{Type: "interface_stats", Interface: iface, Source: LinkSourcePcap, IntervalMs: elapsed.Milliseconds(), Timestamp: now.UnixMilli()}
+	}
+	if hasCapture && previous.hasCapture {
+		stats.CaptureReceived = delta(captured.Received, previous.capture.Received)
+		stats.CaptureDropped = delta(captured.Dropped, previous.capture.Dropped)
+		stats.CaptureIfDropped = delta(captured.IfDropped, previous.capture.IfDropped)
+		if stats.Source == LinkSourcePcap {
+			// Without the kernel's counters, what pcap saw is the best view of the link
+			stats.RxPps = float64(stats.CaptureReceived) / elapsed.Seconds()
+		}
+	}
+	return stats
+}
+
+// LinkStats turns two readings of an interface's counters, taken elapsed apart, into rates and
+// per-interval deltas. Utilization is set when the later reading has a link speed.
+func LinkStats(iface, source string, previous, current InterfaceCounters, elapsed time.Duration, now time.Time) *InterfaceStats {
+	seconds := elapsed.Seconds()
+	stats := &InterfaceStats{
+		Type:       "interface_stats",
+		Interface:  iface,
+		Source:     source,
+		IntervalMs: elapsed.Milliseconds(),
+		RxBps:      float64(delta(current.RxBytes, previous.RxBytes)) * 8 / seconds,
+		TxBps:      float64(delta(current.TxBytes, previous.TxBytes)) * 8 / seconds,
+		RxPps:      float64(delta(current.RxPackets, previous.RxPackets)) / seconds,
+		TxPps:      float64(delta(current.TxPackets, previous.TxPackets)) / seconds,
+		RxErrors:   delta(current.RxErrors, previous.RxErrors),
+		TxErrors:   delta(current.TxErrors, previous.TxErrors),
+		RxDropped:  delta(current.RxDropped, previous.RxDropped),
+		TxDropped:  delta(current.TxDropped, previous.TxDropped),
+		Timestamp:  now.UnixMilli(),
+	}
+	if current.SpeedMbps > 0 {
+		stats.SpeedMbps = current.SpeedMbps
+		busier := stats.RxBps
+		if stats.TxBps > busier {
+			busier = stats.TxBps
+		}
+		stats.Utilization = busier / (float64(current.SpeedMbps) * 1e6)
+	}
+	return stats
+}
+
+// delta is how much a cumulative counter grew; a counter that went backwards was reset, as when
+// a driver reloads or a capture restarts, and counts from zero
+func delta(current, previous uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// Interface returns the interface being captured
+func (r *RealCapture) Interface() string {
+	return r.iface
+}
+
+// CaptureStats returns the capture handle's counters; ok is false while it isn't running
+func (r *RealCapture) CaptureStats() (CaptureStats, bool) {
+	r.filterMu.Lock()
+	handle := r.handle
+	r.filterMu.Unlock()
+	if handle == nil {
+		return CaptureStats{}, false
+	}
+	return pcapStats(handle)
+}
+
+// Interface returns the interface being archived
+func (a *ArchiveCapture) Interface() string {
+	return a.iface
+}
+
+// CaptureStats returns the shared archive handle's counters, which cover every subscriber
+func (a *ArchiveCapture) CaptureStats() (CaptureStats, bool) {
+	a.mu.Lock()
+	hub := a.hub
+	a.mu.Unlock()
+	if hub == nil {
+		return CaptureStats{}, false
+	}
+	hub.mu.Lock()
+	handle := hub.handle
+	hub.mu.Unlock()
+	if handle == nil {
+		return CaptureStats{}, false
+	}
+	return pcapStats(handle)
+}
+
+// Interface returns the interface dumpcap captures
+func (d *DumpcapCapture) Interface() string {
+	return d.iface
+}
+
+// CaptureStats is unavailable: dumpcap's own handle is in another process, and the one reading
+// its files sees no drops
+func (d *DumpcapCapture) CaptureStats() (CaptureStats, bool) {
+	return CaptureStats{}, false
+}
+
+func pcapStats(handle *pcap.Handle) (CaptureStats, bool) {
+	stats, err := handle.Stats()
+	if err != nil || stats == nil {
+		return CaptureStats{}, false
+	}
+	return CaptureStats{
+		Received:  uint64(stats.PacketsReceived),
+		Dropped:   uint64(stats.PacketsDropped),
+		IfDropped: uint64(stats.PacketsIfDropped),
+	}, true
+}
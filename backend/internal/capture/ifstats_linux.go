@@ -0,0 +1,45 @@
+//go:build linux
+
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readInterfaceCounters reads an interface's counters from /sys/class/net
+func readInterfaceCounters(iface string) (InterfaceCounters, error) {
+	dir := filepath.Join("/sys/class/net", filepath.Base(iface))
+	var counters InterfaceCounters
+	for name, counter := range map[string]*uint64{
+		"rx_bytes":   &counters.RxBytes,
+		"tx_bytes":   &counters.TxBytes,
+		"rx_packets": &counters.RxPackets,
+		"tx_packets": &counters.TxPackets,
+		"rx_errors":  &counters.RxErrors,
+		"tx_errors":  &counters.TxErrors,
+		"rx_dropped": &counters.RxDropped,
+		"tx_dropped": &counters.TxDropped,
+	} {
+		value, err := readSysValue(filepath.Join(dir, "statistics", name))
+		if err != nil {
+			return InterfaceCounters{}, err
+		}
+		*counter = uint64(value)
+	}
+	// Reading speed fails, or gives -1, for links that are down or have no fixed speed
+	if speed, err := readSysValue(filepath.Join(dir, "speed")); err == nil && speed > 0 {
+		counters.SpeedMbps = int(speed)
+	}
+	return counters, nil
+}
+
+func readSysValue(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
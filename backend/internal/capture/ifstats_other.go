@@ -0,0 +1,10 @@
+//go:build !linux
+
+package capture
+
+import "errors"
+
+// readInterfaceCounters has no kernel counters to read outside Linux; pcap's stand in for them
+func readInterfaceCounters(iface string) (InterfaceCounters, error) {
+	return InterfaceCounters{}, errors.New("interface counters are only read on Linux")
+}
@@ -0,0 +1,194 @@
+package capture
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// Frame sizes used when padding injected packets to their simulated size
+const (
+	injectMaxFrame = 1514 // Ethernet MTU plus header; larger simulated sizes are cut down
+	ethernetHeader = 14
+)
+
+// InjectorStats counts what an injector put on the wire
+type InjectorStats struct {
+	Interface string `json:"interface"`
+	Sent      uint64 `json:"sent"`
+	Bytes     uint64 `json:"bytes"`
+	Failed    uint64 `json:"failed"`    // serialization or write errors
+	Throttled uint64 `json:"throttled"` // over the packets-per-second cap
+}
+
+// Injector transmits simulated packets on an interface as real Ethernet frames, for closed
+// lab networks that want other sensors to see the traffic too. MAC addresses are derived
+// from the IPs (locally administered 02:00:a:b:c:d), so every simulated host looks distinct.
+type Injector struct {
+	iface  string
+	handle *pcap.Handle
+	maxPPS int
+
+	mu          sync.Mutex
+	buf         gopacket.SerializeBuffer
+	second      int64
+	sentThisSec int
+
+	sent, bytes, failed, throttled atomic.Uint64
+}
+
+// NewInjector opens iface for writing; maxPPS caps frames per second (0 = no cap)
+func NewInjector(iface string, maxPPS int) (*Injector, error) {
+	handle, err := pcap.OpenLive(iface, 65535, false, pcap.BlockForever)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s for injection: %w", iface, err)
+	}
+	return &Injector{
+		iface:  iface,
+		handle: handle,
+		maxPPS: maxPPS,
+		buf:    gopacket.NewSerializeBuffer(),
+	}, nil
+}
+
+// Inject writes one packet to the wire, unless that would exceed the rate cap
+func (i *Injector) Inject(p *Packet) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.maxPPS > 0 {
+		if now := time.Now().Unix(); now != i.second {
+			i.second, i.sentThisSec = now, 0
+		}
+		if i.sentThisSec >= i.maxPPS {
+			i.throttled.Add(1)
+			return nil
+		}
+		i.sentThisSec++
+	}
+
+	if err := serializeFrame(i.buf, p); err != nil {
+		i.failed.Add(1)
+		return err
+	}
+	frame := i.buf.Bytes()
+	if err := i.handle.WritePacketData(frame); err != nil {
+		i.failed.Add(1)
+		return err
+	}
+	i.sent.Add(1)
+	i.bytes.Add(uint64(len(frame)))
+	return nil
+}
+
+// Stats returns the injector's counters
+func (i *Injector) Stats() InjectorStats {
+	return InjectorStats{
+		Interface: i.iface,
+		Sent:      i.sent.Load(),
+		Bytes:     i.bytes.Load(),
+		Failed:    i.failed.Load(),
+		Throttled: i.throttled.Load(),
+	}
+}
+
+// Close releases the interface
+func (i *Injector) Close() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handle.Close()
+}
+
+// serializeFrame builds an Ethernet frame for a simulated packet, zero-padded to its size
+func serializeFrame(buf gopacket.SerializeBuffer, p *Packet) error {
+	src, dst := net.ParseIP(p.Src), net.ParseIP(p.Dst)
+	if src == nil || dst == nil {
+		return fmt.Errorf("packet %s → %s: not IP addresses", p.Src, p.Dst)
+	}
+
+	eth := &layers.Ethernet{SrcMAC: hostMAC(src), DstMAC: hostMAC(dst)}
+	var network gopacket.SerializableLayer
+	var networkLayer gopacket.NetworkLayer
+	headers := ethernetHeader
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Flags: layers.IPv4DontFragment, SrcIP: src4, DstIP: dst4}
+		eth.EthernetType = layers.EthernetTypeIPv4
+		network, networkLayer, headers = ip, ip, headers+20
+		switch p.Protocol {
+		case ProtocolTCP:
+			ip.Protocol = layers.IPProtocolTCP
+		case ProtocolUDP:
+			ip.Protocol = layers.IPProtocolUDP
+		case ProtocolICMP:
+			ip.Protocol = layers.IPProtocolICMPv4
+		}
+	} else {
+		ip := &layers.IPv6{Version: 6, HopLimit: 64, SrcIP: src.To16(), DstIP: dst.To16()}
+		eth.EthernetType = layers.EthernetTypeIPv6
+		network, networkLayer, headers = ip, ip, headers+40
+		switch p.Protocol {
+		case ProtocolTCP:
+			ip.NextHeader = layers.IPProtocolTCP
+		case ProtocolUDP:
+			ip.NextHeader = layers.IPProtocolUDP
+		default:
+			return fmt.Errorf("packet %s → %s: %s over IPv6 can't be injected", p.Src, p.Dst, p.Protocol)
+		}
+	}
+
+	var transport gopacket.SerializableLayer
+	switch p.Protocol {
+	case ProtocolTCP:
+		tcp := &layers.TCP{SrcPort: layers.TCPPort(p.SrcPort), DstPort: layers.TCPPort(p.DstPort), Window: 64240, DataOffset: 5}
+		setTCPFlags(tcp, p.TCPFlags)
+		tcp.SetNetworkLayerForChecksum(networkLayer)
+		transport, headers = tcp, headers+20
+	case ProtocolUDP:
+		udp := &layers.UDP{SrcPort: layers.UDPPort(p.SrcPort), DstPort: layers.UDPPort(p.DstPort)}
+		udp.SetNetworkLayerForChecksum(networkLayer)
+		transport, headers = udp, headers+8
+	case ProtocolICMP:
+		transport, headers = &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(8, 0)}, headers+8
+	default:
+		return fmt.Errorf("packet %s → %s: protocol %q can't be injected", p.Src, p.Dst, p.Protocol)
+	}
+
+	size := p.Size
+	if size > injectMaxFrame {
+		size = injectMaxFrame
+	}
+	payload := 0
+	if size > headers {
+		payload = size - headers
+	}
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	return gopacket.SerializeLayers(buf, opts, eth, network, transport, gopacket.Payload(make([]byte, payload)))
+}
+
+// hostMAC derives a stable, locally administered MAC address from an IP
+func hostMAC(ip net.IP) net.HardwareAddr {
+	ip = ip.To16()
+	return net.HardwareAddr{0x02, 0x00, ip[12], ip[13], ip[14], ip[15]}
+}
+
+// setTCPFlags applies a flag string as reported in Packet.TCPFlags ("S", "SA", "FA", "R"); no
+// flags means an established segment, so ACK and PSH are set
+func setTCPFlags(tcp *layers.TCP, flags string) {
+	if flags == "" {
+		tcp.ACK, tcp.PSH = true, true
+		return
+	}
+	tcp.SYN = strings.Contains(flags, "S")
+	tcp.ACK = strings.Contains(flags, "A")
+	tcp.FIN = strings.Contains(flags, "F")
+	tcp.RST = strings.Contains(flags, "R")
+	tcp.PSH = strings.Contains(flags, "P")
+	tcp.URG = strings.Contains(flags, "U")
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"math"
+	"time"
+)
+
+// Edge intensity buckets carried by packets and summaries
+const (
+	IntensityQuiet  = "quiet"
+	IntensityNormal = "normal"
+	IntensityHot    = "hot"
+)
+
+// IntensityConfig holds the rolling window and the thresholds of edge intensity buckets
+type IntensityConfig struct {
+	Window   time.Duration // rates are averaged over roughly this long
+	QuietBps float64       // edges below this many bits per second are quiet
+	HotBps   float64       // edges at or above this many bits per second are hot
+	MaxEdges int           // edges tracked at once; new edges stay quiet while it is full
+}
+
+// DefaultIntensityConfig returns thresholds for a conference network
+func DefaultIntensityConfig() IntensityConfig {
+	return IntensityConfig{
+		Window:   10 * time.Second,
+		QuietBps: 10_000,
+		HotBps:   1_000_000,
+		MaxEdges: 100000,
+	}
+}
+
+type intensityEdge struct {
+	bytes  int64   // since the last tick
+	rate   float64 // rolling bits per second
+	bucket string
+}
+
+// EdgeIntensity buckets each edge (both directions of a host pair together) as quiet, normal
+// or hot from its rolling bit rate, so every screen colors an edge the same way instead of each
+// client interpolating its own. Rates follow an exponential moving average updated by Tick. It
+// is not safe for concurrent use; each session's forwarder owns one.
+type EdgeIntensity struct {
+	config   IntensityConfig
+	edges    map[edgeKey]*intensityEdge
+	lastTick time.Time
+}
+
+// NewEdgeIntensity creates an empty tracker
+func NewEdgeIntensity(config IntensityConfig) *EdgeIntensity {
+	return &EdgeIntensity{
+		config:   config,
+		edges:    make(map[edgeKey]*intensityEdge),
+		lastTick: time.Now(),
+	}
+}
+
+func pairKey(a, b string) edgeKey {
+	if addressBefore(b, a) {
+		a, b = b, a
+	}
+	return edgeKey{a, b}
+}
+
+// Observe adds a packet to its edge's current interval
+func (t *EdgeIntensity) Observe(p *Packet) {
+	key := pairKey(p.Src, p.Dst)
+	edge, ok := t.edges[key]
+	if !ok {
+		if len(t.edges) >= t.config.MaxEdges {
+			return
+		}
+		// A new edge starts out at the rate of its first interval
+		edge = &intensityEdge{rate: -1}
+		t.edges[key] = edge
+	}
+	edge.bytes += int64(p.Size)
+}
+
+// Tick folds the bytes since the previous tick into every edge's rate and re-buckets it.
+// Edges that have gone quiet and stay idle are forgotten.
+func (t *EdgeIntensity) Tick() {
+	now := time.Now()
+	seconds := now.Sub(t.lastTick).Seconds()
+	t.lastTick = now
+	if seconds <= 0 {
+		return
+	}
+	alpha := 1 - math.Exp(-seconds/t.config.Window.Seconds())
+	for key, edge := range t.edges {
+		current := float64(edge.bytes*8) / seconds
+		edge.bytes = 0
+		if edge.rate < 0 {
+			edge.rate = current
+		} else {
+			edge.rate += alpha * (current - edge.rate)
+		}
+		switch {
+		case edge.rate >= t.config.HotBps:
+			edge.bucket = IntensityHot
+		case edge.rate < t.config.QuietBps:
+			edge.bucket = IntensityQuiet
+		default:
+			edge.bucket = IntensityNormal
+		}
+		if current == 0 && edge.rate < 1 {
+			delete(t.edges, key)
+		}
+	}
+}
+
+// Bucket returns the intensity of the edge between two hosts. An edge that has not been rated
+// yet (new since the last tick) is quiet.
+func (t *EdgeIntensity) Bucket(a, b string) string {
+	if edge, ok := t.edges[pairKey(a, b)]; ok && edge.bucket != "" {
+		return edge.bucket
+	}
+	return IntensityQuiet
+}
+
+// AnnotateEdges sets the intensity of every edge in an edge summary
+func (t *EdgeIntensity) AnnotateEdges(s *EdgeSummary) {
+	for _, edge := range s.Edges {
+		edge.Intensity = t.Bucket(edge.Src, edge.Dst)
+	}
+}
+
+// AnnotateConversations sets the intensity of every conversation in a summary
+func (t *EdgeIntensity) AnnotateConversations(s *ConversationSummary) {
+	for _, conversation := range s.Conversations {
+		conversation.Intensity = t.Bucket(conversation.A, conversation.B)
+	}
+}
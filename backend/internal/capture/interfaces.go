@@ -0,0 +1,131 @@
+package capture
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// Recommended usage values reported for each interface
+const (
+	UsageRecommended  = "recommended" // up, addressed and capturable
+	UsageAvailable    = "available"   // capturable but unlikely to be the main traffic interface
+	UsageLoopback     = "loopback"
+	UsageDown         = "down"
+	UsageNoPermission = "no_permission"
+	UsageUnavailable  = "unavailable"
+)
+
+// InterfaceInfo is a capture device with link state and a capture permission preflight.
+// The embedded pcap.Interface keeps the original /api/interfaces fields.
+type InterfaceInfo struct {
+	pcap.Interface
+	Up               bool     `json:"up"`
+	Running          bool     `json:"running"`
+	Loopback         bool     `json:"loopback"`
+	MTU              int      `json:"mtu,omitempty"`
+	HardwareAddr     string   `json:"hardware_addr,omitempty"`
+	IPs              []string `json:"ips"`
+	CapturePermitted *bool    `json:"capture_permitted,omitempty"` // nil when not probed
+	CaptureError     string   `json:"capture_error,omitempty"`
+	Usage            string   `json:"usage"`
+	Hint             string   `json:"hint,omitempty"`
+}
+
+// ListInterfaceDetails returns every pcap device with OS link state. With probe set, each device
+// is briefly activated to learn whether capture will actually succeed with current privileges.
+func ListInterfaceDetails(probe bool) ([]InterfaceInfo, error) {
+	devices, err := pcap.FindAllDevs()
+	if err != nil {
+		return nil, err
+	}
+
+	osInterfaces := make(map[string]net.Interface)
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			osInterfaces[iface.Name] = iface
+		}
+	}
+
+	infos := make([]InterfaceInfo, 0, len(devices))
+	for _, dev := range devices {
+		info := InterfaceInfo{Interface: dev, IPs: []string{}}
+		for _, addr := range dev.Addresses {
+			info.IPs = append(info.IPs, addr.IP.String())
+		}
+
+		if osIface, ok := osInterfaces[dev.Name]; ok {
+			info.Up = osIface.Flags&net.FlagUp != 0
+			info.Running = osIface.Flags&net.FlagRunning != 0
+			info.Loopback = osIface.Flags&net.FlagLoopback != 0
+			info.MTU = osIface.MTU
+			info.HardwareAddr = osIface.HardwareAddr.String()
+		} else {
+			// Windows NPF device names don't match net.Interfaces; fall back to libpcap's flags
+			info.Up = dev.Flags&pcapIfUp != 0
+			info.Running = dev.Flags&pcapIfRunning != 0
+			info.Loopback = dev.Flags&pcapIfLoopback != 0
+		}
+
+		if probe {
+			permitted, probeErr := probeCapture(dev.Name)
+			info.CapturePermitted = &permitted
+			if probeErr != nil {
+				info.CaptureError = probeErr.Error()
+			}
+		}
+		info.Usage, info.Hint = recommendUsage(&info)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// libpcap PCAP_IF_* flags
+const (
+	pcapIfLoopback = 0x00000001
+	pcapIfUp       = 0x00000002
+	pcapIfRunning  = 0x00000004
+)
+
+// probeCapture activates and immediately closes a handle, the same way RealCapture opens one
+func probeCapture(name string) (bool, error) {
+	inactive, err := pcap.NewInactiveHandle(name)
+	if err != nil {
+		return false, err
+	}
+	defer inactive.CleanUp()
+	inactive.SetSnapLen(64)
+	inactive.SetTimeout(100 * time.Millisecond)
+
+	handle, err := inactive.Activate()
+	if err != nil {
+		return false, err
+	}
+	handle.Close()
+	return true, nil
+}
+
+func recommendUsage(info *InterfaceInfo) (usage, hint string) {
+	if info.CapturePermitted != nil && !*info.CapturePermitted {
+		errText := strings.ToLower(info.CaptureError)
+		if strings.Contains(errText, "permission") || strings.Contains(errText, "not permitted") || strings.Contains(errText, "access") {
+			return UsageNoPermission, "run as root/Administrator or grant CAP_NET_RAW (Linux) / access to /dev/bpf* (macOS)"
+		}
+		return UsageUnavailable, info.CaptureError
+	}
+	if info.Loopback {
+		return UsageLoopback, "only sees traffic local to this host"
+	}
+	if !info.Up {
+		return UsageDown, "interface is administratively down"
+	}
+	if !info.Running {
+		return UsageDown, "no link (cable unplugged or not associated)"
+	}
+	if len(info.IPs) == 0 {
+		return UsageAvailable, "no addresses; fine for a SPAN/mirror port, otherwise probably idle"
+	}
+	return UsageRecommended, ""
+}
@@ -0,0 +1,292 @@
+package capture
+
+import (
+	"encoding/json"
+	"math"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LatencyConfig holds the limits for handshake RTT estimation
+type LatencyConfig struct {
+	Window           time.Duration // samples older than this (capture time) leave the percentiles
+	MaxSamples       int           // most recent samples kept per destination
+	MaxDestinations  int
+	MaxHandshakes    int           // handshakes in flight being timed
+	HandshakeTimeout time.Duration // a SYN without its handshake completing is given up on
+	ExternalOnly     bool          // only time handshakes to public addresses
+	TopN             int
+}
+
+// DefaultLatencyConfig returns limits suited to a conference network's uplink
+func DefaultLatencyConfig() LatencyConfig {
+	return LatencyConfig{
+		Window:           5 * time.Minute,
+		MaxSamples:       256,
+		MaxDestinations:  10000,
+		MaxHandshakes:    50000,
+		HandshakeTimeout: 10 * time.Second,
+		ExternalOnly:     true,
+		TopN:             50,
+	}
+}
+
+// LatencyPercentiles are round-trip times in milliseconds
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// DestinationLatency is the handshake round-trip time to one destination over the window
+type DestinationLatency struct {
+	IP       string             `json:"ip"`
+	Samples  int                `json:"samples"`  // handshakes timed within the window
+	RTT      LatencyPercentiles `json:"rtt"`      // SYN to ACK: the client's round trip to the destination
+	Upstream LatencyPercentiles `json:"upstream"` // SYN to SYN/ACK: from the capture point to the destination and back
+}
+
+// LatencyStats is the periodic latency_stats message
+type LatencyStats struct {
+	Type         string               `json:"type"`
+	Timestamp    int64                `json:"timestamp"`
+	IntervalMs   int64                `json:"interval_ms"`
+	WindowMs     int64                `json:"window_ms"`
+	Handshakes   int64                `json:"handshakes"`   // timed this interval
+	Destinations int                  `json:"destinations"` // with samples within the window
+	Top          []DestinationLatency `json:"top"`          // the busiest destinations
+}
+
+// ToJSON converts latency stats to JSON
+func (s *LatencyStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// handshakeKey is a connection as its initiator sent it
+type handshakeKey struct {
+	client, server         string
+	clientPort, serverPort int
+}
+
+type handshake struct {
+	syn, synAck time.Time
+	ambiguous   bool // a SYN or SYN/ACK was retransmitted, so the replies can't be told apart
+}
+
+type latencySample struct {
+	at            time.Time
+	rtt, upstream float64 // ms
+}
+
+// destinationSamples is a ring of a destination's most recent samples
+type destinationSamples struct {
+	samples []latencySample
+	next    int
+}
+
+// LatencyTracker times TCP handshakes to estimate the round-trip time to each destination: the
+// SYN to SYN/ACK gap is the capture point's distance from the server, and the SYN to ACK gap the
+// client's. Only packets carrying their capture timestamp count, so simulated and relayed traffic
+// is ignored. It is not safe for concurrent use; each session's forwarder owns one.
+type LatencyTracker struct {
+	config       LatencyConfig
+	handshakes   map[handshakeKey]*handshake
+	destinations map[string]*destinationSamples
+	timed        int64
+	clock        time.Time // latest capture timestamp, so replays age samples by their own time
+	last         time.Time
+}
+
+// NewLatencyTracker creates an empty tracker
+func NewLatencyTracker(config LatencyConfig) *LatencyTracker {
+	return &LatencyTracker{
+		config:       config,
+		handshakes:   make(map[handshakeKey]*handshake),
+		destinations: make(map[string]*destinationSamples),
+		last:         time.Now(),
+	}
+}
+
+// Observe follows a packet through the handshake it belongs to
+func (t *LatencyTracker) Observe(p *Packet) {
+	at := p.CaptureInfo.Timestamp
+	if p.Protocol != ProtocolTCP || p.TCPFlags == "" || at.IsZero() {
+		return
+	}
+	if at.After(t.clock) {
+		t.clock = at
+	}
+	syn := strings.IndexByte(p.TCPFlags, 'S') >= 0
+	ack := strings.IndexByte(p.TCPFlags, 'A') >= 0
+	rst := strings.IndexByte(p.TCPFlags, 'R') >= 0
+
+	switch {
+	case syn && !ack:
+		if t.config.ExternalOnly && !isPublic(p.Dst) {
+			return
+		}
+		key := handshakeKey{p.Src, p.Dst, p.SrcPort, p.DstPort}
+		if h, ok := t.handshakes[key]; ok {
+			h.ambiguous = true
+			return
+		}
+		if len(t.handshakes) < t.config.MaxHandshakes {
+			t.handshakes[key] = &handshake{syn: at}
+		}
+
+	case syn && ack:
+		key := handshakeKey{p.Dst, p.Src, p.DstPort, p.SrcPort}
+		if h, ok := t.handshakes[key]; ok {
+			if !h.synAck.IsZero() {
+				h.ambiguous = true
+			}
+			h.synAck = at
+		}
+
+	default:
+		key := handshakeKey{p.Src, p.Dst, p.SrcPort, p.DstPort}
+		h, ok := t.handshakes[key]
+		if !ok {
+			key = handshakeKey{p.Dst, p.Src, p.DstPort, p.SrcPort}
+			if h, ok = t.handshakes[key]; !ok {
+				return
+			}
+		}
+		// The initiator's first ACK completes the handshake; anything else ends it untimed
+		if ack && !rst && key.client == p.Src && !h.synAck.IsZero() && !h.ambiguous {
+			t.record(key.server, latencySample{
+				at:       at,
+				rtt:      milliseconds(at.Sub(h.syn)),
+				upstream: milliseconds(h.synAck.Sub(h.syn)),
+			})
+		}
+		delete(t.handshakes, key)
+	}
+}
+
+func (t *LatencyTracker) record(server string, sample latencySample) {
+	if sample.rtt < 0 || sample.upstream < 0 || sample.upstream > sample.rtt {
+		return // out of order timestamps, e.g. a merged capture
+	}
+	dest, ok := t.destinations[server]
+	if !ok {
+		if len(t.destinations) >= t.config.MaxDestinations {
+			return
+		}
+		dest = &destinationSamples{}
+		t.destinations[server] = dest
+	}
+	if len(dest.samples) < t.config.MaxSamples {
+		dest.samples = append(dest.samples, sample)
+	} else {
+		dest.samples[dest.next] = sample
+		dest.next = (dest.next + 1) % len(dest.samples)
+	}
+	t.timed++
+}
+
+// Report closes the current interval, returning the busiest destinations' percentiles over the
+// window, or nil when nothing was timed within it
+func (t *LatencyTracker) Report() *LatencyStats {
+	now := time.Now()
+	cutoff := t.clock.Add(-t.config.Window)
+	for key, h := range t.handshakes {
+		if t.clock.Sub(h.syn) > t.config.HandshakeTimeout {
+			delete(t.handshakes, key)
+		}
+	}
+
+	type candidate struct {
+		ip      string
+		samples []latencySample
+	}
+	var candidates []candidate
+	for ip, dest := range t.destinations {
+		expired := 0
+		for _, sample := range dest.samples {
+			if !sample.at.After(cutoff) {
+				expired++
+			}
+		}
+		if expired == len(dest.samples) {
+			delete(t.destinations, ip)
+			continue
+		}
+		if expired > 0 {
+			// Ring order no longer matters once old samples are gone; new ones append again
+			recent := make([]latencySample, 0, len(dest.samples)-expired)
+			for _, sample := range dest.samples {
+				if sample.at.After(cutoff) {
+					recent = append(recent, sample)
+				}
+			}
+			dest.samples, dest.next = recent, 0
+		}
+		candidates = append(candidates, candidate{ip, dest.samples})
+	}
+
+	stats := &LatencyStats{
+		Type:         "latency_stats",
+		Timestamp:    now.UnixMilli(),
+		IntervalMs:   now.Sub(t.last).Milliseconds(),
+		WindowMs:     t.config.Window.Milliseconds(),
+		Handshakes:   t.timed,
+		Destinations: len(candidates),
+	}
+	t.timed = 0
+	t.last = now
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i].samples) != len(candidates[j].samples) {
+			return len(candidates[i].samples) > len(candidates[j].samples)
+		}
+		return candidates[i].ip < candidates[j].ip
+	})
+	if len(candidates) > t.config.TopN {
+		candidates = candidates[:t.config.TopN]
+	}
+	stats.Top = make([]DestinationLatency, 0, len(candidates))
+	for _, c := range candidates {
+		rtts := make([]float64, len(c.samples))
+		upstreams := make([]float64, len(c.samples))
+		for i, sample := range c.samples {
+			rtts[i], upstreams[i] = sample.rtt, sample.upstream
+		}
+		stats.Top = append(stats.Top, DestinationLatency{
+			IP:       c.ip,
+			Samples:  len(c.samples),
+			RTT:      percentiles(rtts),
+			Upstream: percentiles(upstreams),
+		})
+	}
+	return stats
+}
+
+// percentiles sorts values and picks the nearest-rank p50, p90 and p99
+func percentiles(values []float64) LatencyPercentiles {
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(values)))) - 1
+		return values[max(i, 0)]
+	}
+	return LatencyPercentiles{P50: rank(0.5), P90: rank(0.9), P99: rank(0.99)}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// isPublic reports whether an address is a global unicast address outside the private ranges
+func isPublic(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
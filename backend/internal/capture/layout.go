@@ -0,0 +1,467 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// LayoutConfig holds the limits of layout hinting
+type LayoutConfig struct {
+	MinNodes  int           // graphs smaller than this are left to the browser's own layout
+	HalfLife  time.Duration // an edge's weight halves after this long without packets
+	MaxEdges  int           // edges tracked at once; new edges are ignored while it is full
+	MaxLinks  int           // heaviest links between communities listed per report
+	MaxLevels int           // community merging rounds at most
+}
+
+// DefaultLayoutConfig returns limits for a graph of some ten thousand hosts
+func DefaultLayoutConfig() LayoutConfig {
+	return LayoutConfig{
+		MinNodes:  5000,
+		HalfLife:  30 * time.Second,
+		MaxEdges:  250000,
+		MaxLinks:  1000,
+		MaxLevels: 8,
+	}
+}
+
+// LayoutHints is the periodic coarse layout of a large graph ("layout_hints"). Browsers lay out
+// the communities and place each one's members around it, instead of running a force-directed
+// layout over every host.
+type LayoutHints struct {
+	Type        string             `json:"type"` // always "layout_hints"
+	Timestamp   int64              `json:"timestamp"`
+	Nodes       int                `json:"nodes"`
+	Edges       int                `json:"edges"`
+	Communities []*LayoutCommunity `json:"communities"` // largest first
+	Links       []*CommunityLink   `json:"links"`       // heaviest first
+}
+
+// LayoutCommunity is a group of hosts that talk mostly among themselves. IDs carry over between
+// reports while most of a community's members stay together.
+type LayoutCommunity struct {
+	ID      int      `json:"id"`
+	Members []string `json:"members"` // innermost first
+	Cores   []int    `json:"cores"`   // k-core number of each member: the deeper, the more central
+}
+
+// CommunityLink is the traffic between two communities
+type CommunityLink struct {
+	A      int     `json:"a"`
+	B      int     `json:"b"`
+	Weight float64 `json:"weight"` // recent packets, decayed by the half-life
+}
+
+// ToJSON converts layout hints to JSON
+func (h *LayoutHints) ToJSON() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+type layoutEdge struct {
+	weight float64
+	seen   time.Time
+}
+
+// LayoutHinter groups the hosts of a large graph into communities (Louvain modularity) and ranks
+// them by k-core, from recent traffic with both directions of a pair counted together. It is not
+// safe for concurrent use; each session's forwarder owns one.
+type LayoutHinter struct {
+	config      LayoutConfig
+	edges       map[edgeKey]*layoutEdge
+	communities map[string]int // community ID of each host in the last report
+	nextID      int
+	lastReport  time.Time
+}
+
+// NewLayoutHinter creates an empty hinter
+func NewLayoutHinter(config LayoutConfig) *LayoutHinter {
+	return &LayoutHinter{
+		config:      config,
+		edges:       make(map[edgeKey]*layoutEdge),
+		communities: make(map[string]int),
+		lastReport:  time.Now(),
+	}
+}
+
+// Observe adds a packet to its pair's weight
+func (h *LayoutHinter) Observe(p *Packet) {
+	if p.Src == p.Dst {
+		return
+	}
+	key := pairKey(p.Src, p.Dst)
+	edge, ok := h.edges[key]
+	if !ok {
+		if len(h.edges) >= h.config.MaxEdges {
+			return
+		}
+		edge = &layoutEdge{}
+		h.edges[key] = edge
+	}
+	edge.weight++
+	edge.seen = time.Now()
+}
+
+// Report decays the graph and returns its layout hints, or nil while it has fewer than
+// MinNodes hosts
+func (h *LayoutHinter) Report() *LayoutHints {
+	now := time.Now()
+	decay := math.Exp2(-now.Sub(h.lastReport).Seconds() / h.config.HalfLife.Seconds())
+	h.lastReport = now
+
+	// Index hosts in address order, so a graph that did not change lays out the same way
+	index := make(map[string]int)
+	var names []string
+	for key := range h.edges {
+		for _, ip := range []string{key.src, key.dst} {
+			if _, ok := index[ip]; !ok {
+				index[ip] = len(names)
+				names = append(names, ip)
+			}
+		}
+	}
+	if len(names) < h.config.MinNodes {
+		h.decay(decay)
+		return nil
+	}
+	sortAddresses(names)
+	for i, ip := range names {
+		index[ip] = i
+	}
+	edges := make([]graphLink, 0, len(h.edges))
+	for key, edge := range h.edges {
+		edges = append(edges, graphLink{index[key.src], index[key.dst], edge.weight})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].a != edges[j].a {
+			return edges[i].a < edges[j].a
+		}
+		return edges[i].b < edges[j].b
+	})
+	h.decay(decay)
+
+	membership := louvain(len(names), edges, h.config.MaxLevels)
+	cores := coreNumbers(len(names), edges)
+	ids := h.stableIDs(names, membership)
+
+	byID := make(map[int]*LayoutCommunity)
+	for i, ip := range names {
+		community, ok := byID[ids[membership[i]]]
+		if !ok {
+			community = &LayoutCommunity{ID: ids[membership[i]]}
+			byID[community.ID] = community
+		}
+		community.Members = append(community.Members, ip)
+		community.Cores = append(community.Cores, cores[i])
+	}
+	hints := &LayoutHints{
+		Type:        "layout_hints",
+		Timestamp:   now.UnixMilli(),
+		Nodes:       len(names),
+		Edges:       len(edges),
+		Communities: make([]*LayoutCommunity, 0, len(byID)),
+		Links:       []*CommunityLink{},
+	}
+	for _, community := range byID {
+		sort.Stable(byCore{community})
+		hints.Communities = append(hints.Communities, community)
+	}
+	sort.Slice(hints.Communities, func(i, j int) bool {
+		a, b := hints.Communities[i], hints.Communities[j]
+		if len(a.Members) != len(b.Members) {
+			return len(a.Members) > len(b.Members)
+		}
+		return a.ID < b.ID
+	})
+
+	links := make(map[[2]int]float64)
+	for _, edge := range edges {
+		a, b := ids[membership[edge.a]], ids[membership[edge.b]]
+		if a == b {
+			continue
+		}
+		if b < a {
+			a, b = b, a
+		}
+		links[[2]int{a, b}] += edge.weight
+	}
+	for pair, weight := range links {
+		hints.Links = append(hints.Links, &CommunityLink{A: pair[0], B: pair[1], Weight: math.Round(weight*100) / 100})
+	}
+	sort.Slice(hints.Links, func(i, j int) bool {
+		a, b := hints.Links[i], hints.Links[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if a.A != b.A {
+			return a.A < b.A
+		}
+		return a.B < b.B
+	})
+	if len(hints.Links) > h.config.MaxLinks {
+		hints.Links = hints.Links[:h.config.MaxLinks]
+	}
+	return hints
+}
+
+// decay fades every edge and forgets those down to a fraction of one packet
+func (h *LayoutHinter) decay(factor float64) {
+	for key, edge := range h.edges {
+		edge.weight *= factor
+		if edge.weight < 0.05 {
+			delete(h.edges, key)
+		}
+	}
+}
+
+// stableIDs names each community after the previous report's community most of its members
+// came from, biggest communities choosing first, so browsers can keep their positions
+func (h *LayoutHinter) stableIDs(names []string, membership []int) map[int]int {
+	members := make(map[int][]string)
+	for i, ip := range names {
+		members[membership[i]] = append(members[membership[i]], ip)
+	}
+	order := make([]int, 0, len(members))
+	for community := range members {
+		order = append(order, community)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := members[order[i]], members[order[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return order[i] < order[j]
+	})
+
+	ids := make(map[int]int, len(order))
+	taken := make(map[int]bool)
+	for _, community := range order {
+		overlap := make(map[int]int)
+		for _, ip := range members[community] {
+			if previous, ok := h.communities[ip]; ok && !taken[previous] {
+				overlap[previous]++
+			}
+		}
+		best, bestCount := 0, 0
+		for previous, count := range overlap {
+			if count > bestCount || (count == bestCount && previous < best) {
+				best, bestCount = previous, count
+			}
+		}
+		if bestCount == 0 {
+			h.nextID++
+			best = h.nextID
+		}
+		taken[best] = true
+		ids[community] = best
+	}
+
+	h.communities = make(map[string]int, len(names))
+	for i, ip := range names {
+		h.communities[ip] = ids[membership[i]]
+	}
+	return ids
+}
+
+// byCore orders a community's members innermost first, keeping address order within a core
+type byCore struct{ c *LayoutCommunity }
+
+func (s byCore) Len() int           { return len(s.c.Members) }
+func (s byCore) Less(i, j int) bool { return s.c.Cores[i] > s.c.Cores[j] }
+func (s byCore) Swap(i, j int) {
+	s.c.Members[i], s.c.Members[j] = s.c.Members[j], s.c.Members[i]
+	s.c.Cores[i], s.c.Cores[j] = s.c.Cores[j], s.c.Cores[i]
+}
+
+// sortAddresses sorts IPs numerically, parsing each one once
+func sortAddresses(ips []string) {
+	keys := make(map[string][]byte, len(ips))
+	for _, ip := range ips {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			keys[ip] = parsed.To16()
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		a, b := keys[ips[i]], keys[ips[j]]
+		if a == nil || b == nil {
+			return ips[i] < ips[j]
+		}
+		return bytes.Compare(a, b) < 0
+	})
+}
+
+// graphLink is an undirected weighted edge between node indexes
+type graphLink struct {
+	a, b   int
+	weight float64
+}
+
+// louvain returns the community of every node, found by greedily moving nodes to the neighboring
+// community that raises modularity most and then merging each community into a single node,
+// until nothing moves
+func louvain(n int, edges []graphLink, maxLevels int) []int {
+	membership := make([]int, n)
+	for i := range membership {
+		membership[i] = i
+	}
+	self := make([]float64, n) // weight inside each node, from merged levels
+	for level := 0; level < maxLevels; level++ {
+		adjacency := make([][]graphLink, n)
+		degree := make([]float64, n)
+		var total float64
+		for _, edge := range edges {
+			adjacency[edge.a] = append(adjacency[edge.a], graphLink{edge.a, edge.b, edge.weight})
+			adjacency[edge.b] = append(adjacency[edge.b], graphLink{edge.b, edge.a, edge.weight})
+			degree[edge.a] += edge.weight
+			degree[edge.b] += edge.weight
+		}
+		for i := range degree {
+			degree[i] += 2 * self[i]
+			total += degree[i]
+		}
+		if total == 0 {
+			break
+		}
+
+		community := make([]int, n)
+		sums := make([]float64, n) // total degree of each community
+		for i := range community {
+			community[i] = i
+			sums[i] = degree[i]
+		}
+		neighbors := make([]float64, n)
+		var touched []int
+		moved := false
+		for pass := 0; pass < 16; pass++ {
+			changed := false
+			for i := 0; i < n; i++ {
+				touched = touched[:0]
+				for _, edge := range adjacency[i] {
+					c := community[edge.b]
+					if neighbors[c] == 0 {
+						touched = append(touched, c)
+					}
+					neighbors[c] += edge.weight
+				}
+				current := community[i]
+				sums[current] -= degree[i]
+				best := current
+				bestGain := neighbors[current] - sums[current]*degree[i]/total
+				for _, c := range touched {
+					if gain := neighbors[c] - sums[c]*degree[i]/total; gain > bestGain+1e-12 {
+						best, bestGain = c, gain
+					}
+				}
+				sums[best] += degree[i]
+				community[i] = best
+				if best != current {
+					changed = true
+				}
+				for _, c := range touched {
+					neighbors[c] = 0
+				}
+			}
+			if !changed {
+				break
+			}
+			moved = true
+		}
+		if !moved {
+			break
+		}
+
+		// Merge each community into one node for the next level
+		renumber := make(map[int]int)
+		for i := 0; i < n; i++ {
+			if _, ok := renumber[community[i]]; !ok {
+				renumber[community[i]] = len(renumber)
+			}
+		}
+		merged := make(map[[2]int]float64)
+		nextSelf := make([]float64, len(renumber))
+		for i := 0; i < n; i++ {
+			nextSelf[renumber[community[i]]] += self[i]
+		}
+		for _, edge := range edges {
+			a, b := renumber[community[edge.a]], renumber[community[edge.b]]
+			if a == b {
+				nextSelf[a] += edge.weight
+				continue
+			}
+			if b < a {
+				a, b = b, a
+			}
+			merged[[2]int{a, b}] += edge.weight
+		}
+		edges = make([]graphLink, 0, len(merged))
+		for pair, weight := range merged {
+			edges = append(edges, graphLink{pair[0], pair[1], weight})
+		}
+		sort.Slice(edges, func(i, j int) bool {
+			if edges[i].a != edges[j].a {
+				return edges[i].a < edges[j].a
+			}
+			return edges[i].b < edges[j].b
+		})
+		for i := range membership {
+			membership[i] = renumber[community[membership[i]]]
+		}
+		n, self = len(renumber), nextSelf
+	}
+	return membership
+}
+
+// coreNumbers returns the k-core number of every node: the largest k such that the node is in
+// a subgraph where every node has at least k neighbors
+func coreNumbers(n int, edges []graphLink) []int {
+	adjacency := make([][]int, n)
+	for _, edge := range edges {
+		adjacency[edge.a] = append(adjacency[edge.a], edge.b)
+		adjacency[edge.b] = append(adjacency[edge.b], edge.a)
+	}
+	degree := make([]int, n)
+	maxDegree := 0
+	for i := range adjacency {
+		degree[i] = len(adjacency[i])
+		if degree[i] > maxDegree {
+			maxDegree = degree[i]
+		}
+	}
+
+	// Peel nodes off in order of remaining degree, bucketed by degree
+	start := make([]int, maxDegree+2)
+	for _, d := range degree {
+		start[d+1]++
+	}
+	for d := 1; d < len(start); d++ {
+		start[d] += start[d-1]
+	}
+	order := make([]int, n)    // nodes sorted by degree
+	position := make([]int, n) // where each node is in order
+	next := append([]int(nil), start...)
+	for i, d := range degree {
+		position[i] = next[d]
+		order[position[i]] = i
+		next[d]++
+	}
+	for i := 0; i < n; i++ {
+		v := order[i]
+		for _, u := range adjacency[v] {
+			if degree[u] > degree[v] {
+				// Move u to the front of its bucket, then shrink its degree by one
+				du := degree[u]
+				first := order[start[du]]
+				if first != u {
+					order[position[u]], order[start[du]] = first, u
+					position[first], position[u] = position[u], start[du]
+				}
+				start[du]++
+				degree[u]--
+			}
+		}
+	}
+	return degree
+}
@@ -0,0 +1,143 @@
+package capture
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+)
+
+// ManifestSuffix names a capture file's sidecar manifest: capture.pcap → capture.pcap.manifest.json
+const ManifestSuffix = ".manifest.json"
+
+// manifestVersion is bumped when manifests gain something the index needs, so older ones are
+// written again
+const manifestVersion = 1
+
+// manifestSettle is how long a capture file must go unmodified before it counts as rotated and
+// gets a manifest; dumpcap's current file changes every few seconds
+const manifestSettle = 2 * time.Minute
+
+// maxManifestIPs is how many of a file's busiest addresses its manifest lists
+const maxManifestIPs = 10
+
+// IPCount is one address's share of a capture file, counting packets it sent or received
+type IPCount struct {
+	IP      string `json:"ip"`
+	Packets int64  `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// manifest is the sidecar written next to a rotated capture file, so the archive index, coverage,
+// heatmaps and replay progress never read the file itself again
+type manifest struct {
+	Version int `json:"version"`
+	*CaptureIndex
+}
+
+// ManifestReader is an archive store that can read the sidecar manifests next to its capture files
+type ManifestReader interface {
+	ReadManifest(path string) ([]byte, error)
+}
+
+// ManifestWriter is an archive store that can write sidecar manifests
+type ManifestWriter interface {
+	WriteManifest(path string, data []byte) error
+}
+
+// ReadManifest reads the sidecar manifest of a capture file
+func (d DirStore) ReadManifest(path string) ([]byte, error) {
+	return os.ReadFile(path + ManifestSuffix)
+}
+
+// WriteManifest replaces the sidecar manifest of a capture file
+func (d DirStore) WriteManifest(path string, data []byte) error {
+	tmp := path + ManifestSuffix + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path+ManifestSuffix); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// loadManifest returns a capture file's index entry from its manifest, if the store has one that
+// is current. Capture files only grow, so a manifest of the same size describes the same file;
+// a copy uploaded to a bucket keeps its manifest although its modification time changed.
+func loadManifest(store ArchiveStore, obj ArchiveObject) (*CaptureIndex, bool) {
+	reader, ok := store.(ManifestReader)
+	if !ok {
+		return nil, false
+	}
+	data, err := reader.ReadManifest(obj.Path)
+	if err != nil {
+		return nil, false
+	}
+	m := manifest{CaptureIndex: &CaptureIndex{}}
+	if err := json.Unmarshal(data, &m); err != nil || m.Version != manifestVersion || m.FileSize != obj.Size {
+		return nil, false
+	}
+	entry := m.CaptureIndex
+	entry.FilePath, entry.ModTime = obj.Path, obj.ModTime
+	return entry, true
+}
+
+// saveManifest writes a capture file's manifest once the file has settled, and reports whether
+// it did
+func saveManifest(store ArchiveStore, obj ArchiveObject, entry *CaptureIndex) bool {
+	writer, ok := store.(ManifestWriter)
+	if !ok || time.Since(obj.ModTime) < manifestSettle {
+		return false
+	}
+	data, err := json.MarshalIndent(manifest{Version: manifestVersion, CaptureIndex: entry}, "", "  ")
+	if err != nil {
+		return false
+	}
+	return writer.WriteManifest(obj.Path, data) == nil
+}
+
+// RenameManifest moves a capture file's manifest along when the file is renamed with a new size,
+// as compression does. Files without a manifest are left alone.
+func RenameManifest(oldPath, newPath string, size int64) error {
+	data, err := os.ReadFile(oldPath + ManifestSuffix)
+	if err != nil {
+		return nil
+	}
+	m := manifest{CaptureIndex: &CaptureIndex{}}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return os.Remove(oldPath + ManifestSuffix)
+	}
+	m.FilePath, m.FileSize = newPath, size
+	if data, err = json.MarshalIndent(m, "", "  "); err != nil {
+		return err
+	}
+	if err := DirStore("").WriteManifest(newPath, data); err != nil {
+		return err
+	}
+	return os.Remove(oldPath + ManifestSuffix)
+}
+
+// RemoveManifest deletes a capture file's manifest, if it has one
+func RemoveManifest(path string) {
+	os.Remove(path + ManifestSuffix)
+}
+
+// topIPs returns the addresses with the most bytes, busiest first
+func topIPs(counts map[string]*IPCount, n int) []IPCount {
+	top := make([]IPCount, 0, len(counts))
+	for _, count := range counts {
+		top = append(top, *count)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Bytes != top[j].Bytes {
+			return top[i].Bytes > top[j].Bytes
+		}
+		return top[i].IP < top[j].IP
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
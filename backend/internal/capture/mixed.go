@@ -0,0 +1,104 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MixedCapture overlays a simulation on another capture, usually live traffic, in one
+// stream. Packets keep the Source of the capture they came from, so a simulated attack
+// can be told apart from the real background it is played over.
+type MixedCapture struct {
+	base       PacketCapture
+	sim        *SimulatedCapture
+	packetChan chan *Packet
+	stopChan   chan struct{}
+	done       chan struct{}
+	running    bool
+	mu         sync.Mutex
+}
+
+// NewMixedCapture merges base and the simulation
+func NewMixedCapture(base PacketCapture, sim *SimulatedCapture) *MixedCapture {
+	return &MixedCapture{
+		base:       base,
+		sim:        sim,
+		packetChan: make(chan *Packet, 10000),
+	}
+}
+
+// Base returns the capture the simulation is overlaid on
+func (m *MixedCapture) Base() PacketCapture {
+	return m.base
+}
+
+// Simulation returns the overlaid simulation, to switch its scenario or trigger attacks
+func (m *MixedCapture) Simulation() *SimulatedCapture {
+	return m.sim
+}
+
+// Start starts both captures; if either fails, neither runs
+func (m *MixedCapture) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return fmt.Errorf("mixed capture already running")
+	}
+	if err := m.base.Start(); err != nil {
+		return err
+	}
+	if err := m.sim.Start(); err != nil {
+		m.base.Stop()
+		return fmt.Errorf("simulation overlay: %v", err)
+	}
+	m.stopChan = make(chan struct{})
+	m.done = make(chan struct{})
+	m.running = true
+	go m.merge(m.stopChan, m.done)
+	return nil
+}
+
+// Stop stops both captures
+func (m *MixedCapture) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running {
+		return fmt.Errorf("mixed capture not running")
+	}
+	close(m.stopChan)
+	<-m.done
+	m.sim.Stop()
+	m.base.Stop()
+	m.running = false
+	return nil
+}
+
+// GetPacketChannel returns the channel to receive packets from both captures
+func (m *MixedCapture) GetPacketChannel() <-chan *Packet {
+	return m.packetChan
+}
+
+func (m *MixedCapture) merge(stop, done chan struct{}) {
+	defer close(done)
+	baseChan, simChan := m.base.GetPacketChannel(), m.sim.GetPacketChannel()
+	for {
+		var p *Packet
+		var ok bool
+		select {
+		case <-stop:
+			return
+		case p, ok = <-baseChan:
+			if !ok {
+				// A finished replay leaves the simulation running on its own
+				baseChan = nil
+				continue
+			}
+		case p = <-simChan:
+		}
+		select {
+		case m.packetChan <- p:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package capture
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Cast classes of packets sent to more than one host
+const (
+	CastMulticast = "multicast"
+	CastBroadcast = "broadcast"
+)
+
+// multicastGroups names the well-known IPv4 multicast groups
+var multicastGroups = map[netip.Addr]string{
+	netip.MustParseAddr("224.0.0.1"):       "igmp", // all hosts: IGMP queries
+	netip.MustParseAddr("224.0.0.2"):       "igmp", // all routers: IGMP leaves
+	netip.MustParseAddr("224.0.0.22"):      "igmp", // IGMPv3 membership reports
+	netip.MustParseAddr("224.0.0.5"):       "ospf",
+	netip.MustParseAddr("224.0.0.6"):       "ospf",
+	netip.MustParseAddr("224.0.0.9"):       "rip",
+	netip.MustParseAddr("224.0.0.13"):      "pim",
+	netip.MustParseAddr("224.0.0.18"):      "vrrp",
+	netip.MustParseAddr("224.0.0.102"):     "hsrp",
+	netip.MustParseAddr("224.0.0.251"):     "mdns",
+	netip.MustParseAddr("224.0.0.252"):     "llmnr",
+	netip.MustParseAddr("224.0.1.1"):       "ntp",
+	netip.MustParseAddr("224.0.1.129"):     "ptp",
+	netip.MustParseAddr("239.255.255.250"): "ssdp",
+	netip.MustParseAddr("239.255.255.253"): "slp",
+}
+
+// castPorts names group traffic by destination port, for groups without a well-known address
+var castPorts = map[int]string{
+	67:   "dhcp",
+	68:   "dhcp",
+	137:  "netbios",
+	138:  "netbios",
+	1900: "ssdp",
+	3702: "ws-discovery",
+	5353: "mdns",
+	5355: "llmnr",
+}
+
+var limitedBroadcast = netip.AddrFrom4([4]byte{255, 255, 255, 255})
+
+// CastOf classifies a packet's destination: "multicast" for 224.0.0.0/4, "broadcast" for
+// 255.255.255.255, empty for unicast. group names the protocol behind group traffic (mdns, ssdp,
+// igmp, dhcp, ...), or is "other" when nothing more is known.
+func CastOf(p *Packet) (cast, group string) {
+	addr, err := netip.ParseAddr(p.Dst)
+	if err != nil {
+		return "", ""
+	}
+	switch {
+	case addr.IsMulticast():
+		cast = CastMulticast
+	case addr == limitedBroadcast:
+		cast = CastBroadcast
+	default:
+		return "", ""
+	}
+
+	if name, ok := multicastGroups[addr]; ok {
+		return cast, name
+	}
+	if name, ok := castPorts[p.DstPort]; ok && p.Protocol == ProtocolUDP {
+		return cast, name
+	}
+	// IGMPv2 reports are sent to the group being joined; no other portless protocol is
+	if cast == CastMulticast && p.Protocol == ProtocolOther && p.SrcPort == 0 && p.DstPort == 0 {
+		return cast, "igmp"
+	}
+	return cast, "other"
+}
+
+// GroupNode returns the synthetic node that stands in for every destination of a cast group,
+// such as "multicast:mdns" or "broadcast:dhcp"
+func GroupNode(cast, group string) string {
+	return cast + ":" + group
+}
+
+// IsGroupNode reports whether a node is a synthetic cast group node rather than an address
+func IsGroupNode(node string) bool {
+	return strings.HasPrefix(node, CastMulticast+":") || strings.HasPrefix(node, CastBroadcast+":")
+}
+
+// GroupNodeLabel names a synthetic group node for display, e.g. "mdns multicast"
+func GroupNodeLabel(node string) string {
+	cast, group, _ := strings.Cut(node, ":")
+	return group + " " + cast
+}
+
+// ClassifyCast returns the packet with its Cast and CastGroup set. With aggregate, the
+// destination of group traffic is replaced by its synthetic group node, so every mDNS or SSDP
+// group address shows up as one node instead of a host per group. A packet that changes is
+// copied, since the capture may share it with other sessions.
+func ClassifyCast(p *Packet, aggregate bool) *Packet {
+	cast, group := CastOf(p)
+	if cast == "" {
+		return p
+	}
+	tagged := *p
+	tagged.Cast, tagged.CastGroup = cast, group
+	if aggregate {
+		tagged.Dst = GroupNode(cast, group)
+	}
+	return &tagged
+}
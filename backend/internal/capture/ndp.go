@@ -0,0 +1,295 @@
+package capture
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// routerAdvertFilter passes ICMPv6 Router Advertisements, which live capture keeps alongside IPv4
+// for the router guard. RAs behind extension headers slip past it.
+const routerAdvertFilter = "icmp6 and ip6[40] == 134"
+
+// Bounds of the router guard: routers remembered at once, how long one is kept after its last
+// advertisement, and the default gap between repeat alerts for the same router
+const (
+	maxRouters         = 1024
+	routerForgetAfter  = time.Hour
+	defaultRAGuardWait = 10 * time.Minute
+)
+
+// RouterAdvert is an IPv6 Router Advertisement: a router offering itself as the default gateway
+// and handing out prefixes for hosts to configure addresses from
+type RouterAdvert struct {
+	MAC      string        // the source link-layer address option, or the Ethernet source
+	Lifetime time.Duration // as a default router; 0 when it only advertises prefixes
+	Managed  bool          // M flag: addresses come from DHCPv6
+	Other    bool          // O flag: other configuration comes from DHCPv6
+	Prefixes []string      // prefix information options, as CIDRs
+}
+
+// decodeRouterAdvert turns a Router Advertisement into a Packet from the router, carrying the
+// advertisement in RouterAdvert. It returns nil for anything else, and for advertisements hosts
+// ignore because they were routed (RFC 4861 requires a hop limit of 255).
+func decodeRouterAdvert(packet gopacket.Packet) *Packet {
+	raLayer := packet.Layer(layers.LayerTypeICMPv6RouterAdvertisement)
+	ipLayer := packet.Layer(layers.LayerTypeIPv6)
+	if raLayer == nil || ipLayer == nil {
+		return nil
+	}
+	ip := ipLayer.(*layers.IPv6)
+	if ip.HopLimit != 255 {
+		return nil
+	}
+	ra := raLayer.(*layers.ICMPv6RouterAdvertisement)
+	advert := &RouterAdvert{
+		Lifetime: time.Duration(ra.RouterLifetime) * time.Second,
+		Managed:  ra.Flags&0x80 != 0,
+		Other:    ra.Flags&0x40 != 0,
+	}
+	for _, option := range ra.Options {
+		switch option.Type {
+		case layers.ICMPv6OptSourceAddress:
+			if len(option.Data) >= 6 {
+				advert.MAC = net.HardwareAddr(option.Data[:6]).String()
+			}
+		case layers.ICMPv6OptPrefixInfo:
+			// prefix length, flags, valid and preferred lifetimes, reserved, then the prefix
+			if len(option.Data) < 30 {
+				continue
+			}
+			addr := netip.AddrFrom16([16]byte(option.Data[14:30]))
+			if prefix, err := addr.Prefix(int(option.Data[0])); err == nil {
+				advert.Prefixes = append(advert.Prefixes, prefix.String())
+			}
+		}
+	}
+
+	p := NewPacket(ip.SrcIP.String(), ip.DstIP.String(), 0, 0, len(packet.Data()), ProtocolOther)
+	if ethLayer := packet.Layer(layers.LayerTypeEthernet); ethLayer != nil {
+		p.SrcMAC = ethLayer.(*layers.Ethernet).SrcMAC.String()
+	}
+	if advert.MAC == "" {
+		advert.MAC = p.SrcMAC
+	}
+	p.TTL = ip.HopLimit
+	p.RouterAdvert = advert
+	p.Raw = packet.Data()
+	p.CaptureInfo = packet.Metadata().CaptureInfo
+	p.LinkType = linkTypeOf(packet)
+	return p
+}
+
+// RAGuardRules say which routers may advertise, and which prefixes; an empty list allows anything
+type RAGuardRules struct {
+	Routers  []netip.Addr   // router addresses, usually link-local
+	MACs     []string       // router MACs, lower case
+	Prefixes []netip.Prefix // every advertised prefix must fall inside one of these
+	Cooldown time.Duration  // minimum gap between repeat alerts for the same router
+}
+
+// Router is a router heard advertising itself on a captured link
+type Router struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac,omitempty"`
+	Interface string    `json:"interface"`
+	Lifetime  int       `json:"router_lifetime"` // seconds as a default router
+	Managed   bool      `json:"managed,omitempty"`
+	Other     bool      `json:"other,omitempty"`
+	Prefixes  []string  `json:"prefixes"`
+	Allowed   bool      `json:"allowed"` // passes the rules, prefixes included
+	Adverts   int64     `json:"adverts"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	alerted time.Time
+	count   int // advertisements since the last alert
+}
+
+// RogueRAAlert is raised for a Router Advertisement the rules don't allow: from a router that
+// isn't one of the venue's, or with a prefix outside the expected ones. Hosts on the link take
+// either as their gateway or address space, which is how RA spoofing hijacks IPv6 traffic.
+type RogueRAAlert struct {
+	Type      string   `json:"type"` // always "rogue_ra"
+	Kind      string   `json:"kind"` // "router" (not an allowed router) or "prefix" (an allowed router, unexpected prefixes)
+	Timestamp int64    `json:"timestamp"`
+	IP        string   `json:"ip"` // the advertising router
+	MAC       string   `json:"mac,omitempty"`
+	Interface string   `json:"interface"`
+	Lifetime  int      `json:"router_lifetime"`    // seconds; 0 when it doesn't offer itself as a gateway
+	Prefixes  []string `json:"prefixes,omitempty"` // router: everything advertised; prefix: the unexpected ones
+	Count     int      `json:"count"`              // advertisements since the router's previous alert, this one included
+}
+
+// ToJSON converts a rogue RA alert to JSON
+func (a *RogueRAAlert) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// RAGuard keeps the routers advertising on the captured links and checks their advertisements
+// against the rules. Safe for concurrent use; every session's live capture feeds the same guard.
+type RAGuard struct {
+	mu      sync.Mutex
+	rules   *RAGuardRules
+	routers map[string]*Router // by interface, address and MAC
+}
+
+// NewRAGuard creates a guard; nil rules only keep track of the routers
+func NewRAGuard(rules *RAGuardRules) *RAGuard {
+	return &RAGuard{rules: rules, routers: make(map[string]*Router)}
+}
+
+// SetRules replaces the rules; every router is checked afresh on its next advertisement
+func (g *RAGuard) SetRules(rules *RAGuardRules) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rules = rules
+	for _, router := range g.routers {
+		router.alerted, router.count = time.Time{}, 0
+	}
+}
+
+// Observe records an advertisement from the packet's source heard on iface, and returns an
+// alert when the rules don't allow it, unless the router alerted within the cooldown
+func (g *RAGuard) Observe(p *Packet, iface string, now time.Time) *RogueRAAlert {
+	ra := p.RouterAdvert
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := iface + "|" + p.Src + "|" + ra.MAC
+	router, ok := g.routers[key]
+	if !ok {
+		if len(g.routers) >= maxRouters {
+			g.expire(now)
+			if len(g.routers) >= maxRouters {
+				return nil
+			}
+		}
+		router = &Router{IP: p.Src, MAC: ra.MAC, Interface: iface, FirstSeen: now}
+		g.routers[key] = router
+	}
+	router.Lifetime = int(ra.Lifetime / time.Second)
+	router.Managed, router.Other = ra.Managed, ra.Other
+	router.Prefixes = append([]string{}, ra.Prefixes...)
+	router.Adverts++
+	router.LastSeen = now
+
+	kind, prefixes := g.check(p.Src, ra)
+	router.Allowed = kind == ""
+	if router.Allowed {
+		return nil
+	}
+	router.count++
+	cooldown := g.rules.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultRAGuardWait
+	}
+	if !router.alerted.IsZero() && now.Sub(router.alerted) < cooldown {
+		return nil
+	}
+	alert := &RogueRAAlert{
+		Type:      "rogue_ra",
+		Kind:      kind,
+		Timestamp: now.UnixMilli(),
+		IP:        p.Src,
+		MAC:       ra.MAC,
+		Interface: iface,
+		Lifetime:  router.Lifetime,
+		Prefixes:  prefixes,
+		Count:     router.count,
+	}
+	router.alerted, router.count = now, 0
+	return alert
+}
+
+// check returns why the rules don't allow an advertisement, with the prefixes at fault, or ""
+// when they do; callers hold mu
+func (g *RAGuard) check(src string, ra *RouterAdvert) (string, []string) {
+	rules := g.rules
+	if rules == nil {
+		return "", nil
+	}
+	if len(rules.Routers) > 0 || len(rules.MACs) > 0 {
+		allowed := false
+		if addr, err := netip.ParseAddr(src); err == nil {
+			for _, router := range rules.Routers {
+				if router == addr {
+					allowed = true
+				}
+			}
+		}
+		for _, mac := range rules.MACs {
+			if strings.EqualFold(mac, ra.MAC) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return "router", ra.Prefixes
+		}
+	}
+	if len(rules.Prefixes) == 0 {
+		return "", nil
+	}
+	var unexpected []string
+	for _, advertised := range ra.Prefixes {
+		prefix, err := netip.ParsePrefix(advertised)
+		if err != nil {
+			continue
+		}
+		inside := false
+		for _, expected := range rules.Prefixes {
+			if expected.Bits() <= prefix.Bits() && expected.Contains(prefix.Addr()) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			unexpected = append(unexpected, advertised)
+		}
+	}
+	if len(unexpected) > 0 {
+		return "prefix", unexpected
+	}
+	return "", nil
+}
+
+// Routers returns the routers heard in the last hour, ordered by interface and address
+func (g *RAGuard) Routers(now time.Time) []Router {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expire(now)
+
+	routers := make([]Router, 0, len(g.routers))
+	for _, router := range g.routers {
+		copied := *router
+		copied.Prefixes = append([]string{}, router.Prefixes...)
+		routers = append(routers, copied)
+	}
+	sort.Slice(routers, func(i, j int) bool {
+		a, b := routers[i], routers[j]
+		if a.Interface != b.Interface {
+			return a.Interface < b.Interface
+		}
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		return a.MAC < b.MAC
+	})
+	return routers
+}
+
+// expire forgets routers that stopped advertising; callers hold mu
+func (g *RAGuard) expire(now time.Time) {
+	for key, router := range g.routers {
+		if now.Sub(router.LastSeen) > routerForgetAfter {
+			delete(g.routers, key)
+		}
+	}
+}
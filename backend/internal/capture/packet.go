@@ -9,11 +9,15 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
+
+	"vibes-network-visualizer/internal/scenario"
 )
 
 // Protocol types
@@ -26,15 +30,46 @@ const (
 
 // Packet represents a network packet
 type Packet struct {
-	Type      string `json:"type"`
-	Src       string `json:"src"`
-	Dst       string `json:"dst"`
-	SrcPort   int    `json:"src_port"` // Source port number
-	DstPort   int    `json:"dst_port"` // Destination port number
-	Size      int    `json:"size"`
-	Protocol  string `json:"protocol"`
-	Timestamp int64  `json:"timestamp"`
-	Source    string `json:"source"` // "real", "simulated", or "pcap_replay"
+	Type       string `json:"type"`
+	Src        string `json:"src"`
+	Dst        string `json:"dst"`
+	SrcPort    int    `json:"src_port"` // Source port number
+	DstPort    int    `json:"dst_port"` // Destination port number
+	Size       int    `json:"size"`
+	Protocol   string `json:"protocol"`
+	Timestamp  int64  `json:"timestamp"`
+	Source     string `json:"source"`               // "real", "simulated", or "pcap_replay"
+	TCPFlags   string `json:"tcp_flags,omitempty"`  // e.g. "S", "SA", "FA", "R" (decoded TCP only)
+	Fragmented bool   `json:"fragmented,omitempty"` // an IPv4 fragment: more fragments follow, or its offset isn't zero
+	Tunnel     string `json:"tunnel,omitempty"`     // encrypted tunnel protocol: "wireguard", "ipsec" or "openvpn"
+	Cast       string `json:"cast,omitempty"`       // "multicast" or "broadcast"; empty for unicast
+	CastGroup  string `json:"cast_group,omitempty"` // protocol behind group traffic, e.g. "mdns", "ssdp", "igmp"
+	SrcGroup   string `json:"src_group,omitempty"`  // server-side cluster hint (node_grouping config)
+	DstGroup   string `json:"dst_group,omitempty"`
+	Sensor     string `json:"sensor,omitempty"`  // capture agent that relayed the packet (relay mode)
+	SrcNAT     string `json:"src_nat,omitempty"` // NAT pool address Src is behind; Src is the internal host (nat config)
+	DstNAT     string `json:"dst_nat,omitempty"`
+	Intensity  string `json:"intensity,omitempty"` // quiet, normal or hot: the edge's rolling rate, for streamed packets
+	Pinned     bool   `json:"pinned,omitempty"`    // an endpoint matches the room's pinning rules; no sampling, summary or queue drops it
+
+	// Decoded header fields used by server-side analysis; never streamed
+	TCPSeq       uint32         `json:"-"`
+	PayloadLen   int            `json:"-"`
+	Fragment     *IPFragment    `json:"-"` // set for decoded IPv4 fragments
+	TTL          uint8          `json:"-"` // 0 when the packet wasn't decoded from a frame
+	IPID         uint16         `json:"-"`
+	IPChecksum   uint16         `json:"-"`
+	SrcMAC       string         `json:"-"` // Ethernet source, for frames with an Ethernet header
+	Wireless     *WirelessFrame `json:"-"` // set for 802.11 management frames (monitor mode)
+	DNSAnswers   []DNSAnswer    `json:"-"` // addresses a DNS response resolved, for the DNS cache
+	DHCP         *DHCPMessage   `json:"-"` // set for DHCPv4 messages, for the DHCP guard
+	Neighbor     *Neighbor      `json:"-"` // set for LLDP and CDP announcements
+	RouterAdvert *RouterAdvert  `json:"-"` // set for IPv6 Router Advertisements
+
+	// Original frame for PCAP recording; empty for synthetic packets
+	Raw         []byte               `json:"-"`
+	CaptureInfo gopacket.CaptureInfo `json:"-"`
+	LinkType    layers.LinkType      `json:"-"`
 }
 
 // ToJSON converts a packet to JSON
@@ -42,6 +77,17 @@ func (p *Packet) ToJSON() ([]byte, error) {
 	return json.Marshal(p)
 }
 
+// WithPinned returns the packet with Pinned set. A packet that changes is copied, since the
+// capture may share it with other sessions.
+func WithPinned(p *Packet, pinned bool) *Packet {
+	if pinned == p.Pinned {
+		return p
+	}
+	marked := *p
+	marked.Pinned = pinned
+	return &marked
+}
+
 // NewPacket creates a new packet
 func NewPacket(src, dst string, srcPort, dstPort, size int, protocol string) *Packet {
 	return &Packet{
@@ -110,20 +156,36 @@ type PacketCapture interface {
 	GetPacketChannel() <-chan *Packet
 }
 
-// SimulatedCapture provides simulated network traffic for testing
+// SimulatedCapture generates traffic from a scenario (see internal/scenario)
 type SimulatedCapture struct {
 	packetChan chan *Packet
 	stopChan   chan bool
 	running    bool
+	scenario   atomic.Pointer[scenario.Scenario]
+	reload     chan struct{}
+	triggers   chan *scenario.Triggered
 }
 
-// NewSimulatedCapture creates a new simulated capture
+// NewSimulatedCapture creates a simulated capture running the default scenario
 func NewSimulatedCapture() *SimulatedCapture {
-	return &SimulatedCapture{
+	sc, err := scenario.Builtin(scenario.DefaultName)
+	if err != nil {
+		panic(fmt.Sprintf("built-in scenario: %v", err))
+	}
+	return NewScenarioCapture(sc)
+}
+
+// NewScenarioCapture creates a simulated capture running the given scenario
+func NewScenarioCapture(sc *scenario.Scenario) *SimulatedCapture {
+	s := &SimulatedCapture{
 		packetChan: make(chan *Packet, 1000), // Increased buffer for busy network simulation
 		stopChan:   make(chan bool),
 		running:    false,
+		reload:     make(chan struct{}, 1),
+		triggers:   make(chan *scenario.Triggered, 8),
 	}
+	s.scenario.Store(sc)
+	return s
 }
 
 // Start begins the simulated packet capture
@@ -153,466 +215,6 @@ func (s *SimulatedCapture) GetPacketChannel() <-chan *Packet {
 	return s.packetChan
 }
 
-// generatePackets simulates realistic busy network traffic
-func (s *SimulatedCapture) generatePackets() {
-	// Ultra-high packet rates for 5000+ packets/second simulation
-	ultraTicker := time.NewTicker(200 * time.Microsecond)   // Every 0.2ms - 5000 packets/second
-	hyperTicker := time.NewTicker(333 * time.Microsecond)   // Every 0.33ms - 3000 packets/second
-	fastTicker := time.NewTicker(500 * time.Microsecond)    // Every 0.5ms - 2000 packets/second
-	mediumTicker := time.NewTicker(1 * time.Millisecond)    // Every 1ms - 1000 packets/second
-	burstTicker := time.NewTicker(2 * time.Millisecond)     // Every 2ms - 500 packets/second
-
-	defer ultraTicker.Stop()
-	defer hyperTicker.Stop()
-	defer fastTicker.Stop()
-	defer mediumTicker.Stop()
-	defer burstTicker.Stop()
-
-	// Expanded network topology (500+ nodes across multiple subnets)
-	loudTalkers := []string{
-		"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4", "203.0.113.5",
-		"203.0.113.6", "203.0.113.7", "203.0.113.8", "203.0.113.9", "203.0.113.10",
-	}
-	localNetwork := []string{
-		// 192.168.1.x subnet (250 nodes)
-		"192.168.1.10", "192.168.1.11", "192.168.1.12", "192.168.1.13", "192.168.1.14", "192.168.1.15", "192.168.1.16", "192.168.1.17", "192.168.1.18", "192.168.1.19",
-		"192.168.1.20", "192.168.1.21", "192.168.1.22", "192.168.1.23", "192.168.1.24", "192.168.1.25", "192.168.1.26", "192.168.1.27", "192.168.1.28", "192.168.1.29",
-		"192.168.1.30", "192.168.1.31", "192.168.1.32", "192.168.1.33", "192.168.1.34", "192.168.1.35", "192.168.1.36", "192.168.1.37", "192.168.1.38", "192.168.1.39",
-		"192.168.1.40", "192.168.1.41", "192.168.1.42", "192.168.1.43", "192.168.1.44", "192.168.1.45", "192.168.1.46", "192.168.1.47", "192.168.1.48", "192.168.1.49",
-		"192.168.1.50", "192.168.1.51", "192.168.1.52", "192.168.1.53", "192.168.1.54", "192.168.1.55", "192.168.1.56", "192.168.1.57", "192.168.1.58", "192.168.1.59",
-		"192.168.1.60", "192.168.1.61", "192.168.1.62", "192.168.1.63", "192.168.1.64", "192.168.1.65", "192.168.1.66", "192.168.1.67", "192.168.1.68", "192.168.1.69",
-		"192.168.1.70", "192.168.1.71", "192.168.1.72", "192.168.1.73", "192.168.1.74", "192.168.1.75", "192.168.1.76", "192.168.1.77", "192.168.1.78", "192.168.1.79",
-		"192.168.1.80", "192.168.1.81", "192.168.1.82", "192.168.1.83", "192.168.1.84", "192.168.1.85", "192.168.1.86", "192.168.1.87", "192.168.1.88", "192.168.1.89",
-		"192.168.1.90", "192.168.1.91", "192.168.1.92", "192.168.1.93", "192.168.1.94", "192.168.1.95", "192.168.1.96", "192.168.1.97", "192.168.1.98", "192.168.1.99",
-		"192.168.1.100", "192.168.1.101", "192.168.1.102", "192.168.1.103", "192.168.1.104", "192.168.1.105", "192.168.1.106", "192.168.1.107", "192.168.1.108", "192.168.1.109",
-		"192.168.1.110", "192.168.1.111", "192.168.1.112", "192.168.1.113", "192.168.1.114", "192.168.1.115", "192.168.1.116", "192.168.1.117", "192.168.1.118", "192.168.1.119",
-		"192.168.1.120", "192.168.1.121", "192.168.1.122", "192.168.1.123", "192.168.1.124", "192.168.1.125", "192.168.1.126", "192.168.1.127", "192.168.1.128", "192.168.1.129",
-		"192.168.1.130", "192.168.1.131", "192.168.1.132", "192.168.1.133", "192.168.1.134", "192.168.1.135", "192.168.1.136", "192.168.1.137", "192.168.1.138", "192.168.1.139",
-		"192.168.1.140", "192.168.1.141", "192.168.1.142", "192.168.1.143", "192.168.1.144", "192.168.1.145", "192.168.1.146", "192.168.1.147", "192.168.1.148", "192.168.1.149",
-		"192.168.1.150", "192.168.1.151", "192.168.1.152", "192.168.1.153", "192.168.1.154", "192.168.1.155", "192.168.1.156", "192.168.1.157", "192.168.1.158", "192.168.1.159",
-		"192.168.1.160", "192.168.1.161", "192.168.1.162", "192.168.1.163", "192.168.1.164", "192.168.1.165", "192.168.1.166", "192.168.1.167", "192.168.1.168", "192.168.1.169",
-		"192.168.1.170", "192.168.1.171", "192.168.1.172", "192.168.1.173", "192.168.1.174", "192.168.1.175", "192.168.1.176", "192.168.1.177", "192.168.1.178", "192.168.1.179",
-		"192.168.1.180", "192.168.1.181", "192.168.1.182", "192.168.1.183", "192.168.1.184", "192.168.1.185", "192.168.1.186", "192.168.1.187", "192.168.1.188", "192.168.1.189",
-		"192.168.1.190", "192.168.1.191", "192.168.1.192", "192.168.1.193", "192.168.1.194", "192.168.1.195", "192.168.1.196", "192.168.1.197", "192.168.1.198", "192.168.1.199",
-		"192.168.1.200", "192.168.1.201", "192.168.1.202", "192.168.1.203", "192.168.1.204", "192.168.1.205", "192.168.1.206", "192.168.1.207", "192.168.1.208", "192.168.1.209",
-		"192.168.1.210", "192.168.1.211", "192.168.1.212", "192.168.1.213", "192.168.1.214", "192.168.1.215", "192.168.1.216", "192.168.1.217", "192.168.1.218", "192.168.1.219",
-		"192.168.1.220", "192.168.1.221", "192.168.1.222", "192.168.1.223", "192.168.1.224", "192.168.1.225", "192.168.1.226", "192.168.1.227", "192.168.1.228", "192.168.1.229",
-		"192.168.1.230", "192.168.1.231", "192.168.1.232", "192.168.1.233", "192.168.1.234", "192.168.1.235", "192.168.1.236", "192.168.1.237", "192.168.1.238", "192.168.1.239",
-		"192.168.1.240", "192.168.1.241", "192.168.1.242", "192.168.1.243", "192.168.1.244", "192.168.1.245", "192.168.1.246", "192.168.1.247", "192.168.1.248", "192.168.1.249",
-		"192.168.1.250",
-
-		// 192.168.2.x subnet (250 nodes)
-		"192.168.2.10", "192.168.2.11", "192.168.2.12", "192.168.2.13", "192.168.2.14", "192.168.2.15", "192.168.2.16", "192.168.2.17", "192.168.2.18", "192.168.2.19",
-		"192.168.2.20", "192.168.2.21", "192.168.2.22", "192.168.2.23", "192.168.2.24", "192.168.2.25", "192.168.2.26", "192.168.2.27", "192.168.2.28", "192.168.2.29",
-		"192.168.2.30", "192.168.2.31", "192.168.2.32", "192.168.2.33", "192.168.2.34", "192.168.2.35", "192.168.2.36", "192.168.2.37", "192.168.2.38", "192.168.2.39",
-		"192.168.2.40", "192.168.2.41", "192.168.2.42", "192.168.2.43", "192.168.2.44", "192.168.2.45", "192.168.2.46", "192.168.2.47", "192.168.2.48", "192.168.2.49",
-		"192.168.2.50", "192.168.2.51", "192.168.2.52", "192.168.2.53", "192.168.2.54", "192.168.2.55", "192.168.2.56", "192.168.2.57", "192.168.2.58", "192.168.2.59",
-		"192.168.2.60", "192.168.2.61", "192.168.2.62", "192.168.2.63", "192.168.2.64", "192.168.2.65", "192.168.2.66", "192.168.2.67", "192.168.2.68", "192.168.2.69",
-		"192.168.2.70", "192.168.2.71", "192.168.2.72", "192.168.2.73", "192.168.2.74", "192.168.2.75", "192.168.2.76", "192.168.2.77", "192.168.2.78", "192.168.2.79",
-		"192.168.2.80", "192.168.2.81", "192.168.2.82", "192.168.2.83", "192.168.2.84", "192.168.2.85", "192.168.2.86", "192.168.2.87", "192.168.2.88", "192.168.2.89",
-		"192.168.2.90", "192.168.2.91", "192.168.2.92", "192.168.2.93", "192.168.2.94", "192.168.2.95", "192.168.2.96", "192.168.2.97", "192.168.2.98", "192.168.2.99",
-		"192.168.2.100", "192.168.2.101", "192.168.2.102", "192.168.2.103", "192.168.2.104", "192.168.2.105", "192.168.2.106", "192.168.2.107", "192.168.2.108", "192.168.2.109",
-		"192.168.2.110", "192.168.2.111", "192.168.2.112", "192.168.2.113", "192.168.2.114", "192.168.2.115", "192.168.2.116", "192.168.2.117", "192.168.2.118", "192.168.2.119",
-		"192.168.2.120", "192.168.2.121", "192.168.2.122", "192.168.2.123", "192.168.2.124", "192.168.2.125", "192.168.2.126", "192.168.2.127", "192.168.2.128", "192.168.2.129",
-		"192.168.2.130", "192.168.2.131", "192.168.2.132", "192.168.2.133", "192.168.2.134", "192.168.2.135", "192.168.2.136", "192.168.2.137", "192.168.2.138", "192.168.2.139",
-		"192.168.2.140", "192.168.2.141", "192.168.2.142", "192.168.2.143", "192.168.2.144", "192.168.2.145", "192.168.2.146", "192.168.2.147", "192.168.2.148", "192.168.2.149",
-		"192.168.2.150", "192.168.2.151", "192.168.2.152", "192.168.2.153", "192.168.2.154", "192.168.2.155", "192.168.2.156", "192.168.2.157", "192.168.2.158", "192.168.2.159",
-		"192.168.2.160", "192.168.2.161", "192.168.2.162", "192.168.2.163", "192.168.2.164", "192.168.2.165", "192.168.2.166", "192.168.2.167", "192.168.2.168", "192.168.2.169",
-		"192.168.2.170", "192.168.2.171", "192.168.2.172", "192.168.2.173", "192.168.2.174", "192.168.2.175", "192.168.2.176", "192.168.2.177", "192.168.2.178", "192.168.2.179",
-		"192.168.2.180", "192.168.2.181", "192.168.2.182", "192.168.2.183", "192.168.2.184", "192.168.2.185", "192.168.2.186", "192.168.2.187", "192.168.2.188", "192.168.2.189",
-		"192.168.2.190", "192.168.2.191", "192.168.2.192", "192.168.2.193", "192.168.2.194", "192.168.2.195", "192.168.2.196", "192.168.2.197", "192.168.2.198", "192.168.2.199",
-		"192.168.2.200", "192.168.2.201", "192.168.2.202", "192.168.2.203", "192.168.2.204", "192.168.2.205", "192.168.2.206", "192.168.2.207", "192.168.2.208", "192.168.2.209",
-		"192.168.2.210", "192.168.2.211", "192.168.2.212", "192.168.2.213", "192.168.2.214", "192.168.2.215", "192.168.2.216", "192.168.2.217", "192.168.2.218", "192.168.2.219",
-		"192.168.2.220", "192.168.2.221", "192.168.2.222", "192.168.2.223", "192.168.2.224", "192.168.2.225", "192.168.2.226", "192.168.2.227", "192.168.2.228", "192.168.2.229",
-		"192.168.2.230", "192.168.2.231", "192.168.2.232", "192.168.2.233", "192.168.2.234", "192.168.2.235", "192.168.2.236", "192.168.2.237", "192.168.2.238", "192.168.2.239",
-		"192.168.2.240", "192.168.2.241", "192.168.2.242", "192.168.2.243", "192.168.2.244", "192.168.2.245", "192.168.2.246", "192.168.2.247", "192.168.2.248", "192.168.2.249",
-		"192.168.2.250",
-	}
-
-	servers := []string{
-		"10.0.0.10", "10.0.0.11", "10.0.0.12", "10.0.0.13", "10.0.0.14", "10.0.0.15", "10.0.0.16", "10.0.0.17", "10.0.0.18", "10.0.0.19",
-		"10.0.0.20", "10.0.0.21", "10.0.0.22", "10.0.0.23", "10.0.0.24", "10.0.0.25", "10.0.0.26", "10.0.0.27", "10.0.0.28", "10.0.0.29",
-		"10.0.0.30", "10.0.0.31", "10.0.0.32", "10.0.0.33", "10.0.0.34", "10.0.0.35", "10.0.0.36", "10.0.0.37", "10.0.0.38", "10.0.0.39",
-		"10.0.0.40", "10.0.0.41", "10.0.0.42", "10.0.0.43", "10.0.0.44", "10.0.0.45", "10.0.0.46", "10.0.0.47", "10.0.0.48", "10.0.0.49",
-		"10.0.0.50", "10.0.0.51", "10.0.0.52", "10.0.0.53", "10.0.0.54", "10.0.0.55", "10.0.0.56", "10.0.0.57", "10.0.0.58", "10.0.0.59",
-	}
-
-	// Multiple gateways
-	gateways := []string{"192.168.1.1", "192.168.2.1", "192.168.3.1"}
-
-	internet := []string{
-		// Major cloud providers and CDNs (AWS, GCP, Azure, Cloudflare, etc)
-		"13.32.0.1", "13.33.0.1", "13.35.0.1", "13.48.0.1", "13.49.0.1", "13.51.0.1", "13.53.0.1", "13.54.0.1", "13.55.0.1", "13.56.0.1",
-		"34.192.0.1", "34.193.0.1", "34.194.0.1", "34.195.0.1", "34.196.0.1", "34.197.0.1", "34.198.0.1", "34.199.0.1", "34.200.0.1", "34.201.0.1",
-		"35.160.0.1", "35.161.0.1", "35.162.0.1", "35.163.0.1", "35.164.0.1", "35.165.0.1", "35.166.0.1", "35.167.0.1", "35.168.0.1", "35.169.0.1",
-		"52.0.0.1", "52.1.0.1", "52.2.0.1", "52.3.0.1", "52.4.0.1", "52.5.0.1", "52.6.0.1", "52.7.0.1", "52.8.0.1", "52.9.0.1",
-		"104.16.0.1", "104.17.0.1", "104.18.0.1", "104.19.0.1", "104.20.0.1", "104.21.0.1", "104.22.0.1", "104.23.0.1", "104.24.0.1", "104.25.0.1",
-		"172.64.0.1", "172.65.0.1", "172.66.0.1", "172.67.0.1", "172.68.0.1", "172.69.0.1", "172.70.0.1", "172.71.0.1", "172.72.0.1", "172.73.0.1",
-		"35.184.0.1", "35.185.0.1", "35.186.0.1", "35.187.0.1", "35.188.0.1", "35.189.0.1", "35.190.0.1", "35.191.0.1", "35.192.0.1", "35.193.0.1",
-		"35.194.0.1", "35.195.0.1", "35.196.0.1", "35.197.0.1", "35.198.0.1", "35.199.0.1", "35.200.0.1", "35.201.0.1", "35.202.0.1", "35.203.0.1",
-		"40.64.0.1", "40.65.0.1", "40.66.0.1", "40.67.0.1", "40.68.0.1", "40.69.0.1", "40.70.0.1", "40.71.0.1", "40.72.0.1", "40.73.0.1",
-		"40.74.0.1", "40.75.0.1", "40.76.0.1", "40.77.0.1", "40.78.0.1", "40.79.0.1", "40.80.0.1", "40.81.0.1", "40.82.0.1", "40.83.0.1",
-
-		// Major websites and services
-		"151.101.0.1", "151.101.64.1", "151.101.128.1", "151.101.192.1", "151.101.0.2", "151.101.64.2", "151.101.128.2", "151.101.192.2", "151.101.0.3", "151.101.64.3",
-		"157.240.0.1", "157.240.1.1", "157.240.2.1", "157.240.3.1", "157.240.4.1", "157.240.5.1", "157.240.6.1", "157.240.7.1", "157.240.8.1", "157.240.9.1",
-		"199.232.0.1", "199.232.1.1", "199.232.2.1", "199.232.3.1", "199.232.4.1", "199.232.5.1", "199.232.6.1", "199.232.7.1", "199.232.8.1", "199.232.9.1",
-		"140.82.112.1", "140.82.113.1", "140.82.114.1", "140.82.115.1", "140.82.116.1", "140.82.117.1", "140.82.118.1", "140.82.119.1", "140.82.120.1", "140.82.121.1",
-		"185.199.108.1", "185.199.109.1", "185.199.110.1", "185.199.111.1", "185.199.108.2", "185.199.109.2", "185.199.110.2", "185.199.111.2", "185.199.108.3", "185.199.109.3",
-
-		// Content delivery networks
-		"23.32.0.1", "23.33.0.1", "23.34.0.1", "23.35.0.1", "23.36.0.1", "23.37.0.1", "23.38.0.1", "23.39.0.1", "23.40.0.1", "23.41.0.1",
-		"23.42.0.1", "23.43.0.1", "23.44.0.1", "23.45.0.1", "23.46.0.1", "23.47.0.1", "23.48.0.1", "23.49.0.1", "23.50.0.1", "23.51.0.1",
-		"23.52.0.1", "23.53.0.1", "23.54.0.1", "23.55.0.1", "23.56.0.1", "23.57.0.1", "23.58.0.1", "23.59.0.1", "23.60.0.1", "23.61.0.1",
-		"23.62.0.1", "23.63.0.1", "23.64.0.1", "23.65.0.1", "23.66.0.1", "23.67.0.1", "23.68.0.1", "23.69.0.1", "23.70.0.1", "23.71.0.1",
-		"23.72.0.1", "23.73.0.1", "23.74.0.1", "23.75.0.1", "23.76.0.1", "23.77.0.1", "23.78.0.1", "23.79.0.1", "23.80.0.1", "23.81.0.1",
-
-		// DNS servers and infrastructure
-		"8.8.8.8", "8.8.4.4", "1.1.1.1", "1.0.0.1", "9.9.9.9", "149.112.112.112", "208.67.222.222", "208.67.220.220", "8.26.56.26", "8.20.247.20",
-		"64.6.64.6", "64.6.65.6", "156.154.70.1", "156.154.71.1", "199.85.126.10", "199.85.127.10", "198.101.242.72", "23.253.163.53", "84.200.69.80", "84.200.70.40",
-		"37.235.1.174", "37.235.1.177", "77.88.8.8", "77.88.8.1", "91.239.100.100", "89.233.43.71", "74.82.42.42", "109.69.8.51", "216.146.35.35", "216.146.36.36",
-
-		// Common internet services
-		"172.217.0.1", "172.217.1.1", "172.217.2.1", "172.217.3.1", "172.217.4.1", "172.217.5.1", "172.217.6.1", "172.217.7.1", "172.217.8.1", "172.217.9.1",
-		"173.194.0.1", "173.194.1.1", "173.194.2.1", "173.194.3.1", "173.194.4.1", "173.194.5.1", "173.194.6.1", "173.194.7.1", "173.194.8.1", "173.194.9.1",
-		"74.125.0.1", "74.125.1.1", "74.125.2.1", "74.125.3.1", "74.125.4.1", "74.125.5.1", "74.125.6.1", "74.125.7.1", "74.125.8.1", "74.125.9.1",
-		"142.250.0.1", "142.250.1.1", "142.250.2.1", "142.250.3.1", "142.250.4.1", "142.250.5.1", "142.250.6.1", "142.250.7.1", "142.250.8.1", "142.250.9.1",
-		"216.58.192.1", "216.58.193.1", "216.58.194.1", "216.58.195.1", "216.58.196.1", "216.58.197.1", "216.58.198.1", "216.58.199.1", "216.58.200.1", "216.58.201.1",
-
-		// Additional cloud and CDN ranges
-		"204.79.197.1", "204.79.198.1", "204.79.199.1", "204.79.200.1", "204.79.201.1", "204.79.202.1", "204.79.203.1", "204.79.204.1", "204.79.205.1", "204.79.206.1",
-		"13.107.0.1", "13.107.1.1", "13.107.2.1", "13.107.3.1", "13.107.4.1", "13.107.5.1", "13.107.6.1", "13.107.7.1", "13.107.8.1", "13.107.9.1",
-		"104.244.40.1", "104.244.41.1", "104.244.42.1", "104.244.43.1", "104.244.44.1", "104.244.45.1", "104.244.46.1", "104.244.47.1", "104.244.48.1", "104.244.49.1",
-		"192.0.64.1", "192.0.65.1", "192.0.66.1", "192.0.67.1", "192.0.68.1", "192.0.69.1", "192.0.70.1", "192.0.71.1", "192.0.72.1", "192.0.73.1",
-		"198.35.26.1", "198.35.27.1", "198.35.28.1", "198.35.29.1", "198.35.30.1", "198.35.31.1", "198.35.32.1", "198.35.33.1", "198.35.34.1", "198.35.35.1",
-
-		// Additional service ranges
-		"44.212.0.1", "44.212.1.1", "44.212.2.1", "44.212.3.1", "44.212.4.1", "44.212.5.1", "44.212.6.1", "44.212.7.1", "44.212.8.1", "44.212.9.1",
-		"52.84.0.1", "52.84.1.1", "52.84.2.1", "52.84.3.1", "52.84.4.1", "52.84.5.1", "52.84.6.1", "52.84.7.1", "52.84.8.1", "52.84.9.1",
-		"99.84.0.1", "99.84.1.1", "99.84.2.1", "99.84.3.1", "99.84.4.1", "99.84.5.1", "99.84.6.1", "99.84.7.1", "99.84.8.1", "99.84.9.1",
-		"108.156.0.1", "108.156.1.1", "108.156.2.1", "108.156.3.1", "108.156.4.1", "108.156.5.1", "108.156.6.1", "108.156.7.1", "108.156.8.1", "108.156.9.1",
-		"205.251.192.1", "205.251.193.1", "205.251.194.1", "205.251.195.1", "205.251.196.1", "205.251.197.1", "205.251.198.1", "205.251.199.1", "205.251.200.1", "205.251.201.1",
-	}
-
-	// Define traffic patterns for simulation
-	clientServerPairs := []struct {
-		client   string
-		server   string
-		protocol string
-	}{}
-
-	// Local to local traffic (30% of connections)
-	for i := 0; i < 6; i++ {
-		srcIndex := rand.Intn(len(localNetwork))
-		dstIndex := rand.Intn(len(localNetwork))
-		for dstIndex == srcIndex { // Ensure different source and destination
-			dstIndex = rand.Intn(len(localNetwork))
-		}
-		protocol := ProtocolTCP
-		if rand.Intn(10) < 3 {
-			protocol = ProtocolUDP
-		}
-		clientServerPairs = append(clientServerPairs, struct {
-			client   string
-			server   string
-			protocol string
-		}{localNetwork[srcIndex], localNetwork[dstIndex], protocol})
-	}
-
-	// Local to gateway traffic (20% of connections)
-	for i := 0; i < 4; i++ {
-		srcIndex := rand.Intn(len(localNetwork))
-		gwIndex := rand.Intn(len(gateways))
-		protocol := ProtocolTCP
-		if rand.Intn(10) < 2 {
-			protocol = ProtocolICMP
-		}
-		clientServerPairs = append(clientServerPairs, struct {
-			client   string
-			server   string
-			protocol string
-		}{localNetwork[srcIndex], gateways[gwIndex], protocol})
-	}
-
-	// Local to server traffic (25% of connections)
-	for i := 0; i < 5; i++ {
-		srcIndex := rand.Intn(len(localNetwork))
-		srvIndex := rand.Intn(len(servers))
-		protocol := ProtocolTCP
-		if rand.Intn(10) < 3 {
-			protocol = ProtocolUDP
-		}
-		clientServerPairs = append(clientServerPairs, struct {
-			client   string
-			server   string
-			protocol string
-		}{localNetwork[srcIndex], servers[srvIndex], protocol})
-	}
-
-	// Local to internet traffic (15% of connections)
-	for i := 0; i < 3; i++ {
-		srcIndex := rand.Intn(len(localNetwork))
-		intIndex := rand.Intn(len(internet))
-		protocol := ProtocolTCP
-		if rand.Intn(10) < 2 {
-			protocol = ProtocolUDP
-		}
-		clientServerPairs = append(clientServerPairs, struct {
-			client   string
-			server   string
-			protocol string
-		}{localNetwork[srcIndex], internet[intIndex], protocol})
-	}
-
-	// Internet to local traffic (10% of connections)
-	for i := 0; i < 2; i++ {
-		intIndex := rand.Intn(len(internet))
-		dstIndex := rand.Intn(len(localNetwork))
-		protocol := ProtocolTCP
-		if rand.Intn(10) < 2 {
-			protocol = ProtocolUDP
-		}
-		clientServerPairs = append(clientServerPairs, struct {
-			client   string
-			server   string
-			protocol string
-		}{internet[intIndex], localNetwork[dstIndex], protocol})
-	}
-
-	log.Println("Starting ULTRA-HIGH THROUGHPUT network simulation with extreme packet rates")
-	log.Println("Generating 5000+ packets/second with realistic randomization...")
-
-	// Seed random number generator for better diversity
-	rand.Seed(time.Now().UnixNano())
-
-	for {
-		select {
-		case <-s.stopChan:
-			log.Println("Stopping simulated packet capture")
-			return
-
-		case <-ultraTicker.C:
-			src := loudTalkers[rand.Intn(len(loudTalkers))]
-			var dst string
-			destType := rand.Intn(3)
-			if destType == 0 {
-				dst = localNetwork[rand.Intn(len(localNetwork))]
-			} else if destType == 1 {
-				dst = servers[rand.Intn(len(servers))]
-			} else {
-				dst = internet[rand.Intn(len(internet))]
-			}
-
-			packetSize := 64 + rand.Intn(1436)
-			protocols := []string{ProtocolTCP, ProtocolUDP}
-			protocol := protocols[rand.Intn(len(protocols))]
-			s.sendPacket(src, dst, packetSize, protocol)
-
-		// Ultra-fast traffic - high-volume local traffic
-		case <-hyperTicker.C:
-			// Truly random selection for diverse connections
-			clientIndex := rand.Intn(len(localNetwork))
-			serverIndex := rand.Intn(len(servers))
-
-			// Random protocol distribution
-			protocols := []string{ProtocolTCP, ProtocolTCP, ProtocolTCP, ProtocolUDP, ProtocolICMP}
-			protocol := protocols[rand.Intn(len(protocols))]
-
-			// Varied packet sizes for realism
-			packetSize := 64 + rand.Intn(1436) // 64-1500 bytes
-			s.sendPacket(localNetwork[clientIndex], servers[serverIndex], packetSize, protocol)
-
-			// Random bidirectional traffic (40% chance of response)
-			if rand.Float32() < 0.4 {
-				responseSize := 64 + rand.Intn(800) // Smaller responses
-				go func() {
-					time.Sleep(time.Duration(1+rand.Intn(10)) * time.Millisecond) // 1-10ms delay
-					s.sendPacket(servers[serverIndex], localNetwork[clientIndex], responseSize, protocol)
-				}()
-			}
-
-		// Fast traffic - gateway/internet traffic
-		case <-fastTicker.C:
-			// Random external to internal traffic
-			internetIndex := rand.Intn(len(internet))
-			localIndex := rand.Intn(len(localNetwork))
-			gatewayIndex := rand.Intn(len(gateways))
-
-			protocol := ProtocolTCP
-			if rand.Float32() < 0.3 { // 30% UDP traffic
-				protocol = ProtocolUDP
-			}
-
-			packetSize := 200 + rand.Intn(1300) // 200-1500 bytes
-
-			// Internet -> Gateway -> Local (common web traffic pattern)
-			s.sendPacket(internet[internetIndex], gateways[gatewayIndex], packetSize, protocol)
-
-			// Forward to local with slight delay
-			go func() {
-				time.Sleep(time.Duration(2+rand.Intn(8)) * time.Millisecond) // 2-10ms delay
-				s.sendPacket(gateways[gatewayIndex], localNetwork[localIndex], packetSize-20, protocol)
-			}()
-
-		// Medium frequency traffic - server communications
-		case <-mediumTicker.C:
-			// Random client-server communications for diversity
-			pairIndex := rand.Intn(len(clientServerPairs))
-			pair := clientServerPairs[pairIndex]
-
-			// Send a request with realistic size
-			requestSize := 200 + rand.Intn(1300) // 200-1500 bytes
-			s.sendPacket(pair.client, pair.server, requestSize, pair.protocol)
-
-			// Server responds asynchronously with realistic delay
-			go func() {
-				responseDelay := time.Duration(10+rand.Intn(40)) * time.Millisecond // 10-50ms
-				time.Sleep(responseDelay)
-				responseSize := 300 + rand.Intn(1700) // 300-2000 bytes
-				s.sendPacket(pair.server, pair.client, responseSize, pair.protocol)
-			}()
-
-			// Random ping traffic (20% chance)
-			if rand.Float32() < 0.2 {
-				randomClientIndex := rand.Intn(len(localNetwork))
-				randomGatewayIndex := rand.Intn(len(gateways))
-				randomClient := localNetwork[randomClientIndex]
-				randomGateway := gateways[randomGatewayIndex]
-
-				// Send ping
-				s.sendPacket(randomClient, randomGateway, 64, ProtocolICMP)
-
-				// Ping response after realistic delay
-				go func() {
-					time.Sleep(time.Duration(5+rand.Intn(15)) * time.Millisecond) // 5-20ms ping time
-					s.sendPacket(randomGateway, randomClient, 64, ProtocolICMP)
-				}()
-			}
-
-		// Burst traffic - high volume data flows
-		case <-burstTicker.C:
-			// Random high-volume data transfer burst
-			serverIndex := rand.Intn(len(servers))
-			server := servers[serverIndex]
-
-			externalIndex := rand.Intn(len(internet))
-			externalIP := internet[externalIndex]
-
-			gatewayIndex := rand.Intn(len(gateways))
-			gateway := gateways[gatewayIndex]
-
-			// Multiple concurrent bursts for busy network simulation
-			go s.simulateDataBurst(externalIP, gateway, server)
-
-			// Additional random bursts (30% chance of multiple simultaneous transfers)
-			if rand.Float32() < 0.3 {
-				go s.simulateDataBurst(
-					internet[rand.Intn(len(internet))],
-					gateways[rand.Intn(len(gateways))],
-					servers[rand.Intn(len(servers))])
-			}
-
-			// Random local-to-local high volume transfer (20% chance)
-			if rand.Float32() < 0.2 {
-				go s.simulateLocalDataBurst(
-					localNetwork[rand.Intn(len(localNetwork))],
-					localNetwork[rand.Intn(len(localNetwork))])
-			}
-		}
-	}
-}
-
-// sendPacket creates and sends a packet
-func (s *SimulatedCapture) sendPacket(src, dst string, size int, protocol string) {
-	// Generate realistic ports based on protocol
-	srcPort, dstPort := generateRealisticPorts(protocol)
-
-	packet := NewPacketWithPorts(
-		src,
-		dst,
-		srcPort,
-		dstPort,
-		size,
-		protocol,
-	)
-
-	select {
-	case s.packetChan <- packet:
-		// Successfully sent packet
-	default:
-		// Channel full, discard packet
-		log.Println("Packet channel full, discarding packet")
-	}
-}
-
-// simulateDataBurst creates a realistic high-volume data transfer
-func (s *SimulatedCapture) simulateDataBurst(external, gateway, server string) {
-	// Initial request from external source
-	initialSize := 1200 + rand.Intn(300) // 1200-1500 bytes
-	s.sendPacket(external, gateway, initialSize, ProtocolTCP)
-
-	time.Sleep(time.Duration(10+rand.Intn(20)) * time.Millisecond) // 10-30ms
-
-	// Gateway forwards to server
-	s.sendPacket(gateway, server, initialSize-20, ProtocolTCP)
-
-	time.Sleep(time.Duration(15+rand.Intn(25)) * time.Millisecond) // 15-40ms
-
-	// Server responds with burst of data packets (5-15 packets)
-	burstSize := 5 + rand.Intn(10)
-	for i := 0; i < burstSize; i++ {
-		packetSize := 800 + rand.Intn(700) // 800-1500 bytes
-		s.sendPacket(server, gateway, packetSize, ProtocolTCP)
-		time.Sleep(time.Duration(3+rand.Intn(10)) * time.Millisecond) // 3-13ms between packets
-	}
-
-	time.Sleep(time.Duration(20+rand.Intn(30)) * time.Millisecond) // 20-50ms
-
-	// Gateway forwards responses back to external
-	for i := 0; i < burstSize/2; i++ {
-		responseSize := 1200 + rand.Intn(300) // 1200-1500 bytes
-		s.sendPacket(gateway, external, responseSize, ProtocolTCP)
-		time.Sleep(time.Duration(5+rand.Intn(15)) * time.Millisecond) // 5-20ms
-	}
-
-	// Final acknowledgments
-	time.Sleep(time.Duration(10+rand.Intn(20)) * time.Millisecond)
-	s.sendPacket(external, gateway, 60+rand.Intn(40), ProtocolTCP) // Small ACK
-}
-
-// simulateLocalDataBurst creates high-volume local network traffic
-func (s *SimulatedCapture) simulateLocalDataBurst(src, dst string) {
-	// Don't create a burst to self
-	if src == dst {
-		return
-	}
-
-	// Initial handshake
-	s.sendPacket(src, dst, 100+rand.Intn(200), ProtocolTCP)
-	time.Sleep(time.Duration(5+rand.Intn(10)) * time.Millisecond)
-
-	// Response handshake
-	s.sendPacket(dst, src, 80+rand.Intn(120), ProtocolTCP)
-	time.Sleep(time.Duration(5+rand.Intn(10)) * time.Millisecond)
-
-	// Data transfer burst (10-30 packets)
-	burstSize := 10 + rand.Intn(20)
-	for i := 0; i < burstSize; i++ {
-		packetSize := 500 + rand.Intn(1000) // 500-1500 bytes
-		s.sendPacket(src, dst, packetSize, ProtocolTCP)
-
-		// Random acknowledgments (30% chance)
-		if rand.Float32() < 0.3 {
-			go func() {
-				time.Sleep(time.Duration(2+rand.Intn(8)) * time.Millisecond)
-				s.sendPacket(dst, src, 64+rand.Intn(100), ProtocolTCP) // Small ACK
-			}()
-		}
-
-		time.Sleep(time.Duration(2+rand.Intn(8)) * time.Millisecond) // 2-10ms between packets
-	}
-}
-
 // RealCapture implements real packet capture using gopacket
 type RealCapture struct {
 	packetChan chan *Packet
@@ -620,6 +222,18 @@ type RealCapture struct {
 	running    bool
 	handle     *pcap.Handle
 	iface      string
+
+	// Monitor puts a wireless interface into monitor mode, so 802.11 management frames are
+	// captured along with IP traffic. Set it before Start.
+	Monitor bool
+	// Filter narrows the capture with a BPF expression on top of the IP filter. Set it before
+	// Start; a filter that doesn't compile fails Start.
+	Filter string
+
+	filterMu   sync.Mutex // guards handle and the filters against SetWatchFilter
+	baseFilter string     // the IP filter for the link type, set by Start
+	watch      string     // BPF compiled from the room's watchlist; see SetWatchFilter
+	discovery  bool       // an Ethernet link: LLDP, CDP and IPv6 RAs pass every filter, for the topology and router guard
 }
 
 // NewRealCapture creates a new real packet capture instance
@@ -665,6 +279,15 @@ func (r *RealCapture) Start() error {
 		log.Printf("Error setting timeout: %v", err)
 		return err
 	}
+	if r.Monitor {
+		if err = inactiveHandle.SetRFMon(true); err != nil {
+			log.Printf("Error enabling monitor mode: %v", err)
+			return fmt.Errorf("error enabling monitor mode on %s: %v", r.iface, err)
+		}
+	}
+
+	r.filterMu.Lock()
+	defer r.filterMu.Unlock()
 
 	// Try with root privileges first
 	r.handle, err = inactiveHandle.Activate()
@@ -674,8 +297,34 @@ func (r *RealCapture) Start() error {
 		return fmt.Errorf("error activating capture on device %s: %v (may need root)", r.iface, err)
 	}
 
-	// Set a filter to only capture IP packets
-	err = r.handle.SetBPFFilter("ip")
+	// Set a filter to only capture IP packets, plus management frames on an 802.11 link and
+	// LLDP/CDP announcements and IPv6 Router Advertisements on Ethernet
+	filter := "ip"
+	switch r.handle.LinkType() {
+	case layers.LinkTypeIEEE80211Radio, layers.LinkTypeIEEE802_11:
+		filter = "ip or type mgt"
+		log.Printf("📡 Interface '%s' is in monitor mode: decoding 802.11 management frames", r.iface)
+	case layers.LinkTypeEthernet:
+		r.discovery = true
+	}
+	r.baseFilter = filter
+	if r.watch != "" {
+		// A watchlist that doesn't compile leaves the capture whole rather than failing it
+		if err = r.handle.SetBPFFilter(r.captureFilter(r.watch)); err == nil {
+			log.Printf("🎯 Capture on '%s' narrowed to the watchlist: %s", r.iface, r.watch)
+			r.running = true
+			go r.capturePackets(r.handle)
+			return nil
+		}
+		log.Printf("Warning: couldn't narrow the capture to the watchlist %q: %v", r.watch, err)
+		r.watch = ""
+	}
+	err = r.handle.SetBPFFilter(r.captureFilter(""))
+	if err != nil && r.Filter != "" {
+		r.handle.Close()
+		r.handle = nil
+		return fmt.Errorf("BPF filter %q: %v", r.Filter, err)
+	}
 	if err != nil {
 		log.Printf("Warning: couldn't set BPF filter: %v", err)
 	}
@@ -684,7 +333,7 @@ func (r *RealCapture) Start() error {
 
 	// Start packet processing
 	r.running = true
-	go r.capturePackets()
+	go r.capturePackets(r.handle)
 	return nil
 }
 
@@ -696,20 +345,56 @@ func (r *RealCapture) Stop() error {
 
 	r.running = false
 	r.stopChan <- true
+	r.filterMu.Lock()
+	defer r.filterMu.Unlock()
 	if r.handle != nil {
 		r.handle.Close()
+		r.handle = nil
 	}
 	return nil
 }
 
+// captureFilter combines the IP filter, Filter and a watchlist expression; callers hold filterMu
+func (r *RealCapture) captureFilter(watch string) string {
+	filter := r.baseFilter
+	if r.Filter != "" {
+		filter = fmt.Sprintf("(%s) and (%s)", filter, r.Filter)
+	}
+	if watch != "" {
+		filter = fmt.Sprintf("(%s) and (%s)", filter, watch)
+	}
+	if r.discovery {
+		filter = fmt.Sprintf("(%s) or (%s) or (%s)", filter, discoveryFilter, routerAdvertFilter)
+	}
+	return filter
+}
+
+// SetWatchFilter narrows the capture to a BPF expression compiled from the room's watchlist, in
+// the kernel, so traffic nobody watches never reaches userspace; "" captures everything again.
+// Before Start it takes effect when Start runs. An expression that doesn't compile changes nothing.
+func (r *RealCapture) SetWatchFilter(expr string) error {
+	r.filterMu.Lock()
+	defer r.filterMu.Unlock()
+	if expr == r.watch {
+		return nil
+	}
+	if r.handle != nil {
+		if err := r.handle.SetBPFFilter(r.captureFilter(expr)); err != nil {
+			return fmt.Errorf("watchlist filter %q: %v", expr, err)
+		}
+	}
+	r.watch = expr
+	return nil
+}
+
 // GetPacketChannel returns the channel to receive packets
 func (r *RealCapture) GetPacketChannel() <-chan *Packet {
 	return r.packetChan
 }
 
 // capturePackets processes real network packets
-func (r *RealCapture) capturePackets() {
-	packetSource := gopacket.NewPacketSource(r.handle, r.handle.LinkType())
+func (r *RealCapture) capturePackets(handle *pcap.Handle) {
+	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
 
 	log.Printf("Starting real packet processing on interface %s", r.iface)
 
@@ -728,64 +413,12 @@ func (r *RealCapture) capturePackets() {
 				continue
 			}
 
-			// Process network layer
-			networkLayer := packet.NetworkLayer()
-			if networkLayer == nil {
+			// Decode IPv4 header and transport ports
+			p := decodePacket(packet)
+			if p == nil {
 				continue
 			}
 
-			// Get IP layer info
-			ipLayer := packet.Layer(layers.LayerTypeIPv4)
-			if ipLayer == nil {
-				continue
-			}
-
-			ip, _ := ipLayer.(*layers.IPv4)
-
-			// Extract IP addresses
-			srcIP := ip.SrcIP.String()
-			dstIP := ip.DstIP.String()
-
-			// Extract protocol and port information
-			var protocol string
-			var srcPort, dstPort int
-
-			// Check TCP layer
-			if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-				tcp, _ := tcpLayer.(*layers.TCP)
-				protocol = ProtocolTCP
-				srcPort = int(tcp.SrcPort)
-				dstPort = int(tcp.DstPort)
-
-			} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-				udp, _ := udpLayer.(*layers.UDP)
-				protocol = ProtocolUDP
-				srcPort = int(udp.SrcPort)
-				dstPort = int(udp.DstPort)
-
-			} else if icmpLayer := packet.Layer(layers.LayerTypeICMPv4); icmpLayer != nil {
-				icmp, _ := icmpLayer.(*layers.ICMPv4)
-				protocol = ProtocolICMP
-				// For ICMP, use type and code as "port" values for visualization
-				srcPort = int(icmp.TypeCode.Type())
-				dstPort = int(icmp.TypeCode.Code())
-
-			} else {
-				protocol = ProtocolOther
-				srcPort = 0
-				dstPort = 0
-			}
-
-			// Create packet with extracted port information
-			p := NewPacket(
-				srcIP,
-				dstIP,
-				srcPort,
-				dstPort,
-				len(packet.Data()),
-				protocol,
-			)
-
 			// Mark this packet as real (not simulated)
 			p.Source = "real"
 
@@ -826,6 +459,11 @@ type PCAPReplayCapture struct {
 	useTimeRange      bool
 	currentPacketTime time.Time
 	replayStartTime   time.Time
+	replayed          atomic.Uint64                // packets sent since Start
+	position          atomic.Int64                 // original timestamp of the packet read last, Unix ns
+	span              atomic.Pointer[CaptureIndex] // the file's time span, once measured
+	completed         atomic.Pointer[ReplayComplete] // set when the replay reaches the end of the file or time range
+	finished          chan struct{}                  // closed when the replay goroutine exits, however it ends
 }
 
 // PCAPReplayConfig holds configuration for PCAP replay
@@ -874,8 +512,8 @@ func (p *PCAPReplayCapture) Start() error {
 		log.Printf("Time range: %s to %s", p.startTime.Format("15:04:05"), p.endTime.Format("15:04:05"))
 	}
 
-	// Open PCAP file
-	handle, err := pcap.OpenOffline(p.pcapFile)
+	// Open PCAP file (pcap, pcapng, optionally gzipped)
+	handle, err := OpenCaptureFile(p.pcapFile)
 	if err != nil {
 		return fmt.Errorf("error opening PCAP file %s: %v", p.pcapFile, err)
 	}
@@ -884,9 +522,16 @@ func (p *PCAPReplayCapture) Start() error {
 
 	p.running = true
 	p.replayStartTime = time.Now()
+	p.replayed.Store(0)
+	p.position.Store(0)
+	p.completed.Store(nil)
+	p.finished = make(chan struct{})
+	if p.span.Load() == nil {
+		go p.measureSpan()
+	}
 
 	// Start replay processing in goroutine
-	go p.replayPackets(handle)
+	go p.replayPackets(handle, p.finished)
 	return nil
 }
 
@@ -897,7 +542,11 @@ func (p *PCAPReplayCapture) Stop() error {
 	}
 
 	p.running = false
-	p.stopChan <- true
+	// A replay that reached the end of its file has nobody left to tell
+	select {
+	case p.stopChan <- true:
+	case <-p.finished:
+	}
 	return nil
 }
 
@@ -907,7 +556,8 @@ func (p *PCAPReplayCapture) GetPacketChannel() <-chan *Packet {
 }
 
 // replayPackets processes and replays packets from the PCAP file
-func (p *PCAPReplayCapture) replayPackets(handle *pcap.Handle) {
+func (p *PCAPReplayCapture) replayPackets(handle CaptureFile, finished chan struct{}) {
+	defer close(finished)
 	defer handle.Close()
 
 	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
@@ -918,6 +568,7 @@ func (p *PCAPReplayCapture) replayPackets(handle *pcap.Handle) {
 	skippedCount := 0
 	var firstPacketTime time.Time
 	var lastPacketTimestamp time.Time
+	complete := &ReplayComplete{Type: "replay_complete", Source: "pcap_replay", File: p.pcapFile}
 
 	for {
 		select {
@@ -929,7 +580,7 @@ func (p *PCAPReplayCapture) replayPackets(handle *pcap.Handle) {
 			if err != nil {
 				if err.Error() == "EOF" {
 					log.Printf("PCAP replay completed - processed %d packets total", packetCount)
-					// Send completion signal or loop if desired
+					p.complete(complete, skippedCount)
 					return
 				}
 				log.Printf("Error reading PCAP packet: %v", err)
@@ -953,6 +604,7 @@ func (p *PCAPReplayCapture) replayPackets(handle *pcap.Handle) {
 				}
 				if !p.endTime.IsZero() && packetTimestamp.After(p.endTime) {
 					log.Printf("Reached end time, stopping replay")
+					p.complete(complete, skippedCount)
 					return
 				}
 			}
@@ -972,71 +624,25 @@ func (p *PCAPReplayCapture) replayPackets(handle *pcap.Handle) {
 			}
 
 			lastPacketTimestamp = packetTimestamp
-
-			// Process network layer
-			networkLayer := packet.NetworkLayer()
-			if networkLayer == nil {
-				continue
+			p.position.Store(packetTimestamp.UnixNano())
+			if complete.Start == 0 {
+				complete.Start = packetTimestamp.UnixMilli()
 			}
+			complete.End = packetTimestamp.UnixMilli()
 
-			// Get IP layer info
-			ipLayer := packet.Layer(layers.LayerTypeIPv4)
-			if ipLayer == nil {
+			// Decode IPv4 header and transport ports
+			replayPacket := decodePacket(packet)
+			if replayPacket == nil {
+				complete.Undecoded++
 				continue
 			}
-
-			ip, _ := ipLayer.(*layers.IPv4)
-
-			// Extract IP addresses
-			srcIP := ip.SrcIP.String()
-			dstIP := ip.DstIP.String()
-
-			// Extract protocol and port information
-			var protocol string
-			var srcPort, dstPort int
-
-			// Parse protocol and port information
-			if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-				tcp, _ := tcpLayer.(*layers.TCP)
-				protocol = ProtocolTCP
-				srcPort = int(tcp.SrcPort)
-				dstPort = int(tcp.DstPort)
-
-			} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-				udp, _ := udpLayer.(*layers.UDP)
-				protocol = ProtocolUDP
-				srcPort = int(udp.SrcPort)
-				dstPort = int(udp.DstPort)
-
-			} else if icmpLayer := packet.Layer(layers.LayerTypeICMPv4); icmpLayer != nil {
-				icmp, _ := icmpLayer.(*layers.ICMPv4)
-				protocol = ProtocolICMP
-				// For ICMP, use type and code as "port" values for visualization
-				srcPort = int(icmp.TypeCode.Type())
-				dstPort = int(icmp.TypeCode.Code())
-
-			} else {
-				protocol = ProtocolOther
-				srcPort = 0
-				dstPort = 0
-			}
-
-			// Create packet with extracted port information
-			replayPacket := &Packet{
-				Type:      "packet",
-				Src:       srcIP,
-				Dst:       dstIP,
-				SrcPort:   srcPort,
-				DstPort:   dstPort,
-				Size:      len(packet.Data()),
-				Protocol:  protocol,
-				Timestamp: time.Now().UnixMilli(), // Use current time for frontend synchronization
-				Source:    "pcap_replay",
-			}
+			replayPacket.Source = "pcap_replay" // Timestamp stays current time for frontend synchronization
 
 			select {
 			case p.packetChan <- replayPacket:
 				packetCount++
+				p.replayed.Add(1)
+				complete.Bytes += uint64(replayPacket.Size)
 
 				// Log progress for epic PCAP moments
 				if packetCount%1000 == 0 {
@@ -1049,6 +655,7 @@ func (p *PCAPReplayCapture) replayPackets(handle *pcap.Handle) {
 			default:
 				// Channel full, discard packet but continue
 				log.Println("Packet channel full during PCAP replay, discarding packet")
+				complete.Dropped++
 			}
 		}
 	}
@@ -1060,17 +667,27 @@ type TimeWindowProcessor struct {
 	stopChan        chan bool
 	running         bool
 	storageDir      string
+	store           ArchiveStore
 	startTime       time.Time
 	endTime         time.Time
 	replaySpeed     float64
 	fileSequence    []string
-	currentIndex    int
-	currentOffset   int64
+	files           []scannedFile // fileSequence with each file's span, in order of start
+	workers         int           // files scanned and read ahead at once
+	sampling        SamplingConfig
+	sampler         *sampler // nil when every packet is kept
+	pinned          func(p *Packet) bool
+	merge           *windowMerge
 	transitionChan  chan string
 	seekChan        chan time.Time
-	currentFile     *pcap.Handle
 	lastPacketTime  time.Time
 	replayStartTime time.Time
+	index           *ArchiveIndex
+	clock           monotonicClock
+	onSkew          func(ClockSkew)
+	position        atomic.Int64 // playback position, Unix ms; read from other goroutines
+	replayed        atomic.Uint64 // packets sent since Start
+	done            atomic.Bool   // playback reached the end of the window
 }
 
 // CaptureIndex represents metadata about a PCAP file
@@ -1080,6 +697,17 @@ type CaptureIndex struct {
 	EndTime     time.Time `json:"end_time"`
 	PacketCount int64     `json:"packet_count"`
 	FileSize    int64     `json:"file_size"`
+	ModTime     time.Time `json:"mod_time"` // detects rotated/rewritten files on rescan
+	Minutes     []MinuteCount `json:"minutes,omitempty"` // per-minute traffic, for /api/archive/heatmap
+	Bytes       int64         `json:"bytes"`             // packet bytes on the wire
+	TopIPs      []IPCount     `json:"top_ips,omitempty"` // busiest addresses by bytes
+}
+
+// MinuteCount is the traffic one capture file holds for one minute
+type MinuteCount struct {
+	Minute    int64            `json:"minute"` // Unix ms of the minute's start
+	Packets   int64            `json:"packets"`
+	Protocols map[string]int64 `json:"protocols"` // TCP, UDP, ICMP or OTHER (anything not IPv4 too)
 }
 
 // PacketIndex represents timestamp-to-offset mapping for fast seeking
@@ -1100,14 +728,27 @@ const (
 // TimeWindowConfig holds configuration for time window replay
 type TimeWindowConfig struct {
 	StorageDir   string    `json:"storage_dir"`
+	Store        ArchiveStore `json:"-"` // where the files are; StorageDir's DirStore when nil
 	StartTime    time.Time `json:"start_time"`
 	EndTime      time.Time `json:"end_time"`
 	ReplaySpeed  float64   `json:"replay_speed"`
-	SamplingRate int       `json:"sampling_rate"`
+	Sampling     SamplingConfig `json:"sampling"` // the zero value keeps every packet
+	Pinned       func(p *Packet) bool `json:"-"` // optional; marks pinned packets, which are never dropped
+	Index        *ArchiveIndex `json:"-"` // optional; avoids globbing and guessing file spans from names
+	OnSkew       func(ClockSkew) `json:"-"` // optional; called when a file's clock steps backwards and is corrected
+	ScanWorkers  int       `json:"scan_workers"` // files scanned and read ahead at once; 4 when zero
 }
 
 // NewTimeWindowProcessor creates a new time window processor
 func NewTimeWindowProcessor(config TimeWindowConfig) *TimeWindowProcessor {
+	store := config.Store
+	if store == nil {
+		store = DirStore(config.StorageDir)
+	}
+	workers := config.ScanWorkers
+	if workers <= 0 {
+		workers = defaultScanWorkers
+	}
 	return &TimeWindowProcessor{
 		packetChan:     make(chan *Packet, 1000),
 		stopChan:       make(chan bool),
@@ -1115,11 +756,16 @@ func NewTimeWindowProcessor(config TimeWindowConfig) *TimeWindowProcessor {
 		seekChan:       make(chan time.Time, 10),
 		running:        false,
 		storageDir:     config.StorageDir,
+		store:          store,
 		startTime:      config.StartTime,
 		endTime:        config.EndTime,
 		replaySpeed:    config.ReplaySpeed,
-		currentIndex:   0,
-		currentOffset:  0,
+		workers:        workers,
+		sampling:       config.Sampling,
+		sampler:        newSampler(config.Sampling),
+		pinned:         config.Pinned,
+		index:          config.Index,
+		onSkew:         config.OnSkew,
 	}
 }
 
@@ -1136,6 +782,7 @@ func (twp *TimeWindowProcessor) Start() error {
 	if err := twp.buildFileSequence(); err != nil {
 		return fmt.Errorf("failed to build file sequence: %v", err)
 	}
+	twp.scanFiles()
 
 	if len(twp.fileSequence) == 0 {
 		return fmt.Errorf("no capture files found for time range")
@@ -1160,10 +807,6 @@ func (twp *TimeWindowProcessor) Stop() error {
 	twp.running = false
 	twp.stopChan <- true
 
-	if twp.currentFile != nil {
-		twp.currentFile.Close()
-	}
-
 	return nil
 }
 
@@ -1185,19 +828,21 @@ func (twp *TimeWindowProcessor) SeekToTime(targetTime time.Time) error {
 
 // buildFileSequence discovers and orders PCAP files for the time window
 func (twp *TimeWindowProcessor) buildFileSequence() error {
-	// Search for PCAP files in storage directory
-	pattern := filepath.Join(twp.storageDir, "**/*.pcap")
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		return err
+	// The archive index knows each file's real time span
+	if twp.index != nil && twp.index.Len() > 0 {
+		twp.fileSequence = twp.index.FilesFor(twp.startTime, twp.endTime)
+		return nil
 	}
 
-	// Build index for each file
+	// Search for capture files in the archive store
 	var validFiles []string
-	for _, file := range files {
-		if twp.fileSpansTimeWindow(file) {
-			validFiles = append(validFiles, file)
+	err := twp.store.Walk(func(obj ArchiveObject) {
+		if twp.fileSpansTimeWindow(obj) {
+			validFiles = append(validFiles, obj.Path)
 		}
+	})
+	if err != nil {
+		return err
 	}
 
 	// Sort files by timestamp (assumes filename contains timestamp)
@@ -1208,10 +853,10 @@ func (twp *TimeWindowProcessor) buildFileSequence() error {
 }
 
 // fileSpansTimeWindow checks if a file overlaps with the requested time window
-func (twp *TimeWindowProcessor) fileSpansTimeWindow(filePath string) bool {
+func (twp *TimeWindowProcessor) fileSpansTimeWindow(obj ArchiveObject) bool {
 	// Quick check: extract timestamp from filename if possible
 	// Format: capture_20240803_143000.pcap
-	basename := filepath.Base(filePath)
+	basename := filepath.Base(obj.Path)
 
 	// Try to parse timestamp from filename
 	if timeStr := twp.extractTimestampFromFilename(basename); timeStr != "" {
@@ -1223,8 +868,7 @@ func (twp *TimeWindowProcessor) fileSpansTimeWindow(filePath string) bool {
 	}
 
 	// Fallback: check file modification time
-	if stat, err := os.Stat(filePath); err == nil {
-		modTime := stat.ModTime()
+	if modTime := obj.ModTime; !modTime.IsZero() {
 		// Rough estimate: file might contain data +/- 1 hour from mod time
 		return modTime.Add(-time.Hour).Before(twp.endTime) && modTime.Add(time.Hour).After(twp.startTime)
 	}
@@ -1252,11 +896,8 @@ func (twp *TimeWindowProcessor) processTimeWindow() {
 		}
 	}()
 
-	// Start with first file
-	if err := twp.openCurrentFile(); err != nil {
-		log.Printf("Error opening first file: %v", err)
-		return
-	}
+	twp.merge = twp.newMerge(twp.startTime)
+	defer func() { twp.merge.close() }()
 
 	packetCount := 0
 	for twp.running {
@@ -1270,29 +911,39 @@ func (twp *TimeWindowProcessor) processTimeWindow() {
 			twp.handleSeek(seekTime)
 
 		default:
-			// Process next packet
-			packet, err := twp.readNextPacket()
-			if err != nil {
-				if err.Error() == "EOF" {
-					// Try to transition to next file
-					if !twp.transitionToNextFile() {
-						// No more files, we're done
-						log.Printf("🏁 Reached end of time window")
-						return
-					}
+			// Process next packet, the earliest left in any of the window's files
+			packet, filePath, ok := twp.merge.next()
+			if !ok {
+				// No more files, we're done
+				log.Printf("🏁 Reached end of time window")
+				if twp.waitForSeek() {
 					continue
 				}
-				log.Printf("Error reading packet: %v", err)
-				continue
+				return
 			}
 
-			// Check if packet is within our time window
-			if packet.Timestamp < twp.startTime.UnixMilli() {
-				continue // Skip packets before start time
+			// Keep the timeline from running backwards when a file's own clock steps back
+			var skewed bool
+			packet.Timestamp, skewed = twp.clock.normalize(packet.Timestamp)
+			if skewed {
+				file := filepath.Base(filePath)
+				log.Printf("⏱️ Clock skew in %s: shifting timestamps by %+dms", file, -twp.clock.offset)
+				if twp.onSkew != nil {
+					twp.onSkew(ClockSkew{
+						Type:      "clock_skew",
+						Source:    "archive",
+						File:      file,
+						OffsetMs:  twp.clock.offset,
+						Timestamp: time.Now().UnixMilli(),
+					})
+				}
 			}
-			if packet.Timestamp > twp.endTime.UnixMilli() {
-				log.Printf("🏁 Reached end time, stopping playback")
-				return
+
+			if twp.pinned != nil {
+				packet.Pinned = twp.pinned(packet)
+			}
+			if !twp.sampler.keep(packet) {
+				continue
 			}
 
 			// Apply replay timing
@@ -1302,6 +953,7 @@ func (twp *TimeWindowProcessor) processTimeWindow() {
 			select {
 			case twp.packetChan <- packet:
 				packetCount++
+				twp.replayed.Add(1)
 
 				// Log progress
 				if packetCount%1000 == 0 {
@@ -1312,129 +964,21 @@ func (twp *TimeWindowProcessor) processTimeWindow() {
 						packetCount, rate, currentTime.Format("15:04:05"))
 				}
 			default:
-				// Channel full, skip packet but continue
+				// Channel full: skip the packet and continue, unless it's pinned and worth waiting for
+				if packet.Pinned {
+					select {
+					case twp.packetChan <- packet:
+						twp.replayed.Add(1)
+					case <-twp.stopChan:
+						log.Printf("Time window processor stopped")
+						return
+					}
+				}
 			}
 		}
 	}
 }
 
-// openCurrentFile opens the current file in the sequence
-func (twp *TimeWindowProcessor) openCurrentFile() error {
-	if twp.currentIndex >= len(twp.fileSequence) {
-		return fmt.Errorf("no more files in sequence")
-	}
-
-	filePath := twp.fileSequence[twp.currentIndex]
-	log.Printf("📂 Opening file: %s", filepath.Base(filePath))
-
-	handle, err := pcap.OpenOffline(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open %s: %v", filePath, err)
-	}
-
-	// Close previous file if open
-	if twp.currentFile != nil {
-		twp.currentFile.Close()
-	}
-
-	twp.currentFile = handle
-	twp.currentOffset = 0
-
-	return nil
-}
-
-// readNextPacket reads the next packet from current file
-func (twp *TimeWindowProcessor) readNextPacket() (*Packet, error) {
-	if twp.currentFile == nil {
-		return nil, fmt.Errorf("no file open")
-	}
-
-	// Read raw packet data
-	data, ci, err := twp.currentFile.ReadPacketData()
-	if err != nil {
-		return nil, err
-	}
-
-	// Parse packet layers
-	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
-
-	// Extract network layer
-	networkLayer := packet.NetworkLayer()
-	if networkLayer == nil {
-		return twp.readNextPacket() // Skip non-IP packets
-	}
-
-	// Get IP layer
-	ipLayer := packet.Layer(layers.LayerTypeIPv4)
-	if ipLayer == nil {
-		return twp.readNextPacket() // Skip non-IPv4 packets
-	}
-
-	ip, _ := ipLayer.(*layers.IPv4)
-	srcIP := ip.SrcIP.String()
-	dstIP := ip.DstIP.String()
-
-	// Extract protocol and port information
-	var protocol string
-	var srcPort, dstPort int
-
-	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-		tcp, _ := tcpLayer.(*layers.TCP)
-		protocol = ProtocolTCP
-		srcPort = int(tcp.SrcPort)
-		dstPort = int(tcp.DstPort)
-	} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-		udp, _ := udpLayer.(*layers.UDP)
-		protocol = ProtocolUDP
-		srcPort = int(udp.SrcPort)
-		dstPort = int(udp.DstPort)
-	} else if icmpLayer := packet.Layer(layers.LayerTypeICMPv4); icmpLayer != nil {
-		icmp, _ := icmpLayer.(*layers.ICMPv4)
-		protocol = ProtocolICMP
-		srcPort = int(icmp.TypeCode.Type())
-		dstPort = int(icmp.TypeCode.Code())
-	} else {
-		protocol = ProtocolOther
-		srcPort = 0
-		dstPort = 0
-	}
-
-	// Create packet with original timestamp
-	replayPacket := &Packet{
-		Type:      "packet",
-		Src:       srcIP,
-		Dst:       dstIP,
-		SrcPort:   srcPort,
-		DstPort:   dstPort,
-		Size:      len(data),
-		Protocol:  protocol,
-		Timestamp: ci.Timestamp.UnixMilli(),
-		Source:    "time_window",
-	}
-
-	return replayPacket, nil
-}
-
-// transitionToNextFile seamlessly moves to the next file in sequence
-func (twp *TimeWindowProcessor) transitionToNextFile() bool {
-	if twp.currentIndex >= len(twp.fileSequence)-1 {
-		return false // No more files
-	}
-
-	oldFile := filepath.Base(twp.fileSequence[twp.currentIndex])
-	twp.currentIndex++
-	newFile := filepath.Base(twp.fileSequence[twp.currentIndex])
-
-	log.Printf("🔄 Seamless transition: %s → %s", oldFile, newFile)
-
-	if err := twp.openCurrentFile(); err != nil {
-		log.Printf("Error opening next file: %v", err)
-		return false
-	}
-
-	return true
-}
-
 // applyReplayTiming implements realistic timing for packet replay
 func (twp *TimeWindowProcessor) applyReplayTiming(packet *Packet) {
 	currentPacketTime := time.Unix(packet.Timestamp/1000, 0)
@@ -1453,40 +997,90 @@ func (twp *TimeWindowProcessor) applyReplayTiming(packet *Packet) {
 	}
 
 	twp.lastPacketTime = currentPacketTime
+	twp.position.Store(packet.Timestamp)
+}
+
+// Position returns the timestamp of the packet played last, or the window's start before the first
+func (twp *TimeWindowProcessor) Position() time.Time {
+	if ms := twp.position.Load(); ms != 0 {
+		return time.UnixMilli(ms)
+	}
+	return twp.startTime
 }
 
-// handleSeek processes seek requests to jump to specific times
+// Speed returns the playback speed
+func (twp *TimeWindowProcessor) Speed() float64 {
+	return twp.replaySpeed
+}
+
+// Sampling returns how the playback is thinned
+func (twp *TimeWindowProcessor) Sampling() SamplingConfig {
+	return twp.sampling
+}
+
+// Window returns the start and end of the time window
+func (twp *TimeWindowProcessor) Window() (time.Time, time.Time) {
+	return twp.startTime, twp.endTime
+}
+
+// handleSeek processes seek requests to jump to specific times, backwards or forwards
 func (twp *TimeWindowProcessor) handleSeek(targetTime time.Time) {
+	if targetTime.Before(twp.startTime) {
+		targetTime = twp.startTime
+	}
+	if targetTime.After(twp.endTime) {
+		targetTime = twp.endTime
+	}
 	log.Printf("🎯 Seeking to %s", targetTime.Format("15:04:05.000"))
+	twp.clock.reset()
+	twp.lastPacketTime = time.Time{}
+	twp.position.Store(targetTime.UnixMilli())
 
-	// Find file that should contain this timestamp
-	for i, filePath := range twp.fileSequence {
-		if twp.fileContainsTime(filePath, targetTime) {
-			twp.currentIndex = i
-			if err := twp.openCurrentFile(); err != nil {
-				log.Printf("Error opening file for seek: %v", err)
-				return
-			}
+	twp.merge.close()
+	twp.merge = twp.newMerge(targetTime)
+	log.Printf("📍 Seeked to file: %s", filepath.Base(twp.fileSequence[twp.fileForTime(targetTime)]))
+}
+
+// waitForSeek holds playback at the end of the window until a seek takes it back, so a finished
+// window can still be rewound. It reports false when the processor is stopped instead.
+func (twp *TimeWindowProcessor) waitForSeek() bool {
+	twp.done.Store(true)
+	select {
+	case <-twp.stopChan:
+		log.Printf("Time window processor stopped")
+		return false
+	case seekTime := <-twp.seekChan:
+		twp.done.Store(false)
+		twp.handleSeek(seekTime)
+		return true
+	}
+}
 
-			// TODO: Implement precise seeking within file using packet timestamps
-			log.Printf("📍 Seeked to file: %s", filepath.Base(filePath))
+// fileForTime returns the position in the file sequence to read from for a target time: the last
+// file whose first packet is at or before it
+func (twp *TimeWindowProcessor) fileForTime(targetTime time.Time) int {
+	found := 0
+	for i, file := range twp.files {
+		if file.start > targetTime.UnixMilli() {
 			break
 		}
+		found = i
 	}
+	return found
 }
 
-// fileContainsTime estimates if a file contains the target timestamp
-func (twp *TimeWindowProcessor) fileContainsTime(filePath string, targetTime time.Time) bool {
-	// Extract timestamp from filename
-	basename := filepath.Base(filePath)
-	if timeStr := twp.extractTimestampFromFilename(basename); timeStr != "" {
-		if fileTime, err := time.Parse("20060102_150405", timeStr); err == nil {
-			// Assume 1-hour files
-			fileEndTime := fileTime.Add(time.Hour)
-			return targetTime.After(fileTime) && targetTime.Before(fileEndTime)
+// newMerge starts playback of the window's files from a time on. Earlier files are read too when
+// the archive index knows they run past it, so overlapping captures from other sensors aren't
+// missed; files whose end isn't known are read from the last one starting at or before it.
+func (twp *TimeWindowProcessor) newMerge(from time.Time) *windowMerge {
+	first := twp.fileForTime(from)
+	var files []scannedFile
+	for i, file := range twp.files {
+		if i >= first || file.end >= from.UnixMilli() {
+			files = append(files, file)
 		}
 	}
-	return false
+	return newWindowMerge(twp.store, files, from.UnixMilli(), twp.endTime.UnixMilli(), twp.workers)
 }
 
 // DumpcapCapture implements packet capture by monitoring dumpcap output files
@@ -1702,9 +1296,26 @@ func (d *DumpcapCapture) readNewPackets() {
 
 // processPacket converts a gopacket.Packet to our internal Packet format
 func (d *DumpcapCapture) processPacket(packet gopacket.Packet) *Packet {
+	return decodePacket(packet)
+}
+
+// decodePacket converts a decoded gopacket.Packet into our Packet format.
+// Returns nil for anything that isn't IPv4, an 802.11 management frame, an LLDP or CDP announcement
+// or an IPv6 Router Advertisement. Source defaults to "simulated" and
+// Timestamp to now; callers set both as appropriate for their capture mode.
+func decodePacket(packet gopacket.Packet) *Packet {
+	if p := decodeWireless(packet); p != nil {
+		return p
+	}
+	if p := decodeDiscovery(packet); p != nil {
+		return p
+	}
+	if p := decodeRouterAdvert(packet); p != nil {
+		return p
+	}
+
 	// Process network layer
-	networkLayer := packet.NetworkLayer()
-	if networkLayer == nil {
+	if packet.NetworkLayer() == nil {
 		return nil
 	}
 
@@ -1713,46 +1324,124 @@ func (d *DumpcapCapture) processPacket(packet gopacket.Packet) *Packet {
 	if ipLayer == nil {
 		return nil
 	}
-
 	ip, _ := ipLayer.(*layers.IPv4)
 
-	// Extract IP addresses
-	srcIP := ip.SrcIP.String()
-	dstIP := ip.DstIP.String()
-
-	// Extract ports and determine protocol
-	srcPort, dstPort, protocol := extractPortsAndProtocol(packet)
-
-	// Create packet
-	return NewPacketWithPorts(
-		srcIP,
-		dstIP,
-		srcPort,
-		dstPort,
-		len(packet.Data()),
-		protocol,
-	)
-}
+	p := NewPacket(ip.SrcIP.String(), ip.DstIP.String(), 0, 0, len(packet.Data()), ProtocolOther)
+	p.TTL = ip.TTL
+	p.IPID = ip.Id
+	p.IPChecksum = ip.Checksum
+	if ethLayer := packet.Layer(layers.LayerTypeEthernet); ethLayer != nil {
+		p.SrcMAC = ethLayer.(*layers.Ethernet).SrcMAC.String()
+	}
+
+	if ip.Flags&layers.IPv4MoreFragments != 0 || ip.FragOffset != 0 {
+		p.Fragmented = true
+		p.Fragment = &IPFragment{
+			ID:     ip.Id,
+			Offset: int(ip.FragOffset) * 8,
+			More:   ip.Flags&layers.IPv4MoreFragments != 0,
+			Length: len(ip.Payload),
+		}
+		// Later fragments carry no transport header; name the protocol from the IP header
+		if ip.FragOffset != 0 {
+			p.Protocol = ipProtocolName(ip.Protocol)
+		}
+	}
 
-// extractPortsAndProtocol extracts source/dest ports and protocol from a packet
-func extractPortsAndProtocol(packet gopacket.Packet) (int, int, string) {
-	// Check for TCP
+	// Extract protocol and port information
+	var payload []byte
 	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
 		tcp, _ := tcpLayer.(*layers.TCP)
-		return int(tcp.SrcPort), int(tcp.DstPort), ProtocolTCP
-	}
+		p.Protocol = ProtocolTCP
+		p.SrcPort = int(tcp.SrcPort)
+		p.DstPort = int(tcp.DstPort)
+		p.TCPFlags = tcpFlagString(tcp)
+		p.TCPSeq = tcp.Seq
+		p.PayloadLen = len(tcp.Payload)
+		payload = tcp.Payload
 
-	// Check for UDP
-	if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
+	} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
 		udp, _ := udpLayer.(*layers.UDP)
-		return int(udp.SrcPort), int(udp.DstPort), ProtocolUDP
+		p.Protocol = ProtocolUDP
+		p.SrcPort = int(udp.SrcPort)
+		p.DstPort = int(udp.DstPort)
+		p.PayloadLen = len(udp.Payload)
+		payload = udp.Payload
+		if dnsLayer := packet.Layer(layers.LayerTypeDNS); dnsLayer != nil {
+			p.DNSAnswers = dnsAnswers(dnsLayer.(*layers.DNS))
+		}
+		if dhcpLayer := packet.Layer(layers.LayerTypeDHCPv4); dhcpLayer != nil {
+			p.DHCP = dhcpMessage(dhcpLayer.(*layers.DHCPv4))
+		}
+
+	} else if icmpLayer := packet.Layer(layers.LayerTypeICMPv4); icmpLayer != nil {
+		icmp, _ := icmpLayer.(*layers.ICMPv4)
+		p.Protocol = ProtocolICMP
+		// For ICMP, use type and code as "port" values for visualization
+		p.SrcPort = int(icmp.TypeCode.Type())
+		p.DstPort = int(icmp.TypeCode.Code())
 	}
+	p.Tunnel = TunnelSignature(ip.Protocol, p.Protocol, payload)
+
+	p.Raw = packet.Data()
+	p.CaptureInfo = packet.Metadata().CaptureInfo
+	p.LinkType = linkTypeOf(packet)
+
+	return p
+}
+
+// ipProtocolName names the transport protocol of an IPv4 header
+func ipProtocolName(protocol layers.IPProtocol) string {
+	switch protocol {
+	case layers.IPProtocolTCP:
+		return ProtocolTCP
+	case layers.IPProtocolUDP:
+		return ProtocolUDP
+	case layers.IPProtocolICMPv4:
+		return ProtocolICMP
+	}
+	return ProtocolOther
+}
 
-	// Check for ICMP
-	if packet.Layer(layers.LayerTypeICMPv4) != nil {
-		return 0, 0, ProtocolICMP
+// linkTypeOf recovers the capture link type from the first decoded layer
+func linkTypeOf(packet gopacket.Packet) layers.LinkType {
+	packetLayers := packet.Layers()
+	if len(packetLayers) == 0 {
+		return layers.LinkTypeEthernet
+	}
+	switch packetLayers[0].LayerType() {
+	case layers.LayerTypeLinuxSLL:
+		return layers.LinkTypeLinuxSLL
+	case layers.LayerTypeLoopback:
+		return layers.LinkTypeNull
+	case layers.LayerTypeIPv4:
+		return layers.LinkTypeRaw
+	case layers.LayerTypeRadioTap:
+		return layers.LinkTypeIEEE80211Radio
+	case layers.LayerTypeDot11:
+		return layers.LinkTypeIEEE802_11
+	default:
+		return layers.LinkTypeEthernet
 	}
+}
 
-	// Default to "Other" for unknown protocols
-	return 0, 0, ProtocolOther
+// tcpFlagString renders the handshake/teardown flags compactly: S, A, F, R, P
+func tcpFlagString(tcp *layers.TCP) string {
+	flags := make([]byte, 0, 5)
+	if tcp.SYN {
+		flags = append(flags, 'S')
+	}
+	if tcp.ACK {
+		flags = append(flags, 'A')
+	}
+	if tcp.FIN {
+		flags = append(flags, 'F')
+	}
+	if tcp.RST {
+		flags = append(flags, 'R')
+	}
+	if tcp.PSH {
+		flags = append(flags, 'P')
+	}
+	return string(flags)
 }
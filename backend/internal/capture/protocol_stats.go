@@ -0,0 +1,173 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// wellKnownPorts names the services protocol_stats buckets ports into
+var wellKnownPorts = func() map[int]string {
+	services := map[string][]int{
+		"ftp":      {20, 21},
+		"ssh":      {22},
+		"telnet":   {23},
+		"smtp":     {25, 465, 587},
+		"dns":      {53},
+		"dhcp":     {67, 68},
+		"http":     {80, 8080},
+		"kerberos": {88},
+		"pop3":     {110, 995},
+		"ntp":      {123},
+		"netbios":  {137, 138, 139},
+		"imap":     {143, 993},
+		"snmp":     {161, 162},
+		"ldap":     {389, 636},
+		"https":    {443, 8443},
+		"smb":      {445},
+		"syslog":   {514},
+		"ssdp":     {1900},
+		"mysql":    {3306},
+		"rdp":      {3389},
+		"mdns":     {5353},
+		"postgres": {5432},
+		"redis":    {6379},
+	}
+	ports := make(map[int]string)
+	for name, numbers := range services {
+		for _, port := range numbers {
+			ports[port] = name
+		}
+	}
+	return ports
+}()
+
+// PortBucket names the service a packet belongs to by its well-known port: the destination's
+// for requests, the source's for replies. Other ported traffic is "other"; packets without
+// ports (ICMP, ARP, ...) have no bucket.
+func PortBucket(p *Packet) string {
+	if name, ok := wellKnownPorts[p.DstPort]; ok {
+		return name
+	}
+	if name, ok := wellKnownPorts[p.SrcPort]; ok {
+		return name
+	}
+	if p.SrcPort != 0 || p.DstPort != 0 {
+		return "other"
+	}
+	return ""
+}
+
+// ProtocolCounters holds traffic totals for one protocol or port bucket over a report interval
+type ProtocolCounters struct {
+	Name    string `json:"name"`
+	Packets int64  `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// ProtocolStats is the periodic protocol_stats message
+type ProtocolStats struct {
+	Type       string              `json:"type"`
+	Timestamp  int64               `json:"timestamp"`
+	IntervalMs int64               `json:"interval_ms"`
+	Packets    int64               `json:"packets"`
+	Bytes      int64               `json:"bytes"`
+	Protocols  []*ProtocolCounters `json:"protocols"`
+	Ports      []*ProtocolCounters `json:"ports,omitempty"`   // by PortBucket; left out when ports aren't exposed
+	Tunnels    []*ProtocolCounters `json:"tunnels,omitempty"` // encrypted tunnel traffic by tunnel protocol
+}
+
+// ToJSON converts protocol stats to JSON
+func (s *ProtocolStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// ProtocolAccountant tallies packets and bytes per protocol and port bucket. It is not safe
+// for concurrent use; each session's forwarder owns one.
+type ProtocolAccountant struct {
+	packets    int64
+	bytes      int64
+	protocols  map[string]*ProtocolCounters
+	ports      map[string]*ProtocolCounters
+	tunnels    map[string]*ProtocolCounters
+	lastReport time.Time
+}
+
+// NewProtocolAccountant creates an empty accountant
+func NewProtocolAccountant() *ProtocolAccountant {
+	return &ProtocolAccountant{
+		protocols:  make(map[string]*ProtocolCounters),
+		ports:      make(map[string]*ProtocolCounters),
+		tunnels:    make(map[string]*ProtocolCounters),
+		lastReport: time.Now(),
+	}
+}
+
+// Observe counts a packet
+func (a *ProtocolAccountant) Observe(p *Packet) {
+	a.packets++
+	a.bytes += int64(p.Size)
+	countTraffic(a.protocols, p.Protocol, p.Size)
+	if bucket := PortBucket(p); bucket != "" {
+		countTraffic(a.ports, bucket, p.Size)
+	}
+	if p.Tunnel != "" {
+		countTraffic(a.tunnels, p.Tunnel, p.Size)
+	}
+}
+
+func countTraffic(counters map[string]*ProtocolCounters, name string, size int) {
+	c, ok := counters[name]
+	if !ok {
+		c = &ProtocolCounters{Name: name}
+		counters[name] = c
+	}
+	c.Packets++
+	c.Bytes += int64(size)
+}
+
+// Report returns the totals since the previous report, busiest first, and resets the counters.
+// withPorts includes the port buckets. It returns nil when no packet was observed.
+func (a *ProtocolAccountant) Report(withPorts bool) *ProtocolStats {
+	now := time.Now()
+	interval := now.Sub(a.lastReport)
+	a.lastReport = now
+	if a.packets == 0 {
+		return nil
+	}
+
+	stats := &ProtocolStats{
+		Type:       "protocol_stats",
+		Timestamp:  now.UnixMilli(),
+		IntervalMs: interval.Milliseconds(),
+		Packets:    a.packets,
+		Bytes:      a.bytes,
+		Protocols:  sortedCounters(a.protocols),
+	}
+	if len(a.tunnels) > 0 {
+		stats.Tunnels = sortedCounters(a.tunnels)
+	}
+	if withPorts {
+		stats.Ports = sortedCounters(a.ports)
+	}
+
+	a.packets, a.bytes = 0, 0
+	a.protocols = make(map[string]*ProtocolCounters)
+	a.ports = make(map[string]*ProtocolCounters)
+	a.tunnels = make(map[string]*ProtocolCounters)
+	return stats
+}
+
+func sortedCounters(counters map[string]*ProtocolCounters) []*ProtocolCounters {
+	sorted := make([]*ProtocolCounters, 0, len(counters))
+	for _, c := range counters {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Packets != sorted[j].Packets {
+			return sorted[i].Packets > sorted[j].Packets
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
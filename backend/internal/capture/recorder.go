@@ -0,0 +1,148 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const recorderSnaplen = 65535
+
+// RecordingStatus describes an in-progress or finished recording
+type RecordingStatus struct {
+	File      string    `json:"file"`
+	Filter    string    `json:"filter,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Packets   int64     `json:"packets"`
+	Bytes     int64     `json:"bytes"`
+	Skipped   int64     `json:"skipped"` // synthetic packets or a different link type than the file
+}
+
+// Recorder writes captured frames to a PCAP file, optionally restricted by a BPF filter.
+// The file header is written on the first recordable packet so the link type matches the capture.
+type Recorder struct {
+	mu            sync.Mutex
+	file          *os.File
+	writer        *pcapgo.Writer
+	headerWritten bool
+	linkType      layers.LinkType
+	bpf           *pcap.BPF
+	status        RecordingStatus
+	closed        bool
+}
+
+// NewRecorder creates a new PCAP file in dir. filter is a BPF expression; empty records everything.
+func NewRecorder(dir, filter string) (*Recorder, error) {
+	if filter != "" {
+		// Validate now so a typo fails the start command instead of silently recording nothing
+		if _, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, recorderSnaplen, filter); err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", filter, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating recording directory: %w", err)
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("vibes-recording-%s.pcap", now.Format("20060102-150405.000")))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("creating recording file: %w", err)
+	}
+
+	return &Recorder{
+		file:   file,
+		writer: pcapgo.NewWriter(file),
+		status: RecordingStatus{File: path, Filter: filter, StartedAt: now},
+	}, nil
+}
+
+// Write appends a packet if it carries its original frame and passes the filter
+func (r *Recorder) Write(p *Packet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	if len(p.Raw) == 0 {
+		r.status.Skipped++
+		return nil
+	}
+
+	if !r.headerWritten {
+		if err := r.writeHeader(p.LinkType); err != nil {
+			return err
+		}
+		if r.status.Filter != "" {
+			bpf, err := pcap.NewBPF(p.LinkType, recorderSnaplen, r.status.Filter)
+			if err != nil {
+				return fmt.Errorf("compiling filter for link type %v: %w", p.LinkType, err)
+			}
+			r.bpf = bpf
+		}
+	}
+
+	if p.LinkType != r.linkType {
+		r.status.Skipped++
+		return nil
+	}
+
+	ci := p.CaptureInfo
+	if ci.Timestamp.IsZero() {
+		ci.Timestamp = time.UnixMilli(p.Timestamp)
+	}
+	ci.CaptureLength = len(p.Raw)
+	if ci.Length < ci.CaptureLength {
+		ci.Length = ci.CaptureLength
+	}
+
+	if r.bpf != nil && !r.bpf.Matches(ci, p.Raw) {
+		return nil
+	}
+
+	if err := r.writer.WritePacket(ci, p.Raw); err != nil {
+		return err
+	}
+	r.status.Packets++
+	r.status.Bytes += int64(len(p.Raw))
+	return nil
+}
+
+func (r *Recorder) writeHeader(linkType layers.LinkType) error {
+	if err := r.writer.WriteFileHeader(recorderSnaplen, linkType); err != nil {
+		return err
+	}
+	r.headerWritten = true
+	r.linkType = linkType
+	return nil
+}
+
+// Status returns a copy of the recording progress
+func (r *Recorder) Status() RecordingStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Close finishes the recording and returns its final status
+func (r *Recorder) Close() (RecordingStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return r.status, nil
+	}
+	r.closed = true
+	if !r.headerWritten {
+		// Leave a valid, empty capture rather than a zero-byte file
+		r.writeHeader(layers.LinkTypeEthernet)
+	}
+	return r.status, r.file.Close()
+}
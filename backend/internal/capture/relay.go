@@ -0,0 +1,95 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RelayCapture streams packets that remote capture agents forwarded to this instance
+// (see -relay-accept). Every packet carries the Sensor ID of the agent that captured it.
+type RelayCapture struct {
+	sensor     string // only this sensor; empty = merge all
+	packetChan chan *Packet
+	running    bool
+	mu         sync.Mutex
+}
+
+// NewRelayCapture creates a subscriber to relayed packets from one sensor, or all when sensor is empty
+func NewRelayCapture(sensor string) *RelayCapture {
+	return &RelayCapture{
+		sensor:     sensor,
+		packetChan: make(chan *Packet, 10000),
+	}
+}
+
+// Start subscribes to the relay ingest
+func (r *RelayCapture) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return fmt.Errorf("relay capture already running")
+	}
+	relayHub.subscribe(r.packetChan, r.sensor)
+	r.running = true
+	return nil
+}
+
+// Stop unsubscribes; agents keep forwarding to other subscribers
+func (r *RelayCapture) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.running {
+		return fmt.Errorf("relay capture not running")
+	}
+	relayHub.unsubscribe(r.packetChan)
+	r.running = false
+	return nil
+}
+
+// GetPacketChannel returns the channel to receive packets
+func (r *RelayCapture) GetPacketChannel() <-chan *Packet {
+	return r.packetChan
+}
+
+// PublishRelayPacket hands a packet received from a sensor to every relay subscriber and
+// returns how many subscribers were too slow to take it
+func PublishRelayPacket(p *Packet) int {
+	return relayHub.broadcast(p)
+}
+
+var relayHub = &relayFanout{subs: make(map[chan *Packet]string)}
+
+type relayFanout struct {
+	mu   sync.RWMutex
+	subs map[chan *Packet]string // channel -> sensor filter
+}
+
+func (h *relayFanout) subscribe(ch chan *Packet, sensor string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[ch] = sensor
+}
+
+func (h *relayFanout) unsubscribe(ch chan *Packet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, ch)
+}
+
+func (h *relayFanout) broadcast(p *Packet) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	dropped := 0
+	for ch, sensor := range h.subs {
+		if sensor != "" && sensor != p.Sensor {
+			continue
+		}
+		select {
+		case ch <- p:
+		default:
+			// drop if client is slow; never stall ingest from the agents
+			dropped++
+		}
+	}
+	return dropped
+}
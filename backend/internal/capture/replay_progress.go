@@ -0,0 +1,152 @@
+package capture
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReplayProgress is how far a PCAP or time window replay has got ("replay_progress"). Times are
+// the capture's own, in Unix milliseconds.
+type ReplayProgress struct {
+	Type       string  `json:"type"`   // always "replay_progress"
+	Source     string  `json:"source"` // pcap_replay or time_window
+	Position   int64   `json:"position"`
+	Start      int64   `json:"start"`
+	End        int64   `json:"end"`
+	Percent    float64 `json:"percent"`
+	Packets    uint64  `json:"packets"`         // replayed so far
+	Total      int64   `json:"total,omitempty"` // packets in the file (PCAP replay only)
+	Speed      float64 `json:"speed"`
+	ETASeconds float64 `json:"eta_seconds"` // at the current speed
+	Done       bool    `json:"done,omitempty"`
+}
+
+// ToJSON converts replay progress to JSON
+func (p *ReplayProgress) ToJSON() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// ReplayComplete reports a PCAP replay that reached the end of its file or time range, with its
+// totals ("replay_complete"). Start and End are the capture times, in Unix ms, of the first and
+// last packets read.
+type ReplayComplete struct {
+	Type       string `json:"type"`   // always "replay_complete"
+	Source     string `json:"source"` // pcap_replay
+	File       string `json:"file"`
+	Packets    uint64 `json:"packets"` // replayed
+	Bytes      uint64 `json:"bytes"`
+	Skipped    uint64 `json:"skipped,omitempty"`   // before the time range
+	Undecoded  uint64 `json:"undecoded,omitempty"` // not IP traffic
+	Dropped    uint64 `json:"dropped,omitempty"`   // lost to a full packet channel
+	Start      int64  `json:"start"`
+	End        int64  `json:"end"`
+	DurationMs int64  `json:"duration_ms"` // wall clock time the replay took
+	OnComplete string `json:"on_complete"` // what the session does next: loop, switch_to_live or stop
+	Mode       string `json:"mode"`        // the session's capture mode from now on
+}
+
+// ToJSON converts a replay completion to JSON
+func (c *ReplayComplete) ToJSON() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// ProgressReporter is a replay that knows how far it has got. ok is false while that isn't
+// known yet, such as before a PCAP file's time span has been measured.
+type ProgressReporter interface {
+	Progress() (progress *ReplayProgress, ok bool)
+}
+
+func newReplayProgress(source string, position, start, end time.Time, speed float64, packets uint64, done bool) *ReplayProgress {
+	if position.Before(start) {
+		position = start
+	}
+	if position.After(end) || done {
+		position = end
+	}
+	percent := 100.0
+	if span := end.Sub(start); span > 0 {
+		percent = math.Round(float64(position.Sub(start))/float64(span)*1000) / 10
+	}
+	eta := 0.0
+	if speed > 0 {
+		eta = math.Round(end.Sub(position).Seconds()/speed*10) / 10
+	}
+	return &ReplayProgress{
+		Type:       "replay_progress",
+		Source:     source,
+		Position:   position.UnixMilli(),
+		Start:      start.UnixMilli(),
+		End:        end.UnixMilli(),
+		Percent:    percent,
+		Packets:    packets,
+		Speed:      speed,
+		ETASeconds: eta,
+		Done:       done,
+	}
+}
+
+// measureSpan finds the time span and packet count progress is measured against, from the file's
+// manifest or else by reading the file once, off the replay's goroutine
+func (p *PCAPReplayCapture) measureSpan() {
+	info, err := os.Stat(p.pcapFile)
+	if err != nil {
+		return
+	}
+	store := DirStore(filepath.Dir(p.pcapFile))
+	obj := ArchiveObject{Path: p.pcapFile, Size: info.Size(), ModTime: info.ModTime()}
+	span, ok := loadManifest(store, obj)
+	if !ok {
+		if span, err = indexArchiveFile(store, obj); err != nil {
+			return
+		}
+	}
+	if span.PacketCount > 0 {
+		p.span.Store(span)
+	}
+}
+
+// complete records the replay's totals once it has reached its end (replay goroutine only)
+func (p *PCAPReplayCapture) complete(totals *ReplayComplete, skipped int) {
+	totals.Packets = p.replayed.Load()
+	totals.Skipped = uint64(skipped)
+	totals.DurationMs = time.Since(p.replayStartTime).Milliseconds()
+	p.completed.Store(totals)
+}
+
+// Completed returns the totals of a replay that reached its end, nil while it runs or after it
+// was stopped early. Start again replays the file from the beginning.
+func (p *PCAPReplayCapture) Completed() *ReplayComplete {
+	return p.completed.Load()
+}
+
+// Progress reports the replay's position within its file, or within its time range if it has one
+func (p *PCAPReplayCapture) Progress() (*ReplayProgress, bool) {
+	span := p.span.Load()
+	if span == nil {
+		return nil, false
+	}
+	start, end := span.StartTime, span.EndTime
+	if p.useTimeRange {
+		if !p.startTime.IsZero() && p.startTime.After(start) {
+			start = p.startTime
+		}
+		if !p.endTime.IsZero() && p.endTime.Before(end) {
+			end = p.endTime
+		}
+	}
+	position := start
+	if nanos := p.position.Load(); nanos != 0 {
+		position = time.Unix(0, nanos)
+	}
+	progress := newReplayProgress("pcap_replay", position, start, end, p.replaySpeed, p.replayed.Load(), p.completed.Load() != nil)
+	progress.Total = span.PacketCount
+	return progress, true
+}
+
+// Progress reports the playback's position within its window
+func (twp *TimeWindowProcessor) Progress() (*ReplayProgress, bool) {
+	return newReplayProgress("time_window", twp.Position(), twp.startTime, twp.endTime, twp.replaySpeed, twp.replayed.Load(), twp.done.Load()), true
+}
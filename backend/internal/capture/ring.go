@@ -0,0 +1,151 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// RingDump describes a PCAP written from a packet ring
+type RingDump struct {
+	File    string    `json:"file"`
+	IP      string    `json:"ip,omitempty"` // empty: every packet
+	From    time.Time `json:"from,omitempty"`
+	To      time.Time `json:"to,omitempty"`
+	Packets int64     `json:"packets"`
+	Bytes   int64     `json:"bytes"`
+	Skipped int64     `json:"skipped"` // a different link type than the file
+}
+
+type ringFrame struct {
+	ci       gopacket.CaptureInfo
+	raw      []byte
+	linkType layers.LinkType
+	src, dst string
+}
+
+// PacketRing is a flight recorder: it keeps the original frames of the last window of traffic,
+// up to maxBytes, so they can be written out after something went wrong
+type PacketRing struct {
+	mu       sync.Mutex
+	window   time.Duration
+	maxBytes int
+	frames   []ringFrame // oldest first, from head on
+	head     int
+	bytes    int
+	newest   time.Time
+}
+
+// NewPacketRing creates a ring keeping window of traffic, and no more than maxBytes of frames
+func NewPacketRing(window time.Duration, maxBytes int) *PacketRing {
+	return &PacketRing{window: window, maxBytes: maxBytes}
+}
+
+// Add keeps a packet's frame; packets without one, such as simulated traffic, are ignored
+func (r *PacketRing) Add(p *Packet) {
+	if len(p.Raw) == 0 {
+		return
+	}
+	ci := p.CaptureInfo
+	if ci.Timestamp.IsZero() {
+		ci.Timestamp = time.UnixMilli(p.Timestamp)
+	}
+	ci.CaptureLength = len(p.Raw)
+	if ci.Length < ci.CaptureLength {
+		ci.Length = ci.CaptureLength
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A jump back in time means the session moved to another source, such as a time window:
+	// what the ring holds no longer leads up to what comes next
+	if ci.Timestamp.Before(r.newest.Add(-r.window)) {
+		r.reset()
+	}
+	if ci.Timestamp.After(r.newest) {
+		r.newest = ci.Timestamp
+	}
+	r.frames = append(r.frames, ringFrame{ci: ci, raw: p.Raw, linkType: p.LinkType, src: p.Src, dst: p.Dst})
+	r.bytes += len(p.Raw)
+
+	cutoff := r.newest.Add(-r.window)
+	for r.head < len(r.frames) && (r.frames[r.head].ci.Timestamp.Before(cutoff) || r.bytes > r.maxBytes) {
+		r.bytes -= len(r.frames[r.head].raw)
+		r.frames[r.head] = ringFrame{}
+		r.head++
+	}
+	// Compact once the dropped frames make up half the slice, so appends reuse the space
+	if r.head > len(r.frames)/2 {
+		r.frames = append(r.frames[:0], r.frames[r.head:]...)
+		r.head = 0
+	}
+}
+
+func (r *PacketRing) reset() {
+	r.frames, r.head, r.bytes, r.newest = nil, 0, 0, time.Time{}
+}
+
+// DumpFile writes the frames of the last span of traffic the ring holds to a new PCAP file in
+// dir, keeping only those to or from ip unless it is empty. A span of 0 writes the whole ring.
+func (r *PacketRing) DumpFile(dir, ip string, span time.Duration) (RingDump, error) {
+	r.mu.Lock()
+	var frames []ringFrame
+	var cutoff time.Time
+	if span > 0 {
+		cutoff = r.newest.Add(-span)
+	}
+	for _, frame := range r.frames[r.head:] {
+		if frame.ci.Timestamp.Before(cutoff) {
+			continue
+		}
+		if ip == "" || frame.src == ip || frame.dst == ip {
+			frames = append(frames, frame)
+		}
+	}
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return RingDump{}, fmt.Errorf("creating recording directory: %w", err)
+	}
+	subject := "all"
+	if ip != "" {
+		// IPv6 colons aren't allowed in Windows file names
+		subject = strings.ReplaceAll(ip, ":", "-")
+	}
+	path := filepath.Join(dir, fmt.Sprintf("vibes-ring-%s-%s.pcap", subject, time.Now().Format("20060102-150405.000")))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return RingDump{}, fmt.Errorf("creating dump file: %w", err)
+	}
+	defer file.Close()
+
+	dump := RingDump{File: path, IP: ip}
+	linkType := layers.LinkTypeEthernet
+	if len(frames) > 0 {
+		linkType = frames[0].linkType
+		dump.From, dump.To = frames[0].ci.Timestamp, frames[len(frames)-1].ci.Timestamp
+	}
+	writer := pcapgo.NewWriter(file)
+	if err := writer.WriteFileHeader(recorderSnaplen, linkType); err != nil {
+		return dump, err
+	}
+	for _, frame := range frames {
+		if frame.linkType != linkType {
+			dump.Skipped++
+			continue
+		}
+		if err := writer.WritePacket(frame.ci, frame.raw); err != nil {
+			return dump, err
+		}
+		dump.Packets++
+		dump.Bytes += int64(len(frame.raw))
+	}
+	return dump, file.Close()
+}
@@ -0,0 +1,306 @@
+package capture
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// Device roles reported in node_info messages
+const (
+	RoleServer         = "server"
+	RoleClient         = "client"
+	RolePrinter        = "printer"
+	RoleIoT            = "iot"
+	RoleInfrastructure = "infrastructure"
+)
+
+// Ports whose service gives a host's role away. Other services count towards server.
+var (
+	printerPorts = map[int]bool{515: true, 631: true, 9100: true}
+	infraPorts   = map[int]bool{
+		53: true, 67: true, 69: true, 88: true, 123: true, 161: true, 162: true, 179: true,
+		389: true, 514: true, 547: true, 636: true, 1812: true, 1813: true, 3268: true,
+	}
+	iotPorts = map[int]bool{554: true, 1900: true, 5683: true, 8008: true, 8009: true, 8883: true, 1883: true}
+	// iotBrokerPorts are the ports IoT devices connect out to: MQTT and CoAP
+	iotBrokerPorts = map[int]bool{1883: true, 8883: true, 5683: true}
+)
+
+// RoleConfig holds the limits and thresholds of role classification
+type RoleConfig struct {
+	MinEvidence     float64       // score a host needs before it is classified at all
+	MinChange       float64       // confidence a known role must move by to be reported again
+	PeerWeight      int           // distinct peers of a service that count, at most
+	TargetWeight    int           // distinct services a client connects to that count, at most
+	TargetsPerPoint int           // services a client connects to per point of client score
+	MaxHosts        int           // hosts tracked at once
+	MaxServices     int           // services remembered per host
+	HostIdle        time.Duration // forget a host after this long without packets
+	MaxPerReport    int           // node_info messages per report at most; the rest wait for the next
+	ServicesShown   int           // busiest services listed in a classification
+}
+
+// DefaultRoleConfig returns thresholds for a conference network
+func DefaultRoleConfig() RoleConfig {
+	return RoleConfig{
+		MinEvidence:     3,
+		MinChange:       0.1,
+		PeerWeight:      16,
+		TargetWeight:    40,
+		TargetsPerPoint: 4,
+		MaxHosts:        50000,
+		MaxServices:     32,
+		HostIdle:        30 * time.Minute,
+		MaxPerReport:    200,
+		ServicesShown:   5,
+	}
+}
+
+// NodeRole is the role side of node_info: what a host looks like from the ports it serves
+// and connects to
+type NodeRole struct {
+	Type string `json:"type"` // always "node_info"
+	IP   string `json:"ip"`
+	Role *Role  `json:"role"`
+}
+
+// Role is a host's classification. Confidence is the winning role's share of the host's
+// evidence, discounted while there is little of it.
+type Role struct {
+	Role       string             `json:"role"`
+	Confidence float64            `json:"confidence"`
+	Scores     map[string]float64 `json:"scores"`             // evidence per role
+	Services   []int              `json:"services,omitempty"` // ports the host serves, busiest first
+}
+
+// ToJSON converts a role update to JSON
+func (r *NodeRole) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// roleService is one port a host answers on
+type roleService struct {
+	peers map[string]struct{} // up to PeerWeight
+	count int                 // connections or datagrams answered
+}
+
+// roleHost is what a host has been seen doing
+type roleHost struct {
+	services   map[int]*roleService
+	targets    map[targetKey]struct{} // services connected to, up to TargetWeight
+	brokers    int                    // connections to MQTT and CoAP brokers
+	lastSeen   time.Time
+	reported   *Role
+	unreported bool // evidence changed since the last report
+}
+
+type targetKey struct {
+	ip   string
+	port int
+}
+
+// RoleClassifier guesses what each host is (server, client, printer, IoT device or network
+// infrastructure) from the services it answers on and the ones it connects to, accumulated
+// for as long as it keeps sending. TCP roles come from handshakes: a SYN-ACK shows the port
+// served and a SYN the port connected to. UDP and flagless TCP (simulated, Zeek) count when
+// one side is a well-known port and the other an ephemeral one. It is not safe for concurrent
+// use; each session's forwarder owns one.
+type RoleClassifier struct {
+	config RoleConfig
+	hosts  map[string]*roleHost
+}
+
+// NewRoleClassifier creates an empty classifier
+func NewRoleClassifier(config RoleConfig) *RoleClassifier {
+	return &RoleClassifier{
+		config: config,
+		hosts:  make(map[string]*roleHost),
+	}
+}
+
+// Observe records a packet's service behavior
+func (c *RoleClassifier) Observe(p *Packet) {
+	if p.Cast != "" || (p.Protocol != "TCP" && p.Protocol != "UDP") {
+		return
+	}
+	switch {
+	case p.Protocol == "TCP" && p.TCPFlags != "":
+		switch p.TCPFlags {
+		case "SA":
+			c.serve(p.Src, p.SrcPort, p.Dst)
+		case "S":
+			c.connect(p.Src, p.Dst, p.DstPort)
+		}
+	case servicePort(p.SrcPort) && !servicePort(p.DstPort):
+		c.serve(p.Src, p.SrcPort, p.Dst)
+	case servicePort(p.DstPort) && !servicePort(p.SrcPort):
+		c.connect(p.Src, p.Dst, p.DstPort)
+	}
+}
+
+// servicePort reports whether a port looks like a service rather than an ephemeral port
+func servicePort(port int) bool {
+	return port > 0 && (port < 1024 || printerPorts[port] || infraPorts[port] || iotPorts[port])
+}
+
+// ClientService reads which side of a packet is the client and which the service it reached, on
+// the same evidence as the role classifier: a SYN for TCP handshakes, well-known against
+// ephemeral ports otherwise. ok is false when the packet doesn't tell.
+func ClientService(p *Packet) (client, server string, port int, ok bool) {
+	if p.Cast != "" || (p.Protocol != "TCP" && p.Protocol != "UDP") {
+		return "", "", 0, false
+	}
+	switch {
+	case p.Protocol == "TCP" && p.TCPFlags != "":
+		if p.TCPFlags == "S" {
+			return p.Src, p.Dst, p.DstPort, true
+		}
+	case servicePort(p.DstPort) && !servicePort(p.SrcPort):
+		return p.Src, p.Dst, p.DstPort, true
+	case servicePort(p.SrcPort) && !servicePort(p.DstPort):
+		return p.Dst, p.Src, p.SrcPort, true
+	}
+	return "", "", 0, false
+}
+
+func (c *RoleClassifier) host(ip string) *roleHost {
+	host, ok := c.hosts[ip]
+	if !ok {
+		if len(c.hosts) >= c.config.MaxHosts {
+			return nil
+		}
+		host = &roleHost{services: make(map[int]*roleService), targets: make(map[targetKey]struct{})}
+		c.hosts[ip] = host
+	}
+	host.lastSeen = time.Now()
+	return host
+}
+
+func (c *RoleClassifier) serve(ip string, port int, peer string) {
+	host := c.host(ip)
+	if host == nil {
+		return
+	}
+	service, ok := host.services[port]
+	if !ok {
+		if len(host.services) >= c.config.MaxServices {
+			return
+		}
+		service = &roleService{peers: make(map[string]struct{})}
+		host.services[port] = service
+	}
+	service.count++
+	if _, seen := service.peers[peer]; !seen && len(service.peers) < c.config.PeerWeight {
+		service.peers[peer] = struct{}{}
+		host.unreported = true
+	}
+}
+
+func (c *RoleClassifier) connect(ip, server string, port int) {
+	host := c.host(ip)
+	if host == nil {
+		return
+	}
+	key := targetKey{server, port}
+	if _, seen := host.targets[key]; seen || len(host.targets) >= c.config.TargetWeight {
+		return
+	}
+	host.targets[key] = struct{}{}
+	if iotBrokerPorts[port] {
+		host.brokers++
+	}
+	host.unreported = true
+}
+
+// classify scores a host's evidence. Each service scores one point per distinct peer, and
+// connecting out scores a point per few services reached.
+func (c *RoleClassifier) classify(host *roleHost) *Role {
+	scores := map[string]float64{}
+	for port, service := range host.services {
+		role := RoleServer
+		switch {
+		case printerPorts[port]:
+			role = RolePrinter
+		case infraPorts[port]:
+			role = RoleInfrastructure
+		case iotPorts[port]:
+			role = RoleIoT
+		}
+		scores[role] += float64(len(service.peers))
+	}
+	scores[RoleIoT] += float64(host.brokers)
+	scores[RoleClient] += float64(len(host.targets)-host.brokers) / float64(c.config.TargetsPerPoint)
+
+	total, best := 0.0, ""
+	for role, score := range scores {
+		if score <= 0 {
+			delete(scores, role)
+			continue
+		}
+		total += score
+		if best == "" || score > scores[best] || (score == scores[best] && role < best) {
+			best = role
+		}
+	}
+	if total < c.config.MinEvidence {
+		return nil
+	}
+	// Little evidence is a weak guess whatever the split
+	certainty := math.Min(1, total/(4*c.config.MinEvidence))
+	role := &Role{
+		Role:       best,
+		Confidence: math.Round(scores[best]/total*certainty*100) / 100,
+		Scores:     scores,
+	}
+	for name, score := range scores {
+		scores[name] = math.Round(score*100) / 100
+	}
+
+	ports := make([]int, 0, len(host.services))
+	for port := range host.services {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, b := host.services[ports[i]], host.services[ports[j]]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return ports[i] < ports[j]
+	})
+	if len(ports) > c.config.ServicesShown {
+		ports = ports[:c.config.ServicesShown]
+	}
+	role.Services = ports
+	return role
+}
+
+// Report returns node_info updates for hosts whose role is new or whose confidence moved,
+// and forgets idle hosts
+func (c *RoleClassifier) Report() []*NodeRole {
+	now := time.Now()
+	var updates []*NodeRole
+	for ip, host := range c.hosts {
+		if now.Sub(host.lastSeen) > c.config.HostIdle {
+			delete(c.hosts, ip)
+			continue
+		}
+		if !host.unreported || len(updates) >= c.config.MaxPerReport {
+			continue
+		}
+		host.unreported = false
+		role := c.classify(host)
+		if role == nil {
+			continue
+		}
+		if previous := host.reported; previous != nil && previous.Role == role.Role &&
+			math.Abs(previous.Confidence-role.Confidence) < c.config.MinChange {
+			continue
+		}
+		host.reported = role
+		updates = append(updates, &NodeRole{Type: "node_info", IP: ip, Role: role})
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].IP < updates[j].IP })
+	return updates
+}
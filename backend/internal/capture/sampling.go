@@ -0,0 +1,90 @@
+package capture
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+)
+
+// Sampling strategies for thinning time window playback
+const (
+	SampleUniform = "uniform" // every packet has the same chance
+	SampleFlow    = "flow"    // a conversation's packets are kept or dropped together
+	SampleSize    = "size"    // larger packets are likelier to be kept
+)
+
+// sizeSmoothing is how many packets the size strategy's running mean packet size spans
+const sizeSmoothing = 256
+
+// SamplingConfig thins time window playback before anything sees it, for dense windows played
+// fast. The zero value keeps every packet.
+type SamplingConfig struct {
+	Strategy   string  `json:"strategy"`    // uniform (the default), flow or size
+	Rate       float64 `json:"rate"`        // fraction of packets kept, (0, 1]; 0 keeps every packet
+	KeepPinned bool    `json:"keep_pinned"` // packets to or from a pinned address are always kept
+}
+
+// Validate reports a strategy or rate playback can't use
+func (c SamplingConfig) Validate() error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return fmt.Errorf("sample_rate must be between 0 and 1")
+	}
+	switch c.Strategy {
+	case "", SampleUniform, SampleFlow, SampleSize:
+	default:
+		return fmt.Errorf("sampling must be %q, %q or %q", SampleUniform, SampleFlow, SampleSize)
+	}
+	return nil
+}
+
+// sampler decides which packets of a playback are kept
+type sampler struct {
+	config   SamplingConfig
+	meanSize float64
+}
+
+func newSampler(config SamplingConfig) *sampler {
+	if config.Rate <= 0 || config.Rate >= 1 {
+		return nil
+	}
+	return &sampler{config: config}
+}
+
+// keep reports whether a packet stays in the playback; a nil sampler keeps everything
+func (s *sampler) keep(p *Packet) bool {
+	if s == nil {
+		return true
+	}
+	if s.config.KeepPinned && p.Pinned {
+		return true
+	}
+
+	switch s.config.Strategy {
+	case SampleFlow:
+		return flowFraction(p) < s.config.Rate
+	case SampleSize:
+		// Weighted by size against the running mean, so the share kept stays near the rate
+		size := float64(p.Size)
+		if s.meanSize == 0 {
+			s.meanSize = size
+		} else {
+			s.meanSize += (size - s.meanSize) / sizeSmoothing
+		}
+		return s.meanSize <= 0 || rand.Float64() < s.config.Rate*size/s.meanSize
+	}
+	return rand.Float64() < s.config.Rate
+}
+
+// flowFraction hashes a packet's conversation (protocol and both endpoints, in either direction)
+// to a number in [0, 1) that is the same for every packet of it
+func flowFraction(p *Packet) float64 {
+	a := p.Src + ":" + strconv.Itoa(p.SrcPort)
+	b := p.Dst + ":" + strconv.Itoa(p.DstPort)
+	if b < a {
+		a, b = b, a
+	}
+	h := fnv.New32a()
+	h.Write([]byte(p.Protocol + "|" + a + "|" + b))
+	return float64(h.Sum32()) / (1 << 32)
+}
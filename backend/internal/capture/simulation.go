@@ -0,0 +1,362 @@
+package capture
+
+import (
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"vibes-network-visualizer/internal/scenario"
+)
+
+const (
+	// scenarioTick is how often the scenario engine emits the packets that came due
+	scenarioTick = time.Millisecond
+	// maxPacketsPerTick bounds the catch-up burst after the process stalls
+	maxPacketsPerTick = 10000
+	// lateralHopPackets is how many packets lateral movement sends before the target is taken over
+	lateralHopPackets = 60
+)
+
+// simDropped counts generated packets discarded because the consumer fell behind
+var simDropped atomic.Uint64
+
+// Scenario returns the scenario being simulated
+func (s *SimulatedCapture) Scenario() *scenario.Scenario {
+	return s.scenario.Load()
+}
+
+// SetScenario switches to another scenario; its timeline starts from T+0
+func (s *SimulatedCapture) SetScenario(sc *scenario.Scenario) {
+	s.scenario.Store(sc)
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Trigger starts an attack on top of the running scenario; false if the engine is backlogged
+func (s *SimulatedCapture) Trigger(t *scenario.Triggered) bool {
+	select {
+	case s.triggers <- t:
+		return true
+	default:
+		return false
+	}
+}
+
+// activeFlow is a traffic matrix row or a running event with its emission state
+type activeFlow struct {
+	name      string
+	kind      string
+	flow      *scenario.Flow
+	src, dst  []string
+	pairs     [][2]string
+	protocols []string // expanded by weight
+	due       float64  // packets owed but not yet emitted
+	until     time.Duration
+	deadline  time.Time // triggered attacks end at a wall-clock time rather than on the timeline
+
+	// state of the single-source and single-target kinds
+	source, target string
+	targetIndex    int
+	port           int
+	sent           int
+	visited        map[string]bool
+}
+
+func newActiveFlow(flow *scenario.Flow, kind string, src, dst []string, rng *rand.Rand) *activeFlow {
+	f := &activeFlow{
+		name: flow.Name,
+		kind: kind,
+		flow: flow,
+		src:  src,
+		dst:  dst,
+	}
+	for protocol, weight := range flow.Protocols {
+		for i := 0; i < weight; i++ {
+			f.protocols = append(f.protocols, protocol)
+		}
+	}
+	if len(f.protocols) == 0 {
+		f.protocols = []string{ProtocolTCP}
+	}
+	for i := 0; i < flow.Pairs; i++ {
+		f.pairs = append(f.pairs, [2]string{pick(rng, f.src), pick(rng, f.dst)})
+	}
+	switch kind {
+	case scenario.KindScan:
+		f.source = pick(rng, f.src)
+		f.port = flow.Ports[0]
+	case scenario.KindDDoS, scenario.KindSYNFlood:
+		f.target = pick(rng, f.dst)
+	case scenario.KindDNSExfil, scenario.KindBeacon:
+		f.source, f.target = pick(rng, f.src), pick(rng, f.dst)
+	case scenario.KindLateral:
+		f.source = pick(rng, f.src)
+		f.visited = map[string]bool{f.source: true}
+		f.nextHop(rng)
+	}
+	return f
+}
+
+// nextHop picks the next host lateral movement goes after, preferring ones not yet taken over
+func (f *activeFlow) nextHop(rng *rand.Rand) {
+	var fresh []string
+	for _, addr := range f.dst {
+		if !f.visited[addr] {
+			fresh = append(fresh, addr)
+		}
+	}
+	if len(fresh) == 0 {
+		f.visited = map[string]bool{f.source: true}
+		fresh = f.dst
+	}
+	f.target = pick(rng, fresh)
+	f.port = f.flow.Ports[rng.Intn(len(f.flow.Ports))]
+	f.sent = 0
+}
+
+func pick(rng *rand.Rand, addresses []string) string {
+	return addresses[rng.Intn(len(addresses))]
+}
+
+// emit generates one packet (plus its reply, if any)
+func (f *activeFlow) emit(s *SimulatedCapture, rng *rand.Rand) {
+	flow := f.flow
+	size := flow.Size[0] + rng.Intn(flow.Size[1]-flow.Size[0]+1)
+	protocol := f.protocols[rng.Intn(len(f.protocols))]
+
+	switch f.kind {
+	case scenario.KindScan:
+		target := f.dst[f.targetIndex]
+		packet := NewPacket(f.source, target, 40000+rng.Intn(20000), f.port, 60, ProtocolTCP)
+		packet.TCPFlags = "S"
+		s.send(packet)
+		if rng.Float64() < flow.Reply {
+			reply := NewPacket(target, f.source, f.port, packet.SrcPort, 60, ProtocolTCP)
+			reply.TCPFlags = "RA"
+			s.send(reply)
+		}
+		// Every target gets a port before moving on to the next port
+		f.targetIndex++
+		if f.targetIndex == len(f.dst) {
+			f.targetIndex = 0
+			f.port++
+			if f.port > flow.Ports[1] {
+				f.port = flow.Ports[0]
+			}
+		}
+		return
+
+	case scenario.KindDDoS:
+		srcPort, dstPort := f.ports(rng, protocol)
+		packet := NewPacket(pick(rng, f.src), f.target, srcPort, dstPort, size, protocol)
+		if protocol == ProtocolTCP {
+			packet.TCPFlags = "S"
+		}
+		s.send(packet)
+		return
+
+	case scenario.KindSYNFlood:
+		srcPort, dstPort := f.ports(rng, ProtocolTCP)
+		source := pick(rng, f.src)
+		packet := NewPacket(source, f.target, srcPort, dstPort, size, ProtocolTCP)
+		packet.TCPFlags = "S"
+		s.send(packet)
+		// The target answers some of them, but the handshake is never completed
+		if rng.Float64() < flow.Reply {
+			reply := NewPacket(f.target, source, dstPort, srcPort, 60, ProtocolTCP)
+			reply.TCPFlags = "SA"
+			s.send(reply)
+		}
+		return
+
+	case scenario.KindDNSExfil:
+		srcPort, dstPort := f.ports(rng, ProtocolUDP)
+		s.send(NewPacket(f.source, f.target, srcPort, dstPort, size, ProtocolUDP))
+		if rng.Float64() < flow.Reply {
+			// Answers are short, which is what makes the query sizes stand out
+			s.send(NewPacket(f.target, f.source, dstPort, srcPort, 80+rng.Intn(60), ProtocolUDP))
+		}
+		return
+
+	case scenario.KindLateral:
+		srcPort := 49152 + rng.Intn(16384)
+		s.send(NewPacket(f.source, f.target, srcPort, f.port, size, ProtocolTCP))
+		if rng.Float64() < flow.Reply {
+			replySize := flow.Size[0] + rng.Intn(flow.Size[1]-flow.Size[0]+1)
+			s.send(NewPacket(f.target, f.source, f.port, srcPort, replySize, ProtocolTCP))
+		}
+		f.sent++
+		if f.sent >= lateralHopPackets {
+			f.source = f.target
+			f.visited[f.source] = true
+			f.nextHop(rng)
+		}
+		return
+
+	case scenario.KindBeacon:
+		srcPort, dstPort := f.ports(rng, protocol)
+		s.send(NewPacket(f.source, f.target, srcPort, dstPort, size, protocol))
+		if rng.Float64() < flow.Reply {
+			replySize := flow.Size[0] + rng.Intn(flow.Size[1]-flow.Size[0]+1)
+			s.send(NewPacket(f.target, f.source, dstPort, srcPort, replySize, protocol))
+		}
+		return
+	}
+
+	var src, dst string
+	if len(f.pairs) > 0 {
+		pair := f.pairs[rng.Intn(len(f.pairs))]
+		src, dst = pair[0], pair[1]
+	} else {
+		src, dst = pick(rng, f.src), pick(rng, f.dst)
+		if src == dst && len(f.dst) > 1 {
+			dst = pick(rng, f.dst)
+		}
+	}
+	srcPort, dstPort := f.ports(rng, protocol)
+	s.send(NewPacket(src, dst, srcPort, dstPort, size, protocol))
+	if rng.Float64() < flow.Reply {
+		replySize := flow.Size[0] + rng.Intn(flow.Size[1]-flow.Size[0]+1)
+		s.send(NewPacket(dst, src, dstPort, srcPort, replySize, protocol))
+	}
+}
+
+// ports picks ports for a packet: the flow's destination ports when it lists any, realistic ones otherwise
+func (f *activeFlow) ports(rng *rand.Rand, protocol string) (int, int) {
+	srcPort, dstPort := generateRealisticPorts(protocol)
+	if len(f.flow.Ports) > 0 && protocol != ProtocolICMP {
+		dstPort = f.flow.Ports[rng.Intn(len(f.flow.Ports))]
+		srcPort = 32768 + rng.Intn(32767)
+	}
+	return srcPort, dstPort
+}
+
+// send queues a packet without blocking the engine
+func (s *SimulatedCapture) send(packet *Packet) {
+	select {
+	case s.packetChan <- packet:
+	default:
+		if n := simDropped.Add(1); n == 1 || n%100000 == 0 {
+			log.Printf("Simulation outpacing its consumer: %d packets discarded", n)
+		}
+	}
+}
+
+// generatePackets runs the scenario: the traffic matrix continuously, events on their timeline
+func (s *SimulatedCapture) generatePackets() {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(scenarioTick)
+	defer ticker.Stop()
+
+	var (
+		sc        *scenario.Scenario
+		traffic   []*activeFlow
+		events    []*activeFlow
+		attacks   []*activeFlow
+		start     time.Time
+		last      time.Time
+		nextEvent int
+	)
+	load := func() {
+		sc = s.scenario.Load()
+		traffic = traffic[:0]
+		for i := range sc.Traffic {
+			flow := &sc.Traffic[i]
+			traffic = append(traffic, newActiveFlow(flow, scenario.KindFlow, sc.Addresses(flow.From), sc.Addresses(flow.To), rng))
+		}
+		events = events[:0]
+		attacks = attacks[:0]
+		start, last = time.Now(), time.Now()
+		nextEvent = 0
+		log.Printf("🎬 Scenario %s: %d nodes, %d flows, %d events", sc.Name, sc.NodeCount(), len(sc.Traffic), len(sc.Events))
+	}
+	load()
+
+	for {
+		select {
+		case <-s.stopChan:
+			log.Println("Stopping simulated packet capture")
+			return
+
+		case <-s.reload:
+			load()
+
+		case t := <-s.triggers:
+			active := newActiveFlow(&t.Event.Flow, t.Event.Kind, t.Sources, t.Targets, rng)
+			active.name = t.Attack
+			active.deadline = time.Now().Add(t.Event.Duration)
+			active.due = 1 // on cue: the first packet goes out with the next tick, even for slow beacons
+			attacks = append(attacks, active)
+			log.Printf("🎯 Scenario %s: attack %s triggered for %s", sc.Name, t.Attack, t.Event.Duration)
+
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if sc.Loop > 0 && elapsed >= sc.Loop {
+				start, elapsed = now, 0
+				events = events[:0]
+				nextEvent = 0
+				log.Printf("🎬 Scenario %s: restarting timeline", sc.Name)
+			}
+			for nextEvent < len(sc.Events) && sc.Events[nextEvent].At <= elapsed {
+				event := &sc.Events[nextEvent]
+				active := newActiveFlow(&event.Flow, event.Kind, sc.Addresses(event.From), sc.Addresses(event.To), rng)
+				if event.Duration > 0 {
+					active.until = event.At + event.Duration
+				}
+				events = append(events, active)
+				log.Printf("🎬 Scenario %s: %s %s at T+%s", sc.Name, event.Kind, event.Name, event.At)
+				nextEvent++
+			}
+
+			dt := now.Sub(last).Seconds()
+			last = now
+			for _, f := range traffic {
+				f.run(s, rng, dt)
+			}
+			running := events[:0]
+			for _, f := range events {
+				if f.until > 0 && elapsed >= f.until {
+					log.Printf("🎬 Scenario %s: %s %s ended", sc.Name, f.kind, f.name)
+					continue
+				}
+				f.run(s, rng, dt)
+				running = append(running, f)
+			}
+			events = running
+			running = attacks[:0]
+			for _, f := range attacks {
+				if now.After(f.deadline) {
+					log.Printf("🎯 Scenario %s: attack %s ended", sc.Name, f.name)
+					continue
+				}
+				f.run(s, rng, dt)
+				running = append(running, f)
+			}
+			attacks = running
+		}
+	}
+}
+
+// run emits the packets that came due over dt seconds
+func (f *activeFlow) run(s *SimulatedCapture, rng *rand.Rand, dt float64) {
+	f.due += f.flow.Rate * dt
+	if jitter := f.flow.Jitter; jitter > 0 {
+		// Each packet uses up between 1-jitter and 1+jitter intervals, so the gaps wander around the rate
+		for n := 0; f.due >= 1 && n < maxPacketsPerTick; n++ {
+			f.emit(s, rng)
+			f.due -= 1 + jitter*(2*rng.Float64()-1)
+		}
+		return
+	}
+	n := int(f.due)
+	f.due -= float64(n)
+	if n > maxPacketsPerTick {
+		n = maxPacketsPerTick
+	}
+	for i := 0; i < n; i++ {
+		f.emit(s, rng)
+	}
+}
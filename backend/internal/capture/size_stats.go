@@ -0,0 +1,254 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Packet size anomaly kinds reported in size_anomaly events
+const (
+	AnomalyTinyPackets   = "tiny_packet_spike" // fragmentation floods, SYN/ACK floods
+	AnomalyMaxSizePacket = "max_size_spike"    // bulk transfers at the MTU, PMTU black holes
+	AnomalyJumboFrames   = "jumbo_frames"      // frames above 1518 bytes on a network that doesn't expect them
+)
+
+// SizeBounds are the upper bounds of the packet size histogram's buckets; a last bucket holds
+// everything larger (jumbo frames)
+var SizeBounds = []int{64, 128, 256, 512, 1024, 1499, 1518}
+
+// SizeAnomalyConfig holds the thresholds for packet size spikes. A class spikes when its share
+// of an interface's packets reaches its floor and SpikeFactor times its usual share.
+type SizeAnomalyConfig struct {
+	TinyMax        int     // packets up to this size are tiny
+	MaxSizeMin     int     // packets from this size up to JumboMin are max-size
+	JumboMin       int     // packets from this size up are jumbo frames
+	TinyFloor      float64 // share of tiny packets that can spike
+	MaxSizeFloor   float64
+	JumboFloor     float64
+	SpikeFactor    float64
+	MinPackets     int64         // ignore intervals with fewer packets on the interface
+	BaselineWeight float64       // how fast the usual shares follow the traffic (EWMA weight per interval)
+	Cooldown       time.Duration // minimum gap between repeat alerts for the same interface and kind
+}
+
+// DefaultSizeAnomalyConfig returns thresholds tuned for Ethernet with a 1500-byte MTU
+func DefaultSizeAnomalyConfig() SizeAnomalyConfig {
+	return SizeAnomalyConfig{
+		TinyMax:        64,
+		MaxSizeMin:     1500,
+		JumboMin:       1519,
+		TinyFloor:      0.2,
+		MaxSizeFloor:   0.5,
+		JumboFloor:     0.01,
+		SpikeFactor:    3,
+		MinPackets:     200,
+		BaselineWeight: 0.1,
+		Cooldown:       time.Minute,
+	}
+}
+
+// InterfaceSizes is one interface's packet size distribution over a report interval
+type InterfaceSizes struct {
+	Interface string  `json:"interface"`
+	Packets   int64   `json:"packets"`
+	Bytes     int64   `json:"bytes"`
+	Counts    []int64 `json:"counts"` // per bucket of SizeBounds, plus one for larger packets
+	Tiny      int64   `json:"tiny"`
+	MaxSize   int64   `json:"max_size"`
+	Jumbo     int64   `json:"jumbo"`
+
+	usual sizeShares // baseline shares, carried across intervals
+	warm  bool       // usual has seen at least one full interval
+}
+
+// sizeShares are the fractions of an interface's packets in each watched class
+type sizeShares struct {
+	tiny, maxSize, jumbo float64
+}
+
+// SizeStats is the periodic size_stats message
+type SizeStats struct {
+	Type       string            `json:"type"`
+	Timestamp  int64             `json:"timestamp"`
+	IntervalMs int64             `json:"interval_ms"`
+	Bounds     []int             `json:"bounds"`
+	Interfaces []*InterfaceSizes `json:"interfaces"`
+}
+
+// ToJSON converts size stats to JSON
+func (s *SizeStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// SizeAnomalyEvent flags a spike of tiny, max-size or jumbo packets on an interface
+type SizeAnomalyEvent struct {
+	Type      string  `json:"type"` // always "size_anomaly"
+	Kind      string  `json:"kind"`
+	Timestamp int64   `json:"timestamp"`
+	Interface string  `json:"interface"`
+	Count     int64   `json:"count"`    // packets of the kind this interval
+	Packets   int64   `json:"packets"`  // all packets on the interface this interval
+	Fraction  float64 `json:"fraction"` // Count / Packets
+	Baseline  float64 `json:"baseline"` // the kind's usual share
+}
+
+// ToJSON converts an anomaly event to JSON
+func (e *SizeAnomalyEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// SizeAnomalyDetector keeps a packet size histogram per interface and flags unusual shares of
+// tiny, max-size and jumbo packets. Relayed packets count against their sensor, everything else
+// against the session's own interface. It is not safe for concurrent use; each session's
+// forwarder owns one.
+type SizeAnomalyDetector struct {
+	config     SizeAnomalyConfig
+	local      string // interface name for packets that weren't relayed
+	interfaces map[string]*InterfaceSizes
+	lastReport time.Time
+	alerted    map[string]time.Time
+}
+
+// NewSizeAnomalyDetector creates a detector; local names the session's capture interface
+func NewSizeAnomalyDetector(config SizeAnomalyConfig, local string) *SizeAnomalyDetector {
+	return &SizeAnomalyDetector{
+		config:     config,
+		local:      local,
+		interfaces: make(map[string]*InterfaceSizes),
+		lastReport: time.Now(),
+		alerted:    make(map[string]time.Time),
+	}
+}
+
+// Observe adds a packet to its interface's histogram. Zeek conn records carry connection
+// totals rather than packet sizes, so they are left out.
+func (d *SizeAnomalyDetector) Observe(p *Packet) {
+	if p.Source == "zeek" {
+		return
+	}
+	name := p.Sensor
+	if name == "" {
+		name = d.local
+	}
+	sizes, ok := d.interfaces[name]
+	if !ok {
+		sizes = &InterfaceSizes{Interface: name, Counts: make([]int64, len(SizeBounds)+1)}
+		d.interfaces[name] = sizes
+	}
+	sizes.Packets++
+	sizes.Bytes += int64(p.Size)
+	sizes.Counts[sort.SearchInts(SizeBounds, p.Size)]++
+	switch {
+	case p.Size <= d.config.TinyMax:
+		sizes.Tiny++
+	case p.Size >= d.config.JumboMin:
+		sizes.Jumbo++
+	case p.Size >= d.config.MaxSizeMin:
+		sizes.MaxSize++
+	}
+}
+
+// Report closes the current interval, returning every interface's histogram plus any new
+// anomaly events. It returns nil stats when no packet was observed.
+func (d *SizeAnomalyDetector) Report() (*SizeStats, []*SizeAnomalyEvent) {
+	now := time.Now()
+	interval := now.Sub(d.lastReport)
+	d.lastReport = now
+
+	var stats *SizeStats
+	var events []*SizeAnomalyEvent
+	for name, sizes := range d.interfaces {
+		if sizes.Packets == 0 {
+			continue
+		}
+		if stats == nil {
+			stats = &SizeStats{
+				Type:       "size_stats",
+				Timestamp:  now.UnixMilli(),
+				IntervalMs: interval.Milliseconds(),
+				Bounds:     SizeBounds,
+			}
+		}
+		report := *sizes
+		report.Counts = append([]int64(nil), sizes.Counts...)
+		stats.Interfaces = append(stats.Interfaces, &report)
+
+		if sizes.Packets >= d.config.MinPackets {
+			events = append(events, d.spikes(sizes, now)...)
+		}
+
+		// Start the next interval, keeping the baseline
+		*sizes = InterfaceSizes{Interface: name, Counts: make([]int64, len(SizeBounds)+1), usual: sizes.usual, warm: sizes.warm}
+	}
+	if stats != nil {
+		sort.Slice(stats.Interfaces, func(i, j int) bool {
+			return stats.Interfaces[i].Interface < stats.Interfaces[j].Interface
+		})
+	}
+	for key, at := range d.alerted {
+		if now.Sub(at) > d.config.Cooldown {
+			delete(d.alerted, key)
+		}
+	}
+	return stats, events
+}
+
+// spikes compares an interval's shares with the interface's usual ones, then folds them in
+func (d *SizeAnomalyDetector) spikes(sizes *InterfaceSizes, now time.Time) []*SizeAnomalyEvent {
+	total := float64(sizes.Packets)
+	current := sizeShares{
+		tiny:    float64(sizes.Tiny) / total,
+		maxSize: float64(sizes.MaxSize) / total,
+		jumbo:   float64(sizes.Jumbo) / total,
+	}
+
+	var events []*SizeAnomalyEvent
+	if sizes.warm {
+		for _, class := range []struct {
+			kind                string
+			count               int64
+			share, usual, floor float64
+		}{
+			{AnomalyTinyPackets, sizes.Tiny, current.tiny, sizes.usual.tiny, d.config.TinyFloor},
+			{AnomalyMaxSizePacket, sizes.MaxSize, current.maxSize, sizes.usual.maxSize, d.config.MaxSizeFloor},
+			{AnomalyJumboFrames, sizes.Jumbo, current.jumbo, sizes.usual.jumbo, d.config.JumboFloor},
+		} {
+			if class.share < class.floor || class.share < class.usual*d.config.SpikeFactor {
+				continue
+			}
+			if !d.shouldAlert(sizes.Interface+":"+class.kind, now) {
+				continue
+			}
+			events = append(events, &SizeAnomalyEvent{
+				Type:      "size_anomaly",
+				Kind:      class.kind,
+				Timestamp: now.UnixMilli(),
+				Interface: sizes.Interface,
+				Count:     class.count,
+				Packets:   sizes.Packets,
+				Fraction:  class.share,
+				Baseline:  class.usual,
+			})
+		}
+	}
+
+	if !sizes.warm {
+		sizes.usual = current
+		sizes.warm = true
+	} else {
+		w := d.config.BaselineWeight
+		sizes.usual.tiny += w * (current.tiny - sizes.usual.tiny)
+		sizes.usual.maxSize += w * (current.maxSize - sizes.usual.maxSize)
+		sizes.usual.jumbo += w * (current.jumbo - sizes.usual.jumbo)
+	}
+	return events
+}
+
+func (d *SizeAnomalyDetector) shouldAlert(key string, now time.Time) bool {
+	if at, ok := d.alerted[key]; ok && now.Sub(at) < d.config.Cooldown {
+		return false
+	}
+	d.alerted[key] = now
+	return true
+}
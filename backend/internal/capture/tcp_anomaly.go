@@ -0,0 +1,207 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TCP anomaly kinds reported in tcp_anomaly events
+const (
+	AnomalyRSTStorm           = "rst_storm"
+	AnomalyHighRetransmission = "high_retransmission"
+)
+
+// TCPAnomalyConfig holds thresholds for RST storm and retransmission detection
+type TCPAnomalyConfig struct {
+	RSTStormThreshold int           // RSTs from one host within a report interval
+	RetransMinPackets int64         // ignore flows smaller than this
+	RetransRateAlert  float64       // fraction of a flow's packets that were retransmitted
+	Cooldown          time.Duration // minimum gap between repeat alerts for the same host/flow
+	TopN              int
+}
+
+// DefaultTCPAnomalyConfig returns thresholds tuned for a conference network
+func DefaultTCPAnomalyConfig() TCPAnomalyConfig {
+	return TCPAnomalyConfig{
+		RSTStormThreshold: 50,
+		RetransMinPackets: 50,
+		RetransRateAlert:  0.05,
+		Cooldown:          time.Minute,
+		TopN:              10,
+	}
+}
+
+// HostCount is an IP with an associated count
+type HostCount struct {
+	IP    string `json:"ip"`
+	Count int    `json:"count"`
+}
+
+// TCPMetrics is the periodic tcp_stats message
+type TCPMetrics struct {
+	Type              string       `json:"type"`
+	Timestamp         int64        `json:"timestamp"`
+	IntervalMs        int64        `json:"interval_ms"`
+	ActiveConns       int          `json:"active_conns"`
+	Packets           uint64       `json:"packets"`
+	Retransmits       uint64       `json:"retransmits"`
+	RetransmitRate    float64      `json:"retransmit_rate"`
+	Resets            int          `json:"resets"`
+	ResetsPerSec      float64      `json:"resets_per_sec"`
+	TopResetSources   []HostCount  `json:"top_reset_sources"`
+	TopRetransmitters []FlowHealth `json:"top_retransmit_flows"`
+}
+
+// ToJSON converts TCP metrics to JSON
+func (m *TCPMetrics) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// TCPAnomalyEvent flags an RST storm or a flow with a high retransmission rate
+type TCPAnomalyEvent struct {
+	Type      string      `json:"type"` // always "tcp_anomaly"
+	Kind      string      `json:"kind"`
+	Timestamp int64       `json:"timestamp"`
+	IP        string      `json:"ip,omitempty"`
+	Count     int         `json:"count,omitempty"`
+	Rate      float64     `json:"rate"`
+	Flow      *FlowHealth `json:"flow,omitempty"`
+}
+
+// ToJSON converts an anomaly event to JSON
+func (e *TCPAnomalyEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// TCPAnomalyDetector counts RSTs per source and turns ConnTracker flow health into metrics and alerts
+type TCPAnomalyDetector struct {
+	mu          sync.Mutex
+	config      TCPAnomalyConfig
+	conns       *ConnTracker
+	resets      map[string]int
+	resetTotal  int
+	lastReport  time.Time
+	lastPackets uint64
+	lastRetrans uint64
+	alerted     map[string]time.Time
+}
+
+// NewTCPAnomalyDetector creates a detector reading per-flow health from conns
+func NewTCPAnomalyDetector(config TCPAnomalyConfig, conns *ConnTracker) *TCPAnomalyDetector {
+	return &TCPAnomalyDetector{
+		config:     config,
+		conns:      conns,
+		resets:     make(map[string]int),
+		lastReport: time.Now(),
+		alerted:    make(map[string]time.Time),
+	}
+}
+
+// Observe counts RST packets by sender
+func (d *TCPAnomalyDetector) Observe(p *Packet) {
+	if p.Protocol != ProtocolTCP || strings.IndexByte(p.TCPFlags, 'R') < 0 {
+		return
+	}
+	d.mu.Lock()
+	d.resets[p.Src]++
+	d.resetTotal++
+	d.mu.Unlock()
+}
+
+// Report closes the current interval, returning metrics plus any new anomaly events
+func (d *TCPAnomalyDetector) Report() (*TCPMetrics, []*TCPAnomalyEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	interval := now.Sub(d.lastReport)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	packets, retrans := d.conns.Totals()
+
+	metrics := &TCPMetrics{
+		Type:        "tcp_stats",
+		Timestamp:   now.UnixMilli(),
+		IntervalMs:  interval.Milliseconds(),
+		ActiveConns: d.conns.ActiveCount(),
+		Packets:     packets - d.lastPackets,
+		Retransmits: retrans - d.lastRetrans,
+		Resets:      d.resetTotal,
+	}
+	if metrics.Packets > 0 {
+		metrics.RetransmitRate = float64(metrics.Retransmits) / float64(metrics.Packets)
+	}
+	metrics.ResetsPerSec = float64(d.resetTotal) / interval.Seconds()
+
+	var events []*TCPAnomalyEvent
+
+	// RST storms: a single host tearing down connections en masse (IPS resets, crashing service)
+	for ip, count := range d.resets {
+		metrics.TopResetSources = append(metrics.TopResetSources, HostCount{IP: ip, Count: count})
+		if count >= d.config.RSTStormThreshold && d.shouldAlert("rst:"+ip, now) {
+			events = append(events, &TCPAnomalyEvent{
+				Type:      "tcp_anomaly",
+				Kind:      AnomalyRSTStorm,
+				Timestamp: now.UnixMilli(),
+				IP:        ip,
+				Count:     count,
+				Rate:      float64(count) / interval.Seconds(),
+			})
+		}
+	}
+	sort.Slice(metrics.TopResetSources, func(i, j int) bool {
+		return metrics.TopResetSources[i].Count > metrics.TopResetSources[j].Count
+	})
+	if len(metrics.TopResetSources) > d.config.TopN {
+		metrics.TopResetSources = metrics.TopResetSources[:d.config.TopN]
+	}
+
+	// Lossy flows
+	flows := d.conns.FlowHealth(d.config.RetransMinPackets)
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].RetransmitRate > flows[j].RetransmitRate
+	})
+	for i := range flows {
+		flow := flows[i]
+		if flow.RetransmitRate >= d.config.RetransRateAlert && d.shouldAlert("retrans:"+flow.ID, now) {
+			events = append(events, &TCPAnomalyEvent{
+				Type:      "tcp_anomaly",
+				Kind:      AnomalyHighRetransmission,
+				Timestamp: now.UnixMilli(),
+				IP:        flow.Dst,
+				Rate:      flow.RetransmitRate,
+				Flow:      &flow,
+			})
+		}
+	}
+	if len(flows) > d.config.TopN {
+		flows = flows[:d.config.TopN]
+	}
+	metrics.TopRetransmitters = flows
+
+	// Start the next interval
+	d.resets = make(map[string]int)
+	d.resetTotal = 0
+	d.lastReport = now
+	d.lastPackets = packets
+	d.lastRetrans = retrans
+	for key, at := range d.alerted {
+		if now.Sub(at) > d.config.Cooldown {
+			delete(d.alerted, key)
+		}
+	}
+
+	return metrics, events
+}
+
+func (d *TCPAnomalyDetector) shouldAlert(key string, now time.Time) bool {
+	if at, ok := d.alerted[key]; ok && now.Sub(at) < d.config.Cooldown {
+		return false
+	}
+	d.alerted[key] = now
+	return true
+}
@@ -0,0 +1,177 @@
+package capture
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bounds of the topology: devices remembered at once, and how long an announcement holds when it
+// didn't say
+const (
+	maxTopologyDevices = 4096
+	defaultNeighborTTL = 2 * time.Minute
+)
+
+// TopologyDevice is a switch, router, access point or phone that announced itself over LLDP or CDP
+type TopologyDevice struct {
+	ID            string    `json:"id"` // LLDP chassis ID or CDP device ID
+	Name          string    `json:"name,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Capabilities  []string  `json:"capabilities,omitempty"`   // "bridge", "router", "wlan_ap", "phone", ...
+	MgmtAddresses []string  `json:"mgmt_addresses,omitempty"` // graph nodes with these IPs are this device
+	MACs          []string  `json:"macs"`                     // the ports' MACs the announcements came from
+	Protocols     []string  `json:"protocols"`                // lldp, cdp or both
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// TopologyLink is one neighbor relationship: a device's port, heard announcing itself on a
+// capture interface, so that port is what the interface is plugged into (or mirrors)
+type TopologyLink struct {
+	Device          string    `json:"device"` // TopologyDevice.ID
+	Port            string    `json:"port"`
+	PortDescription string    `json:"port_description,omitempty"`
+	NativeVLAN      int       `json:"native_vlan,omitempty"`
+	Interface       string    `json:"interface"` // the capture interface the announcement was heard on
+	Protocol        string    `json:"protocol"`
+	LastSeen        time.Time `json:"last_seen"`
+	Expires         time.Time `json:"expires"` // when the announcement's TTL runs out
+}
+
+// TopologySnapshot is the L2 skeleton discovered so far (/api/topology)
+type TopologySnapshot struct {
+	Devices []TopologyDevice `json:"devices"`
+	Links   []TopologyLink   `json:"links"`
+}
+
+type topologyLinkKey struct {
+	device, port, iface string
+}
+
+// Topology keeps the LLDP and CDP neighbors heard on the captured interfaces. Links are dropped
+// when their announcement's TTL runs out, and devices along with their last link. Safe for
+// concurrent use.
+type Topology struct {
+	mu      sync.Mutex
+	devices map[string]*TopologyDevice
+	links   map[topologyLinkKey]*TopologyLink
+}
+
+// NewTopology creates an empty topology
+func NewTopology() *Topology {
+	return &Topology{
+		devices: make(map[string]*TopologyDevice),
+		links:   make(map[topologyLinkKey]*TopologyLink),
+	}
+}
+
+// Observe records an announcement from mac, heard on iface. It reports whether the device is
+// new.
+func (t *Topology) Observe(n *Neighbor, mac, iface string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	device, known := t.devices[n.ChassisID]
+	if !known {
+		if len(t.devices) >= maxTopologyDevices {
+			t.expire(now)
+			if len(t.devices) >= maxTopologyDevices {
+				return false
+			}
+		}
+		device = &TopologyDevice{ID: n.ChassisID, MACs: []string{}, Protocols: []string{}, FirstSeen: now}
+		t.devices[n.ChassisID] = device
+	}
+	device.LastSeen = now
+	if n.SystemName != "" {
+		device.Name = n.SystemName
+	}
+	if n.Description != "" {
+		device.Description = n.Description
+	}
+	if len(n.Capabilities) > 0 {
+		device.Capabilities = n.Capabilities
+	}
+	if len(n.MgmtAddresses) > 0 {
+		device.MgmtAddresses = n.MgmtAddresses
+	}
+	device.MACs = addString(device.MACs, mac)
+	device.Protocols = addString(device.Protocols, n.Protocol)
+
+	ttl := n.TTL
+	if ttl <= 0 {
+		ttl = defaultNeighborTTL
+	}
+	key := topologyLinkKey{device: n.ChassisID, port: n.PortID, iface: iface}
+	link, ok := t.links[key]
+	if !ok {
+		link = &TopologyLink{Device: n.ChassisID, Port: n.PortID, Interface: iface}
+		t.links[key] = link
+	}
+	link.PortDescription, link.NativeVLAN, link.Protocol = n.PortDescription, n.NativeVLAN, n.Protocol
+	link.LastSeen, link.Expires = now, now.Add(ttl)
+	return !known
+}
+
+// Snapshot returns the devices and links whose announcements still hold, sorted by ID
+func (t *Topology) Snapshot(now time.Time) TopologySnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(now)
+
+	snapshot := TopologySnapshot{Devices: make([]TopologyDevice, 0, len(t.devices)), Links: make([]TopologyLink, 0, len(t.links))}
+	for _, device := range t.devices {
+		copied := *device
+		copied.MACs = append([]string(nil), device.MACs...)
+		copied.Protocols = append([]string(nil), device.Protocols...)
+		snapshot.Devices = append(snapshot.Devices, copied)
+	}
+	for _, link := range t.links {
+		snapshot.Links = append(snapshot.Links, *link)
+	}
+	sort.Slice(snapshot.Devices, func(i, j int) bool { return snapshot.Devices[i].ID < snapshot.Devices[j].ID })
+	sort.Slice(snapshot.Links, func(i, j int) bool {
+		a, b := snapshot.Links[i], snapshot.Links[j]
+		if a.Device != b.Device {
+			return a.Device < b.Device
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Interface < b.Interface
+	})
+	return snapshot
+}
+
+// expire drops links past their TTL, then devices left without a link; callers hold mu
+func (t *Topology) expire(now time.Time) {
+	linked := make(map[string]bool, len(t.devices))
+	for key, link := range t.links {
+		if now.After(link.Expires) {
+			delete(t.links, key)
+			continue
+		}
+		linked[link.Device] = true
+	}
+	for id := range t.devices {
+		if !linked[id] {
+			delete(t.devices, id)
+		}
+	}
+}
+
+// addString appends value to a short set kept as a sorted slice
+func addString(set []string, value string) []string {
+	if value == "" {
+		return set
+	}
+	i := sort.SearchStrings(set, value)
+	if i < len(set) && set[i] == value {
+		return set
+	}
+	set = append(set, "")
+	copy(set[i+1:], set[i:])
+	set[i] = value
+	return set
+}
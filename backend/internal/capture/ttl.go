@@ -0,0 +1,300 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// TTL anomaly kinds reported in ttl_anomaly events
+const (
+	AnomalyTTLChange   = "ttl_change"   // a source's packets arrive with a different TTL: spoofing or a route change
+	AnomalyLowTTL      = "low_ttl"      // a source keeps sending packets that are about to expire
+	AnomalyTraceroute  = "traceroute"   // low TTLs stepping through several values from one source
+	AnomalyRoutingLoop = "routing_loop" // the same packet seen again with a lower TTL
+)
+
+// TTLConfig holds the thresholds for TTL anomaly detection
+type TTLConfig struct {
+	ChangeMin   int           // hops a TTL must move from a source's usual one to count as a change
+	MinPackets  int           // packets at one TTL before it is a source's usual TTL
+	LowTTL      uint8         // packets arriving with at most this TTL are low (multicast is exempt)
+	LowTTLAlert int           // low-TTL packets from a source within a report interval
+	TraceSteps  int           // distinct low TTLs from a source that make a traceroute
+	LoopTTL     uint8         // packets at or below this TTL are remembered to spot loops
+	LoopAlert   int           // repeats of looping packets between a pair within a report interval
+	MaxSources  int           // sources whose usual TTL is remembered
+	SourceIdle  time.Duration // forget a source's TTL after this long without packets
+	Cooldown    time.Duration // minimum gap between repeat alerts for the same source and kind
+	TopN        int
+}
+
+// DefaultTTLConfig returns thresholds tuned for a conference network
+func DefaultTTLConfig() TTLConfig {
+	return TTLConfig{
+		ChangeMin:   3,
+		MinPackets:  20,
+		LowTTL:      5,
+		LowTTLAlert: 10,
+		TraceSteps:  3,
+		LoopTTL:     32,
+		LoopAlert:   5,
+		MaxSources:  100000,
+		SourceIdle:  10 * time.Minute,
+		Cooldown:    time.Minute,
+		TopN:        10,
+	}
+}
+
+// TTLStats is the periodic ttl_stats message
+type TTLStats struct {
+	Type             string      `json:"type"`
+	Timestamp        int64       `json:"timestamp"`
+	IntervalMs       int64       `json:"interval_ms"`
+	Sources          int         `json:"sources"` // sources with a known usual TTL
+	LowTTL           int64       `json:"low_ttl"` // low-TTL packets this interval
+	Changes          int64       `json:"changes"` // packets off their source's usual TTL
+	Loops            int64       `json:"loops"`   // packets seen again with a lower TTL
+	TopLowTTLSources []HostCount `json:"top_low_ttl_sources"`
+}
+
+// ToJSON converts TTL stats to JSON
+func (s *TTLStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// TTLAnomalyEvent flags a TTL change, low TTLs, a traceroute or a routing loop
+type TTLAnomalyEvent struct {
+	Type      string `json:"type"` // always "ttl_anomaly"
+	Kind      string `json:"kind"`
+	Timestamp int64  `json:"timestamp"`
+	IP        string `json:"ip"`            // the source
+	Dst       string `json:"dst,omitempty"` // routing_loop: the destination the packets loop towards
+	TTL       uint8  `json:"ttl,omitempty"` // ttl_change: the new TTL; low_ttl: the lowest seen
+	UsualTTL  uint8  `json:"usual_ttl,omitempty"`
+	Count     int    `json:"count"`
+}
+
+// ToJSON converts an anomaly event to JSON
+func (e *TTLAnomalyEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// ttlSource is what a source's TTLs usually look like
+type ttlSource struct {
+	usual      uint8
+	seen       int   // packets at the usual TTL, up to MinPackets
+	candidate  uint8 // a different TTL that may be taking over
+	candidates int
+	lastSeen   time.Time
+}
+
+// ttlCount is a source's packets of one kind within an interval
+type ttlCount struct {
+	count int
+	ttl   uint8  // the latest TTL off the usual one, or the lowest low TTL
+	usual uint8  // ttl_change only
+	steps uint64 // low TTLs only; bit n set: TTL n was seen
+}
+
+type loopKey struct {
+	src, dst string
+	id       uint16
+	size     int
+}
+
+// TTLTracker records the TTL each source's packets usually arrive with and flags departures
+// from it. It is not safe for concurrent use; each session's forwarder owns one.
+type TTLTracker struct {
+	config  TTLConfig
+	sources map[string]*ttlSource
+	changed map[string]*ttlCount
+	low     map[string]*ttlCount
+	seen    map[loopKey]uint8 // remembered low-TTL packets this interval
+	loops   map[[2]string]int
+	stats   TTLStats
+	last    time.Time
+	alerted map[string]time.Time
+}
+
+// NewTTLTracker creates an empty tracker
+func NewTTLTracker(config TTLConfig) *TTLTracker {
+	return &TTLTracker{
+		config:  config,
+		sources: make(map[string]*ttlSource),
+		changed: make(map[string]*ttlCount),
+		low:     make(map[string]*ttlCount),
+		seen:    make(map[loopKey]uint8),
+		loops:   make(map[[2]string]int),
+		last:    time.Now(),
+		alerted: make(map[string]time.Time),
+	}
+}
+
+// Observe records a packet's TTL. Packets without one (simulated, relayed or Zeek) are ignored.
+func (t *TTLTracker) Observe(p *Packet) {
+	if p.TTL == 0 {
+		return
+	}
+	t.observeSource(p)
+
+	// A loop passes the capture point again and again, one loop's worth of hops lower each time
+	if p.TTL <= t.config.LoopTTL && len(t.seen) < t.config.MaxSources {
+		key := loopKey{p.Src, p.Dst, p.IPID, p.Size}
+		if previous, ok := t.seen[key]; ok && p.TTL < previous {
+			t.stats.Loops++
+			t.loops[[2]string{p.Src, p.Dst}]++
+		}
+		t.seen[key] = p.TTL
+	}
+
+	// Link-local protocols (IGMP, mDNS, SSDP, ...) send to group addresses with TTLs of 1 to 4
+	if p.TTL <= t.config.LowTTL && p.Cast == "" {
+		t.stats.LowTTL++
+		low, ok := t.low[p.Src]
+		if !ok {
+			low = &ttlCount{ttl: p.TTL}
+			t.low[p.Src] = low
+		}
+		low.count++
+		if p.TTL < low.ttl {
+			low.ttl = p.TTL
+		}
+		low.steps |= 1 << p.TTL // LowTTL is far below 64
+	}
+}
+
+func (t *TTLTracker) observeSource(p *Packet) {
+	src, ok := t.sources[p.Src]
+	if !ok {
+		if len(t.sources) >= t.config.MaxSources {
+			return
+		}
+		src = &ttlSource{usual: p.TTL}
+		t.sources[p.Src] = src
+	}
+	src.lastSeen = time.Now()
+
+	if hops(p.TTL, src.usual) < t.config.ChangeMin {
+		src.candidates = 0
+		if src.seen < t.config.MinPackets {
+			src.seen++
+		}
+		return
+	}
+	if src.seen < t.config.MinPackets {
+		// Still learning the source: start over from this TTL
+		src.usual, src.seen = p.TTL, 1
+		return
+	}
+
+	t.stats.Changes++
+	change, ok := t.changed[p.Src]
+	if !ok {
+		change = &ttlCount{usual: src.usual}
+		t.changed[p.Src] = change
+	}
+	change.count++
+	change.ttl = p.TTL
+
+	if src.candidates == 0 || hops(p.TTL, src.candidate) >= t.config.ChangeMin {
+		src.candidate, src.candidates = p.TTL, 0
+	}
+	src.candidates++
+	if src.candidates >= t.config.MinPackets {
+		// Only the new TTL arrives now: the route changed
+		src.usual, src.candidates = src.candidate, 0
+	}
+}
+
+// Report closes the current interval, returning stats plus any new anomaly events
+func (t *TTLTracker) Report() (*TTLStats, []*TTLAnomalyEvent) {
+	now := time.Now()
+	var events []*TTLAnomalyEvent
+	alert := func(kind, ip string, event *TTLAnomalyEvent) {
+		if t.shouldAlert(kind+":"+ip, now) {
+			event.Type, event.Kind, event.Timestamp, event.IP = "ttl_anomaly", kind, now.UnixMilli(), ip
+			events = append(events, event)
+		}
+	}
+
+	for ip, change := range t.changed {
+		alert(AnomalyTTLChange, ip, &TTLAnomalyEvent{TTL: change.ttl, UsualTTL: change.usual, Count: change.count})
+	}
+	stats := t.stats
+	stats.TopLowTTLSources = make([]HostCount, 0, len(t.low))
+	for ip, low := range t.low {
+		stats.TopLowTTLSources = append(stats.TopLowTTLSources, HostCount{IP: ip, Count: low.count})
+		switch {
+		case bits(low.steps) >= t.config.TraceSteps:
+			alert(AnomalyTraceroute, ip, &TTLAnomalyEvent{TTL: low.ttl, Count: low.count})
+		case low.count >= t.config.LowTTLAlert:
+			alert(AnomalyLowTTL, ip, &TTLAnomalyEvent{TTL: low.ttl, Count: low.count})
+		}
+	}
+	for pair, count := range t.loops {
+		if count >= t.config.LoopAlert {
+			alert(AnomalyRoutingLoop, pair[0], &TTLAnomalyEvent{Dst: pair[1], Count: count})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Kind != events[j].Kind {
+			return events[i].Kind < events[j].Kind
+		}
+		return events[i].IP < events[j].IP
+	})
+	sort.Slice(stats.TopLowTTLSources, func(i, j int) bool {
+		return stats.TopLowTTLSources[i].Count > stats.TopLowTTLSources[j].Count
+	})
+	if len(stats.TopLowTTLSources) > t.config.TopN {
+		stats.TopLowTTLSources = stats.TopLowTTLSources[:t.config.TopN]
+	}
+
+	// Start the next interval
+	for ip, src := range t.sources {
+		if now.Sub(src.lastSeen) > t.config.SourceIdle {
+			delete(t.sources, ip)
+		}
+	}
+	for key, at := range t.alerted {
+		if now.Sub(at) > t.config.Cooldown {
+			delete(t.alerted, key)
+		}
+	}
+	stats.Type = "ttl_stats"
+	stats.Timestamp = now.UnixMilli()
+	stats.IntervalMs = now.Sub(t.last).Milliseconds()
+	stats.Sources = len(t.sources)
+	t.stats = TTLStats{}
+	t.changed = make(map[string]*ttlCount)
+	t.low = make(map[string]*ttlCount)
+	t.seen = make(map[loopKey]uint8)
+	t.loops = make(map[[2]string]int)
+	t.last = now
+	return &stats, events
+}
+
+func (t *TTLTracker) shouldAlert(key string, now time.Time) bool {
+	if at, ok := t.alerted[key]; ok && now.Sub(at) < t.config.Cooldown {
+		return false
+	}
+	t.alerted[key] = now
+	return true
+}
+
+// hops is how far apart two TTLs are
+func hops(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// bits counts the set bits of a TTL step mask
+func bits(mask uint64) int {
+	n := 0
+	for ; mask != 0; mask &= mask - 1 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,153 @@
+package capture
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Encrypted tunnel protocols packets are tagged with
+const (
+	TunnelWireGuard = "wireguard"
+	TunnelIPsec     = "ipsec"
+	TunnelOpenVPN   = "openvpn"
+)
+
+// tunnelPorts are the standard ports of the tunnel protocols, for packets whose first bytes
+// weren't seen (simulated, relayed or mid-flow)
+var tunnelPorts = map[string]map[int]string{
+	ProtocolUDP: {51820: TunnelWireGuard, 500: TunnelIPsec, 4500: TunnelIPsec, 1194: TunnelOpenVPN},
+	ProtocolTCP: {1194: TunnelOpenVPN},
+}
+
+// TunnelSignature recognizes a tunnel from one decoded packet: ESP and AH by IP protocol,
+// WireGuard by its fixed message layouts and OpenVPN by its session reset opcodes. It only looks
+// at the handful of header bytes these protocols send in the clear; an empty result means no
+// tunnel was recognized.
+func TunnelSignature(ipProtocol layers.IPProtocol, transport string, payload []byte) string {
+	switch {
+	case ipProtocol == layers.IPProtocolESP || ipProtocol == layers.IPProtocolAH:
+		return TunnelIPsec
+	case transport == ProtocolUDP && wireGuardMessage(payload):
+		return TunnelWireGuard
+	case transport == ProtocolUDP && openVPNReset(payload):
+		return TunnelOpenVPN
+	case transport == ProtocolTCP && len(payload) > 2 &&
+		int(binary.BigEndian.Uint16(payload)) == len(payload)-2 && openVPNReset(payload[2:]):
+		return TunnelOpenVPN // TCP mode prefixes each packet with its length
+	}
+	return ""
+}
+
+// wireGuardMessage matches the four WireGuard message types: a type byte, three reserved zero
+// bytes and a length fixed by the type (transport data is padded to 16 bytes)
+func wireGuardMessage(payload []byte) bool {
+	if len(payload) < 4 || payload[1] != 0 || payload[2] != 0 || payload[3] != 0 {
+		return false
+	}
+	switch payload[0] {
+	case 1: // handshake initiation
+		return len(payload) == 148
+	case 2: // handshake response
+		return len(payload) == 92
+	case 3: // cookie reply
+		return len(payload) == 64
+	case 4: // transport data
+		return len(payload) >= 32 && len(payload)%16 == 0
+	}
+	return false
+}
+
+// openVPNReset matches an OpenVPN client's hard reset (v2 or v3), which opens every session
+func openVPNReset(payload []byte) bool {
+	if len(payload) < 14 || len(payload) > 128 {
+		return false
+	}
+	opcode, keyID := payload[0]>>3, payload[0]&0x07
+	return keyID == 0 && (opcode == 7 || opcode == 10)
+}
+
+// TunnelConfig holds the limits of tunnel flow tracking
+type TunnelConfig struct {
+	MaxFlows int           // tunnel flows remembered at once
+	FlowIdle time.Duration // forget a tunnel flow after this long without packets
+}
+
+// DefaultTunnelConfig returns limits for a busy event network
+func DefaultTunnelConfig() TunnelConfig {
+	return TunnelConfig{MaxFlows: 50000, FlowIdle: 2 * time.Minute}
+}
+
+type tunnelFlowKey struct {
+	a, b         string
+	aPort, bPort int
+	protocol     string
+}
+
+// tunnelFlowOf keys a packet's flow the same way in both directions
+func tunnelFlowOf(p *Packet) tunnelFlowKey {
+	if p.Src < p.Dst || (p.Src == p.Dst && p.SrcPort < p.DstPort) {
+		return tunnelFlowKey{p.Src, p.Dst, p.SrcPort, p.DstPort, p.Protocol}
+	}
+	return tunnelFlowKey{p.Dst, p.Src, p.DstPort, p.SrcPort, p.Protocol}
+}
+
+type tunnelFlow struct {
+	tunnel   string
+	lastSeen time.Time
+}
+
+// TunnelClassifier tags every packet of a flow once one of its packets was recognized as a
+// tunnel, and packets on the tunnels' standard ports. It is not safe for concurrent use; each
+// session's forwarder owns one.
+type TunnelClassifier struct {
+	config TunnelConfig
+	flows  map[tunnelFlowKey]*tunnelFlow
+}
+
+// NewTunnelClassifier creates a classifier with no known flows
+func NewTunnelClassifier(config TunnelConfig) *TunnelClassifier {
+	return &TunnelClassifier{config: config, flows: make(map[tunnelFlowKey]*tunnelFlow)}
+}
+
+// Classify returns the packet with its Tunnel tag set. A packet that needs a tag it doesn't have
+// is copied, since the capture may share it with other sessions.
+func (c *TunnelClassifier) Classify(p *Packet) *Packet {
+	tunnel := p.Tunnel
+	if tunnel == "" {
+		tunnel = tunnelPorts[p.Protocol][p.DstPort]
+	}
+	if tunnel == "" {
+		tunnel = tunnelPorts[p.Protocol][p.SrcPort]
+	}
+
+	key := tunnelFlowOf(p)
+	flow, known := c.flows[key]
+	switch {
+	case known:
+		flow.lastSeen = time.Now()
+		if tunnel == "" {
+			tunnel = flow.tunnel
+		}
+	case tunnel != "" && len(c.flows) < c.config.MaxFlows:
+		c.flows[key] = &tunnelFlow{tunnel: tunnel, lastSeen: time.Now()}
+	}
+
+	if tunnel == p.Tunnel {
+		return p
+	}
+	tagged := *p
+	tagged.Tunnel = tunnel
+	return &tagged
+}
+
+// Sweep forgets tunnel flows that went idle
+func (c *TunnelClassifier) Sweep() {
+	now := time.Now()
+	for key, flow := range c.flows {
+		if now.Sub(flow.lastSeen) > c.config.FlowIdle {
+			delete(c.flows, key)
+		}
+	}
+}
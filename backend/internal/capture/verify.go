@@ -0,0 +1,189 @@
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// pcapng block types that carry a packet: enhanced, simple and the obsolete packet block
+const (
+	pcapngEnhancedPacket = 6
+	pcapngSimplePacket   = 3
+	pcapngObsoletePacket = 2
+)
+
+// maxRecordLength bounds a record or block; anything longer is a damaged length field
+const maxRecordLength = 16 << 20
+
+// CaptureCheck is what walking a capture file's records found
+type CaptureCheck struct {
+	Packets   int64 // complete packet records
+	ValidSize int64 // bytes up to the end of the last complete record (of the decompressed stream, for .gz files)
+	Truncated bool  // the file ends partway through a record, as after a crash mid-write
+}
+
+// VerifyCaptureFile reads a pcap or pcapng file, gzip-compressed or not, record by record without
+// decoding packets. A file cut off mid-record reports Truncated; a damaged header, record length
+// or gzip stream is an error.
+func VerifyCaptureFile(path string) (CaptureCheck, error) {
+	var check CaptureCheck
+	file, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return check, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	w := &recordWalker{r: bufio.NewReaderSize(r, 64*1024)}
+	magic, err := w.r.Peek(4)
+	if len(magic) < 4 {
+		if err == io.EOF {
+			return check, fmt.Errorf("no file header")
+		}
+		return check, err
+	}
+	switch {
+	case binary.LittleEndian.Uint32(magic) == pcapngMagic:
+		err = verifyPcapng(w, &check)
+	case isPcapMagic(binary.LittleEndian.Uint32(magic)):
+		err = verifyPcap(w, binary.LittleEndian, &check)
+	case isPcapMagic(binary.BigEndian.Uint32(magic)):
+		err = verifyPcap(w, binary.BigEndian, &check)
+	default:
+		return check, fmt.Errorf("not a pcap or pcapng file")
+	}
+	if cutShort(err) && check.ValidSize > 0 {
+		check.Truncated = true
+		return check, nil
+	}
+	if cutShort(err) {
+		return check, fmt.Errorf("file header cut short")
+	}
+	return check, err
+}
+
+// isPcapMagic matches the microsecond and nanosecond pcap magic numbers
+func isPcapMagic(magic uint32) bool {
+	return magic == 0xa1b2c3d4 || magic == 0xa1b23c4d
+}
+
+// cutShort reports whether a read ran out of file
+func cutShort(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// recordWalker reads through a capture file, keeping count of the bytes consumed
+type recordWalker struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (w *recordWalker) read(buf []byte) error {
+	n, err := io.ReadFull(w.r, buf)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *recordWalker) skip(n int) error {
+	skipped, err := w.r.Discard(n)
+	w.offset += int64(skipped)
+	return err
+}
+
+// verifyPcap walks a classic pcap file: a 24-byte header, then records of a 16-byte header and
+// the captured bytes. A clean end of file between records returns nil.
+func verifyPcap(w *recordWalker, order binary.ByteOrder, check *CaptureCheck) error {
+	header := make([]byte, 24)
+	if err := w.read(header); err != nil {
+		return err
+	}
+	check.ValidSize = w.offset
+
+	record := make([]byte, 16)
+	for {
+		if err := w.read(record); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		length := order.Uint32(record[8:12])
+		if length > maxRecordLength {
+			return fmt.Errorf("record at byte %d claims %d bytes", check.ValidSize, length)
+		}
+		if err := w.skip(int(length)); err != nil {
+			return err
+		}
+		check.Packets++
+		check.ValidSize = w.offset
+	}
+}
+
+// verifyPcapng walks a pcapng file's blocks, each framed by its length at both ends. Every section
+// header block sets the byte order of the blocks after it.
+func verifyPcapng(w *recordWalker, check *CaptureCheck) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	head := make([]byte, 8)
+	trailer := make([]byte, 4)
+	for {
+		start := w.offset
+		if err := w.read(head); err != nil {
+			if err == io.EOF && start > 0 {
+				return nil
+			}
+			return err
+		}
+
+		consumed := 8
+		if binary.LittleEndian.Uint32(head) == pcapngMagic {
+			byteOrder := make([]byte, 4)
+			if err := w.read(byteOrder); err != nil {
+				return err
+			}
+			switch {
+			case binary.LittleEndian.Uint32(byteOrder) == 0x1A2B3C4D:
+				order = binary.LittleEndian
+			case binary.BigEndian.Uint32(byteOrder) == 0x1A2B3C4D:
+				order = binary.BigEndian
+			default:
+				return fmt.Errorf("section header at byte %d has no byte-order magic", start)
+			}
+			consumed += 4
+		}
+
+		length := order.Uint32(head[4:8])
+		if length < uint32(consumed)+4 || length%4 != 0 || length > maxRecordLength {
+			return fmt.Errorf("block at byte %d claims %d bytes", start, length)
+		}
+		if err := w.skip(int(length) - consumed - 4); err != nil {
+			return err
+		}
+		if err := w.read(trailer); err != nil {
+			return err
+		}
+		if order.Uint32(trailer) != length {
+			return fmt.Errorf("block at byte %d ends with a mismatched length", start)
+		}
+
+		switch order.Uint32(head) {
+		case pcapngEnhancedPacket, pcapngSimplePacket, pcapngObsoletePacket:
+			check.Packets++
+		}
+		check.ValidSize = w.offset
+	}
+}
@@ -0,0 +1,258 @@
+package capture
+
+import (
+	"container/heap"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/gopacket"
+)
+
+// defaultScanWorkers is how many capture files a time window reads at once when
+// TimeWindowConfig.ScanWorkers is unset
+const defaultScanWorkers = 4
+
+// streamBuffer is how many decoded packets each file reads ahead of playback
+const streamBuffer = 256
+
+// scannedFile is a capture file in a time window, with what is known of its span
+type scannedFile struct {
+	path  string
+	start int64 // Unix ms of its first packet
+	end   int64 // Unix ms of its last packet, when the archive index knows it; else 0
+}
+
+// scanFiles finds where each file in the sequence starts, from the archive index or else from its
+// first packet, reading up to the worker limit of files at once. Files that fail to open, hold no
+// packets or start after the window are left out, and the rest are ordered by start so playback
+// can merge them.
+func (twp *TimeWindowProcessor) scanFiles() {
+	scanned := make([]scannedFile, len(twp.fileSequence))
+	found := make([]bool, len(twp.fileSequence))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < twp.workers && w < len(twp.fileSequence); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scanned[i], found[i] = twp.scanFile(twp.fileSequence[i])
+			}
+		}()
+	}
+	for i := range twp.fileSequence {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	twp.files = twp.files[:0]
+	for i, file := range scanned {
+		if found[i] && file.start <= twp.endTime.UnixMilli() {
+			twp.files = append(twp.files, file)
+		}
+	}
+	sort.SliceStable(twp.files, func(i, j int) bool { return twp.files[i].start < twp.files[j].start })
+	twp.fileSequence = twp.fileSequence[:0]
+	for _, file := range twp.files {
+		twp.fileSequence = append(twp.fileSequence, file.path)
+	}
+}
+
+// scanFile returns a file's span from the archive index, or its start from its first packet
+func (twp *TimeWindowProcessor) scanFile(path string) (scannedFile, bool) {
+	if twp.index != nil {
+		if f, ok := twp.index.File(path); ok {
+			return scannedFile{path: path, start: f.StartTime.UnixMilli(), end: f.EndTime.UnixMilli()}, f.PacketCount > 0
+		}
+	}
+	handle, err := twp.store.OpenCapture(path)
+	if err != nil {
+		log.Printf("⚠️ Leaving %s out of the time window: %v", filepath.Base(path), err)
+		return scannedFile{}, false
+	}
+	defer handle.Close()
+	_, ci, err := handle.ReadPacketData()
+	if err != nil {
+		return scannedFile{}, false
+	}
+	return scannedFile{path: path, start: ci.Timestamp.UnixMilli()}, true
+}
+
+// fileStream reads one capture file on its own goroutine, passing over packets before the window
+// or seek target and decoding the rest ahead of playback
+type fileStream struct {
+	file    scannedFile
+	packets chan *Packet
+	stop    chan struct{}
+	err     error // why reading ended before the end of the file; set before packets closes
+	head    *Packet
+}
+
+func startFileStream(store ArchiveStore, file scannedFile, from, end int64) *fileStream {
+	s := &fileStream{
+		file:    file,
+		packets: make(chan *Packet, streamBuffer),
+		stop:    make(chan struct{}),
+	}
+	go s.read(store, from, end)
+	return s
+}
+
+func (s *fileStream) read(store ArchiveStore, from, end int64) {
+	defer close(s.packets)
+	log.Printf("📂 Opening file: %s", filepath.Base(s.file.path))
+	handle, err := store.OpenCapture(s.file.path)
+	if err != nil {
+		s.err = err
+		return
+	}
+	defer handle.Close()
+
+	var skipped int
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return
+		}
+
+		// Packets before the window or a seek target are passed over without decoding
+		timestamp := ci.Timestamp.UnixMilli()
+		if timestamp < from {
+			if skipped++; skipped%1024 == 0 {
+				select {
+				case <-s.stop:
+					return
+				default:
+				}
+			}
+			continue
+		}
+		if timestamp > end {
+			return
+		}
+
+		replayPacket := decodePacket(gopacket.NewPacket(data, handle.LinkType(), gopacket.Default))
+		if replayPacket == nil {
+			continue // Skip non-IPv4 packets
+		}
+		// Keep original timestamp
+		replayPacket.Size = len(data)
+		replayPacket.Timestamp = timestamp
+		replayPacket.Source = "time_window"
+		replayPacket.CaptureInfo = ci
+
+		select {
+		case s.packets <- replayPacket:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// windowMerge plays a time window's files as one stream in timestamp order. Files are read ahead
+// on their own goroutines, up to the worker limit, and each joins the merge once playback reaches
+// its first packet, so files from several sensors that overlap interleave.
+type windowMerge struct {
+	store   ArchiveStore
+	files   []scannedFile // not yet started, in order of start
+	from    int64
+	end     int64
+	workers int
+	pending []*fileStream // started, waiting for playback to reach them
+	active  streamHeap    // merging, by the timestamp of each one's next packet
+}
+
+func newWindowMerge(store ArchiveStore, files []scannedFile, from, end int64, workers int) *windowMerge {
+	return &windowMerge{store: store, files: files, from: from, end: end, workers: workers}
+}
+
+// next returns the earliest packet left in the window and the file it came from, or false once
+// every file is done
+func (m *windowMerge) next() (*Packet, string, bool) {
+	for {
+		stream := m.upcoming()
+		if stream == nil || (len(m.active) > 0 && stream.file.start > m.active[0].head.Timestamp) {
+			break
+		}
+		m.pending = m.pending[1:]
+		if m.advance(stream) {
+			heap.Push(&m.active, stream)
+		}
+	}
+	if len(m.active) == 0 {
+		return nil, "", false
+	}
+
+	stream := m.active[0]
+	packet := stream.head
+	if m.advance(stream) {
+		heap.Fix(&m.active, 0)
+	} else {
+		heap.Pop(&m.active)
+	}
+	return packet, stream.file.path, true
+}
+
+// upcoming starts files ahead of playback up to the worker limit, and returns the next one to
+// join the merge
+func (m *windowMerge) upcoming() *fileStream {
+	for len(m.files) > 0 && (len(m.pending) == 0 || len(m.pending)+len(m.active) < m.workers) {
+		m.pending = append(m.pending, startFileStream(m.store, m.files[0], m.from, m.end))
+		m.files = m.files[1:]
+	}
+	if len(m.pending) == 0 {
+		return nil
+	}
+	return m.pending[0]
+}
+
+// advance takes a stream's next packet, reporting false when the stream is done. A damaged or
+// truncated file ends its stream early; what it held up to there has played.
+func (m *windowMerge) advance(stream *fileStream) bool {
+	packet, ok := <-stream.packets
+	if !ok {
+		if stream.err != nil {
+			log.Printf("⚠️ Skipping the rest of %s: %v", filepath.Base(stream.file.path), stream.err)
+		}
+		return false
+	}
+	stream.head = packet
+	return true
+}
+
+// close stops every file still being read
+func (m *windowMerge) close() {
+	for _, stream := range m.pending {
+		close(stream.stop)
+	}
+	for _, stream := range m.active {
+		close(stream.stop)
+	}
+	m.pending, m.active, m.files = nil, nil, nil
+}
+
+// streamHeap orders merging streams by their next packet, then by file start
+type streamHeap []*fileStream
+
+func (h streamHeap) Len() int { return len(h) }
+func (h streamHeap) Less(i, j int) bool {
+	if h[i].head.Timestamp != h[j].head.Timestamp {
+		return h[i].head.Timestamp < h[j].head.Timestamp
+	}
+	return h[i].file.start < h[j].file.start
+}
+func (h streamHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *streamHeap) Push(x interface{}) { *h = append(*h, x.(*fileStream)) }
+func (h *streamHeap) Pop() interface{} {
+	old := *h
+	stream := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return stream
+}
@@ -0,0 +1,200 @@
+package capture
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// WireReplayConfig describes a capture file to re-transmit onto an interface. At most one
+// of Speed, PPS and Mbps sets the pace; with none of them the file goes out as fast as
+// the interface takes it.
+type WireReplayConfig struct {
+	File      string
+	Interface string
+	Speed     float64 // multiple of the file's own timing
+	PPS       float64 // fixed packets per second
+	Mbps      float64 // fixed rate in megabits per second
+	Loops     int     // passes over the file; 0 repeats until stopped
+}
+
+// WireReplayStatus reports a wire replay's progress
+type WireReplayStatus struct {
+	File      string    `json:"file"`
+	Interface string    `json:"interface"`
+	Pace      string    `json:"pace"`
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	Pass      int       `json:"pass"`
+	Packets   uint64    `json:"packets"`
+	Bytes     uint64    `json:"bytes"`
+	Failed    uint64    `json:"failed"`
+	Error     string    `json:"error,omitempty"` // why the replay stopped early
+}
+
+// WireReplay re-transmits a capture file onto an interface, like tcpreplay, so the same
+// traffic can feed vibes and other sensors under test
+type WireReplay struct {
+	config WireReplayConfig
+	handle *pcap.Handle
+
+	mu     sync.Mutex
+	status WireReplayStatus
+}
+
+// NewWireReplay opens the interface for writing and checks that the file's link type matches it
+func NewWireReplay(config WireReplayConfig) (*WireReplay, error) {
+	paced := 0
+	for _, v := range []float64{config.Speed, config.PPS, config.Mbps} {
+		if v < 0 {
+			return nil, fmt.Errorf("speed, pps and mbps must not be negative")
+		}
+		if v > 0 {
+			paced++
+		}
+	}
+	if paced > 1 {
+		return nil, fmt.Errorf("choose one of speed, pps and mbps")
+	}
+
+	file, err := OpenCaptureFile(config.File)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %v", config.File, err)
+	}
+	fileLinkType := file.LinkType()
+	file.Close()
+
+	handle, err := pcap.OpenLive(config.Interface, 65535, false, pcap.BlockForever)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s for replay: %v", config.Interface, err)
+	}
+	if linkType := handle.LinkType(); linkType != fileLinkType {
+		handle.Close()
+		return nil, fmt.Errorf("%s has link type %v but %s is %v", config.File, fileLinkType, config.Interface, linkType)
+	}
+
+	r := &WireReplay{config: config, handle: handle}
+	r.status = WireReplayStatus{File: config.File, Interface: config.Interface, Pace: r.pace()}
+	return r, nil
+}
+
+func (r *WireReplay) pace() string {
+	switch {
+	case r.config.PPS > 0:
+		return fmt.Sprintf("%g packets/s", r.config.PPS)
+	case r.config.Mbps > 0:
+		return fmt.Sprintf("%g Mbit/s", r.config.Mbps)
+	case r.config.Speed > 0:
+		return fmt.Sprintf("%gx", r.config.Speed)
+	}
+	return "top speed"
+}
+
+// Status returns the replay's progress
+func (r *WireReplay) Status() WireReplayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Run transmits the file until its passes are done or stop is closed, then releases the interface
+func (r *WireReplay) Run(stop <-chan struct{}) error {
+	defer r.handle.Close()
+	r.mu.Lock()
+	r.status.Running, r.status.StartedAt = true, time.Now()
+	r.mu.Unlock()
+
+	var err error
+	for pass := 1; r.config.Loops == 0 || pass <= r.config.Loops; pass++ {
+		r.mu.Lock()
+		r.status.Pass = pass
+		r.mu.Unlock()
+		var stopped bool
+		if stopped, err = r.playOnce(stop); stopped || err != nil {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	r.status.Running = false
+	if err != nil {
+		r.status.Error = err.Error()
+	}
+	r.mu.Unlock()
+	return err
+}
+
+// playOnce sends the file once, sleeping until each packet's scheduled time; it reports
+// whether stop was closed
+func (r *WireReplay) playOnce(stop <-chan struct{}) (bool, error) {
+	file, err := OpenCaptureFile(r.config.File)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	start := time.Now()
+	var first time.Time
+	var sent, bytes float64
+	for {
+		select {
+		case <-stop:
+			return true, nil
+		default:
+		}
+
+		data, ci, err := file.ReadPacketData()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		// Packets are scheduled from the start of the pass, so pacing doesn't drift
+		var due time.Duration
+		switch {
+		case r.config.PPS > 0:
+			due = time.Duration(sent / r.config.PPS * float64(time.Second))
+		case r.config.Mbps > 0:
+			due = time.Duration(bytes * 8 / (r.config.Mbps * 1e6) * float64(time.Second))
+		case r.config.Speed > 0:
+			if first.IsZero() {
+				first = ci.Timestamp
+			}
+			due = time.Duration(float64(ci.Timestamp.Sub(first)) / r.config.Speed)
+		}
+		if wait := time.Until(start.Add(due)); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-stop:
+				timer.Stop()
+				return true, nil
+			case <-timer.C:
+			}
+		}
+
+		sent++
+		bytes += float64(len(data))
+		if err := r.handle.WritePacketData(data); err != nil {
+			r.mu.Lock()
+			failed := r.status.Failed
+			r.status.Failed++
+			r.mu.Unlock()
+			if failed == 0 {
+				log.Printf("⚠️ Wire replay on %s: %v", r.config.Interface, err)
+			}
+			continue
+		}
+		r.mu.Lock()
+		r.status.Packets++
+		r.status.Bytes += uint64(len(data))
+		r.mu.Unlock()
+	}
+}
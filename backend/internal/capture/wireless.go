@@ -0,0 +1,287 @@
+package capture
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// wirelessFrameTypes names the 802.11 management frames reported in wifi_stats
+var wirelessFrameTypes = map[layers.Dot11Type]string{
+	layers.Dot11TypeMgmtAssociationReq:    "association_request",
+	layers.Dot11TypeMgmtAssociationResp:   "association_response",
+	layers.Dot11TypeMgmtReassociationReq:  "reassociation_request",
+	layers.Dot11TypeMgmtReassociationResp: "reassociation_response",
+	layers.Dot11TypeMgmtProbeReq:          "probe_request",
+	layers.Dot11TypeMgmtProbeResp:         "probe_response",
+	layers.Dot11TypeMgmtBeacon:            "beacon",
+	layers.Dot11TypeMgmtATIM:              "atim",
+	layers.Dot11TypeMgmtDisassociation:    "disassociation",
+	layers.Dot11TypeMgmtAuthentication:    "authentication",
+	layers.Dot11TypeMgmtDeauthentication:  "deauthentication",
+	layers.Dot11TypeMgmtAction:            "action",
+	layers.Dot11TypeMgmtActionNoAck:       "action",
+}
+
+// WirelessFrame is what a monitor-mode capture reports of an 802.11 management frame
+type WirelessFrame struct {
+	FrameType string // "beacon", "probe_request", "deauthentication", ...
+	BSSID     string
+	SSID      string // empty for hidden networks and wildcard probes
+	Channel   int    // 0 when neither the frame nor the radio said
+	Frequency int    // MHz; 0 without a Radiotap header
+	RSSI      int    // dBm; 0 when the radio didn't report it
+}
+
+// decodeWireless turns an 802.11 management frame into a Packet between transmitter and
+// receiver MAC addresses, carrying its metadata in Wireless. It returns nil for anything else,
+// data frames included: their IPv4 payload is decoded like any other.
+func decodeWireless(packet gopacket.Packet) *Packet {
+	dot11Layer := packet.Layer(layers.LayerTypeDot11)
+	if dot11Layer == nil {
+		return nil
+	}
+	dot11, _ := dot11Layer.(*layers.Dot11)
+	if dot11.Type.MainType() != layers.Dot11TypeMgmt {
+		return nil
+	}
+	frameType, ok := wirelessFrameTypes[dot11.Type]
+	if !ok {
+		return nil
+	}
+
+	frame := &WirelessFrame{FrameType: frameType, BSSID: dot11.Address3.String()}
+	if radioLayer := packet.Layer(layers.LayerTypeRadioTap); radioLayer != nil {
+		radio, _ := radioLayer.(*layers.RadioTap)
+		if radio.Present.Channel() {
+			frame.Frequency = int(radio.ChannelFrequency)
+			frame.Channel = channelOf(frame.Frequency)
+		}
+		if radio.Present.DBMAntennaSignal() {
+			frame.RSSI = int(radio.DBMAntennaSignal)
+		}
+	}
+	for _, layer := range packet.Layers() {
+		element, ok := layer.(*layers.Dot11InformationElement)
+		if !ok {
+			continue
+		}
+		switch element.ID {
+		case layers.Dot11InformationElementIDSSID:
+			frame.SSID = string(element.Info)
+		case layers.Dot11InformationElementIDDSSet:
+			// The channel the network is on, which a radio hopping channels may not be
+			if len(element.Info) == 1 {
+				frame.Channel = int(element.Info[0])
+			}
+		}
+	}
+
+	p := NewPacket(dot11.Address2.String(), dot11.Address1.String(), 0, 0, len(packet.Data()), ProtocolOther)
+	p.Wireless = frame
+	p.Raw = packet.Data()
+	p.CaptureInfo = packet.Metadata().CaptureInfo
+	p.LinkType = linkTypeOf(packet)
+	return p
+}
+
+// channelOf converts a frequency to its 802.11 channel number (2.4, 5 and 6 GHz bands)
+func channelOf(mhz int) int {
+	switch {
+	case mhz == 2484:
+		return 14
+	case mhz >= 2412 && mhz <= 2472:
+		return (mhz - 2407) / 5
+	case mhz >= 5955 && mhz <= 7115:
+		return (mhz - 5950) / 5
+	case mhz >= 5000 && mhz <= 5900:
+		return (mhz - 5000) / 5
+	}
+	return 0
+}
+
+// WirelessConfig holds the limits of the wireless view
+type WirelessConfig struct {
+	MaxNetworks int           // networks (BSSIDs) remembered at once
+	NetworkIdle time.Duration // drop a network after this long without frames from it
+	TopN        int           // probed SSIDs listed per report
+}
+
+// DefaultWirelessConfig returns limits for a crowded event venue
+func DefaultWirelessConfig() WirelessConfig {
+	return WirelessConfig{MaxNetworks: 2000, NetworkIdle: 5 * time.Minute, TopN: 20}
+}
+
+// WirelessNetwork is one access point (BSSID) as heard by the monitor-mode radio
+type WirelessNetwork struct {
+	BSSID    string `json:"bssid"`
+	SSID     string `json:"ssid"` // empty for hidden networks
+	Channel  int    `json:"channel,omitempty"`
+	RSSI     int    `json:"rssi,omitempty"` // dBm, averaged over this interval's beacons and probe responses
+	Beacons  int64  `json:"beacons"`        // this interval
+	Frames   int64  `json:"frames"`         // management frames from or to the BSSID this interval
+	LastSeen int64  `json:"last_seen"`      // ms
+
+	rssiSum   int64
+	rssiCount int64
+	lastSeen  time.Time
+}
+
+// SSIDCount is how often an SSID was probed for
+type SSIDCount struct {
+	SSID  string `json:"ssid"`
+	Count int    `json:"count"`
+}
+
+// WirelessStats is the periodic wifi_stats message
+type WirelessStats struct {
+	Type       string             `json:"type"`
+	Timestamp  int64              `json:"timestamp"`
+	IntervalMs int64              `json:"interval_ms"`
+	Frames     int64              `json:"frames"`      // management frames this interval
+	FrameTypes map[string]int64   `json:"frame_types"` // by frame type
+	Stations   int                `json:"stations"`    // distinct transmitters other than access points
+	Networks   []*WirelessNetwork `json:"networks"`
+	Probes     []SSIDCount        `json:"probes"` // SSIDs clients probed for most
+}
+
+// ToJSON converts wireless stats to JSON
+func (s *WirelessStats) ToJSON() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// WirelessTracker builds the wireless view from management frames: the networks on the air,
+// their channels and signal strength, and what clients probe for. Station addresses are only
+// counted, never reported. It is not safe for concurrent use; each session's forwarder owns one.
+type WirelessTracker struct {
+	config     WirelessConfig
+	networks   map[string]*WirelessNetwork
+	stations   map[string]struct{}
+	probes     map[string]int
+	frameTypes map[string]int64
+	frames     int64
+	last       time.Time
+}
+
+// NewWirelessTracker creates an empty tracker
+func NewWirelessTracker(config WirelessConfig) *WirelessTracker {
+	return &WirelessTracker{
+		config:     config,
+		networks:   make(map[string]*WirelessNetwork),
+		stations:   make(map[string]struct{}),
+		probes:     make(map[string]int),
+		frameTypes: make(map[string]int64),
+		last:       time.Now(),
+	}
+}
+
+// Observe records a management frame; packets without 802.11 metadata are ignored
+func (t *WirelessTracker) Observe(p *Packet) {
+	frame := p.Wireless
+	if frame == nil {
+		return
+	}
+	t.frames++
+	t.frameTypes[frame.FrameType]++
+	if frame.FrameType == "probe_request" && frame.SSID != "" {
+		t.probes[frame.SSID]++
+	}
+	if p.Src != frame.BSSID {
+		t.stations[p.Src] = struct{}{}
+	}
+
+	network, ok := t.networks[frame.BSSID]
+	if !ok {
+		// Only access points announce networks; probes and the like merely mention a BSSID
+		if frame.FrameType != "beacon" && frame.FrameType != "probe_response" {
+			return
+		}
+		if len(t.networks) >= t.config.MaxNetworks {
+			return
+		}
+		network = &WirelessNetwork{BSSID: frame.BSSID}
+		t.networks[frame.BSSID] = network
+	}
+	network.Frames++
+	network.lastSeen = time.Now()
+	if frame.FrameType != "beacon" && frame.FrameType != "probe_response" {
+		return
+	}
+	if frame.FrameType == "beacon" {
+		network.Beacons++
+	}
+	if frame.SSID != "" {
+		network.SSID = frame.SSID
+	}
+	if frame.Channel != 0 {
+		network.Channel = frame.Channel
+	}
+	if frame.RSSI != 0 {
+		network.rssiSum += int64(frame.RSSI)
+		network.rssiCount++
+	}
+}
+
+// Report closes the current interval. It returns nil until the first management frame arrives,
+// so sessions that don't capture in monitor mode never get wifi_stats.
+func (t *WirelessTracker) Report() *WirelessStats {
+	now := time.Now()
+	interval := now.Sub(t.last)
+	t.last = now
+	if t.frames == 0 && len(t.networks) == 0 {
+		return nil
+	}
+
+	stats := &WirelessStats{
+		Type:       "wifi_stats",
+		Timestamp:  now.UnixMilli(),
+		IntervalMs: interval.Milliseconds(),
+		Frames:     t.frames,
+		FrameTypes: t.frameTypes,
+		Stations:   len(t.stations),
+		Networks:   make([]*WirelessNetwork, 0, len(t.networks)),
+		Probes:     make([]SSIDCount, 0, len(t.probes)),
+	}
+	for bssid, network := range t.networks {
+		if now.Sub(network.lastSeen) > t.config.NetworkIdle {
+			delete(t.networks, bssid)
+			continue
+		}
+		report := *network
+		report.LastSeen = network.lastSeen.UnixMilli()
+		if network.rssiCount > 0 {
+			report.RSSI = int(network.rssiSum / network.rssiCount)
+		}
+		stats.Networks = append(stats.Networks, &report)
+
+		// Start the next interval, keeping what the network is
+		network.Beacons, network.Frames, network.rssiSum, network.rssiCount = 0, 0, 0, 0
+	}
+	sort.Slice(stats.Networks, func(i, j int) bool {
+		if stats.Networks[i].SSID != stats.Networks[j].SSID {
+			return stats.Networks[i].SSID < stats.Networks[j].SSID
+		}
+		return stats.Networks[i].BSSID < stats.Networks[j].BSSID
+	})
+	for ssid, count := range t.probes {
+		stats.Probes = append(stats.Probes, SSIDCount{SSID: ssid, Count: count})
+	}
+	sort.Slice(stats.Probes, func(i, j int) bool {
+		if stats.Probes[i].Count != stats.Probes[j].Count {
+			return stats.Probes[i].Count > stats.Probes[j].Count
+		}
+		return stats.Probes[i].SSID < stats.Probes[j].SSID
+	})
+	if len(stats.Probes) > t.config.TopN {
+		stats.Probes = stats.Probes[:t.config.TopN]
+	}
+
+	t.frames = 0
+	t.frameTypes = make(map[string]int64)
+	t.stations = make(map[string]struct{})
+	t.probes = make(map[string]int)
+	return stats
+}
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// Config is the optional JSON configuration file passed with -config.
+// Command-line flags cover single-value settings; the file holds structured ones.
+type Config struct {
+	SubnetGroups  []SubnetGroup  `json:"subnet_groups"`
+	NodeGrouping  *NodeGrouping  `json:"node_grouping,omitempty"`
+	Retention     *Retention     `json:"retention,omitempty"`
+	Rooms         []Room         `json:"rooms,omitempty"`
+	Profiles      []Profile      `json:"profiles,omitempty"`
+	Webhooks      []Webhook      `json:"webhooks,omitempty"`
+	AlertPolicies []AlertPolicy  `json:"alert_policies,omitempty"`
+	NAT           *NAT           `json:"nat,omitempty"`
+	DHCP          *DHCP          `json:"dhcp,omitempty"`
+	DHCPGuard     *DHCPGuard     `json:"dhcp_guard,omitempty"`
+	ARP           *ARP           `json:"arp,omitempty"`
+	Devices       *Devices       `json:"devices,omitempty"`
+	NewEdges      *NewEdges      `json:"new_edges,omitempty"`
+	DarkSpace     []SubnetGroup  `json:"dark_space,omitempty"` // ranges that should never send: unallocated subnets, honeynets
+	RouterAdverts *RouterAdverts `json:"router_advertisements,omitempty"`
+	Exfiltration  *Exfiltration  `json:"exfiltration,omitempty"`
+	SNMP          *SNMP          `json:"snmp,omitempty"`
+	Probes        *Probes        `json:"probes,omitempty"`
+	HealthChecks  []HealthCheck  `json:"health_checks,omitempty"`
+	Schedules     []Schedule     `json:"schedules,omitempty"`
+}
+
+// SubnetGroup names a set of CIDRs for bandwidth accounting (e.g. "Attendee WiFi")
+type SubnetGroup struct {
+	Name  string   `json:"name"`
+	CIDRs []string `json:"cidrs"`
+}
+
+// NodeGrouping controls the src_group/dst_group cluster hints attached to streamed packets.
+// Grouping is off unless this section is present.
+type NodeGrouping struct {
+	Rules         []GroupRule `json:"rules"`
+	AutoPrefixV4  int         `json:"auto_prefix_v4"` // fallback grouping for unmatched IPv4 (default 24)
+	AutoPrefixV6  int         `json:"auto_prefix_v6"` // fallback grouping for unmatched IPv6 (default 64)
+	ExternalGroup string      `json:"external_group"` // if set, all public IPs share this group
+}
+
+// GroupRule assigns a CIDR to a named cluster
+type GroupRule struct {
+	CIDR  string `json:"cidr"`
+	Group string `json:"group"`
+}
+
+// NAT identifies clients behind a NAT gateway when capturing outside it: packets to and from
+// the pool's addresses are shown with the internal host the gateway's translation table maps
+// them to. NAT awareness is off unless this section is present.
+type NAT struct {
+	Pool    []string `json:"pool"`              // public addresses or CIDRs the gateway translates to
+	Source  string   `json:"source,omitempty"`  // "conntrack" (conntrack -L / -E output) or "asa" (Cisco ASA/FTD syslog); mappings can also be posted to /api/nat
+	File    string   `json:"file,omitempty"`    // file the source is followed in
+	Timeout Duration `json:"timeout,omitempty"` // a mapping not seen again for this long is dropped (default "10m")
+}
+
+// DHCP names nodes after the devices the DHCP server leased their addresses to. Leases come
+// from the server's leases file or are posted to /api/dhcp (DHCP snooping); lease naming is off
+// unless this section is present.
+type DHCP struct {
+	Source    string   `json:"source,omitempty"`     // "isc" (dhcpd.leases) or "dnsmasq" (dnsmasq.leases)
+	File      string   `json:"file,omitempty"`       // leases file, read again whenever it changes
+	LeaseTime Duration `json:"lease_time,omitempty"` // how long a posted lease without "expires" lasts (default "1h")
+}
+
+// DHCPGuard watches the DHCP traffic of live captures for servers that aren't the venue's and for
+// DISCOVER floods that starve the pool (dhcp_anomaly alerts). Alerting is off unless this section
+// is present.
+type DHCPGuard struct {
+	Servers      []string `json:"servers,omitempty"`                  // server identifiers allowed to answer; any when empty
+	MaxDiscovers int      `json:"max_discovers_per_minute,omitempty"` // per interface (default 600)
+	Cooldown     Duration `json:"cooldown,omitempty"`                 // between repeat alerts for the same server or interface (default "10m")
+}
+
+// ARP seeds the graph with every host a gateway's ARP and IPv6 neighbor tables know, before the
+// tap sees it send. The table is read from a dump the gateway writes or copies to a file, from a
+// command run every interval (such as ip neigh over ssh), or posted to /api/arp. Seeding is off
+// unless this section is present.
+type ARP struct {
+	Source   string   `json:"source,omitempty"`   // "linux" (ip neigh) or "cisco" (show ip arp, show ipv6 neighbors)
+	File     string   `json:"file,omitempty"`     // dump read again whenever it changes
+	Command  []string `json:"command,omitempty"`  // run every interval instead, e.g. ["ssh", "gw1", "ip", "neigh"]
+	Interval Duration `json:"interval,omitempty"` // how often the command runs (default "1m")
+	MaxAge   Duration `json:"max_age,omitempty"`  // forget a host missing from the table this long (default "30m")
+}
+
+// Devices watches subnets for hosts never seen before (new_device alerts). Every host seen
+// sending there is remembered in the -devices file; detection is off unless this section is
+// present.
+type Devices struct {
+	Subnets []string `json:"subnets"` // CIDRs to watch, e.g. the attendee and staff networks
+}
+
+// NewEdges learns which hosts talk to which services where a sensitive subnet is involved, and
+// watches for a host contacting a service it never has before (new_edge alerts). Every
+// connection is remembered in the -edges file; detection is off unless this section is present.
+type NewEdges struct {
+	Subnets []string `json:"subnets"`         // CIDRs of the sensitive hosts, e.g. the servers and management networks
+	Learn   Duration `json:"learn,omitempty"` // connections in this long from the first start are the baseline, without alerts (default "24h")
+}
+
+// RouterAdverts checks the IPv6 Router Advertisements heard on live Ethernet captures against the
+// venue's routers and prefixes (rogue_ra alerts). Alerting is off unless this section is present.
+type RouterAdverts struct {
+	Routers  []string `json:"routers,omitempty"`  // addresses allowed to advertise, usually link-local, e.g. "fe80::1"
+	MACs     []string `json:"macs,omitempty"`     // MACs allowed to advertise; a router matching either list is allowed
+	Prefixes []string `json:"prefixes,omitempty"` // CIDRs every advertised prefix must fall inside
+	Cooldown Duration `json:"cooldown,omitempty"` // between repeat alerts for the same router (default "10m")
+}
+
+// Exfiltration sums what each internal host sends to external addresses over a sliding window,
+// and alerts on hosts sending more than a set volume or far more than they usually do
+// (exfiltration alerts). Alerting is off unless this section is present.
+type Exfiltration struct {
+	Internal  []string `json:"internal,omitempty"`  // CIDRs of the hosts watched (default: the private ranges)
+	Window    Duration `json:"window,omitempty"`    // the sliding window, at least "10s" (default "5m")
+	MaxBytes  int64    `json:"max_bytes,omitempty"` // a host sending more in a window raises a volume alert; none when absent
+	Deviation float64  `json:"deviation,omitempty"` // a window this many times the host's usual raises a baseline alert (default 10)
+	MinBytes  int64    `json:"min_bytes,omitempty"` // baseline alerts need this many bytes in the window (default 100 MB)
+	Baseline  Duration `json:"baseline,omitempty"`  // how long a host's usual is averaged over, and watched for before it counts (default "1h", or 12 windows)
+	Cooldown  Duration `json:"cooldown,omitempty"`  // between repeat alerts for the same host (default "10m")
+}
+
+// SNMP polls venue switch ports over SNMPv2c, so uplink utilization is streamed alongside the
+// tap's own interface_stats. Polling is off unless this section is present.
+type SNMP struct {
+	Interval Duration     `json:"interval,omitempty"` // default "10s"
+	Timeout  Duration     `json:"timeout,omitempty"`  // per request, retried once (default "2s")
+	Ports    []SNMPTarget `json:"ports"`
+}
+
+// SNMPTarget is one switch port to poll
+type SNMPTarget struct {
+	Name      string `json:"name"`                 // shown on the dashboard, e.g. "core uplink"
+	Host      string `json:"host"`                 // switch address, with :port when not 161
+	Community string `json:"community,omitempty"`  // default "public"
+	IfIndex   int    `json:"if_index"`             // the port's ifIndex
+	SpeedMbps int    `json:"speed_mbps,omitempty"` // for utilization; read from ifHighSpeed when unset
+}
+
+// Probes lets operators ping or traceroute a node from the server with the probe command. Probing
+// is off unless this section is present, and needs the admin token when one is set.
+type Probes struct {
+	Count   int      `json:"count,omitempty"`    // echo requests per ping (default 4)
+	MaxHops int      `json:"max_hops,omitempty"` // how far a traceroute goes (default 30)
+	Timeout Duration `json:"timeout,omitempty"`  // a probe still running after this is stopped (default "60s")
+	Targets []string `json:"targets,omitempty"`  // CIDRs that may be probed; anywhere when empty
+}
+
+// HealthCheck actively checks a key server (registration, the Wi-Fi portal, DNS) so its node
+// shows whether the service is up, next to the traffic it draws
+type HealthCheck struct {
+	Name         string   `json:"name"`                    // e.g. "registration"
+	Host         string   `json:"host"`                    // the server's IP address: the node the status belongs to
+	Kind         string   `json:"kind"`                    // "http", "tcp" or "dns"
+	Port         int      `json:"port,omitempty"`          // tcp: required; http: default 80 (443 for https URLs); dns: default 53
+	URL          string   `json:"url,omitempty"`           // http: what to GET (default http://host:port/)
+	ExpectStatus int      `json:"expect_status,omitempty"` // http: the status that means up (default any 2xx or 3xx)
+	Query        string   `json:"query,omitempty"`         // dns: a name the server must resolve
+	Interval     Duration `json:"interval,omitempty"`      // default "30s"
+	Timeout      Duration `json:"timeout,omitempty"`       // default "5s"
+	FailAfter    int      `json:"fail_after,omitempty"`    // consecutive failures before the service is down (default 2)
+}
+
+// Retention bounds how much capture data is kept on disk. Files are deleted oldest first
+// when they exceed max_age or the directories together exceed max_total_gb.
+type Retention struct {
+	Dirs            []string `json:"dirs"`              // defaults to the -storage and -dumpcap-dir directories
+	MaxAge          Duration `json:"max_age"`           // e.g. "72h"; zero disables age-based deletion
+	MaxTotalGB      float64  `json:"max_total_gb"`      // zero disables size-based deletion
+	WarnFreePercent float64  `json:"warn_free_percent"` // warn when the filesystem has less free space (default 10)
+	CheckInterval   Duration `json:"check_interval"`    // default "5m"
+	CompressAfter   Duration `json:"compress_after"`    // gzip files older than this in place; zero disables
+}
+
+// Room presets the capture source and view of a named room (/ws?room=name). Query parameters on
+// the WebSocket URL still override the source; rooms that aren't listed use the command-line defaults.
+type Room struct {
+	Name       string   `json:"name"`
+	Interface  string   `json:"interface,omitempty"`
+	PCAP       string   `json:"pcap,omitempty"`
+	Speed      float64  `json:"speed,omitempty"`
+	ZeekTCP    string   `json:"zeek_tcp,omitempty"`    // "1" for the -zeek-tcp address, or a listen address
+	Relay      string   `json:"relay,omitempty"`       // "1" for packets from every relay agent, or one sensor ID
+	Scenario   string   `json:"scenario,omitempty"`    // simulate this scenario (see /api/scenarios)
+	Mix        string   `json:"mix,omitempty"`         // overlay this scenario ("1" for the default) on the room's capture
+	Preset     string   `json:"preset,omitempty"`      // applied when the room is first used
+	Profile    string   `json:"profile,omitempty"`     // capture profile active when the room is first used; overrides the source fields
+	SampleRate float64  `json:"sample_rate,omitempty"` // fraction of unpinned packets streamed (0-1]; overrides the preset's
+	Expose     []string `json:"expose,omitempty"`      // metadata streamed: "ports", "hostnames"; overrides -expose
+	Anonymize  *bool    `json:"anonymize,omitempty"`   // pseudonymize attendee addresses (public kiosks); overrides -anonymize
+	// Record the messages sent to the room to -streams from the moment it opens (see /ws/playback)
+	RecordStream bool `json:"record_stream,omitempty"`
+	// Show multicast and broadcast destinations as one node per group protocol; overrides -aggregate-multicast
+	AggregateMulticast *bool `json:"aggregate_multicast,omitempty"`
+	// Narrow live capture in the kernel to what the room's pins and filter select; overrides -watchlist-bpf
+	WatchlistBPF *bool `json:"watchlist_bpf,omitempty"`
+}
+
+// Profile is a named capture setup that a room can switch to at runtime (switch_profile), taking
+// every session in the room along without reconnecting. With no source set it simulates traffic.
+type Profile struct {
+	Name       string   `json:"name"`
+	Interface  string   `json:"interface,omitempty"`
+	PCAP       string   `json:"pcap,omitempty"`
+	Speed      float64  `json:"speed,omitempty"`
+	Scenario   string   `json:"scenario,omitempty"`
+	Filter     string   `json:"filter,omitempty"`      // BPF filter for live capture, on top of the IP filter
+	SampleRate float64  `json:"sample_rate,omitempty"` // fraction of unpinned packets streamed (0-1]
+	Enrich     []string `json:"enrich,omitempty"`      // enrichments to run: "node_info", "node_groups", "tunnels", "multicast"; all when absent
+}
+
+// Webhook posts alerts (tcp_anomaly, sensor_alert, storage_warning, ...) to a chat channel or
+// any HTTP endpoint
+type Webhook struct {
+	Name          string            `json:"name"`
+	URL           string            `json:"url"`
+	Format        string            `json:"format,omitempty"`          // "slack", "mattermost" or "json" (default)
+	Alerts        []string          `json:"alerts,omitempty"`          // alert types ("ttl_anomaly") or type:kind ("tcp_anomaly:syn_flood"); every alert when empty
+	Template      string            `json:"template,omitempty"`        // Go text/template for the message text
+	RatePerMinute int               `json:"rate_per_minute,omitempty"` // posts per minute at most (default 10)
+	Cooldown      Duration          `json:"cooldown,omitempty"`        // silence repeats of the same alert for this long (default "5m")
+	MinSeverity   string            `json:"min_severity,omitempty"`    // "info", "warning" or "critical"; alerts below it aren't posted (default "info")
+	Headers       map[string]string `json:"headers,omitempty"`         // added to every request, e.g. an Authorization header
+}
+
+// AlertPolicy shapes how the alerts it matches reach the webhooks: how urgent they are, which
+// ones count as repeats, how long repeats stay quiet, and whether they are posted again until
+// someone acknowledges them (/api/alerts/ack). The first policy matching an alert applies.
+type AlertPolicy struct {
+	Match          []string `json:"match"`                     // alert types ("ttl_anomaly") or type:kind ("tcp_anomaly:syn_flood"); "*" for every alert
+	Severity       string   `json:"severity,omitempty"`        // "info", "warning" (default) or "critical"
+	DedupKey       []string `json:"dedup_key,omitempty"`       // fields two alerts must share to be repeats: "type", "kind", "subject", "room" or any field of the alert (default type, kind and subject)
+	Cooldown       Duration `json:"cooldown,omitempty"`        // silence repeats this long, instead of each webhook's cooldown
+	Suppress       bool     `json:"suppress,omitempty"`        // never post them; screens and the timeline still get them
+	EscalateAfter  Duration `json:"escalate_after,omitempty"`  // post again this long after the last post while unacknowledged
+	MaxEscalations int      `json:"max_escalations,omitempty"` // give up after this many repeat posts (default: keep going until acknowledged)
+}
+
+// Schedule is a window of time that changes how the server behaves while it is open: quiet
+// alerts during load-in, more retention over the conference days. A window opens on each time
+// its cron expression matches and stays open for duration, or runs once from from to until.
+// Where open windows disagree, the one listed first wins.
+type Schedule struct {
+	Name       string     `json:"name"`
+	Cron       string     `json:"cron,omitempty"`        // "minute hour day-of-month month day-of-week", e.g. "0 7 * * 1-5"
+	Duration   Duration   `json:"duration,omitempty"`    // how long each cron window stays open
+	From       string     `json:"from,omitempty"`        // a one-off window instead of cron, RFC 3339
+	Until      string     `json:"until,omitempty"`       // end of the one-off window, RFC 3339
+	Timezone   string     `json:"timezone,omitempty"`    // IANA zone the cron expression is read in (default: the server's)
+	MuteAlerts []string   `json:"mute_alerts,omitempty"` // alert types or type:kind kept off the webhooks; "*" for every alert
+	SampleRate float64    `json:"sample_rate,omitempty"` // fraction of unpinned packets streamed (0-1], instead of the rooms' own
+	Rooms      []string   `json:"rooms,omitempty"`       // rooms sample_rate applies to; every room when empty
+	Retention  *Retention `json:"retention,omitempty"`   // fields set here override the retention section's
+}
+
+// Duration is a time.Duration written as a Go duration string ("90s", "72h") in JSON
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		d.Duration = parsed
+	case float64:
+		d.Duration = time.Duration(value * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Load reads a JSON config file; an empty path returns an empty config
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", path, err)
+	}
+	for _, room := range cfg.Rooms {
+		if room.SampleRate < 0 || room.SampleRate > 1 {
+			return nil, fmt.Errorf("config %s: room %s: sample_rate must be between 0 and 1", path, room.Name)
+		}
+	}
+	names := make(map[string]bool)
+	for _, profile := range cfg.Profiles {
+		switch {
+		case profile.Name == "":
+			return nil, fmt.Errorf("config %s: profile without a name", path)
+		case names[profile.Name]:
+			return nil, fmt.Errorf("config %s: profile %s: duplicate name", path, profile.Name)
+		case profile.SampleRate < 0 || profile.SampleRate > 1:
+			return nil, fmt.Errorf("config %s: profile %s: sample_rate must be between 0 and 1", path, profile.Name)
+		case profile.Filter != "" && profile.Interface == "":
+			return nil, fmt.Errorf("config %s: profile %s: filter needs an interface", path, profile.Name)
+		}
+		names[profile.Name] = true
+	}
+	if cfg.SNMP != nil {
+		ports := make(map[string]bool)
+		for _, port := range cfg.SNMP.Ports {
+			switch {
+			case port.Name == "" || port.Host == "":
+				return nil, fmt.Errorf("config %s: snmp: every port needs a name and a host", path)
+			case ports[port.Name]:
+				return nil, fmt.Errorf("config %s: snmp: port %s: duplicate name", path, port.Name)
+			case port.IfIndex <= 0:
+				return nil, fmt.Errorf("config %s: snmp: port %s: if_index must be positive", path, port.Name)
+			}
+			ports[port.Name] = true
+		}
+	}
+	if cfg.Probes != nil {
+		switch {
+		case cfg.Probes.Count < 0 || cfg.Probes.Count > 100:
+			return nil, fmt.Errorf("config %s: probes: count must be between 1 and 100", path)
+		case cfg.Probes.MaxHops < 0 || cfg.Probes.MaxHops > 64:
+			return nil, fmt.Errorf("config %s: probes: max_hops must be between 1 and 64", path)
+		}
+		for _, cidr := range cfg.Probes.Targets {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				return nil, fmt.Errorf("config %s: probes: target %q: %v", path, cidr, err)
+			}
+		}
+	}
+	checks := make(map[string]bool)
+	for _, check := range cfg.HealthChecks {
+		if check.Name == "" || check.Host == "" {
+			return nil, fmt.Errorf("config %s: health_checks: every check needs a name and a host", path)
+		}
+		if checks[check.Name] {
+			return nil, fmt.Errorf("config %s: health check %s: duplicate name", path, check.Name)
+		}
+		checks[check.Name] = true
+		if _, err := netip.ParseAddr(check.Host); err != nil {
+			return nil, fmt.Errorf("config %s: health check %s: host must be an IP address", path, check.Name)
+		}
+		switch {
+		case check.Kind != "http" && check.Kind != "tcp" && check.Kind != "dns":
+			return nil, fmt.Errorf("config %s: health check %s: unknown kind %q (http, tcp or dns)", path, check.Name, check.Kind)
+		case check.Kind == "tcp" && check.Port <= 0:
+			return nil, fmt.Errorf("config %s: health check %s: tcp checks need a port", path, check.Name)
+		case check.Kind == "dns" && check.Query == "":
+			return nil, fmt.Errorf("config %s: health check %s: dns checks need a query", path, check.Name)
+		case check.Port < 0 || check.Port > 65535:
+			return nil, fmt.Errorf("config %s: health check %s: invalid port %d", path, check.Name, check.Port)
+		}
+	}
+	for _, room := range cfg.Rooms {
+		if room.Profile != "" && !names[room.Profile] {
+			return nil, fmt.Errorf("config %s: room %s: no profile named %q", path, room.Name, room.Profile)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,139 @@
+package enrich
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+)
+
+// AnonymizeKeySize is the length of an Anonymizer key: an AES-128 key followed by the pad secret
+const AnonymizeKeySize = 32
+
+// maxPseudonyms bounds the pseudonym cache; it starts over when full
+const maxPseudonyms = 1 << 16
+
+// DefaultAnonymizedNets are the ranges attendee devices are addressed from: private, carrier-grade
+// NAT and link-local space
+var DefaultAnonymizedNets = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"169.254.0.0/16",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// Anonymizer pseudonymizes IP addresses with Crypto-PAn, so addresses that share a prefix
+// keep sharing one of the same length and the wall still shows the network's shape. Only
+// addresses inside the configured ranges are rewritten, and they stay inside them: the
+// range's own prefix is kept. Everything else, such as internet servers, passes through.
+// The same key gives the same pseudonyms across restarts.
+type Anonymizer struct {
+	block cipher.Block
+	pad   [16]byte
+	nets  []netip.Prefix // shortest first
+
+	mu    sync.Mutex
+	cache map[netip.Addr]netip.Addr
+}
+
+// NewAnonymizer builds an anonymizer from an AnonymizeKeySize-byte key and the CIDRs to rewrite
+// (DefaultAnonymizedNets when empty)
+func NewAnonymizer(key []byte, cidrs []string) (*Anonymizer, error) {
+	if len(key) != AnonymizeKeySize {
+		return nil, fmt.Errorf("anonymization key must be %d bytes, got %d", AnonymizeKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	a := &Anonymizer{block: block, cache: make(map[netip.Addr]netip.Addr)}
+	block.Encrypt(a.pad[:], key[16:])
+
+	if len(cidrs) == 0 {
+		cidrs = DefaultAnonymizedNets
+	}
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("anonymized range %q: %v", cidr, err)
+		}
+		a.nets = append(a.nets, prefix.Masked())
+	}
+	sort.SliceStable(a.nets, func(i, j int) bool {
+		return a.nets[i].Bits() < a.nets[j].Bits()
+	})
+	return a, nil
+}
+
+// Masks reports whether an address is in an anonymized range. Pseudonyms stay in their
+// range, so this holds for an address and its pseudonym alike.
+func (a *Anonymizer) Masks(ipStr string) bool {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return false
+	}
+	_, ok := a.rangeOf(addr.Unmap())
+	return ok
+}
+
+// Address returns the pseudonym for an IP, or the IP itself outside the anonymized ranges
+func (a *Anonymizer) Address(ipStr string) string {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return ipStr
+	}
+	addr = addr.Unmap()
+	keep, ok := a.rangeOf(addr)
+	if !ok {
+		return ipStr
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if pseudonym, ok := a.cache[addr]; ok {
+		return pseudonym.String()
+	}
+	pseudonym := a.pseudonym(addr, keep)
+	if len(a.cache) >= maxPseudonyms {
+		a.cache = make(map[netip.Addr]netip.Addr)
+	}
+	a.cache[addr] = pseudonym
+	return pseudonym.String()
+}
+
+// rangeOf returns the prefix length to keep for an address, if it is anonymized
+func (a *Anonymizer) rangeOf(addr netip.Addr) (int, bool) {
+	for _, prefix := range a.nets {
+		if prefix.Contains(addr) {
+			return prefix.Bits(), true
+		}
+	}
+	return 0, false
+}
+
+// pseudonym flips each bit after the first keep with a bit of AES output that depends only
+// on the bits before it, which is what makes the mapping prefix-preserving. Callers hold mu.
+func (a *Anonymizer) pseudonym(addr netip.Addr, keep int) netip.Addr {
+	original := addr.AsSlice()
+	result := append([]byte(nil), original...)
+	var input, output [16]byte
+	for pos := keep; pos < len(original)*8; pos++ {
+		// The first pos bits of the original address, padded out with the secret pad
+		input = a.pad
+		whole := pos / 8
+		copy(input[:whole], original[:whole])
+		if rest := pos % 8; rest > 0 {
+			mask := byte(0xff << (8 - rest))
+			input[whole] = original[whole]&mask | a.pad[whole]&^mask
+		}
+		a.block.Encrypt(output[:], input[:])
+		result[pos/8] ^= (output[0] >> 7) << (7 - pos%8)
+	}
+	pseudonym, _ := netip.AddrFromSlice(result)
+	return pseudonym
+}
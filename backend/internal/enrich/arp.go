@@ -0,0 +1,355 @@
+package enrich
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+const (
+	// DefaultNeighborInterval is how often an arp command is run
+	DefaultNeighborInterval = time.Minute
+	// DefaultNeighborMaxAge is how long a host missing from the gateway's table is remembered
+	DefaultNeighborMaxAge = 30 * time.Minute
+	// maxNeighbors bounds the table; hosts at new addresses are ignored while it is full
+	maxNeighbors = 1 << 16
+	// neighborPollInterval is how often an arp file is checked for changes
+	neighborPollInterval = 2 * time.Second
+)
+
+// NeighborEntry is a host a gateway's ARP or IPv6 neighbor table knows
+type NeighborEntry struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac"`
+	Interface string    `json:"interface,omitempty"` // the gateway's interface or VLAN
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// NeighborParser reads a whole ARP or neighbor table dump
+type NeighborParser func(r io.Reader) ([]NeighborEntry, error)
+
+// NeighborParsers are the table dumps an arp config section can read
+var NeighborParsers = map[string]NeighborParser{
+	"linux": ParseIPNeigh,
+	"cisco": ParseCiscoNeighbors,
+}
+
+// NeighborTable holds the hosts the gateways know, so the graph can show every host on the
+// network before it sends traffic the tap sees. Entries come from a dump file, a command run
+// every interval, or posts to /api/arp, and are forgotten once missing from them for max_age.
+type NeighborTable struct {
+	source   string
+	file     string
+	command  []string
+	interval time.Duration
+	maxAge   time.Duration
+
+	// OnChange is called with hosts that appeared (or moved to another MAC) and addresses that
+	// were forgotten
+	OnChange func(added []NeighborEntry, removed []string)
+
+	mu      sync.RWMutex
+	entries map[string]*NeighborEntry
+}
+
+// NewNeighborTable builds an empty table from config
+func NewNeighborTable(cfg *config.ARP) (*NeighborTable, error) {
+	t := &NeighborTable{
+		source:   cfg.Source,
+		file:     cfg.File,
+		command:  cfg.Command,
+		interval: cfg.Interval.Duration,
+		maxAge:   cfg.MaxAge.Duration,
+		entries:  make(map[string]*NeighborEntry),
+	}
+	if t.interval <= 0 {
+		t.interval = DefaultNeighborInterval
+	}
+	if t.maxAge <= 0 {
+		t.maxAge = DefaultNeighborMaxAge
+	}
+	if cfg.File != "" && len(cfg.Command) > 0 {
+		return nil, fmt.Errorf("arp: file and command can't both be set")
+	}
+	if cfg.Source != "" {
+		if _, ok := NeighborParsers[cfg.Source]; !ok {
+			return nil, fmt.Errorf("arp: unknown source %q (linux or cisco)", cfg.Source)
+		}
+		if cfg.File == "" && len(cfg.Command) == 0 {
+			return nil, fmt.Errorf("arp: source %s needs a file or a command", cfg.Source)
+		}
+	} else if cfg.File != "" || len(cfg.Command) > 0 {
+		return nil, fmt.Errorf("arp: a file or command needs a source (linux or cisco)")
+	}
+	return t, nil
+}
+
+// Put records hosts posted to /api/arp, returning those that are new to the table and how many
+// were recorded. Entries that aren't a unicast host with a MAC are reported in errs.
+func (t *NeighborTable) Put(entries []NeighborEntry) (added []NeighborEntry, learned int, errs []string) {
+	var valid []NeighborEntry
+	for _, entry := range entries {
+		if err := normalizeNeighbor(&entry); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	added, full := t.observe(valid, time.Now())
+	if full > 0 {
+		errs = append(errs, fmt.Sprintf("%d hosts ignored: the table is full", full))
+	}
+	return added, len(valid) - full, errs
+}
+
+// Entries returns the hosts in the table ordered by address
+func (t *NeighborTable) Entries() []NeighborEntry {
+	t.mu.RLock()
+	entries := make([]NeighborEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, *entry)
+	}
+	t.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		a, _ := netip.ParseAddr(entries[i].IP)
+		b, _ := netip.ParseAddr(entries[j].IP)
+		return a.Less(b)
+	})
+	return entries
+}
+
+// observe refreshes hosts seen in a dump or post, returning the ones that are new or moved to
+// another MAC, and how many didn't fit
+func (t *NeighborTable) observe(entries []NeighborEntry, now time.Time) (added []NeighborEntry, full int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, entry := range entries {
+		entry.LastSeen = now
+		previous, ok := t.entries[entry.IP]
+		if !ok && len(t.entries) >= maxNeighbors {
+			full++
+			continue
+		}
+		if !ok || previous.MAC != entry.MAC {
+			added = append(added, entry)
+		}
+		stored := entry
+		t.entries[entry.IP] = &stored
+	}
+	return added, full
+}
+
+// sweep forgets hosts missing from the table for max_age and returns their addresses
+func (t *NeighborTable) sweep(now time.Time) []string {
+	var removed []string
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ip, entry := range t.entries {
+		if now.Sub(entry.LastSeen) > t.maxAge {
+			delete(t.entries, ip)
+			removed = append(removed, ip)
+		}
+	}
+	return removed
+}
+
+// Run keeps the table current until stop is closed: the dump file is read again whenever it
+// changes, or the command is run every interval, and hosts past max_age are forgotten. OnChange
+// hears about both.
+func (t *NeighborTable) Run(stop <-chan struct{}) {
+	var modTime time.Time
+	var size int64 = -1
+	var lastRun time.Time
+	failing := false
+
+	ticker := time.NewTicker(neighborPollInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		var entries []NeighborEntry
+		var err error
+		read := false
+		switch {
+		case t.file != "":
+			var info os.FileInfo
+			if info, err = os.Stat(t.file); err == nil && (!info.ModTime().Equal(modTime) || info.Size() != size) {
+				if entries, err = t.readFile(); err == nil {
+					modTime, size, read = info.ModTime(), info.Size(), true
+				}
+			}
+		case len(t.command) > 0 && now.Sub(lastRun) >= t.interval:
+			lastRun = now
+			if entries, err = t.runCommand(); err == nil {
+				read = true
+			}
+		}
+		switch {
+		case err != nil && !failing:
+			log.Printf("⚠️ ARP table %s: %v", t.origin(), err)
+			failing = true
+		case err == nil && read && failing:
+			log.Printf("🔁 ARP table %s readable again", t.origin())
+			failing = false
+		}
+
+		var added []NeighborEntry
+		if read {
+			added, _ = t.observe(entries, now)
+		}
+		removed := t.sweep(now)
+		if t.OnChange != nil && (len(added) > 0 || len(removed) > 0) {
+			t.OnChange(added, removed)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// origin names where the table comes from, for logs
+func (t *NeighborTable) origin() string {
+	if t.file != "" {
+		return t.file
+	}
+	return strings.Join(t.command, " ")
+}
+
+func (t *NeighborTable) readFile() ([]NeighborEntry, error) {
+	file, err := os.Open(t.file)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return t.parse(file)
+}
+
+// runCommand runs the command, given no longer than the interval, and parses its output
+func (t *NeighborTable) runCommand() ([]NeighborEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.interval)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command[0], t.command[1:]...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%v: %s", err, message)
+		}
+		return nil, err
+	}
+	return t.parse(bytes.NewReader(output))
+}
+
+// parse reads a dump, keeping the unicast hosts in it
+func (t *NeighborTable) parse(r io.Reader) ([]NeighborEntry, error) {
+	entries, err := NeighborParsers[t.source](r)
+	if err != nil {
+		return nil, err
+	}
+	valid := entries[:0]
+	for _, entry := range entries {
+		if normalizeNeighbor(&entry) == nil {
+			valid = append(valid, entry)
+		}
+	}
+	return valid, nil
+}
+
+// normalizeNeighbor canonicalizes an entry's address and MAC, rejecting anything that isn't a
+// unicast host: multicast, broadcast and link-local addresses never appear as graph nodes
+func normalizeNeighbor(entry *NeighborEntry) error {
+	addr, err := netip.ParseAddr(entry.IP)
+	if err != nil {
+		return fmt.Errorf("invalid neighbor IP %q", entry.IP)
+	}
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() {
+		return fmt.Errorf("neighbor %s isn't a unicast host", addr)
+	}
+	mac, err := net.ParseMAC(entry.MAC)
+	if err != nil || len(mac) != 6 {
+		return fmt.Errorf("neighbor %s: invalid MAC %q", addr, entry.MAC)
+	}
+	if mac[0]&1 != 0 || bytes.Equal(mac, make(net.HardwareAddr, 6)) {
+		return fmt.Errorf("neighbor %s: MAC %s isn't a unicast host", addr, mac)
+	}
+	entry.IP, entry.MAC = addr.String(), mac.String()
+	return nil
+}
+
+// ParseIPNeigh reads Linux `ip neigh` output. Entries without a link-layer address (FAILED,
+// INCOMPLETE) are skipped.
+//
+//	10.10.3.17 dev eth1 lladdr 3c:22:fb:12:34:56 REACHABLE
+//	2001:db8::17 dev eth1 lladdr 3c:22:fb:12:34:56 router STALE
+func ParseIPNeigh(r io.Reader) ([]NeighborEntry, error) {
+	var entries []NeighborEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		entry := NeighborEntry{IP: fields[0]}
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "dev":
+				entry.Interface = fields[i+1]
+			case "lladdr":
+				entry.MAC = fields[i+1]
+			}
+		}
+		if entry.MAC != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ParseCiscoNeighbors reads IOS `show ip arp` and `show ipv6 neighbors` output, either or both.
+// Incomplete entries and header lines are skipped.
+//
+//	Internet  10.10.3.17             12   3c22.fb12.3456  ARPA   Vlan30
+//	2001:DB8::17                      0 3c22.fb12.3456  REACH Vl30
+func ParseCiscoNeighbors(r io.Reader) ([]NeighborEntry, error) {
+	var entries []NeighborEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		var entry NeighborEntry
+		switch {
+		case len(fields) >= 5 && fields[0] == "Internet":
+			entry = NeighborEntry{IP: fields[1], MAC: fields[3]}
+			if len(fields) >= 6 {
+				entry.Interface = fields[5]
+			}
+		case len(fields) >= 4 && strings.Contains(fields[0], ":"):
+			entry = NeighborEntry{IP: fields[0], MAC: fields[2]}
+			if len(fields) >= 5 {
+				entry.Interface = fields[4]
+			}
+		default:
+			continue
+		}
+		if _, err := net.ParseMAC(entry.MAC); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,149 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Asset is an operator-supplied label for a known IP (e.g. the registration server)
+type Asset struct {
+	IP        string    `json:"ip"`
+	Label     string    `json:"label"`
+	Role      string    `json:"role,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+	Color     string    `json:"color,omitempty"` // CSS hex color, e.g. "#00ff9f"
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Validate normalizes the IP and checks required fields
+func (a *Asset) Validate() error {
+	addr, err := netip.ParseAddr(a.IP)
+	if err != nil {
+		return fmt.Errorf("invalid asset IP %q", a.IP)
+	}
+	a.IP = addr.Unmap().String()
+	if a.Label == "" {
+		return fmt.Errorf("asset %s needs a label", a.IP)
+	}
+	if a.Color != "" && !hexColor.MatchString(a.Color) {
+		return fmt.Errorf("asset %s: color %q must be #rgb or #rrggbb", a.IP, a.Color)
+	}
+	return nil
+}
+
+// AssetStore is the IP → asset inventory, persisted as JSON on every change
+type AssetStore struct {
+	mu     sync.RWMutex
+	path   string
+	assets map[string]*Asset
+}
+
+// NewAssetStore loads the inventory from path (missing file = empty inventory)
+func NewAssetStore(path string) (*AssetStore, error) {
+	store := &AssetStore{
+		path:   path,
+		assets: make(map[string]*Asset),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var assets []*Asset
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, fmt.Errorf("parsing asset inventory %s: %v", path, err)
+	}
+	for _, asset := range assets {
+		if err := asset.Validate(); err != nil {
+			log.Printf("⚠️ Skipping asset: %v", err)
+			continue
+		}
+		store.assets[asset.IP] = asset
+	}
+	log.Printf("🏷️ Loaded %d assets from %s", len(store.assets), path)
+	return store, nil
+}
+
+// Get returns the asset for an IP
+func (s *AssetStore) Get(ip string) (*Asset, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	asset, ok := s.assets[ip]
+	return asset, ok
+}
+
+// List returns all assets ordered by IP
+func (s *AssetStore) List() []*Asset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	assets := make([]*Asset, 0, len(s.assets))
+	for _, asset := range s.assets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].IP < assets[j].IP
+	})
+	return assets
+}
+
+// Put creates or replaces an asset and persists the inventory
+func (s *AssetStore) Put(asset *Asset) error {
+	if err := asset.Validate(); err != nil {
+		return err
+	}
+	asset.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assets[asset.IP] = asset
+	return s.saveLocked()
+}
+
+// Delete removes an asset; it reports whether the IP was present
+func (s *AssetStore) Delete(ip string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.assets[ip]; !ok {
+		return false, nil
+	}
+	delete(s.assets, ip)
+	return true, s.saveLocked()
+}
+
+func (s *AssetStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	assets := make([]*Asset, 0, len(s.assets))
+	for _, asset := range s.assets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].IP < assets[j].IP
+	})
+
+	data, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
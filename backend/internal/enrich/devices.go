@@ -0,0 +1,252 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+const (
+	// maxDevices bounds the registry; hosts appearing while it is full are not remembered
+	maxDevices = 200000
+	// deviceTouchInterval is how stale last_seen may get before a packet moves it on
+	deviceTouchInterval = 10 * time.Second
+	// deviceSaveInterval is how often a changed registry is written out
+	deviceSaveInterval = 30 * time.Second
+)
+
+// Device is a host seen sending on a monitored subnet
+type Device struct {
+	IP        string    `json:"ip"`            // the address it was last seen with
+	MAC       string    `json:"mac,omitempty"` // known for hosts on the capture's own segment or with a DHCP lease
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// DeviceRegistry remembers every host ever seen sending on the monitored subnets, across
+// restarts, so a host that has never been seen before stands out. A host is known by its MAC
+// when there is one, so a laptop that gets a new address is not new; without a MAC its address
+// is all there is.
+type DeviceRegistry struct {
+	subnets []netip.Prefix
+	path    string
+
+	mu    sync.RWMutex
+	byIP  map[string]*Device
+	byMAC map[string]*Device
+	dirty bool
+}
+
+// NewDeviceRegistry loads the registry from path (missing file = nothing seen yet)
+func NewDeviceRegistry(cfg *config.Devices, path string) (*DeviceRegistry, error) {
+	r := &DeviceRegistry{
+		path:  path,
+		byIP:  make(map[string]*Device),
+		byMAC: make(map[string]*Device),
+	}
+	if len(cfg.Subnets) == 0 {
+		return nil, fmt.Errorf("devices: subnets lists no CIDRs")
+	}
+	for _, cidr := range cfg.Subnets {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("devices: invalid subnet %q", cidr)
+		}
+		r.subnets = append(r.subnets, prefix.Masked())
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var devices []*Device
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("parsing device registry %s: %v", path, err)
+	}
+	for _, device := range devices {
+		r.index(device)
+	}
+	log.Printf("🆕 Loaded %d known devices from %s", len(devices), path)
+	return r, nil
+}
+
+func (r *DeviceRegistry) index(device *Device) {
+	r.byIP[device.IP] = device
+	if device.MAC != "" {
+		r.byMAC[device.MAC] = device
+	}
+}
+
+// Monitored reports whether an address is on one of the monitored subnets
+func (r *DeviceRegistry) Monitored(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.subnets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Observe records a host seen sending at the given time and returns it when it has never been
+// seen before, nil otherwise. mac may be empty. Hosts off the monitored subnets are ignored.
+func (r *DeviceRegistry) Observe(ip, mac string, at time.Time) *Device {
+	if !r.Monitored(ip) {
+		return nil
+	}
+	// Most packets come from hosts seen moments ago
+	r.mu.RLock()
+	device, ok := r.byIP[ip]
+	fresh := ok && (mac == "" || device.MAC == mac) && at.Sub(device.LastSeen) < deviceTouchInterval
+	r.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if mac != "" {
+		device, ok = r.byMAC[mac]
+	}
+	if !ok {
+		device, ok = r.byIP[ip]
+		// The address belonged to another device before
+		if ok && mac != "" && device.MAC != "" && device.MAC != mac {
+			ok = false
+		}
+	}
+	if ok {
+		if device.IP != ip {
+			if r.byIP[device.IP] == device {
+				delete(r.byIP, device.IP)
+			}
+			device.IP = ip
+		}
+		if mac != "" {
+			device.MAC = mac
+		}
+		if at.After(device.LastSeen) {
+			device.LastSeen = at
+		}
+		r.index(device)
+		r.dirty = true
+		return nil
+	}
+
+	if len(r.byIP) >= maxDevices {
+		return nil
+	}
+	device = &Device{IP: ip, MAC: mac, FirstSeen: at, LastSeen: at}
+	r.index(device)
+	r.dirty = true
+	copied := *device
+	return &copied
+}
+
+// List returns the known devices, most recent first by first_seen or last_seen. limit bounds
+// the list when positive.
+func (r *DeviceRegistry) List(order string, limit int) []Device {
+	devices := r.snapshot()
+	sort.Slice(devices, func(i, j int) bool {
+		a, b := devices[i].FirstSeen, devices[j].FirstSeen
+		if order == "last_seen" {
+			a, b = devices[i].LastSeen, devices[j].LastSeen
+		}
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return devices[i].IP < devices[j].IP
+	})
+	if limit > 0 && len(devices) > limit {
+		devices = devices[:limit]
+	}
+	return devices
+}
+
+// snapshot copies every device once; a device moved to a new address may still be indexed by
+// its MAC alone
+func (r *DeviceRegistry) snapshot() []Device {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[*Device]bool, len(r.byIP))
+	devices := make([]Device, 0, len(r.byIP))
+	for _, index := range []map[string]*Device{r.byIP, r.byMAC} {
+		for _, device := range index {
+			if !seen[device] {
+				seen[device] = true
+				devices = append(devices, *device)
+			}
+		}
+	}
+	return devices
+}
+
+// Subnets returns the monitored subnets
+func (r *DeviceRegistry) Subnets() []string {
+	subnets := make([]string, len(r.subnets))
+	for i, prefix := range r.subnets {
+		subnets[i] = prefix.String()
+	}
+	return subnets
+}
+
+// Run writes the registry out every 30 s while it changes, until stop is closed
+func (r *DeviceRegistry) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(deviceSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			r.save()
+			return
+		case <-ticker.C:
+			r.save()
+		}
+	}
+}
+
+func (r *DeviceRegistry) save() {
+	if r.path == "" {
+		return
+	}
+	r.mu.Lock()
+	dirty := r.dirty
+	r.dirty = false
+	r.mu.Unlock()
+	if !dirty {
+		return
+	}
+
+	devices := r.snapshot()
+	sort.Slice(devices, func(i, j int) bool { return devices[i].FirstSeen.Before(devices[j].FirstSeen) })
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err == nil {
+		tmp := r.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, r.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Device registry %s: %v", r.path, err)
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,393 @@
+package enrich
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+const (
+	// DefaultLeaseTime is how long a posted lease without an expiry lasts
+	DefaultLeaseTime = time.Hour
+	// maxLeases bounds the table; posted leases for new addresses are ignored while it is full
+	maxLeases = 1 << 16
+	// leasePollInterval is how often the leases file is checked for changes
+	leasePollInterval = 2 * time.Second
+)
+
+// Lease is one DHCP lease: the device an address was handed to
+type Lease struct {
+	IP       string    `json:"ip"`
+	MAC      string    `json:"mac,omitempty"`
+	Hostname string    `json:"hostname,omitempty"` // the name the client asked for
+	Expires  time.Time `json:"expires"`            // zero (0001-01-01T00:00:00Z) for a lease that never expires
+	Source   string    `json:"source"`             // "isc", "dnsmasq" or "api"
+}
+
+// sameDevice reports whether two leases name the same device; renewals only move the expiry
+func (l *Lease) sameDevice(other *Lease) bool {
+	return l.MAC == other.MAC && l.Hostname == other.Hostname
+}
+
+// LeaseParser reads a whole leases file
+type LeaseParser func(r io.Reader) ([]Lease, error)
+
+// LeaseParsers are the leases files a dhcp config section can follow
+var LeaseParsers = map[string]LeaseParser{
+	"isc":     ParseISCLeases,
+	"dnsmasq": ParseDnsmasqLeases,
+}
+
+// LeaseTable maps addresses to the devices the DHCP server leased them to, so a node can be
+// shown as "bob-laptop" rather than 10.10.3.17 even after the address moves to someone else.
+// Leases come from the DHCP server's leases file or are posted to /api/dhcp by a switch doing
+// DHCP snooping, and are dropped when they expire.
+type LeaseTable struct {
+	source    string
+	file      string
+	leaseTime time.Duration
+
+	// OnChange is called from Run whenever an address gets a new device, or loses its lease
+	OnChange func(ip string)
+
+	mu     sync.RWMutex
+	leases map[string]*Lease
+}
+
+// NewLeaseTable builds an empty table from config
+func NewLeaseTable(cfg *config.DHCP) (*LeaseTable, error) {
+	t := &LeaseTable{
+		source:    cfg.Source,
+		file:      cfg.File,
+		leaseTime: cfg.LeaseTime.Duration,
+		leases:    make(map[string]*Lease),
+	}
+	if t.leaseTime <= 0 {
+		t.leaseTime = DefaultLeaseTime
+	}
+	if cfg.Source != "" {
+		if _, ok := LeaseParsers[cfg.Source]; !ok {
+			return nil, fmt.Errorf("dhcp: unknown source %q (isc or dnsmasq)", cfg.Source)
+		}
+		if cfg.File == "" {
+			return nil, fmt.Errorf("dhcp: source %s needs a file", cfg.Source)
+		}
+	}
+	return t, nil
+}
+
+// Get returns the live lease of an address
+func (t *LeaseTable) Get(ip string) (*Lease, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	lease, ok := t.leases[ip]
+	if !ok || lease.expired(time.Now()) {
+		return nil, false
+	}
+	return lease, true
+}
+
+func (l *Lease) expired(now time.Time) bool {
+	return !l.Expires.IsZero() && now.After(l.Expires)
+}
+
+// Put adds or renews a posted lease and reports whether the address changed hands. A lease
+// without an expiry lasts the configured lease time.
+func (t *LeaseTable) Put(lease Lease) (changed bool, err error) {
+	addr, err := netip.ParseAddr(lease.IP)
+	if err != nil {
+		return false, fmt.Errorf("invalid lease IP %q", lease.IP)
+	}
+	lease.IP = addr.Unmap().String()
+	if lease.MAC != "" {
+		mac, err := net.ParseMAC(lease.MAC)
+		if err != nil {
+			return false, fmt.Errorf("lease %s: invalid MAC %q", lease.IP, lease.MAC)
+		}
+		lease.MAC = mac.String()
+	}
+	if lease.MAC == "" && lease.Hostname == "" {
+		return false, fmt.Errorf("lease %s needs a mac or a hostname", lease.IP)
+	}
+	if lease.Expires.IsZero() {
+		lease.Expires = time.Now().Add(t.leaseTime)
+	}
+	lease.Source = "api"
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous, ok := t.leases[lease.IP]
+	if !ok && len(t.leases) >= maxLeases {
+		return false, fmt.Errorf("lease table is full")
+	}
+	t.leases[lease.IP] = &lease
+	return !ok || previous.expired(time.Now()) || !previous.sameDevice(&lease), nil
+}
+
+// Release drops the lease of an address and reports whether it had one
+func (t *LeaseTable) Release(ip string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.leases[ip]; !ok {
+		return false
+	}
+	delete(t.leases, ip)
+	return true
+}
+
+// Leases returns the live leases ordered by address
+func (t *LeaseTable) Leases() []Lease {
+	now := time.Now()
+	t.mu.RLock()
+	leases := make([]Lease, 0, len(t.leases))
+	for _, lease := range t.leases {
+		if !lease.expired(now) {
+			leases = append(leases, *lease)
+		}
+	}
+	t.mu.RUnlock()
+	sort.Slice(leases, func(i, j int) bool {
+		a, _ := netip.ParseAddr(leases[i].IP)
+		b, _ := netip.ParseAddr(leases[j].IP)
+		return a.Less(b)
+	})
+	return leases
+}
+
+// replaceFile swaps the leases read from the file for a fresh read of it, keeping posted
+// leases, and returns the addresses whose device changed
+func (t *LeaseTable) replaceFile(leases []Lease) []string {
+	now := time.Now()
+	fresh := make(map[string]*Lease, len(leases))
+	for i := range leases {
+		lease := &leases[i]
+		lease.Source = t.source
+		if !lease.expired(now) {
+			fresh[lease.IP] = lease
+		}
+	}
+
+	var changed []string
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ip, lease := range t.leases {
+		if lease.Source == t.source && fresh[ip] == nil {
+			delete(t.leases, ip)
+			changed = append(changed, ip)
+		}
+	}
+	for ip, lease := range fresh {
+		previous, ok := t.leases[ip]
+		// A snooped lease is newer than what the server has written out so far
+		if ok && previous.Source == "api" && !previous.expired(now) {
+			continue
+		}
+		if !ok || previous.expired(now) || !previous.sameDevice(lease) {
+			changed = append(changed, ip)
+		}
+		t.leases[ip] = lease
+	}
+	return changed
+}
+
+// sweep drops expired leases and returns their addresses
+func (t *LeaseTable) sweep() []string {
+	now := time.Now()
+	var expired []string
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ip, lease := range t.leases {
+		if lease.expired(now) {
+			delete(t.leases, ip)
+			expired = append(expired, ip)
+		}
+	}
+	return expired
+}
+
+// Run keeps the table current until stop is closed: the leases file is read again whenever it
+// changes (the DHCP server appends renewals and rewrites it now and then), and expired leases
+// are dropped. OnChange hears about every address that changed hands.
+func (t *LeaseTable) Run(stop <-chan struct{}) {
+	var modTime time.Time
+	var size int64 = -1
+	failing := false
+
+	ticker := time.NewTicker(leasePollInterval)
+	defer ticker.Stop()
+	for {
+		var changed []string
+		if t.file != "" {
+			info, err := os.Stat(t.file)
+			if err == nil && (!info.ModTime().Equal(modTime) || info.Size() != size) {
+				var leases []Lease
+				if leases, err = t.readFile(); err == nil {
+					modTime, size = info.ModTime(), info.Size()
+					changed = t.replaceFile(leases)
+				}
+			}
+			switch {
+			case err != nil && !failing:
+				log.Printf("⚠️ DHCP leases %s: %v", t.file, err)
+				failing = true
+			case err == nil && failing:
+				log.Printf("🔁 DHCP leases %s readable again", t.file)
+				failing = false
+			}
+		}
+		changed = append(changed, t.sweep()...)
+		if t.OnChange != nil {
+			for _, ip := range changed {
+				t.OnChange(ip)
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *LeaseTable) readFile() ([]Lease, error) {
+	file, err := os.Open(t.file)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LeaseParsers[t.source](file)
+}
+
+// ParseISCLeases reads an ISC dhcpd.leases file. dhcpd appends a lease block whenever a lease
+// changes, so the last block for an address wins, and a block whose binding state isn't active
+// ends the lease. Times are UTC unless written as "epoch" seconds.
+//
+//	lease 10.10.3.17 {
+//	  ends 6 2024/08/10 16:00:00;
+//	  binding state active;
+//	  hardware ethernet 3c:22:fb:12:34:56;
+//	  client-hostname "bob-laptop";
+//	}
+func ParseISCLeases(r io.Reader) ([]Lease, error) {
+	latest := make(map[string]*Lease)
+	var order []string
+	var current *Lease
+	active := true
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+		if current == nil {
+			fields := strings.Fields(line)
+			if len(fields) == 3 && fields[0] == "lease" && fields[2] == "{" {
+				if addr, err := netip.ParseAddr(fields[1]); err == nil {
+					current, active = &Lease{IP: addr.Unmap().String()}, true
+				}
+			}
+			continue
+		}
+		if line == "}" {
+			if _, seen := latest[current.IP]; !seen {
+				order = append(order, current.IP)
+			}
+			if active {
+				latest[current.IP] = current
+			} else {
+				latest[current.IP] = nil
+			}
+			current = nil
+			continue
+		}
+
+		statement := strings.Fields(strings.TrimSuffix(line, ";"))
+		switch {
+		case len(statement) >= 2 && statement[0] == "ends":
+			current.Expires = parseISCTime(statement[1:])
+		case len(statement) == 3 && statement[0] == "binding" && statement[1] == "state":
+			active = statement[2] == "active"
+		case len(statement) == 3 && statement[0] == "hardware":
+			if mac, err := net.ParseMAC(statement[2]); err == nil {
+				current.MAC = mac.String()
+			}
+		case len(statement) >= 2 && statement[0] == "client-hostname":
+			current.Hostname = strings.Trim(strings.Join(statement[1:], " "), `"`)
+		}
+	}
+
+	leases := make([]Lease, 0, len(order))
+	for _, ip := range order {
+		if lease := latest[ip]; lease != nil {
+			leases = append(leases, *lease)
+		}
+	}
+	return leases, scanner.Err()
+}
+
+// parseISCTime reads the value of an ends statement: "never", "epoch <seconds>" or
+// "<weekday> <yyyy/mm/dd> <hh:mm:ss>" in UTC. An unreadable time counts as never.
+func parseISCTime(value []string) time.Time {
+	switch {
+	case value[0] == "epoch" && len(value) >= 2:
+		seconds, err := strconv.ParseInt(value[1], 10, 64)
+		if err == nil {
+			return time.Unix(seconds, 0)
+		}
+	case len(value) >= 3:
+		at, err := time.Parse("2006/01/02 15:04:05", value[1]+" "+value[2])
+		if err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// ParseDnsmasqLeases reads a dnsmasq leases file: expiry (Unix seconds, 0 for never), MAC,
+// address, hostname ("*" when the client sent none) and client ID per line. DHCPv6 leases
+// have an IAID where the MAC goes and keep no MAC.
+//
+//	1723305600 3c:22:fb:12:34:56 10.10.3.17 bob-laptop 01:3c:22:fb:12:34:56
+func ParseDnsmasqLeases(r io.Reader) ([]Lease, error) {
+	var leases []Lease
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		lease := Lease{IP: addr.Unmap().String()}
+		if expiry > 0 {
+			lease.Expires = time.Unix(expiry, 0)
+		}
+		if mac, err := net.ParseMAC(fields[1]); err == nil {
+			lease.MAC = mac.String()
+		}
+		if fields[3] != "*" {
+			lease.Hostname = fields[3]
+		}
+		leases = append(leases, lease)
+	}
+	return leases, scanner.Err()
+}
@@ -0,0 +1,90 @@
+package enrich
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxDNSAddresses bounds the cache; addresses resolved while it is full are not remembered
+	maxDNSAddresses = 100000
+	// maxNamesPerAddress bounds the names kept for one address, such as a CDN edge serving many sites
+	maxNamesPerAddress = 32
+	// minDNSTTL keeps a name for at least this long, since connections outlive short DNS TTLs
+	minDNSTTL = 10 * time.Minute
+	// dnsSweepInterval is how often expired names are dropped
+	dnsSweepInterval = time.Minute
+)
+
+// DNSCache remembers the names DNS responses on the wire resolved to each address, for as long
+// as the response's TTL (and at least a few minutes)
+type DNSCache struct {
+	mu        sync.RWMutex
+	names     map[string]map[string]time.Time // address -> name -> expiry
+	lastSweep time.Time
+}
+
+// NewDNSCache creates an empty cache
+func NewDNSCache() *DNSCache {
+	return &DNSCache{names: make(map[string]map[string]time.Time)}
+}
+
+// Observe remembers that name resolved to ip, for ttl
+func (c *DNSCache) Observe(ip, name string, ttl time.Duration) {
+	now := time.Now()
+	if ttl < minDNSTTL {
+		ttl = minDNSTTL
+	}
+	expires := now.Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) >= dnsSweepInterval {
+		c.sweep(now)
+	}
+	names, ok := c.names[ip]
+	if !ok {
+		if len(c.names) >= maxDNSAddresses {
+			return
+		}
+		names = make(map[string]time.Time)
+		c.names[ip] = names
+	}
+	if _, known := names[name]; !known && len(names) >= maxNamesPerAddress {
+		return
+	}
+	if expires.After(names[name]) {
+		names[name] = expires
+	}
+}
+
+// sweep drops expired names; callers hold mu
+func (c *DNSCache) sweep(now time.Time) {
+	c.lastSweep = now
+	for ip, names := range c.names {
+		for name, expires := range names {
+			if now.After(expires) {
+				delete(names, name)
+			}
+		}
+		if len(names) == 0 {
+			delete(c.names, ip)
+		}
+	}
+}
+
+// Names returns the unexpired names an address resolved from, sorted
+func (c *DNSCache) Names(ip string) []string {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var names []string
+	for name, expires := range c.names[ip] {
+		if !now.After(expires) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
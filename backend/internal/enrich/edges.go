@@ -0,0 +1,252 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+const (
+	// maxEdges bounds the baseline; connections appearing while it is full are not remembered
+	maxEdges = 500000
+	// defaultEdgeLearning is how long connections are learnt without alerts
+	defaultEdgeLearning = 24 * time.Hour
+)
+
+// Edge is a client seen reaching a service, with a sensitive host at one end or the other
+type Edge struct {
+	Client    string    `json:"client"`
+	Server    string    `json:"server"`
+	Port      int       `json:"port"`
+	Protocol  string    `json:"protocol"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type edgeKey struct {
+	client, server string
+	port           int
+	protocol       string
+}
+
+// edgeFile is the -edges file: the baseline and when learning it began
+type edgeFile struct {
+	Started time.Time `json:"learning_started"`
+	Edges   []Edge    `json:"edges"`
+}
+
+// EdgeBaseline remembers which hosts talk to which services on the sensitive subnets, across
+// restarts, so a host reaching something it never has before stands out. Connections seen
+// while the baseline is still learning are remembered without raising anything.
+type EdgeBaseline struct {
+	subnets []netip.Prefix
+	learn   time.Duration
+	path    string
+
+	mu      sync.RWMutex
+	started time.Time
+	edges   map[edgeKey]*Edge
+	dirty   bool
+}
+
+// NewEdgeBaseline loads the baseline from path (missing file = learning starts now)
+func NewEdgeBaseline(cfg *config.NewEdges, path string) (*EdgeBaseline, error) {
+	b := &EdgeBaseline{
+		learn:   cfg.Learn.Duration,
+		path:    path,
+		started: time.Now(),
+		edges:   make(map[edgeKey]*Edge),
+	}
+	if len(cfg.Subnets) == 0 {
+		return nil, fmt.Errorf("new_edges: subnets lists no CIDRs")
+	}
+	for _, cidr := range cfg.Subnets {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("new_edges: invalid subnet %q", cidr)
+		}
+		b.subnets = append(b.subnets, prefix.Masked())
+	}
+	if b.learn <= 0 {
+		b.learn = defaultEdgeLearning
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		b.dirty = true
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file edgeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing edge baseline %s: %v", path, err)
+	}
+	if !file.Started.IsZero() {
+		b.started = file.Started
+	}
+	for i := range file.Edges {
+		edge := file.Edges[i]
+		b.edges[edgeKey{edge.Client, edge.Server, edge.Port, edge.Protocol}] = &edge
+	}
+	log.Printf("🕸️ Loaded %d known connections from %s", len(file.Edges), path)
+	return b, nil
+}
+
+// Sensitive reports whether an address is on one of the sensitive subnets
+func (b *EdgeBaseline) Sensitive(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range b.subnets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// LearningUntil returns when the baseline stops learning and starts alerting
+func (b *EdgeBaseline) LearningUntil() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.started.Add(b.learn)
+}
+
+// Observe records a client reaching a service at the given time and returns the edge when it
+// has never been seen before and the baseline is done learning, nil otherwise. Connections with
+// no sensitive host at either end are ignored.
+func (b *EdgeBaseline) Observe(client, server string, port int, protocol string, at time.Time) *Edge {
+	if !b.Sensitive(client) && !b.Sensitive(server) {
+		return nil
+	}
+	key := edgeKey{client, server, port, protocol}
+	// Most packets belong to connections seen moments ago
+	b.mu.RLock()
+	edge, ok := b.edges[key]
+	fresh := ok && at.Sub(edge.LastSeen) < deviceTouchInterval
+	b.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if edge, ok = b.edges[key]; ok {
+		if at.After(edge.LastSeen) {
+			edge.LastSeen = at
+			b.dirty = true
+		}
+		return nil
+	}
+	if len(b.edges) >= maxEdges {
+		return nil
+	}
+	edge = &Edge{Client: client, Server: server, Port: port, Protocol: protocol, FirstSeen: at, LastSeen: at}
+	b.edges[key] = edge
+	b.dirty = true
+	if at.Before(b.started.Add(b.learn)) {
+		return nil
+	}
+	copied := *edge
+	return &copied
+}
+
+// List returns the known edges with ip at either end (every edge when ip is empty), most
+// recent first by first_seen. limit bounds the list when positive.
+func (b *EdgeBaseline) List(ip string, limit int) []Edge {
+	b.mu.RLock()
+	edges := make([]Edge, 0, len(b.edges))
+	for _, edge := range b.edges {
+		if ip == "" || edge.Client == ip || edge.Server == ip {
+			edges = append(edges, *edge)
+		}
+	}
+	b.mu.RUnlock()
+	sort.Slice(edges, func(i, j int) bool {
+		a, c := edges[i], edges[j]
+		if !a.FirstSeen.Equal(c.FirstSeen) {
+			return a.FirstSeen.After(c.FirstSeen)
+		}
+		if a.Client != c.Client {
+			return a.Client < c.Client
+		}
+		if a.Server != c.Server {
+			return a.Server < c.Server
+		}
+		return a.Port < c.Port
+	})
+	if limit > 0 && len(edges) > limit {
+		edges = edges[:limit]
+	}
+	return edges
+}
+
+// Subnets returns the sensitive subnets
+func (b *EdgeBaseline) Subnets() []string {
+	subnets := make([]string, len(b.subnets))
+	for i, prefix := range b.subnets {
+		subnets[i] = prefix.String()
+	}
+	return subnets
+}
+
+// Run writes the baseline out every 30 s while it changes, until stop is closed
+func (b *EdgeBaseline) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(deviceSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			b.save()
+			return
+		case <-ticker.C:
+			b.save()
+		}
+	}
+}
+
+func (b *EdgeBaseline) save() {
+	if b.path == "" {
+		return
+	}
+	b.mu.Lock()
+	if !b.dirty {
+		b.mu.Unlock()
+		return
+	}
+	b.dirty = false
+	file := edgeFile{Started: b.started, Edges: make([]Edge, 0, len(b.edges))}
+	for _, edge := range b.edges {
+		file.Edges = append(file.Edges, *edge)
+	}
+	b.mu.Unlock()
+
+	sort.Slice(file.Edges, func(i, j int) bool { return file.Edges[i].FirstSeen.Before(file.Edges[j].FirstSeen) })
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err == nil {
+		tmp := b.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, b.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Edge baseline %s: %v", b.path, err)
+		b.mu.Lock()
+		b.dirty = true
+		b.mu.Unlock()
+	}
+}
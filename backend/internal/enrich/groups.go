@@ -0,0 +1,87 @@
+package enrich
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+type groupPrefix struct {
+	prefix netip.Prefix
+	name   string
+}
+
+// SubnetGroups maps IPs to named groups of CIDRs; the most specific prefix wins
+type SubnetGroups struct {
+	mu       sync.RWMutex
+	groups   []config.SubnetGroup
+	prefixes []groupPrefix // sorted longest prefix first
+}
+
+// NewSubnetGroups creates a group matcher from config
+func NewSubnetGroups(groups []config.SubnetGroup) (*SubnetGroups, error) {
+	sg := &SubnetGroups{}
+	if err := sg.Set(groups); err != nil {
+		return nil, err
+	}
+	return sg, nil
+}
+
+// Set validates and atomically replaces all group definitions
+func (sg *SubnetGroups) Set(groups []config.SubnetGroup) error {
+	var prefixes []groupPrefix
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		if group.Name == "" {
+			return fmt.Errorf("subnet group with CIDRs %v has no name", group.CIDRs)
+		}
+		if seen[group.Name] {
+			return fmt.Errorf("duplicate subnet group %q", group.Name)
+		}
+		seen[group.Name] = true
+		for _, cidr := range group.CIDRs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return fmt.Errorf("subnet group %q: invalid CIDR %q: %v", group.Name, cidr, err)
+			}
+			prefixes = append(prefixes, groupPrefix{prefix: prefix.Masked(), name: group.Name})
+		}
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return prefixes[i].prefix.Bits() > prefixes[j].prefix.Bits()
+	})
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	sg.groups = groups
+	sg.prefixes = prefixes
+	return nil
+}
+
+// Groups returns the current group definitions
+func (sg *SubnetGroups) Groups() []config.SubnetGroup {
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+	return sg.groups
+}
+
+// Lookup returns the group name for an IP, or "" if it belongs to no group
+func (sg *SubnetGroups) Lookup(ipStr string) string {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+	for _, gp := range sg.prefixes {
+		if gp.prefix.Contains(addr) {
+			return gp.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,367 @@
+package enrich
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+const (
+	// DefaultNATTimeout is how long a mapping lasts without being seen again
+	DefaultNATTimeout = 10 * time.Minute
+	// maxNATMappings bounds the table; new mappings are ignored while it is full
+	maxNATMappings = 1 << 18
+	// natPollInterval is how often a followed file is checked for new lines
+	natPollInterval = time.Second
+)
+
+// NATMapping is one translation made by the NAT gateway: the internal host's address and port,
+// and the public ones it was given
+type NATMapping struct {
+	Protocol     string    `json:"protocol"` // "TCP" or "UDP"
+	Public       string    `json:"public"`
+	PublicPort   int       `json:"public_port"`
+	Internal     string    `json:"internal"`
+	InternalPort int       `json:"internal_port"`
+	Seen         time.Time `json:"seen"` // when the mapping was last learned
+}
+
+type natKey struct {
+	protocol string
+	public   string
+	port     int
+}
+
+// NATParser reads one line of a translation source. teardown is true for a line that ends the
+// mapping; ok is false for lines that aren't translations.
+type NATParser func(line string) (mapping NATMapping, teardown, ok bool)
+
+// NATParsers are the translation sources a nat config section can follow
+var NATParsers = map[string]NATParser{
+	"conntrack": ParseConntrackNAT,
+	"asa":       ParseASANAT,
+}
+
+// NATTable maps the NAT gateway's public addresses and ports back to the internal hosts behind
+// them, so a capture outside the gateway shows each client instead of one gateway node.
+// Mappings come from the gateway's translation table (conntrack output, firewall logs) or are
+// posted to /api/nat, and expire when they aren't seen again for the timeout.
+type NATTable struct {
+	pool    []netip.Prefix
+	timeout time.Duration
+
+	mu       sync.RWMutex
+	mappings map[natKey]*NATMapping
+}
+
+// NewNATTable builds an empty table from config
+func NewNATTable(cfg *config.NAT) (*NATTable, error) {
+	t := &NATTable{
+		timeout:  cfg.Timeout.Duration,
+		mappings: make(map[natKey]*NATMapping),
+	}
+	if t.timeout <= 0 {
+		t.timeout = DefaultNATTimeout
+	}
+	if len(cfg.Pool) == 0 {
+		return nil, fmt.Errorf("nat: pool lists no addresses")
+	}
+	for _, entry := range cfg.Pool {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("nat: pool entry %q is neither an address nor a CIDR", entry)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		t.pool = append(t.pool, prefix.Masked())
+	}
+	if cfg.Source != "" {
+		if _, ok := NATParsers[cfg.Source]; !ok {
+			return nil, fmt.Errorf("nat: unknown source %q (conntrack or asa)", cfg.Source)
+		}
+		if cfg.File == "" {
+			return nil, fmt.Errorf("nat: source %s needs a file", cfg.Source)
+		}
+	}
+	return t, nil
+}
+
+// InPool reports whether an address is one the gateway translates to
+func (t *NATTable) InPool(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range t.pool {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Learn adds or refreshes a mapping. Mappings to addresses outside the pool are ignored, and so
+// is everything but TCP and UDP.
+func (t *NATTable) Learn(m NATMapping) bool {
+	m.Protocol = strings.ToUpper(m.Protocol)
+	if (m.Protocol != "TCP" && m.Protocol != "UDP") || !t.InPool(m.Public) {
+		return false
+	}
+	if _, err := netip.ParseAddr(m.Internal); err != nil {
+		return false
+	}
+	if m.Seen.IsZero() {
+		m.Seen = time.Now()
+	}
+	key := natKey{m.Protocol, m.Public, m.PublicPort}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.mappings[key]; !ok && len(t.mappings) >= maxNATMappings {
+		return false
+	}
+	t.mappings[key] = &m
+	return true
+}
+
+// Forget removes a mapping the gateway tore down
+func (t *NATTable) Forget(protocol, public string, port int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.mappings, natKey{strings.ToUpper(protocol), public, port})
+}
+
+// Translate returns the internal host behind a public address and port, if the table has it
+func (t *NATTable) Translate(protocol, ip string, port int) (string, int, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.mappings) == 0 {
+		return "", 0, false
+	}
+	m, ok := t.mappings[natKey{protocol, ip, port}]
+	if !ok || time.Since(m.Seen) > t.timeout {
+		return "", 0, false
+	}
+	return m.Internal, m.InternalPort, true
+}
+
+// Sweep drops mappings that timed out
+func (t *NATTable) Sweep() {
+	cutoff := time.Now().Add(-t.timeout)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, m := range t.mappings {
+		if m.Seen.Before(cutoff) {
+			delete(t.mappings, key)
+		}
+	}
+}
+
+// Mappings returns the live mappings ordered by public address and port
+func (t *NATTable) Mappings() []NATMapping {
+	cutoff := time.Now().Add(-t.timeout)
+	t.mu.RLock()
+	mappings := make([]NATMapping, 0, len(t.mappings))
+	for _, m := range t.mappings {
+		if !m.Seen.Before(cutoff) {
+			mappings = append(mappings, *m)
+		}
+	}
+	t.mu.RUnlock()
+	sort.Slice(mappings, func(i, j int) bool {
+		if mappings[i].Public != mappings[j].Public {
+			return mappings[i].Public < mappings[j].Public
+		}
+		if mappings[i].PublicPort != mappings[j].PublicPort {
+			return mappings[i].PublicPort < mappings[j].PublicPort
+		}
+		return mappings[i].Protocol < mappings[j].Protocol
+	})
+	return mappings
+}
+
+// Pool returns the pool as configured, normalized to CIDRs
+func (t *NATTable) Pool() []string {
+	pool := make([]string, len(t.pool))
+	for i, prefix := range t.pool {
+		pool[i] = prefix.String()
+	}
+	return pool
+}
+
+// Follow reads a translation source from path until stop is closed, like tail -F: lines
+// appended to the file are applied as they come, and a file that is truncated or replaced
+// (renamed over) is read again from the start. Expired mappings are swept as it goes.
+func (t *NATTable) Follow(path string, parse NATParser, stop <-chan struct{}) {
+	var file *os.File
+	var reader *bufio.Reader
+	var offset int64
+	var partial string
+	failing := false
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(natPollInterval)
+	defer ticker.Stop()
+	for {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			if !failing {
+				log.Printf("⚠️ NAT source %s: %v", path, err)
+				failing = true
+			}
+		default:
+			if file != nil {
+				current, statErr := file.Stat()
+				if statErr != nil || !os.SameFile(current, info) || info.Size() < offset {
+					file.Close()
+					file = nil
+				}
+			}
+			if file == nil {
+				if file, err = os.Open(path); err != nil {
+					if !failing {
+						log.Printf("⚠️ NAT source %s: %v", path, err)
+						failing = true
+					}
+					file = nil
+					break
+				}
+				reader, offset, partial = bufio.NewReader(file), 0, ""
+				if failing {
+					log.Printf("🔁 NAT source %s readable again", path)
+				}
+				failing = false
+			}
+			for {
+				line, err := reader.ReadString('\n')
+				offset += int64(len(line))
+				if err != nil {
+					// A line still being written is finished on a later poll
+					partial += line
+					if err != io.EOF {
+						log.Printf("⚠️ NAT source %s: %v", path, err)
+					}
+					break
+				}
+				line, partial = partial+line, ""
+				if mapping, teardown, ok := parse(line); ok {
+					if teardown {
+						t.Forget(mapping.Protocol, mapping.Public, mapping.PublicPort)
+					} else {
+						t.Learn(mapping)
+					}
+				}
+			}
+		}
+		t.Sweep()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ParseConntrackNAT reads a line of conntrack -L or conntrack -E output. The original tuple
+// comes first and the reply tuple second; a reply that isn't the mirror image of the original
+// is a translation. Source NAT maps the reply's destination back to the original source, and a
+// port forward maps the original destination to the reply's source.
+//
+//	[NEW] tcp 6 120 SYN_SENT src=10.0.0.5 dst=93.184.216.34 sport=51234 dport=443 [UNREPLIED] src=93.184.216.34 dst=203.0.113.1 sport=443 dport=40001
+func ParseConntrackNAT(line string) (NATMapping, bool, bool) {
+	fields := strings.Fields(line)
+	teardown := false
+	// Event tags, and the timestamp of conntrack -E -o timestamp
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "[") {
+		teardown = teardown || fields[0] == "[DESTROY]"
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return NATMapping{}, false, false
+	}
+	protocol := strings.ToUpper(fields[0])
+	if protocol != "TCP" && protocol != "UDP" {
+		return NATMapping{}, false, false
+	}
+
+	// Two tuples of src, dst, sport, dport, in order
+	var tuples [2]map[string]string
+	n := 0
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || (key != "src" && key != "dst" && key != "sport" && key != "dport") {
+			continue
+		}
+		if key == "src" && tuples[n] != nil && tuples[n]["src"] != "" {
+			if n == 1 {
+				break
+			}
+			n++
+		}
+		if tuples[n] == nil {
+			tuples[n] = make(map[string]string, 4)
+		}
+		tuples[n][key] = value
+	}
+	original, reply := tuples[0], tuples[1]
+	if original == nil || reply == nil {
+		return NATMapping{}, false, false
+	}
+	port := func(tuple map[string]string, key string) int {
+		p, _ := strconv.Atoi(tuple[key])
+		return p
+	}
+
+	mapping := NATMapping{Protocol: protocol}
+	switch {
+	case reply["dst"] != original["src"] || reply["dport"] != original["sport"]:
+		mapping.Internal, mapping.InternalPort = original["src"], port(original, "sport")
+		mapping.Public, mapping.PublicPort = reply["dst"], port(reply, "dport")
+	case reply["src"] != original["dst"] || reply["sport"] != original["dport"]:
+		mapping.Internal, mapping.InternalPort = reply["src"], port(reply, "sport")
+		mapping.Public, mapping.PublicPort = original["dst"], port(original, "dport")
+	default:
+		return NATMapping{}, false, false
+	}
+	return mapping, teardown, true
+}
+
+var asaTranslation = regexp.MustCompile(`(Built|Teardown) (?:dynamic|static) (TCP|UDP) translation from [^:\s]+:([0-9.]+)/(\d+) to [^:\s]+:([0-9.]+)/(\d+)`)
+
+// ParseASANAT reads Cisco ASA and FTD translation syslog messages (305011 and 305012)
+//
+//	%ASA-6-305011: Built dynamic TCP translation from inside:10.0.0.5/51234 to outside:203.0.113.1/40001
+func ParseASANAT(line string) (NATMapping, bool, bool) {
+	match := asaTranslation.FindStringSubmatch(line)
+	if match == nil {
+		return NATMapping{}, false, false
+	}
+	internalPort, _ := strconv.Atoi(match[4])
+	publicPort, _ := strconv.Atoi(match[6])
+	return NATMapping{
+		Protocol:     match[2],
+		Internal:     match[3],
+		InternalPort: internalPort,
+		Public:       match[5],
+		PublicPort:   publicPort,
+	}, match[1] == "Teardown", true
+}
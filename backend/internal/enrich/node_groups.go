@@ -0,0 +1,76 @@
+package enrich
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+// NodeGrouper assigns every IP a stable cluster identifier so all clients lay nodes out the same way.
+// Explicit CIDR rules win (longest prefix first); everything else falls back to its /24 (or /64).
+type NodeGrouper struct {
+	rules         []groupPrefix
+	autoPrefixV4  int
+	autoPrefixV6  int
+	externalGroup string
+}
+
+// NewNodeGrouper builds a grouper from config
+func NewNodeGrouper(cfg *config.NodeGrouping) (*NodeGrouper, error) {
+	g := &NodeGrouper{
+		autoPrefixV4:  cfg.AutoPrefixV4,
+		autoPrefixV6:  cfg.AutoPrefixV6,
+		externalGroup: cfg.ExternalGroup,
+	}
+	if g.autoPrefixV4 <= 0 || g.autoPrefixV4 > 32 {
+		g.autoPrefixV4 = 24
+	}
+	if g.autoPrefixV6 <= 0 || g.autoPrefixV6 > 128 {
+		g.autoPrefixV6 = 64
+	}
+
+	for _, rule := range cfg.Rules {
+		prefix, err := netip.ParsePrefix(rule.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("node grouping rule %q: invalid CIDR: %v", rule.Group, err)
+		}
+		if rule.Group == "" {
+			return nil, fmt.Errorf("node grouping rule for %s has no group", rule.CIDR)
+		}
+		g.rules = append(g.rules, groupPrefix{prefix: prefix.Masked(), name: rule.Group})
+	}
+	sort.SliceStable(g.rules, func(i, j int) bool {
+		return g.rules[i].prefix.Bits() > g.rules[j].prefix.Bits()
+	})
+	return g, nil
+}
+
+// Group returns the cluster identifier for an IP ("" if it can't be parsed)
+func (g *NodeGrouper) Group(ipStr string) string {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	for _, rule := range g.rules {
+		if rule.prefix.Contains(addr) {
+			return rule.name
+		}
+	}
+	if g.externalGroup != "" && IsExternalIP(ipStr) {
+		return g.externalGroup
+	}
+
+	bits := g.autoPrefixV4
+	if addr.Is6() {
+		bits = g.autoPrefixV6
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.String()
+}
@@ -0,0 +1,361 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reputation providers supported by ReputationCache
+const (
+	ProviderAbuseIPDB = "abuseipdb"
+	ProviderGreyNoise = "greynoise"
+)
+
+// Reputation is the score attached to an external IP (0 = clean, 100 = known bad)
+type Reputation struct {
+	IP             string    `json:"ip"`
+	Score          int       `json:"score"`
+	Provider       string    `json:"provider"`
+	Classification string    `json:"classification,omitempty"`
+	Reports        int       `json:"reports,omitempty"`
+	Country        string    `json:"country,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// ReputationConfig holds configuration for reputation lookups
+type ReputationConfig struct {
+	Provider       string        // "abuseipdb" or "greynoise"
+	APIKey         string        // Provider API key
+	TTL            time.Duration // How long a successful lookup stays cached
+	ErrorTTL       time.Duration // How long a failed lookup is cached before retrying
+	LookupsPerMin  int           // Upper bound on outbound API calls per minute
+	MaxEntries     int           // Cache size cap; oldest entries are evicted first
+	CacheFile      string        // Optional: persist cache across restarts
+	RequestTimeout time.Duration
+}
+
+// ReputationCache looks up external IPs against a reputation API in the background.
+// Packets never wait on a lookup: Observe queues unknown IPs and OnUpdate fires when a score arrives.
+type ReputationCache struct {
+	config   ReputationConfig
+	client   *http.Client
+	mu       sync.RWMutex
+	entries  map[string]*Reputation
+	pending  map[string]struct{}
+	queue    chan string
+	stopChan chan struct{}
+	dirty    bool
+
+	// OnUpdate is called from the lookup goroutine whenever a new score is cached
+	OnUpdate func(*Reputation)
+}
+
+// NewReputationCache creates a reputation cache; call Start to begin background lookups
+func NewReputationCache(config ReputationConfig) (*ReputationCache, error) {
+	switch config.Provider {
+	case ProviderAbuseIPDB, ProviderGreyNoise:
+	default:
+		return nil, fmt.Errorf("unknown reputation provider %q (expected %s or %s)", config.Provider, ProviderAbuseIPDB, ProviderGreyNoise)
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.ErrorTTL <= 0 {
+		config.ErrorTTL = 10 * time.Minute
+	}
+	if config.LookupsPerMin <= 0 {
+		config.LookupsPerMin = 30
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 100000
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = 5 * time.Second
+	}
+
+	return &ReputationCache{
+		config:   config,
+		client:   &http.Client{Timeout: config.RequestTimeout},
+		entries:  make(map[string]*Reputation),
+		pending:  make(map[string]struct{}),
+		queue:    make(chan string, 1024),
+		stopChan: make(chan struct{}),
+	}, nil
+}
+
+// Start loads the persisted cache (if configured) and begins the rate-limited lookup worker
+func (r *ReputationCache) Start() {
+	if r.config.CacheFile != "" {
+		if err := r.load(); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Reputation cache load failed: %v", err)
+		}
+	}
+	go r.lookupLoop()
+	log.Printf("🛡️ Reputation lookups enabled via %s (%d/min, TTL %s)", r.config.Provider, r.config.LookupsPerMin, r.config.TTL)
+}
+
+// Stop halts the lookup worker and flushes the cache to disk
+func (r *ReputationCache) Stop() {
+	close(r.stopChan)
+	r.save()
+}
+
+// Get returns the cached reputation for an IP, if present and not expired
+func (r *ReputationCache) Get(ip string) (*Reputation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rep, ok := r.entries[ip]
+	if !ok || r.expired(rep) {
+		return nil, false
+	}
+	return rep, true
+}
+
+// Observe returns the cached reputation for an IP and queues a lookup if it is external and unknown.
+// It never blocks: if the lookup queue is full the IP is simply tried again on a later sighting.
+func (r *ReputationCache) Observe(ip string) (*Reputation, bool) {
+	if rep, ok := r.Get(ip); ok {
+		return rep, true
+	}
+	if !IsExternalIP(ip) {
+		return nil, false
+	}
+
+	r.mu.Lock()
+	if _, queued := r.pending[ip]; queued {
+		r.mu.Unlock()
+		return nil, false
+	}
+	r.pending[ip] = struct{}{}
+	r.mu.Unlock()
+
+	select {
+	case r.queue <- ip:
+	default:
+		r.mu.Lock()
+		delete(r.pending, ip)
+		r.mu.Unlock()
+	}
+	return nil, false
+}
+
+func (r *ReputationCache) expired(rep *Reputation) bool {
+	ttl := r.config.TTL
+	if rep.Error != "" {
+		ttl = r.config.ErrorTTL
+	}
+	return time.Since(rep.CheckedAt) > ttl
+}
+
+// lookupLoop drains the queue at most LookupsPerMin times a minute
+func (r *ReputationCache) lookupLoop() {
+	limiter := time.NewTicker(time.Minute / time.Duration(r.config.LookupsPerMin))
+	defer limiter.Stop()
+	saveTicker := time.NewTicker(5 * time.Minute)
+	defer saveTicker.Stop()
+
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-saveTicker.C:
+			r.save()
+		case ip := <-r.queue:
+			select {
+			case <-limiter.C:
+			case <-r.stopChan:
+				return
+			}
+
+			rep := r.lookup(ip)
+			r.store(rep)
+			if r.OnUpdate != nil && rep.Error == "" {
+				r.OnUpdate(rep)
+			}
+		}
+	}
+}
+
+func (r *ReputationCache) store(rep *Reputation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, rep.IP)
+	if len(r.entries) >= r.config.MaxEntries {
+		r.evictOldestLocked()
+	}
+	r.entries[rep.IP] = rep
+	r.dirty = true
+}
+
+func (r *ReputationCache) evictOldestLocked() {
+	var oldestIP string
+	var oldest time.Time
+	for ip, rep := range r.entries {
+		if oldestIP == "" || rep.CheckedAt.Before(oldest) {
+			oldestIP = ip
+			oldest = rep.CheckedAt
+		}
+	}
+	delete(r.entries, oldestIP)
+}
+
+func (r *ReputationCache) lookup(ip string) *Reputation {
+	var rep *Reputation
+	var err error
+	switch r.config.Provider {
+	case ProviderAbuseIPDB:
+		rep, err = r.lookupAbuseIPDB(ip)
+	case ProviderGreyNoise:
+		rep, err = r.lookupGreyNoise(ip)
+	}
+	if err != nil {
+		log.Printf("Reputation lookup for %s failed: %v", ip, err)
+		rep = &Reputation{IP: ip, Error: err.Error()}
+	}
+	rep.IP = ip
+	rep.Provider = r.config.Provider
+	rep.CheckedAt = time.Now()
+	return rep
+}
+
+func (r *ReputationCache) lookupAbuseIPDB(ip string) (*Reputation, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.abuseipdb.com/api/v2/check?maxAgeInDays=90&ipAddress="+ip, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Key", r.config.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		Data struct {
+			AbuseConfidenceScore int    `json:"abuseConfidenceScore"`
+			TotalReports         int    `json:"totalReports"`
+			CountryCode          string `json:"countryCode"`
+		} `json:"data"`
+	}
+	if err := r.getJSON(req, &body); err != nil {
+		return nil, err
+	}
+	return &Reputation{
+		Score:   body.Data.AbuseConfidenceScore,
+		Reports: body.Data.TotalReports,
+		Country: body.Data.CountryCode,
+	}, nil
+}
+
+func (r *ReputationCache) lookupGreyNoise(ip string) (*Reputation, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.greynoise.io/v3/community/"+ip, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("key", r.config.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		Noise          bool   `json:"noise"`
+		Riot           bool   `json:"riot"`
+		Classification string `json:"classification"`
+	}
+	if err := r.getJSON(req, &body); err != nil {
+		return nil, err
+	}
+
+	score := 0
+	switch {
+	case body.Classification == "malicious":
+		score = 100
+	case body.Riot || body.Classification == "benign":
+		score = 0
+	case body.Noise:
+		score = 50
+	}
+	return &Reputation{Score: score, Classification: body.Classification}, nil
+}
+
+func (r *ReputationCache) getJSON(req *http.Request, out interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// GreyNoise answers 404 for IPs it has never seen; that is a clean result, not an error
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (r *ReputationCache) load() error {
+	data, err := os.ReadFile(r.config.CacheFile)
+	if err != nil {
+		return err
+	}
+	var reps []*Reputation
+	if err := json.Unmarshal(data, &reps); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rep := range reps {
+		if !r.expired(rep) {
+			r.entries[rep.IP] = rep
+		}
+	}
+	log.Printf("🛡️ Loaded %d cached reputation scores from %s", len(r.entries), r.config.CacheFile)
+	return nil
+}
+
+func (r *ReputationCache) save() {
+	if r.config.CacheFile == "" {
+		return
+	}
+
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return
+	}
+	reps := make([]*Reputation, 0, len(r.entries))
+	for _, rep := range r.entries {
+		if rep.Error == "" {
+			reps = append(reps, rep)
+		}
+	}
+	r.dirty = false
+	r.mu.Unlock()
+
+	data, err := json.Marshal(reps)
+	if err != nil {
+		return
+	}
+	tmp := r.config.CacheFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("⚠️ Reputation cache save failed: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, r.config.CacheFile); err != nil {
+		log.Printf("⚠️ Reputation cache save failed: %v", err)
+	}
+}
+
+// IsExternalIP reports whether an address is publicly routable (not private, loopback, link-local or multicast)
+func IsExternalIP(ipStr string) bool {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
@@ -0,0 +1,252 @@
+// Package health runs the config file's HTTP, TCP and DNS checks against key servers and keeps
+// each one's up or down state.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+// States a check can be in
+const (
+	StateUnknown = "unknown" // not checked yet
+	StateUp      = "up"
+	StateDown    = "down"
+)
+
+// Defaults for the fields a check leaves out
+const (
+	DefaultInterval  = 30 * time.Second
+	DefaultTimeout   = 5 * time.Second
+	DefaultFailAfter = 2
+)
+
+// Status is where a check stands
+type Status struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Host      string    `json:"host"`
+	State     string    `json:"state"`
+	Since     time.Time `json:"since"` // when it entered the state
+	LastCheck time.Time `json:"last_check,omitempty"`
+	LatencyMs float64   `json:"latency_ms,omitempty"` // the last successful check
+	Error     string    `json:"error,omitempty"`      // why the last check failed
+}
+
+type check struct {
+	config.HealthCheck
+	interval, timeout time.Duration
+	failAfter         int
+	url               string
+	failures          int
+}
+
+// Monitor runs the checks and tells OnChange whenever one goes up or down
+type Monitor struct {
+	checks []*check
+	client *http.Client
+
+	// OnChange is called with a check's status when its state changes
+	OnChange func(Status)
+
+	mu       sync.RWMutex
+	statuses map[string]*Status
+}
+
+// NewMonitor prepares the checks; none runs until Run
+func NewMonitor(checks []config.HealthCheck) (*Monitor, error) {
+	m := &Monitor{
+		statuses: make(map[string]*Status),
+		// A check is of the server itself: redirects elsewhere don't count
+		client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+	now := time.Now()
+	for _, cfg := range checks {
+		c := &check{
+			HealthCheck: cfg,
+			interval:    cfg.Interval.Duration,
+			timeout:     cfg.Timeout.Duration,
+			failAfter:   cfg.FailAfter,
+		}
+		if c.interval <= 0 {
+			c.interval = DefaultInterval
+		}
+		if c.timeout <= 0 {
+			c.timeout = DefaultTimeout
+		}
+		if c.failAfter <= 0 {
+			c.failAfter = DefaultFailAfter
+		}
+		switch cfg.Kind {
+		case "http":
+			c.url = cfg.URL
+			if c.url == "" {
+				port := cfg.Port
+				if port == 0 {
+					port = 80
+				}
+				c.url = "http://" + net.JoinHostPort(cfg.Host, strconv.Itoa(port)) + "/"
+			}
+			if _, err := http.NewRequest(http.MethodGet, c.url, nil); err != nil {
+				return nil, fmt.Errorf("health check %s: %v", cfg.Name, err)
+			}
+		case "dns":
+			if c.Port == 0 {
+				c.Port = 53
+			}
+		}
+		m.checks = append(m.checks, c)
+		m.statuses[cfg.Name] = &Status{Name: cfg.Name, Kind: cfg.Kind, Host: cfg.Host, State: StateUnknown, Since: now}
+	}
+	return m, nil
+}
+
+// Run checks every server on its interval until stop is closed
+func (m *Monitor) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, c := range m.checks {
+		wg.Add(1)
+		go func(c *check) {
+			defer wg.Done()
+			ticker := time.NewTicker(c.interval)
+			defer ticker.Stop()
+			for {
+				m.run(c)
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+// Statuses returns every check's status ordered by name
+func (m *Monitor) Statuses() []Status {
+	m.mu.RLock()
+	statuses := make([]Status, 0, len(m.statuses))
+	for _, status := range m.statuses {
+		statuses = append(statuses, *status)
+	}
+	m.mu.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// ForHost returns the statuses of the checks on one server, ordered by name
+func (m *Monitor) ForHost(ip string) []Status {
+	var statuses []Status
+	for _, status := range m.Statuses() {
+		if status.Host == ip {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// run checks once and records the outcome. A success brings the check up straight away; it goes
+// down after fail_after failures in a row.
+func (m *Monitor) run(c *check) {
+	start := time.Now()
+	err := c.probe(m.client)
+	now := time.Now()
+
+	m.mu.Lock()
+	status := m.statuses[c.Name]
+	status.LastCheck = now
+	state := status.State
+	if err == nil {
+		c.failures = 0
+		status.Error, status.LatencyMs = "", float64(now.Sub(start).Microseconds())/1000
+		state = StateUp
+	} else {
+		c.failures++
+		status.Error = err.Error()
+		if c.failures >= c.failAfter {
+			state = StateDown
+		}
+	}
+	changed := state != status.State
+	if changed {
+		status.State, status.Since = state, now
+	}
+	snapshot := *status
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if state == StateDown {
+		log.Printf("🩺 Health check %s (%s %s) is down: %v", c.Name, c.Kind, c.Host, err)
+	} else {
+		log.Printf("🩺 Health check %s (%s %s) is up", c.Name, c.Kind, c.Host)
+	}
+	if m.OnChange != nil {
+		m.OnChange(snapshot)
+	}
+}
+
+// probe runs the check once
+func (c *check) probe(client *http.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	switch c.Kind {
+	case "tcp":
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.Host, strconv.Itoa(c.Port)))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case "http":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if c.ExpectStatus != 0 && resp.StatusCode != c.ExpectStatus {
+			return fmt.Errorf("status %d, expected %d", resp.StatusCode, c.ExpectStatus)
+		}
+		if c.ExpectStatus == 0 && resp.StatusCode >= 400 {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+
+	case "dns":
+		server := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+		addrs, err := resolver.LookupHost(ctx, c.Query)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no addresses for %s", c.Query)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown kind %q", c.Kind)
+}
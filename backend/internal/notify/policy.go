@@ -0,0 +1,301 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+const (
+	// escalationTick is how often open alerts are checked for escalation
+	escalationTick = 10 * time.Second
+	// openAlertTTL is how long an alert stays open once it stops being raised, unless it is
+	// still escalating
+	openAlertTTL = time.Hour
+	// maxOpenAlerts bounds the open alerts; new ones are not tracked while it is full
+	maxOpenAlerts = 10000
+)
+
+// Severity levels, lowest first
+var severities = map[string]int{"info": 0, "warning": 1, "critical": 2}
+
+// defaultSeverity is the severity of alerts no policy matches
+const defaultSeverity = "warning"
+
+// policy is a validated config.AlertPolicy
+type policy struct {
+	match          map[string]bool // nil: every alert
+	severity       string
+	dedup          []string // nil: type, kind and subject
+	cooldown       time.Duration
+	suppress       bool
+	escalate       time.Duration
+	maxEscalations int
+}
+
+// defaultPolicy applies to alerts no configured policy matches
+var defaultPolicy = &policy{severity: defaultSeverity}
+
+func newPolicies(cfgs []config.AlertPolicy) ([]*policy, error) {
+	policies := make([]*policy, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		if len(cfg.Match) == 0 {
+			return nil, fmt.Errorf("alert policy %d: match lists no alerts", i+1)
+		}
+		p := &policy{
+			severity:       cfg.Severity,
+			dedup:          cfg.DedupKey,
+			cooldown:       cfg.Cooldown.Duration,
+			suppress:       cfg.Suppress,
+			escalate:       cfg.EscalateAfter.Duration,
+			maxEscalations: cfg.MaxEscalations,
+		}
+		if p.severity == "" {
+			p.severity = defaultSeverity
+		}
+		if _, ok := severities[p.severity]; !ok {
+			return nil, fmt.Errorf("alert policy %d: severity must be info, warning or critical", i+1)
+		}
+		if p.cooldown < 0 || p.escalate < 0 || p.maxEscalations < 0 {
+			return nil, fmt.Errorf("alert policy %d: cooldown, escalate_after and max_escalations can't be negative", i+1)
+		}
+		if p.escalate > 0 && p.escalate < time.Minute {
+			return nil, fmt.Errorf("alert policy %d: escalate_after must be at least 1m", i+1)
+		}
+		for _, selector := range cfg.Match {
+			if selector == "*" {
+				p.match = nil
+				break
+			}
+			if p.match == nil {
+				p.match = make(map[string]bool, len(cfg.Match))
+			}
+			p.match[selector] = true
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (p *policy) matches(alert *Alert) bool {
+	return p.match == nil || p.match[alert.Type] || p.match[alert.Type+":"+alert.Kind]
+}
+
+// key identifies repeats of an alert under the policy's dedup fields
+func (p *policy) key(alert *Alert) string {
+	if len(p.dedup) == 0 {
+		return alert.key()
+	}
+	parts := make([]string, len(p.dedup))
+	for i, field := range p.dedup {
+		switch field {
+		case "type":
+			parts[i] = alert.Type
+		case "kind":
+			parts[i] = alert.Kind
+		case "subject":
+			parts[i] = alert.Subject
+		case "room":
+			parts[i] = alert.Room
+		default:
+			if value, ok := alert.Details[field]; ok {
+				parts[i] = fmt.Sprint(value)
+			}
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// policyFor returns the first policy matching an alert
+func (n *Notifier) policyFor(alert *Alert) *policy {
+	for _, p := range n.policies {
+		if p.matches(alert) {
+			return p
+		}
+	}
+	return defaultPolicy
+}
+
+// OpenAlert is an alert raised lately, acknowledged or not. Escalating ones are posted again
+// until someone acknowledges them.
+type OpenAlert struct {
+	Key         string    `json:"key"` // its dedup key, for /api/alerts/ack
+	Type        string    `json:"type"`
+	Kind        string    `json:"kind,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Room        string    `json:"room,omitempty"`
+	Severity    string    `json:"severity"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Count       int       `json:"count"` // times raised by any session, repeats within the cooldown included
+	Escalating  bool      `json:"escalating"`
+	Escalations int       `json:"escalations"` // repeat posts so far
+	Acked       bool      `json:"acked"`
+	AckedAt     time.Time `json:"acked_at,omitempty"`
+	AckedBy     string    `json:"acked_by,omitempty"`
+
+	alert      Alert
+	policy     *policy
+	lastPosted time.Time
+}
+
+// track records an alert handed to the webhooks as open; a repeat of an acknowledged one after
+// its cooldown opens it again. Callers hold mu.
+func (n *Notifier) track(alert Alert, p *policy, now time.Time) {
+	open, ok := n.open[alert.Key]
+	if ok && open.Acked && now.Sub(open.LastSeen) >= n.cooldown(p) {
+		ok = false
+	}
+	if !ok {
+		if len(n.open) >= maxOpenAlerts {
+			n.expire(now)
+			if len(n.open) >= maxOpenAlerts {
+				return
+			}
+		}
+		open = &OpenAlert{Key: alert.Key, FirstSeen: now, lastPosted: now}
+		n.open[alert.Key] = open
+	}
+	open.Type, open.Kind, open.Subject, open.Room = alert.Type, alert.Kind, alert.Subject, alert.Room
+	open.Severity = alert.Severity
+	open.Escalating = p.escalate > 0
+	open.LastSeen = now
+	open.Count++
+	open.alert, open.policy = alert, p
+}
+
+// cooldown is the longest a policy's repeats can stay quiet on any webhook
+func (n *Notifier) cooldown(p *policy) time.Duration {
+	if p.cooldown > 0 {
+		return p.cooldown
+	}
+	longest := defaultCooldown
+	for _, h := range n.hooks {
+		if h.config.Cooldown.Duration > longest {
+			longest = h.config.Cooldown.Duration
+		}
+	}
+	return longest
+}
+
+// escalating reports whether an open alert is still due repeat posts
+func (o *OpenAlert) escalating() bool {
+	p := o.policy
+	return !o.Acked && p.escalate > 0 && (p.maxEscalations == 0 || o.Escalations < p.maxEscalations)
+}
+
+// expire forgets alerts no longer raised or escalating; callers hold mu
+func (n *Notifier) expire(now time.Time) {
+	for key, open := range n.open {
+		if !open.escalating() && now.Sub(open.LastSeen) > openAlertTTL {
+			delete(n.open, key)
+		}
+	}
+}
+
+// escalate posts unacknowledged escalating alerts again once their escalate_after is up,
+// skipping the webhooks' cooldowns
+func (n *Notifier) escalate(now time.Time) {
+	n.mu.Lock()
+	var due []Alert
+	for _, open := range n.open {
+		if !open.escalating() || now.Sub(open.lastPosted) < open.policy.escalate {
+			continue
+		}
+		open.Escalations++
+		open.lastPosted = now
+		alert := open.alert
+		alert.Escalation = open.Escalations
+		due = append(due, alert)
+	}
+	n.expire(now)
+	n.mu.Unlock()
+
+	for _, alert := range due {
+		for _, h := range n.hooks {
+			if h.wants(&alert) {
+				h.enqueue(alert, true)
+			}
+		}
+	}
+}
+
+func (n *Notifier) runEscalations() {
+	ticker := time.NewTicker(escalationTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.done:
+			return
+		case now := <-ticker.C:
+			n.escalate(now)
+		}
+	}
+}
+
+// Ack acknowledges the open alert with the given key, which stops its escalation
+func (n *Notifier) Ack(key, by string) (OpenAlert, error) {
+	if n == nil {
+		return OpenAlert{}, fmt.Errorf("no open alert %q", key)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	open, ok := n.open[key]
+	if !ok {
+		return OpenAlert{}, fmt.Errorf("no open alert %q", key)
+	}
+	if !open.Acked {
+		open.Acked, open.AckedAt, open.AckedBy = true, time.Now(), by
+	}
+	return *open, nil
+}
+
+// Open returns the open alerts: unacknowledged first, then the most severe, then the latest
+func (n *Notifier) Open() []OpenAlert {
+	if n == nil {
+		return []OpenAlert{}
+	}
+	n.mu.Lock()
+	alerts := make([]OpenAlert, 0, len(n.open))
+	for _, open := range n.open {
+		alerts = append(alerts, *open)
+	}
+	n.mu.Unlock()
+	sort.Slice(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if a.Acked != b.Acked {
+			return !a.Acked
+		}
+		if severities[a.Severity] != severities[b.Severity] {
+			return severities[a.Severity] > severities[b.Severity]
+		}
+		if !a.LastSeen.Equal(b.LastSeen) {
+			return a.LastSeen.After(b.LastSeen)
+		}
+		return a.Key < b.Key
+	})
+	return alerts
+}
+
+// Adopt takes over the open alerts of the notifier being replaced on a reload, under this
+// notifier's policies
+func (n *Notifier) Adopt(old *Notifier) {
+	if n == nil || old == nil {
+		return
+	}
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, open := range old.open {
+		adopted := *open
+		adopted.policy = n.policyFor(&adopted.alert)
+		adopted.Severity = adopted.policy.severity
+		adopted.alert.Severity = adopted.policy.severity
+		adopted.Escalating = adopted.policy.escalate > 0
+		n.open[key] = &adopted
+	}
+}
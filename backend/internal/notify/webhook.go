@@ -0,0 +1,396 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+// DefaultTemplate renders an alert when a webhook has no template of its own
+const DefaultTemplate = `vibes {{.Type}}{{with .Kind}}: {{.}}{{end}}{{with .Subject}} on {{.}}{{end}}{{with .Room}} (room {{.}}){{end}}{{with index .Details "message"}}: {{.}}{{end}}{{if .Escalation}} (unacknowledged, reminder {{.Escalation}}){{end}}`
+
+const (
+	defaultRatePerMinute = 10
+	defaultCooldown      = 5 * time.Minute
+	queueSize            = 256
+	postTimeout          = 10 * time.Second
+	maxCooldownKeys      = 10000
+)
+
+// Alert is one alert as the webhooks see it. Templates get it as their data.
+type Alert struct {
+	Type    string // message type: "tcp_anomaly", "sensor_alert", ...
+	Kind    string // "syn_flood", "sensor_silent", ...; empty when the type has no kinds
+	Subject string // what the alert is about: an IP, an interface or a sensor
+	Room    string // the room whose traffic raised it; empty for server-wide alerts
+	Time    time.Time
+	Details map[string]interface{} // every field of the alert message
+
+	// Set by the notifier from the alert's policy
+	Severity   string // info, warning or critical
+	Key        string // identifies repeats: the policy's dedup key
+	Escalation int    // 0 for the first post, n for the nth repeat of an unacknowledged alert
+
+	cooldown time.Duration // the policy's, overriding the webhook's when set
+}
+
+// key identifies repeats of an alert, whichever room's session raised them
+func (a *Alert) key() string {
+	return a.Type + ":" + a.Kind + ":" + a.Subject
+}
+
+// FromMessage builds an alert from the JSON of the message sent to clients
+func FromMessage(msg []byte, room string) (Alert, error) {
+	var details map[string]interface{}
+	if err := json.Unmarshal(msg, &details); err != nil {
+		return Alert{}, err
+	}
+	alert := Alert{Room: room, Time: time.Now(), Details: details}
+	alert.Type, _ = details["type"].(string)
+	alert.Kind, _ = details["kind"].(string)
+	for _, field := range []string{"ip", "interface", "sensor"} {
+		if subject, ok := details[field].(string); ok && subject != "" {
+			alert.Subject = subject
+			break
+		}
+	}
+	if ms, ok := details["timestamp"].(float64); ok {
+		alert.Time = time.UnixMilli(int64(ms))
+	}
+	return alert, nil
+}
+
+// HookStatus reports what a webhook has done since the server started
+type HookStatus struct {
+	Name       string    `json:"name"`
+	Format     string    `json:"format"`
+	Alerts     []string  `json:"alerts"`
+	Sent       int64     `json:"sent"`
+	Failed     int64     `json:"failed"`
+	Suppressed int64     `json:"suppressed"` // rate limited or queued behind a slow endpoint
+	LastSent   time.Time `json:"last_sent,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// hook is one configured webhook with its own queue, so a slow endpoint holds up nobody else
+type hook struct {
+	config config.Webhook
+	alerts map[string]bool // nil: every alert
+	text   *template.Template
+	queue  chan Alert
+	client *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // by alert key, for the cooldown
+	tokens   float64
+	refilled time.Time
+	unsent   int64 // suppressed since the last post; mentioned in the next one
+	status   HookStatus
+}
+
+// Notifier posts alerts to the configured webhooks. Alerts are shaped by the alert policies,
+// then filtered, deduplicated and rate limited per webhook; posting happens in the background
+// and never blocks the caller. Posted alerts stay open until acknowledged or quiet for an hour.
+type Notifier struct {
+	hooks    []*hook
+	policies []*policy
+	done     chan struct{}
+
+	mu   sync.Mutex
+	open map[string]*OpenAlert // by key
+}
+
+// New validates the webhook configs and alert policies and starts a sender for each webhook. It
+// returns nil when there are no webhooks; a nil Notifier ignores every alert.
+func New(webhooks []config.Webhook, policies []config.AlertPolicy) (*Notifier, error) {
+	compiled, err := newPolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+	n := &Notifier{policies: compiled, done: make(chan struct{}), open: make(map[string]*OpenAlert)}
+	names := make(map[string]bool)
+	for _, cfg := range webhooks {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("webhook without a name")
+		}
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("webhook %q: duplicate name", cfg.Name)
+		}
+		names[cfg.Name] = true
+		if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %q: url must be an http(s) URL", cfg.Name)
+		}
+		switch cfg.Format {
+		case "":
+			cfg.Format = "json"
+		case "json", "slack", "mattermost":
+		default:
+			return nil, fmt.Errorf("webhook %q: format must be slack, mattermost or json", cfg.Name)
+		}
+		if cfg.Template == "" {
+			cfg.Template = DefaultTemplate
+		}
+		text, err := template.New(cfg.Name).Option("missingkey=zero").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q: template: %v", cfg.Name, err)
+		}
+		if cfg.RatePerMinute <= 0 {
+			cfg.RatePerMinute = defaultRatePerMinute
+		}
+		if cfg.Cooldown.Duration <= 0 {
+			cfg.Cooldown.Duration = defaultCooldown
+		}
+		if cfg.MinSeverity == "" {
+			cfg.MinSeverity = "info"
+		}
+		if _, ok := severities[cfg.MinSeverity]; !ok {
+			return nil, fmt.Errorf("webhook %q: min_severity must be info, warning or critical", cfg.Name)
+		}
+
+		h := &hook{
+			config:   cfg,
+			text:     text,
+			queue:    make(chan Alert, queueSize),
+			client:   &http.Client{Timeout: postTimeout},
+			lastSent: make(map[string]time.Time),
+			tokens:   float64(cfg.RatePerMinute),
+			refilled: time.Now(),
+			status:   HookStatus{Name: cfg.Name, Format: cfg.Format, Alerts: cfg.Alerts},
+		}
+		if len(cfg.Alerts) > 0 {
+			h.alerts = make(map[string]bool, len(cfg.Alerts))
+			for _, selector := range cfg.Alerts {
+				h.alerts[selector] = true
+			}
+		}
+		n.hooks = append(n.hooks, h)
+	}
+	for _, h := range n.hooks {
+		go h.run(n.done)
+	}
+	go n.runEscalations()
+	return n, nil
+}
+
+// Stop ends the senders once their current post is done; queued alerts are dropped. Alerts
+// handed to a stopped notifier are counted as suppressed.
+func (n *Notifier) Stop() {
+	if n != nil {
+		close(n.done)
+	}
+}
+
+// Notify hands an alert to every webhook that wants it, unless its policy suppresses it
+func (n *Notifier) Notify(alert Alert) {
+	if n == nil {
+		return
+	}
+	p := n.policyFor(&alert)
+	alert.Severity, alert.Key, alert.cooldown = p.severity, p.key(&alert), p.cooldown
+	if p.suppress {
+		return
+	}
+	n.mu.Lock()
+	n.track(alert, p, time.Now())
+	n.mu.Unlock()
+	for _, h := range n.hooks {
+		if h.wants(&alert) {
+			h.enqueue(alert, false)
+		}
+	}
+}
+
+// Test sends a test alert through the named webhook, ignoring its alert filter and cooldown
+func (n *Notifier) Test(name string) error {
+	if n != nil {
+		for _, h := range n.hooks {
+			if h.config.Name == name {
+				h.enqueue(Alert{Type: "webhook_test", Subject: name, Severity: "info", Time: time.Now(), Details: map[string]interface{}{
+					"type":    "webhook_test",
+					"message": "test notification",
+				}}, true)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no webhook named %q", name)
+}
+
+// Status reports every webhook's counters
+func (n *Notifier) Status() []HookStatus {
+	if n == nil {
+		return []HookStatus{}
+	}
+	statuses := make([]HookStatus, 0, len(n.hooks))
+	for _, h := range n.hooks {
+		h.mu.Lock()
+		statuses = append(statuses, h.status)
+		h.mu.Unlock()
+	}
+	return statuses
+}
+
+func (h *hook) wants(alert *Alert) bool {
+	if severities[alert.Severity] < severities[h.config.MinSeverity] {
+		return false
+	}
+	return h.alerts == nil || h.alerts[alert.Type] || h.alerts[alert.Type+":"+alert.Kind]
+}
+
+// enqueue queues an alert unless it repeats one sent within the cooldown. Every session
+// watching the same traffic raises the same alert, so this is also what keeps one alert
+// from being posted once per session. Tests and escalations are forced through.
+func (h *hook) enqueue(alert Alert, force bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if !force {
+		cooldown := h.config.Cooldown.Duration
+		if alert.cooldown > 0 {
+			cooldown = alert.cooldown
+		}
+		if at, ok := h.lastSent[alert.Key]; ok && now.Sub(at) < cooldown {
+			return
+		}
+		if len(h.lastSent) >= maxCooldownKeys {
+			for k, at := range h.lastSent {
+				if now.Sub(at) >= cooldown {
+					delete(h.lastSent, k)
+				}
+			}
+		}
+		h.lastSent[alert.Key] = now
+	}
+	select {
+	case h.queue <- alert:
+	default:
+		h.suppress()
+	}
+}
+
+// suppress counts an alert that won't be posted; callers hold mu
+func (h *hook) suppress() {
+	h.status.Suppressed++
+	h.unsent++
+}
+
+// take spends a token of the rate limit, refilling RatePerMinute tokens a minute
+func (h *hook) take() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	rate := float64(h.config.RatePerMinute)
+	h.tokens += now.Sub(h.refilled).Minutes() * rate
+	if h.tokens > rate {
+		h.tokens = rate
+	}
+	h.refilled = now
+	if h.tokens < 1 {
+		h.suppress()
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+func (h *hook) run(done <-chan struct{}) {
+	for {
+		var alert Alert
+		select {
+		case alert = <-h.queue:
+		case <-done:
+			return
+		}
+		if !h.take() {
+			continue
+		}
+		h.mu.Lock()
+		unsent := h.unsent
+		h.unsent = 0
+		h.mu.Unlock()
+
+		err := h.post(alert, unsent)
+
+		h.mu.Lock()
+		if err != nil {
+			h.status.Failed++
+			h.status.LastError = err.Error()
+			h.unsent += unsent
+		} else {
+			h.status.Sent++
+			h.status.LastSent = time.Now()
+		}
+		h.mu.Unlock()
+		if err != nil {
+			log.Printf("⚠️ Webhook %s: %v", h.config.Name, err)
+		}
+	}
+}
+
+// post renders an alert in the webhook's format and sends it
+func (h *hook) post(alert Alert, unsent int64) error {
+	var text strings.Builder
+	if err := h.text.Execute(&text, alert); err != nil {
+		return fmt.Errorf("template: %v", err)
+	}
+	if unsent > 0 {
+		fmt.Fprintf(&text, " (%d more alerts suppressed)", unsent)
+	}
+
+	var payload interface{}
+	switch h.config.Format {
+	case "slack":
+		payload = map[string]string{"text": text.String()}
+	case "mattermost":
+		payload = map[string]string{"text": text.String(), "username": "vibes"}
+	default:
+		payload = map[string]interface{}{
+			"type":       alert.Type,
+			"kind":       alert.Kind,
+			"subject":    alert.Subject,
+			"room":       alert.Room,
+			"severity":   alert.Severity,
+			"key":        alert.Key,
+			"escalation": alert.Escalation,
+			"timestamp":  alert.Time.UnixMilli(),
+			"text":       text.String(),
+			"suppressed": unsent,
+			"details":    alert.Details,
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range h.config.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// The URL may hold a secret token (Slack, Mattermost); report the host only
+		return fmt.Errorf("posting to %s failed", req.URL.Host)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
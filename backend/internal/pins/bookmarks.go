@@ -0,0 +1,149 @@
+package pins
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBookmarkDescription bounds a bookmark's description
+const MaxBookmarkDescription = 500
+
+// Bookmark marks a moment worth coming back to, with the view the room had at the time
+type Bookmark struct {
+	ID          string      `json:"id"`
+	Room        string      `json:"room"`
+	Time        time.Time   `json:"time"` // live time, or the playback position in a time window
+	Description string      `json:"description,omitempty"`
+	Preset      string      `json:"preset,omitempty"`
+	Pins        []string    `json:"pins"`
+	Filter      *ViewFilter `json:"filter,omitempty"`
+	SampleRate  float64     `json:"sample_rate"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CreatedBy   string      `json:"created_by,omitempty"` // remote address of whoever added it
+}
+
+// BookmarkStore keeps bookmarks in memory and persists them as a JSON file
+type BookmarkStore struct {
+	mu        sync.RWMutex
+	path      string
+	bookmarks map[string]*Bookmark
+}
+
+// NewBookmarkStore loads bookmarks from path (missing file = no bookmarks)
+func NewBookmarkStore(path string) (*BookmarkStore, error) {
+	store := &BookmarkStore{
+		path:      path,
+		bookmarks: make(map[string]*Bookmark),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bookmarks []*Bookmark
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("parsing bookmarks %s: %v", path, err)
+	}
+	for _, bookmark := range bookmarks {
+		if bookmark.ID == "" {
+			continue
+		}
+		store.bookmarks[bookmark.ID] = bookmark
+	}
+	log.Printf("🔖 Loaded %d bookmarks from %s", len(store.bookmarks), path)
+	return store, nil
+}
+
+// Add stores a new bookmark under a fresh ID and persists the store
+func (s *BookmarkStore) Add(bookmark *Bookmark) error {
+	bookmark.Description = strings.TrimSpace(bookmark.Description)
+	if len(bookmark.Description) > MaxBookmarkDescription {
+		return fmt.Errorf("description is longer than %d bytes", MaxBookmarkDescription)
+	}
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	bookmark.ID = hex.EncodeToString(id)
+	bookmark.CreatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bookmarks[bookmark.ID] = bookmark
+	return s.saveLocked()
+}
+
+// Get returns a bookmark by ID
+func (s *BookmarkStore) Get(id string) (*Bookmark, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bookmark, ok := s.bookmarks[id]
+	return bookmark, ok
+}
+
+// List returns a room's bookmarks, or every bookmark for an empty room, ordered by time
+func (s *BookmarkStore) List(room string) []*Bookmark {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bookmarks := make([]*Bookmark, 0, len(s.bookmarks))
+	for _, bookmark := range s.sortedLocked() {
+		if room == "" || bookmark.Room == room {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	return bookmarks
+}
+
+// Delete removes a bookmark; it reports whether the ID was present
+func (s *BookmarkStore) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.bookmarks[id]; !ok {
+		return false, nil
+	}
+	delete(s.bookmarks, id)
+	return true, s.saveLocked()
+}
+
+func (s *BookmarkStore) sortedLocked() []*Bookmark {
+	bookmarks := make([]*Bookmark, 0, len(s.bookmarks))
+	for _, bookmark := range s.bookmarks {
+		bookmarks = append(bookmarks, bookmark)
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		if !bookmarks[i].Time.Equal(bookmarks[j].Time) {
+			return bookmarks[i].Time.Before(bookmarks[j].Time)
+		}
+		return bookmarks[i].ID < bookmarks[j].ID
+	})
+	return bookmarks
+}
+
+func (s *BookmarkStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,155 @@
+package pins
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// maxBPFPrefixes bounds the prefixes one address range expands to; longer ranges are not worth
+// pushing down to the capture
+const maxBPFPrefixes = 64
+
+// BPF compiles the rules into a BPF expression that captures every packet one of them would
+// match, or "" when there are no rules. ok is false when a rule can't be expressed: hostname pins
+// depend on names resolved as traffic flows, and very fragmented ranges would bloat the filter.
+// IPv6 rules are left out, since live capture only takes IPv4.
+func (s *RuleSet) BPF() (expr string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var terms []string
+	for _, pin := range s.rules {
+		term, ok := pin.bpf()
+		if !ok {
+			return "", false
+		}
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return orTerms(terms), true
+}
+
+func (p Pin) bpf() (string, bool) {
+	switch {
+	case p.addr != nil:
+		if !p.addr.start.Is4() {
+			return "", true
+		}
+		return rangeBPF(p.addr.start, p.addr.end)
+	case p.host != "":
+		return "", false
+	}
+	term := protocolBPF(p.protocol)
+	if p.portLow != 0 {
+		ports := portsBPF(p.portLow, p.portHigh)
+		if p.protocol != "" {
+			ports = strings.ToLower(p.protocol) + " " + ports
+		}
+		term = ports
+	}
+	return term, true
+}
+
+// BPF compiles the filter into a BPF expression that captures every packet it passes, or "" for a
+// view that passes everything; ok is false when it can't be expressed
+func (v *View) BPF() (expr string, ok bool) {
+	if v == nil {
+		return "", true
+	}
+	var clauses []string
+	if len(v.protocols) > 0 {
+		var terms []string
+		for _, protocol := range []string{"TCP", "UDP", "ICMP", "OTHER"} {
+			if v.protocols[protocol] {
+				terms = append(terms, protocolBPF(protocol))
+			}
+		}
+		clauses = append(clauses, orTerms(terms))
+	}
+	if len(v.ports) > 0 {
+		ports := make([]int, 0, len(v.ports))
+		for port := range v.ports {
+			ports = append(ports, port)
+		}
+		sort.Ints(ports)
+		var terms []string
+		for _, port := range ports {
+			terms = append(terms, portsBPF(port, port))
+		}
+		clauses = append(clauses, orTerms(terms))
+	}
+	if len(v.hosts) > 0 {
+		var terms []string
+		for _, rule := range v.hosts {
+			if !rule.start.Is4() {
+				continue
+			}
+			term, ok := rangeBPF(rule.start, rule.end)
+			if !ok {
+				return "", false
+			}
+			terms = append(terms, term)
+		}
+		if len(terms) == 0 {
+			return "", false // only IPv6 hosts: nothing live capture takes passes
+		}
+		clauses = append(clauses, orTerms(terms))
+	}
+	if len(clauses) > 1 {
+		return "(" + strings.Join(clauses, ") and (") + ")", true
+	}
+	return strings.Join(clauses, ""), true
+}
+
+func protocolBPF(protocol string) string {
+	if protocol == "OTHER" {
+		return "not (tcp or udp or icmp)"
+	}
+	return strings.ToLower(protocol)
+}
+
+func portsBPF(low, high int) string {
+	if low == high {
+		return fmt.Sprintf("port %d", low)
+	}
+	return fmt.Sprintf("portrange %d-%d", low, high)
+}
+
+// rangeBPF covers an inclusive IPv4 range with the fewest prefixes
+func rangeBPF(start, end netip.Addr) (string, bool) {
+	var terms []string
+	for addr := start; addr.IsValid() && !end.Less(addr); {
+		bits := 32
+		for bits > 0 {
+			prefix, _ := addr.Prefix(bits - 1)
+			if prefix.Addr() != addr || end.Less(lastAddr(prefix)) {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(addr, bits)
+		if bits == 32 {
+			terms = append(terms, "host "+addr.String())
+		} else {
+			terms = append(terms, "net "+prefix.String())
+		}
+		if len(terms) > maxBPFPrefixes {
+			return "", false
+		}
+		addr = lastAddr(prefix).Next()
+	}
+	return orTerms(terms), true
+}
+
+// orTerms joins alternatives, parenthesized when there is more than one
+func orTerms(terms []string) string {
+	switch len(terms) {
+	case 0:
+		return ""
+	case 1:
+		return terms[0]
+	}
+	return "(" + strings.Join(terms, ") or (") + ")"
+}
@@ -0,0 +1,72 @@
+package pins
+
+import (
+	"sync"
+	"time"
+)
+
+// hitMinutes is how many minutes of per-minute match counts each rule keeps
+const hitMinutes = 60
+
+// RuleHits is how much traffic one rule has matched, so a stale rule can be told from a live one
+// before it is cleared
+type RuleHits struct {
+	Rule      string     `json:"rule"`
+	Added     time.Time  `json:"added"`
+	Packets   int64      `json:"packets"`              // matched since the rule was added
+	LastHour  int64      `json:"last_hour"`            // matched in the last 60 minutes
+	LastMatch *time.Time `json:"last_match,omitempty"` // unset if the rule never matched
+	PerMinute []int64    `json:"per_minute"`           // the last 60 minutes, oldest first
+	Expires   *time.Time `json:"expires,omitempty"`    // when a temporary pin lapses; unset for one that stays
+}
+
+// ruleHits counts one rule's matches, by minute for the last hour
+type ruleHits struct {
+	mu        sync.Mutex
+	added     time.Time
+	packets   int64
+	lastMatch time.Time
+	minutes   [hitMinutes]int64
+	minute    int64 // Unix minute the newest bucket counts
+}
+
+func newRuleHits(now time.Time) *ruleHits {
+	return &ruleHits{added: now, minute: now.Unix() / 60}
+}
+
+// roll moves the buckets forward to now's minute, emptying the ones passed over; callers hold mu
+func (h *ruleHits) roll(now time.Time) {
+	minute := now.Unix() / 60
+	for m := h.minute + 1; m <= minute && m <= h.minute+hitMinutes; m++ {
+		h.minutes[m%hitMinutes] = 0
+	}
+	if minute > h.minute {
+		h.minute = minute
+	}
+}
+
+func (h *ruleHits) hit(now time.Time) {
+	h.mu.Lock()
+	h.roll(now)
+	h.packets++
+	h.minutes[h.minute%hitMinutes]++
+	h.lastMatch = now
+	h.mu.Unlock()
+}
+
+func (h *ruleHits) snapshot(rule string, now time.Time) RuleHits {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.roll(now)
+	hits := RuleHits{Rule: rule, Added: h.added, Packets: h.packets, PerMinute: make([]int64, hitMinutes)}
+	for i := range hits.PerMinute {
+		count := h.minutes[(h.minute+1+int64(i))%hitMinutes]
+		hits.PerMinute[i] = count
+		hits.LastHour += count
+	}
+	if !h.lastMatch.IsZero() {
+		lastMatch := h.lastMatch
+		hits.LastMatch = &lastMatch
+	}
+	return hits
+}
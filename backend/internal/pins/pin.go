@@ -0,0 +1,193 @@
+package pins
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pin is one pinning rule: an address rule, a hostname pattern matched against the names an
+// address resolved from, or a port and protocol predicate
+type Pin struct {
+	text     string // canonical form, used for display and removal
+	addr     *Rule
+	host     string // lowercase name; "*.example.com" matches any name below example.com
+	protocol string // TCP, UDP, ICMP or OTHER; empty for any
+	portLow  int    // 0 for any port
+	portHigh int
+	expires  time.Time // zero for a pin that stays until it is removed
+}
+
+// ParsePin parses a pinning rule. Accepted forms are every address form ParseRule takes, and:
+//
+//	scoreboard.ctf.example.com, bob-laptop   hostname, from DNS responses and DHCP leases
+//	*.ctf.example.com                        any name below ctf.example.com
+//	port:443, port:8000-8100                 either port
+//	proto:udp                                TCP, UDP, ICMP or OTHER
+//	tcp:443, udp:5000-5100                   protocol and either port
+func ParsePin(text string) (Pin, error) {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	if kind, value, ok := strings.Cut(lower, ":"); ok {
+		switch kind {
+		case "port":
+			low, high, err := parsePorts(value)
+			if err != nil {
+				return Pin{}, fmt.Errorf("%v in %q", err, text)
+			}
+			return Pin{text: "port:" + portsText(low, high), portLow: low, portHigh: high}, nil
+		case "proto":
+			protocol := strings.ToUpper(strings.TrimSpace(value))
+			switch protocol {
+			case "TCP", "UDP", "ICMP", "OTHER":
+			default:
+				return Pin{}, fmt.Errorf("unknown protocol %q in %q (tcp, udp, icmp or other)", value, text)
+			}
+			return Pin{text: "proto:" + strings.ToLower(protocol), protocol: protocol}, nil
+		case "tcp", "udp":
+			low, high, err := parsePorts(value)
+			if err != nil {
+				return Pin{}, fmt.Errorf("%v in %q", err, text)
+			}
+			return Pin{text: kind + ":" + portsText(low, high), protocol: strings.ToUpper(kind), portLow: low, portHigh: high}, nil
+		}
+	}
+
+	// Addresses, prefixes and ranges are digits, dots, colons, slashes and dashes; a letter or a
+	// wildcard outside them is a name
+	if !strings.ContainsAny(lower, ":/") && strings.ContainsAny(lower, "abcdefghijklmnopqrstuvwxyz*") {
+		if err := checkHostPattern(lower); err != nil {
+			return Pin{}, err
+		}
+		return Pin{text: lower, host: lower}, nil
+	}
+
+	rule, err := ParseRule(text)
+	if err != nil {
+		return Pin{}, err
+	}
+	return Pin{text: rule.text, addr: &rule}, nil
+}
+
+// parsePorts parses a port or an inclusive port range
+func parsePorts(text string) (int, int, error) {
+	lowText, highText, isRange := strings.Cut(strings.TrimSpace(text), "-")
+	low, err := strconv.Atoi(strings.TrimSpace(lowText))
+	if err != nil || low < 1 || low > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q", lowText)
+	}
+	if !isRange {
+		return low, low, nil
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(highText))
+	if err != nil || high < 1 || high > 65535 {
+		return 0, 0, fmt.Errorf("invalid port %q", highText)
+	}
+	if high < low {
+		return 0, 0, fmt.Errorf("port range ends before it starts")
+	}
+	return low, high, nil
+}
+
+func portsText(low, high int) string {
+	if low == high {
+		return strconv.Itoa(low)
+	}
+	return strconv.Itoa(low) + "-" + strconv.Itoa(high)
+}
+
+// checkHostPattern accepts a hostname, optionally with a leading "*." wildcard label. A name that
+// starts with a number or ends in a label that doesn't start with a letter reads as a mistyped
+// address, such as 10.0.0.x or 10.0.0.5-2O, and is rejected.
+func checkHostPattern(pattern string) error {
+	name := strings.TrimPrefix(pattern, "*.")
+	if len(name) > 253 {
+		return fmt.Errorf("hostname %q is too long", pattern)
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid hostname %q", pattern)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("invalid hostname %q", pattern)
+			}
+		}
+	}
+	if _, err := strconv.Atoi(labels[0]); err == nil || labels[len(labels)-1][0] < 'a' || labels[len(labels)-1][0] > 'z' {
+		return fmt.Errorf("invalid address or hostname %q", pattern)
+	}
+	return nil
+}
+
+// String returns the canonical form of the pin
+func (p Pin) String() string {
+	return p.text
+}
+
+// Expires returns when a temporary pin lapses; zero for one that stays
+func (p Pin) Expires() time.Time {
+	return p.expires
+}
+
+// packetTraffic is what pins match a packet on; names are looked up once, when a hostname pin
+// first needs them
+type packetTraffic struct {
+	src, dst           string
+	srcAddr, dstAddr   netip.Addr
+	srcPort, dstPort   int
+	protocol           string
+	resolve            func(ip string) []string
+	srcNames, dstNames []string
+	resolved           bool
+}
+
+func newPacketTraffic(src, dst string, srcPort, dstPort int, protocol string, resolve func(ip string) []string) *packetTraffic {
+	t := &packetTraffic{src: src, dst: dst, srcPort: srcPort, dstPort: dstPort, protocol: protocol, resolve: resolve}
+	if addr, err := netip.ParseAddr(src); err == nil {
+		t.srcAddr = addr
+	}
+	if addr, err := netip.ParseAddr(dst); err == nil {
+		t.dstAddr = addr
+	}
+	return t
+}
+
+func (p Pin) matches(t *packetTraffic) bool {
+	switch {
+	case p.addr != nil:
+		return (t.srcAddr.IsValid() && p.addr.Contains(t.srcAddr)) || (t.dstAddr.IsValid() && p.addr.Contains(t.dstAddr))
+	case p.host != "":
+		if t.resolve == nil {
+			return false
+		}
+		if !t.resolved {
+			t.srcNames, t.dstNames, t.resolved = t.resolve(t.src), t.resolve(t.dst), true
+		}
+		return p.matchesName(t.srcNames) || p.matchesName(t.dstNames)
+	}
+	if p.protocol != "" && p.protocol != t.protocol {
+		return false
+	}
+	return p.portLow == 0 || p.inPorts(t.srcPort) || p.inPorts(t.dstPort)
+}
+
+func (p Pin) matchesName(names []string) bool {
+	for _, name := range names {
+		if suffix, wildcard := strings.CutPrefix(p.host, "*"); wildcard {
+			if strings.HasSuffix(name, suffix) {
+				return true
+			}
+		} else if name == p.host {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Pin) inPorts(port int) bool {
+	return port >= p.portLow && port <= p.portHigh
+}
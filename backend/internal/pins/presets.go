@@ -0,0 +1,229 @@
+package pins
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ViewFilter narrows what a session displays. Empty fields match everything.
+type ViewFilter struct {
+	Protocols []string `json:"protocols,omitempty"` // TCP, UDP, ICMP, OTHER
+	Hosts     []string `json:"hosts,omitempty"`     // same forms as pin rules; either endpoint matches
+	Ports     []int    `json:"ports,omitempty"`     // either port matches
+}
+
+// Preset is a named view: the pins to apply plus an optional display filter and sampling rate
+type Preset struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Pins        []string    `json:"pins"`
+	Filter      *ViewFilter `json:"filter,omitempty"`
+	SampleRate  *float64    `json:"sample_rate,omitempty"` // fraction of unpinned packets forwarded (0-1]; nil keeps the default
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Validate checks every rule, host and setting and canonicalizes the pins
+func (p *Preset) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("preset needs a name")
+	}
+	for i, text := range p.Pins {
+		pin, err := ParsePin(text)
+		if err != nil {
+			return fmt.Errorf("preset %q: %v", p.Name, err)
+		}
+		p.Pins[i] = pin.String()
+	}
+	if p.Pins == nil {
+		p.Pins = []string{}
+	}
+	if p.Filter != nil {
+		if _, err := CompileView(p.Filter); err != nil {
+			return fmt.Errorf("preset %q: %v", p.Name, err)
+		}
+	}
+	if p.SampleRate != nil && (*p.SampleRate <= 0 || *p.SampleRate > 1) {
+		return fmt.Errorf("preset %q: sample_rate must be in (0, 1]", p.Name)
+	}
+	return nil
+}
+
+// View is a compiled ViewFilter
+type View struct {
+	protocols map[string]bool
+	hosts     []Rule
+	ports     map[int]bool
+}
+
+// CompileView parses a filter; a nil filter compiles to a nil view, which matches everything
+func CompileView(filter *ViewFilter) (*View, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	view := &View{}
+	for _, protocol := range filter.Protocols {
+		protocol = strings.ToUpper(protocol)
+		switch protocol {
+		case "TCP", "UDP", "ICMP", "OTHER":
+		default:
+			return nil, fmt.Errorf("unknown protocol %q", protocol)
+		}
+		if view.protocols == nil {
+			view.protocols = make(map[string]bool)
+		}
+		view.protocols[protocol] = true
+	}
+	for _, host := range filter.Hosts {
+		rule, err := ParseRule(host)
+		if err != nil {
+			return nil, err
+		}
+		view.hosts = append(view.hosts, rule)
+	}
+	for _, port := range filter.Ports {
+		if port < 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+		if view.ports == nil {
+			view.ports = make(map[int]bool)
+		}
+		view.ports[port] = true
+	}
+	return view, nil
+}
+
+// Matches reports whether a packet passes the filter
+func (v *View) Matches(src, dst string, srcPort, dstPort int, protocol string) bool {
+	if v == nil {
+		return true
+	}
+	if v.protocols != nil && !v.protocols[protocol] {
+		return false
+	}
+	if v.ports != nil && !v.ports[srcPort] && !v.ports[dstPort] {
+		return false
+	}
+	if len(v.hosts) > 0 {
+		srcAddr, srcErr := netip.ParseAddr(src)
+		dstAddr, dstErr := netip.ParseAddr(dst)
+		for _, rule := range v.hosts {
+			if (srcErr == nil && rule.Contains(srcAddr)) || (dstErr == nil && rule.Contains(dstAddr)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// PresetStore holds the named presets, persisted as JSON on every change
+type PresetStore struct {
+	mu      sync.RWMutex
+	path    string
+	presets map[string]*Preset
+}
+
+// NewPresetStore loads presets from path (missing file = no presets)
+func NewPresetStore(path string) (*PresetStore, error) {
+	store := &PresetStore{
+		path:    path,
+		presets: make(map[string]*Preset),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var presets []*Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("parsing presets %s: %v", path, err)
+	}
+	for _, preset := range presets {
+		if err := preset.Validate(); err != nil {
+			log.Printf("⚠️ Skipping preset: %v", err)
+			continue
+		}
+		store.presets[preset.Name] = preset
+	}
+	log.Printf("📌 Loaded %d presets from %s", len(store.presets), path)
+	return store, nil
+}
+
+// Get returns a preset by name
+func (s *PresetStore) Get(name string) (*Preset, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	preset, ok := s.presets[name]
+	return preset, ok
+}
+
+// List returns all presets ordered by name
+func (s *PresetStore) List() []*Preset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sortedLocked()
+}
+
+// Put creates or replaces a preset and persists the store
+func (s *PresetStore) Put(preset *Preset) error {
+	if err := preset.Validate(); err != nil {
+		return err
+	}
+	preset.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.presets[preset.Name] = preset
+	return s.saveLocked()
+}
+
+// Delete removes a preset; it reports whether the name was present
+func (s *PresetStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.presets[name]; !ok {
+		return false, nil
+	}
+	delete(s.presets, name)
+	return true, s.saveLocked()
+}
+
+func (s *PresetStore) sortedLocked() []*Preset {
+	presets := make([]*Preset, 0, len(s.presets))
+	for _, preset := range s.presets {
+		presets = append(presets, preset)
+	}
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+	return presets
+}
+
+func (s *PresetStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,307 @@
+// Package pins matches packets against the pinning rules operators set from the UI.
+// Pinned traffic bypasses sampling, so a bad rule must be rejected, never guessed at.
+package pins
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule is one parsed pinning rule: an address, a CIDR prefix or an inclusive address range
+type Rule struct {
+	text  string // canonical form, used for display and removal
+	start netip.Addr
+	end   netip.Addr
+}
+
+// ParseRule parses a pinning rule. Accepted forms:
+//
+//	10.0.0.5, 2001:db8::1               single address
+//	10.0.0.0/24, 2001:db8::/64          CIDR prefix
+//	10.0.0.5-10.0.1.20, 2001:db8::1-2001:db8::ff   inclusive range
+//	10.0.0.5-20                         IPv4 last-octet shorthand for 10.0.0.5-10.0.0.20
+func ParseRule(text string) (Rule, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Rule{}, fmt.Errorf("empty pin rule")
+	}
+
+	if strings.Contains(text, "/") {
+		prefix, err := netip.ParsePrefix(text)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid CIDR %q", text)
+		}
+		prefix = prefix.Masked()
+		return Rule{text: prefix.String(), start: prefix.Addr().Unmap(), end: lastAddr(prefix)}, nil
+	}
+
+	if startText, endText, isRange := strings.Cut(text, "-"); isRange {
+		start, err := netip.ParseAddr(strings.TrimSpace(startText))
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid range start in %q", text)
+		}
+		start = normalize(start)
+		endText = strings.TrimSpace(endText)
+		if endText == "" {
+			return Rule{}, fmt.Errorf("range %q has no end", text)
+		}
+
+		var end netip.Addr
+		if octet, err := strconv.Atoi(endText); err == nil && start.Is4() {
+			if octet < 0 || octet > 255 {
+				return Rule{}, fmt.Errorf("range end octet %d out of bounds in %q", octet, text)
+			}
+			b := start.As4()
+			b[3] = byte(octet)
+			end = netip.AddrFrom4(b)
+		} else if end, err = netip.ParseAddr(endText); err != nil {
+			return Rule{}, fmt.Errorf("invalid range end in %q", text)
+		}
+		end = normalize(end)
+
+		if start.Is4() != end.Is4() {
+			return Rule{}, fmt.Errorf("range %q mixes IPv4 and IPv6", text)
+		}
+		if end.Less(start) {
+			return Rule{}, fmt.Errorf("range %q ends before it starts", text)
+		}
+		return Rule{text: start.String() + "-" + end.String(), start: start, end: end}, nil
+	}
+
+	addr, err := netip.ParseAddr(text)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid address %q", text)
+	}
+	addr = normalize(addr)
+	return Rule{text: addr.String(), start: addr, end: addr}, nil
+}
+
+// normalize compares IPv4-mapped IPv6 addresses as IPv4 and ignores zones
+func normalize(addr netip.Addr) netip.Addr {
+	return addr.Unmap().WithZone("")
+}
+
+// lastAddr returns the highest address inside a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr().Unmap()
+	bits := prefix.Bits()
+	if addr.Is4() {
+		b := addr.As4()
+		for i := bits; i < 32; i++ {
+			b[i/8] |= 1 << (7 - i%8)
+		}
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	for i := bits; i < 128; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	return netip.AddrFrom16(b)
+}
+
+// String returns the canonical form of the rule
+func (r Rule) String() string {
+	return r.text
+}
+
+// Bounds returns the first and last address the rule covers
+func (r Rule) Bounds() (netip.Addr, netip.Addr) {
+	return r.start, r.end
+}
+
+// Contains reports whether addr falls inside the rule
+func (r Rule) Contains(addr netip.Addr) bool {
+	addr = normalize(addr)
+	if addr.Is4() != r.start.Is4() {
+		return false
+	}
+	return !addr.Less(r.start) && !r.end.Less(addr)
+}
+
+// RuleSet is the concurrency-safe list of active pinning rules, with how much traffic each matched
+type RuleSet struct {
+	mu      sync.RWMutex
+	rules   []Pin
+	hits    map[string]*ruleHits // by canonical text
+	resolve func(ip string) []string
+}
+
+// NewRuleSet creates an empty rule set
+func NewRuleSet() *RuleSet {
+	return &RuleSet{hits: make(map[string]*ruleHits)}
+}
+
+// SetResolver sets where hostname pins look up the names of an address; without one they
+// match nothing
+func (s *RuleSet) SetResolver(resolve func(ip string) []string) {
+	s.mu.Lock()
+	s.resolve = resolve
+	s.mu.Unlock()
+}
+
+// Add parses and adds a rule. A ttl above zero makes it a temporary watch that Expire removes
+// once the ttl has passed. Adding a rule that is already present only sets when it expires, or
+// makes it stay when ttl is zero; its match counts carry on.
+func (s *RuleSet) Add(text string, ttl time.Duration) (Pin, error) {
+	pin, err := ParsePin(text)
+	if err != nil {
+		return Pin{}, err
+	}
+	now := time.Now()
+	if ttl > 0 {
+		pin.expires = now.Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.rules {
+		if existing.text == pin.text {
+			s.rules[i].expires = pin.expires
+			return pin, nil
+		}
+	}
+	s.rules = append(s.rules, pin)
+	s.hits[pin.text] = newRuleHits(now)
+	return pin, nil
+}
+
+// Expire removes the temporary pins whose time is up and returns them
+func (s *RuleSet) Expire(now time.Time) []Pin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []Pin
+	kept := s.rules[:0]
+	for _, pin := range s.rules {
+		if !pin.expires.IsZero() && !now.Before(pin.expires) {
+			expired = append(expired, pin)
+			delete(s.hits, pin.text)
+			continue
+		}
+		kept = append(kept, pin)
+	}
+	s.rules = kept
+	return expired
+}
+
+// Remove deletes a rule by its text or canonical form; it reports whether anything was removed
+func (s *RuleSet) Remove(text string) bool {
+	canonical := strings.TrimSpace(text)
+	if pin, err := ParsePin(text); err == nil {
+		canonical = pin.text
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.rules {
+		if existing.text == canonical {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			delete(s.hits, canonical)
+			return true
+		}
+	}
+	return false
+}
+
+// Replace swaps in a new list of rules, none of them temporary; nothing changes if any rule is
+// invalid. Rules kept from the old list keep their match counts.
+func (s *RuleSet) Replace(texts []string) error {
+	rules := make([]Pin, 0, len(texts))
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		pin, err := ParsePin(text)
+		if err != nil {
+			return err
+		}
+		if !seen[pin.text] {
+			seen[pin.text] = true
+			rules = append(rules, pin)
+		}
+	}
+	now := time.Now()
+	s.mu.Lock()
+	hits := make(map[string]*ruleHits, len(rules))
+	for _, pin := range rules {
+		h := s.hits[pin.text]
+		if h == nil {
+			h = newRuleHits(now)
+		}
+		hits[pin.text] = h
+	}
+	s.rules = rules
+	s.hits = hits
+	s.mu.Unlock()
+	return nil
+}
+
+// Clear removes every rule
+func (s *RuleSet) Clear() {
+	s.mu.Lock()
+	s.rules = nil
+	s.hits = make(map[string]*ruleHits)
+	s.mu.Unlock()
+}
+
+// Rules returns the canonical text of every rule, in insertion order
+func (s *RuleSet) Rules() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	texts := make([]string, len(s.rules))
+	for i, pin := range s.rules {
+		texts[i] = pin.text
+	}
+	return texts
+}
+
+// Matches reports whether a packet matches any rule, by either address, the names either
+// address resolved from, either port or its protocol
+func (s *RuleSet) Matches(src, dst string, srcPort, dstPort int, protocol string) bool {
+	return s.match(src, dst, srcPort, dstPort, protocol, false)
+}
+
+// Observe is Matches that also counts the packet once against every rule it matched
+func (s *RuleSet) Observe(src, dst string, srcPort, dstPort int, protocol string) bool {
+	return s.match(src, dst, srcPort, dstPort, protocol, true)
+}
+
+func (s *RuleSet) match(src, dst string, srcPort, dstPort int, protocol string, count bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.rules) == 0 {
+		return false
+	}
+	traffic := newPacketTraffic(src, dst, srcPort, dstPort, protocol, s.resolve)
+	var now time.Time
+	matched := false
+	for _, pin := range s.rules {
+		if !pin.matches(traffic) {
+			continue
+		}
+		if !count {
+			return true
+		}
+		if !matched {
+			now = time.Now()
+			matched = true
+		}
+		s.hits[pin.text].hit(now)
+	}
+	return matched
+}
+
+// Hits returns how much traffic each rule has matched, in insertion order
+func (s *RuleSet) Hits() []RuleHits {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hits := make([]RuleHits, len(s.rules))
+	for i, pin := range s.rules {
+		hits[i] = s.hits[pin.text].snapshot(pin.text, now)
+		if !pin.expires.IsZero() {
+			expires := pin.expires
+			hits[i].Expires = &expires
+		}
+	}
+	return hits
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package probe
+
+import (
+	"fmt"
+	"net/netip"
+	"runtime"
+	"strconv"
+)
+
+// command builds the ping or traceroute invocation; -n keeps both from resolving names
+func command(kind string, target netip.Addr, opts Options) (string, []string, error) {
+	switch kind {
+	case KindPing:
+		name := "ping"
+		// macOS and the BSDs keep IPv6 in separate binaries
+		if target.Is6() && runtime.GOOS != "linux" {
+			name = "ping6"
+		}
+		return name, []string{"-n", "-c", strconv.Itoa(opts.Count), target.String()}, nil
+	case KindTraceroute:
+		name := "traceroute"
+		args := []string{"-n", "-q", "3", "-w", "2", "-m", strconv.Itoa(opts.MaxHops)}
+		if target.Is6() {
+			if runtime.GOOS == "linux" {
+				args = append(args, "-6")
+			} else {
+				name = "traceroute6"
+			}
+		}
+		return name, append(args, target.String()), nil
+	}
+	return "", nil, fmt.Errorf("unknown probe %q (ping or traceroute)", kind)
+}
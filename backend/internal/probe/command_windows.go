@@ -0,0 +1,22 @@
+package probe
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+)
+
+// command builds the ping or tracert invocation; -d keeps tracert from resolving names
+func command(kind string, target netip.Addr, opts Options) (string, []string, error) {
+	family := "-4"
+	if target.Is6() {
+		family = "-6"
+	}
+	switch kind {
+	case KindPing:
+		return "ping", []string{family, "-n", strconv.Itoa(opts.Count), target.String()}, nil
+	case KindTraceroute:
+		return "tracert", []string{family, "-d", "-h", strconv.Itoa(opts.MaxHops), target.String()}, nil
+	}
+	return "", nil, fmt.Errorf("unknown probe %q (ping or traceroute)", kind)
+}
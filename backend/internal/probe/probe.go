@@ -0,0 +1,171 @@
+// Package probe runs the system's ping and traceroute against an address and reads their output
+// as it comes, so an operator can check a host from the server without opening a terminal.
+package probe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Probe kinds
+const (
+	KindPing       = "ping"
+	KindTraceroute = "traceroute"
+)
+
+// Options bound a probe
+type Options struct {
+	Count   int // echo requests a ping sends
+	MaxHops int // hops a traceroute goes out to
+}
+
+// Result is one line of a probe's output that says something: a ping reply or loss, or a
+// traceroute hop
+type Result struct {
+	Seq     int       `json:"seq"`              // ping: the echo request, from 1; traceroute: the hop
+	From    string    `json:"from,omitempty"`   // the address that answered
+	RTTMs   []float64 `json:"rtt_ms,omitempty"` // ping: one; traceroute: one per query answered
+	Timeout bool      `json:"timeout,omitempty"`
+	Line    string    `json:"line"` // as the tool printed it
+}
+
+// Summary is how a probe ended
+type Summary struct {
+	Sent     int     `json:"sent,omitempty"` // ping
+	Received int     `json:"received,omitempty"`
+	Loss     float64 `json:"loss,omitempty"`    // 0-1
+	Hops     int     `json:"hops,omitempty"`    // traceroute: the last hop printed
+	Reached  bool    `json:"reached,omitempty"` // traceroute: the last hop is the target
+}
+
+var (
+	replyFrom = regexp.MustCompile(`(?i)\bfrom ([0-9a-f.:]+)`)
+	replyTime = regexp.MustCompile(`(?i)\btime[=<]\s*([0-9.]+)\s*ms`)
+	replySeq  = regexp.MustCompile(`icmp_seq[= ](\d+)`)
+	lost      = regexp.MustCompile(`(?i)timed out|timeout|unreachable`)
+)
+
+// Run probes target, calling result for every reply, loss or hop as the tool prints it, until the
+// tool exits or ctx ends. A ping that got no replies is not an error.
+func Run(ctx context.Context, kind string, target netip.Addr, opts Options, result func(Result)) (Summary, error) {
+	name, args, err := command(kind, target, opts)
+	if err != nil {
+		return Summary{}, err
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Summary{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return Summary{}, fmt.Errorf("%s: %v", name, err)
+	}
+
+	var summary Summary
+	if kind == KindPing {
+		summary.Sent = opts.Count
+	}
+	requests := 0
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		var r Result
+		var ok bool
+		if kind == KindPing {
+			if r, ok = parsePing(line); ok {
+				// Windows doesn't number its replies
+				if requests++; r.Seq == 0 {
+					r.Seq = requests
+				}
+				if !r.Timeout {
+					summary.Received++
+				}
+			}
+		} else {
+			r, ok = parseHop(line)
+			if ok {
+				summary.Hops = r.Seq
+				summary.Reached = r.From == target.String()
+			}
+		}
+		if ok {
+			result(r)
+		}
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return summary, fmt.Errorf("%s stopped: %v", kind, ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return summary, err
+	}
+	// ping exits non-zero when replies went missing; that is what the summary reports
+	if summary.Sent > 0 {
+		summary.Loss = 1 - float64(summary.Received)/float64(summary.Sent)
+		if summary.Loss < 0 {
+			summary.Loss = 0
+		}
+	}
+	return summary, nil
+}
+
+// parsePing reads a ping reply or a lost request in the Linux, BSD or Windows format
+func parsePing(line string) (Result, bool) {
+	r := Result{Line: line}
+	if m := replyTime.FindStringSubmatch(line); m != nil {
+		rtt, _ := strconv.ParseFloat(m[1], 64)
+		r.RTTMs = []float64{rtt}
+	} else if lost.MatchString(line) {
+		r.Timeout = true // a router's unreachable names the router in From
+	} else {
+		return Result{}, false
+	}
+	if from := replyFrom.FindStringSubmatch(line); from != nil {
+		r.From = strings.TrimSuffix(from[1], ":")
+	}
+	if seq := replySeq.FindStringSubmatch(line); seq != nil {
+		r.Seq, _ = strconv.Atoi(seq[1])
+	}
+	return r, true
+}
+
+// parseHop reads a traceroute (-n) or tracert (-d) hop line: the hop number, then addresses,
+// round-trip times ("1.234 ms", "<1 ms") and "*" for queries that went unanswered
+func parseHop(line string) (Result, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Result{}, false
+	}
+	hop, err := strconv.Atoi(fields[0])
+	if err != nil || hop <= 0 {
+		return Result{}, false
+	}
+	r := Result{Seq: hop, Line: line}
+	answered := false
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "*":
+		case i+1 < len(fields) && fields[i+1] == "ms":
+			if rtt, err := strconv.ParseFloat(strings.TrimPrefix(field, "<"), 64); err == nil {
+				r.RTTMs = append(r.RTTMs, rtt)
+				answered = true
+			}
+			i++
+		default:
+			if addr, err := netip.ParseAddr(strings.Trim(field, "()[]")); err == nil && r.From == "" {
+				r.From = addr.String()
+			}
+		}
+	}
+	r.Timeout = !answered
+	return r, true
+}
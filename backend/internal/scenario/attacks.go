@@ -0,0 +1,138 @@
+package scenario
+
+import (
+	"embed"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed attacks/*.yaml
+var attackFiles embed.FS
+
+// Attack is a canned event from the attack library. It runs on top of any scenario: node
+// sets the running scenario defines are used as they are, the attack's own sets fill in
+// the rest.
+type Attack struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	Nodes       map[string][]string `yaml:"nodes"` // defaults for the sets the event refers to
+	Event       Event               `yaml:"event"` // at is ignored; duration is required
+
+	addresses map[string][]string
+}
+
+// TriggerOptions override an attack's defaults when it is triggered
+type TriggerOptions struct {
+	Source   string // comma-separated addresses, CIDRs or ranges (pin rule syntax) replacing the from set
+	Target   string // the same, replacing the to set
+	Duration time.Duration
+	Rate     float64
+}
+
+// Triggered is an attack resolved against a running scenario, ready for the simulation engine
+type Triggered struct {
+	Attack  string
+	Event   Event
+	Sources []string
+	Targets []string
+}
+
+// Single-source kinds run from one host picked at trigger time; single-target kinds hit one host
+var (
+	singleSource = map[string]bool{KindScan: true, KindDNSExfil: true, KindLateral: true, KindBeacon: true}
+	singleTarget = map[string]bool{KindDDoS: true, KindSYNFlood: true, KindDNSExfil: true, KindBeacon: true}
+)
+
+// ParseAttack decodes and validates an attack
+func ParseAttack(data []byte) (*Attack, error) {
+	var a Attack
+	if err := yaml.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parsing attack: %v", err)
+	}
+	// Validate the event as the only event of a scenario made of the attack's nodes
+	s := &Scenario{Name: a.Name, Nodes: a.Nodes, Events: []Event{a.Event}}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("attack %s: %v", a.Name, err)
+	}
+	if s.Events[0].Duration <= 0 {
+		return nil, fmt.Errorf("attack %s needs a duration", s.Name)
+	}
+	a.Name, a.Event, a.addresses = s.Name, s.Events[0], s.addresses
+	return &a, nil
+}
+
+// BuiltinAttack returns one attack from the library shipped with the binary
+func BuiltinAttack(name string) (*Attack, error) {
+	if strings.ContainsAny(name, `/\.`) {
+		return nil, fmt.Errorf("unknown attack %q", name)
+	}
+	data, err := attackFiles.ReadFile(path.Join("attacks", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown attack %q", name)
+	}
+	return ParseAttack(data)
+}
+
+// AttackNames lists the attack library
+func AttackNames() []string {
+	entries, _ := attackFiles.ReadDir("attacks")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve fixes the attack's addresses for one run on top of sc (which may be nil). Each
+// side comes from the override, else the scenario's set of that name, else the attack's own.
+func (a *Attack) Resolve(sc *Scenario, opts TriggerOptions, rng *rand.Rand) (*Triggered, error) {
+	t := &Triggered{Attack: a.Name, Event: a.Event}
+	if opts.Duration > 0 {
+		t.Event.Duration = opts.Duration
+	}
+	if opts.Rate > 0 {
+		t.Event.Rate = opts.Rate
+	}
+
+	var err error
+	if t.Sources, err = a.addressesFor(sc, a.Event.From, opts.Source); err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+	if t.Targets, err = a.addressesFor(sc, a.Event.To, opts.Target); err != nil {
+		return nil, fmt.Errorf("target: %v", err)
+	}
+	if singleSource[t.Event.Kind] {
+		t.Sources = []string{t.Sources[rng.Intn(len(t.Sources))]}
+	}
+	if singleTarget[t.Event.Kind] {
+		t.Targets = []string{t.Targets[rng.Intn(len(t.Targets))]}
+	}
+	return t, nil
+}
+
+func (a *Attack) addressesFor(sc *Scenario, set, override string) ([]string, error) {
+	if override != "" {
+		entries := strings.Split(override, ",")
+		for i := range entries {
+			entries[i] = strings.TrimSpace(entries[i])
+		}
+		addresses, err := expand(entries)
+		if err == nil && len(addresses) == 0 {
+			err = fmt.Errorf("no addresses in %q", override)
+		}
+		return addresses, err
+	}
+	if sc != nil {
+		if addresses := sc.Addresses(set); len(addresses) > 0 {
+			return addresses, nil
+		}
+	}
+	return a.addresses[set], nil
+}
@@ -0,0 +1,321 @@
+// Package scenario describes simulated networks: named node sets, a traffic matrix between
+// them and a timeline of events (a scan at T+2m, a DDoS at T+5m). Scenarios are written in
+// YAML so demos can tell the same curated story every time. Attacks from the built-in library
+// can also be triggered on cue on top of whatever scenario is running.
+package scenario
+
+import (
+	"embed"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"vibes-network-visualizer/internal/pins"
+)
+
+// DefaultName is the built-in scenario used when none is selected
+const DefaultName = "busy-lan"
+
+// maxSetSize caps how many addresses one node set may expand to
+const maxSetSize = 65536
+
+// Event kinds
+const (
+	KindFlow     = "flow"      // extra traffic from the flow fields, for the event's duration
+	KindScan     = "scan"      // one source walks the destination ports of every target
+	KindDDoS     = "ddos"      // every source floods one target
+	KindSYNFlood = "syn_flood" // every source sends TCP SYNs to one target, half-open connections only
+	KindDNSExfil = "dns_exfil" // one source sends long DNS queries to one resolver
+	KindLateral  = "lateral"   // one source connects to a target, which then moves on to the next
+	KindBeacon   = "beacon"    // one source calls one destination home at a steady, slightly jittered rate
+)
+
+var kinds = map[string]bool{
+	KindFlow: true, KindScan: true, KindDDoS: true,
+	KindSYNFlood: true, KindDNSExfil: true, KindLateral: true, KindBeacon: true,
+}
+
+// Protocols a flow may use; matches the capture package's protocol names
+var protocols = map[string]bool{"TCP": true, "UDP": true, "ICMP": true}
+
+//go:embed builtin/*.yaml
+var builtinFiles embed.FS
+
+// Scenario is one simulated network and its timeline
+type Scenario struct {
+	Name        string              `yaml:"name"`
+	Description string              `yaml:"description"`
+	Loop        time.Duration       `yaml:"loop"`     // restart the event timeline after this long; 0 runs it once
+	Nodes       map[string][]string `yaml:"nodes"`    // set name → addresses, CIDRs or ranges (pin rule syntax)
+	Topology    *Topology           `yaml:"topology"` // generates clients, servers, gateways and internet; nodes override them
+	Traffic     []Flow              `yaml:"traffic"`  // defaults to a matrix sized to the topology, if there is one
+	Events      []Event             `yaml:"events"`
+
+	addresses map[string][]string // expanded node sets, filled by Validate
+}
+
+// Flow is one row of the traffic matrix
+type Flow struct {
+	Name      string         `yaml:"name"`
+	From      string         `yaml:"from"`      // node set
+	To        string         `yaml:"to"`        // node set
+	Rate      float64        `yaml:"rate"`      // packets per second
+	Protocols map[string]int `yaml:"protocols"` // protocol → weight; default TCP only
+	Size      []int          `yaml:"size"`      // [min, max] bytes; default [64, 1500]
+	Ports     []int          `yaml:"ports"`     // destination ports to pick from; scans walk [first, last]
+	Reply     float64        `yaml:"reply"`     // fraction of packets answered by the destination
+	Pairs     int            `yaml:"pairs"`     // > 0 fixes the flow to this many source/destination pairs
+	Jitter    float64        `yaml:"jitter"`    // fraction of the interval each packet may come early or late
+}
+
+// Event is a timed flow, scan or flood
+type Event struct {
+	At       time.Duration `yaml:"at"`       // offset from the start of the timeline
+	Duration time.Duration `yaml:"duration"` // 0 lasts until the timeline restarts
+	Kind     string        `yaml:"kind"`
+	Flow     `yaml:",inline"`
+}
+
+// Parse decodes and validates a scenario
+func Parse(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Load reads a scenario file
+func Load(file string) (*Scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return s, nil
+}
+
+// Builtin returns one of the scenarios shipped with the binary
+func Builtin(name string) (*Scenario, error) {
+	data, err := builtinFiles.ReadFile(path.Join("builtin", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown scenario %q", name)
+	}
+	return Parse(data)
+}
+
+// BuiltinNames lists the built-in scenarios
+func BuiltinNames() []string {
+	entries, _ := builtinFiles.ReadDir("builtin")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup resolves a built-in scenario name or a path to a YAML file; empty means the default
+func Lookup(nameOrFile string) (*Scenario, error) {
+	if nameOrFile == "" {
+		nameOrFile = DefaultName
+	}
+	if strings.HasSuffix(nameOrFile, ".yaml") || strings.HasSuffix(nameOrFile, ".yml") || strings.ContainsRune(nameOrFile, os.PathSeparator) {
+		return Load(nameOrFile)
+	}
+	return Builtin(nameOrFile)
+}
+
+// Validate checks every reference and expands the node sets
+func (s *Scenario) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("scenario needs a name")
+	}
+	if len(s.Nodes) == 0 && s.Topology == nil {
+		return fmt.Errorf("scenario %s has no nodes", s.Name)
+	}
+	if s.Loop < 0 {
+		return fmt.Errorf("scenario %s: loop must not be negative", s.Name)
+	}
+
+	s.addresses = make(map[string][]string, len(s.Nodes))
+	if s.Topology != nil {
+		generated, err := s.Topology.generate()
+		if err != nil {
+			return fmt.Errorf("scenario %s: topology: %v", s.Name, err)
+		}
+		s.addresses = generated
+	}
+	for name, entries := range s.Nodes {
+		addresses, err := expand(entries)
+		if err != nil {
+			return fmt.Errorf("node set %s: %v", name, err)
+		}
+		if len(addresses) == 0 {
+			return fmt.Errorf("node set %s is empty", name)
+		}
+		s.addresses[name] = addresses
+	}
+	if s.Topology != nil && len(s.Traffic) == 0 {
+		s.Traffic = defaultTraffic(s.addresses)
+	}
+
+	for i := range s.Traffic {
+		flow := &s.Traffic[i]
+		if err := s.validateFlow(flow, KindFlow); err != nil {
+			return fmt.Errorf("traffic %s: %v", flow.label(i), err)
+		}
+	}
+	for i := range s.Events {
+		event := &s.Events[i]
+		if event.Kind == "" {
+			event.Kind = KindFlow
+		}
+		if !kinds[event.Kind] {
+			return fmt.Errorf("event %s: unknown kind %q", event.label(i), event.Kind)
+		}
+		if event.At < 0 || event.Duration < 0 {
+			return fmt.Errorf("event %s: at and duration must not be negative", event.label(i))
+		}
+		if err := s.validateFlow(&event.Flow, event.Kind); err != nil {
+			return fmt.Errorf("event %s: %v", event.label(i), err)
+		}
+	}
+	sort.SliceStable(s.Events, func(i, j int) bool { return s.Events[i].At < s.Events[j].At })
+	return nil
+}
+
+func (s *Scenario) validateFlow(flow *Flow, kind string) error {
+	if _, ok := s.addresses[flow.From]; !ok {
+		return fmt.Errorf("unknown node set %q in from", flow.From)
+	}
+	if _, ok := s.addresses[flow.To]; !ok {
+		return fmt.Errorf("unknown node set %q in to", flow.To)
+	}
+	if flow.Rate <= 0 {
+		return fmt.Errorf("rate must be greater than 0")
+	}
+	if len(flow.Protocols) == 0 {
+		flow.Protocols = map[string]int{"TCP": 1}
+	}
+	for protocol, weight := range flow.Protocols {
+		if !protocols[protocol] {
+			return fmt.Errorf("unknown protocol %q", protocol)
+		}
+		if weight < 0 {
+			return fmt.Errorf("protocol weight for %s must not be negative", protocol)
+		}
+	}
+	if len(flow.Size) == 0 {
+		flow.Size = []int{64, 1500}
+	}
+	if len(flow.Size) != 2 || flow.Size[0] < 20 || flow.Size[1] < flow.Size[0] || flow.Size[1] > 65535 {
+		return fmt.Errorf("size must be [min, max] between 20 and 65535 bytes")
+	}
+	for _, port := range flow.Ports {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	switch kind {
+	case KindScan:
+		if len(flow.Ports) == 0 {
+			flow.Ports = []int{1, 1024}
+		}
+		if len(flow.Ports) != 2 || flow.Ports[1] < flow.Ports[0] {
+			return fmt.Errorf("scan ports must be [first, last]")
+		}
+	case KindSYNFlood:
+		flow.Protocols = map[string]int{"TCP": 1}
+	case KindDNSExfil:
+		flow.Protocols = map[string]int{"UDP": 1}
+		if len(flow.Ports) == 0 {
+			flow.Ports = []int{53}
+		}
+	case KindLateral:
+		flow.Protocols = map[string]int{"TCP": 1}
+		if len(flow.Ports) == 0 {
+			flow.Ports = []int{445, 3389, 22, 5985}
+		}
+	case KindBeacon:
+		if len(flow.Ports) == 0 {
+			flow.Ports = []int{443}
+		}
+	}
+	if flow.Jitter < 0 || flow.Jitter >= 1 {
+		return fmt.Errorf("jitter must be at least 0 and less than 1")
+	}
+	if flow.Reply < 0 || flow.Reply > 1 {
+		return fmt.Errorf("reply must be between 0 and 1")
+	}
+	if flow.Pairs < 0 {
+		return fmt.Errorf("pairs must not be negative")
+	}
+	return nil
+}
+
+func (f *Flow) label(i int) string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// Addresses returns the expanded members of a node set
+func (s *Scenario) Addresses(set string) []string {
+	return s.addresses[set]
+}
+
+// NodeCount returns the number of distinct addresses across all node sets
+func (s *Scenario) NodeCount() int {
+	seen := make(map[string]struct{})
+	for _, addresses := range s.addresses {
+		for _, addr := range addresses {
+			seen[addr] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// expand turns addresses, CIDRs and ranges into individual addresses. Network and
+// broadcast addresses of IPv4 prefixes are left out.
+func expand(entries []string) ([]string, error) {
+	var addresses []string
+	for _, entry := range entries {
+		rule, err := pins.ParseRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		start, end := rule.Bounds()
+		if strings.Contains(entry, "/") && start.Is4() && start != end {
+			start, end = start.Next(), prevAddr(end)
+		}
+		for addr := start; addr.IsValid() && !end.Less(addr); addr = addr.Next() {
+			if len(addresses) >= maxSetSize {
+				return nil, fmt.Errorf("more than %d addresses", maxSetSize)
+			}
+			addresses = append(addresses, addr.String())
+		}
+	}
+	return addresses, nil
+}
+
+func prevAddr(addr netip.Addr) netip.Addr {
+	if prev := addr.Prev(); prev.IsValid() {
+		return prev
+	}
+	return addr
+}
@@ -0,0 +1,217 @@
+package scenario
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits on generated topologies
+const (
+	maxSubnets        = 4096
+	maxGeneratedHosts = 1 << 18
+	maxExternal       = maxSetSize
+)
+
+// Topology generates a network of any size from a few parameters instead of listing node
+// sets by hand. The same parameters and seed always give the same addresses. Client
+// subnets are carved out of 10.0.0.0/8, each with its gateway at .1; a share of every
+// subnet's hosts are servers; the internet is a pool of random public addresses.
+type Topology struct {
+	Subnets     int     `yaml:"subnets" json:"subnets"`           // default 2
+	Hosts       int     `yaml:"hosts" json:"hosts"`               // per subnet, gateway excluded; default 240
+	ServerRatio float64 `yaml:"server_ratio" json:"server_ratio"` // share of hosts that are servers; default 0.1
+	External    int     `yaml:"external" json:"external"`         // internet addresses; default 100
+	Seed        int64   `yaml:"seed" json:"seed"`
+}
+
+// ParseTopology reads -topology's "subnets=8,hosts=100,server_ratio=0.2,external=500,seed=7";
+// parameters left out keep their defaults
+func ParseTopology(spec string) (Topology, error) {
+	var t Topology
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return t, fmt.Errorf("topology parameter %q is not key=value", field)
+		}
+		var err error
+		switch strings.TrimSpace(key) {
+		case "subnets":
+			t.Subnets, err = strconv.Atoi(value)
+		case "hosts":
+			t.Hosts, err = strconv.Atoi(value)
+		case "server_ratio":
+			t.ServerRatio, err = strconv.ParseFloat(value, 64)
+		case "external":
+			t.External, err = strconv.Atoi(value)
+		case "seed":
+			t.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return t, fmt.Errorf("unknown topology parameter %q", key)
+		}
+		if err != nil {
+			return t, fmt.Errorf("topology parameter %s: %v", key, err)
+		}
+	}
+	return t, nil
+}
+
+// Generate builds a scenario from a topology alone, with a traffic matrix sized to it
+func Generate(t Topology) (*Scenario, error) {
+	s := &Scenario{Name: "generated", Topology: &t}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	s.Description = fmt.Sprintf("%d subnets of %d hosts (%.0f%% servers), %d internet addresses, seed %d",
+		t.Subnets, t.Hosts, t.ServerRatio*100, t.External, t.Seed)
+	return s, nil
+}
+
+func (t *Topology) applyDefaults() {
+	if t.Subnets == 0 {
+		t.Subnets = 2
+	}
+	if t.Hosts == 0 {
+		t.Hosts = 240
+	}
+	if t.ServerRatio == 0 {
+		t.ServerRatio = 0.1
+	}
+	if t.External == 0 {
+		t.External = 100
+	}
+}
+
+// generate returns the clients, servers, gateways and internet node sets
+func (t *Topology) generate() (map[string][]string, error) {
+	t.applyDefaults()
+	switch {
+	case t.Subnets < 1 || t.Subnets > maxSubnets:
+		return nil, fmt.Errorf("subnets must be between 1 and %d", maxSubnets)
+	case t.Hosts < 1:
+		return nil, fmt.Errorf("hosts must be at least 1")
+	case t.Subnets*t.Hosts > maxGeneratedHosts:
+		return nil, fmt.Errorf("more than %d hosts in total", maxGeneratedHosts)
+	case t.ServerRatio < 0 || t.ServerRatio > 1:
+		return nil, fmt.Errorf("server_ratio must be between 0 and 1")
+	case t.External < 1 || t.External > maxExternal:
+		return nil, fmt.Errorf("external must be between 1 and %d", maxExternal)
+	}
+
+	// Each subnet is the smallest block of at least /24 that holds the network, gateway,
+	// hosts and broadcast addresses; subnet 0 of 10.0.0.0/8 is left out
+	blockBits := bits.Len(uint(t.Hosts + 2))
+	if blockBits < 8 {
+		blockBits = 8
+	}
+	if (t.Subnets+1)<<blockBits > 1<<24 {
+		return nil, fmt.Errorf("%d subnets of %d hosts don't fit in 10.0.0.0/8", t.Subnets, t.Hosts)
+	}
+
+	rng := rand.New(rand.NewSource(t.Seed))
+	sets := map[string][]string{}
+	serversPerSubnet := int(math.Round(float64(t.Hosts) * t.ServerRatio))
+	blockSize := 1 << blockBits
+	for i := 1; i <= t.Subnets; i++ {
+		base := uint32(10)<<24 | uint32(i*blockSize)
+		sets["gateways"] = append(sets["gateways"], ipv4(base+1).String())
+
+		// Hosts are spread over the block; the first ones drawn become the servers
+		offsets := rng.Perm(blockSize - 3)[:t.Hosts]
+		servers, clients := offsets[:serversPerSubnet], offsets[serversPerSubnet:]
+		sort.Ints(servers)
+		sort.Ints(clients)
+		for _, offset := range servers {
+			sets["servers"] = append(sets["servers"], ipv4(base+uint32(offset)+2).String())
+		}
+		for _, offset := range clients {
+			sets["clients"] = append(sets["clients"], ipv4(base+uint32(offset)+2).String())
+		}
+	}
+
+	seen := make(map[uint32]bool, t.External)
+	for len(seen) < t.External {
+		n := rng.Uint32()
+		if seen[n] || !isPublic(ipv4(n)) {
+			continue
+		}
+		seen[n] = true
+		sets["internet"] = append(sets["internet"], ipv4(n).String())
+	}
+
+	for name, addresses := range sets {
+		if len(addresses) == 0 {
+			delete(sets, name)
+		}
+	}
+	return sets, nil
+}
+
+func ipv4(n uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+}
+
+// Special-purpose IPv4 ranges generated internet addresses stay out of
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+func isPublic(addr netip.Addr) bool {
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsMulticast() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultTraffic is the traffic matrix of a generated topology without one of its own,
+// scaled to the number of clients
+func defaultTraffic(addresses map[string][]string) []Flow {
+	clients := len(addresses["clients"])
+	rate := func(perClient float64) float64 {
+		return math.Min(math.Max(perClient*float64(clients), 1), 5000)
+	}
+	var flows []Flow
+	if clients == 0 {
+		return flows
+	}
+	if len(addresses["servers"]) > 0 {
+		flows = append(flows,
+			Flow{Name: "clients to servers", From: "clients", To: "servers", Rate: rate(4), Protocols: map[string]int{"TCP": 3, "UDP": 1, "ICMP": 1}, Reply: 0.4},
+			Flow{Name: "server downloads", From: "servers", To: "clients", Rate: rate(1), Size: []int{800, 1500}, Reply: 0.5},
+		)
+	}
+	pairs := clients / 10
+	if pairs < 1 {
+		pairs = 1
+	} else if pairs > 200 {
+		pairs = 200
+	}
+	flows = append(flows,
+		Flow{Name: "client sessions", From: "clients", To: "internet", Rate: rate(3), Protocols: map[string]int{"TCP": 4, "UDP": 1}, Size: []int{200, 1500}, Reply: 1, Pairs: pairs},
+		Flow{Name: "inbound", From: "internet", To: "gateways", Rate: rate(1), Protocols: map[string]int{"TCP": 7, "UDP": 3}, Size: []int{200, 1500}},
+		Flow{Name: "gateway to clients", From: "gateways", To: "clients", Rate: rate(1), Protocols: map[string]int{"TCP": 7, "UDP": 3}, Size: []int{180, 1480}},
+		Flow{Name: "pings", From: "clients", To: "gateways", Rate: rate(0.2), Protocols: map[string]int{"ICMP": 1}, Size: []int{64, 64}, Reply: 1},
+	)
+	return flows
+}
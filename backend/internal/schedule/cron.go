@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The fields of a cron expression, in order, and the values each takes. Sunday is 0 or 7.
+var cronFields = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Cron is a five-field cron expression: minute, hour, day of month, month and day of week. Each
+// field is *, a number, a range (1-5), a step (*/15, 8-18/2) or a comma-separated list of them.
+// As in cron, a day matches when either day field does, unless one of them is *.
+type Cron struct {
+	bits           [5]uint64 // the values each field matches
+	anyDay, anyDow bool      // the day-of-month or day-of-week field is *
+}
+
+// ParseCron parses a five-field cron expression
+func ParseCron(expr string) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+	c := &Cron{anyDay: strings.HasPrefix(parts[2], "*"), anyDow: strings.HasPrefix(parts[4], "*")}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %s: %v", expr, cronFields[i].name, err)
+		}
+		c.bits[i] = bits
+	}
+	if c.bits[4]&(1<<7) != 0 {
+		c.bits[4] |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		values, step := item, 1
+		if before, after, ok := strings.Cut(item, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			values, step = before, n
+		}
+		lo, hi := min, max
+		switch {
+		case values == "*":
+		case strings.Contains(values, "-"):
+			from, to, _ := strings.Cut(values, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(from)
+			hi, err2 = strconv.Atoi(to)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", item)
+			}
+		default:
+			n, err := strconv.Atoi(values)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			lo, hi = n, n
+			// 5/15 means every 15 from 5 on
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", item)
+		}
+		if lo < min || hi > max {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// day reports whether the expression's day fields match t's date
+func (c *Cron) day(t time.Time) bool {
+	dom := c.bits[2]&(1<<uint(t.Day())) != 0
+	dow := c.bits[4]&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDay && c.anyDow:
+		return true
+	case c.anyDay:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next returns the first minute after t the expression matches, in t's location, or the zero
+// time when it matches nothing in the next five years (February 30th)
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case c.bits[3]&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.day(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+		case c.bits[1]&(1<<uint(t.Hour())) == 0:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+		case c.bits[0]&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
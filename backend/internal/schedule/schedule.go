@@ -0,0 +1,265 @@
+// Package schedule opens and closes the windows of the config file's schedules section, during
+// which alerts stay off the webhooks, rooms stream another share of their packets or captures
+// are kept for longer.
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"vibes-network-visualizer/internal/config"
+)
+
+// maxDuration bounds how long a cron window stays open; longer ones are one-off windows
+const maxDuration = 31 * 24 * time.Hour
+
+// window is a validated config.Schedule
+type window struct {
+	name       string
+	cron       *Cron // nil for a one-off window
+	expr       string
+	loc        *time.Location
+	duration   time.Duration
+	from       time.Time
+	until      time.Time
+	muteAlerts []string
+	mute       map[string]bool
+	muteAll    bool
+	sampleRate float64
+	rooms      map[string]bool // nil: every room
+	retention  *config.Retention
+}
+
+// opened returns when the window's current opening began, or the zero time when it is closed at t
+func (w *window) opened(t time.Time) time.Time {
+	if w.cron == nil {
+		if !t.Before(w.from) && t.Before(w.until) {
+			return w.from
+		}
+		return time.Time{}
+	}
+	t = t.In(w.loc)
+	start := w.cron.Next(t.Add(-w.duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}
+	}
+	return start
+}
+
+// next returns when the window opens next after t, or the zero time when it never does again
+func (w *window) next(t time.Time) time.Time {
+	if w.cron == nil {
+		if t.Before(w.from) {
+			return w.from
+		}
+		return time.Time{}
+	}
+	return w.cron.Next(t.In(w.loc))
+}
+
+// Schedules are the configured windows, in config order
+type Schedules struct {
+	windows []*window
+}
+
+// New validates the schedules section; it returns nil when there are no schedules
+func New(cfgs []config.Schedule) (*Schedules, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	s := &Schedules{}
+	names := make(map[string]bool)
+	for _, cfg := range cfgs {
+		switch {
+		case cfg.Name == "":
+			return nil, fmt.Errorf("every schedule needs a name")
+		case names[cfg.Name]:
+			return nil, fmt.Errorf("schedule %s: duplicate name", cfg.Name)
+		}
+		names[cfg.Name] = true
+		w, err := newWindow(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %v", cfg.Name, err)
+		}
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+func newWindow(cfg config.Schedule) (*window, error) {
+	w := &window{
+		name:       cfg.Name,
+		expr:       cfg.Cron,
+		loc:        time.Local,
+		duration:   cfg.Duration.Duration,
+		muteAlerts: cfg.MuteAlerts,
+		sampleRate: cfg.SampleRate,
+		retention:  cfg.Retention,
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %v", err)
+		}
+		w.loc = loc
+	}
+	switch {
+	case cfg.Cron != "" && (cfg.From != "" || cfg.Until != ""):
+		return nil, fmt.Errorf("cron and from/until don't mix")
+	case cfg.Cron != "":
+		cron, err := ParseCron(cfg.Cron)
+		if err != nil {
+			return nil, err
+		}
+		if w.duration < time.Minute || w.duration > maxDuration {
+			return nil, fmt.Errorf("duration must be between 1m and %v", maxDuration)
+		}
+		if cron.Next(time.Now().In(w.loc)).IsZero() {
+			return nil, fmt.Errorf("cron %q never matches", cfg.Cron)
+		}
+		w.cron = cron
+	case cfg.From != "" && cfg.Until != "":
+		var err error
+		if w.from, err = time.Parse(time.RFC3339, cfg.From); err != nil {
+			return nil, fmt.Errorf("from: %v", err)
+		}
+		if w.until, err = time.Parse(time.RFC3339, cfg.Until); err != nil {
+			return nil, fmt.Errorf("until: %v", err)
+		}
+		if !w.until.After(w.from) {
+			return nil, fmt.Errorf("until must be after from")
+		}
+	default:
+		return nil, fmt.Errorf("needs a cron and a duration, or from and until")
+	}
+
+	for _, selector := range cfg.MuteAlerts {
+		if selector == "*" {
+			w.muteAll = true
+			continue
+		}
+		if w.mute == nil {
+			w.mute = make(map[string]bool, len(cfg.MuteAlerts))
+		}
+		w.mute[selector] = true
+	}
+	if w.sampleRate < 0 || w.sampleRate > 1 {
+		return nil, fmt.Errorf("sample_rate must be between 0 and 1")
+	}
+	if len(cfg.Rooms) > 0 {
+		if w.sampleRate == 0 {
+			return nil, fmt.Errorf("rooms only applies to sample_rate")
+		}
+		w.rooms = make(map[string]bool, len(cfg.Rooms))
+		for _, room := range cfg.Rooms {
+			w.rooms[room] = true
+		}
+	}
+	if r := w.retention; r != nil && (r.MaxAge.Duration < 0 || r.MaxTotalGB < 0 || r.CompressAfter.Duration < 0) {
+		return nil, fmt.Errorf("retention: max_age, max_total_gb and compress_after can't be negative")
+	}
+	if !w.muteAll && w.mute == nil && w.sampleRate == 0 && w.retention == nil {
+		return nil, fmt.Errorf("changes nothing: set mute_alerts, sample_rate or retention")
+	}
+	return w, nil
+}
+
+// State is what the windows open at one moment change
+type State struct {
+	Open       []string          // names of the open windows, in config order
+	Retention  *config.Retention // the retention overrides of the first open window with some
+	mute       map[string]bool
+	muteAll    bool
+	sampleRate map[string]float64 // by room, for windows listing rooms
+	anyRoom    float64            // every other room's; 0 for none
+}
+
+// At returns what the windows open at t change, or nil when none is open
+func (s *Schedules) At(t time.Time) *State {
+	if s == nil {
+		return nil
+	}
+	var state *State
+	for _, w := range s.windows {
+		if w.opened(t).IsZero() {
+			continue
+		}
+		if state == nil {
+			state = &State{mute: make(map[string]bool), sampleRate: make(map[string]float64)}
+		}
+		state.Open = append(state.Open, w.name)
+		state.muteAll = state.muteAll || w.muteAll
+		for selector := range w.mute {
+			state.mute[selector] = true
+		}
+		if state.Retention == nil {
+			state.Retention = w.retention
+		}
+		switch {
+		case w.sampleRate == 0:
+		case w.rooms == nil:
+			if state.anyRoom == 0 {
+				state.anyRoom = w.sampleRate
+			}
+		case state.anyRoom == 0:
+			for room := range w.rooms {
+				if _, ok := state.sampleRate[room]; !ok {
+					state.sampleRate[room] = w.sampleRate
+				}
+			}
+		}
+	}
+	return state
+}
+
+// Muted reports whether an open window keeps an alert off the webhooks
+func (s *State) Muted(alertType, kind string) bool {
+	return s != nil && (s.muteAll || s.mute[alertType] || s.mute[alertType+":"+kind])
+}
+
+// SampleRate returns the share of a room's unpinned packets an open window streams instead of
+// the room's own, and false when none changes it
+func (s *State) SampleRate(room string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	if rate, ok := s.sampleRate[room]; ok {
+		return rate, true
+	}
+	return s.anyRoom, s.anyRoom > 0
+}
+
+// Status is one schedule as /api/schedules reports it
+type Status struct {
+	Name       string     `json:"name"`
+	Cron       string     `json:"cron,omitempty"`
+	Open       bool       `json:"open"`
+	OpenedAt   *time.Time `json:"opened_at,omitempty"`
+	ClosesAt   *time.Time `json:"closes_at,omitempty"`
+	NextOpen   *time.Time `json:"next_open,omitempty"` // closed windows only
+	MuteAlerts []string   `json:"mute_alerts,omitempty"`
+	SampleRate float64    `json:"sample_rate,omitempty"`
+	Retention  bool       `json:"retention"` // whether it overrides the retention section
+}
+
+// Status reports where each schedule stands at t
+func (s *Schedules) Status(t time.Time) []Status {
+	if s == nil {
+		return []Status{}
+	}
+	statuses := make([]Status, 0, len(s.windows))
+	for _, w := range s.windows {
+		status := Status{Name: w.name, Cron: w.expr, MuteAlerts: w.muteAlerts, SampleRate: w.sampleRate, Retention: w.retention != nil}
+		if opened := w.opened(t); !opened.IsZero() {
+			closes := w.until
+			if w.cron != nil {
+				closes = opened.Add(w.duration)
+			}
+			status.Open, status.OpenedAt, status.ClosesAt = true, &opened, &closes
+		} else if next := w.next(t); !next.IsZero() {
+			status.NextOpen = &next
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
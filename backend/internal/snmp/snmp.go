@@ -0,0 +1,283 @@
+// Package snmp is a minimal SNMPv2c client: enough to GET a switch port's interface counters
+// without pulling in a full SNMP stack.
+package snmp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BER tags used by SNMPv2c
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagOID         = 0x06
+	tagSequence    = 0x30
+	tagCounter32   = 0x41
+	tagGauge32     = 0x42
+	tagTimeTicks   = 0x43
+	tagCounter64   = 0x46
+	tagGetRequest  = 0xa0
+	tagResponse    = 0xa2
+
+	version2c = 1
+)
+
+// Client reads numeric objects from one SNMPv2c agent
+type Client struct {
+	Addr      string // host:port
+	Community string
+	Timeout   time.Duration // per attempt
+	Retries   int           // attempts after the first that timed out
+}
+
+// NewClient creates a client for an agent; host may carry a port, 161 otherwise
+func NewClient(host, community string, timeout time.Duration) *Client {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "161")
+	}
+	return &Client{Addr: host, Community: community, Timeout: timeout, Retries: 1}
+}
+
+// Get reads numeric objects (counters, gauges, integers and time ticks) in one request. Objects
+// the agent doesn't have are left out of the result.
+func (c *Client) Get(oids ...string) (map[string]uint64, error) {
+	requestID := rand.Int31()
+	request, err := encodeGet(c.Community, requestID, oids)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("udp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 65535)
+	for attempt := 0; ; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(c.Timeout))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() && attempt < c.Retries {
+					break
+				}
+				return nil, err
+			}
+			values, id, err := decodeResponse(buf[:n])
+			if err != nil {
+				return nil, err
+			}
+			if id == requestID {
+				return values, nil
+			}
+			// A late answer to an earlier attempt; keep waiting for ours
+		}
+	}
+}
+
+func encodeGet(community string, requestID int32, oids []string) ([]byte, error) {
+	var varbinds []byte
+	for _, oid := range oids {
+		encoded, err := encodeOID(oid)
+		if err != nil {
+			return nil, err
+		}
+		varbinds = append(varbinds, tlv(tagSequence, append(tlv(tagOID, encoded), tlv(tagNull, nil)...))...)
+	}
+	pdu := append(encodeInt(int64(requestID)), encodeInt(0)...) // error-status
+	pdu = append(pdu, encodeInt(0)...)                          // error-index
+	pdu = append(pdu, tlv(tagSequence, varbinds)...)
+	message := append(encodeInt(version2c), tlv(tagOctetString, []byte(community))...)
+	message = append(message, tlv(tagGetRequest, pdu)...)
+	return tlv(tagSequence, message), nil
+}
+
+func decodeResponse(data []byte) (map[string]uint64, int32, error) {
+	message, err := expect(&data, tagSequence)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := expect(&message, tagInteger); err != nil { // version
+		return nil, 0, err
+	}
+	if _, err := expect(&message, tagOctetString); err != nil { // community
+		return nil, 0, err
+	}
+	pdu, err := expect(&message, tagResponse)
+	if err != nil {
+		return nil, 0, err
+	}
+	var header [3]uint64
+	for i := range header {
+		value, err := expect(&pdu, tagInteger)
+		if err != nil {
+			return nil, 0, err
+		}
+		header[i] = decodeUint(value)
+	}
+	requestID := int32(header[0])
+	if header[1] != 0 {
+		return nil, requestID, fmt.Errorf("agent returned error status %d for object %d", header[1], header[2])
+	}
+	varbinds, err := expect(&pdu, tagSequence)
+	if err != nil {
+		return nil, requestID, err
+	}
+
+	values := make(map[string]uint64)
+	for len(varbinds) > 0 {
+		varbind, err := expect(&varbinds, tagSequence)
+		if err != nil {
+			return nil, requestID, err
+		}
+		oid, err := expect(&varbind, tagOID)
+		if err != nil {
+			return nil, requestID, err
+		}
+		tag, value, err := next(&varbind)
+		if err != nil {
+			return nil, requestID, err
+		}
+		switch tag {
+		case tagInteger, tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+			values[decodeOID(oid)] = decodeUint(value)
+		}
+		// noSuchObject, noSuchInstance and endOfMibView leave the object out
+	}
+	return values, requestID, nil
+}
+
+// tlv encodes one BER element
+func tlv(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	if n := len(content); n < 0x80 {
+		out = append(out, byte(n))
+	} else {
+		var length []byte
+		for ; n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, content...)
+}
+
+func encodeInt(v int64) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(v)}, content...)
+		if v >= -128 && v < 128 {
+			break // what is left is the sign of the byte just written
+		}
+		v >>= 8
+	}
+	return tlv(tagInteger, content)
+}
+
+func encodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+	arcs := make([]uint64, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", oid)
+		}
+		arcs[i] = arc
+	}
+	if arcs[0] > 2 || (arcs[0] < 2 && arcs[1] >= 40) {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+	var out []byte
+	for _, arc := range append([]uint64{arcs[0]*40 + arcs[1]}, arcs[2:]...) {
+		encoded := []byte{byte(arc & 0x7f)}
+		for arc >>= 7; arc > 0; arc >>= 7 {
+			encoded = append([]byte{byte(arc&0x7f) | 0x80}, encoded...)
+		}
+		out = append(out, encoded...)
+	}
+	return out, nil
+}
+
+func decodeOID(content []byte) string {
+	var arcs []string
+	var arc uint64
+	for _, b := range content {
+		arc = arc<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			continue
+		}
+		if len(arcs) == 0 {
+			first := arc / 40
+			if first > 2 {
+				first = 2
+			}
+			arcs = append(arcs, strconv.FormatUint(first, 10), strconv.FormatUint(arc-first*40, 10))
+		} else {
+			arcs = append(arcs, strconv.FormatUint(arc, 10))
+		}
+		arc = 0
+	}
+	return strings.Join(arcs, ".")
+}
+
+// decodeUint reads a big-endian unsigned value; Counter64 values may carry a leading zero byte
+func decodeUint(content []byte) uint64 {
+	var v uint64
+	for _, b := range bytes.TrimLeft(content, "\x00") {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// next splits the first BER element off data
+func next(data *[]byte) (byte, []byte, error) {
+	d := *data
+	if len(d) < 2 {
+		return 0, nil, errors.New("truncated SNMP message")
+	}
+	tag, length, d := d[0], int(d[1]), d[2:]
+	if length&0x80 != 0 {
+		octets := length & 0x7f
+		if octets == 0 || octets > 4 || len(d) < octets {
+			return 0, nil, errors.New("malformed SNMP length")
+		}
+		length = 0
+		for _, b := range d[:octets] {
+			length = length<<8 | int(b)
+		}
+		d = d[octets:]
+	}
+	if length > len(d) {
+		return 0, nil, errors.New("truncated SNMP message")
+	}
+	*data = d[length:]
+	return tag, d[:length], nil
+}
+
+// expect splits off the first element, which must have the given tag
+func expect(data *[]byte, want byte) ([]byte, error) {
+	tag, content, err := next(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != want {
+		return nil, fmt.Errorf("unexpected SNMP element 0x%02x, wanted 0x%02x", tag, want)
+	}
+	return content, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// compressFile gzips a capture file in place (capture.pcap → capture.pcap.gz), keeping its
+// modification time so retention still ages it correctly
+func compressFile(path string) (saved int64, err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	target := path + ".gz"
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	compressed, err := os.Stat(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	if err := capture.RenameManifest(path, target, compressed.Size()); err != nil {
+		log.Printf("⚠️ Retention: manifest of %s not moved: %v", path, err)
+	}
+	return info.Size() - compressed.Size(), nil
+}
+
+func isCompressed(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".gz")
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package storage
+
+import "syscall"
+
+func diskUsage(path string) (*DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, err
+	}
+	total := uint64(stat.Blocks) * uint64(stat.Bsize)
+	free := uint64(stat.Bavail) * uint64(stat.Bsize)
+	return newDiskUsage(total, free), nil
+}
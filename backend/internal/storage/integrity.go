@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// QuarantineSuffix is appended to a damaged capture file's name, which takes it out of the
+// archive index, time window playback and retention until someone looks at it
+const QuarantineSuffix = ".corrupt"
+
+// integrityInterval is how often the archive directories are checked for newly rotated files
+const integrityInterval = 5 * time.Minute
+
+// maxIntegrityEvents is how many repairs and quarantines /api/storage lists
+const maxIntegrityEvents = 50
+
+// IntegrityEvent is one damaged capture file the checker repaired or quarantined
+type IntegrityEvent struct {
+	Path      string    `json:"path"`
+	Action    string    `json:"action"` // "repaired", "quarantined", or "damaged" when quarantining failed
+	Reason    string    `json:"reason"`
+	LostBytes int64     `json:"lost_bytes,omitempty"` // cut from a repaired file's incomplete last record
+	Time      time.Time `json:"time"`
+}
+
+// QuarantinedFile is a damaged capture file set aside with QuarantineSuffix
+type QuarantinedFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// IntegrityStatus is the result of the latest check, served by /api/storage
+type IntegrityStatus struct {
+	Dirs        []string          `json:"dirs"`
+	LastRun     time.Time         `json:"last_run"`
+	Checked     int64             `json:"checked"` // files verified since startup
+	Repaired    int64             `json:"repaired"`
+	Quarantined []QuarantinedFile `json:"quarantined"` // on disk now, including ones from earlier runs
+	Events      []IntegrityEvent  `json:"events"`      // most recent first
+}
+
+type verifiedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// IntegrityChecker verifies capture files once they have been rotated, so a crash that left a
+// file cut off mid-record or a damaged one never stalls playback. A truncated file loses its
+// incomplete last record; anything else wrong gets quarantined.
+type IntegrityChecker struct {
+	mu       sync.RWMutex
+	dirs     []string
+	status   IntegrityStatus
+	verified map[string]verifiedFile // size and modification time each file was verified at
+	stopChan chan struct{}
+
+	// OnWarning is called from the checking goroutine for each file repaired or quarantined
+	OnWarning func(message string)
+}
+
+// NewIntegrityChecker creates a checker for capture files under dirs; call Start to begin checking
+func NewIntegrityChecker(dirs []string) *IntegrityChecker {
+	return &IntegrityChecker{
+		dirs:     dirs,
+		status:   IntegrityStatus{Dirs: dirs, Quarantined: []QuarantinedFile{}, Events: []IntegrityEvent{}},
+		verified: make(map[string]verifiedFile),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start checks immediately and then every few minutes
+func (c *IntegrityChecker) Start() {
+	go func() {
+		c.Check()
+		ticker := time.NewTicker(integrityInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Check()
+			case <-c.stopChan:
+				return
+			}
+		}
+	}()
+	log.Printf("🩺 Verifying rotated capture files in %s", strings.Join(c.dirs, ", "))
+}
+
+// Stop halts checking
+func (c *IntegrityChecker) Stop() {
+	close(c.stopChan)
+}
+
+// Status returns the latest check's result
+func (c *IntegrityChecker) Status() IntegrityStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	status := c.status
+	status.Quarantined = append([]QuarantinedFile{}, c.status.Quarantined...)
+	status.Events = append([]IntegrityEvent{}, c.status.Events...)
+	return status
+}
+
+// Check runs one pass: every rotated capture file not yet verified at its current size is read
+// through, then repaired or quarantined if damaged
+func (c *IntegrityChecker) Check() {
+	now := time.Now()
+	files, quarantined := c.listFiles()
+
+	// A directory's newest file may be the one dumpcap is still writing, with a record half
+	// flushed; only files something newer has replaced are checked
+	newest := make(map[string]time.Time)
+	for _, f := range files {
+		dir := filepath.Dir(f.path)
+		if f.modTime.After(newest[dir]) {
+			newest[dir] = f.modTime
+		}
+	}
+
+	seen := make(map[string]struct{}, len(files))
+	var checked int64
+	var events []IntegrityEvent
+	for _, f := range files {
+		seen[f.path] = struct{}{}
+		if !f.modTime.Before(newest[filepath.Dir(f.path)]) || now.Sub(f.modTime) < activeFileGuard {
+			continue
+		}
+		c.mu.RLock()
+		v, ok := c.verified[f.path]
+		c.mu.RUnlock()
+		if ok && v.size == f.size && v.modTime.Equal(f.modTime) {
+			continue
+		}
+
+		checked++
+		event, size := c.verify(f)
+		if event != nil {
+			event.Time = now
+			events = append(events, *event)
+			if event.Action == "quarantined" {
+				quarantined = append(quarantined, QuarantinedFile{Path: f.path + QuarantineSuffix, Size: f.size, ModTime: f.modTime})
+				continue
+			}
+		}
+		c.mu.Lock()
+		c.verified[f.path] = verifiedFile{size: size, modTime: f.modTime}
+		c.mu.Unlock()
+	}
+
+	sort.Slice(quarantined, func(i, j int) bool { return quarantined[i].Path < quarantined[j].Path })
+	c.mu.Lock()
+	for path := range c.verified {
+		if _, ok := seen[path]; !ok {
+			delete(c.verified, path)
+		}
+	}
+	c.status.LastRun = now
+	c.status.Checked += checked
+	c.status.Quarantined = quarantined
+	for _, event := range events {
+		if event.Action == "repaired" {
+			c.status.Repaired++
+		}
+	}
+	c.status.Events = append(events, c.status.Events...)
+	if len(c.status.Events) > maxIntegrityEvents {
+		c.status.Events = c.status.Events[:maxIntegrityEvents]
+	}
+	c.mu.Unlock()
+
+	for _, event := range events {
+		message := fmt.Sprintf("capture file %s %s: %s", filepath.Base(event.Path), event.Action, event.Reason)
+		log.Printf("⚠️ Storage: %s", message)
+		if c.OnWarning != nil {
+			c.OnWarning(message)
+		}
+	}
+}
+
+// verify reads one file through, repairing or quarantining it when damaged. It returns what was
+// done, if anything, and the file's size afterwards.
+func (c *IntegrityChecker) verify(f captureFile) (*IntegrityEvent, int64) {
+	check, err := capture.VerifyCaptureFile(f.path)
+	switch {
+	case err == nil && !check.Truncated:
+		return nil, f.size
+	case err == nil && !isCompressed(f.path):
+		reason := fmt.Sprintf("cut off after %d packets", check.Packets)
+		if err := repairTruncated(f, check.ValidSize); err != nil {
+			return quarantine(f, fmt.Sprintf("%s, and could not be repaired: %v", reason, err)), f.size
+		}
+		return &IntegrityEvent{Path: f.path, Action: "repaired", Reason: reason, LostBytes: f.size - check.ValidSize}, check.ValidSize
+	case err == nil:
+		// The incomplete record is inside the gzip stream; cutting the stream short would not help
+		return quarantine(f, fmt.Sprintf("cut off after %d packets", check.Packets)), f.size
+	}
+	if os.IsNotExist(err) {
+		return nil, f.size // deleted by retention while being checked
+	}
+	return quarantine(f, err.Error()), f.size
+}
+
+// repairTruncated cuts a file back to its last complete record, keeping its modification time
+// so retention and the archive index still place it where it was
+func repairTruncated(f captureFile, size int64) error {
+	if err := os.Truncate(f.path, size); err != nil {
+		return err
+	}
+	return os.Chtimes(f.path, f.modTime, f.modTime)
+}
+
+// quarantine renames a damaged file out of the archive, and its manifest with it. A file that
+// can't be renamed is reported as damaged and left where it is.
+func quarantine(f captureFile, reason string) *IntegrityEvent {
+	if err := os.Rename(f.path, f.path+QuarantineSuffix); err != nil {
+		return &IntegrityEvent{Path: f.path, Action: "damaged", Reason: fmt.Sprintf("%s (not quarantined: %v)", reason, err)}
+	}
+	capture.RemoveManifest(f.path)
+	return &IntegrityEvent{Path: f.path, Action: "quarantined", Reason: reason}
+}
+
+// listFiles collects capture files and quarantined files in every directory (recursively)
+func (c *IntegrityChecker) listFiles() ([]captureFile, []QuarantinedFile) {
+	var files []captureFile
+	quarantined := []QuarantinedFile{}
+	for _, dir := range c.dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			quarantinedFile := strings.HasSuffix(path, QuarantineSuffix)
+			if !quarantinedFile && !capture.IsCaptureFile(path) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if quarantinedFile {
+				quarantined = append(quarantined, QuarantinedFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+				return nil
+			}
+			files = append(files, captureFile{path: path, size: info.Size(), modTime: info.ModTime()})
+			return nil
+		})
+	}
+	return files, quarantined
+}
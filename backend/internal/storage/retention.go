@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+	"vibes-network-visualizer/internal/config"
+)
+
+// activeFileGuard protects files dumpcap may still be writing from deletion
+const activeFileGuard = 2 * time.Minute
+
+// DiskUsage is the capacity of the filesystem holding a directory
+type DiskUsage struct {
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	FreePercent float64 `json:"free_percent"`
+}
+
+// DirStatus summarizes one managed directory
+type DirStatus struct {
+	Path       string     `json:"path"`
+	Files      int        `json:"files"`
+	TotalBytes int64      `json:"total_bytes"`
+	Oldest     time.Time  `json:"oldest,omitempty"`
+	Newest     time.Time  `json:"newest,omitempty"`
+	Disk       *DiskUsage `json:"disk,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// RetentionStatus is the result of the latest enforcement pass, served by /api/storage
+type RetentionStatus struct {
+	Policy       config.Retention `json:"policy"`
+	LastRun      time.Time        `json:"last_run"`
+	Dirs         []DirStatus      `json:"dirs"`
+	DeletedFiles int64            `json:"deleted_files"` // since startup
+	DeletedBytes int64            `json:"deleted_bytes"`
+	Warnings     []string         `json:"warnings"`
+}
+
+type captureFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// RetentionManager periodically deletes the oldest capture files to honor the age and size
+// limits, and warns when a filesystem is nearly full.
+type RetentionManager struct {
+	mu       sync.RWMutex
+	policy   config.Retention
+	status   RetentionStatus
+	warned   map[string]bool // warning conditions already reported via OnWarning
+	stopChan chan struct{}
+
+	// OnWarning is called from the enforcement goroutine for each new warning
+	OnWarning func(message string)
+}
+
+// NewRetentionManager creates a retention manager; call Start to begin enforcement
+func NewRetentionManager(policy config.Retention) (*RetentionManager, error) {
+	if len(policy.Dirs) == 0 {
+		return nil, fmt.Errorf("retention: no directories to manage")
+	}
+	if policy.MaxAge.Duration < 0 || policy.MaxTotalGB < 0 {
+		return nil, fmt.Errorf("retention: max_age and max_total_gb must not be negative")
+	}
+	if policy.WarnFreePercent <= 0 {
+		policy.WarnFreePercent = 10
+	}
+	if policy.CheckInterval.Duration <= 0 {
+		policy.CheckInterval.Duration = 5 * time.Minute
+	}
+
+	return &RetentionManager{
+		policy:   policy,
+		status:   RetentionStatus{Policy: policy, Warnings: []string{}},
+		warned:   make(map[string]bool),
+		stopChan: make(chan struct{}),
+	}, nil
+}
+
+// Start enforces the policy immediately and then every check interval
+func (m *RetentionManager) Start() {
+	go func() {
+		m.Enforce()
+		ticker := time.NewTicker(m.policy.CheckInterval.Duration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Enforce()
+			case <-m.stopChan:
+				return
+			}
+		}
+	}()
+	log.Printf("🧹 Storage retention enabled for %s (max age %s, max size %.1f GB)",
+		strings.Join(m.policy.Dirs, ", "), m.policy.MaxAge, m.policy.MaxTotalGB)
+}
+
+// Stop halts enforcement
+func (m *RetentionManager) Stop() {
+	close(m.stopChan)
+}
+
+// Status returns the latest enforcement result
+func (m *RetentionManager) Status() RetentionStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	status := m.status
+	status.Dirs = append([]DirStatus(nil), m.status.Dirs...)
+	status.Warnings = append([]string{}, m.status.Warnings...)
+	return status
+}
+
+// Enforce runs one retention pass: compress aged files, delete expired files, then delete
+// oldest files until under the size cap
+func (m *RetentionManager) Enforce() {
+	now := time.Now()
+	if m.policy.CompressAfter.Duration > 0 {
+		m.compressAged(now)
+	}
+	files, dirs := m.listFiles()
+
+	// Oldest first across all directories
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	maxTotal := int64(m.policy.MaxTotalGB * 1e9)
+
+	var deletedFiles, deletedBytes int64
+	for _, f := range files {
+		if now.Sub(f.modTime) < activeFileGuard {
+			break // everything after this is newer still
+		}
+		expired := m.policy.MaxAge.Duration > 0 && now.Sub(f.modTime) > m.policy.MaxAge.Duration
+		oversize := maxTotal > 0 && total > maxTotal
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			log.Printf("⚠️ Retention: failed to delete %s: %v", f.path, err)
+			continue
+		}
+		capture.RemoveManifest(f.path)
+		total -= f.size
+		deletedFiles++
+		deletedBytes += f.size
+	}
+	if deletedFiles > 0 {
+		log.Printf("🧹 Retention: deleted %d files (%.1f MB)", deletedFiles, float64(deletedBytes)/1e6)
+		// Re-list so the reported status reflects the deletions
+		_, dirs = m.listFiles()
+	}
+
+	// Keyed by condition so OnWarning fires once per episode, not on every pass as the numbers move
+	warnings := make(map[string]string)
+	if maxTotal > 0 && total > maxTotal {
+		warnings["size"] = fmt.Sprintf("capture files use %.1f GB, above the %.1f GB limit (recent files are protected)",
+			float64(total)/1e9, m.policy.MaxTotalGB)
+	}
+	for i := range dirs {
+		usage, err := diskUsage(dirs[i].Path)
+		if err != nil {
+			continue
+		}
+		dirs[i].Disk = usage
+		if usage.FreePercent < m.policy.WarnFreePercent {
+			warnings["disk:"+dirs[i].Path] = fmt.Sprintf("filesystem holding %s is nearly full: %.1f%% free (%.1f GB)",
+				dirs[i].Path, usage.FreePercent, float64(usage.FreeBytes)/1e9)
+		}
+	}
+
+	messages := make([]string, 0, len(warnings))
+	var fresh []string
+	m.mu.Lock()
+	for key, message := range warnings {
+		messages = append(messages, message)
+		if !m.warned[key] {
+			fresh = append(fresh, message)
+		}
+	}
+	for key := range m.warned {
+		if _, ok := warnings[key]; !ok {
+			delete(m.warned, key)
+		}
+	}
+	for key := range warnings {
+		m.warned[key] = true
+	}
+	sort.Strings(messages)
+	m.status.LastRun = now
+	m.status.Dirs = dirs
+	m.status.DeletedFiles += deletedFiles
+	m.status.DeletedBytes += deletedBytes
+	m.status.Warnings = messages
+	m.mu.Unlock()
+
+	for _, message := range messages {
+		log.Printf("⚠️ Storage: %s", message)
+	}
+	if m.OnWarning != nil {
+		for _, message := range fresh {
+			m.OnWarning(message)
+		}
+	}
+}
+
+// compressAged gzips closed capture files older than compress_after
+func (m *RetentionManager) compressAged(now time.Time) {
+	files, _ := m.listFiles()
+	var count int
+	var saved int64
+	for _, f := range files {
+		age := now.Sub(f.modTime)
+		if isCompressed(f.path) || age < activeFileGuard || age < m.policy.CompressAfter.Duration {
+			continue
+		}
+		n, err := compressFile(f.path)
+		if err != nil {
+			log.Printf("⚠️ Retention: failed to compress %s: %v", f.path, err)
+			continue
+		}
+		count++
+		saved += n
+	}
+	if count > 0 {
+		log.Printf("🗜️ Retention: compressed %d files, saved %.1f MB", count, float64(saved)/1e6)
+	}
+}
+
+// listFiles collects capture files in every managed directory (recursively)
+func (m *RetentionManager) listFiles() ([]captureFile, []DirStatus) {
+	var files []captureFile
+	dirs := make([]DirStatus, 0, len(m.policy.Dirs))
+
+	for _, dir := range m.policy.Dirs {
+		status := DirStatus{Path: dir}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !capture.IsCaptureFile(path) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files = append(files, captureFile{path: path, size: info.Size(), modTime: info.ModTime()})
+			status.Files++
+			status.TotalBytes += info.Size()
+			if status.Oldest.IsZero() || info.ModTime().Before(status.Oldest) {
+				status.Oldest = info.ModTime()
+			}
+			if info.ModTime().After(status.Newest) {
+				status.Newest = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			status.Error = err.Error()
+		}
+		dirs = append(dirs, status)
+	}
+	return files, dirs
+}
+
+func newDiskUsage(total, free uint64) *DiskUsage {
+	usage := &DiskUsage{TotalBytes: total, FreeBytes: free}
+	if total > 0 {
+		usage.FreePercent = float64(free) / float64(total) * 100
+	}
+	return usage
+}
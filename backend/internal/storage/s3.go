@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibes-network-visualizer/internal/capture"
+)
+
+// s3ChunkSize is how much of an object one ranged GET asks for. Replay reads files front to
+// back, so a chunk is fetched only when playback gets to it.
+const s3ChunkSize = 8 << 20
+
+// emptyPayloadHash is the SHA-256 of an empty request body, signed into every request
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config locates an S3 or S3-compatible (MinIO) bucket and the credentials to read it
+type S3Config struct {
+	Endpoint     string // e.g. http://minio:9000, addressed path-style; empty for AWS in Region
+	Region       string // us-east-1 when empty
+	AccessKey    string // requests go unsigned without one (public buckets)
+	SecretKey    string
+	SessionToken string // temporary credentials only
+}
+
+// S3Store is an archive in an S3 bucket, under an optional key prefix. Objects are listed for
+// the archive index and read with ranged GETs, so replaying a window of a multi-gigabyte capture
+// downloads only as far as playback goes.
+type S3Store struct {
+	config S3Config
+	bucket string
+	prefix string
+	base   *url.URL // the bucket's URL
+	client *http.Client
+}
+
+// NewS3Store opens an s3://bucket/prefix location
+func NewS3Store(location string, config S3Config) (*S3Store, error) {
+	loc, err := url.Parse(location)
+	if err != nil || loc.Scheme != "s3" || loc.Host == "" {
+		return nil, fmt.Errorf("invalid S3 location %q, want s3://bucket/prefix", location)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	var base *url.URL
+	if config.Endpoint != "" {
+		if base, err = url.Parse(strings.TrimSuffix(config.Endpoint, "/")); err != nil || base.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+		}
+		base.Path += "/" + loc.Host
+	} else {
+		base = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", loc.Host, config.Region)}
+	}
+
+	return &S3Store{
+		config: config,
+		bucket: loc.Host,
+		prefix: strings.TrimPrefix(loc.Path, "/"),
+		base:   base,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Location returns the store's s3:// URL
+func (s *S3Store) Location() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+// listBucketResult is the part of a ListObjectsV2 response the store reads
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Walk lists every capture file under the prefix, a page of up to 1000 at a time
+func (s *S3Store) Walk(fn func(capture.ArchiveObject)) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.prefix != "" {
+			query.Set("prefix", s.prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do("", query, "")
+		if err != nil {
+			return err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("listing %s: %v", s.Location(), err)
+		}
+
+		for _, obj := range page.Contents {
+			if capture.IsCaptureFile(obj.Key) {
+				fn(capture.ArchiveObject{Path: s.path(obj.Key), Size: obj.Size, ModTime: obj.LastModified})
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// OpenCapture reads a capture file from the bucket as it is decoded
+func (s *S3Store) OpenCapture(path string) (capture.CaptureFile, error) {
+	key, ok := s.key(path)
+	if !ok {
+		return nil, fmt.Errorf("%s is not in %s", path, s.Location())
+	}
+	return capture.ReadCaptureFile(&s3RangeReader{store: s, key: key, size: -1}, path)
+}
+
+// ReadManifest reads the sidecar manifest uploaded next to a capture file, so the bucket's files
+// are indexed without downloading them
+func (s *S3Store) ReadManifest(path string) ([]byte, error) {
+	key, ok := s.key(path)
+	if !ok {
+		return nil, fmt.Errorf("%s is not in %s", path, s.Location())
+	}
+	resp, err := s.do(key+capture.ManifestSuffix, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (s *S3Store) path(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+func (s *S3Store) key(path string) (string, bool) {
+	return strings.CutPrefix(path, "s3://"+s.bucket+"/")
+}
+
+// do sends a signed GET for an object (key) or the bucket (key ""), with an optional Range. Any
+// status but 200, 206 and 416 is returned as an error carrying S3's message.
+func (s *S3Store) do(key string, query url.Values, byteRange string) (*http.Response, error) {
+	// SigV4 signs the path percent-encoded byte by byte, so keys are sent that way too
+	target := *s.base
+	target.RawPath = target.EscapedPath()
+	if key != "" {
+		target.Path += "/" + key
+		for _, segment := range strings.Split(key, "/") {
+			target.RawPath += "/" + s3Escape(segment)
+		}
+	} else if target.Path == "" {
+		target.Path, target.RawPath = "/", "/"
+	}
+	target.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return resp, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return nil, fmt.Errorf("s3 %s: %s: %s", resp.Status, s3Err.Code, s3Err.Message)
+	}
+	return nil, fmt.Errorf("s3 %s", resp.Status)
+}
+
+// sign adds AWS Signature Version 4 headers to a bodiless request; without an access key the
+// request goes anonymous
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+	if s.config.AccessKey == "" {
+		return
+	}
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	scope := day + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), day)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery sorts and strictly percent-encodes query parameters, as SigV4 wants them
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes everything but the unreserved characters
+func s3Escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3RangeReader reads an object front to back, s3ChunkSize bytes per GET. A connection that
+// drops mid-chunk is picked up again from the byte it stopped at.
+type s3RangeReader struct {
+	store   *S3Store
+	key     string
+	offset  int64
+	size    int64 // -1 until the first response says
+	end     int64 // offset the current chunk ends at
+	body    io.ReadCloser
+	retried bool // the current offset was already asked for again once
+}
+
+func (r *s3RangeReader) Read(p []byte) (int, error) {
+	for {
+		if r.size >= 0 && r.offset >= r.size {
+			return 0, io.EOF
+		}
+		if r.body == nil {
+			if err := r.fetch(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if err == nil && r.offset < r.end {
+			return n, nil
+		}
+		r.body.Close()
+		r.body = nil
+		if n > 0 {
+			r.retried = false
+			return n, nil
+		}
+		if r.offset < r.end {
+			// Cut off before the chunk ended: ask again from here, once
+			if r.retried {
+				if err == nil || err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, fmt.Errorf("s3 %s: %v", r.key, err)
+			}
+			r.retried = true
+		}
+	}
+}
+
+// fetch starts the GET for the chunk at the current offset
+func (r *s3RangeReader) fetch() error {
+	byteRange := fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+s3ChunkSize-1)
+	resp, err := r.store.do(r.key, nil, byteRange)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		r.size = r.offset
+		return nil
+	case http.StatusOK:
+		// The server ignored the range and sends the whole object
+		if r.offset > 0 {
+			resp.Body.Close()
+			return fmt.Errorf("s3 %s: server doesn't support ranged reads", r.key)
+		}
+		if resp.ContentLength < 0 {
+			resp.Body.Close()
+			return fmt.Errorf("s3 %s: response has no length", r.key)
+		}
+		r.size, r.end = resp.ContentLength, resp.ContentLength
+	default:
+		// Content-Range: bytes 0-8388607/123456789
+		first, last, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || first != r.offset {
+			resp.Body.Close()
+			return fmt.Errorf("s3 %s: unexpected Content-Range %q", r.key, resp.Header.Get("Content-Range"))
+		}
+		r.size = total
+		r.end = last + 1
+	}
+	r.body = resp.Body
+	return nil
+}
+
+func parseContentRange(header string) (first, last, total int64, ok bool) {
+	span, size, found := strings.Cut(strings.TrimPrefix(header, "bytes "), "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	from, to, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	var err1, err2, err3 error
+	first, err1 = strconv.ParseInt(from, 10, 64)
+	last, err2 = strconv.ParseInt(to, 10, 64)
+	total, err3 = strconv.ParseInt(size, 10, 64)
+	return first, last, total, err1 == nil && err2 == nil && err3 == nil
+}
+
+func (r *s3RangeReader) Close() error {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package streamrec records the exact WebSocket message stream a room receives and plays it back
+// with the original timing. A recording is a JSON-lines file: a header line, then one line per
+// message holding its offset from the start and the message bytes as they were sent.
+package streamrec
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Extension ends every recording's file name
+const Extension = ".stream.jsonl"
+
+// flushInterval bounds how much of a recording a crash can lose
+const flushInterval = time.Second
+
+// Header is the first line of a recording
+type Header struct {
+	Room      string    `json:"room"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// line is one recorded message; M is the message verbatim
+type line struct {
+	T int64           `json:"t"` // ms since StartedAt
+	M json.RawMessage `json:"m"`
+}
+
+// Status describes an in-progress or finished recording
+type Status struct {
+	File      string    `json:"file"`
+	Room      string    `json:"room"`
+	StartedAt time.Time `json:"started_at"`
+	Messages  int64     `json:"messages"`
+	Bytes     int64     `json:"bytes"` // message bytes, as sent
+}
+
+// Recorder appends messages to a recording file
+type Recorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	flushedAt time.Time
+	status    Status
+	closed    bool
+}
+
+// Create starts a new recording of room in dir
+func Create(dir, room string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating stream recording directory: %w", err)
+	}
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s%s", room, now.Format("20060102-150405.000"), Extension))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("creating stream recording: %w", err)
+	}
+	r := &Recorder{
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		flushedAt: now,
+		status:    Status{File: path, Room: room, StartedAt: now},
+	}
+	header, _ := json.Marshal(Header{Room: room, StartedAt: now})
+	r.writer.Write(append(header, '\n'))
+	return r, nil
+}
+
+// Write appends a message as it was sent. Messages that aren't JSON are skipped: every message
+// vibes sends is.
+func (r *Recorder) Write(msg []byte) error {
+	if !json.Valid(msg) {
+		return nil
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	fmt.Fprintf(r.writer, `{"t":%d,"m":`, now.Sub(r.status.StartedAt).Milliseconds())
+	r.writer.Write(msg)
+	if _, err := r.writer.WriteString("}\n"); err != nil {
+		return err
+	}
+	r.status.Messages++
+	r.status.Bytes += int64(len(msg))
+	if now.Sub(r.flushedAt) >= flushInterval {
+		r.flushedAt = now
+		return r.writer.Flush()
+	}
+	return nil
+}
+
+// Status returns a copy of the recording progress
+func (r *Recorder) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Close finishes the recording and returns its final status
+func (r *Recorder) Close() (Status, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.status, nil
+	}
+	r.closed = true
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return r.status, err
+	}
+	return r.status, r.file.Close()
+}
+
+// Recording is a finished or in-progress recording file, as listed by List
+type Recording struct {
+	Name      string    `json:"name"`
+	Room      string    `json:"room"`
+	StartedAt time.Time `json:"started_at"`
+	Size      int64     `json:"size"`
+	Modified  time.Time `json:"modified"`
+}
+
+// List returns the recordings in dir, newest first
+func List(dir string) ([]Recording, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Recording{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	recordings := []Recording{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), Extension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		recording := Recording{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime()}
+		if player, err := Open(filepath.Join(dir, entry.Name())); err == nil {
+			recording.Room, recording.StartedAt = player.Header.Room, player.Header.StartedAt
+			player.Close()
+		}
+		recordings = append(recordings, recording)
+	}
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].Modified.After(recordings[j].Modified) })
+	return recordings, nil
+}
+
+// Path resolves a recording name from List within dir, refusing anything that isn't one
+func Path(dir, name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || !strings.HasSuffix(name, Extension) {
+		return "", fmt.Errorf("invalid stream recording name %q", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Player reads a recording back
+type Player struct {
+	Header  Header
+	file    *os.File
+	scanner *bufio.Scanner
+}
+
+// Open opens a recording and reads its header
+func Open(path string) (*Player, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{file: file}
+	if err := p.rewind(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Player) rewind() error {
+	if _, err := p.file.Seek(0, 0); err != nil {
+		return err
+	}
+	p.scanner = bufio.NewScanner(p.file)
+	p.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s: empty stream recording", p.file.Name())
+	}
+	if err := json.Unmarshal(p.scanner.Bytes(), &p.Header); err != nil {
+		return fmt.Errorf("%s: not a stream recording: %v", p.file.Name(), err)
+	}
+	return nil
+}
+
+// Rewind starts the recording over
+func (p *Player) Rewind() error {
+	return p.rewind()
+}
+
+// Next returns the next message and its offset from the start of the recording; ok is false at
+// the end. A last line cut short by a crash ends the recording.
+func (p *Player) Next() (offset time.Duration, msg []byte, ok bool, err error) {
+	for p.scanner.Scan() {
+		var l line
+		if err := json.Unmarshal(p.scanner.Bytes(), &l); err != nil {
+			return 0, nil, false, nil
+		}
+		if len(l.M) == 0 {
+			continue
+		}
+		return time.Duration(l.T) * time.Millisecond, l.M, true, nil
+	}
+	return 0, nil, false, p.scanner.Err()
+}
+
+// Close closes the recording file
+func (p *Player) Close() error {
+	return p.file.Close()
+}
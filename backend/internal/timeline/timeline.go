@@ -0,0 +1,156 @@
+// Package timeline keeps notable moments (annotations, alerts, scans, mode switches) so a time
+// window replay can show them again and /api/timeline can list them. Events are appended to a
+// JSON-lines file and held in memory, in time order.
+package timeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultQueryLimit is how many events a query returns when it doesn't say
+const DefaultQueryLimit = 500
+
+// Event kinds
+const (
+	KindAnnotation = "annotation" // text an operator pushed to a room
+	KindAlert      = "alert"      // tcp_anomaly, sensor_alert, storage_warning, ...
+	KindScan       = "scan"       // an alert that is a scan of the network, such as a traceroute
+	KindMode       = "mode"       // a room went into a time window, back to live or to another capture profile
+)
+
+// Event is one moment on a room's timeline
+type Event struct {
+	Time    time.Time       `json:"time"`
+	Kind    string          `json:"kind"`
+	Room    string          `json:"room,omitempty"` // empty for server-wide events, which every room shows
+	Text    string          `json:"text"`
+	Marker  string          `json:"marker,omitempty"`  // how the UI draws it: "flag", "block", "start", ...
+	Actor   string          `json:"actor,omitempty"`   // remote address of whoever added it
+	Details json.RawMessage `json:"details,omitempty"` // alerts: the message as the room received it
+}
+
+// Query selects events; zero fields match everything
+type Query struct {
+	From  time.Time
+	To    time.Time
+	Room  string // this room's events and server-wide ones
+	Kind  string
+	Limit int // most recent matches returned; DefaultQueryLimit when zero, no limit when negative
+}
+
+func (q *Query) matches(e *Event) bool {
+	switch {
+	case q.Room != "" && e.Room != q.Room && e.Room != "":
+		return false
+	case q.Kind != "" && e.Kind != q.Kind:
+		return false
+	}
+	return true
+}
+
+// Store appends events to a file and answers time range lookups from memory
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	events []Event // sorted by Time
+}
+
+// Open loads the events already in the file at path and opens it for appending
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				continue // a line cut short by a crash
+			}
+			s.events = append(s.events, e)
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading timeline %s: %v", path, err)
+		}
+		sort.SliceStable(s.events, func(i, j int) bool { return s.events[i].Time.Before(s.events[j].Time) })
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening timeline %s: %v", path, err)
+	}
+	s.file = file
+	return s, nil
+}
+
+// Path returns the timeline file's path
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Record stores an event; Time defaults to now
+func (s *Store) Record(e Event) (Event, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(&e)
+	if err != nil {
+		return e, err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		return e, err
+	}
+	// Clocks can step back; keep the slice sorted anyway
+	i := sort.Search(len(s.events), func(i int) bool { return s.events[i].Time.After(e.Time) })
+	s.events = append(s.events, Event{})
+	copy(s.events[i+1:], s.events[i:])
+	s.events[i] = e
+	return e, nil
+}
+
+// Query returns the most recent events matching q, oldest first
+func (s *Store) Query(q Query) []Event {
+	if q.Limit == 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := sort.Search(len(s.events), func(i int) bool { return !s.events[i].Time.Before(q.From) })
+	events := []Event{}
+	for i := start; i < len(s.events); i++ {
+		if !q.To.IsZero() && s.events[i].Time.After(q.To) {
+			break
+		}
+		if q.matches(&s.events[i]) {
+			events = append(events, s.events[i])
+		}
+	}
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[len(events)-q.Limit:]
+	}
+	return events
+}
+
+// Between returns a room's events, server-wide ones included, from from to to inclusive
+func (s *Store) Between(room string, from, to time.Time) []Event {
+	return s.Query(Query{From: from, To: to, Room: room, Limit: -1})
+}
+
+// Close closes the timeline file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
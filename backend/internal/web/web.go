@@ -0,0 +1,175 @@
+// Package web serves the frontend: the Vite build embedded in the binary, or a build directory on
+// disk during development. Paths that match no file get index.html, so client-side routes survive
+// a reload.
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dist holds `npm run build` output; without a build it holds a page saying the backend is up
+//
+//go:embed all:dist
+var dist embed.FS
+
+const (
+	// Vite puts content-hashed bundles under assets/, so a changed file always has a new name
+	immutableCache = "public, max-age=31536000, immutable"
+	// Everything else, index.html above all, must be checked on every load to pick up new builds
+	revalidateCache = "no-cache"
+	minGzipSize     = 1024
+)
+
+// compressible lists the extensions worth gzipping; images and fonts are compressed already
+var compressible = map[string]bool{
+	".html": true,
+	".js":   true,
+	".mjs":  true,
+	".css":  true,
+	".svg":  true,
+	".json": true,
+	".map":  true,
+	".txt":  true,
+	".xml":  true,
+	".wasm": true,
+}
+
+// Embedded returns the frontend built into the binary
+func Embedded() fs.FS {
+	sub, _ := fs.Sub(dist, "dist")
+	return sub
+}
+
+// file is a frontend file held in memory with its gzipped form
+type file struct {
+	modTime time.Time
+	size    int64  // of the file on disk, to notice a rebuild
+	etag    string // quoted, from the content hash
+	data    []byte
+	gzipped []byte // nil when compressing isn't worth it
+}
+
+// Handler serves a frontend build with cache headers and gzip. Files are read and compressed
+// once, on first request, and again when their size or modification time changes.
+type Handler struct {
+	fsys  fs.FS
+	mu    sync.Mutex
+	files map[string]*file
+}
+
+// New creates a handler for the build in fsys, which must hold index.html at its root
+func New(fsys fs.FS) *Handler {
+	return &Handler{fsys: fsys, files: make(map[string]*file)}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	f, err := h.load(name)
+	// A missing file with an extension is a missing asset, and a missing API path is a typo;
+	// anything else is a client-side route
+	if errors.Is(err, fs.ErrNotExist) && path.Ext(name) == "" && !strings.HasPrefix(name, "api/") {
+		name = "index.html"
+		f, err = h.load(name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "frontend unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.HasPrefix(name, "assets/") {
+		w.Header().Set("Cache-Control", immutableCache)
+	} else {
+		w.Header().Set("Cache-Control", revalidateCache)
+	}
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	data, etag := f.data, f.etag
+	if f.gzipped != nil {
+		w.Header().Set("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			data, etag = f.gzipped, strings.TrimSuffix(etag, `"`)+`-gzip"`
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, f.modTime, bytes.NewReader(data))
+}
+
+// load returns a file from the cache, reading it again if it changed on disk. Directories count
+// as missing.
+func (h *Handler) load(name string) (*file, error) {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if f, ok := h.files[name]; ok && f.size == info.Size() && f.modTime.Equal(info.ModTime()) {
+		return f, nil
+	}
+	data, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	f := &file{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		etag:    `"` + hex.EncodeToString(sum[:12]) + `"`,
+		data:    data,
+	}
+	if compressible[path.Ext(name)] && len(data) >= minGzipSize {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		gz.Write(data)
+		gz.Close()
+		if buf.Len() < len(data) {
+			f.gzipped = buf.Bytes()
+		}
+	}
+	h.files[name] = f
+	return f, nil
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
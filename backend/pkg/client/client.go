@@ -0,0 +1,415 @@
+// Package client subscribes to a vibes server over its WebSocket API. It reconnects with
+// backoff when the connection drops and decodes every frame into the typed messages in
+// messages.go, so other services can consume the enriched stream without the browser UI.
+package client
+
+import (
+	"bufio"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is the WebSocket message schema these types implement
+const ProtocolVersion = 1
+
+// ErrNotConnected is returned by commands sent while the client is reconnecting
+var ErrNotConnected = errors.New("not connected to vibes server")
+
+// Options selects the capture source, like the query parameters the frontend passes to /ws.
+// Zero values let the server use its command-line defaults.
+type Options struct {
+	Interface  string  // live capture interface
+	PCAP       string  // PCAP file on the server to replay
+	Speed      float64 // replay speed multiplier
+	ZeekTCP    string  // "true" or a listen address for Zeek conn.log ingest
+	Room       string  // room to join; empty joins "default"
+	Relay      string  // "1" for packets from every relay agent, or one sensor ID
+	Scenario   string  // simulate this scenario instead of capturing
+	Mix        string  // overlay this scenario ("1" for the default) on the capture; "0" turns off the server's -mix
+	Coalesce   bool    // latest-wins: when this client falls behind, the server drops its oldest queued messages
+	Stream     string  // "summary" for per-second EdgeSummary messages instead of packets, "conversations" for ConversationSummary
+	Compress   bool    // batch messages into deflate-compressed frames, for slow links to the server
+	OnComplete string  // when a PCAP replay ends: "loop", "switch_to_live" or "stop" (the default)
+
+	MinBackoff time.Duration // first reconnect delay (default 1s)
+	MaxBackoff time.Duration // reconnect delay cap (default 30s)
+
+	// OnConnect is called after every successful (re)connection, before messages flow
+	OnConnect func()
+	// OnDisconnect is called with the error that ended a connection
+	OnDisconnect func(err error)
+}
+
+// Client is a reconnecting subscriber to one vibes server
+type Client struct {
+	url      string
+	options  Options
+	messages chan Message
+
+	mu          sync.Mutex // guards conn and resumeToken, and serializes writes
+	conn        *websocket.Conn
+	resumeToken string // from the latest Mode; reconnections pass it to pick up where they left off
+}
+
+// New creates a client for a server base URL (http://host:8080 or ws://host:8080/ws). Call Run to connect.
+func New(server string, options Options) (*Client, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if !strings.HasSuffix(u.Path, "/ws") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/ws"
+	}
+
+	query := u.Query()
+	query.Set("v", strconv.Itoa(ProtocolVersion))
+	if options.Interface != "" {
+		query.Set("interface", options.Interface)
+	}
+	if options.PCAP != "" {
+		query.Set("pcap", options.PCAP)
+	}
+	if options.Speed > 0 {
+		query.Set("speed", strconv.FormatFloat(options.Speed, 'f', -1, 64))
+	}
+	if options.ZeekTCP != "" {
+		query.Set("zeek_tcp", options.ZeekTCP)
+	}
+	if options.Room != "" {
+		query.Set("room", options.Room)
+	}
+	if options.Relay != "" {
+		query.Set("relay", options.Relay)
+	}
+	if options.Scenario != "" {
+		query.Set("scenario", options.Scenario)
+	}
+	if options.Mix != "" {
+		query.Set("mix", options.Mix)
+	}
+	if options.Coalesce {
+		query.Set("coalesce", "1")
+	}
+	if options.Stream != "" {
+		query.Set("stream", options.Stream)
+	}
+	if options.Compress {
+		query.Set("compress", "deflate")
+	}
+	if options.OnComplete != "" {
+		query.Set("on_complete", options.OnComplete)
+	}
+	u.RawQuery = query.Encode()
+
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = time.Second
+	}
+	if options.MaxBackoff < options.MinBackoff {
+		options.MaxBackoff = 30 * time.Second
+	}
+
+	return &Client{
+		url:      u.String(),
+		options:  options,
+		messages: make(chan Message, 1024),
+	}, nil
+}
+
+// Messages returns the decoded message stream. It is closed when Run returns.
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Run connects and reads until ctx is cancelled, reconnecting after failures. A consumer that
+// stops draining Messages stalls the connection and the server drops packets for it.
+func (c *Client) Run(ctx context.Context) error {
+	defer close(c.messages)
+
+	backoff := c.options.MinBackoff
+	for {
+		connectedAt := time.Now()
+		err := c.runOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if c.options.OnDisconnect != nil {
+			c.options.OnDisconnect(err)
+		}
+
+		// A connection that stayed up for a while resets the backoff
+		if time.Since(connectedAt) > c.options.MaxBackoff {
+			backoff = c.options.MinBackoff
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > c.options.MaxBackoff {
+			backoff = c.options.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) runOnce(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.dialURL(), nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Unblock ReadMessage when the caller cancels
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if c.options.OnConnect != nil {
+		c.options.OnConnect()
+	}
+	if c.options.Compress {
+		return c.readCompressed(ctx, conn)
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := c.deliver(ctx, data); err != nil {
+			return err
+		}
+	}
+}
+
+// dialURL is the session URL, with the resume token of the previous connection if there was one
+func (c *Client) dialURL() string {
+	c.mu.Lock()
+	token := c.resumeToken
+	c.mu.Unlock()
+	if token == "" {
+		return c.url
+	}
+	return c.url + "&resume=" + url.QueryEscape(token)
+}
+
+// readCompressed reads a compressed session: binary frames that continue one deflate stream,
+// holding a message per line
+func (c *Client) readCompressed(ctx context.Context, conn *websocket.Conn) error {
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeReader.Close()
+	go func() {
+		for {
+			_, frame, err := conn.ReadMessage()
+			if err != nil {
+				pipeWriter.CloseWithError(err)
+				return
+			}
+			if _, err := pipeWriter.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	lines := bufio.NewScanner(flate.NewReader(pipeReader))
+	lines.Buffer(make([]byte, 64*1024), 16<<20)
+	for lines.Scan() {
+		if err := c.deliver(ctx, lines.Bytes()); err != nil {
+			return err
+		}
+	}
+	return lines.Err()
+}
+
+// deliver decodes one message onto the Messages channel
+func (c *Client) deliver(ctx context.Context, data []byte) error {
+	msg, err := Decode(data)
+	if err != nil {
+		return nil // malformed message; keep the stream going
+	}
+	if mode, ok := msg.(*Mode); ok {
+		c.mu.Lock()
+		c.resumeToken = mode.ResumeToken
+		c.mu.Unlock()
+	}
+	select {
+	case c.messages <- msg:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// Acknowledged once handed over, so a consumer that stops draining shows up as stale
+	if heartbeat, ok := msg.(*Heartbeat); ok {
+		c.Send(map[string]interface{}{"type": "heartbeat_ack", "seq": heartbeat.Seq})
+	}
+	return nil
+}
+
+// Send writes a raw command object; it must have a "type" field the server understands
+func (c *Client) Send(command interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteJSON(command)
+}
+
+// PinRule always forwards packets matching an IP, CIDR or a.b.c.d-e range, a hostname such as
+// *.ctf.example.com, or a port or protocol such as port:443, udp:53 or proto:icmp, despite sampling
+func (c *Client) PinRule(rule string) error {
+	return c.Send(map[string]interface{}{"type": "pinRule", "rule": rule})
+}
+
+// WatchRule pins a rule for ttl only; the room gets a "pin_expired" message when it lapses
+func (c *Client) WatchRule(rule string, ttl time.Duration) error {
+	return c.Send(map[string]interface{}{"type": "pinRule", "rule": rule, "ttl": ttl.Seconds()})
+}
+
+// UnpinRule removes a pinning rule
+func (c *Client) UnpinRule(rule string) error {
+	return c.Send(map[string]interface{}{"type": "unpinRule", "rule": rule})
+}
+
+// ClearAllPins removes every pinning rule
+func (c *Client) ClearAllPins() error {
+	return c.Send(map[string]interface{}{"type": "clearAllPins"})
+}
+
+// SelectTimeWindow starts playback of archived traffic between start and end
+func (c *Client) SelectTimeWindow(start, end time.Time, speed float64) error {
+	return c.Send(map[string]interface{}{
+		"type":       "select_time_window",
+		"start_time": start.Format(time.RFC3339),
+		"end_time":   end.Format(time.RFC3339),
+		"speed":      speed,
+	})
+}
+
+// SelectSessionTimeWindow starts playback of archived traffic between start and end for this
+// client alone, leaving the rest of its room on what it was watching
+func (c *Client) SelectSessionTimeWindow(start, end time.Time, speed float64) error {
+	return c.Send(map[string]interface{}{
+		"type":       "select_time_window",
+		"start_time": start.Format(time.RFC3339),
+		"end_time":   end.Format(time.RFC3339),
+		"speed":      speed,
+		"scope":      "session",
+	})
+}
+
+// SelectSampledTimeWindow starts playback of archived traffic between start and end, keeping
+// only rate (0-1] of its packets, chosen by strategy: "uniform", "flow" (whole conversations) or
+// "size" (larger packets first). Pinned addresses' packets are always kept.
+func (c *Client) SelectSampledTimeWindow(start, end time.Time, speed, rate float64, strategy string) error {
+	return c.Send(map[string]interface{}{
+		"type":        "select_time_window",
+		"start_time":  start.Format(time.RFC3339),
+		"end_time":    end.Format(time.RFC3339),
+		"speed":       speed,
+		"sample_rate": rate,
+		"sampling":    strategy,
+	})
+}
+
+// SeekToTime jumps within the active time window
+func (c *Client) SeekToTime(t time.Time) error {
+	return c.Send(map[string]interface{}{"type": "seek_to_time", "time": t.Format(time.RFC3339)})
+}
+
+// JumpBack rewinds time window playback by d (30 s when d is 0), never before the window's start
+func (c *Client) JumpBack(d time.Duration) error {
+	command := map[string]interface{}{"type": "jump_back"}
+	if d > 0 {
+		command["seconds"] = d.Seconds()
+	}
+	return c.Send(command)
+}
+
+// Bookmark saves the moment the room is looking at, with its view; the room is told with a
+// BookmarkAdded message
+func (c *Client) Bookmark(description string) error {
+	return c.Send(map[string]interface{}{"type": "bookmark", "description": description})
+}
+
+// SeekToBookmark restores a bookmark's view and jumps to its moment, starting a time window
+// around it when the room is live
+func (c *Client) SeekToBookmark(id string) error {
+	return c.Send(map[string]interface{}{"type": "seek_to_time", "bookmark": id})
+}
+
+// SwitchToLive leaves time window playback
+func (c *Client) SwitchToLive() error {
+	return c.Send(map[string]interface{}{"type": "switch_to_live"})
+}
+
+// StartRecording writes this session's packets to a PCAP on the server; filter is optional BPF
+func (c *Client) StartRecording(filter string) error {
+	return c.Send(map[string]interface{}{"type": "start_recording", "filter": filter})
+}
+
+// StopRecording finishes the session's recording
+func (c *Client) StopRecording() error {
+	return c.Send(map[string]interface{}{"type": "stop_recording"})
+}
+
+// SetStream switches this session to "raw" packets, per-second "summary" edge totals or
+// per-second "conversations" with both directions of a pair merged; the server answers with a
+// StreamMode message
+func (c *Client) SetStream(stream string) error {
+	return c.Send(map[string]interface{}{"type": "set_stream", "stream": stream})
+}
+
+// TriggerScenario starts an attack from the server's library (see /api/attacks) in the room's
+// simulated sessions; the room is told with a ScenarioTriggered message
+func (c *Client) TriggerScenario(name string) error {
+	return c.Send(map[string]interface{}{"type": "trigger_scenario", "name": name})
+}
+
+// Annotate pushes a note ("CTF started") to everyone in the room; marker is an optional label
+// for how it is drawn. Time window replays show it again.
+func (c *Client) Annotate(text, marker string) error {
+	return c.Send(map[string]interface{}{"type": "annotate", "text": text, "marker": marker})
+}
+
+// SwitchProfile moves the room to a capture profile from the server's config; token is the
+// server's -admin-token, or "" when it has none. The room is told with a ProfileSwitched message.
+func (c *Client) SwitchProfile(name, token string) error {
+	command := map[string]interface{}{"type": "switch_profile", "name": name}
+	if token != "" {
+		command["token"] = token
+	}
+	return c.Send(command)
+}
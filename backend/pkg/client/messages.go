@@ -0,0 +1,970 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Message is any decoded server → client message
+type Message interface {
+	MessageType() string
+}
+
+// Packet is a single captured, replayed or simulated packet ("packet")
+type Packet struct {
+	Type       string `json:"type"`
+	Src        string `json:"src"`
+	Dst        string `json:"dst"`
+	SrcPort    int    `json:"src_port"`
+	DstPort    int    `json:"dst_port"`
+	Size       int    `json:"size"`
+	Protocol   string `json:"protocol"`
+	Timestamp  int64  `json:"timestamp"` // Unix milliseconds
+	Source     string `json:"source"`    // real, simulated, pcap_replay, ...
+	TCPFlags   string `json:"tcp_flags,omitempty"`
+	Fragmented bool   `json:"fragmented,omitempty"` // an IPv4 fragment
+	Tunnel     string `json:"tunnel,omitempty"`     // "wireguard", "ipsec" or "openvpn"
+	Cast       string `json:"cast,omitempty"`       // "multicast" or "broadcast"
+	CastGroup  string `json:"cast_group,omitempty"` // e.g. "mdns", "ssdp", "igmp"
+	SrcGroup   string `json:"src_group,omitempty"`
+	DstGroup   string `json:"dst_group,omitempty"`
+	Sensor     string `json:"sensor,omitempty"`  // relay agent that captured it
+	SrcNAT     string `json:"src_nat,omitempty"` // public address Src was translated to by the NAT gateway
+	DstNAT     string `json:"dst_nat,omitempty"`
+	Intensity  string `json:"intensity,omitempty"` // the edge's rolling rate: quiet, normal or hot
+	Pinned     bool   `json:"pinned,omitempty"`    // to or from an address the room has pinned
+}
+
+// Mode is sent once per connection with the capture mode the server picked ("mode")
+type Mode struct {
+	Type          string  `json:"type"`
+	Mode          string  `json:"mode"`
+	Interface     string  `json:"interface"`
+	PCAPFile      string  `json:"pcapFile"`
+	ReplaySpeed   float64 `json:"replaySpeed"`
+	ZeekTCP       string  `json:"zeek_tcp"`
+	Protocol      int     `json:"protocol_version"`
+	Room          string  `json:"room"`
+	Scenario      string  `json:"scenario,omitempty"`     // simulated and mixed sessions only
+	Overlay       bool    `json:"overlay,omitempty"`      // a simulation is mixed into the capture
+	Anonymized    bool    `json:"anonymized,omitempty"`   // attendee addresses are pseudonyms
+	Coalesce      bool    `json:"coalesce,omitempty"`     // the server drops this client's oldest queued messages when it falls behind
+	Stream        string  `json:"stream,omitempty"`       // raw or summary
+	Compress      string  `json:"compress,omitempty"`     // deflate when Options.Compress was set
+	Profile       string  `json:"profile,omitempty"`      // the room's capture profile, when it has one
+	OnComplete    string  `json:"on_complete,omitempty"`  // what happens when a PCAP replay ends
+	ResumeToken   string  `json:"resume_token,omitempty"` // reconnections pass it to keep this session's parameters and playback
+	Resumed       bool    `json:"resumed,omitempty"`      // this connection picked up a previous session
+	TimeWindow    bool    `json:"time_window,omitempty"`  // resumed into the room's time window playback
+	Error         bool    `json:"error,omitempty"`
+	ErrorMsg      string  `json:"errorMsg,omitempty"`
+	RequestedMode string  `json:"requestedMode,omitempty"` // mode that failed when Error is set
+}
+
+// ConnEvent reports a TCP session opening or closing ("conn_open", "conn_close")
+type ConnEvent struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Src         string `json:"src"`
+	Dst         string `json:"dst"`
+	SrcPort     int    `json:"src_port"`
+	DstPort     int    `json:"dst_port"`
+	Protocol    string `json:"protocol"`
+	Reason      string `json:"reason,omitempty"`
+	Midstream   bool   `json:"midstream,omitempty"`
+	StartTime   int64  `json:"start_time"`
+	Timestamp   int64  `json:"timestamp"`
+	DurationMs  int64  `json:"duration_ms"`
+	BytesOut    int64  `json:"bytes_out"`
+	BytesIn     int64  `json:"bytes_in"`
+	PacketsOut  int64  `json:"packets_out"`
+	PacketsIn   int64  `json:"packets_in"`
+	Retransmits int64  `json:"retransmits"`
+}
+
+// HostCount is an IP with an associated count
+type HostCount struct {
+	IP    string `json:"ip"`
+	Count int    `json:"count"`
+}
+
+// FlowHealth summarizes retransmissions on one connection
+type FlowHealth struct {
+	ID             string  `json:"id"`
+	Src            string  `json:"src"`
+	Dst            string  `json:"dst"`
+	SrcPort        int     `json:"src_port"`
+	DstPort        int     `json:"dst_port"`
+	Packets        int64   `json:"packets"`
+	Retransmits    int64   `json:"retransmits"`
+	RetransmitRate float64 `json:"retransmit_rate"`
+}
+
+// TCPStats is the periodic TCP health summary ("tcp_stats")
+type TCPStats struct {
+	Type              string       `json:"type"`
+	Timestamp         int64        `json:"timestamp"`
+	IntervalMs        int64        `json:"interval_ms"`
+	ActiveConns       int          `json:"active_conns"`
+	Packets           uint64       `json:"packets"`
+	Retransmits       uint64       `json:"retransmits"`
+	RetransmitRate    float64      `json:"retransmit_rate"`
+	Resets            int          `json:"resets"`
+	ResetsPerSec      float64      `json:"resets_per_sec"`
+	TopResetSources   []HostCount  `json:"top_reset_sources"`
+	TopRetransmitters []FlowHealth `json:"top_retransmit_flows"`
+}
+
+// Alert flags an RST storm or a flow with a high retransmission rate ("tcp_anomaly")
+type Alert struct {
+	Type      string      `json:"type"`
+	Kind      string      `json:"kind"` // rst_storm or high_retransmission
+	Timestamp int64       `json:"timestamp"`
+	IP        string      `json:"ip,omitempty"`
+	Count     int         `json:"count,omitempty"`
+	Rate      float64     `json:"rate"`
+	Flow      *FlowHealth `json:"flow,omitempty"`
+}
+
+// GroupCounters holds traffic totals for one subnet group over a report interval
+type GroupCounters struct {
+	Name          string  `json:"name"`
+	PacketsTx     int64   `json:"packets_tx"`
+	PacketsRx     int64   `json:"packets_rx"`
+	BytesTx       int64   `json:"bytes_tx"`
+	BytesRx       int64   `json:"bytes_rx"`
+	PacketsPerSec float64 `json:"packets_per_sec"`
+	BitsPerSec    float64 `json:"bits_per_sec"`
+}
+
+// GroupStats is the periodic per-subnet-group traffic summary ("group_stats")
+type GroupStats struct {
+	Type       string           `json:"type"`
+	Timestamp  int64            `json:"timestamp"`
+	IntervalMs int64            `json:"interval_ms"`
+	Groups     []*GroupCounters `json:"groups"`
+}
+
+// ProtocolCounters holds traffic totals for one protocol or port bucket
+type ProtocolCounters struct {
+	Name    string `json:"name"`
+	Packets int64  `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// ProtocolStats is the periodic per-protocol and per-service breakdown of the room's traffic
+// ("protocol_stats"); Ports is empty when the room hides ports
+type ProtocolStats struct {
+	Type       string              `json:"type"`
+	Timestamp  int64               `json:"timestamp"`
+	IntervalMs int64               `json:"interval_ms"`
+	Packets    int64               `json:"packets"`
+	Bytes      int64               `json:"bytes"`
+	Protocols  []*ProtocolCounters `json:"protocols"`
+	Ports      []*ProtocolCounters `json:"ports,omitempty"`
+	Tunnels    []*ProtocolCounters `json:"tunnels,omitempty"`
+}
+
+// InterfaceSizes is one interface's packet size distribution
+type InterfaceSizes struct {
+	Interface string  `json:"interface"` // capture interface, relay sensor or source kind
+	Packets   int64   `json:"packets"`
+	Bytes     int64   `json:"bytes"`
+	Counts    []int64 `json:"counts"` // per bucket of SizeStats.Bounds, plus one for larger packets
+	Tiny      int64   `json:"tiny"`
+	MaxSize   int64   `json:"max_size"`
+	Jumbo     int64   `json:"jumbo"`
+}
+
+// SizeStats is the periodic packet size histogram per interface ("size_stats")
+type SizeStats struct {
+	Type       string            `json:"type"`
+	Timestamp  int64             `json:"timestamp"`
+	IntervalMs int64             `json:"interval_ms"`
+	Bounds     []int             `json:"bounds"` // bucket upper limits in bytes
+	Interfaces []*InterfaceSizes `json:"interfaces"`
+}
+
+// SizeAlert flags a spike of tiny, max-size or jumbo packets on an interface ("size_anomaly")
+type SizeAlert struct {
+	Type      string  `json:"type"`
+	Kind      string  `json:"kind"` // tiny_packet_spike, max_size_spike or jumbo_frames
+	Timestamp int64   `json:"timestamp"`
+	Interface string  `json:"interface"`
+	Count     int64   `json:"count"`
+	Packets   int64   `json:"packets"`
+	Fraction  float64 `json:"fraction"`
+	Baseline  float64 `json:"baseline"`
+}
+
+// FragmentStats is the periodic IPv4 fragmentation and reassembly summary ("fragment_stats")
+type FragmentStats struct {
+	Type        string      `json:"type"`
+	Timestamp   int64       `json:"timestamp"`
+	IntervalMs  int64       `json:"interval_ms"`
+	Fragments   int64       `json:"fragments"`
+	Bytes       int64       `json:"bytes"`
+	Datagrams   int64       `json:"datagrams"`
+	Reassembled int64       `json:"reassembled"`
+	TimedOut    int64       `json:"timed_out"`
+	Overlapping int64       `json:"overlapping"`
+	TinyFirst   int64       `json:"tiny_first"`
+	Pending     int         `json:"pending"`
+	TopSources  []HostCount `json:"top_sources"`
+}
+
+// DedupStats reports mirrored-tap duplicates removed by the server ("dedup_stats")
+type DedupStats struct {
+	Type       string  `json:"type"`
+	Timestamp  int64   `json:"timestamp"`
+	IntervalMs int64   `json:"interval_ms"`
+	Packets    int64   `json:"packets"`
+	Duplicates int64   `json:"duplicates"`
+	Ratio      float64 `json:"ratio"`
+	Total      int64   `json:"total"`
+}
+
+// InterfaceStats is an interface's link counters since the previous report: the captured one, or
+// a switch port polled over SNMP ("interface_stats"). Rates are per second, the error and drop
+// counts are for the interval.
+type InterfaceStats struct {
+	Type             string  `json:"type"`
+	Timestamp        int64   `json:"timestamp"`
+	IntervalMs       int64   `json:"interval_ms"`
+	Interface        string  `json:"interface"`
+	Source           string  `json:"source"`           // kernel, pcap or snmp
+	Device           string  `json:"device,omitempty"` // the switch, for snmp
+	RxBps            float64 `json:"rx_bps"`
+	TxBps            float64 `json:"tx_bps"`
+	RxPps            float64 `json:"rx_pps"`
+	TxPps            float64 `json:"tx_pps"`
+	RxErrors         uint64  `json:"rx_errors"`
+	TxErrors         uint64  `json:"tx_errors"`
+	RxDropped        uint64  `json:"rx_dropped"`
+	TxDropped        uint64  `json:"tx_dropped"`
+	SpeedMbps        int     `json:"speed_mbps,omitempty"`
+	Utilization      float64 `json:"utilization,omitempty"`
+	CaptureReceived  uint64  `json:"capture_received,omitempty"`
+	CaptureDropped   uint64  `json:"capture_dropped,omitempty"`
+	CaptureIfDropped uint64  `json:"capture_if_dropped,omitempty"`
+}
+
+// LayoutCommunity is a group of hosts in layout hints; Cores holds each member's k-core number
+type LayoutCommunity struct {
+	ID      int      `json:"id"` // kept while most members stay together
+	Members []string `json:"members"`
+	Cores   []int    `json:"cores"`
+}
+
+// CommunityLink is the traffic between two communities in layout hints
+type CommunityLink struct {
+	A      int     `json:"a"`
+	B      int     `json:"b"`
+	Weight float64 `json:"weight"`
+}
+
+// LayoutHints is the periodic coarse layout of a large graph ("layout_hints")
+type LayoutHints struct {
+	Type        string             `json:"type"`
+	Timestamp   int64              `json:"timestamp"`
+	Nodes       int                `json:"nodes"`
+	Edges       int                `json:"edges"`
+	Communities []*LayoutCommunity `json:"communities"`
+	Links       []*CommunityLink   `json:"links"`
+}
+
+// TTLStats is the periodic TTL summary of captured traffic ("ttl_stats")
+type TTLStats struct {
+	Type             string      `json:"type"`
+	Timestamp        int64       `json:"timestamp"`
+	IntervalMs       int64       `json:"interval_ms"`
+	Sources          int         `json:"sources"`
+	LowTTL           int64       `json:"low_ttl"`
+	Changes          int64       `json:"changes"`
+	Loops            int64       `json:"loops"`
+	TopLowTTLSources []HostCount `json:"top_low_ttl_sources"`
+}
+
+// LatencyStats is the periodic handshake round-trip summary per destination ("latency_stats")
+type LatencyStats struct {
+	Type         string               `json:"type"`
+	Timestamp    int64                `json:"timestamp"`
+	IntervalMs   int64                `json:"interval_ms"`
+	WindowMs     int64                `json:"window_ms"`
+	Handshakes   int64                `json:"handshakes"`
+	Destinations int                  `json:"destinations"`
+	Top          []DestinationLatency `json:"top"`
+}
+
+// DestinationLatency is one destination's round-trip percentiles over the window, in ms
+type DestinationLatency struct {
+	IP       string             `json:"ip"`
+	Samples  int                `json:"samples"`
+	RTT      LatencyPercentiles `json:"rtt"`      // SYN to ACK
+	Upstream LatencyPercentiles `json:"upstream"` // SYN to SYN/ACK
+}
+
+// LatencyPercentiles are round-trip times in milliseconds
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// TTLAlert flags a TTL change, low TTLs, a traceroute or a routing loop ("ttl_anomaly")
+type TTLAlert struct {
+	Type      string `json:"type"`
+	Kind      string `json:"kind"` // ttl_change, low_ttl, traceroute or routing_loop
+	Timestamp int64  `json:"timestamp"`
+	IP        string `json:"ip"`
+	Dst       string `json:"dst,omitempty"`
+	TTL       uint8  `json:"ttl,omitempty"`
+	UsualTTL  uint8  `json:"usual_ttl,omitempty"`
+	Count     int    `json:"count"`
+}
+
+// WirelessNetwork is one access point heard by a monitor-mode capture
+type WirelessNetwork struct {
+	BSSID    string `json:"bssid"`
+	SSID     string `json:"ssid"`
+	Channel  int    `json:"channel,omitempty"`
+	RSSI     int    `json:"rssi,omitempty"` // dBm
+	Beacons  int64  `json:"beacons"`
+	Frames   int64  `json:"frames"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// SSIDCount is how often clients probed for an SSID
+type SSIDCount struct {
+	SSID  string `json:"ssid"`
+	Count int    `json:"count"`
+}
+
+// WirelessStats is the periodic 802.11 summary of a monitor-mode capture ("wifi_stats")
+type WirelessStats struct {
+	Type       string             `json:"type"`
+	Timestamp  int64              `json:"timestamp"`
+	IntervalMs int64              `json:"interval_ms"`
+	Frames     int64              `json:"frames"`
+	FrameTypes map[string]int64   `json:"frame_types"`
+	Stations   int                `json:"stations"`
+	Networks   []*WirelessNetwork `json:"networks"`
+	Probes     []SSIDCount        `json:"probes"`
+}
+
+// NodeInfo carries the asset label, DHCP lease, device role, health checks, DHCP server and
+// reputation of an IP ("node_info"). Asset, Lease and Reputation are left raw; a JSON null Asset or Lease means it
+// was removed. Role updates come on their own, without the other fields.
+type NodeInfo struct {
+	Type       string          `json:"type"`
+	IP         string          `json:"ip"`
+	Label      string          `json:"label,omitempty"`
+	Asset      json.RawMessage `json:"asset,omitempty"`
+	Lease      json.RawMessage `json:"lease,omitempty"` // ip, mac, hostname, expires, source
+	Health     []HealthStatus  `json:"health,omitempty"`
+	DHCPServer *DHCPServer     `json:"dhcp_server,omitempty"`
+	Reputation json.RawMessage `json:"reputation,omitempty"`
+	Role       *NodeRole       `json:"role,omitempty"`
+	GroupNode  bool            `json:"group_node,omitempty"` // a synthetic multicast/broadcast node
+}
+
+// HealthStatus is where one of a server's health checks stands
+type HealthStatus struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"` // http, tcp or dns
+	Host      string    `json:"host"`
+	State     string    `json:"state"` // unknown, up or down
+	Since     time.Time `json:"since"`
+	LastCheck time.Time `json:"last_check,omitempty"`
+	LatencyMs float64   `json:"latency_ms,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DHCPServer is a DHCP server heard answering clients
+type DHCPServer struct {
+	IP         string    `json:"ip"`
+	MAC        string    `json:"mac,omitempty"`
+	Authorized bool      `json:"authorized"` // listed in dhcp_guard, or no servers are listed
+	Offers     int64     `json:"offers"`
+	Clients    int       `json:"clients"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// NodeRole is a host's device role guessed from the ports it serves and connects to
+type NodeRole struct {
+	Role       string             `json:"role"`       // server, client, printer, iot or infrastructure
+	Confidence float64            `json:"confidence"` // 0-1
+	Scores     map[string]float64 `json:"scores"`     // evidence per role
+	Services   []int              `json:"services"`   // ports served, busiest first; empty without ports exposure
+}
+
+// EdgeUpdate is the traffic between two hosts over one summary interval
+type EdgeUpdate struct {
+	Src       string `json:"src"`
+	Dst       string `json:"dst"`
+	Packets   int64  `json:"packets"`
+	Bytes     int64  `json:"bytes"`
+	Intensity string `json:"intensity,omitempty"` // quiet, normal or hot
+}
+
+// EdgeSummary replaces packets once a second on the summary stream ("edge_summary")
+type EdgeSummary struct {
+	Type       string        `json:"type"`
+	Timestamp  int64         `json:"timestamp"`
+	IntervalMs int64         `json:"interval_ms"`
+	Edges      []*EdgeUpdate `json:"edges"` // busiest first
+	Truncated  int           `json:"truncated,omitempty"`
+}
+
+// Conversation is the traffic between two hosts in both directions; A is the lower address
+type Conversation struct {
+	A         string `json:"a"`
+	B         string `json:"b"`
+	Packets   int64  `json:"packets"`
+	Bytes     int64  `json:"bytes"`
+	PacketsAB int64  `json:"packets_ab"`
+	BytesAB   int64  `json:"bytes_ab"`
+	PacketsBA int64  `json:"packets_ba"`
+	BytesBA   int64  `json:"bytes_ba"`
+	Intensity string `json:"intensity,omitempty"` // quiet, normal or hot
+}
+
+// ConversationSummary replaces packets once a second on the conversations stream
+// ("conversation_summary")
+type ConversationSummary struct {
+	Type          string          `json:"type"`
+	Timestamp     int64           `json:"timestamp"`
+	IntervalMs    int64           `json:"interval_ms"`
+	Conversations []*Conversation `json:"conversations"` // busiest first
+	Truncated     int             `json:"truncated,omitempty"`
+}
+
+// StreamMode reports a switch between the raw, summary and conversations streams ("stream_mode")
+type StreamMode struct {
+	Type   string      `json:"type"`
+	Stream string      `json:"stream"` // raw, summary or conversations
+	Reason string      `json:"reason"` // requested or slow_client
+	ID     interface{} `json:"id,omitempty"`
+}
+
+// Drops reports messages the server dropped because this client fell behind ("drops")
+type Drops struct {
+	Type       string `json:"type"`
+	Dropped    uint64 `json:"dropped"` // since the previous report
+	IntervalMs int64  `json:"interval_ms"`
+	Total      uint64 `json:"total"`
+	Coalesce   bool   `json:"coalesce"` // the oldest queued messages were dropped rather than the newest
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// Heartbeat reports this session's latency every 5 s ("heartbeat"); Client acknowledges each
+// one so the server can time the round trip through the consumer too
+type Heartbeat struct {
+	Type       string  `json:"type"`
+	Seq        uint64  `json:"seq"`
+	Timestamp  int64   `json:"timestamp"`
+	RTTMs      float64 `json:"rtt_ms,omitempty"`     // WebSocket ping round trip
+	AppRTTMs   float64 `json:"app_rtt_ms,omitempty"` // heartbeat to heartbeat_ack round trip
+	QueueLagMs float64 `json:"queue_lag_ms"`         // time the previous heartbeat spent in the server's send queue
+	Queued     int     `json:"queued"`
+	Stale      bool    `json:"stale,omitempty"`
+}
+
+// ReplayProgress reports how far a PCAP replay or time window playback has got, every second
+// while it runs and once when it ends ("replay_progress"). Times are the capture's own, in Unix ms.
+type ReplayProgress struct {
+	Type       string  `json:"type"`
+	Source     string  `json:"source"` // pcap_replay or time_window
+	Position   int64   `json:"position"`
+	Start      int64   `json:"start"`
+	End        int64   `json:"end"`
+	Percent    float64 `json:"percent"`
+	Packets    uint64  `json:"packets"`
+	Total      int64   `json:"total,omitempty"` // packets in the file (PCAP replay only)
+	Speed      float64 `json:"speed"`
+	ETASeconds float64 `json:"eta_seconds"`
+	Done       bool    `json:"done,omitempty"`
+}
+
+// ReplayComplete reports a PCAP replay that reached the end of its file or time range
+// ("replay_complete"). Start and End are the capture times of its first and last packets, in Unix ms.
+type ReplayComplete struct {
+	Type       string `json:"type"`
+	Source     string `json:"source"`
+	File       string `json:"file"`
+	Packets    uint64 `json:"packets"`
+	Bytes      uint64 `json:"bytes"`
+	Skipped    uint64 `json:"skipped,omitempty"`   // before the time range
+	Undecoded  uint64 `json:"undecoded,omitempty"` // not IP traffic
+	Dropped    uint64 `json:"dropped,omitempty"`
+	Start      int64  `json:"start"`
+	End        int64  `json:"end"`
+	DurationMs int64  `json:"duration_ms"`
+	OnComplete string `json:"on_complete"` // what the session does next: loop, switch_to_live or stop
+	Mode       string `json:"mode"`        // the session's capture mode from now on
+}
+
+// StorageWarning reports an archive filesystem running out of space, or a damaged capture file
+// repaired or quarantined ("storage_warning")
+type StorageWarning struct {
+	Type      string `json:"type"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// AlertAck reports an alert acknowledged through /api/alerts/ack ("alert_ack")
+type AlertAck struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`   // the alert's dedup key
+	Alert     string `json:"alert"` // the alert's type
+	Kind      string `json:"kind,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Severity  string `json:"severity"` // info, warning or critical
+	AckedBy   string `json:"acked_by,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// SensorAlert reports a relay sensor that went silent or recovered ("sensor_alert")
+type SensorAlert struct {
+	Type       string    `json:"type"`
+	Kind       string    `json:"kind"` // sensor_silent or sensor_recovered
+	Sensor     string    `json:"sensor"`
+	Connected  bool      `json:"connected"`
+	LastSeen   time.Time `json:"last_seen"`
+	SilentForS float64   `json:"silent_for_s,omitempty"`
+	Timestamp  int64     `json:"timestamp"`
+}
+
+// NewDevice reports a host seen sending on a monitored subnet for the first time ("new_device")
+type NewDevice struct {
+	Type      string `json:"type"`
+	IP        string `json:"ip"`
+	MAC       string `json:"mac,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+	Timestamp int64  `json:"timestamp"` // first seen (ms)
+}
+
+// NewEdge reports a client reaching a service it never has before, with a host on a new_edges
+// subnet at one end ("new_edge")
+type NewEdge struct {
+	Type      string `json:"type"`
+	IP        string `json:"ip"` // the client
+	Server    string `json:"server"`
+	Port      int    `json:"port"` // 0 in rooms without ports exposure
+	Protocol  string `json:"protocol"`
+	Timestamp int64  `json:"timestamp"` // first seen (ms)
+}
+
+// KnownHosts lists hosts from the gateways' ARP and neighbor tables, so they can be drawn before
+// they send ("known_hosts"). Full marks the whole table, sent when the session starts.
+type KnownHosts struct {
+	Type      string      `json:"type"`
+	Hosts     []KnownHost `json:"hosts"`
+	Removed   []string    `json:"removed,omitempty"` // forgotten by every gateway
+	Full      bool        `json:"full,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// KnownHost is one host a gateway knows; MAC is left out where hostnames are hidden
+type KnownHost struct {
+	IP        string `json:"ip"`
+	MAC       string `json:"mac,omitempty"`
+	Interface string `json:"interface,omitempty"` // the gateway's interface or VLAN
+}
+
+// DarkSpace reports a packet from an address range that should never send ("dark_space")
+type DarkSpace struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	IP        string `json:"ip"`    // the source in dark space
+	Range     string `json:"range"` // name of the config file's dark_space entry
+	Dst       string `json:"dst"`
+	Protocol  string `json:"protocol"`
+	Count     int    `json:"count"` // packets from the source since its previous alert
+}
+
+// Exfiltration reports an internal host sending more to external addresses over the window than
+// the config file's exfiltration section allows (volume), or far more than usual (baseline)
+// ("exfiltration")
+type Exfiltration struct {
+	Type      string  `json:"type"`
+	Kind      string  `json:"kind"` // volume or baseline
+	Timestamp int64   `json:"timestamp"`
+	IP        string  `json:"ip"` // the internal host
+	Dst       string  `json:"dst"`
+	Bytes     int64   `json:"bytes"`
+	Window    int     `json:"window_seconds"`
+	Limit     int64   `json:"limit"`
+	Baseline  int64   `json:"baseline,omitempty"`
+	Ratio     float64 `json:"ratio,omitempty"`
+}
+
+// RogueRA reports an IPv6 Router Advertisement the config file's router_advertisements section
+// doesn't allow ("rogue_ra")
+type RogueRA struct {
+	Type      string   `json:"type"`
+	Kind      string   `json:"kind"` // router or prefix
+	Timestamp int64    `json:"timestamp"`
+	IP        string   `json:"ip"` // the advertising router
+	MAC       string   `json:"mac,omitempty"`
+	Interface string   `json:"interface"`
+	Lifetime  int      `json:"router_lifetime"`
+	Prefixes  []string `json:"prefixes,omitempty"`
+	Count     int      `json:"count"`
+}
+
+// DHCPAnomaly reports a DHCP server dhcp_guard doesn't list answering clients (rogue_server), or
+// a flood of DISCOVERs on a link (starvation) ("dhcp_anomaly")
+type DHCPAnomaly struct {
+	Type      string  `json:"type"`
+	Kind      string  `json:"kind"` // rogue_server or starvation
+	Timestamp int64   `json:"timestamp"`
+	Interface string  `json:"interface"`
+	IP        string  `json:"ip,omitempty"`
+	MAC       string  `json:"mac,omitempty"`
+	Offered   string  `json:"offered,omitempty"`
+	Client    string  `json:"client,omitempty"`
+	Count     int     `json:"count,omitempty"`
+	Discovers int     `json:"discovers,omitempty"`
+	Clients   int     `json:"clients,omitempty"`
+	TopSource string  `json:"top_source,omitempty"`
+	TopShare  float64 `json:"top_share,omitempty"`
+}
+
+// ClockSkew reports a clock offset the server corrected in a merged or replayed stream ("clock_skew")
+type ClockSkew struct {
+	Type      string `json:"type"`
+	Source    string `json:"source"` // sensor or archive
+	Sensor    string `json:"sensor,omitempty"`
+	File      string `json:"file,omitempty"`
+	OffsetMs  int64  `json:"offset_ms"` // subtracted from the source's timestamps
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ScenarioStarted reports that a room's simulated sessions switched scenario ("scenario_started")
+type ScenarioStarted struct {
+	Type        string `json:"type"`
+	Room        string `json:"room"`
+	Scenario    string `json:"scenario"`
+	Description string `json:"description,omitempty"`
+	Sessions    int    `json:"sessions"`
+}
+
+// ScenarioTriggered reports an attack started in a room's simulated sessions ("scenario_triggered")
+type ScenarioTriggered struct {
+	Type        string      `json:"type"`
+	Room        string      `json:"room"`
+	Attack      string      `json:"attack"`
+	Kind        string      `json:"kind"`
+	Description string      `json:"description,omitempty"`
+	Source      string      `json:"source,omitempty"` // the attacking host, when there is only one
+	Target      string      `json:"target,omitempty"` // the victim, when there is only one
+	Sources     int         `json:"sources"`
+	Targets     int         `json:"targets"`
+	DurationS   float64     `json:"duration_s"`
+	Sessions    int         `json:"sessions"`
+	Timestamp   int64       `json:"timestamp"`
+	ID          interface{} `json:"id,omitempty"`
+}
+
+// Probe follows a ping or traceroute the server runs for a room: "probe_started", then a
+// "probe_result" per reply or hop, then "probe_complete"
+type Probe struct {
+	Type      string        `json:"type"`
+	Probe     string        `json:"probe"`
+	Kind      string        `json:"kind"` // ping or traceroute
+	Target    string        `json:"target"`
+	Room      string        `json:"room"`
+	Result    *ProbeResult  `json:"result,omitempty"`
+	Summary   *ProbeSummary `json:"summary,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Timestamp int64         `json:"timestamp"`
+	ID        interface{}   `json:"id,omitempty"`
+}
+
+// ProbeResult is one ping reply or loss, or one traceroute hop
+type ProbeResult struct {
+	Seq     int       `json:"seq"` // the echo request or the hop
+	From    string    `json:"from,omitempty"`
+	RTTMs   []float64 `json:"rtt_ms,omitempty"`
+	Timeout bool      `json:"timeout,omitempty"`
+	Line    string    `json:"line"`
+}
+
+// ProbeSummary is how a probe ended
+type ProbeSummary struct {
+	Sent     int     `json:"sent,omitempty"`
+	Received int     `json:"received,omitempty"`
+	Loss     float64 `json:"loss,omitempty"`
+	Hops     int     `json:"hops,omitempty"`
+	Reached  bool    `json:"reached,omitempty"`
+}
+
+// RingDump answers dump_ring with the PCAP written from the session's packet ring ("ring_dump")
+type RingDump struct {
+	Type    string      `json:"type"`
+	File    string      `json:"file"`
+	IP      string      `json:"ip,omitempty"`
+	From    time.Time   `json:"from,omitempty"`
+	To      time.Time   `json:"to,omitempty"`
+	Packets int64       `json:"packets"`
+	Bytes   int64       `json:"bytes"`
+	Skipped int64       `json:"skipped"`
+	ID      interface{} `json:"id,omitempty"`
+}
+
+// ProfileSwitched reports a room moving to another capture profile ("profile_switched")
+type ProfileSwitched struct {
+	Type       string      `json:"type"`
+	Room       string      `json:"room"`
+	Profile    string      `json:"profile"`
+	Mode       string      `json:"mode"`
+	Sessions   int         `json:"sessions"`
+	SampleRate float64     `json:"sample_rate"`
+	Enrich     []string    `json:"enrich,omitempty"` // nil when every enrichment runs
+	ID         interface{} `json:"id,omitempty"`
+}
+
+// Annotation is an operator's note to the room ("annotation"), sent when it is made and again
+// when a time window replay reaches it
+type Annotation struct {
+	Type      string      `json:"type"`
+	Room      string      `json:"room"`
+	Text      string      `json:"text"`
+	Marker    string      `json:"marker,omitempty"`
+	Timestamp int64       `json:"timestamp"` // Unix milliseconds, when it was made
+	Replay    bool        `json:"replay,omitempty"`
+	ID        interface{} `json:"id,omitempty"`
+}
+
+// Bookmark is a saved moment with the view the room had then
+type Bookmark struct {
+	ID          string          `json:"id"`
+	Room        string          `json:"room"`
+	Time        time.Time       `json:"time"`
+	Description string          `json:"description,omitempty"`
+	Preset      string          `json:"preset,omitempty"`
+	Pins        []string        `json:"pins"`
+	Filter      json.RawMessage `json:"filter,omitempty"`
+	SampleRate  float64         `json:"sample_rate"`
+	CreatedAt   time.Time       `json:"created_at"`
+	CreatedBy   string          `json:"created_by,omitempty"`
+}
+
+// BookmarkAdded reports a bookmark made in the room ("bookmark_added")
+type BookmarkAdded struct {
+	Type     string      `json:"type"`
+	Bookmark Bookmark    `json:"bookmark"`
+	ID       interface{} `json:"id,omitempty"`
+}
+
+// PinExpired reports a temporary pin the room's rules no longer hold ("pin_expired")
+type PinExpired struct {
+	Type    string    `json:"type"`
+	Room    string    `json:"room"`
+	Rule    string    `json:"rule"`
+	Expired time.Time `json:"expired"`
+}
+
+// TimelineEvent is an alert, scan or mode switch from the timeline, sent when time window playback
+// reaches the moment it happened ("timeline_event")
+type TimelineEvent struct {
+	Type      string          `json:"type"`
+	Room      string          `json:"room"`
+	Kind      string          `json:"kind"` // alert, scan or mode
+	Text      string          `json:"text"`
+	Timestamp int64           `json:"timestamp"`         // Unix milliseconds, when it happened
+	Details   json.RawMessage `json:"details,omitempty"` // alerts and scans: the original message
+	Replay    bool            `json:"replay"`
+}
+
+// Error reports a command the server rejected ("error")
+type Error struct {
+	Type    string      `json:"type"`
+	Code    string      `json:"code"` // invalid_json, missing_type, unknown_command, missing_field, invalid_field, unauthorized
+	Message string      `json:"message"`
+	Command string      `json:"command,omitempty"`
+	Field   string      `json:"field,omitempty"`
+	ID      interface{} `json:"id,omitempty"` // id of the rejected command
+}
+
+// Ack confirms a command that has no dedicated reply ("ack"); only sent when the command had an id
+type Ack struct {
+	Type    string      `json:"type"`
+	Command string      `json:"command"`
+	ID      interface{} `json:"id"`
+}
+
+// Unknown is any message type this package doesn't model; Raw is the original JSON
+type Unknown struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (m *Packet) MessageType() string              { return m.Type }
+func (m *Mode) MessageType() string                { return m.Type }
+func (m *ConnEvent) MessageType() string           { return m.Type }
+func (m *TCPStats) MessageType() string            { return m.Type }
+func (m *Alert) MessageType() string               { return m.Type }
+func (m *GroupStats) MessageType() string          { return m.Type }
+func (m *ProtocolStats) MessageType() string       { return m.Type }
+func (m *SizeStats) MessageType() string           { return m.Type }
+func (m *SizeAlert) MessageType() string           { return m.Type }
+func (m *FragmentStats) MessageType() string       { return m.Type }
+func (m *DedupStats) MessageType() string          { return m.Type }
+func (m *InterfaceStats) MessageType() string      { return m.Type }
+func (m *LayoutHints) MessageType() string         { return m.Type }
+func (m *TTLStats) MessageType() string            { return m.Type }
+func (m *LatencyStats) MessageType() string        { return m.Type }
+func (m *TTLAlert) MessageType() string            { return m.Type }
+func (m *WirelessStats) MessageType() string       { return m.Type }
+func (m *NodeInfo) MessageType() string            { return m.Type }
+func (m *EdgeSummary) MessageType() string         { return m.Type }
+func (m *ConversationSummary) MessageType() string { return m.Type }
+func (m *StreamMode) MessageType() string          { return m.Type }
+func (m *Heartbeat) MessageType() string           { return m.Type }
+func (m *ReplayProgress) MessageType() string      { return m.Type }
+func (m *ReplayComplete) MessageType() string      { return m.Type }
+func (m *Drops) MessageType() string               { return m.Type }
+func (m *StorageWarning) MessageType() string      { return m.Type }
+func (m *AlertAck) MessageType() string            { return m.Type }
+func (m *SensorAlert) MessageType() string         { return m.Type }
+func (m *NewDevice) MessageType() string           { return m.Type }
+func (m *NewEdge) MessageType() string             { return m.Type }
+func (m *KnownHosts) MessageType() string          { return m.Type }
+func (m *DarkSpace) MessageType() string           { return m.Type }
+func (m *Exfiltration) MessageType() string        { return m.Type }
+func (m *RogueRA) MessageType() string             { return m.Type }
+func (m *DHCPAnomaly) MessageType() string         { return m.Type }
+func (m *ClockSkew) MessageType() string           { return m.Type }
+func (m *ScenarioStarted) MessageType() string     { return m.Type }
+func (m *ScenarioTriggered) MessageType() string   { return m.Type }
+func (m *Probe) MessageType() string               { return m.Type }
+func (m *RingDump) MessageType() string            { return m.Type }
+func (m *ProfileSwitched) MessageType() string     { return m.Type }
+func (m *Annotation) MessageType() string          { return m.Type }
+func (m *TimelineEvent) MessageType() string       { return m.Type }
+func (m *BookmarkAdded) MessageType() string       { return m.Type }
+func (m *PinExpired) MessageType() string          { return m.Type }
+func (m *Error) MessageType() string               { return m.Type }
+func (m *Ack) MessageType() string                 { return m.Type }
+func (m *Unknown) MessageType() string             { return m.Type }
+
+// Decode parses one WebSocket text frame into its typed message
+func Decode(data []byte) (Message, error) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Type == "" {
+		return nil, fmt.Errorf("message has no type")
+	}
+
+	var msg Message
+	switch envelope.Type {
+	case "packet":
+		msg = &Packet{}
+	case "mode":
+		msg = &Mode{}
+	case "conn_open", "conn_close":
+		msg = &ConnEvent{}
+	case "tcp_stats":
+		msg = &TCPStats{}
+	case "tcp_anomaly":
+		msg = &Alert{}
+	case "group_stats":
+		msg = &GroupStats{}
+	case "protocol_stats":
+		msg = &ProtocolStats{}
+	case "size_stats":
+		msg = &SizeStats{}
+	case "size_anomaly":
+		msg = &SizeAlert{}
+	case "fragment_stats":
+		msg = &FragmentStats{}
+	case "dedup_stats":
+		msg = &DedupStats{}
+	case "interface_stats":
+		msg = &InterfaceStats{}
+	case "layout_hints":
+		msg = &LayoutHints{}
+	case "ttl_stats":
+		msg = &TTLStats{}
+	case "ttl_anomaly":
+		msg = &TTLAlert{}
+	case "latency_stats":
+		msg = &LatencyStats{}
+	case "wifi_stats":
+		msg = &WirelessStats{}
+	case "node_info":
+		msg = &NodeInfo{}
+	case "edge_summary":
+		msg = &EdgeSummary{}
+	case "conversation_summary":
+		msg = &ConversationSummary{}
+	case "stream_mode":
+		msg = &StreamMode{}
+	case "drops":
+		msg = &Drops{}
+	case "heartbeat":
+		msg = &Heartbeat{}
+	case "replay_progress":
+		msg = &ReplayProgress{}
+	case "replay_complete":
+		msg = &ReplayComplete{}
+	case "storage_warning":
+		msg = &StorageWarning{}
+	case "alert_ack":
+		msg = &AlertAck{}
+	case "sensor_alert":
+		msg = &SensorAlert{}
+	case "new_device":
+		msg = &NewDevice{}
+	case "new_edge":
+		msg = &NewEdge{}
+	case "known_hosts":
+		msg = &KnownHosts{}
+	case "dark_space":
+		msg = &DarkSpace{}
+	case "exfiltration":
+		msg = &Exfiltration{}
+	case "rogue_ra":
+		msg = &RogueRA{}
+	case "dhcp_anomaly":
+		msg = &DHCPAnomaly{}
+	case "clock_skew":
+		msg = &ClockSkew{}
+	case "scenario_started":
+		msg = &ScenarioStarted{}
+	case "scenario_triggered":
+		msg = &ScenarioTriggered{}
+	case "probe_started", "probe_result", "probe_complete":
+		msg = &Probe{}
+	case "ring_dump":
+		msg = &RingDump{}
+	case "profile_switched":
+		msg = &ProfileSwitched{}
+	case "annotation":
+		msg = &Annotation{}
+	case "timeline_event":
+		msg = &TimelineEvent{}
+	case "bookmark_added":
+		msg = &BookmarkAdded{}
+	case "pin_expired":
+		msg = &PinExpired{}
+	case "error":
+		msg = &Error{}
+	case "ack":
+		msg = &Ack{}
+	default:
+		return &Unknown{Type: envelope.Type, Raw: append(json.RawMessage(nil), data...)}, nil
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", envelope.Type, err)
+	}
+	return msg, nil
+}
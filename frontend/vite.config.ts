@@ -14,6 +14,11 @@ export default defineConfig(({ mode }) => {
 
   return {
     plugins: [react(), tsconfigPaths()],
+    build: {
+      // The backend embeds this directory, so `go build` after `npm run build` ships one binary
+      outDir: '../backend/internal/web/dist',
+      emptyOutDir: true,
+    },
     server: {
       proxy: {
         '/ws': {